@@ -25,7 +25,7 @@ var diffCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Diff(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		resp, err := apiCl.Diff(context.Background(), api.TargetOperationInput{Path: gitPath, Name: manifestName, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,6 +41,7 @@ func init() {
 	// TODO these should be '-' separated.
 	diffCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	diffCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	diffCmd.Flags().StringVar(&manifestName, "name", "", "Name of the workflow to use in a manifest with several")
 	diffCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
 	diffCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
@@ -25,7 +25,7 @@ var execCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Exec(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		resp, err := apiCl.Exec(context.Background(), api.TargetOperationInput{Path: gitPath, Name: manifestName, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,6 +41,7 @@ func init() {
 	// TODO these should be '-' separated.
 	execCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	execCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	execCmd.Flags().StringVar(&manifestName, "name", "", "Name of the workflow to use in a manifest with several")
 	execCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
 	execCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
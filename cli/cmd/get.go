@@ -18,7 +18,12 @@ var getCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		name := args[0]
-		apiCl := api.NewClient(argoCloudOpsServiceAddr(), "")
+		token, err := argoCloudOpsUserToken()
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
 		status, err := apiCl.GetWorkflowStatus(context.Background(), name)
 		if err != nil {
@@ -20,18 +20,32 @@ var logsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		workflowName := args[0]
 
-		apiCl := api.NewClient(argoCloudOpsServiceAddr(), "")
+		token, err := argoCloudOpsUserToken()
+		if err != nil {
+			cobra.CheckErr(err)
+		}
+
+		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
 		ctx := context.Background()
 		if streamLogs {
 			// This is a _very_ simple approach to streaming.
 			cobra.CheckErr(apiCl.StreamLogs(ctx, os.Stdout, workflowName))
 		} else {
-			resp, err := apiCl.GetLogs(ctx, workflowName)
-			if err != nil {
-				cobra.CheckErr(err)
+			// Logs are returned in chunks, printed as they're received.
+			var continueToken string
+			for {
+				resp, err := apiCl.GetLogs(ctx, workflowName, continueToken)
+				if err != nil {
+					cobra.CheckErr(err)
+				}
+				fmt.Println(strings.Join(resp.Logs, "\n"))
+
+				if resp.Continue == "" {
+					break
+				}
+				continueToken = resp.Continue
 			}
-			fmt.Println(strings.Join(resp.Logs, "\n"))
 		}
 	},
 }
@@ -21,6 +21,7 @@ var (
 	framework               string
 	gitPath                 string
 	gitSHA                  string
+	manifestName            string
 	parametersCSV           string
 	projectName             string
 	streamLogs              bool
@@ -25,7 +25,7 @@ var syncCmd = &cobra.Command{
 
 		apiCl := api.NewClient(argoCloudOpsServiceAddr(), token)
 
-		resp, err := apiCl.Sync(context.Background(), api.TargetOperationInput{Path: gitPath, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
+		resp, err := apiCl.Sync(context.Background(), api.TargetOperationInput{Path: gitPath, Name: manifestName, ProjectName: projectName, SHA: gitSHA, TargetName: targetName})
 		if err != nil {
 			cobra.CheckErr(err)
 		}
@@ -41,6 +41,7 @@ func init() {
 	// TODO these should be '-' separated.
 	syncCmd.Flags().StringVarP(&gitPath, "path", "p", "", "Path to manifest within git repository")
 	syncCmd.Flags().StringVarP(&gitSHA, "sha", "s", "", "Commit sha to use when creating workflow through git")
+	syncCmd.Flags().StringVar(&manifestName, "name", "", "Name of the workflow to use in a manifest with several")
 	syncCmd.Flags().StringVarP(&projectName, "project_name", "n", "", "Name of project")
 	// TODO inconsistent
 	syncCmd.Flags().StringVarP(&targetName, "target", "t", "", "Name of target")
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"time"
 
@@ -55,15 +56,21 @@ func NewClient(endpoint, authToken string) Client {
 
 // TargetOperationInput represents the input to a targetOperation.
 type TargetOperationInput struct {
-	Path        string
+	Path string
+	// Name selects the workflow of a manifest with several.
+	Name        string
 	ProjectName string
 	SHA         string
 	TargetName  string
 }
 
-// GetLogs gets the logs of a workflow.
-func (c *Client) GetLogs(ctx context.Context, workflowName string) (responses.GetLogs, error) {
+// GetLogs gets a chunk of the logs of a workflow, the first one or the one of
+// the continue token of the previous chunk.
+func (c *Client) GetLogs(ctx context.Context, workflowName, continueToken string) (responses.GetLogs, error) {
 	url := fmt.Sprintf("%s/workflows/%s/logs", c.endpoint, workflowName)
+	if continueToken != "" {
+		url += "?continue=" + neturl.QueryEscape(continueToken)
+	}
 
 	body, err := c.getRequest(ctx, url)
 	if err != nil {
@@ -105,6 +112,8 @@ func (c *Client) streamLogsToWriterAtCursor(ctx context.Context, w io.Writer, wo
 		return fmt.Errorf("unable to create api request: %w", err)
 	}
 
+	req.Header.Add("Authorization", c.authToken)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("unable to make api call: %w", err)
@@ -239,6 +248,8 @@ func (c *Client) getRequest(ctx context.Context, url string) ([]byte, error) {
 		return nil, fmt.Errorf("unable to create api request: %w", err)
 	}
 
+	req.Header.Add("Authorization", c.authToken)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("unable to make api call: %w", err)
@@ -263,6 +274,7 @@ func (c *Client) targetOperation(ctx context.Context, input TargetOperationInput
 	targetReq := requests.TargetOperation{
 		Path: input.Path,
 		SHA:  input.SHA,
+		Name: input.Name,
 		Type: operationType,
 	}
 
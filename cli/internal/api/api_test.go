@@ -30,6 +30,8 @@ func TestGetLogs(t *testing.T) {
 		endpoint              string          // Used to create new request error.
 		mockHTTPClient        *mockHTTPClient // Only used when needed.
 		writeBadContentLength bool            // Used to create response body error.
+		continueToken         string
+		wantQuery             string
 		want                  responses.GetLogs
 		wantErr               error
 	}{
@@ -52,6 +54,17 @@ func TestGetLogs(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:              "good continued",
+			apiRespBody:       []byte(`{"logs":["line 11"],"continue":"d29ya2Zsb3cxLzEy"}`),
+			apiRespStatusCode: http.StatusOK,
+			continueToken:     "d29ya2Zsb3cxLzEx",
+			wantQuery:         "continue=d29ya2Zsb3cxLzEx",
+			want: responses.GetLogs{
+				Logs:     []string{"line 11"},
+				Continue: "d29ya2Zsb3cxLzEy",
+			},
+		},
 		{
 			name:              "error non-200 response",
 			apiRespBody:       []byte("boom"),
@@ -97,6 +110,9 @@ func TestGetLogs(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+				assert.Equal(t, tt.wantQuery, r.URL.RawQuery)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -106,6 +122,7 @@ func TestGetLogs(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -118,7 +135,7 @@ func TestGetLogs(t *testing.T) {
 				client.httpClient = tt.mockHTTPClient
 			}
 
-			output, err := client.GetLogs(context.Background(), "workflow1")
+			output, err := client.GetLogs(context.Background(), "workflow1", tt.continueToken)
 
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
@@ -186,6 +203,8 @@ func TestStreamLogs(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -195,6 +214,7 @@ func TestStreamLogs(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -289,6 +309,8 @@ func TestGetWorkflowStatus(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -298,6 +320,7 @@ func TestGetWorkflowStatus(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -384,6 +407,8 @@ func TestGetWorkflows(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, r.Header.Get("Authorization"), authToken)
+
 				if tt.writeBadContentLength {
 					w.Header().Set("Content-Length", "1")
 				}
@@ -393,6 +418,7 @@ func TestGetWorkflows(t *testing.T) {
 			defer server.Close()
 
 			client := Client{
+				authToken:  authToken,
 				endpoint:   server.URL,
 				httpClient: &http.Client{},
 			}
@@ -522,10 +548,10 @@ func TestDiff(t *testing.T) {
 			got, err := client.Diff(
 				context.Background(),
 				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
+					Path:        "./prod/target1.yaml",
+					ProjectName: "project1",
+					SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+					TargetName:  "target1",
 				},
 			)
 
@@ -763,10 +789,10 @@ func TestSync(t *testing.T) {
 			got, err := client.Sync(
 				context.Background(),
 				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
+					Path:        "./prod/target1.yaml",
+					ProjectName: "project1",
+					SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+					TargetName:  "target1",
 				},
 			)
 
@@ -884,10 +910,10 @@ func TestExec(t *testing.T) {
 			got, err := client.Exec(
 				context.Background(),
 				TargetOperationInput{
-					"./prod/target1.yaml",
-					"project1",
-					"7fa96067f580a20c3908f5b872377181091ffaec",
-					"target1",
+					Path:        "./prod/target1.yaml",
+					ProjectName: "project1",
+					SHA:         "7fa96067f580a20c3908f5b872377181091ffaec",
+					TargetName:  "target1",
 				},
 			)
 
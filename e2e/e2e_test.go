@@ -0,0 +1,272 @@
+//go:build e2e
+// +build e2e
+
+// Package e2e runs the service against real Vault, Postgres and Argo
+// Workflows, set up by scripts/e2e.sh (make e2e).
+package e2e
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	projectName = "e2eproject"
+	targetName  = "e2etarget"
+	// Public images are pulled by the kind cluster.
+	executeImage = "alpine:3.15"
+)
+
+// service is the running service under test.
+type service struct {
+	t         *testing.T
+	addr      string
+	client    *http.Client
+	adminAuth string
+}
+
+// startService builds the service and runs it with the environment of the
+// test, which scripts/e2e.sh points at its dependencies.
+func startService(t *testing.T) *service {
+	adminSecret := os.Getenv("ARGO_CLOUDOPS_ADMIN_SECRET")
+	if adminSecret == "" {
+		t.Skip("ARGO_CLOUDOPS_ADMIN_SECRET isn't set, run the end to end tests with make e2e")
+	}
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "service")
+	build := exec.Command("go", "build", "-o", bin, "../service")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("unable to build service: %v\n%s", err, out)
+	}
+	writeCertificate(t, filepath.Join(dir, "ssl"))
+
+	port := freePort(t)
+	cmd := exec.Command(bin)
+	// The service reads the certificate relative to its working directory.
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), fmt.Sprintf("ARGO_CLOUDOPS_PORT=%d", port))
+	logs, err := os.Create(filepath.Join(dir, "service.log"))
+	if err != nil {
+		t.Fatalf("unable to create service log: %v", err)
+	}
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unable to start service: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		logs.Close()
+		if t.Failed() {
+			out, _ := os.ReadFile(logs.Name())
+			t.Logf("service logs:\n%s", out)
+		}
+	})
+
+	s := &service{
+		t:    t,
+		addr: fmt.Sprintf("https://127.0.0.1:%d", port),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// #nosec the certificate is generated by the test
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		},
+		adminAuth: "vault:admin:" + adminSecret,
+	}
+
+	ready := func() bool {
+		resp, err := s.client.Get(s.addr + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
+	if !waitFor(time.Minute, ready) {
+		t.Fatalf("service not ready")
+	}
+	return s
+}
+
+// do executes a request, decoding the JSON response into out when not nil.
+func (s *service) do(method, path, auth string, body interface{}, out interface{}) int {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("unable to serialize request: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.addr+path, reqBody)
+	if err != nil {
+		s.t.Fatalf("unable to create request: %v", err)
+	}
+	req.Header.Set("Authorization", auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatalf("unable to execute request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(data, out); err != nil {
+			s.t.Fatalf("unable to decode response '%s': %v", data, err)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.t.Logf("%s %s: %d %s", method, path, resp.StatusCode, data)
+	}
+	return resp.StatusCode
+}
+
+func TestCreateProjectToLogs(t *testing.T) {
+	s := startService(t)
+
+	// Leftovers of a previous run (E2E_KEEP=1) are removed first.
+	s.do(http.MethodDelete, "/projects/"+projectName, s.adminAuth, nil, nil)
+	t.Cleanup(func() { s.do(http.MethodDelete, "/projects/"+projectName, s.adminAuth, nil, nil) })
+
+	var project struct {
+		Token string `json:"token"`
+	}
+	code := s.do(http.MethodPost, "/projects", s.adminAuth, map[string]string{
+		"name":       projectName,
+		"repository": "https://github.com/cello-proj/cello.git",
+	}, &project)
+	if !assert.Equal(t, http.StatusOK, code) {
+		return
+	}
+	userAuth := project.Token
+
+	code = s.do(http.MethodPost, "/projects/"+projectName+"/targets", s.adminAuth, map[string]interface{}{
+		"name": targetName,
+		"type": "aws_account",
+		"properties": map[string]interface{}{
+			"credential_type": "assumed_role",
+			"policy_arns":     []string{"arn:aws:iam::012345678901:policy/e2e"},
+			"role_arn":        "arn:aws:iam::012345678901:role/e2e",
+		},
+	}, nil)
+	if !assert.Equal(t, http.StatusOK, code) {
+		return
+	}
+
+	var targets []string
+	assert.Equal(t, http.StatusOK, s.do(http.MethodGet, "/projects/"+projectName+"/targets", s.adminAuth, nil, &targets))
+	assert.Equal(t, []string{targetName}, targets)
+
+	var created struct {
+		WorkflowName string `json:"workflow_name"`
+	}
+	code = s.do(http.MethodPost, "/workflows", userAuth, map[string]interface{}{
+		"project_name":           projectName,
+		"target_name":            targetName,
+		"framework":              "e2e",
+		"type":                   "sync",
+		"workflow_template_name": "cello-e2e",
+		"arguments":              map[string][]string{"execute": {"submitted"}},
+		"parameters":             map[string]string{"execute_container_image_uri": executeImage},
+	}, &created)
+	if !assert.Equal(t, http.StatusOK, code) {
+		return
+	}
+
+	var status responses.GetWorkflowStatus
+	completed := func() bool {
+		s.do(http.MethodGet, "/workflows/"+created.WorkflowName, userAuth, nil, &status)
+		return status.Status == "succeeded" || status.Status == "failed" || status.Status == "error"
+	}
+	if !waitFor(5*time.Minute, completed) {
+		t.Fatalf("workflow '%s' didn't complete, status '%s'", created.WorkflowName, status.Status)
+	}
+	assert.Equal(t, "succeeded", status.Status)
+
+	var logs responses.GetLogs
+	assert.Equal(t, http.StatusOK, s.do(http.MethodGet, "/workflows/"+created.WorkflowName+"/logs", userAuth, nil, &logs))
+	assert.Contains(t, strings.Join(logs.Logs, "\n"), "cello-e2e submitted")
+}
+
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// writeCertificate writes the self-signed certificate the service serves TLS
+// with into dir.
+func writeCertificate(t *testing.T, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cello-e2e"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("unable to create ssl directory: %v", err)
+	}
+	files := map[string]*pem.Block{
+		"certificate.crt": {Type: "CERTIFICATE", Bytes: der},
+		"certificate.key": {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	}
+	for name, block := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+#!/bin/bash
+
+set -e
+
+ansible_version=$1
+repo=$2
+
+usage() {
+    echo "$0 ANSIBLE_VERSION REPO"
+}
+
+if [ -z $ansible_version ]; then
+    usage
+    exit 1
+fi
+
+if [ -z $repo ]; then
+    usage
+    exit 1
+fi
+
+build_dir=$TMPDIR/docker-ansible
+
+rm -rf $build_dir
+
+mkdir -p $build_dir
+
+cp Dockerfile $build_dir
+cp requirements.txt $build_dir
+cp ../shared/setup.sh $build_dir
+cp ../shared/decrypt.sh $build_dir
+
+cd $build_dir
+
+sed -i '' "s/{{ANSIBLE_VERSION}}/$ansible_version/g" Dockerfile requirements.txt
+
+tags="-t $repo:$ansible_version -t $repo:latest"
+
+docker build . --no-cache $tags
+
+docker push $repo:$ansible_version
+docker push $repo:latest
@@ -0,0 +1,43 @@
+#!/bin/bash
+
+set -e
+
+awscli_version=$1
+repo=$2
+
+usage() {
+    echo "$0 AWSCLI_VERSION REPO"
+}
+
+if [ -z $awscli_version ]; then
+    usage
+    exit 1
+fi
+
+if [ -z $repo ]; then
+    usage
+    exit 1
+fi
+
+build_dir=$TMPDIR/docker-cloudformation
+
+rm -rf $build_dir
+
+mkdir -p $build_dir
+
+cp Dockerfile $build_dir
+cp requirements.txt $build_dir
+cp ../shared/setup.sh $build_dir
+cp ../shared/decrypt.sh $build_dir
+cp cfn-change-set.sh $build_dir
+
+cd $build_dir
+
+sed -i '' "s/{{AWSCLI_VERSION}}/$awscli_version/g" Dockerfile requirements.txt
+
+tags="-t $repo:$awscli_version -t $repo:latest"
+
+docker build . --no-cache $tags
+
+docker push $repo:$awscli_version
+docker push $repo:latest
@@ -0,0 +1,117 @@
+#!/bin/bash
+
+# Creates or executes a CloudFormation change set, for the diff and sync
+# commands of the cloudformation framework.
+#
+#   cfn-change-set create --stack-name NAME --change-set-name NAME --template-file PATH [ARGS]
+#   cfn-change-set execute --stack-name NAME --change-set-name NAME --template-file PATH
+#
+# Additional arguments of create are passed to aws cloudformation
+# create-change-set (e.g. --capabilities CAPABILITY_IAM CAPABILITY_AUTO_EXPAND
+# for SAM templates). With PACKAGE_S3_BUCKET set, local artifacts of the
+# template (e.g. SAM CodeUri) are uploaded with aws cloudformation package
+# first.
+#
+# create prints the summary of the change set on a line prefixed by
+# CELLO_CHANGE_SET_SUMMARY, which the service records.
+
+set -e
+
+usage() {
+    echo "$0 create|execute --stack-name NAME --change-set-name NAME --template-file PATH [ARGS]"
+}
+
+action=$1
+shift || true
+
+stack_name=
+change_set_name=
+template_file=
+args=()
+
+while [ $# -gt 0 ]; do
+    case "$1" in
+        --stack-name) stack_name=$2; shift 2 ;;
+        --change-set-name) change_set_name=$2; shift 2 ;;
+        --template-file) template_file=$2; shift 2 ;;
+        *) args+=("$1"); shift ;;
+    esac
+done
+
+if [ -z "$stack_name" ] || [ -z "$change_set_name" ] || [ -z "$template_file" ]; then
+    usage
+    exit 1
+fi
+
+create() {
+    if [ -n "$PACKAGE_S3_BUCKET" ]; then
+        aws cloudformation package \
+            --template-file "$template_file" \
+            --s3-bucket "$PACKAGE_S3_BUCKET" \
+            --output-template-file /tmp/packaged-template.yaml
+        template_file=/tmp/packaged-template.yaml
+    fi
+
+    change_set_type=UPDATE
+    if ! aws cloudformation describe-stacks --stack-name "$stack_name" > /dev/null 2>&1; then
+        change_set_type=CREATE
+    fi
+
+    aws cloudformation create-change-set \
+        --stack-name "$stack_name" \
+        --change-set-name "$change_set_name" \
+        --change-set-type "$change_set_type" \
+        --template-body "file://$template_file" \
+        "${args[@]}"
+
+    # Change sets without changes fail to create, which isn't an error of the
+    # diff.
+    aws cloudformation wait change-set-create-complete \
+        --stack-name "$stack_name" \
+        --change-set-name "$change_set_name" || true
+
+    summary=$(aws cloudformation describe-change-set \
+        --stack-name "$stack_name" \
+        --change-set-name "$change_set_name" \
+        --output json | jq -c '{
+            stack_name: .StackName,
+            change_set_name: .ChangeSetName,
+            status: .Status,
+            changes: [.Changes[].ResourceChange | {
+                action: .Action,
+                logical_resource_id: .LogicalResourceId,
+                resource_type: .ResourceType,
+                replacement: (.Replacement // "")
+            }]
+        }')
+    echo "CELLO_CHANGE_SET_SUMMARY $summary"
+
+    status=$(echo "$summary" | jq -r .status)
+    if [ "$status" = "FAILED" ]; then
+        aws cloudformation describe-change-set \
+            --stack-name "$stack_name" \
+            --change-set-name "$change_set_name" \
+            --query StatusReason --output text
+    fi
+}
+
+execute() {
+    aws cloudformation execute-change-set \
+        --stack-name "$stack_name" \
+        --change-set-name "$change_set_name"
+
+    stack_status=$(aws cloudformation describe-stacks \
+        --stack-name "$stack_name" \
+        --query 'Stacks[0].StackStatus' --output text)
+    if [ "$stack_status" = "CREATE_IN_PROGRESS" ] || [ "$stack_status" = "REVIEW_IN_PROGRESS" ]; then
+        aws cloudformation wait stack-create-complete --stack-name "$stack_name"
+    else
+        aws cloudformation wait stack-update-complete --stack-name "$stack_name"
+    fi
+}
+
+case "$action" in
+    create) create ;;
+    execute) execute ;;
+    *) usage; exit 1 ;;
+esac
@@ -0,0 +1,43 @@
+#!/bin/bash
+
+set -e
+
+helm_version=$1
+repo=$2
+
+usage() {
+    echo "$0 HELM_VERSION REPO"
+}
+
+if [ -z $helm_version ]; then
+    usage
+    exit 1
+fi
+
+if [ -z $repo ]; then
+    usage
+    exit 1
+fi
+
+build_dir=$TMPDIR/docker-helm
+
+\rm -rf $build_dir
+
+mkdir -p $build_dir
+
+cp Dockerfile $build_dir
+cp requirements.txt $build_dir
+cp ../shared/setup.sh $build_dir
+cp ../shared/decrypt.sh $build_dir
+cp helm-init.sh $build_dir
+
+cd $build_dir
+
+sed -i '' "s/{{HELM_VERSION}}/$helm_version/g" Dockerfile
+
+tags="-t $repo:$helm_version -t $repo:latest"
+
+docker build . --no-cache $tags
+
+docker push $repo:$helm_version
+docker push $repo:latest
@@ -0,0 +1,13 @@
+#!/bin/bash
+
+# Configures kubectl access for helm. With EKS_CLUSTER_NAME set, the kubeconfig
+# of the EKS cluster is generated with the AWS credentials of the target,
+# arguments are passed to aws eks update-kubeconfig (e.g. --role-arn). Otherwise
+# the kubeconfig of the container (e.g. KUBECONFIG) is used as is.
+
+set -e
+
+if [ -n "$EKS_CLUSTER_NAME" ]; then
+    aws eks update-kubeconfig --name "$EKS_CLUSTER_NAME" "$@"
+fi
+
@@ -0,0 +1,44 @@
+#!/bin/bash
+
+set -e
+
+kubectl_version=$1
+repo=$2
+
+usage() {
+    echo "$0 KUBECTL_VERSION REPO"
+}
+
+if [ -z $kubectl_version ]; then
+    usage
+    exit 1
+fi
+
+if [ -z $repo ]; then
+    usage
+    exit 1
+fi
+
+build_dir=$TMPDIR/docker-kubectl
+
+rm -rf $build_dir
+
+mkdir -p $build_dir
+
+cp Dockerfile $build_dir
+cp requirements.txt $build_dir
+cp ../shared/setup.sh $build_dir
+cp ../shared/decrypt.sh $build_dir
+cp kube-init.sh $build_dir
+cp kube-diff.sh $build_dir
+
+cd $build_dir
+
+sed -i '' "s/{{KUBECTL_VERSION}}/$kubectl_version/g" Dockerfile
+
+tags="-t $repo:$kubectl_version -t $repo:latest"
+
+docker build . --no-cache $tags
+
+docker push $repo:$kubectl_version
+docker push $repo:latest
@@ -0,0 +1,12 @@
+#!/bin/bash
+
+# Runs kubectl diff with the arguments. kubectl diff exits with 1 when there
+# are differences, which isn't an error of the diff command.
+
+kubectl diff "$@"
+status=$?
+
+if [ $status -eq 1 ]; then
+    exit 0
+fi
+exit $status
@@ -0,0 +1,38 @@
+#!/bin/bash
+
+# This is a sample script which decrypts the environment variables encrypted
+# with the public key of the project. It prints export statements for the
+# caller to evaluate, the values are never written to disk.
+#
+#   cello_env=$(bash /usr/local/bin/decrypt.sh) && eval "$cello_env"
+#
+# CELLO_ENCRYPTED_ENVIRONMENT holds the encrypted variables as space separated
+# NAME=enc:v1:<key id>:<base64 ciphertext> pairs. The private key is read from
+# CELLO_ENCRYPTION_KEY_FILE, mounted from the Kubernetes secret of the project.
+
+set -euo pipefail
+
+if [ -z "${CELLO_ENCRYPTED_ENVIRONMENT:-}" ]; then
+    exit 0
+fi
+
+key_file=${CELLO_ENCRYPTION_KEY_FILE:-/etc/cello/encryption/private_key}
+
+if [ ! -f "$key_file" ]; then
+    echo "Error: private key '$key_file' not found" >&2
+    exit 1
+fi
+
+for pair in $CELLO_ENCRYPTED_ENVIRONMENT; do
+    name=${pair%%=*}
+    ciphertext=${pair##*:}
+
+    echo "Decrypting '$name'." >&2
+    value=$(echo "$ciphertext" | base64 -d | \
+        openssl pkeyutl -decrypt -inkey "$key_file" \
+            -pkeyopt rsa_padding_mode:oaep \
+            -pkeyopt rsa_oaep_md:sha256 \
+            -pkeyopt rsa_mgf1_md:sha256)
+
+    printf 'export %s=%q\n' "$name" "$value"
+done
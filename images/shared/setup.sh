@@ -17,7 +17,8 @@ usage() {
     echo "$0 VAULT_TOKEN PROJECT_NAME TARGET_NAME"
     echo
     echo "CODE_URI env variable must be set with S3 uri for zip archive "
-    echo "VAULT_ADDR env variable must have valid vault endpoint"
+    echo "VAULT_ADDR env variable must have valid vault endpoint, unless"
+    echo "CELLO_CREDENTIALS_PROVIDER is aws_secrets_manager"
     echo
 }
 
@@ -39,7 +40,7 @@ if [ -z $TARGET_NAME ]; then
     exit 1
 fi
 
-if [ -z $VAULT_ADDR ]; then
+if [ "${CELLO_CREDENTIALS_PROVIDER:-vault}" = "vault" ] && [ -z $VAULT_ADDR ]; then
     echo "Error: VAULT_ADDR not set"
     usage
     exit 1
@@ -57,30 +58,127 @@ echo "Starting setup."
 echo "CODE_URI: $CODE_URI"
 echo "PROJECT_NAME: $PROJECT_NAME"
 echo "TARGET_NAME: $TARGET_NAME"
-echo "VAULT_ADDR: $VAULT_ADDR"
+echo "VAULT_ADDR: ${VAULT_ADDR:-}"
+
+# Wrapped tokens can only be unwrapped once, within the wrap TTL of the
+# service, the token unwrapped is the run token of the workflow.
+if [ "${CELLO_CREDENTIALS_TOKEN_WRAPPED:-false}" = "true" ]; then
+    echo "Unwrapping token via '$VAULT_ADDR'."
+    export VAULT_TOKEN=$(vault unwrap -field=token)
+fi
 
 #
-# Get credentials from vault
+# Get credentials of the target from vault or AWS Secrets Manager
 #
-vault_project_prefix='aws/sts/argo-cloudops'
-target="${vault_project_prefix}-projects-${PROJECT_NAME}-target-${TARGET_NAME}"
-
 token_head=`echo $VAULT_TOKEN |cut -b1-8`
-echo "Exchanging token '${token_head}...' via '$VAULT_ADDR' for target '$target'"
 
-creds=$(vault read --format json $target | \
-    jq -r '"aws_access_key_id=\(.data.access_key)\naws_secret_access_key=\(.data.secret_key)\naws_session_token=\(.data.security_token)"')
+if [ "${CELLO_CREDENTIALS_PROVIDER:-vault}" = "aws_secrets_manager" ]; then
+    # The token is a session of the run role tagged with the project, which
+    # can only read the secrets and assume the roles of targets of the project.
+    target_secret="argo-cloudops-projects/${PROJECT_NAME}/targets/${TARGET_NAME}"
+    echo "Exchanging token '${token_head}...' via AWS Secrets Manager for target '$target_secret'"
+
+    session=$(echo $VAULT_TOKEN | base64 -d)
+    export AWS_ACCESS_KEY_ID=$(echo "$session" | jq -r .access_key_id)
+    export AWS_SECRET_ACCESS_KEY=$(echo "$session" | jq -r .secret_access_key)
+    export AWS_SESSION_TOKEN=$(echo "$session" | jq -r .session_token)
+
+    target=$(aws secretsmanager get-secret-value --secret-id $target_secret --output text --query SecretString)
+    assume_args=(--role-arn "$(echo "$target" | jq -r .properties.role_arn)"
+        --role-session-name "cello-${PROJECT_NAME}-${TARGET_NAME}")
+    policy_arns=$(echo "$target" | jq -c '[.properties.policy_arns[]? | {arn: .}]')
+    if [ "$policy_arns" != "[]" ]; then
+        assume_args+=(--policy-arns "$policy_arns")
+    fi
+    policy_document=$(echo "$target" | jq -r '.properties.policy_document // empty')
+    if [ -n "$policy_document" ]; then
+        assume_args+=(--policy "$policy_document")
+    fi
+
+    creds=$(aws sts assume-role "${assume_args[@]}" --output json | \
+        jq -r '"aws_access_key_id=\(.Credentials.AccessKeyId)\naws_secret_access_key=\(.Credentials.SecretAccessKey)\naws_session_token=\(.Credentials.SessionToken)"')
+
+    echo "Exchanging token successful."
+
+    if [ "${CELLO_HOST_CREDENTIALS:-}" = "true" ]; then
+        host_secret="${target_secret}/host"
+        echo "Reading host credentials from '$host_secret'."
+        host_creds=$(aws secretsmanager get-secret-value --secret-id $host_secret --output text --query SecretString)
+
+        (umask 077
+        echo "$host_creds" | jq -r '.private_key // empty' > /tmp/cello-host-key
+        echo "$host_creds" | jq -r '.password // empty' > /tmp/cello-host-password)
+        echo "Host credentials written."
+    fi
+    unset AWS_ACCESS_KEY_ID AWS_SECRET_ACCESS_KEY AWS_SESSION_TOKEN
+
+    echo "Writing credentials to '$credentials_file'."
+    cat > $credentials_file <<EOF
+[default]
+$creds
+EOF
+
+    arn=`aws sts get-caller-identity --output text --query Arn`
+    echo "Arn of role assumed '$arn'."
+elif [ "${CELLO_TARGET_TYPE:-aws_account}" = "gcp_project" ]; then
+    target="gcp/impersonated-account/argo-cloudops-projects-${PROJECT_NAME}-target-${TARGET_NAME}/token"
+    echo "Exchanging token '${token_head}...' via '$VAULT_ADDR' for target '$target'"
+
+    (umask 077
+    vault read -field=token $target > $CLOUDSDK_AUTH_ACCESS_TOKEN_FILE)
+
+    echo "Exchanging token successful, access token written to '$CLOUDSDK_AUTH_ACCESS_TOKEN_FILE'."
+elif [ "${CELLO_TARGET_TYPE:-aws_account}" = "azure_subscription" ]; then
+    target="azure/creds/argo-cloudops-projects-${PROJECT_NAME}-target-${TARGET_NAME}"
+    subscription_secret="secret/data/argo-cloudops-projects-${PROJECT_NAME}-target-${TARGET_NAME}-azure"
+    echo "Exchanging token '${token_head}...' via '$VAULT_ADDR' for target '$target'"
+
+    creds=$(vault read --format json $target)
+    subscription=$(vault read --format json $subscription_secret)
+
+    (umask 077
+    cat > $CELLO_AZURE_ENV_FILE <<EOF
+export ARM_CLIENT_ID=$(echo "$creds" | jq -r .data.client_id)
+export ARM_CLIENT_SECRET=$(echo "$creds" | jq -r .data.client_secret)
+export ARM_TENANT_ID=$(echo "$subscription" | jq -r .data.data.tenant_id)
+export ARM_SUBSCRIPTION_ID=$(echo "$subscription" | jq -r .data.data.subscription_id)
+EOF
+    )
+
+    echo "Exchanging token successful, service principal written to '$CELLO_AZURE_ENV_FILE'."
+else
+    vault_project_prefix='aws/sts/argo-cloudops'
+    target="${vault_project_prefix}-projects-${PROJECT_NAME}-target-${TARGET_NAME}"
+    echo "Exchanging token '${token_head}...' via '$VAULT_ADDR' for target '$target'"
+
+    creds=$(vault read --format json $target | \
+        jq -r '"aws_access_key_id=\(.data.access_key)\naws_secret_access_key=\(.data.secret_key)\naws_session_token=\(.data.security_token)"')
 
-echo "Exchanging token successful."
+    echo "Exchanging token successful."
 
-echo "Writing credentials to '$credentials_file'."
-cat > $credentials_file <<EOF
+    echo "Writing credentials to '$credentials_file'."
+    cat > $credentials_file <<EOF
 [default]
 $creds
 EOF
 
-arn=`aws sts get-caller-identity --output text --query Arn`
-echo "Arn of role assumed '$arn'."
+    arn=`aws sts get-caller-identity --output text --query Arn`
+    echo "Arn of role assumed '$arn'."
+fi
+
+#
+# Get host credentials of the target from vault for the ansible inventory
+#
+if [ "${CELLO_HOST_CREDENTIALS:-}" = "true" ] && [ "${CELLO_CREDENTIALS_PROVIDER:-vault}" = "vault" ]; then
+    host_secret="secret/data/argo-cloudops-projects-${PROJECT_NAME}-target-${TARGET_NAME}-host"
+    echo "Reading host credentials from '$host_secret'."
+    host_creds=$(vault read --format json $host_secret)
+
+    (umask 077
+    echo "$host_creds" | jq -r '.data.data.private_key // empty' > /tmp/cello-host-key
+    echo "$host_creds" | jq -r '.data.data.password // empty' > /tmp/cello-host-password)
+    echo "Host credentials written."
+fi
 
 if [[ "$CODE_URI" =~ ^s3://.* ]]; then
     echo "Downloading $CODE_URI from S3."
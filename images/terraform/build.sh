@@ -28,6 +28,7 @@ mkdir -p $build_dir
 cp Dockerfile $build_dir
 cp requirements.txt $build_dir
 cp ../shared/setup.sh $build_dir
+cp ../shared/decrypt.sh $build_dir
 
 cd $build_dir
 
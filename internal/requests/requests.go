@@ -1,12 +1,22 @@
 package requests
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cello-proj/cello/internal/types"
 	"github.com/cello-proj/cello/internal/validations"
+
+	"gopkg.in/yaml.v2"
 )
 
 // CreateWorkflow request.
@@ -16,22 +26,201 @@ type CreateWorkflow struct {
 	EnvironmentVariables map[string]string   `json:"environment_variables" yaml:"environment_variables"`
 	// We don't validate the specific framework as it's dynamic and can only be
 	// done server side.
-	Framework   string            `json:"framework" yaml:"framework" valid:"required~framework is required"`
-	Parameters  map[string]string `json:"parameters" yaml:"parameters"`
-	ProjectName string            `json:"project_name" yaml:"project_name" valid:"required~project_name is required,alphanum~project_name must be alphanumeric,stringlength(4|32)~project_name must be between 4 and 32 characters"`
-	TargetName  string            `json:"target_name" yaml:"target_name" valid:"required~target_name is required,alphanumunderscore~target_name must be alphanumeric underscore,stringlength(4|32)~target_name must be between 4 and 32 characters"`
+	Framework   string           `json:"framework" yaml:"framework" valid:"required~framework is required"`
+	Parameters  types.Parameters `json:"parameters" yaml:"parameters"`
+	ProjectName string           `json:"project_name" yaml:"project_name" valid:"required~project_name is required,alphanum~project_name must be alphanumeric,stringlength(4|32)~project_name must be between 4 and 32 characters"`
+	TargetName  string           `json:"target_name" yaml:"target_name" valid:"required~target_name is required,alphanumunderscore~target_name must be alphanumeric underscore,stringlength(4|32)~target_name must be between 4 and 32 characters"`
 	// We don't validate the specific type as it's dynamic and can only be done
 	// server side.
 	Type                 string `json:"type" yaml:"type" valid:"required~type is required"`
 	WorkflowTemplateName string `json:"workflow_template_name" yaml:"workflow_template_name" valid:"required~workflow_template_name is required"`
+	// WorkflowTemplateKind is the kind of the workflow template, a
+	// WorkflowTemplate when empty. ClusterWorkflowTemplates must be allowed
+	// for the project.
+	WorkflowTemplateKind string `json:"workflow_template_kind,omitempty" yaml:"workflow_template_kind,omitempty"`
+	// ChangeTicket is an existing change ticket for change controlled targets.
+	ChangeTicket string `json:"change_ticket,omitempty" yaml:"change_ticket,omitempty" valid:"matches(^[A-Za-z0-9-]+$)~change_ticket must be alphanumeric dash,stringlength(1|63)~change_ticket must be between 1 and 63 characters"`
+	// Helm is the release of the helm framework, required by it.
+	Helm *HelmRelease `json:"helm,omitempty" yaml:"helm,omitempty"`
+	// CloudFormation is the stack of the cloudformation framework, required
+	// by it.
+	CloudFormation *CloudFormationStack `json:"cloudformation,omitempty" yaml:"cloudformation,omitempty"`
+	// Ansible is the playbook of the ansible framework, required by it.
+	Ansible *AnsiblePlaybook `json:"ansible,omitempty" yaml:"ansible,omitempty"`
+	// Kubectl are the manifests of the kubectl framework, required by it.
+	Kubectl *KubernetesManifests `json:"kubectl,omitempty" yaml:"kubectl,omitempty"`
+	// FrameworkParameters are the parameters of frameworks registered by the
+	// frameworks of the service config, validated by the service.
+	FrameworkParameters map[string]string `json:"framework_parameters,omitempty" yaml:"framework_parameters,omitempty"`
+	// Name identifies the workflow among the documents of a manifest, see
+	// SelectManifestWorkflow.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Environment selects the target among the targets of the environment of
+	// the project, the target must be one of them when it's set.
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	// Justification is why the resources of the target are destroyed,
+	// required by destroys. It's recorded with the workflow.
+	Justification string `json:"justification,omitempty" yaml:"justification,omitempty"`
+	// Confirmation must be the DestroyConfirmation of the target for
+	// destroys, so targets aren't destroyed by mistake.
+	Confirmation string `json:"confirmation,omitempty" yaml:"confirmation,omitempty"`
+}
+
+// TypeDestroy is the type of workflows destroying the resources of the
+// target. Destroys must be justified and confirmed.
+const TypeDestroy = "destroy"
+
+// DestroyConfirmation returns the confirmation destroys of the target must
+// type.
+func DestroyConfirmation(projectName, targetName string) string {
+	return fmt.Sprintf("destroy %s/%s", projectName, targetName)
+}
+
+// ErrManifestSelection conveys that the workflow of a manifest can't be
+// selected.
+var ErrManifestSelection = errors.New("manifest workflow can't be selected")
+
+// SelectManifestWorkflow returns the workflow of a manifest of one or more
+// YAML documents, the one with the name when a name is given. Exactly one
+// document must match, and manifests with several documents require a name.
+// Empty documents are ignored.
+func SelectManifestWorkflow(data []byte, name string) (CreateWorkflow, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []CreateWorkflow
+	for {
+		var cwr CreateWorkflow
+		err := dec.Decode(&cwr)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return CreateWorkflow{}, err
+		}
+		if reflect.DeepEqual(cwr, CreateWorkflow{}) {
+			continue
+		}
+		docs = append(docs, cwr)
+	}
+
+	if name == "" {
+		switch len(docs) {
+		case 0:
+			return CreateWorkflow{}, fmt.Errorf("%w: manifest has no workflow", ErrManifestSelection)
+		case 1:
+			return docs[0], nil
+		default:
+			return CreateWorkflow{}, fmt.Errorf("%w: manifest has %d workflows, name must select one", ErrManifestSelection, len(docs))
+		}
+	}
+
+	var matches []CreateWorkflow
+	for _, d := range docs {
+		if d.Name == name {
+			matches = append(matches, d)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return CreateWorkflow{}, fmt.Errorf("%w: manifest has no workflow named '%s'", ErrManifestSelection, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return CreateWorkflow{}, fmt.Errorf("%w: manifest has %d workflows named '%s'", ErrManifestSelection, len(matches), name)
+	}
+}
+
+// HelmFramework is the framework deploying Helm releases, configured by the
+// Helm field of CreateWorkflow.
+const HelmFramework = "helm"
+
+// HelmRelease are the typed parameters of a Helm release. They're part of the
+// generated command, so values are restricted to characters safe in a shell.
+type HelmRelease struct {
+	// Chart is a chart reference, e.g. repo/chart or oci://registry/chart, or
+	// a path to a chart at the commit starting with ./.
+	Chart string `json:"chart" yaml:"chart" valid:"required~helm.chart is required,matches(^(\\./)?[A-Za-z0-9][A-Za-z0-9._/:@-]*$)~helm.chart must be a chart reference"`
+	// Version of the chart, required unless the chart is a path.
+	Version string `json:"version,omitempty" yaml:"version,omitempty" valid:"matches(^[A-Za-z0-9][A-Za-z0-9.+-]*$)~helm.version must be a chart version"`
+	// ValuesFile is the path of a values file at the commit.
+	ValuesFile  string `json:"values_file,omitempty" yaml:"values_file,omitempty" valid:"matches(^[A-Za-z0-9._/-]+$)~helm.values_file must be a relative path"`
+	ReleaseName string `json:"release_name" yaml:"release_name" valid:"required~helm.release_name is required,matches(^[a-z0-9]([-a-z0-9]*[a-z0-9])?$)~helm.release_name must be lowercase alphanumeric dash,stringlength(1|53)~helm.release_name must be between 1 and 53 characters"`
+	// Namespace of the release, the namespace of the kube context when empty.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty" valid:"matches(^[a-z0-9]([-a-z0-9]*[a-z0-9])?$)~helm.namespace must be lowercase alphanumeric dash,stringlength(1|63)~helm.namespace must be between 1 and 63 characters"`
+}
+
+// IsLocalChart reports whether the chart is a path at the commit.
+func (h HelmRelease) IsLocalChart() bool {
+	return strings.HasPrefix(h.Chart, "./")
+}
+
+// CloudFormationFramework is the framework deploying CloudFormation (and SAM)
+// stacks with change sets, configured by the CloudFormation field of
+// CreateWorkflow. Diffs create the change set, syncs execute it.
+const CloudFormationFramework = "cloudformation"
+
+// CloudFormationStack are the typed parameters of a CloudFormation stack. Like
+// HelmRelease, they're part of the generated command.
+type CloudFormationStack struct {
+	StackName string `json:"stack_name" yaml:"stack_name" valid:"required~cloudformation.stack_name is required,matches(^[A-Za-z][A-Za-z0-9-]*$)~cloudformation.stack_name must be alphanumeric dash,stringlength(1|128)~cloudformation.stack_name must be between 1 and 128 characters"`
+	// TemplateFile is the path of the template at the commit.
+	TemplateFile string `json:"template_file" yaml:"template_file" valid:"required~cloudformation.template_file is required,matches(^[A-Za-z0-9._/-]+$)~cloudformation.template_file must be a relative path"`
+	// ChangeSetName is the change set created by diffs and executed by syncs,
+	// e.g. named after the commit.
+	ChangeSetName string `json:"change_set_name" yaml:"change_set_name" valid:"required~cloudformation.change_set_name is required,matches(^[A-Za-z][A-Za-z0-9-]*$)~cloudformation.change_set_name must be alphanumeric dash,stringlength(1|128)~cloudformation.change_set_name must be between 1 and 128 characters"`
+}
+
+// AnsibleFramework is the framework running Ansible playbooks against the
+// inventory of the target, configured by the Ansible field of CreateWorkflow.
+// Diffs run in check mode.
+const AnsibleFramework = "ansible"
+
+// AnsiblePlaybook are the typed parameters of an Ansible playbook.
+type AnsiblePlaybook struct {
+	// Playbook is the path of the playbook at the commit.
+	Playbook string `json:"playbook" yaml:"playbook" valid:"required~ansible.playbook is required,matches(^[A-Za-z0-9._/-]+$)~ansible.playbook must be a relative path"`
+}
+
+// KubectlFramework is the framework applying Kubernetes manifests or a
+// kustomization, configured by the Kubectl field of CreateWorkflow. Diffs run
+// kubectl diff, syncs kubectl apply pruning the allowed kinds.
+const KubectlFramework = "kubectl"
+
+var labelSelectorRegex = regexp.MustCompile(`^[A-Za-z0-9./_=,-]+$`)
+
+// KubernetesManifests are the typed parameters of the manifests applied by
+// the kubectl framework.
+type KubernetesManifests struct {
+	// Path is the directory of the manifests, or of the kustomization, at
+	// the commit.
+	Path      string `json:"path" yaml:"path" valid:"required~kubectl.path is required,matches(^[A-Za-z0-9._/-]+$)~kubectl.path must be a relative path"`
+	Kustomize bool   `json:"kustomize,omitempty" yaml:"kustomize,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty" valid:"matches(^[a-z0-9]([-a-z0-9]*[a-z0-9])?$)~kubectl.namespace must be a valid kubernetes namespace,stringlength(1|63)~kubectl.namespace must be between 1 and 63 characters"`
+	// Selector is the label selector of the objects of the manifests, only
+	// objects matching it are pruned.
+	Selector string `json:"selector" yaml:"selector" valid:"required~kubectl.selector is required"`
 }
 
+// WorkflowTemplateKinds are the kinds of Argo workflow templates workflows can
+// be created from.
+var WorkflowTemplateKinds = []string{"WorkflowTemplate", "ClusterWorkflowTemplate"}
+
 // Validate validates CreateWorkflow.
 func (req CreateWorkflow) Validate(optionalValidations ...func() error) error {
 	v := []func() error{
 		func() error { return validations.ValidateStruct(req) },
 		req.validateArguments,
 		req.validateParameters,
+		req.validateHelm,
+		req.validateCloudFormation,
+		req.validateAnsible,
+		req.validateKubectl,
+		req.validateDestroy,
+		func() error {
+			if req.WorkflowTemplateKind != "" {
+				return validateWorkflowTemplateKind("workflow_template_kind", req.WorkflowTemplateKind)
+			}
+			return nil
+		},
 	}
 	v = append(v, optionalValidations...)
 
@@ -51,6 +240,24 @@ func (req CreateWorkflow) ValidateType(types []string) func() error {
 	}
 }
 
+// validateDestroy validates destroys are justified and confirmed.
+func (req CreateWorkflow) validateDestroy() error {
+	if req.Type != TypeDestroy {
+		if req.Confirmation != "" {
+			return fmt.Errorf("confirmation is only valid for type '%s'", TypeDestroy)
+		}
+		return nil
+	}
+
+	if l := len(strings.TrimSpace(req.Justification)); l < 20 || l > 1000 {
+		return errors.New("justification must be between 20 and 1000 characters for destroys")
+	}
+	if want := DestroyConfirmation(req.ProjectName, req.TargetName); req.Confirmation != want {
+		return fmt.Errorf("confirmation must be '%s' for destroys", want)
+	}
+	return nil
+}
+
 // validateParameters validates the Parameters.
 // 'execute_container_image_uri' is required and the URI format will be
 // validated.
@@ -83,6 +290,118 @@ func (req CreateWorkflow) validateParameters() error {
 	return nil
 }
 
+// validateHelm validates the Helm release is set for, and only for, the helm
+// framework. Paths can't leave the code of the commit.
+func (req CreateWorkflow) validateHelm() error {
+	if req.Helm == nil {
+		if req.Framework == HelmFramework {
+			return errors.New("helm is required for the helm framework")
+		}
+		return nil
+	}
+	if req.Framework != HelmFramework {
+		return fmt.Errorf("helm is only supported by the %s framework", HelmFramework)
+	}
+
+	if req.Helm.Version == "" && !req.Helm.IsLocalChart() {
+		return errors.New("helm.version is required for charts of repositories")
+	}
+	if req.Helm.IsLocalChart() && !isRelativePath(req.Helm.Chart) {
+		return errors.New("helm.chart must be a relative path")
+	}
+	if req.Helm.ValuesFile != "" && !isRelativePath(req.Helm.ValuesFile) {
+		return errors.New("helm.values_file must be a relative path")
+	}
+	return nil
+}
+
+// validateCloudFormation validates the CloudFormation stack is set for, and
+// only for, the cloudformation framework.
+func (req CreateWorkflow) validateCloudFormation() error {
+	if req.CloudFormation == nil {
+		if req.Framework == CloudFormationFramework {
+			return errors.New("cloudformation is required for the cloudformation framework")
+		}
+		return nil
+	}
+	if req.Framework != CloudFormationFramework {
+		return fmt.Errorf("cloudformation is only supported by the %s framework", CloudFormationFramework)
+	}
+
+	if !isRelativePath(req.CloudFormation.TemplateFile) {
+		return errors.New("cloudformation.template_file must be a relative path")
+	}
+	return nil
+}
+
+// validateAnsible validates the Ansible playbook is set for, and only for,
+// the ansible framework.
+func (req CreateWorkflow) validateAnsible() error {
+	if req.Ansible == nil {
+		if req.Framework == AnsibleFramework {
+			return errors.New("ansible is required for the ansible framework")
+		}
+		return nil
+	}
+	if req.Framework != AnsibleFramework {
+		return fmt.Errorf("ansible is only supported by the %s framework", AnsibleFramework)
+	}
+
+	if !isRelativePath(req.Ansible.Playbook) {
+		return errors.New("ansible.playbook must be a relative path")
+	}
+	return nil
+}
+
+// validateKubectl validates the Kubernetes manifests are set for, and only
+// for, the kubectl framework.
+func (req CreateWorkflow) validateKubectl() error {
+	if req.Kubectl == nil {
+		if req.Framework == KubectlFramework {
+			return errors.New("kubectl is required for the kubectl framework")
+		}
+		return nil
+	}
+	if req.Framework != KubectlFramework {
+		return fmt.Errorf("kubectl is only supported by the %s framework", KubectlFramework)
+	}
+
+	if !isRelativePath(req.Kubectl.Path) {
+		return errors.New("kubectl.path must be a relative path")
+	}
+	// Commas separate the requirements of selectors, so they can't be
+	// matched by the struct tag.
+	if !labelSelectorRegex.MatchString(req.Kubectl.Selector) {
+		return errors.New("kubectl.selector must be a label selector")
+	}
+	return nil
+}
+
+// isRelativePath reports whether the path stays below the current directory.
+func isRelativePath(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return false
+	}
+	for _, e := range strings.Split(p, "/") {
+		if e == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWorkflowTemplateKind validates the kind (of the field) is one of
+// WorkflowTemplateKinds.
+func validateWorkflowTemplateKind(field, kind string) error {
+	for _, k := range WorkflowTemplateKinds {
+		if kind == k {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s must be one of '%s'", field, strings.Join(WorkflowTemplateKinds, " "))
+}
+
 // validateArguments validates the Arguments.
 // If any Arguments are provided, they must be one of 'execute' or 'init'.
 // TODO long term, we should evaluate if hard coding in code is the right
@@ -102,6 +421,12 @@ func (req CreateWorkflow) validateArguments() error {
 type CreateGitWorkflow struct {
 	CommitHash string `json:"sha" valid:"required~sha is required,alphanum~sha must be alphanumeric"`
 	Path       string `json:"path" valid:"required~path is required"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
+	// Template renders the manifest before it's loaded, see ManifestTemplate.
+	Template *ManifestTemplate `json:"template,omitempty"`
+	// ChangeTicket overrides the change ticket of the manifest.
+	ChangeTicket string `json:"change_ticket,omitempty" valid:"matches(^[A-Za-z0-9-]+$)~change_ticket must be alphanumeric dash,stringlength(1|63)~change_ticket must be between 1 and 63 characters"`
 }
 
 // Validate validates CreateGitWorkflow.
@@ -109,6 +434,54 @@ func (req CreateGitWorkflow) Validate() error {
 	return validations.ValidateStruct(req)
 }
 
+// ManifestTemplate renders the manifest of a request as a Go template with
+// the sprig functions. The parameters, the project and the target (e.g.
+// {{ .Target.Properties.RoleArn }}) are the context of the template.
+type ManifestTemplate struct {
+	Parameters types.Parameters `json:"parameters,omitempty"`
+	// Strict makes referring to an undefined parameter an error rather than
+	// rendering it as empty.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// CreateOCIWorkflow from an OCI artifact manifest request.
+type CreateOCIWorkflow struct {
+	// Reference is the artifact pinned by its digest, e.g.
+	// registry.example.com/team/bundle@sha256:<hex>.
+	Reference string `json:"reference" valid:"required~reference is required,matches(^[^@]+@sha256:[a-f0-9]{64}$)~reference must be pinned by a sha256 digest"`
+	// Path is the file name of the manifest in the artifact.
+	Path string `json:"path" valid:"required~path is required"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
+	// Template renders the manifest before it's loaded, see ManifestTemplate.
+	Template *ManifestTemplate `json:"template,omitempty"`
+	// ChangeTicket overrides the change ticket of the manifest.
+	ChangeTicket string `json:"change_ticket,omitempty" valid:"matches(^[A-Za-z0-9-]+$)~change_ticket must be alphanumeric dash,stringlength(1|63)~change_ticket must be between 1 and 63 characters"`
+}
+
+// Validate validates CreateOCIWorkflow.
+func (req CreateOCIWorkflow) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
+// CreateUploadWorkflow are the fields of the multipart form of a manifest
+// bundle upload, whose 'bundle' file is the gzipped tarball of the manifest.
+type CreateUploadWorkflow struct {
+	// SHA256 is the hex encoded checksum of the bundle.
+	SHA256 string `json:"sha256" valid:"required~sha256 is required,matches(^[a-fA-F0-9]{64}$)~sha256 must be a hex encoded sha256 checksum"`
+	// Path is the path of the manifest in the bundle.
+	Path string `json:"path" valid:"required~path is required"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
+	// ChangeTicket overrides the change ticket of the manifest.
+	ChangeTicket string `json:"change_ticket,omitempty" valid:"matches(^[A-Za-z0-9-]+$)~change_ticket must be alphanumeric dash,stringlength(1|63)~change_ticket must be between 1 and 63 characters"`
+}
+
+// Validate validates CreateUploadWorkflow.
+func (req CreateUploadWorkflow) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
 // CreateTarget request.
 type CreateTarget types.Target
 
@@ -133,11 +506,33 @@ func (req CreateProject) Validate() error {
 	return validations.Validate(v...)
 }
 
+// RenameProject request, renaming a project to Name.
+type RenameProject struct {
+	Name string `json:"name" valid:"required~name is required,alphanum~name must be alphanumeric,stringlength(4|32)~name must be between 4 and 32 characters"`
+}
+
+// Validate validates RenameProject.
+func (req RenameProject) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
+// MoveTarget request, moving a target to the project Project.
+type MoveTarget struct {
+	Project string `json:"project" valid:"required~project is required,alphanum~project must be alphanumeric,stringlength(4|32)~project must be between 4 and 32 characters"`
+}
+
+// Validate validates MoveTarget.
+func (req MoveTarget) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
 // TargetOperation represents a target operation request.
 // TODO evaluate this vs. CreateGitWorkflow.
 type TargetOperation struct {
 	Path string `json:"path" valid:"required~path is required"`
 	SHA  string `json:"sha" valid:"required~sha is required,alphanum~sha must be alphanumeric"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
 	// We don't validate the specific type as it's dynamic and can only be done
 	// server side.
 	Type string `json:"type" valid:"required~type is required"`
@@ -152,3 +547,1128 @@ func (req TargetOperation) Validate() error {
 type UpdateTarget struct {
 	Properties types.TargetProperties `json:"properties"`
 }
+
+// PutTargetGuardrail request.
+type PutTargetGuardrail struct {
+	// We don't validate the specific provider as it depends on the service
+	// configuration and can only be done server side.
+	Provider  string  `json:"provider" valid:"required~provider is required"`
+	Query     string  `json:"query" valid:"required~query is required"`
+	Threshold float64 `json:"threshold"`
+	Operator  string  `json:"operator" valid:"required~operator is required"`
+}
+
+// Validate validates PutTargetGuardrail.
+func (req PutTargetGuardrail) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if req.Operator != "above" && req.Operator != "below" {
+				return errors.New("operator must be one of 'above below'")
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateProvider is an optional validation should be passed as parameter to Validate().
+func (req PutTargetGuardrail) ValidateProvider(providers []string) func() error {
+	return func() error {
+		for _, p := range providers {
+			if req.Provider == p {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("provider must be one of '%s'", strings.Join(providers, " "))
+	}
+}
+
+// PutTargetChangeControl request.
+type PutTargetChangeControl struct {
+	RequireApproval bool `json:"require_approval"`
+}
+
+// PutTargetScheduling request.
+type PutTargetScheduling struct {
+	NodeSelector      map[string]string  `json:"node_selector"`
+	Tolerations       []types.Toleration `json:"tolerations"`
+	PriorityClassName string             `json:"priority_class_name" valid:"matches(^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$)~priority_class_name must be a valid kubernetes resource name,stringlength(1|253)~priority_class_name must be between 1 and 253 characters"`
+	RuntimeClassName  string             `json:"runtime_class_name" valid:"matches(^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$)~runtime_class_name must be a valid kubernetes resource name,stringlength(1|253)~runtime_class_name must be between 1 and 253 characters"`
+	// Platform is the os/arch of the nodes, selecting the image variant of
+	// frameworks.
+	Platform string `json:"platform,omitempty"`
+}
+
+// Validate validates PutTargetScheduling.
+func (req PutTargetScheduling) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if len(req.NodeSelector) == 0 && len(req.Tolerations) == 0 && req.PriorityClassName == "" && req.RuntimeClassName == "" && req.Platform == "" {
+				return errors.New("one of node_selector, tolerations, priority_class_name, runtime_class_name or platform is required")
+			}
+			return nil
+		},
+		func() error {
+			for k := range req.NodeSelector {
+				if k == "" {
+					return errors.New("node_selector keys must not be empty")
+				}
+				if req.Platform != "" && (k == types.LabelOS || k == types.LabelArch) {
+					return fmt.Errorf("node_selector must not set %s with platform", k)
+				}
+			}
+			return nil
+		},
+		func() error {
+			if req.Platform != "" && !types.IsValidPlatform(req.Platform) {
+				return fmt.Errorf("platform must be one of '%s'", strings.Join(types.Platforms, " "))
+			}
+			return nil
+		},
+		func() error {
+			for i, t := range req.Tolerations {
+				if err := t.Validate(); err != nil {
+					return fmt.Errorf("tolerations[%d] %w", i, err)
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// Connections of target inventories.
+const (
+	InventoryConnectionSSH   = "ssh"
+	InventoryConnectionWinRM = "winrm"
+)
+
+var (
+	inventoryHostRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.:-]*[A-Za-z0-9])?$`)
+	inventoryNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// reservedInventoryVars are set by the service from the connection.
+var reservedInventoryVars = []string{"ansible_connection", "ansible_user", "ansible_port", "ansible_password", "ansible_ssh_private_key_file"}
+
+// PutTargetInventory request. The private key (ssh) or password (winrm) is
+// stored in Vault, the rest renders the inventory of ansible workflows.
+type PutTargetInventory struct {
+	Connection string              `json:"connection" valid:"required~connection is required"`
+	User       string              `json:"user" valid:"required~user is required,matches(^[A-Za-z0-9._@-]+$)~user must be alphanumeric dot underscore at dash"`
+	Port       int                 `json:"port,omitempty" valid:"range(1|65535)~port must be between 1 and 65535"`
+	Hosts      []string            `json:"hosts"`
+	Groups     map[string][]string `json:"groups,omitempty"`
+	Vars       map[string]string   `json:"vars,omitempty"`
+	PrivateKey string              `json:"private_key,omitempty"`
+	Password   string              `json:"password,omitempty"`
+}
+
+// Validate validates PutTargetInventory.
+func (req PutTargetInventory) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			switch req.Connection {
+			case InventoryConnectionSSH:
+				if req.PrivateKey == "" || req.Password != "" {
+					return errors.New("ssh connections require private_key and no password")
+				}
+			case InventoryConnectionWinRM:
+				if req.Password == "" || req.PrivateKey != "" {
+					return errors.New("winrm connections require password and no private_key")
+				}
+			default:
+				return fmt.Errorf("connection must be one of '%s %s'", InventoryConnectionSSH, InventoryConnectionWinRM)
+			}
+			return nil
+		},
+		func() error {
+			if len(req.Hosts) == 0 {
+				return errors.New("hosts is required")
+			}
+			for _, h := range req.Hosts {
+				if !inventoryHostRegex.MatchString(h) {
+					return fmt.Errorf("host '%s' must be a hostname or ip address", h)
+				}
+			}
+			return nil
+		},
+		func() error {
+			hosts := map[string]bool{}
+			for _, h := range req.Hosts {
+				hosts[h] = true
+			}
+			for g, members := range req.Groups {
+				if !inventoryNameRegex.MatchString(g) || g == "all" || g == "ungrouped" {
+					return fmt.Errorf("group '%s' must be alphanumeric underscore and not all or ungrouped", g)
+				}
+				for _, h := range members {
+					if !hosts[h] {
+						return fmt.Errorf("host '%s' of group '%s' must be one of hosts", h, g)
+					}
+				}
+			}
+			return nil
+		},
+		func() error {
+			for k := range req.Vars {
+				if !inventoryNameRegex.MatchString(k) {
+					return fmt.Errorf("var '%s' must be alphanumeric underscore", k)
+				}
+				for _, r := range reservedInventoryVars {
+					if k == r {
+						return fmt.Errorf("var '%s' is set from the connection", k)
+					}
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// PutTargetWorkloadIdentity request.
+type PutTargetWorkloadIdentity struct {
+	ServiceAccount string `json:"service_account" valid:"required~service_account is required,matches(^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$)~service_account must be a valid kubernetes resource name,stringlength(1|253)~service_account must be between 1 and 253 characters"`
+}
+
+// Validate validates PutTargetWorkloadIdentity.
+func (req PutTargetWorkloadIdentity) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+	)
+}
+
+// MaxCostAllocationTags is the most cost allocation tags of a target, the
+// most tags of an AWS resource.
+const MaxCostAllocationTags = 50
+
+// costAllocationTagRegex matches the characters allowed in AWS tags.
+var costAllocationTagRegex = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// PutTargetCostAllocationTags request.
+type PutTargetCostAllocationTags struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// Validate validates PutTargetCostAllocationTags.
+func (req PutTargetCostAllocationTags) Validate() error {
+	return validations.Validate(
+		func() error {
+			if len(req.Tags) == 0 {
+				return errors.New("tags is required")
+			}
+			if len(req.Tags) > MaxCostAllocationTags {
+				return fmt.Errorf("tags cannot be more than %d", MaxCostAllocationTags)
+			}
+			return nil
+		},
+		func() error {
+			keys := make([]string, 0, len(req.Tags))
+			for k := range req.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				v := req.Tags[k]
+				if n := utf8.RuneCountInString(k); n < 1 || n > 128 {
+					return fmt.Errorf("tag key '%s' must be between 1 and 128 characters", k)
+				}
+				if utf8.RuneCountInString(v) > 256 {
+					return fmt.Errorf("tag '%s' value must be at most 256 characters", k)
+				}
+				if strings.HasPrefix(strings.ToLower(k), "aws:") {
+					return fmt.Errorf("tag key '%s' must not start with 'aws:'", k)
+				}
+				if !costAllocationTagRegex.MatchString(k) || !costAllocationTagRegex.MatchString(v) {
+					return fmt.Errorf("tag '%s' must only have letters, numbers, spaces or one of '_.:/=+-@'", k)
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// PutTargetSoakTime request.
+type PutTargetSoakTime struct {
+	// MinSoak is how long after a diff of a commit succeeded a sync of it can
+	// be submitted, e.g. 1h.
+	MinSoak string `json:"min_soak,omitempty"`
+	// MaxStaleness is how long after a diff of a commit succeeded a sync of it
+	// can still be submitted, e.g. 24h.
+	MaxStaleness string `json:"max_staleness,omitempty"`
+}
+
+// Validate validates PutTargetSoakTime.
+func (req PutTargetSoakTime) Validate() error {
+	return validations.Validate(
+		func() error {
+			if req.MinSoak == "" && req.MaxStaleness == "" {
+				return errors.New("min_soak or max_staleness is required")
+			}
+			return nil
+		},
+		func() error {
+			if req.MinSoak == "" {
+				return nil
+			}
+			if d, err := time.ParseDuration(req.MinSoak); err != nil || d <= 0 {
+				return errors.New("min_soak must be a positive duration, e.g. 1h")
+			}
+			return nil
+		},
+		func() error {
+			if req.MaxStaleness == "" {
+				return nil
+			}
+			if d, err := time.ParseDuration(req.MaxStaleness); err != nil || d <= 0 {
+				return errors.New("max_staleness must be a positive duration, e.g. 24h")
+			}
+			return nil
+		},
+		func() error {
+			minSoak, _ := time.ParseDuration(req.MinSoak)
+			maxStaleness, _ := time.ParseDuration(req.MaxStaleness)
+			if req.MaxStaleness != "" && maxStaleness <= minSoak {
+				return errors.New("max_staleness must be longer than min_soak")
+			}
+			return nil
+		},
+	)
+}
+
+// terraformBackends are the terraform backends whose state location can be
+// recorded.
+var terraformBackends = []string{"azurerm", "consul", "cos", "gcs", "http", "kubernetes", "oss", "pg", "remote", "s3"}
+
+// PutTargetTerraformState request.
+type PutTargetTerraformState struct {
+	// Backend is the terraform backend of the state, e.g. s3.
+	Backend string `json:"backend"`
+	// Location is where the state is in the backend, e.g.
+	// s3://bucket/key/terraform.tfstate.
+	Location string `json:"location"`
+}
+
+// Validate validates PutTargetTerraformState.
+func (req PutTargetTerraformState) Validate() error {
+	return validations.Validate(
+		func() error {
+			for _, b := range terraformBackends {
+				if req.Backend == b {
+					return nil
+				}
+			}
+			return fmt.Errorf("backend must be one of '%s'", strings.Join(terraformBackends, " "))
+		},
+		func() error {
+			if strings.TrimSpace(req.Location) == "" {
+				return errors.New("location is required")
+			}
+			if len(req.Location) > 1024 {
+				return errors.New("location must be at most 1024 characters")
+			}
+			return nil
+		},
+	)
+}
+
+// ForceUnlockTerraformState request.
+type ForceUnlockTerraformState struct {
+	// LockID is the ID of the stale lock, which must be the one recorded for
+	// the target so a lock acquired since isn't released.
+	LockID string `json:"lock_id" valid:"required~lock_id is required"`
+}
+
+// Validate validates ForceUnlockTerraformState.
+func (req ForceUnlockTerraformState) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
+// PutProjectNotificationRule request.
+type PutProjectNotificationRule struct {
+	// We don't validate the specific type as it depends on the service
+	// configuration and can only be done server side.
+	Type                string `json:"type" valid:"required~type is required"`
+	RoutingKey          string `json:"routing_key" valid:"required~routing_key is required"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// Validate validates PutProjectNotificationRule.
+func (req PutProjectNotificationRule) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if req.ConsecutiveFailures < 1 {
+				return errors.New("consecutive_failures must be at least 1")
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateType is an optional validation should be passed as parameter to Validate().
+func (req PutProjectNotificationRule) ValidateType(types []string) func() error {
+	return func() error {
+		for _, t := range types {
+			if req.Type == t {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("type must be one of '%s'", strings.Join(types, " "))
+	}
+}
+
+// PutEnvironment request, creating or replacing an environment of the
+// project.
+type PutEnvironment types.Environment
+
+// Validate validates PutEnvironment.
+func (req PutEnvironment) Validate(optionalValidations ...func() error) error {
+	v := []func() error{types.Environment(req).Validate}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+var environmentNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,30}[a-z0-9])?$`)
+
+// ValidateName is an optional validation should be passed as parameter to
+// Validate(), validating the name of the environment (e.g. 'production').
+func (req PutEnvironment) ValidateName(name string) func() error {
+	return func() error {
+		if !environmentNameRegex.MatchString(name) {
+			return errors.New("name must be lowercase alphanumeric dash between 1 and 32 characters")
+		}
+		return nil
+	}
+}
+
+// PutProjectSettings request, replacing the settings document of the
+// project.
+type PutProjectSettings types.ProjectSettings
+
+// Validate validates PutProjectSettings.
+func (req PutProjectSettings) Validate(optionalValidations ...func() error) error {
+	v := []func() error{types.ProjectSettings(req).Validate}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateNotificationTypes is an optional validation should be passed as
+// parameter to Validate().
+func (req PutProjectSettings) ValidateNotificationTypes(notificationTypes []string) func() error {
+	return func() error {
+	next:
+		for _, n := range req.Notifications {
+			for _, t := range notificationTypes {
+				if n.Type == t {
+					continue next
+				}
+			}
+			return fmt.Errorf("notifications type must be one of '%s'", strings.Join(notificationTypes, " "))
+		}
+		return nil
+	}
+}
+
+// PutProjectFeatureFlag request.
+type PutProjectFeatureFlag struct {
+	// We don't validate the specific name as the known feature flags are
+	// defined by the service.
+	Name    string `json:"name" valid:"required~name is required"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Validate validates PutProjectFeatureFlag.
+func (req PutProjectFeatureFlag) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateName is an optional validation should be passed as parameter to Validate().
+func (req PutProjectFeatureFlag) ValidateName(names []string) func() error {
+	return func() error {
+		for _, n := range names {
+			if req.Name == n {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("name must be one of '%s'", strings.Join(names, " "))
+	}
+}
+
+// PutProjectRegistryCredential request.
+type PutProjectRegistryCredential struct {
+	// Registry is the host, and optionally port, of the registry.
+	Registry string `json:"registry" valid:"required~registry is required,matches(^[a-zA-Z0-9.-]+(:[0-9]+)?$)~registry must be a host and optional port"`
+	Username string `json:"username" valid:"required~username is required"`
+	Password string `json:"password" valid:"required~password is required"`
+}
+
+// Validate validates PutProjectRegistryCredential.
+func (req PutProjectRegistryCredential) Validate() error {
+	return validations.ValidateStruct(req)
+}
+
+// PutProjectBusinessHours request.
+type PutProjectBusinessHours struct {
+	// The timezone, days and times are validated server side where the
+	// timezone database is available.
+	Timezone string   `json:"timezone" valid:"required~timezone is required"`
+	Days     []string `json:"days"`
+	Start    string   `json:"start" valid:"required~start is required"`
+	End      string   `json:"end" valid:"required~end is required"`
+	// Targets limits the business hours to some targets, all targets of the
+	// project when empty.
+	Targets []string `json:"targets"`
+}
+
+// Validate validates PutProjectBusinessHours.
+func (req PutProjectBusinessHours) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if len(req.Days) == 0 {
+				return errors.New("days is required")
+			}
+			return nil
+		},
+	)
+}
+
+// PutProjectWorkflowTemplate request.
+type PutProjectWorkflowTemplate struct {
+	Kind string `json:"kind" valid:"required~kind is required"`
+	Name string `json:"name" valid:"required~name is required,matches(^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$)~name must be a valid kubernetes resource name,stringlength(1|253)~name must be between 1 and 253 characters"`
+}
+
+// Validate validates PutProjectWorkflowTemplate.
+func (req PutProjectWorkflowTemplate) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error { return validateWorkflowTemplateKind("kind", req.Kind) },
+	)
+}
+
+var cronWorkflowNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// PutTargetSchedule request.
+type PutTargetSchedule struct {
+	// Cron is the schedule in cron format (e.g. '0 9 * * 1-5') or a
+	// descriptor (e.g. '@daily').
+	Cron string `json:"cron"`
+	// Timezone of the schedule, UTC when empty. It's validated server side
+	// where the timezone database is available.
+	Timezone string `json:"timezone"`
+	Suspend  bool   `json:"suspend"`
+	// Workflow is submitted on schedule, its project and target are the ones
+	// of the schedule.
+	Workflow CreateWorkflow `json:"workflow"`
+}
+
+// Validate validates PutTargetSchedule. The workflow is validated separately
+// once its project and target are set.
+func (req PutTargetSchedule) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			if req.Cron == "" {
+				return errors.New("cron is required")
+			}
+			return nil
+		},
+		func() error {
+			if !strings.HasPrefix(req.Cron, "@") && len(strings.Fields(req.Cron)) != 5 {
+				return errors.New("cron must have 5 fields or be a descriptor like '@daily'")
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateCronWorkflowName is an optional validation should be passed as
+// parameter to Validate(). Cron workflow names are limited to 52 characters
+// as Kubernetes appends a timestamp to the names of the workflows.
+func (req PutTargetSchedule) ValidateCronWorkflowName(name string) func() error {
+	return func() error {
+		if len(name) > 52 || !cronWorkflowNameRegex.MatchString(name) {
+			return fmt.Errorf("cron workflow name '%s' must be at most 52 lowercase alphanumeric or '-' characters", name)
+		}
+		return nil
+	}
+}
+
+// ShareWorkflow request.
+type ShareWorkflow struct {
+	// TTL is how long the link is valid for, e.g. 30m. The service default
+	// when empty.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate validates ShareWorkflow.
+func (req ShareWorkflow) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			if req.TTL == "" {
+				return nil
+			}
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				return errors.New("ttl must be a positive duration, e.g. 30m")
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateMaxTTL is an optional validation should be passed as parameter to
+// Validate().
+func (req ShareWorkflow) ValidateMaxTTL(max time.Duration) func() error {
+	return func() error {
+		if ttl, err := time.ParseDuration(req.TTL); err == nil && ttl > max {
+			return fmt.Errorf("ttl must be at most %s", max)
+		}
+		return nil
+	}
+}
+
+// MinBreakGlassTTL is the shortest TTL of break-glass credentials, the
+// minimum duration of AWS STS credentials.
+const MinBreakGlassTTL = 15 * time.Minute
+
+// BreakGlass request.
+type BreakGlass struct {
+	// Justification is why the credentials are needed. It's recorded and
+	// sent to the project owners.
+	Justification string `json:"justification" valid:"required~justification is required,stringlength(20|1000)~justification must be between 20 and 1000 characters"`
+	// TTL is how long the credentials are valid for, e.g. 30m.
+	// MinBreakGlassTTL when empty.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate validates BreakGlass.
+func (req BreakGlass) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if req.TTL == "" {
+				return nil
+			}
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl < MinBreakGlassTTL {
+				return fmt.Errorf("ttl must be a duration of at least %s, e.g. 30m", MinBreakGlassTTL)
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateMaxTTL is an optional validation should be passed as parameter to
+// Validate().
+func (req BreakGlass) ValidateMaxTTL(max time.Duration) func() error {
+	return func() error {
+		if ttl, err := time.ParseDuration(req.TTL); err == nil && ttl > max {
+			return fmt.Errorf("ttl must be at most %s", max)
+		}
+		return nil
+	}
+}
+
+// MinElevationTTL is the shortest TTL of elevated access.
+const MinElevationTTL = 5 * time.Minute
+
+// RequestElevation request, temporary admin access of a project to itself.
+type RequestElevation struct {
+	// Justification is why the access is needed. It's recorded for the
+	// admin granting it.
+	Justification string `json:"justification" valid:"required~justification is required,stringlength(20|1000)~justification must be between 20 and 1000 characters"`
+	// TTL is how long the access lasts once granted, e.g. 30m.
+	// MinElevationTTL when empty.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Validate validates RequestElevation.
+func (req RequestElevation) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if req.TTL == "" {
+				return nil
+			}
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl < MinElevationTTL {
+				return fmt.Errorf("ttl must be a duration of at least %s, e.g. 30m", MinElevationTTL)
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateMaxTTL is an optional validation should be passed as parameter to
+// Validate().
+func (req RequestElevation) ValidateMaxTTL(max time.Duration) func() error {
+	return func() error {
+		if ttl, err := time.ParseDuration(req.TTL); err == nil && ttl > max {
+			return fmt.Errorf("ttl must be at most %s", max)
+		}
+		return nil
+	}
+}
+
+// CreateFrameworkImageRotation request, rolls the image of a framework
+// version to a digest, used by the workflows of the canary project until the
+// rotation is promoted.
+type CreateFrameworkImageRotation struct {
+	// Image is the tagged image of the framework version as workflows set
+	// it, e.g. argocloudops/argo-cloudops-terraform:1.0.11.
+	Image         string `json:"image" valid:"required~image is required"`
+	Digest        string `json:"digest" valid:"required~digest is required,matches(^sha256:[a-f0-9]{64}$)~digest must be a sha256 digest"`
+	CanaryProject string `json:"canary_project" valid:"required~canary_project is required,alphanum~canary_project must be alphanumeric,stringlength(4|32)~canary_project must be between 4 and 32 characters"`
+}
+
+// Validate validates CreateFrameworkImageRotation.
+func (req CreateFrameworkImageRotation) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if !validations.IsTaggedImageURI(req.Image) {
+				return errors.New("image must be a tagged container uri without digest")
+			}
+			return nil
+		},
+	)
+}
+
+// Principals requests can be evaluated for.
+const (
+	PrincipalAdmin   = "admin"
+	PrincipalProject = "project"
+)
+
+// EvaluatePolicies request, a hypothetical request the policies are evaluated
+// against without making it. Exactly one of CreateWorkflow and CreateTarget is
+// required.
+type EvaluatePolicies struct {
+	// Principal is who makes the request, admin or project. The caller when
+	// empty.
+	Principal string `json:"principal,omitempty"`
+	// At is the RFC 3339 time the request is made at, now when empty.
+	At             string                `json:"at,omitempty"`
+	CreateWorkflow *CreateWorkflow       `json:"create_workflow,omitempty"`
+	CreateTarget   *EvaluateCreateTarget `json:"create_target,omitempty"`
+}
+
+// EvaluateCreateTarget is a CreateTarget request of a project.
+type EvaluateCreateTarget struct {
+	ProjectName string `json:"project_name"`
+	CreateTarget
+}
+
+// Validate validates EvaluatePolicies.
+func (req EvaluatePolicies) Validate() error {
+	return validations.Validate(
+		func() error {
+			if req.Principal != "" && req.Principal != PrincipalAdmin && req.Principal != PrincipalProject {
+				return fmt.Errorf("principal must be one of '%s %s'", PrincipalAdmin, PrincipalProject)
+			}
+			return nil
+		},
+		func() error {
+			if req.At == "" {
+				return nil
+			}
+			if _, err := time.Parse(time.RFC3339, req.At); err != nil {
+				return errors.New("at must be an RFC 3339 time, e.g. 2022-03-14T10:00:00Z")
+			}
+			return nil
+		},
+		func() error {
+			if (req.CreateWorkflow == nil) == (req.CreateTarget == nil) {
+				return errors.New("exactly one of create_workflow and create_target is required")
+			}
+			return nil
+		},
+		func() error {
+			if req.CreateTarget != nil && req.CreateTarget.ProjectName == "" {
+				return errors.New("create_target project_name is required")
+			}
+			return nil
+		},
+	)
+}
+
+// MaxBatchWorkflowStatus is the most workflows whose status can be requested
+// in one BatchWorkflowStatus request.
+const MaxBatchWorkflowStatus = 100
+
+// BatchWorkflowStatus request, the workflows whose status is requested.
+type BatchWorkflowStatus struct {
+	WorkflowNames []string `json:"workflow_names"`
+}
+
+// Validate validates BatchWorkflowStatus.
+func (req BatchWorkflowStatus) Validate() error {
+	return validations.Validate(
+		func() error {
+			if len(req.WorkflowNames) == 0 {
+				return errors.New("workflow_names is required")
+			}
+			if len(req.WorkflowNames) > MaxBatchWorkflowStatus {
+				return fmt.Errorf("workflow_names must have at most %d names", MaxBatchWorkflowStatus)
+			}
+			return nil
+		},
+		func() error {
+			for _, name := range req.WorkflowNames {
+				if name == "" {
+					return errors.New("workflow_names must not have empty names")
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// PutVaultPolicyTemplate request. The template is validated server side,
+// where Vault parses the policies it renders.
+type PutVaultPolicyTemplate struct {
+	Template string `json:"template" valid:"required~template is required"`
+}
+
+// Validate validates PutVaultPolicyTemplate.
+func (req PutVaultPolicyTemplate) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+	)
+}
+
+// TargetReference is a target of a project.
+type TargetReference struct {
+	Project string `json:"project" valid:"required~project is required"`
+	Target  string `json:"target" valid:"required~target is required"`
+}
+
+// PutTargetDependencies request, replacing the dependencies of a target.
+type PutTargetDependencies struct {
+	// DependsOn are the targets which must deploy successfully before the
+	// target in rollouts.
+	DependsOn []TargetReference `json:"depends_on"`
+}
+
+// Validate validates PutTargetDependencies. Cycles are validated server side
+// with the dependencies of the other targets.
+func (req PutTargetDependencies) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			seen := map[TargetReference]bool{}
+			for _, d := range req.DependsOn {
+				if err := validations.ValidateStruct(d); err != nil {
+					return fmt.Errorf("depends_on %w", err)
+				}
+				if seen[d] {
+					return fmt.Errorf("depends_on has target '%s/%s' more than once", d.Project, d.Target)
+				}
+				seen[d] = true
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateNotSelf is an optional validation should be passed as parameter to
+// Validate().
+func (req PutTargetDependencies) ValidateNotSelf(project, target string) func() error {
+	return func() error {
+		for _, d := range req.DependsOn {
+			if d.Project == project && d.Target == target {
+				return errors.New("depends_on must not have the target itself")
+			}
+		}
+		return nil
+	}
+}
+
+// MaxRolloutSteps is the most steps a rollout can have.
+const MaxRolloutSteps = 50
+
+// RolloutStep is the submission of the workflow of a manifest of the git
+// repository of a project to one of its targets.
+type RolloutStep struct {
+	Project    string `json:"project" valid:"required~project is required"`
+	Target     string `json:"target" valid:"required~target is required"`
+	CommitHash string `json:"sha" valid:"required~sha is required,alphanum~sha must be alphanumeric"`
+	Path       string `json:"path" valid:"required~path is required"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
+}
+
+// CreateRollout request. Steps are submitted following the dependencies
+// between their targets.
+type CreateRollout struct {
+	Steps []RolloutStep `json:"steps"`
+}
+
+// Validate validates CreateRollout.
+func (req CreateRollout) Validate() error {
+	return validations.Validate(
+		func() error {
+			if len(req.Steps) == 0 {
+				return errors.New("steps is required")
+			}
+			if len(req.Steps) > MaxRolloutSteps {
+				return fmt.Errorf("steps must have at most %d steps", MaxRolloutSteps)
+			}
+			return nil
+		},
+		func() error {
+			for _, s := range req.Steps {
+				if err := validations.ValidateStruct(s); err != nil {
+					return fmt.Errorf("steps %w", err)
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// CreateExecutionNote request, a note attached to a past workflow.
+type CreateExecutionNote struct {
+	Note string `json:"note" valid:"required~note is required,stringlength(1|4000)~note must be between 1 and 4000 characters"`
+	// IncidentURL links the incident the workflow was part of, if any.
+	IncidentURL string `json:"incident_url,omitempty"`
+	// KnownBad flags the workflow as known-bad, or clears the flag when
+	// false. The flag is unchanged when nil.
+	KnownBad *bool `json:"known_bad,omitempty"`
+}
+
+// Validate validates CreateExecutionNote.
+func (req CreateExecutionNote) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if req.IncidentURL == "" {
+				return nil
+			}
+			u, err := url.Parse(req.IncidentURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("incident_url must be an http or https URL")
+			}
+			return nil
+		},
+	)
+}
+
+// MaxWebhookEvents is the maximum number of event filters of a webhook.
+const MaxWebhookEvents = 20
+
+var webhookEventFilter = regexp.MustCompile(`^([a-z0-9_]+\*?|\*)$`)
+
+// CreateProjectWebhook request, registering a callback URL the execution
+// events of the project are delivered to.
+type CreateProjectWebhook struct {
+	URL string `json:"url" valid:"required~url is required"`
+	// Secret signs the deliveries, one is generated when empty.
+	Secret string `json:"secret,omitempty"`
+	// Events filters the event types delivered (e.g. "workflow_submitted" or
+	// "rollout_step_*"), every event is delivered when empty.
+	Events []string `json:"events,omitempty"`
+}
+
+// Validate validates CreateProjectWebhook.
+func (req CreateProjectWebhook) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			u, err := url.Parse(req.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > 2048 {
+				return errors.New("url must be an http or https URL")
+			}
+			return nil
+		},
+		func() error {
+			if req.Secret != "" && (len(req.Secret) < 16 || len(req.Secret) > 255) {
+				return errors.New("secret must be between 16 and 255 characters")
+			}
+			return nil
+		},
+		func() error {
+			if len(req.Events) > MaxWebhookEvents {
+				return fmt.Errorf("events must have at most %d filters", MaxWebhookEvents)
+			}
+			for _, e := range req.Events {
+				if !webhookEventFilter.MatchString(e) {
+					return fmt.Errorf("event %q must be lowercase alphanumeric underscore, optionally ending with '*'", e)
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// secretNameRegex matches Kubernetes secret names, DNS subdomains.
+var secretNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// PutProjectEncryptionKey request, registering the public key values of the
+// project are encrypted with.
+type PutProjectEncryptionKey struct {
+	// PublicKey is a PEM encoded RSA public key.
+	PublicKey string `json:"public_key" valid:"required~public_key is required"`
+	// PrivateKeySecret is the name of the Kubernetes secret in the workflow
+	// namespace holding the private key, as 'private_key'.
+	PrivateKeySecret string `json:"private_key_secret" valid:"required~private_key_secret is required"`
+}
+
+// Validate validates PutProjectEncryptionKey.
+func (req PutProjectEncryptionKey) Validate() error {
+	return validations.Validate(
+		func() error { return validations.ValidateStruct(req) },
+		func() error {
+			if len(req.PrivateKeySecret) > 253 || !secretNameRegex.MatchString(req.PrivateKeySecret) {
+				return errors.New("private_key_secret must be a valid Kubernetes secret name")
+			}
+			return nil
+		},
+	)
+}
+
+// ApplyState request, the desired state of projects, e.g. from a git
+// repository managing Cello. Settings and schedules which aren't declared
+// (nil) are left alone. When Prune is set the targets and schedules of the
+// declared projects which aren't declared are deleted, projects themselves
+// are never deleted.
+type ApplyState struct {
+	Prune    bool                `json:"prune"`
+	Projects []ApplyStateProject `json:"projects"`
+}
+
+// ApplyStateProject is the desired state of a project.
+type ApplyStateProject struct {
+	Name       string              `json:"name"`
+	Repository string              `json:"repository"`
+	Settings   *PutProjectSettings `json:"settings,omitempty"`
+	Targets    []ApplyStateTarget  `json:"targets"`
+}
+
+// ApplyStateTarget is the desired state of a target and its schedules.
+type ApplyStateTarget struct {
+	CreateTarget
+	Schedules []ApplyStateSchedule `json:"schedules,omitempty"`
+}
+
+// ApplyStateSchedule is the desired state of a schedule of a target.
+type ApplyStateSchedule struct {
+	Name string `json:"name"`
+	PutTargetSchedule
+}
+
+// Validate validates ApplyState. The workflows of the schedules are
+// validated separately like the ones of PutTargetSchedule.
+func (req ApplyState) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			if len(req.Projects) == 0 {
+				return errors.New("projects is required")
+			}
+			return nil
+		},
+		func() error {
+			projects := map[string]bool{}
+			for _, p := range req.Projects {
+				if err := (CreateProject{Name: p.Name, Repository: p.Repository}).Validate(); err != nil {
+					return fmt.Errorf("project '%s' %w", p.Name, err)
+				}
+				if projects[p.Name] {
+					return fmt.Errorf("project '%s' is declared more than once", p.Name)
+				}
+				projects[p.Name] = true
+
+				if p.Settings != nil {
+					if err := p.Settings.Validate(); err != nil {
+						return fmt.Errorf("project '%s' settings %w", p.Name, err)
+					}
+				}
+				if err := p.validateTargets(); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+func (p ApplyStateProject) validateTargets() error {
+	targets := map[string]bool{}
+	for _, t := range p.Targets {
+		if err := types.Target(t.CreateTarget).Validate(); err != nil {
+			return fmt.Errorf("target '%s/%s' %w", p.Name, t.Name, err)
+		}
+		if targets[t.Name] {
+			return fmt.Errorf("target '%s/%s' is declared more than once", p.Name, t.Name)
+		}
+		targets[t.Name] = true
+
+		schedules := map[string]bool{}
+		for _, s := range t.Schedules {
+			if s.Name == "" {
+				return fmt.Errorf("target '%s/%s' schedule name is required", p.Name, t.Name)
+			}
+			if err := s.Validate(); err != nil {
+				return fmt.Errorf("schedule '%s/%s/%s' %w", p.Name, t.Name, s.Name, err)
+			}
+			if schedules[s.Name] {
+				return fmt.Errorf("schedule '%s/%s/%s' is declared more than once", p.Name, t.Name, s.Name)
+			}
+			schedules[s.Name] = true
+		}
+	}
+	return nil
+}
+
+// ValidateNotificationTypes is an optional validation should be passed as
+// parameter to Validate(), see PutProjectSettings.
+func (req ApplyState) ValidateNotificationTypes(notificationTypes []string) func() error {
+	return func() error {
+		for _, p := range req.Projects {
+			if p.Settings == nil {
+				continue
+			}
+			if err := p.Settings.ValidateNotificationTypes(notificationTypes)(); err != nil {
+				return fmt.Errorf("project '%s' settings %w", p.Name, err)
+			}
+		}
+		return nil
+	}
+}
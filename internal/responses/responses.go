@@ -1,5 +1,11 @@
 package responses
 
+import (
+	"encoding/json"
+
+	"github.com/cello-proj/cello/internal/types"
+)
+
 // Diff represents the responses for Diff.
 type Diff TargetOperation
 
@@ -11,16 +17,375 @@ type ExecuteWorkflow struct {
 	WorkflowName string `json:"workflow_name"`
 }
 
+// GetAdminStats represents the responses for GetAdminStats.
+type GetAdminStats struct {
+	Projects           int                     `json:"projects"`
+	Targets            int                     `json:"targets"`
+	WindowHours        int                     `json:"window_hours"`
+	Workflows          WorkflowStats           `json:"workflows"`
+	SubmissionsPerHour []HourlySubmissions     `json:"submissions_per_hour"`
+	Latencies          map[string]LatencyStats `json:"latencies"`
+	TopProjects        []ProjectStats          `json:"top_projects"`
+}
+
+// GetPublicStats represents the responses for GetPublicStats, the admin stats
+// without the ones naming projects.
+type GetPublicStats struct {
+	Projects           int                 `json:"projects"`
+	Targets            int                 `json:"targets"`
+	WindowHours        int                 `json:"window_hours"`
+	Workflows          WorkflowStats       `json:"workflows"`
+	SubmissionsPerHour []HourlySubmissions `json:"submissions_per_hour"`
+}
+
+// WorkflowStats represents the outcome of workflows submitted in a window.
+// The failure rate is of the completed workflows.
+type WorkflowStats struct {
+	Submitted   int     `json:"submitted"`
+	Active      int     `json:"active"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// HourlySubmissions represents the workflows submitted in an hour.
+type HourlySubmissions struct {
+	Hour        string `json:"hour"`
+	Submissions int    `json:"submissions"`
+}
+
+// LatencyStats represents latency percentiles of calls to a dependency.
+type LatencyStats struct {
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// ProjectStats represents the usage of a project.
+type ProjectStats struct {
+	Project     string  `json:"project"`
+	Submitted   int     `json:"submitted"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// GetConcurrency represents the responses for GetConcurrency, the workflows
+// running during each bucket of the window, oldest first. MaxRunning is the
+// most running during a bucket.
+type GetConcurrency struct {
+	WindowHours   int                 `json:"window_hours"`
+	BucketMinutes int                 `json:"bucket_minutes"`
+	MaxRunning    int                 `json:"max_running"`
+	Buckets       []ConcurrencyBucket `json:"buckets"`
+}
+
+// ConcurrencyBucket represents the workflows running at any time during a
+// bucket, in total and by target.
+type ConcurrencyBucket struct {
+	Start   string              `json:"start"`
+	Running int                 `json:"running"`
+	Targets []TargetConcurrency `json:"targets"`
+}
+
+// TargetConcurrency represents the workflows of a target running during a
+// bucket.
+type TargetConcurrency struct {
+	Project string `json:"project"`
+	Target  string `json:"target"`
+	Running int    `json:"running"`
+}
+
+// GetScheduledActions represents the responses for GetScheduledActions, the
+// actions the service will take in the window from Now, soonest first.
+type GetScheduledActions struct {
+	Now         string            `json:"now"`
+	WindowHours int               `json:"window_hours"`
+	Actions     []ScheduledAction `json:"actions"`
+}
+
+// ScheduledAction represents an action the service will take at a time. Name
+// is of the schedule, break-glass credentials, workflow or webhook delivery of
+// the action.
+type ScheduledAction struct {
+	At      string `json:"at"`
+	Type    string `json:"type"`
+	Project string `json:"project"`
+	Target  string `json:"target,omitempty"`
+	Name    string `json:"name"`
+}
+
+// GetUsage represents the responses for GetUsage, the resource usage of
+// workflow pods during the window by value of the label By.
+type GetUsage struct {
+	WindowHours int          `json:"window_hours"`
+	By          string       `json:"by"`
+	Usage       []LabelUsage `json:"usage"`
+}
+
+// LabelUsage represents the resource usage of the pods with a label value.
+type LabelUsage struct {
+	Value          string  `json:"value"`
+	CPUCoreHours   float64 `json:"cpu_core_hours"`
+	MemoryGiBHours float64 `json:"memory_gib_hours"`
+}
+
 // GetLogs represents the responses for GetLogs.
 type GetLogs struct {
 	Logs []string `json:"logs"`
+	// Continue is the token of the following lines, empty when the logs
+	// were complete.
+	Continue string `json:"continue,omitempty"`
 }
 
-// GetProject represents the responses for GetProject.
+// GetWorkflowNodes represents the responses for GetWorkflowNodes, a page of
+// the nodes of a workflow in the order they started.
+type GetWorkflowNodes struct {
+	Nodes []WorkflowNode `json:"nodes"`
+	// Continue is the token of the following page, empty on the last one.
+	Continue string `json:"continue,omitempty"`
+}
+
+// WorkflowNode represents a node of a workflow, e.g. a step or the pod
+// running it. Started and Finished are unix timestamps, empty until the node
+// started or finished.
+type WorkflowNode struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	DisplayName  string `json:"display_name"`
+	Type         string `json:"type"`
+	TemplateName string `json:"template_name"`
+	Phase        string `json:"phase"`
+	Message      string `json:"message,omitempty"`
+	Started      string `json:"started,omitempty"`
+	Finished     string `json:"finished,omitempty"`
+}
+
+// GetProject represents the responses for GetProject. ID is the stable ID
+// of the project, which changes when it's recreated with the same name.
 type GetProject struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// ListProjects represents the responses for ListProjects.
+type ListProjects []ProjectSummary
+
+// ProjectSummary represents a project of ListProjects.
+type ProjectSummary struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Repository string `json:"repository"`
+}
+
+// CreateTarget represents the responses for CreateTarget.
+type CreateTarget struct {
+	ID string `json:"id"`
+	// Warnings are about the target's project, e.g. its Vault policy
+	// exceeding the warning size.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// GetTarget represents the responses for GetTarget. ID is the stable ID of
+// the target, which changes when it's recreated with the same name.
+type GetTarget struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Type       string              `json:"type"`
+	Properties GetTargetProperties `json:"properties"`
+}
+
+// GetTargetProperties represents the properties of a target. The policy
+// document is sensitive: when it isn't included its SHA-256 is, so changes
+// can be detected without it.
+type GetTargetProperties struct {
+	CredentialType       string                      `json:"credential_type"`
+	PolicyArns           []string                    `json:"policy_arns"`
+	PolicyDocument       *string                     `json:"policy_document,omitempty"`
+	PolicyDocumentSHA256 string                      `json:"policy_document_sha256,omitempty"`
+	RoleArn              string                      `json:"role_arn"`
+	ServiceAccountEmail  string                      `json:"service_account_email,omitempty"`
+	TokenScopes          []string                    `json:"token_scopes,omitempty"`
+	TenantID             string                      `json:"tenant_id,omitempty"`
+	SubscriptionID       string                      `json:"subscription_id,omitempty"`
+	RoleAssignments      []types.AzureRoleAssignment `json:"role_assignments,omitempty"`
+}
+
+// ListTargets represents the responses for ListTargets with their IDs.
+type ListTargets []TargetSummary
+
+// TargetSummary represents a target of ListTargets.
+type TargetSummary struct {
+	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
+// GetProjectBusinessHours represents the responses for GetProjectBusinessHours.
+type GetProjectBusinessHours struct {
+	Timezone string   `json:"timezone"`
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Targets  []string `json:"targets"`
+}
+
+// GetProjectFeatureFlags represents the responses for GetProjectFeatureFlags.
+type GetProjectFeatureFlags []ProjectFeatureFlag
+
+// ProjectFeatureFlag represents the state of a feature flag for a project.
+// Source is where the state comes from, default, config or project.
+type ProjectFeatureFlag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+// GetProjectRegistryCredentials represents the responses for GetProjectRegistryCredentials.
+type GetProjectRegistryCredentials []ProjectRegistryCredential
+
+// ProjectRegistryCredential represents the credentials of a project for a
+// registry, the password is never returned.
+type ProjectRegistryCredential struct {
+	Registry string `json:"registry"`
+	Username string `json:"username"`
+}
+
+// GetProjectNotificationRules represents the responses for GetProjectNotificationRules.
+type GetProjectNotificationRules []ProjectNotificationRule
+
+// ProjectNotificationRule represents a project notification rule.
+type ProjectNotificationRule struct {
+	Type                string `json:"type"`
+	RoutingKey          string `json:"routing_key"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// GetProjectWorkflowTemplates represents the responses for GetProjectWorkflowTemplates.
+type GetProjectWorkflowTemplates []ProjectWorkflowTemplate
+
+// ProjectWorkflowTemplate represents a workflow template allowed for a project.
+type ProjectWorkflowTemplate struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// GetTargetChangeControl represents the responses for GetTargetChangeControl.
+type GetTargetChangeControl struct {
+	RequireApproval bool `json:"require_approval"`
+}
+
+// Environment represents an environment of a project in responses.
+type Environment struct {
+	Name string `json:"name"`
+	types.Environment
+}
+
+// GetProjectSettings represents the responses for GetProjectSettings.
+type GetProjectSettings types.ProjectSettings
+
+// GetTargetScheduling represents the responses for GetTargetScheduling.
+type GetTargetScheduling struct {
+	NodeSelector      map[string]string  `json:"node_selector"`
+	Tolerations       []types.Toleration `json:"tolerations"`
+	PriorityClassName string             `json:"priority_class_name"`
+	RuntimeClassName  string             `json:"runtime_class_name"`
+	Platform          string             `json:"platform,omitempty"`
+}
+
+// GetTargetWorkloadIdentity represents the responses for
+// GetTargetWorkloadIdentity.
+type GetTargetWorkloadIdentity struct {
+	ServiceAccount string `json:"service_account"`
+}
+
+// GetTargetCostAllocationTags represents the responses for
+// GetTargetCostAllocationTags.
+type GetTargetCostAllocationTags struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// ExecutionPlan represents the responses for GetExecutionPlan.
+type ExecutionPlan struct {
+	WorkflowName  string         `json:"workflow_name"`
+	CommitHash    string         `json:"commit_hash,omitempty"`
+	Add           int            `json:"add"`
+	Change        int            `json:"change"`
+	Destroy       int            `json:"destroy"`
+	ResourceTypes map[string]int `json:"resource_types"`
+	Changes       []PlanChange   `json:"changes"`
+	CreatedAt     string         `json:"created_at"`
+}
+
+// PlanChange is a planned change of a resource of ExecutionPlan.
+type PlanChange struct {
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	Address      string `json:"address"`
+}
+
+// GetTargetSoakTime represents the responses for GetTargetSoakTime.
+type GetTargetSoakTime struct {
+	MinSoak      string `json:"min_soak,omitempty"`
+	MaxStaleness string `json:"max_staleness,omitempty"`
+}
+
+// GetTargetTerraformState represents the responses for
+// GetTargetTerraformState.
+type GetTargetTerraformState struct {
+	Backend  string `json:"backend"`
+	Location string `json:"location"`
+	// Lock is the stale lock detected in the logs of a failed run, if any.
+	Lock *TerraformStateLock `json:"lock,omitempty"`
+}
+
+// TerraformStateLock is a stale lock of the terraform state of a target.
+type TerraformStateLock struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Operation string `json:"operation"`
+	Who       string `json:"who"`
+	// CreatedAt is empty when terraform's format couldn't be parsed.
+	CreatedAt    string `json:"created_at,omitempty"`
+	DetectedAt   string `json:"detected_at"`
+	WorkflowName string `json:"workflow_name"`
+}
+
+// PreviewWorkflow represents the responses for PreviewWorkflow.
+type PreviewWorkflow struct {
+	ExecuteCommand           string `json:"execute_command"`
+	ExecuteContainerImageURI string `json:"execute_container_image_uri"`
+}
+
+// GetTargetInventory represents the responses for GetTargetInventory, the
+// credentials of the hosts aren't returned.
+type GetTargetInventory struct {
+	Connection string              `json:"connection"`
+	User       string              `json:"user"`
+	Port       int                 `json:"port,omitempty"`
+	Hosts      []string            `json:"hosts"`
+	Groups     map[string][]string `json:"groups"`
+	Vars       map[string]string   `json:"vars"`
+}
+
+// GetTargetSchedules represents the responses for GetTargetSchedules.
+type GetTargetSchedules []TargetSchedule
+
+// TargetSchedule represents a schedule of a target.
+type TargetSchedule struct {
+	Name             string          `json:"name"`
+	Cron             string          `json:"cron"`
+	Timezone         string          `json:"timezone"`
+	Suspend          bool            `json:"suspend"`
+	CronWorkflowName string          `json:"cron_workflow_name"`
+	Workflow         json.RawMessage `json:"workflow"`
+}
+
+// GetTargetGuardrail represents the responses for GetTargetGuardrail.
+type GetTargetGuardrail struct {
+	Provider  string  `json:"provider"`
+	Query     string  `json:"query"`
+	Threshold float64 `json:"threshold"`
+	Operator  string  `json:"operator"`
+}
+
 // GetWorkflows represents the responses for GetWorkflows.
 type GetWorkflows []string
 
@@ -30,6 +395,107 @@ type GetWorkflowStatus struct {
 	Status   string `json:"status"`
 	Created  string `json:"created"`
 	Finished string `json:"finished"`
+	// PublicID is set when anonymous read only endpoints are enabled.
+	PublicID string `json:"public_id,omitempty"`
+	// Progress is set while the workflow is active.
+	Progress *WorkflowProgress `json:"progress,omitempty"`
+	// Origin is set when the workflow was submitted with one.
+	Origin *types.Origin `json:"origin,omitempty"`
+	// KnownBad is whether a note flagged the workflow as known-bad.
+	KnownBad bool            `json:"known_bad,omitempty"`
+	Notes    []ExecutionNote `json:"notes,omitempty"`
+}
+
+// WorkflowProgress is the progress of an active workflow. Percent is of the
+// completed nodes, or of the median duration of previous workflows of the
+// same project, target and type until Argo reports the nodes. ETA (RFC 3339)
+// is the creation time plus the median duration, in the past when the
+// workflow is slower than usual.
+type WorkflowProgress struct {
+	Percent               int    `json:"percent"`
+	NodesCompleted        int64  `json:"nodes_completed"`
+	NodesTotal            int64  `json:"nodes_total"`
+	MedianDurationSeconds int64  `json:"median_duration_seconds,omitempty"`
+	ETA                   string `json:"eta,omitempty"`
+}
+
+// GetPublicWorkflowStatus represents the responses for
+// GetPublicWorkflowStatus.
+type GetPublicWorkflowStatus struct {
+	PublicID string `json:"public_id"`
+	Status   string `json:"status"`
+	Created  string `json:"created"`
+	Finished string `json:"finished"`
+}
+
+// ShareWorkflow represents the responses for ShareWorkflow.
+type ShareWorkflow struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// BreakGlass represents the responses for BreakGlass, credentials of the
+// target valid until they expire. ID identifies the audit record.
+type BreakGlass struct {
+	ID              string `json:"id"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+	ExpiresAt       string `json:"expires_at"`
+}
+
+// BreakGlassRecord represents the audit record of break-glass credentials,
+// without the credentials. RevokedAt is empty until the lease is revoked.
+type BreakGlassRecord struct {
+	ID            string `json:"id"`
+	Justification string `json:"justification"`
+	RemoteAddress string `json:"remote_address"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+	RevokedAt     string `json:"revoked_at,omitempty"`
+}
+
+// Elevation represents the responses for RequestElevation. Token is the
+// authorization header of the elevated access, only valid once granted. It's
+// only returned when requested.
+type Elevation struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	State string `json:"state"`
+}
+
+// ElevationRecord represents the audit record of elevated access, without
+// its token. State is pending, granted, expired or revoked.
+type ElevationRecord struct {
+	ID            string `json:"id"`
+	RequestedBy   string `json:"requested_by"`
+	Justification string `json:"justification"`
+	TTL           string `json:"ttl"`
+	State         string `json:"state"`
+	CreatedAt     string `json:"created_at"`
+	GrantedBy     string `json:"granted_by,omitempty"`
+	GrantedAt     string `json:"granted_at,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	RevokedAt     string `json:"revoked_at,omitempty"`
+}
+
+// FrameworkImage represents a rotation of the image of a framework version
+// to a digest. State is canary, promoted or aborted. Pinned is whether it's
+// the latest promoted rotation of the image, whose digest every workflow
+// setting the image uses.
+type FrameworkImage struct {
+	ID            string `json:"id"`
+	Image         string `json:"image"`
+	Digest        string `json:"digest"`
+	CanaryProject string `json:"canary_project"`
+	State         string `json:"state"`
+	Pinned        bool   `json:"pinned"`
+	CreatedBy     string `json:"created_by"`
+	CreatedAt     string `json:"created_at"`
+	PromotedBy    string `json:"promoted_by,omitempty"`
+	PromotedAt    string `json:"promoted_at,omitempty"`
+	AbortedBy     string `json:"aborted_by,omitempty"`
+	AbortedAt     string `json:"aborted_at,omitempty"`
 }
 
 // Sync represents the responses for Sync.
@@ -39,3 +505,323 @@ type Sync TargetOperation
 type TargetOperation struct {
 	WorkflowName string `json:"workflow_name"`
 }
+
+// EvaluatePolicies represents the responses for EvaluatePolicies. The request
+// is allowed when all policies pass.
+type EvaluatePolicies struct {
+	Allowed  bool           `json:"allowed"`
+	Policies []PolicyResult `json:"policies"`
+}
+
+// PolicyResult is the result of a policy, the message tells why it failed.
+type PolicyResult struct {
+	Policy  string `json:"policy"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// CompareExecutions represents the responses for CompareExecutions, the
+// differences between two workflows of the same target.
+type CompareExecutions struct {
+	Project     string                `json:"project"`
+	Target      string                `json:"target"`
+	A           ExecutionSummary      `json:"a"`
+	B           ExecutionSummary      `json:"b"`
+	Differences []ExecutionDifference `json:"differences"`
+}
+
+// ExecutionSummary is what's compared of a workflow. DurationSeconds is 0
+// until the workflow finishes.
+type ExecutionSummary struct {
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	CommitHash      string `json:"commit_hash,omitempty"`
+	Created         string `json:"created"`
+	Finished        string `json:"finished"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+// ExecutionDifference is a field whose values differ between the workflows,
+// e.g. 'commit' or 'parameters.execute_container_image_uri'. Values missing
+// from a workflow are empty.
+type ExecutionDifference struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// BatchWorkflowStatus represents the responses for BatchWorkflowStatus, the
+// statuses in the order of the requested names.
+type BatchWorkflowStatus struct {
+	Workflows []BatchWorkflowStatusResult `json:"workflows"`
+}
+
+// BatchWorkflowStatusResult is the status of a workflow, or why it couldn't
+// be retrieved, e.g. 'workflow not found'.
+type BatchWorkflowStatusResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Finished string `json:"finished,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExecutionOutputs represents the responses for GetExecutionOutputs, the
+// results the steps of a workflow reported in the order they started. Outputs
+// are the outputs of all valid step results, of later steps when steps report
+// the same output.
+type ExecutionOutputs struct {
+	WorkflowName string            `json:"workflow_name"`
+	Status       string            `json:"status"`
+	Outputs      map[string]string `json:"outputs"`
+	Steps        []StepResult      `json:"steps"`
+}
+
+// StepResult represents the result a step reported. Error is why the result
+// is invalid, without its status, summary and outputs.
+type StepResult struct {
+	Step    string            `json:"step"`
+	Status  string            `json:"status,omitempty"`
+	Summary string            `json:"summary,omitempty"`
+	Outputs map[string]string `json:"outputs,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// ExecutionAttestation represents the responses for GetExecutionAttestation.
+// Signature is the base64 encoded signature of the bytes of Provenance, as
+// returned.
+type ExecutionAttestation struct {
+	Provenance         json.RawMessage `json:"provenance"`
+	Signature          string          `json:"signature"`
+	SignatureAlgorithm string          `json:"signature_algorithm"`
+	CreatedAt          string          `json:"created_at"`
+}
+
+// Provenance is how a sync was executed: what was deployed (commit and
+// parameters), with which images and by whom. Times are RFC 3339.
+type Provenance struct {
+	WorkflowName string            `json:"workflow_name"`
+	TxID         string            `json:"txid"`
+	Project      string            `json:"project"`
+	Target       string            `json:"target"`
+	Submitter    string            `json:"submitter"`
+	CommitHash   string            `json:"commit_hash,omitempty"`
+	Builder      ProvenanceBuilder `json:"builder"`
+	Parameters   map[string]string `json:"parameters"`
+	StartedOn    string            `json:"started_on"`
+	FinishedOn   string            `json:"finished_on"`
+	Outcome      string            `json:"outcome"`
+}
+
+// ProvenanceBuilder is what executed a sync, the images of its containers.
+type ProvenanceBuilder struct {
+	ID     string            `json:"id"`
+	Images []ProvenanceImage `json:"images"`
+}
+
+// ProvenanceImage is an image of a sync, from its workflow parameter. Digest
+// is only set for images referenced by digest.
+type ProvenanceImage struct {
+	Parameter string `json:"parameter"`
+	URI       string `json:"uri"`
+	Digest    string `json:"digest,omitempty"`
+}
+
+// GetVaultPolicyTemplate represents the responses for GetVaultPolicyTemplate.
+type GetVaultPolicyTemplate struct {
+	Template string `json:"template"`
+	// Default is whether the template is the built in template.
+	Default bool `json:"default"`
+	// Policy is the policy the template renders for the project of the
+	// request, if any.
+	Policy string `json:"policy,omitempty"`
+}
+
+// GetVaultPolicyUsage represents the responses for GetVaultPolicyUsage.
+type GetVaultPolicyUsage struct {
+	// Bytes is the size of the Vault policy of the project.
+	Bytes int `json:"bytes"`
+	// Policies are the Vault policies the policy is sharded across.
+	Policies     []string `json:"policies"`
+	Targets      int      `json:"targets"`
+	WarningBytes int      `json:"warning_bytes"`
+	MaxBytes     int      `json:"max_bytes"`
+}
+
+// GetTargetDependencies represents the responses for GetTargetDependencies.
+type GetTargetDependencies struct {
+	DependsOn []TargetReference `json:"depends_on"`
+}
+
+// TargetReference is a target of a project.
+type TargetReference struct {
+	Project string `json:"project"`
+	Target  string `json:"target"`
+}
+
+// Rollout represents a rollout across projects and the status of its steps.
+// Failed rollouts report which steps succeeded, failed or were skipped
+// because a dependency failed.
+type Rollout struct {
+	ID        string        `json:"id"`
+	Status    string        `json:"status"`
+	Steps     []RolloutStep `json:"steps"`
+	CreatedAt string        `json:"created_at"`
+	UpdatedAt string        `json:"updated_at"`
+}
+
+// RolloutStep represents a step of a rollout.
+type RolloutStep struct {
+	Project      string   `json:"project"`
+	Target       string   `json:"target"`
+	CommitHash   string   `json:"sha"`
+	Path         string   `json:"path"`
+	Name         string   `json:"name,omitempty"`
+	DependsOn    []string `json:"depends_on"`
+	Status       string   `json:"status"`
+	WorkflowName string   `json:"workflow_name,omitempty"`
+	Message      string   `json:"message,omitempty"`
+	// Outputs are the outputs the workflow of the step reported, see
+	// GetExecutionOutputs.
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// ExecutionNote represents a note attached to a workflow.
+type ExecutionNote struct {
+	Author      string `json:"author"`
+	Note        string `json:"note"`
+	IncidentURL string `json:"incident_url,omitempty"`
+	KnownBad    *bool  `json:"known_bad,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ProjectWebhook represents a webhook of a project. Secret is only returned
+// when the webhook is created.
+type ProjectWebhook struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// GetProjectWebhooks represents the webhooks of a project.
+type GetProjectWebhooks []ProjectWebhook
+
+// WebhookDelivery represents a delivery of an event to a webhook.
+type WebhookDelivery struct {
+	ID            string `json:"id"`
+	EventType     string `json:"event_type"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	ResponseCode  int    `json:"response_code,omitempty"`
+	Error         string `json:"error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// GetWebhookDeliveries represents the latest deliveries of a webhook.
+type GetWebhookDeliveries []WebhookDelivery
+
+// GetProjectEncryptionKey represents the encryption key of a project. Values
+// are encrypted as 'enc:v1:<key_id>:<base64 RSA-OAEP SHA-256 ciphertext>'.
+type GetProjectEncryptionKey struct {
+	KeyID            string `json:"key_id"`
+	PublicKey        string `json:"public_key"`
+	PrivateKeySecret string `json:"private_key_secret"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// ValidateTarget represents the result of validating a target without
+// creating it. Exists is true when the project already has a target with its
+// name, which would be updated rather than created.
+type ValidateTarget struct {
+	Valid      bool     `json:"valid"`
+	Exists     bool     `json:"exists"`
+	Violations []string `json:"violations"`
+}
+
+// ApplyState represents the changes applying a desired state made, or would
+// make for a dry run, in the order they're applied.
+type ApplyState struct {
+	DryRun  bool          `json:"dry_run"`
+	Changes []ApplyChange `json:"changes"`
+}
+
+// ApplyChange represents a change of a project, its settings, a target or a
+// schedule. Token is the token of created projects, only returned once.
+type ApplyChange struct {
+	Action   string `json:"action"`
+	Kind     string `json:"kind"`
+	Project  string `json:"project"`
+	Target   string `json:"target,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// Capabilities represents the responses for GetCapabilities, what the
+// deployment supports, so clients can adapt to the optional features it
+// enables rather than failing on their routes.
+type Capabilities struct {
+	AuthModes   []string            `json:"auth_modes"`
+	Frameworks  map[string][]string `json:"frameworks"`
+	TargetTypes []string            `json:"target_types"`
+	Features    map[string]bool     `json:"features"`
+	Backends    CapabilityBackends  `json:"backends"`
+	Limits      CapabilityLimits    `json:"limits"`
+}
+
+// CapabilityBackends are the providers of guardrails and notification rule
+// types registered in the deployment.
+type CapabilityBackends struct {
+	Guardrails    []string `json:"guardrails"`
+	Notifications []string `json:"notifications"`
+	// Credentials is the credentials provider, vault or aws_secrets_manager.
+	Credentials string `json:"credentials"`
+}
+
+// CapabilityLimits are the limits of requests, zero when disabled.
+type CapabilityLimits struct {
+	MaxRequestBodyBytes    int64  `json:"max_request_body_bytes"`
+	ManifestBundleMaxBytes int64  `json:"manifest_bundle_max_bytes"`
+	LogChunkMaxBytes       int    `json:"log_chunk_max_bytes"`
+	MaxWorkflowListLimit   int    `json:"max_workflow_list_limit"`
+	BreakGlassMaxTTL       string `json:"break_glass_max_ttl"`
+	ElevationMaxTTL        string `json:"elevation_max_ttl"`
+}
+
+// AuthSelf represents the resolved identity of the caller and what it can
+// act on, so clients can hide the actions it can't perform.
+type AuthSelf struct {
+	Project  string        `json:"project,omitempty"`
+	Roles    []string      `json:"roles"`
+	Scopes   []string      `json:"scopes"`
+	Quota    AuthQuota     `json:"quota"`
+	Projects []AuthProject `json:"projects"`
+	// Support is where the caller goes for help with failures of the
+	// platform, omitted when none is configured.
+	Support *SupportContact `json:"support,omitempty"`
+}
+
+// SupportContact represents where users go for help with failures of the
+// platform. Fields are omitted when not configured.
+type SupportContact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Chat  string `json:"chat,omitempty"`
+}
+
+// AuthQuota represents what's left of the credentials of the caller. Fields
+// are omitted when unlimited.
+type AuthQuota struct {
+	CredentialsExpireAt     string `json:"credentials_expire_at,omitempty"`
+	CredentialUsesRemaining int    `json:"credential_uses_remaining,omitempty"`
+}
+
+// AuthProject represents a project the caller can act on, with its targets.
+type AuthProject struct {
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+}
@@ -1,9 +1,26 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/validations"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Types of targets, an AWS account the role of which is assumed, a GCP
+// project the service account of which is impersonated or an Azure
+// subscription a service principal is created in.
+const (
+	TargetTypeAWSAccount        = "aws_account"
+	TargetTypeAzureSubscription = "azure_subscription"
+	TargetTypeGCPProject        = "gcp_project"
 )
 
 type Target struct {
@@ -12,43 +29,85 @@ type Target struct {
 	Type       string           `json:"type" valid:"required~type is required"`
 }
 
-// TargetProperties for target
+// TargetProperties for target. The role and policies are the ones of
+// aws_account targets, the service account and token scopes the ones of
+// gcp_project targets, the tenant, subscription and role assignments the ones
+// of azure_subscription targets.
 type TargetProperties struct {
-	CredentialType string   `json:"credential_type" valid:"required~credential_type is required"`
-	PolicyArns     []string `json:"policy_arns"`
-	PolicyDocument string   `json:"policy_document"`
-	RoleArn        string   `json:"role_arn" valid:"required~role_arn is required"`
+	CredentialType      string                `json:"credential_type" valid:"required~credential_type is required"`
+	PolicyArns          []string              `json:"policy_arns"`
+	PolicyDocument      string                `json:"policy_document"`
+	RoleArn             string                `json:"role_arn"`
+	ServiceAccountEmail string                `json:"service_account_email,omitempty"`
+	TokenScopes         []string              `json:"token_scopes,omitempty"`
+	TenantID            string                `json:"tenant_id,omitempty"`
+	SubscriptionID      string                `json:"subscription_id,omitempty"`
+	RoleAssignments     []AzureRoleAssignment `json:"role_assignments,omitempty"`
+}
+
+// AzureRoleAssignment is an Azure role assigned to the service principals of
+// an azure_subscription target at a scope of its subscription, e.g.
+// Contributor at /subscriptions/<id>/resourceGroups/<name>.
+type AzureRoleAssignment struct {
+	RoleName string `json:"role_name"`
+	Scope    string `json:"scope"`
 }
 
 // Validate validates Target.
 func (target Target) Validate() error {
+	return validations.Validate(target.validations()...)
+}
+
+// Violations returns every violation of Target rather than the first one,
+// e.g. for validating targets without creating them.
+func (target Target) Violations() []error {
+	return validations.ValidateAll(target.validations()...)
+}
+
+func (target Target) validations() []func() error {
 	v := []func() error{
 		func() error { return validations.ValidateStruct(target) },
 		func() error {
-			if target.Type != "aws_account" {
-				return errors.New("type must be one of 'aws_account'")
+			if target.Type != "" && target.Type != TargetTypeAWSAccount && target.Type != TargetTypeAzureSubscription && target.Type != TargetTypeGCPProject {
+				return errors.New("type must be one of 'aws_account azure_subscription gcp_project'")
 			}
 			return nil
 		},
-		target.Properties.Validate,
 	}
 
-	return validations.Validate(v...)
+	switch target.Type {
+	case TargetTypeAzureSubscription:
+		return append(v, target.Properties.azureValidations()...)
+	case TargetTypeGCPProject:
+		return append(v, target.Properties.gcpValidations()...)
+	}
+	return append(v, target.Properties.validations()...)
 }
 
-// Validate validates TargetProperties.
+// Validate validates TargetProperties of an aws_account target.
 func (properties TargetProperties) Validate() error {
-	v := []func() error{
+	return validations.Validate(properties.validations()...)
+}
+
+func (properties TargetProperties) validations() []func() error {
+	return []func() error{
 		func() error { return validations.ValidateStruct(properties) },
 		func() error {
-			if properties.CredentialType != "assumed_role" {
+			if properties.CredentialType != "" && properties.CredentialType != "assumed_role" {
 				return errors.New("credential_type must be one of 'assumed_role'")
 			}
-
+			return nil
+		},
+		func() error {
+			if properties.RoleArn == "" {
+				return errors.New("role_arn is required")
+			}
 			if !validations.IsValidARN(properties.RoleArn) {
 				return errors.New("role_arn must be a valid arn")
 			}
-
+			return nil
+		},
+		func() error {
 			if len(properties.PolicyArns) > 5 {
 				return errors.New("policy_arns cannot be more than 5")
 			}
@@ -60,7 +119,633 @@ func (properties TargetProperties) Validate() error {
 			}
 			return nil
 		},
+		func() error {
+			if properties.ServiceAccountEmail != "" || len(properties.TokenScopes) > 0 {
+				return errors.New("service_account_email and token_scopes are only supported by gcp_project targets")
+			}
+			return nil
+		},
+		properties.notAzure,
+	}
+}
+
+// notAzure rejects the properties of azure_subscription targets.
+func (properties TargetProperties) notAzure() error {
+	if properties.TenantID != "" || properties.SubscriptionID != "" || len(properties.RoleAssignments) > 0 {
+		return errors.New("tenant_id, subscription_id and role_assignments are only supported by azure_subscription targets")
+	}
+	return nil
+}
+
+// Max number of OAuth scopes of the tokens of gcp_project targets.
+const maxTokenScopes = 10
+
+func (properties TargetProperties) gcpValidations() []func() error {
+	return []func() error{
+		func() error { return validations.ValidateStruct(properties) },
+		func() error {
+			if properties.CredentialType != "" && properties.CredentialType != "impersonated_account" {
+				return errors.New("credential_type must be one of 'impersonated_account'")
+			}
+			return nil
+		},
+		func() error {
+			if properties.ServiceAccountEmail == "" {
+				return errors.New("service_account_email is required")
+			}
+			if !validations.IsValidServiceAccountEmail(properties.ServiceAccountEmail) {
+				return errors.New("service_account_email must be a valid service account email")
+			}
+			return nil
+		},
+		func() error {
+			if len(properties.TokenScopes) == 0 {
+				return errors.New("token_scopes is required")
+			}
+			if len(properties.TokenScopes) > maxTokenScopes {
+				return fmt.Errorf("token_scopes cannot be more than %d", maxTokenScopes)
+			}
+
+			for _, scope := range properties.TokenScopes {
+				if !validations.IsValidOAuthScope(scope) {
+					return errors.New("token_scopes contains an invalid scope")
+				}
+			}
+			return nil
+		},
+		func() error {
+			if properties.RoleArn != "" || len(properties.PolicyArns) > 0 || properties.PolicyDocument != "" {
+				return errors.New("role_arn, policy_arns and policy_document are only supported by aws_account targets")
+			}
+			return nil
+		},
+		properties.notAzure,
+	}
+}
+
+// Max number of role assignments of azure_subscription targets.
+const maxRoleAssignments = 10
+
+func (properties TargetProperties) azureValidations() []func() error {
+	return []func() error{
+		func() error { return validations.ValidateStruct(properties) },
+		func() error {
+			if properties.CredentialType != "" && properties.CredentialType != "service_principal" {
+				return errors.New("credential_type must be one of 'service_principal'")
+			}
+			return nil
+		},
+		func() error {
+			if properties.TenantID == "" {
+				return errors.New("tenant_id is required")
+			}
+			if !validations.IsValidUUID(properties.TenantID) {
+				return errors.New("tenant_id must be a valid uuid")
+			}
+			return nil
+		},
+		func() error {
+			if properties.SubscriptionID == "" {
+				return errors.New("subscription_id is required")
+			}
+			if !validations.IsValidUUID(properties.SubscriptionID) {
+				return errors.New("subscription_id must be a valid uuid")
+			}
+			return nil
+		},
+		func() error {
+			if len(properties.RoleAssignments) == 0 {
+				return errors.New("role_assignments is required")
+			}
+			if len(properties.RoleAssignments) > maxRoleAssignments {
+				return fmt.Errorf("role_assignments cannot be more than %d", maxRoleAssignments)
+			}
+
+			// Service principals are only assigned roles in the subscription
+			// of the target.
+			subscription := "/subscriptions/" + strings.ToLower(properties.SubscriptionID)
+			for _, ra := range properties.RoleAssignments {
+				if ra.RoleName == "" {
+					return errors.New("role_assignments role_name is required")
+				}
+				scope := strings.ToLower(ra.Scope)
+				if scope != subscription && !strings.HasPrefix(scope, subscription+"/") {
+					return errors.New("role_assignments scope must be in the subscription of subscription_id")
+				}
+			}
+			return nil
+		},
+		func() error {
+			if properties.RoleArn != "" || len(properties.PolicyArns) > 0 || properties.PolicyDocument != "" {
+				return errors.New("role_arn, policy_arns and policy_document are only supported by aws_account targets")
+			}
+			return nil
+		},
+		func() error {
+			if properties.ServiceAccountEmail != "" || len(properties.TokenScopes) > 0 {
+				return errors.New("service_account_email and token_scopes are only supported by gcp_project targets")
+			}
+			return nil
+		},
+	}
+}
+
+// Platforms are the os/arch platforms targets can run workflows on.
+var Platforms = []string{"linux/amd64", "linux/arm64", "windows/amd64"}
+
+// DefaultPlatform is the platform of targets not declaring one.
+const DefaultPlatform = "linux/amd64"
+
+// Well known node labels of the platform of Kubernetes nodes.
+const (
+	LabelOS   = "kubernetes.io/os"
+	LabelArch = "kubernetes.io/arch"
+)
+
+// IsValidPlatform returns true when the platform is one of Platforms.
+func IsValidPlatform(platform string) bool {
+	for _, p := range Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// Toleration allows the pods of workflows to run on nodes with matching
+// taints, like a Kubernetes toleration.
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+	// TolerationSeconds is how long a NoExecute taint is tolerated, forever
+	// when unset.
+	TolerationSeconds *int64 `json:"toleration_seconds,omitempty"`
+}
+
+// Validate validates Toleration.
+func (t Toleration) Validate() error {
+	v := []func() error{
+		func() error {
+			if t.Operator != "" && t.Operator != "Equal" && t.Operator != "Exists" {
+				return errors.New("operator must be one of 'Equal Exists'")
+			}
+			return nil
+		},
+		func() error {
+			if t.Operator == "Exists" && t.Value != "" {
+				return errors.New("value must be empty when operator is 'Exists'")
+			}
+			if t.Key == "" && t.Operator != "Exists" {
+				return errors.New("key is required unless operator is 'Exists'")
+			}
+			return nil
+		},
+		func() error {
+			switch t.Effect {
+			case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+				return nil
+			}
+			return errors.New("effect must be one of 'NoSchedule PreferNoSchedule NoExecute'")
+		},
+		func() error {
+			if t.TolerationSeconds != nil && t.Effect != "NoExecute" {
+				return errors.New("toleration_seconds requires effect 'NoExecute'")
+			}
+			return nil
+		},
+	}
+
+	return validations.Validate(v...)
+}
+
+// MaxRetentionDays is the longest retention of project settings.
+const MaxRetentionDays = 3650
+
+// ReservedLabelPrefix is the prefix of the labels the service adds to
+// workflows, which default labels can't set.
+const ReservedLabelPrefix = "cello-"
+
+// ProjectSettings is the settings document of a project.
+type ProjectSettings struct {
+	// Notifications are where the project owners are notified.
+	Notifications []NotificationDestination `json:"notifications"`
+	Approval      ApprovalSettings          `json:"approval"`
+	// DefaultLabels are added to the workflows of the project.
+	DefaultLabels map[string]string `json:"default_labels"`
+	Retention     RetentionSettings `json:"retention"`
+	// Tenant is charged for the resource usage of the workflows of the
+	// project (e.g. a cost center), the project when empty.
+	Tenant  string          `json:"tenant"`
+	Destroy DestroySettings `json:"destroy"`
+	HungRun HungRunSettings `json:"hung_run"`
+}
+
+// NotificationDestination is a notification system (e.g. pagerduty) and the
+// routing key of the project in it.
+type NotificationDestination struct {
+	Type       string `json:"type" valid:"required~notifications type is required"`
+	RoutingKey string `json:"routing_key" valid:"required~notifications routing_key is required"`
+}
+
+// ApprovalSettings are the default change control of the targets of the
+// project, for targets without their own.
+type ApprovalSettings struct {
+	ChangeControl   bool `json:"change_control"`
+	RequireApproval bool `json:"require_approval"`
+}
+
+// RetentionSettings are how many days workflows and their execution events
+// are kept, the service default when 0.
+type RetentionSettings struct {
+	WorkflowDays       int `json:"workflow_days"`
+	ExecutionEventDays int `json:"execution_event_days"`
+}
+
+// DestroySettings are the safeguards of the destroys of the targets of the
+// project, see requests.TypeDestroy.
+type DestroySettings struct {
+	// Allowed grants the project the workflows:destroy scope, its destroys
+	// are rejected otherwise.
+	Allowed bool `json:"allowed"`
+	// ProtectedTargets can't be destroyed, even when destroys are allowed.
+	ProtectedTargets []string `json:"protected_targets"`
+	// DualControl requires the change tickets of destroys and of every
+	// submission to protected targets to be approved by two distinct
+	// principals, neither being the submitter.
+	DualControl bool `json:"dual_control"`
+}
+
+// MinHungRunTimeout is the shortest no progress timeout of hung runs.
+const MinHungRunTimeout = time.Minute
+
+// HungRunSettings are when the workflows of the project are considered hung,
+// distinct from their overall deadline.
+type HungRunSettings struct {
+	// NoProgressTimeout is how long a workflow can run without any of its
+	// nodes completing before it's possibly hung, e.g. 30m. Empty disables.
+	NoProgressTimeout string `json:"no_progress_timeout"`
+	// Terminate terminates possibly hung workflows.
+	Terminate bool `json:"terminate"`
+}
+
+// Validate validates ProjectSettings.
+func (s ProjectSettings) Validate() error {
+	v := []func() error{}
+	for _, n := range s.Notifications {
+		n := n
+		v = append(v, func() error { return validations.ValidateStruct(n) })
+	}
+	v = append(v,
+		func() error {
+			seen := map[string]bool{}
+			for _, n := range s.Notifications {
+				if seen[n.Type] {
+					return fmt.Errorf("notifications must not repeat type '%s'", n.Type)
+				}
+				seen[n.Type] = true
+			}
+			return nil
+		},
+		func() error {
+			if s.Approval.RequireApproval && !s.Approval.ChangeControl {
+				return errors.New("approval require_approval requires change_control")
+			}
+			return nil
+		},
+		func() error {
+			for k, val := range s.DefaultLabels {
+				if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+					return fmt.Errorf("default_labels key '%s' is invalid: %s", k, strings.Join(errs, "; "))
+				}
+				if strings.HasPrefix(k, ReservedLabelPrefix) {
+					return fmt.Errorf("default_labels key '%s' must not start with '%s'", k, ReservedLabelPrefix)
+				}
+				if errs := validation.IsValidLabelValue(val); len(errs) > 0 {
+					return fmt.Errorf("default_labels value of '%s' is invalid: %s", k, strings.Join(errs, "; "))
+				}
+			}
+			return nil
+		},
+		func() error {
+			r := s.Retention
+			if r.WorkflowDays < 0 || r.WorkflowDays > MaxRetentionDays || r.ExecutionEventDays < 0 || r.ExecutionEventDays > MaxRetentionDays {
+				return fmt.Errorf("retention days must be between 0 and %d", MaxRetentionDays)
+			}
+			return nil
+		},
+		func() error {
+			if errs := validation.IsValidLabelValue(s.Tenant); len(errs) > 0 {
+				return fmt.Errorf("tenant is invalid: %s", strings.Join(errs, "; "))
+			}
+			return nil
+		},
+		func() error {
+			seen := map[string]bool{}
+			for _, t := range s.Destroy.ProtectedTargets {
+				if !targetNameRegex.MatchString(t) {
+					return fmt.Errorf("destroy protected_targets '%s' must be alphanumeric underscore between 4 and 32 characters", t)
+				}
+				if seen[t] {
+					return fmt.Errorf("destroy protected_targets must not repeat '%s'", t)
+				}
+				seen[t] = true
+			}
+			return nil
+		},
+		func() error {
+			if s.HungRun.NoProgressTimeout == "" {
+				if s.HungRun.Terminate {
+					return errors.New("hung_run terminate requires no_progress_timeout")
+				}
+				return nil
+			}
+			if d, err := time.ParseDuration(s.HungRun.NoProgressTimeout); err != nil || d < MinHungRunTimeout {
+				return fmt.Errorf("hung_run no_progress_timeout must be a duration of at least %s, e.g. 30m", MinHungRunTimeout)
+			}
+			return nil
+		},
+	)
+
+	return validations.Validate(v...)
+}
+
+// MaxEnvironmentTargets is the most targets of an environment.
+const MaxEnvironmentTargets = 50
+
+// Environment groups targets of a project (e.g. the regions of production)
+// sharing parameters, approval rules and freeze windows.
+type Environment struct {
+	Targets []string `json:"targets"`
+	// Parameters are the defaults of the workflow parameters of the targets,
+	// overridden by the ones of the requests.
+	Parameters Parameters `json:"parameters"`
+	// Approval is the change control of the targets without their own,
+	// overriding the one of the project settings when ChangeControl is set.
+	Approval      ApprovalSettings `json:"approval"`
+	FreezeWindows []FreezeWindow   `json:"freeze_windows"`
+}
+
+// FreezeWindow is a period during which workflows of the targets of an
+// environment aren't accepted, e.g. a holiday change freeze.
+type FreezeWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+var targetNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{3,31}$`)
+
+// Validate validates Environment.
+func (e Environment) Validate() error {
+	return validations.Validate(
+		func() error {
+			if len(e.Targets) == 0 || len(e.Targets) > MaxEnvironmentTargets {
+				return fmt.Errorf("targets must have between 1 and %d targets", MaxEnvironmentTargets)
+			}
+			seen := map[string]bool{}
+			for _, t := range e.Targets {
+				if !targetNameRegex.MatchString(t) {
+					return fmt.Errorf("targets '%s' must be alphanumeric underscore between 4 and 32 characters", t)
+				}
+				if seen[t] {
+					return fmt.Errorf("targets must not repeat '%s'", t)
+				}
+				seen[t] = true
+			}
+			return nil
+		},
+		func() error {
+			for k := range e.Parameters {
+				if k == "" {
+					return errors.New("parameters names must not be empty")
+				}
+			}
+			return nil
+		},
+		func() error {
+			if e.Approval.RequireApproval && !e.Approval.ChangeControl {
+				return errors.New("approval require_approval requires change_control")
+			}
+			return nil
+		},
+		func() error {
+			for _, f := range e.FreezeWindows {
+				if f.Start.IsZero() || !f.End.After(f.Start) {
+					return errors.New("freeze_windows end must be after start")
+				}
+				if len(f.Reason) > 255 {
+					return errors.New("freeze_windows reason must be at most 255 characters")
+				}
+			}
+			return nil
+		},
+	)
+}
+
+// FrozenAt returns the freeze window of the environment at the time, and
+// false when there's none.
+func (e Environment) FrozenAt(t time.Time) (FreezeWindow, bool) {
+	for _, f := range e.FreezeWindows {
+		if !t.Before(f.Start) && t.Before(f.End) {
+			return f, true
+		}
+	}
+	return FreezeWindow{}, false
+}
+
+// Origin is where a workflow submission comes from, e.g. the CI job, passed
+// in the X-Cello-Origin header for traceability. Values are workflow
+// annotations, so they can't contain ',' or '='.
+type Origin struct {
+	CIJobURL    string `json:"ci_job_url,omitempty"`
+	TicketID    string `json:"ticket_id,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+}
+
+var (
+	originURLRegex    = regexp.MustCompile(`^https?://[^\s,=]+$`)
+	originTicketRegex = regexp.MustCompile(`^[A-Za-z0-9#._/-]+$`)
+	originUserRegex   = regexp.MustCompile(`^[^\x00-\x1f\x7f,=]+$`)
+)
+
+// Validate validates Origin.
+func (o Origin) Validate() error {
+	v := []func() error{
+		func() error {
+			if o == (Origin{}) {
+				return errors.New("origin must set at least one of ci_job_url, ticket_id or triggered_by")
+			}
+			return nil
+		},
+		func() error {
+			if o.CIJobURL != "" && (len(o.CIJobURL) > 512 || !originURLRegex.MatchString(o.CIJobURL)) {
+				return errors.New("origin ci_job_url must be an http(s) url of at most 512 characters without ',' or '='")
+			}
+			return nil
+		},
+		func() error {
+			if o.TicketID != "" && (len(o.TicketID) > 64 || !originTicketRegex.MatchString(o.TicketID)) {
+				return errors.New("origin ticket_id must be at most 64 alphanumeric, '#', '.', '_', '/' or '-' characters")
+			}
+			return nil
+		},
+		func() error {
+			if o.TriggeredBy != "" && (len(o.TriggeredBy) > 128 || !originUserRegex.MatchString(o.TriggeredBy)) {
+				return errors.New("origin triggered_by must be at most 128 printable characters without ',' or '='")
+			}
+			return nil
+		},
 	}
 
 	return validations.Validate(v...)
 }
+
+// Fields returns the fields of the origin which are set, by JSON name.
+func (o Origin) Fields() map[string]string {
+	fields := map[string]string{}
+	for k, v := range map[string]string{"ci_job_url": o.CIJobURL, "ticket_id": o.TicketID, "triggered_by": o.TriggeredBy} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// Parameters are workflow parameters. Requests can set them as any JSON or
+// YAML value, rendered to the strings Argo parameters are: strings are
+// unchanged, numbers and booleans are their literal as written, lists and
+// objects are compact JSON, e.g. ["a","b"]. Null values are invalid.
+type Parameters map[string]string
+
+// InvalidParameterError is returned decoding Parameters with a value which
+// can't be rendered.
+type InvalidParameterError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidParameterError) Error() string {
+	return fmt.Sprintf("parameter '%s' %s", e.Name, e.Reason)
+}
+
+const nullParameterReason = "can't be null, use \"\" for an empty value"
+
+// UnmarshalJSON renders the values of the parameters.
+func (p *Parameters) UnmarshalJSON(data []byte) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if values == nil {
+		*p = nil
+		return nil
+	}
+
+	params := make(Parameters, len(values))
+	for k, v := range values {
+		switch v[0] {
+		case 'n':
+			return &InvalidParameterError{Name: k, Reason: nullParameterReason}
+		case '"':
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			params[k] = s
+		default:
+			// Numbers are kept as written, e.g. without rounding.
+			var b bytes.Buffer
+			if err := json.Compact(&b, v); err != nil {
+				return err
+			}
+			params[k] = b.String()
+		}
+	}
+	*p = params
+	return nil
+}
+
+// yamlScalar is the text of a YAML scalar as written, empty for other nodes.
+type yamlScalar string
+
+func (s *yamlScalar) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		*s = yamlScalar(str)
+	}
+	return nil
+}
+
+// UnmarshalYAML renders the values of the parameters. Scalars are kept as
+// written, e.g. 1.10 isn't rendered as 1.1.
+func (p *Parameters) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values map[string]interface{}
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	var scalars map[string]yamlScalar
+	if err := unmarshal(&scalars); err != nil {
+		return err
+	}
+	if values == nil {
+		*p = nil
+		return nil
+	}
+
+	params := make(Parameters, len(values))
+	for k, v := range values {
+		switch v.(type) {
+		case nil:
+			return &InvalidParameterError{Name: k, Reason: nullParameterReason}
+		case []interface{}, map[interface{}]interface{}:
+			jv, err := jsonValue(v)
+			if err != nil {
+				return &InvalidParameterError{Name: k, Reason: err.Error()}
+			}
+			data, err := json.Marshal(jv)
+			if err != nil {
+				return &InvalidParameterError{Name: k, Reason: err.Error()}
+			}
+			params[k] = string(data)
+		default:
+			params[k] = string(scalars[k])
+		}
+	}
+	*p = params
+	return nil
+}
+
+// jsonValue returns the YAML value with the maps JSON can encode, whose keys
+// are strings.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("keys must be strings, got %v", k)
+			}
+			jv, err := jsonValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = jv
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			jv, err := jsonValue(v)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = jv
+		}
+		return l, nil
+	default:
+		return v, nil
+	}
+}
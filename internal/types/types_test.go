@@ -1,10 +1,13 @@
 package types
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 )
 
 func TestTargetPropertiesValidate(t *testing.T) {
@@ -190,7 +193,7 @@ func TestCTargetValidate(t *testing.T) {
 				},
 				Type: "bad",
 			},
-			wantErr: errors.New("type must be one of 'aws_account'"),
+			wantErr: errors.New("type must be one of 'aws_account azure_subscription gcp_project'"),
 		},
 		{
 			name: "missing credential_type",
@@ -274,6 +277,260 @@ func TestCTargetValidate(t *testing.T) {
 			},
 			wantErr: errors.New("policy_arns contains an invalid arn"),
 		},
+		{
+			name: "aws_account properties must not have a service account",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "assumed_role",
+					RoleArn:             "arn:aws:iam::012345678901:role/test-role",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+				},
+				Type: "aws_account",
+			},
+			wantErr: errors.New("service_account_email and token_scopes are only supported by gcp_project targets"),
+		},
+		{
+			name: "valid gcp_project",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "impersonated_account",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+					TokenScopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+		},
+		{
+			name: "invalid gcp_project credential_type",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "assumed_role",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+					TokenScopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("credential_type must be one of 'impersonated_account'"),
+		},
+		{
+			name: "missing service_account_email",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "impersonated_account",
+					TokenScopes:    []string{"https://www.googleapis.com/auth/cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("service_account_email is required"),
+		},
+		{
+			name: "service_account_email must be a service account",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "impersonated_account",
+					ServiceAccountEmail: "someone@example.com",
+					TokenScopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("service_account_email must be a valid service account email"),
+		},
+		{
+			name: "missing token_scopes",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "impersonated_account",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("token_scopes is required"),
+		},
+		{
+			name: "token_scopes must be valid",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "impersonated_account",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+					TokenScopes:         []string{"cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("token_scopes contains an invalid scope"),
+		},
+		{
+			name: "gcp_project properties must not have a role",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType:      "impersonated_account",
+					RoleArn:             "arn:aws:iam::012345678901:role/test-role",
+					ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+					TokenScopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+				},
+				Type: "gcp_project",
+			},
+			wantErr: errors.New("role_arn, policy_arns and policy_document are only supported by aws_account targets"),
+		},
+		{
+			name: "aws_account properties must not have a tenant",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "assumed_role",
+					RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+				},
+				Type: "aws_account",
+			},
+			wantErr: errors.New("tenant_id, subscription_id and role_assignments are only supported by azure_subscription targets"),
+		},
+		{
+			name: "valid azure_subscription",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002/resourceGroups/deploy"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+		},
+		{
+			name: "valid azure_subscription at the subscription scope",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Reader", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+		},
+		{
+			name: "invalid azure_subscription credential_type",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "assumed_role",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002/resourceGroups/deploy"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("credential_type must be one of 'service_principal'"),
+		},
+		{
+			name: "missing tenant_id",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002/resourceGroups/deploy"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("tenant_id is required"),
+		},
+		{
+			name: "subscription_id must be a uuid",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "my-subscription",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/my-subscription"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("subscription_id must be a valid uuid"),
+		},
+		{
+			name: "missing role_assignments",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("role_assignments is required"),
+		},
+		{
+			name: "role_assignments must have a role_name",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{Scope: "/subscriptions/00000000-0000-0000-0000-000000000002"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("role_assignments role_name is required"),
+		},
+		{
+			name: "role_assignments must be in the subscription",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002-other"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("role_assignments scope must be in the subscription of subscription_id"),
+		},
+		{
+			name: "azure_subscription properties must not have token scopes",
+			target: Target{
+				Name: "target1",
+				Properties: TargetProperties{
+					CredentialType: "service_principal",
+					TenantID:       "00000000-0000-0000-0000-000000000001",
+					SubscriptionID: "00000000-0000-0000-0000-000000000002",
+					TokenScopes:    []string{"https://www.googleapis.com/auth/cloud-platform"},
+					RoleAssignments: []AzureRoleAssignment{
+						{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002/resourceGroups/deploy"},
+					},
+				},
+				Type: "azure_subscription",
+			},
+			wantErr: errors.New("service_account_email and token_scopes are only supported by gcp_project targets"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -286,3 +543,412 @@ func TestCTargetValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetViolations(t *testing.T) {
+	valid := Target{
+		Name: "target1",
+		Properties: TargetProperties{
+			CredentialType: "assumed_role",
+			RoleArn:        "arn:aws:iam::012345678901:role/test-role",
+		},
+		Type: "aws_account",
+	}
+	assert.Empty(t, valid.Violations())
+
+	invalid := Target{
+		Name: "t1",
+		Properties: TargetProperties{
+			CredentialType: "static",
+			RoleArn:        "not-an-arn",
+			PolicyArns:     []string{"not-an-arn"},
+		},
+		Type: "bad",
+	}
+	var got []string
+	for _, err := range invalid.Violations() {
+		got = append(got, err.Error())
+	}
+	assert.Equal(t, []string{
+		"name must be between 4 and 32 characters",
+		"type must be one of 'aws_account azure_subscription gcp_project'",
+		"credential_type must be one of 'assumed_role'",
+		"role_arn must be a valid arn",
+		"policy_arns contains an invalid arn",
+	}, got)
+}
+
+func TestTolerationValidate(t *testing.T) {
+	seconds := int64(300)
+
+	tests := []struct {
+		name       string
+		toleration Toleration
+		wantErr    error
+	}{
+		{
+			name:       "valid equal",
+			toleration: Toleration{Key: "dedicated", Operator: "Equal", Value: "deploy", Effect: "NoSchedule"},
+		},
+		{
+			name:       "valid exists without key",
+			toleration: Toleration{Operator: "Exists"},
+		},
+		{
+			name:       "valid toleration seconds",
+			toleration: Toleration{Key: "node.kubernetes.io/unreachable", Operator: "Exists", Effect: "NoExecute", TolerationSeconds: &seconds},
+		},
+		{
+			name:       "invalid operator",
+			toleration: Toleration{Key: "dedicated", Operator: "In"},
+			wantErr:    errors.New("operator must be one of 'Equal Exists'"),
+		},
+		{
+			name:       "exists with value",
+			toleration: Toleration{Key: "dedicated", Operator: "Exists", Value: "deploy"},
+			wantErr:    errors.New("value must be empty when operator is 'Exists'"),
+		},
+		{
+			name:       "missing key",
+			toleration: Toleration{Value: "deploy"},
+			wantErr:    errors.New("key is required unless operator is 'Exists'"),
+		},
+		{
+			name:       "invalid effect",
+			toleration: Toleration{Key: "dedicated", Effect: "NoRun"},
+			wantErr:    errors.New("effect must be one of 'NoSchedule PreferNoSchedule NoExecute'"),
+		},
+		{
+			name:       "toleration seconds without no execute",
+			toleration: Toleration{Key: "dedicated", Effect: "NoSchedule", TolerationSeconds: &seconds},
+			wantErr:    errors.New("toleration_seconds requires effect 'NoExecute'"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.toleration.Validate()
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestIsValidPlatform(t *testing.T) {
+	assert.True(t, IsValidPlatform("linux/arm64"))
+	assert.True(t, IsValidPlatform("windows/amd64"))
+	assert.False(t, IsValidPlatform("linux"))
+	assert.False(t, IsValidPlatform("darwin/arm64"))
+}
+
+func TestProjectSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ProjectSettings
+		wantErr  error
+	}{
+		{
+			name: "valid",
+			settings: ProjectSettings{
+				Notifications: []NotificationDestination{{Type: "pagerduty", RoutingKey: "key1"}},
+				Approval:      ApprovalSettings{ChangeControl: true, RequireApproval: true},
+				DefaultLabels: map[string]string{"team": "payments", "example.com/cost-center": "1234"},
+				Retention:     RetentionSettings{WorkflowDays: 30, ExecutionEventDays: 90},
+				Tenant:        "cost-center-1234",
+				Destroy:       DestroySettings{Allowed: true, ProtectedTargets: []string{"production"}},
+			},
+		},
+		{
+			name:     "empty",
+			settings: ProjectSettings{},
+		},
+		{
+			name:     "notification without routing key",
+			settings: ProjectSettings{Notifications: []NotificationDestination{{Type: "pagerduty"}}},
+			wantErr:  errors.New("notifications routing_key is required"),
+		},
+		{
+			name: "repeated notification type",
+			settings: ProjectSettings{Notifications: []NotificationDestination{
+				{Type: "pagerduty", RoutingKey: "key1"},
+				{Type: "pagerduty", RoutingKey: "key2"},
+			}},
+			wantErr: errors.New("notifications must not repeat type 'pagerduty'"),
+		},
+		{
+			name:     "approval without change control",
+			settings: ProjectSettings{Approval: ApprovalSettings{RequireApproval: true}},
+			wantErr:  errors.New("approval require_approval requires change_control"),
+		},
+		{
+			name:     "invalid label key",
+			settings: ProjectSettings{DefaultLabels: map[string]string{"team name": "payments"}},
+			wantErr:  errors.New("default_labels key 'team name' is invalid: name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')"),
+		},
+		{
+			name:     "reserved label key",
+			settings: ProjectSettings{DefaultLabels: map[string]string{"cello-project": "other"}},
+			wantErr:  errors.New("default_labels key 'cello-project' must not start with 'cello-'"),
+		},
+		{
+			name:     "invalid label value",
+			settings: ProjectSettings{DefaultLabels: map[string]string{"team": "pay ments"}},
+			wantErr:  errors.New("default_labels value of 'team' is invalid: a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"),
+		},
+		{
+			name:     "retention too long",
+			settings: ProjectSettings{Retention: RetentionSettings{WorkflowDays: 3651}},
+			wantErr:  errors.New("retention days must be between 0 and 3650"),
+		},
+		{
+			name:     "invalid tenant",
+			settings: ProjectSettings{Tenant: "cost center"},
+			wantErr:  errors.New("tenant is invalid: a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"),
+		},
+		{
+			name:     "invalid protected target",
+			settings: ProjectSettings{Destroy: DestroySettings{ProtectedTargets: []string{"pro"}}},
+			wantErr:  errors.New("destroy protected_targets 'pro' must be alphanumeric underscore between 4 and 32 characters"),
+		},
+		{
+			name:     "repeated protected target",
+			settings: ProjectSettings{Destroy: DestroySettings{ProtectedTargets: []string{"production", "production"}}},
+			wantErr:  errors.New("destroy protected_targets must not repeat 'production'"),
+		},
+		{
+			name:     "hung run",
+			settings: ProjectSettings{HungRun: HungRunSettings{NoProgressTimeout: "30m", Terminate: true}},
+		},
+		{
+			name:     "hung run timeout too short",
+			settings: ProjectSettings{HungRun: HungRunSettings{NoProgressTimeout: "30s"}},
+			wantErr:  errors.New("hung_run no_progress_timeout must be a duration of at least 1m0s, e.g. 30m"),
+		},
+		{
+			name:     "hung run terminate without timeout",
+			settings: ProjectSettings{HungRun: HungRunSettings{Terminate: true}},
+			wantErr:  errors.New("hung_run terminate requires no_progress_timeout"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.settings.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.settings.Validate())
+			}
+		})
+	}
+}
+
+func TestEnvironmentValidate(t *testing.T) {
+	start := time.Date(2022, 12, 23, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		env     Environment
+		wantErr error
+	}{
+		{
+			name: "valid",
+			env: Environment{
+				Targets:       []string{"prod_east", "prod_west"},
+				Parameters:    map[string]string{"execute_container_image_uri": "argocloudops/argo-cloudops-cdk:1.87.1"},
+				Approval:      ApprovalSettings{ChangeControl: true, RequireApproval: true},
+				FreezeWindows: []FreezeWindow{{Start: start, End: start.Add(240 * time.Hour), Reason: "holidays"}},
+			},
+		},
+		{
+			name:    "no targets",
+			env:     Environment{},
+			wantErr: errors.New("targets must have between 1 and 50 targets"),
+		},
+		{
+			name:    "invalid target",
+			env:     Environment{Targets: []string{"prod-east"}},
+			wantErr: errors.New("targets 'prod-east' must be alphanumeric underscore between 4 and 32 characters"),
+		},
+		{
+			name:    "repeated target",
+			env:     Environment{Targets: []string{"prod_east", "prod_east"}},
+			wantErr: errors.New("targets must not repeat 'prod_east'"),
+		},
+		{
+			name:    "empty parameter name",
+			env:     Environment{Targets: []string{"prod_east"}, Parameters: map[string]string{"": "value"}},
+			wantErr: errors.New("parameters names must not be empty"),
+		},
+		{
+			name:    "approval without change control",
+			env:     Environment{Targets: []string{"prod_east"}, Approval: ApprovalSettings{RequireApproval: true}},
+			wantErr: errors.New("approval require_approval requires change_control"),
+		},
+		{
+			name:    "freeze window ending before start",
+			env:     Environment{Targets: []string{"prod_east"}, FreezeWindows: []FreezeWindow{{Start: start, End: start}}},
+			wantErr: errors.New("freeze_windows end must be after start"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.env.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.env.Validate())
+			}
+		})
+	}
+}
+
+func TestEnvironmentFrozenAt(t *testing.T) {
+	start := time.Date(2022, 12, 23, 0, 0, 0, 0, time.UTC)
+	env := Environment{FreezeWindows: []FreezeWindow{{Start: start, End: start.Add(time.Hour), Reason: "holidays"}}}
+
+	_, ok := env.FrozenAt(start.Add(-time.Second))
+	assert.False(t, ok)
+	f, ok := env.FrozenAt(start)
+	assert.True(t, ok)
+	assert.Equal(t, "holidays", f.Reason)
+	_, ok = env.FrozenAt(start.Add(time.Hour))
+	assert.False(t, ok)
+}
+
+func TestOriginValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  Origin
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			origin: Origin{CIJobURL: "https://github.com/org/repo/actions/runs/123", TicketID: "CHG-123", TriggeredBy: "Jane Doe <jane@example.com>"},
+		},
+		{
+			name:    "empty",
+			origin:  Origin{},
+			wantErr: errors.New("origin must set at least one of ci_job_url, ticket_id or triggered_by"),
+		},
+		{
+			name:    "ci job url not http",
+			origin:  Origin{CIJobURL: "ftp://ci.example.com/job/1"},
+			wantErr: errors.New("origin ci_job_url must be an http(s) url of at most 512 characters without ',' or '='"),
+		},
+		{
+			name:    "ci job url with query",
+			origin:  Origin{CIJobURL: "https://ci.example.com/job?id=1"},
+			wantErr: errors.New("origin ci_job_url must be an http(s) url of at most 512 characters without ',' or '='"),
+		},
+		{
+			name:    "invalid ticket id",
+			origin:  Origin{TicketID: "CHG 123"},
+			wantErr: errors.New("origin ticket_id must be at most 64 alphanumeric, '#', '.', '_', '/' or '-' characters"),
+		},
+		{
+			name:    "triggered by with comma",
+			origin:  Origin{TriggeredBy: "Doe, Jane"},
+			wantErr: errors.New("origin triggered_by must be at most 128 printable characters without ',' or '='"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr != nil {
+				assert.EqualError(t, tt.origin.Validate(), tt.wantErr.Error())
+			} else {
+				assert.Nil(t, tt.origin.Validate())
+			}
+		})
+	}
+}
+
+func TestParametersUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Parameters
+		wantErr error
+	}{
+		{
+			name: "typed values",
+			data: `{"region": "us-west-2", "replicas": 3, "ratio": 1.50, "big": 12345678901234567890, "enabled": true, "zones": ["a", "b"], "tags": {"team": "payments", "cost": 1}}`,
+			want: Parameters{
+				"region":   "us-west-2",
+				"replicas": "3",
+				"ratio":    "1.50",
+				"big":      "12345678901234567890",
+				"enabled":  "true",
+				"zones":    `["a","b"]`,
+				"tags":     `{"team":"payments","cost":1}`,
+			},
+		},
+		{
+			name: "strings are unchanged",
+			data: `{"command": "echo \"[1, 2]\"", "empty": ""}`,
+			want: Parameters{"command": `echo "[1, 2]"`, "empty": ""},
+		},
+		{
+			name: "null parameters",
+			data: `null`,
+		},
+		{
+			name:    "null value",
+			data:    `{"region": null}`,
+			wantErr: &InvalidParameterError{Name: "region", Reason: `can't be null, use "" for an empty value`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Parameters
+			err := json.Unmarshal([]byte(tt.data), &p)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
+
+func TestParametersUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Parameters
+		wantErr string
+	}{
+		{
+			name: "typed values",
+			data: "region: us-west-2\nreplicas: 3\nversion: 1.10\nenabled: yes\nzones: [a, b]\ntags:\n  team: payments\n  cost: 1\n",
+			want: Parameters{
+				"region":   "us-west-2",
+				"replicas": "3",
+				"version":  "1.10",
+				"enabled":  "yes",
+				"zones":    `["a","b"]`,
+				"tags":     `{"cost":1,"team":"payments"}`,
+			},
+		},
+		{
+			name:    "null value",
+			data:    "region:\n",
+			wantErr: `parameter 'region' can't be null, use "" for an empty value`,
+		},
+		{
+			name:    "non string keys",
+			data:    "ports: {80: http}\n",
+			wantErr: "parameter 'ports' keys must be strings, got 80",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Parameters
+			err := yaml.Unmarshal([]byte(tt.data), &p)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
@@ -30,6 +30,34 @@ func Validate(validations ...func() error) error {
 	return nil
 }
 
+// ValidateAll runs all of the provided validation funcs, returning every
+// error rather than the first one. Struct errors are flattened to an error
+// per field and duplicate errors are only returned once.
+func ValidateAll(validations ...func() error) []error {
+	var errs []error
+	seen := map[string]bool{}
+	var add func(err error)
+	add = func(err error) {
+		if ve, ok := err.(govalidator.Errors); ok {
+			for _, e := range ve {
+				add(e)
+			}
+			return
+		}
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+
+	for _, v := range validations {
+		if err := v(); err != nil {
+			add(err)
+		}
+	}
+	return errs
+}
+
 // ValidateStruct validates the provided struct.
 func ValidateStruct(input interface{}) error {
 	customValidators := map[string]govalidator.CustomTypeValidator{
@@ -64,6 +92,28 @@ func IsValidARN(s string) bool {
 	return arn.IsARN(s)
 }
 
+// IsValidServiceAccountEmail determines if the string is the email of a GCP
+// service account, user-managed or a default one, e.g.
+// deployer@my-project.iam.gserviceaccount.com.
+func IsValidServiceAccountEmail(s string) bool {
+	pattern := `^[a-z0-9][a-z0-9-]{0,62}@[a-z][a-z0-9.-]*\.(iam\.gserviceaccount\.com|gserviceaccount\.com)$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsValidOAuthScope determines if the string is a Google OAuth scope, e.g.
+// https://www.googleapis.com/auth/cloud-platform.
+func IsValidOAuthScope(s string) bool {
+	pattern := `^https://www\.googleapis\.com/auth/[a-z0-9._-]+$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
+// IsValidUUID determines if the string is a UUID in its canonical form, e.g.
+// the ID of an Azure tenant or subscription.
+func IsValidUUID(s string) bool {
+	pattern := `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+	return regexp.MustCompile(pattern).MatchString(s)
+}
+
 // IsValidImageURI determines if the image URI is a valid container image URI
 // format.
 func IsValidImageURI(imageURI string) bool {
@@ -71,6 +121,18 @@ func IsValidImageURI(imageURI string) bool {
 	return err == nil
 }
 
+// IsTaggedImageURI determines if the image URI is a valid container image URI
+// with a tag and without a digest, e.g. argocloudops/argo-cloudops-cdk:1.87.1.
+func IsTaggedImageURI(imageURI string) bool {
+	ref, err := reference.Parse(imageURI)
+	if err != nil {
+		return false
+	}
+	_, tagged := ref.(reference.Tagged)
+	_, digested := ref.(reference.Digested)
+	return tagged && !digested
+}
+
 // IsApprovedImageURI determines if the image URI is approved for use. Default is allow-all. Full filepath matching
 // rules are applied to allow varying levels of globbing and wildcards.
 // Examples:
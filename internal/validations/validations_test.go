@@ -3,6 +3,7 @@ package validations
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,6 +40,26 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateAll(t *testing.T) {
+	type testStruct struct {
+		Name string `valid:"required~name is required"`
+		Type string `valid:"required~type is required"`
+	}
+	fooErr := errors.New("foo")
+
+	assert.Nil(t, ValidateAll(func() error { return nil }))
+
+	var got []string
+	for _, err := range ValidateAll(
+		func() error { return ValidateStruct(testStruct{}) },
+		func() error { return fooErr },
+		func() error { return fooErr },
+	) {
+		got = append(got, err.Error())
+	}
+	assert.Equal(t, []string{"name is required", "type is required", "foo"}, got)
+}
+
 func TestIsAlphaNumericUnderscore(t *testing.T) {
 	type testStruct struct {
 		Test string `valid:"alphanumunderscore"`
@@ -139,6 +160,43 @@ func TestIsValidImageURI(t *testing.T) {
 	}
 }
 
+func TestIsTaggedImageURI(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "tagged image",
+			testString: "argocloudops/argo-cloudops-cdk:1.87.1",
+			want:       true,
+		},
+		{
+			name:       "tagged image with registry",
+			testString: "registry.example.com:5000/argocloudops/argo-cloudops-cdk:1.87.1",
+			want:       true,
+		},
+		{
+			name:       "untagged image",
+			testString: "argocloudops/argo-cloudops-cdk",
+		},
+		{
+			name:       "digested image",
+			testString: "argocloudops/argo-cloudops-cdk:1.87.1@sha256:" + strings.Repeat("a", 64),
+		},
+		{
+			name:       "invalid image",
+			testString: "()argocloudops  -- /argo-cloudops-cdk:1.87.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTaggedImageURI(tt.testString))
+		})
+	}
+}
+
 func TestIsApprovedImageURI(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -215,3 +273,102 @@ func TestIsValidARN(t *testing.T) {
 		})
 	}
 }
+
+func TestIsValidServiceAccountEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "user-managed service account",
+			testString: "deployer@my-project.iam.gserviceaccount.com",
+			want:       true,
+		},
+		{
+			name:       "default service account",
+			testString: "my-project@appspot.gserviceaccount.com",
+			want:       true,
+		},
+		{
+			name:       "user account",
+			testString: "someone@example.com",
+		},
+		{
+			name:       "not an email",
+			testString: "deployer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidServiceAccountEmail(tt.testString))
+		})
+	}
+}
+
+func TestIsValidOAuthScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid scope",
+			testString: "https://www.googleapis.com/auth/cloud-platform",
+			want:       true,
+		},
+		{
+			name:       "valid read only scope",
+			testString: "https://www.googleapis.com/auth/devstorage.read_only",
+			want:       true,
+		},
+		{
+			name:       "short scope",
+			testString: "cloud-platform",
+		},
+		{
+			name:       "other host",
+			testString: "https://example.com/auth/cloud-platform",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidOAuthScope(tt.testString))
+		})
+	}
+}
+
+func TestIsValidUUID(t *testing.T) {
+	tests := []struct {
+		name       string
+		testString string
+		want       bool
+	}{
+		{
+			name:       "valid uuid",
+			testString: "0b5e7c1a-8d2f-4f5e-b1a3-6c9d2e4f7a8b",
+			want:       true,
+		},
+		{
+			name:       "valid upper case uuid",
+			testString: "0B5E7C1A-8D2F-4F5E-B1A3-6C9D2E4F7A8B",
+			want:       true,
+		},
+		{
+			name:       "without hyphens",
+			testString: "0b5e7c1a8d2f4f5eb1a36c9d2e4f7a8b",
+		},
+		{
+			name:       "braced",
+			testString: "{0b5e7c1a-8d2f-4f5e-b1a3-6c9d2e4f7a8b}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsValidUUID(tt.testString))
+		})
+	}
+}
@@ -5,4 +5,383 @@ CREATE TABLE IF NOT EXISTS projects
     repository character varying(200),
     CONSTRAINT projects_pkey PRIMARY KEY (project)
 );
-GRANT ALL PRIVILEGES ON projects TO argoco;
\ No newline at end of file
+GRANT ALL PRIVILEGES ON projects TO argoco;
+CREATE TABLE IF NOT EXISTS execution_events
+(
+    id SERIAL PRIMARY KEY,
+    txid character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    workflow_name character varying(253),
+    type character varying(40) NOT NULL,
+    message text,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS execution_events_txid_idx ON execution_events (txid);
+CREATE INDEX IF NOT EXISTS execution_events_type_created_at_idx ON execution_events (type, created_at);
+CREATE INDEX IF NOT EXISTS execution_events_workflow_name_idx ON execution_events (workflow_name);
+GRANT ALL PRIVILEGES ON execution_events TO argoco;
+GRANT USAGE, SELECT ON SEQUENCE execution_events_id_seq TO argoco;
+CREATE TABLE IF NOT EXISTS target_guardrails
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    provider character varying(40) NOT NULL,
+    query text NOT NULL,
+    threshold double precision NOT NULL,
+    operator character varying(10) NOT NULL,
+    CONSTRAINT target_guardrails_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_guardrails TO argoco;
+CREATE TABLE IF NOT EXISTS target_change_controls
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    require_approval boolean NOT NULL DEFAULT false,
+    CONSTRAINT target_change_controls_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_change_controls TO argoco;
+CREATE TABLE IF NOT EXISTS project_notification_rules
+(
+    project character varying(80) NOT NULL,
+    type character varying(40) NOT NULL,
+    routing_key character varying(255) NOT NULL,
+    consecutive_failures integer NOT NULL,
+    CONSTRAINT project_notification_rules_pkey PRIMARY KEY (project, type)
+);
+GRANT ALL PRIVILEGES ON project_notification_rules TO argoco;
+CREATE TABLE IF NOT EXISTS project_business_hours
+(
+    project character varying(80) NOT NULL,
+    timezone character varying(80) NOT NULL,
+    days character varying(40) NOT NULL,
+    start_time character varying(5) NOT NULL,
+    end_time character varying(5) NOT NULL,
+    targets text NOT NULL DEFAULT '',
+    CONSTRAINT project_business_hours_pkey PRIMARY KEY (project)
+);
+GRANT ALL PRIVILEGES ON project_business_hours TO argoco;
+CREATE TABLE IF NOT EXISTS project_workflow_templates
+(
+    project character varying(80) NOT NULL,
+    kind character varying(40) NOT NULL,
+    name character varying(253) NOT NULL,
+    CONSTRAINT project_workflow_templates_pkey PRIMARY KEY (project, kind, name)
+);
+GRANT ALL PRIVILEGES ON project_workflow_templates TO argoco;
+CREATE TABLE IF NOT EXISTS project_feature_flags
+(
+    project character varying(80) NOT NULL,
+    name character varying(80) NOT NULL,
+    enabled boolean NOT NULL,
+    CONSTRAINT project_feature_flags_pkey PRIMARY KEY (project, name)
+);
+GRANT ALL PRIVILEGES ON project_feature_flags TO argoco;
+CREATE TABLE IF NOT EXISTS project_registry_credentials
+(
+    project character varying(80) NOT NULL,
+    registry character varying(255) NOT NULL,
+    username character varying(255) NOT NULL,
+    password_ref character varying(80) NOT NULL DEFAULT '',
+    CONSTRAINT project_registry_credentials_pkey PRIMARY KEY (project, registry)
+);
+ALTER TABLE project_registry_credentials ADD COLUMN IF NOT EXISTS password_ref character varying(80) NOT NULL DEFAULT '';
+ALTER TABLE project_registry_credentials DROP COLUMN IF EXISTS password;
+GRANT ALL PRIVILEGES ON project_registry_credentials TO argoco;
+CREATE TABLE IF NOT EXISTS target_schedules
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    name character varying(40) NOT NULL,
+    cron character varying(80) NOT NULL,
+    timezone character varying(80) NOT NULL DEFAULT '',
+    suspend boolean NOT NULL DEFAULT false,
+    workflow text NOT NULL,
+    CONSTRAINT target_schedules_pkey PRIMARY KEY (project, target, name)
+);
+GRANT ALL PRIVILEGES ON target_schedules TO argoco;
+CREATE TABLE IF NOT EXISTS target_scheduling
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    node_selector text NOT NULL DEFAULT '{}',
+    tolerations text NOT NULL DEFAULT '[]',
+    priority_class_name character varying(253) NOT NULL DEFAULT '',
+    runtime_class_name character varying(253) NOT NULL DEFAULT '',
+    platform character varying(32) NOT NULL DEFAULT '',
+    CONSTRAINT target_scheduling_pkey PRIMARY KEY (project, target)
+);
+ALTER TABLE target_scheduling ADD COLUMN IF NOT EXISTS platform character varying(32) NOT NULL DEFAULT '';
+GRANT ALL PRIVILEGES ON target_scheduling TO argoco;
+CREATE TABLE IF NOT EXISTS target_workload_identities
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    service_account character varying(253) NOT NULL,
+    CONSTRAINT target_workload_identities_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_workload_identities TO argoco;
+CREATE TABLE IF NOT EXISTS run_tokens
+(
+    workflow_name character varying(253) NOT NULL,
+    txid character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    accessor character varying(128) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT run_tokens_pkey PRIMARY KEY (workflow_name)
+);
+GRANT ALL PRIVILEGES ON run_tokens TO argoco;
+
+CREATE TABLE IF NOT EXISTS target_change_set_summaries
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    stack_name character varying(128) NOT NULL,
+    change_set_name character varying(128) NOT NULL,
+    workflow_name character varying(253) NOT NULL,
+    summary text NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT target_change_set_summaries_pkey PRIMARY KEY (project, target, stack_name, change_set_name)
+);
+GRANT ALL PRIVILEGES ON target_change_set_summaries TO argoco;
+CREATE TABLE IF NOT EXISTS target_plan_summaries
+(
+    workflow_name character varying(253) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    commit_hash character varying(80) NOT NULL DEFAULT '',
+    summary text NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT target_plan_summaries_pkey PRIMARY KEY (workflow_name)
+);
+CREATE INDEX IF NOT EXISTS target_plan_summaries_commit_idx ON target_plan_summaries (project, target, commit_hash);
+GRANT ALL PRIVILEGES ON target_plan_summaries TO argoco;
+CREATE TABLE IF NOT EXISTS target_inventories
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    connection character varying(16) NOT NULL,
+    user_name character varying(255) NOT NULL,
+    port integer NOT NULL DEFAULT 0,
+    hosts text NOT NULL DEFAULT '[]',
+    groups text NOT NULL DEFAULT '{}',
+    vars text NOT NULL DEFAULT '{}',
+    CONSTRAINT target_inventories_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_inventories TO argoco;
+CREATE TABLE IF NOT EXISTS target_break_glass
+(
+    id character varying(80) NOT NULL,
+    txid character varying(80) NOT NULL DEFAULT '',
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    justification text NOT NULL,
+    remote_address character varying(255) NOT NULL,
+    lease_id character varying(255) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    revoked_at timestamp with time zone,
+    CONSTRAINT target_break_glass_pkey PRIMARY KEY (id)
+);
+ALTER TABLE target_break_glass ADD COLUMN IF NOT EXISTS txid character varying(80) NOT NULL DEFAULT '';
+GRANT ALL PRIVILEGES ON target_break_glass TO argoco;
+CREATE TABLE IF NOT EXISTS project_settings
+(
+    project character varying(80) NOT NULL,
+    settings text NOT NULL DEFAULT '{}',
+    updated_at timestamp with time zone NOT NULL,
+    CONSTRAINT project_settings_pkey PRIMARY KEY (project)
+);
+GRANT ALL PRIVILEGES ON project_settings TO argoco;
+CREATE TABLE IF NOT EXISTS target_cost_allocation_tags
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    tags text NOT NULL DEFAULT '{}',
+    CONSTRAINT target_cost_allocation_tags_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_cost_allocation_tags TO argoco;
+CREATE TABLE IF NOT EXISTS target_soak_times
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    min_soak_seconds bigint NOT NULL DEFAULT 0,
+    max_staleness_seconds bigint NOT NULL DEFAULT 0,
+    CONSTRAINT target_soak_times_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_soak_times TO argoco;
+CREATE TABLE IF NOT EXISTS target_terraform_states
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    backend character varying(32) NOT NULL,
+    location character varying(1024) NOT NULL,
+    lock_id character varying(128) NOT NULL DEFAULT '',
+    lock_path character varying(1024) NOT NULL DEFAULT '',
+    lock_operation character varying(64) NOT NULL DEFAULT '',
+    lock_who character varying(256) NOT NULL DEFAULT '',
+    lock_created_at timestamp with time zone,
+    lock_workflow_name character varying(253) NOT NULL DEFAULT '',
+    lock_detected_at timestamp with time zone,
+    workflow text NOT NULL DEFAULT '',
+    CONSTRAINT target_terraform_states_pkey PRIMARY KEY (project, target)
+);
+GRANT ALL PRIVILEGES ON target_terraform_states TO argoco;
+CREATE TABLE IF NOT EXISTS execution_attestations
+(
+    workflow_name character varying(253) NOT NULL,
+    txid character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    provenance text NOT NULL,
+    signature character varying(128) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT execution_attestations_pkey PRIMARY KEY (workflow_name)
+);
+GRANT ALL PRIVILEGES ON execution_attestations TO argoco;
+CREATE TABLE IF NOT EXISTS target_submission_sources
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    source character varying(512) NOT NULL,
+    first_submitted_at timestamp with time zone NOT NULL,
+    CONSTRAINT target_submission_sources_pkey PRIMARY KEY (project, target, source)
+);
+GRANT ALL PRIVILEGES ON target_submission_sources TO argoco;
+CREATE TABLE IF NOT EXISTS target_dependencies
+(
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    depends_on_project character varying(80) NOT NULL,
+    depends_on_target character varying(80) NOT NULL,
+    CONSTRAINT target_dependencies_pkey PRIMARY KEY (project, target, depends_on_project, depends_on_target)
+);
+GRANT ALL PRIVILEGES ON target_dependencies TO argoco;
+CREATE TABLE IF NOT EXISTS rollouts
+(
+    id character varying(80) NOT NULL,
+    status character varying(20) NOT NULL,
+    steps text NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    CONSTRAINT rollouts_pkey PRIMARY KEY (id)
+);
+GRANT ALL PRIVILEGES ON rollouts TO argoco;
+CREATE TABLE IF NOT EXISTS execution_notes
+(
+    id character varying(80) NOT NULL,
+    workflow_name character varying(253) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    author character varying(255) NOT NULL,
+    note text NOT NULL,
+    incident_url character varying(2048) NOT NULL,
+    known_bad boolean,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT execution_notes_pkey PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS execution_notes_project_target_idx ON execution_notes (project, target);
+GRANT ALL PRIVILEGES ON execution_notes TO argoco;
+CREATE TABLE IF NOT EXISTS project_webhooks
+(
+    id character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    url character varying(2048) NOT NULL,
+    secret character varying(255) NOT NULL,
+    events text NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT project_webhooks_pkey PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS project_webhooks_project_idx ON project_webhooks (project);
+GRANT ALL PRIVILEGES ON project_webhooks TO argoco;
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id character varying(80) NOT NULL,
+    webhook_id character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    event_type character varying(80) NOT NULL,
+    payload text NOT NULL,
+    status character varying(20) NOT NULL,
+    attempts integer NOT NULL,
+    response_code integer NOT NULL,
+    error text NOT NULL,
+    next_attempt_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_idx ON webhook_deliveries (status, next_attempt_at);
+GRANT ALL PRIVILEGES ON webhook_deliveries TO argoco;
+CREATE TABLE IF NOT EXISTS project_encryption_keys
+(
+    project character varying(80) NOT NULL,
+    key_id character varying(16) NOT NULL,
+    public_key text NOT NULL,
+    private_key_secret character varying(253) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT project_encryption_keys_pkey PRIMARY KEY (project)
+);
+GRANT ALL PRIVILEGES ON project_encryption_keys TO argoco;
+CREATE TABLE IF NOT EXISTS resource_ids
+(
+    id character varying(36) NOT NULL,
+    kind character varying(16) NOT NULL,
+    project character varying(80) NOT NULL,
+    target character varying(80) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT resource_ids_pkey PRIMARY KEY (kind, project, target),
+    CONSTRAINT resource_ids_id_key UNIQUE (id)
+);
+GRANT ALL PRIVILEGES ON resource_ids TO argoco;
+CREATE TABLE IF NOT EXISTS project_aliases
+(
+    alias character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    CONSTRAINT project_aliases_pkey PRIMARY KEY (alias)
+);
+CREATE INDEX IF NOT EXISTS project_aliases_project_idx ON project_aliases (project);
+GRANT ALL PRIVILEGES ON project_aliases TO argoco;
+CREATE TABLE IF NOT EXISTS project_environments
+(
+    project character varying(80) NOT NULL,
+    name character varying(32) NOT NULL,
+    environment text NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    CONSTRAINT project_environments_pkey PRIMARY KEY (project, name)
+);
+GRANT ALL PRIVILEGES ON project_environments TO argoco;
+CREATE TABLE IF NOT EXISTS project_elevations
+(
+    id character varying(80) NOT NULL,
+    project character varying(80) NOT NULL,
+    requested_by character varying(255) NOT NULL,
+    justification text NOT NULL,
+    ttl_seconds bigint NOT NULL,
+    token_hash character varying(64) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    granted_by character varying(255) NOT NULL DEFAULT '',
+    granted_at timestamp with time zone,
+    expires_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    CONSTRAINT project_elevations_pkey PRIMARY KEY (id)
+);
+GRANT ALL PRIVILEGES ON project_elevations TO argoco;
+CREATE TABLE IF NOT EXISTS framework_images
+(
+    id character varying(80) NOT NULL,
+    framework character varying(80) NOT NULL,
+    image character varying(255) NOT NULL,
+    digest character varying(80) NOT NULL,
+    canary_project character varying(80) NOT NULL,
+    created_by character varying(255) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    promoted_by character varying(255) NOT NULL DEFAULT '',
+    promoted_at timestamp with time zone,
+    aborted_by character varying(255) NOT NULL DEFAULT '',
+    aborted_at timestamp with time zone,
+    CONSTRAINT framework_images_pkey PRIMARY KEY (id)
+);
+GRANT ALL PRIVILEGES ON framework_images TO argoco;
@@ -0,0 +1,126 @@
+#!/bin/bash
+# Runs the end to end tests against a kind cluster running the Argo Workflows
+# controller and dockerized Vault (dev mode) and Postgres. Set E2E_KEEP=1 to
+# keep them running afterwards.
+
+set -e
+
+CLUSTER_NAME=cello-e2e
+ARGO_VERSION=v3.1.13
+VAULT_CONTAINER=cello-e2e-vault
+POSTGRES_CONTAINER=cello-e2e-postgres
+
+for cmd in docker kind kubectl jq; do
+    if ! command -v $cmd >/dev/null 2>&1; then
+        echo "ERROR: '$cmd' command could not be found"
+        exit 1
+    fi
+done
+
+cleanup() {
+    if [ "$E2E_KEEP" == "1" ]; then
+        echo "Keeping cluster '$CLUSTER_NAME' and containers running."
+        return
+    fi
+    kind delete cluster --name $CLUSTER_NAME >/dev/null 2>&1 || true
+    docker rm -f $VAULT_CONTAINER $POSTGRES_CONTAINER >/dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+export KUBECONFIG=$(mktemp -d)/kubeconfig
+
+if ! kind get clusters | grep -q "^$CLUSTER_NAME$"; then
+    echo "Creating kind cluster '$CLUSTER_NAME'."
+    kind create cluster --name $CLUSTER_NAME --wait 120s
+fi
+kind export kubeconfig --name $CLUSTER_NAME
+
+echo "Installing Argo Workflows $ARGO_VERSION."
+kubectl create namespace argo --dry-run=client -o yaml | kubectl apply -f -
+kubectl apply -n argo -f https://github.com/argoproj/argo-workflows/releases/download/$ARGO_VERSION/install.yaml
+# kind nodes run containerd, so the docker executor can't be used.
+kubectl patch configmap workflow-controller-configmap -n argo --type merge \
+    -p '{"data":{"containerRuntimeExecutor":"emissary"}}'
+kubectl create rolebinding default-admin -n argo --clusterrole=admin --serviceaccount=argo:default \
+    --dry-run=client -o yaml | kubectl apply -f -
+kubectl rollout restart deployment/workflow-controller -n argo
+kubectl rollout status deployment/workflow-controller -n argo --timeout=180s
+kubectl apply -n argo -f e2e/testdata/workflow-template.yaml
+
+echo "Starting Vault and Postgres."
+docker rm -f $VAULT_CONTAINER $POSTGRES_CONTAINER >/dev/null 2>&1 || true
+docker run -d --name $VAULT_CONTAINER -p 18200:8200 \
+    -e VAULT_DEV_ROOT_TOKEN_ID=root --cap-add IPC_LOCK vault:1.9.4 >/dev/null
+docker run -d --name $POSTGRES_CONTAINER -p 15432:5432 \
+    -e POSTGRES_DB=argocloudops -e POSTGRES_PASSWORD=postgres postgres:13 >/dev/null
+
+until docker exec $POSTGRES_CONTAINER pg_isready -U postgres >/dev/null 2>&1; do
+    sleep 1
+done
+docker exec -i $POSTGRES_CONTAINER psql -q -U postgres -d argocloudops < scripts/createdbtables.sql
+
+vault_cmd() {
+    docker exec -e VAULT_ADDR=http://127.0.0.1:8200 -e VAULT_TOKEN=root $VAULT_CONTAINER vault "$@"
+}
+until vault_cmd status >/dev/null 2>&1; do
+    sleep 1
+done
+
+vault_cmd secrets enable aws >/dev/null
+vault_cmd auth enable approle >/dev/null
+
+docker exec -i -e VAULT_ADDR=http://127.0.0.1:8200 -e VAULT_TOKEN=root $VAULT_CONTAINER \
+    vault policy write argo-cloudops-service - >/dev/null << EOF
+# Create and manage roles
+path "auth/approle/role/argo-cloudops-projects-*" {
+  capabilities = [ "create", "read", "update", "delete", "list" ]
+}
+
+# Write ACL policies
+path "sys/policies/acl/argo-cloudops-projects-*" {
+  capabilities = [ "create", "read", "update", "delete", "list" ]
+}
+
+# Write AWS roles
+path "aws/roles/argo-cloudops-projects-*" {
+  capabilities = [ "create", "read", "update", "delete", "list" ]
+}
+
+# List roles
+path "aws/roles/*" {
+  capabilities = [ "read", "list" ]
+}
+
+# Wrap and revoke run tokens
+path "sys/wrapping/wrap" {
+  capabilities = [ "update" ]
+}
+path "auth/token/revoke-accessor" {
+  capabilities = [ "update" ]
+}
+
+# Issue and revoke break-glass credentials of targets
+path "aws/sts/argo-cloudops-projects-*" {
+  capabilities = [ "update" ]
+}
+path "sys/leases/revoke" {
+  capabilities = [ "update" ]
+}
+EOF
+
+vault_cmd write auth/approle/role/argo-cloudops policies=argo-cloudops-service secret_id_ttl=1h >/dev/null
+
+export VAULT_ADDR=http://127.0.0.1:18200
+export VAULT_ROLE=$(vault_cmd read -format json auth/approle/role/argo-cloudops/role-id | jq -r '.data.role_id')
+export VAULT_SECRET=$(vault_cmd write -f -format json auth/approle/role/argo-cloudops/secret-id | jq -r '.data.secret_id')
+export ARGO_ADDR=http://127.0.0.1:2746
+export ARGO_CLOUDOPS_ADMIN_SECRET=e2ee2ee2ee2ee2ee2ee2ee2e
+export ARGO_CLOUDOPS_CONFIG=$(pwd)/e2e/testdata/argo-cloudops.yaml
+export ARGO_CLOUDOPS_DB_HOST=localhost:15432
+export ARGO_CLOUDOPS_DB_NAME=argocloudops
+export ARGO_CLOUDOPS_DB_USER=argoco
+export ARGO_CLOUDOPS_DB_PASSWORD=1234
+export ARGO_CLOUDOPS_GIT_AUTH_METHOD=https
+
+echo "Running end to end tests."
+go test -count=1 -timeout=15m -tags e2e ./e2e/... "$@"
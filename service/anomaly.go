@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cello-proj/cello/service/internal/anomaly"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	upper "github.com/upper/db/v4"
+)
+
+// Actions taken on anomalous submissions, from the lowest threshold.
+const (
+	anomalyActionTag     = "tag"
+	anomalyActionAlert   = "alert"
+	anomalyActionApprove = "approve"
+)
+
+var anomalousSubmissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cello",
+	Name:      "anomalous_submissions_total",
+	Help:      "Submissions assessed as anomalous, by the strongest action taken (tag, alert or approve).",
+}, []string{"action"})
+
+func init() {
+	prometheus.MustRegister(anomalousSubmissions)
+}
+
+// dbSubmissionSources records the submission sources of targets in the db.
+type dbSubmissionSources struct {
+	db db.Client
+}
+
+func (s dbSubmissionSources) FirstSource(ctx context.Context, sub anomaly.Submission) (bool, error) {
+	_, err := s.db.ReadTargetSubmissionSourceEntry(ctx, sub.Project, sub.Target, sub.Source)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, upper.ErrNoMoreRows) {
+		return false, err
+	}
+
+	err = s.db.CreateTargetSubmissionSourceEntry(ctx, db.TargetSubmissionSourceEntry{
+		Project:          sub.Project,
+		Target:           sub.Target,
+		Source:           sub.Source,
+		FirstSubmittedAt: sub.Time.UTC(),
+	})
+	return err == nil, err
+}
+
+// Assesses a submission with the anomaly detector, if any, returning the
+// assessment and the strongest action its score reaches, empty when it isn't
+// anomalous. Anomalous submissions are recorded as 'anomaly_detected'
+// execution events. Detection errors are logged, they don't fail submissions.
+func (h handler) assessSubmission(ctx context.Context, l log.Logger, txID string, s anomaly.Submission) (anomaly.Assessment, string) {
+	if h.anomalies == nil {
+		return anomaly.Assessment{}, ""
+	}
+
+	a, err := h.anomalies.Assess(ctx, s)
+	if err != nil {
+		level.Warn(l).Log("message", "error assessing submission for anomalies", "error", err)
+		return anomaly.Assessment{}, ""
+	}
+
+	var action string
+	switch {
+	case h.env.AnomalyApprovalThreshold > 0 && a.Score >= h.env.AnomalyApprovalThreshold:
+		action = anomalyActionApprove
+	case h.env.AnomalyAlertThreshold > 0 && a.Score >= h.env.AnomalyAlertThreshold:
+		action = anomalyActionAlert
+	case a.Score >= h.env.AnomalyTagThreshold && a.Score > 0:
+		action = anomalyActionTag
+	default:
+		return a, ""
+	}
+
+	level.Info(l).Log("message", "anomalous submission", "score", a.Score, "action", action, "reasons", a.Reasons())
+	anomalousSubmissions.WithLabelValues(action).Inc()
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:      txID,
+		Project:   s.Project,
+		Target:    s.Target,
+		Type:      "anomaly_detected",
+		Message:   a.String(),
+		CreatedAt: h.now().UTC(),
+	})
+	return a, action
+}
+
+// Alerts the notification rules of the project of an anomalous submission,
+// whatever their threshold, like break-glass credentials. Sent alerts are
+// recorded as 'notification_sent' execution events.
+func (h handler) alertAnomaly(ctx context.Context, l log.Logger, txID string, s anomaly.Submission, a anomaly.Assessment) {
+	if h.notifications == nil {
+		level.Warn(l).Log("message", "notifications disabled, project owners won't be alerted of the anomalous submission")
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectNotificationRuleEntries(ctx, s.Project)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project notification rules, project owners won't be alerted of the anomalous submission", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		level.Warn(l).Log("message", "project has no notification rules, project owners won't be alerted of the anomalous submission")
+		return
+	}
+
+	rules := make([]notify.Rule, 0, len(entries))
+	for _, nr := range entries {
+		rules = append(rules, notify.Rule{Type: nr.Type, RoutingKey: nr.RoutingKey})
+	}
+
+	alert := notify.Alert{
+		Project: s.Project,
+		Target:  s.Target,
+		Summary: h.messages.Message(h.messages.DefaultLanguage(), messages.AnomalousSubmissionNotification, messages.Params{
+			"project": s.Project,
+			"target":  s.Target,
+			"score":   strconv.FormatFloat(a.Score, 'f', 2, 64),
+			"reasons": a.Reasons(),
+		}),
+		DedupKey: fmt.Sprintf("cello/%s/%s/anomaly/%s", s.Project, s.Target, txID),
+		Details: map[string]string{
+			"txid":      txID,
+			"score":     strconv.FormatFloat(a.Score, 'f', 2, 64),
+			"reasons":   a.Reasons(),
+			"source":    s.Source,
+			"principal": s.Principal,
+		},
+	}
+
+	// The request context is done once the response is written.
+	go h.notifications.Alert(h.argoCtx, alert, rules, func(rule notify.Rule) {
+		h.recordExecutionEvent(h.argoCtx, l, db.ExecutionEvent{
+			TxID:      txID,
+			Project:   s.Project,
+			Target:    s.Target,
+			Type:      "notification_sent",
+			Message:   fmt.Sprintf("%s alerted of anomalous submission", rule.Type),
+			CreatedAt: h.now().UTC(),
+		})
+	})
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+// Actions and kinds of the changes applying a desired state makes.
+const (
+	applyActionCreate = "create"
+	applyActionUpdate = "update"
+	applyActionDelete = "delete"
+
+	applyKindProject  = "project"
+	applyKindSettings = "settings"
+	applyKindTarget   = "target"
+	applyKindSchedule = "schedule"
+)
+
+// applyChange is a planned change and the function applying it, which may
+// add to the change (e.g. the token of a created project).
+type applyChange struct {
+	responses.ApplyChange
+	apply func(ctx context.Context, c *responses.ApplyChange) error
+}
+
+func (c applyChange) String() string {
+	key := c.Project
+	if c.Target != "" {
+		key += "/" + c.Target
+	}
+	if c.Schedule != "" {
+		key += "/" + c.Schedule
+	}
+	return fmt.Sprintf("%s %s '%s'", c.Action, c.Kind, key)
+}
+
+// Applies the desired state of projects, e.g. for managing Cello from a git
+// repository. The changes are planned against the current state up front,
+// validating the whole state like the handlers of each change, then applied
+// in order unless the dry_run query parameter is true. Applying a state again
+// makes no changes, so an apply which failed part way is retried by applying
+// the same state again.
+func (h handler) applyState(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "apply-state")
+
+	ctx := rs.ctx
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			h.errorResponse(w, "invalid request, dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	// Unknown fields are rejected so typos in the state aren't silently
+	// ignored, like project settings.
+	var asr requests.ApplyState
+	dec := json.NewDecoder(bytes.NewReader(reqBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&asr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error deserializing request body, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := asr.Validate(asr.ValidateNotificationTypes(h.notifications.Types())); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "planning changes", "projects", len(asr.Projects))
+	var plan []applyChange
+	for _, p := range asr.Projects {
+		changes, ok := h.planProject(ctx, rs, w, log.With(l, "project", p.Name), cp, p, asr.Prune)
+		if !ok {
+			return
+		}
+		plan = append(plan, changes...)
+	}
+
+	resp := responses.ApplyState{DryRun: dryRun, Changes: []responses.ApplyChange{}}
+	for i := range plan {
+		c := &plan[i]
+		if !dryRun {
+			level.Debug(l).Log("message", "applying change", "change", c)
+			if err := c.apply(ctx, &c.ApplyChange); err != nil {
+				level.Error(l).Log("message", "error applying change", "change", c, "error", err)
+				h.errorResponse(w, fmt.Sprintf("error applying changes, %d of %d applied, error applying %s", i, len(plan), c), http.StatusInternalServerError)
+				return
+			}
+		}
+		resp.Changes = append(resp.Changes, c.ApplyChange)
+	}
+
+	level.Info(l).Log("message", "applied state", "dry-run", dryRun, "changes", len(resp.Changes))
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Plans the changes of a project, its settings and targets. Returns false
+// when an error response was written.
+func (h handler) planProject(ctx context.Context, rs *requestScope, w http.ResponseWriter, l log.Logger, cp credentials.Provider, p requests.ApplyStateProject, prune bool) ([]applyChange, bool) {
+	exists, err := cp.ProjectExists(p.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var changes []applyChange
+	pe := db.ProjectEntry{ProjectID: p.Name, Repository: p.Repository}
+	if !exists {
+		changes = append(changes, applyChange{
+			ApplyChange: responses.ApplyChange{Action: applyActionCreate, Kind: applyKindProject, Project: p.Name},
+			apply: func(ctx context.Context, c *responses.ApplyChange) error {
+				if err := h.dbClient.CreateProjectEntry(ctx, pe); err != nil {
+					return err
+				}
+				role, secret, err := cp.CreateProject(p.Name)
+				if err != nil {
+					return err
+				}
+				c.Token = newArgoCloudOpsToken("vault", role, secret).Token
+				return nil
+			},
+		})
+	} else {
+		current, err := h.dbClient.ReadProjectEntry(ctx, p.Name)
+		if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error reading project data", "error", err)
+			h.errorResponse(w, "error reading project data", http.StatusInternalServerError)
+			return nil, false
+		}
+		if current.Repository != p.Repository {
+			changes = append(changes, applyChange{
+				ApplyChange: responses.ApplyChange{Action: applyActionUpdate, Kind: applyKindProject, Project: p.Name},
+				apply: func(ctx context.Context, c *responses.ApplyChange) error {
+					return h.dbClient.CreateProjectEntry(ctx, pe)
+				},
+			})
+		}
+	}
+
+	if p.Settings != nil {
+		settings := normalizeProjectSettings(types.ProjectSettings(*p.Settings))
+		current := normalizeProjectSettings(types.ProjectSettings{})
+		if exists {
+			if current, err = h.projectSettings(ctx, p.Name); err != nil {
+				level.Error(l).Log("message", "error reading project settings", "error", err)
+				h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+				return nil, false
+			}
+		}
+		if !reflect.DeepEqual(settings, current) {
+			changes = append(changes, applyChange{
+				ApplyChange: responses.ApplyChange{Action: applyActionUpdate, Kind: applyKindSettings, Project: p.Name},
+				apply: func(ctx context.Context, c *responses.ApplyChange) error {
+					data, err := json.Marshal(settings)
+					if err != nil {
+						return err
+					}
+					return h.dbClient.CreateProjectSettingsEntry(ctx, db.ProjectSettingsEntry{
+						Project:   p.Name,
+						Settings:  string(data),
+						UpdatedAt: h.now().UTC(),
+					})
+				},
+			})
+		}
+	}
+
+	var names []string
+	if exists {
+		if names, err = cp.ListTargets(p.Name); err != nil {
+			level.Error(l).Log("message", "error listing targets", "error", err)
+			h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+			return nil, false
+		}
+		sort.Strings(names)
+	}
+	targets := map[string]bool{}
+	for _, name := range names {
+		targets[name] = true
+	}
+
+	declared := map[string]bool{}
+	for _, t := range p.Targets {
+		declared[t.Name] = true
+		tl := log.With(l, "target", t.Name)
+
+		targetChanges, ok := h.planTarget(ctx, w, tl, cp, p.Name, t, targets[t.Name])
+		if !ok {
+			return nil, false
+		}
+		changes = append(changes, targetChanges...)
+
+		scheduleChanges, ok := h.planSchedules(ctx, rs, w, tl, cp, p.Name, t, targets[t.Name], prune)
+		if !ok {
+			return nil, false
+		}
+		changes = append(changes, scheduleChanges...)
+	}
+
+	if prune {
+		for _, name := range names {
+			if declared[name] {
+				continue
+			}
+			name := name
+			changes = append(changes, applyChange{
+				ApplyChange: responses.ApplyChange{Action: applyActionDelete, Kind: applyKindTarget, Project: p.Name, Target: name},
+				apply: func(ctx context.Context, c *responses.ApplyChange) error {
+					return h.deleteTargetAndSettings(ctx, log.With(l, "target", name), cp, p.Name, name)
+				},
+			})
+		}
+	}
+
+	return changes, true
+}
+
+// Plans the creation or update of a target. Its type can't be changed, like
+// updateTarget. Returns false when an error response was written.
+func (h handler) planTarget(ctx context.Context, w http.ResponseWriter, l log.Logger, cp credentials.Provider, projectName string, t requests.ApplyStateTarget, exists bool) ([]applyChange, bool) {
+	target := types.Target(t.CreateTarget)
+	if !exists {
+		if err := h.targetTypeError(target); err != nil {
+			h.errorResponse(w, fmt.Sprintf("invalid request, target '%s/%s' %s", projectName, target.Name, err), http.StatusBadRequest)
+			return nil, false
+		}
+		return []applyChange{{
+			ApplyChange: responses.ApplyChange{Action: applyActionCreate, Kind: applyKindTarget, Project: projectName, Target: target.Name},
+			apply: func(ctx context.Context, c *responses.ApplyChange) error {
+				return cp.CreateTarget(projectName, target)
+			},
+		}}, true
+	}
+
+	current, err := cp.GetTarget(projectName, target.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return nil, false
+	}
+	if current.Type != target.Type {
+		h.errorResponse(w, fmt.Sprintf("invalid request, target '%s/%s' type can't be changed from '%s'", projectName, target.Name, current.Type), http.StatusBadRequest)
+		return nil, false
+	}
+	if sameTarget(current, target) {
+		return nil, true
+	}
+
+	// The workload identity must keep assuming the role of the target.
+	if h.serviceAccounts != nil {
+		wi, err := h.dbClient.ReadTargetWorkloadIdentityEntry(ctx, projectName, target.Name)
+		if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error reading target workload identity", "error", err)
+			h.errorResponse(w, "error reading target workload identity", http.StatusInternalServerError)
+			return nil, false
+		}
+		if err == nil && !h.validServiceAccountRole(ctx, w, l, wi.ServiceAccount, target.Properties.RoleArn) {
+			return nil, false
+		}
+	}
+
+	return []applyChange{{
+		ApplyChange: responses.ApplyChange{Action: applyActionUpdate, Kind: applyKindTarget, Project: projectName, Target: target.Name},
+		apply: func(ctx context.Context, c *responses.ApplyChange) error {
+			return cp.UpdateTarget(projectName, target)
+		},
+	}}, true
+}
+
+// sameTarget reports whether the targets are the same, no policy ARNs being
+// the same as empty ones.
+func sameTarget(a, b types.Target) bool {
+	if len(a.Properties.PolicyArns) == 0 && len(b.Properties.PolicyArns) == 0 {
+		a.Properties.PolicyArns, b.Properties.PolicyArns = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Plans the schedules of a target, unless they aren't declared. Returns false
+// when an error response was written.
+func (h handler) planSchedules(ctx context.Context, rs *requestScope, w http.ResponseWriter, l log.Logger, cp credentials.Provider, projectName string, t requests.ApplyStateTarget, targetExists, prune bool) ([]applyChange, bool) {
+	if t.Schedules == nil {
+		return nil, true
+	}
+
+	current := map[string]db.TargetScheduleEntry{}
+	if targetExists {
+		entries, err := h.dbClient.ListTargetScheduleEntries(ctx, projectName, t.Name)
+		if err != nil {
+			level.Error(l).Log("message", "error reading target schedules", "error", err)
+			h.errorResponse(w, "error reading target schedules", http.StatusInternalServerError)
+			return nil, false
+		}
+		for _, ts := range entries {
+			current[ts.Name] = ts
+		}
+	}
+
+	var changes []applyChange
+	declared := map[string]bool{}
+	for _, s := range t.Schedules {
+		declared[s.Name] = true
+		sl := log.With(l, "schedule", s.Name)
+
+		cwr, from, referenced, ok := h.scheduleWorkflow(ctx, rs, w, sl, projectName, t.Name, s)
+		if !ok {
+			return nil, false
+		}
+		workflowData, err := json.Marshal(cwr)
+		if err != nil {
+			level.Error(sl).Log("message", "error serializing workflow", "error", err)
+			h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+			return nil, false
+		}
+
+		ts := db.TargetScheduleEntry{
+			Project:  projectName,
+			Target:   t.Name,
+			Name:     s.Name,
+			Cron:     s.Cron,
+			Timezone: s.Timezone,
+			Suspend:  s.Suspend,
+			Workflow: string(workflowData),
+		}
+
+		action := applyActionCreate
+		if existing, ok := current[s.Name]; ok {
+			if existing == ts {
+				continue
+			}
+			action = applyActionUpdate
+		}
+		changes = append(changes, applyChange{
+			ApplyChange: responses.ApplyChange{Action: action, Kind: applyKindSchedule, Project: projectName, Target: t.Name, Schedule: s.Name},
+			apply: func(ctx context.Context, c *responses.ApplyChange) error {
+				if err := h.applyScheduleCronWorkflow(ctx, cp, ts, cwr, from, referenced); err != nil {
+					return err
+				}
+				return h.dbClient.CreateTargetScheduleEntry(ctx, ts)
+			},
+		})
+	}
+
+	if prune {
+		names := make([]string, 0, len(current))
+		for name := range current {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if declared[name] {
+				continue
+			}
+			name := name
+			changes = append(changes, applyChange{
+				ApplyChange: responses.ApplyChange{Action: applyActionDelete, Kind: applyKindSchedule, Project: projectName, Target: t.Name, Schedule: name},
+				apply: func(ctx context.Context, c *responses.ApplyChange) error {
+					return h.deleteSchedule(ctx, projectName, t.Name, name)
+				},
+			})
+		}
+	}
+
+	return changes, true
+}
+
+// Validates the workflow of a schedule like putTargetSchedule, returning it
+// with its project, target and framework defaults set, and the template it's
+// created from. Returns false when an error response was written.
+func (h handler) scheduleWorkflow(ctx context.Context, rs *requestScope, w http.ResponseWriter, l log.Logger, projectName, targetName string, s requests.ApplyStateSchedule) (requests.CreateWorkflow, string, bool, bool) {
+	key := fmt.Sprintf("%s/%s/%s", projectName, targetName, s.Name)
+
+	if err := s.ValidateCronWorkflowName(scheduleCronWorkflowName(projectName, targetName, s.Name))(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, schedule '%s' %s", key, err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		level.Error(l).Log("message", "error invalid timezone", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, schedule '%s' unknown timezone '%s'", key, s.Timezone), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	cwr := s.Workflow
+	cwr.ProjectName = projectName
+	cwr.TargetName = targetName
+
+	types, err := rs.config.listTypes(cwr.Framework)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid framework", "error", err)
+		h.errorResponse(
+			w,
+			fmt.Sprintf("invalid request, schedule '%s' framework must be one of '%s'", key, strings.Join(rs.config.listFrameworks(), " ")),
+			http.StatusBadRequest,
+		)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	scheduling, err := h.targetScheduling(ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+	if err := rs.config.applyFrameworkDefaults(&cwr, scheduling.Platform); err != nil {
+		level.Error(l).Log("message", "error selecting framework image", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, schedule '%s' workflow %s", key, err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+	if err := cwr.Validate(cwr.ValidateType(types), rs.config.validateFrameworkRequest(cwr)); err != nil {
+		level.Error(l).Log("message", "error validating workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, schedule '%s' workflow %s", key, err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	level.Debug(l).Log("message", "checking workflow template")
+	from, referenced, ok := h.resolveWorkflowTemplate(ctx, w, l, cwr)
+	if !ok {
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	level.Debug(l).Log("message", "checking encrypted values")
+	if _, _, ok := h.checkEncryptedValues(ctx, w, l, cwr); !ok {
+		return requests.CreateWorkflow{}, "", false, false
+	}
+
+	return cwr, from, referenced, true
+}
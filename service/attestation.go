@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+const (
+	// Maximum time a sync is watched for its attestation.
+	attestationWatchTimeout = 24 * time.Hour
+	// attestationBuilderID identifies the service as the builder of syncs.
+	attestationBuilderID = "cello"
+	// attestationSignatureAlgorithm is the algorithm of the signatures of
+	// attestations, keyed with the attestation key of the service.
+	attestationSignatureAlgorithm = "HMAC-SHA256"
+)
+
+// Watches a sync until it completes to record its attestation, see
+// recordAttestation.
+func (h handler) watchAttestation(l log.Logger, txID, submitter string, cwr requests.CreateWorkflow, workflowName string) {
+	if h.env.AttestationKey == "" || cwr.Type != "sync" {
+		return
+	}
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, attestationWatchTimeout)
+		defer cancel()
+		h.recordAttestation(ctx, l, txID, submitter, workflowName)
+	}()
+}
+
+// Records the signed provenance of a completed sync, whatever its outcome,
+// and an 'attestation_recorded' execution event. Failures are logged as the
+// sync already completed.
+func (h handler) recordAttestation(ctx context.Context, l log.Logger, txID, submitter, workflowName string) {
+	status, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.AttestationWatchInterval, func(err error) {
+		level.Warn(l).Log("message", "error getting workflow status", "error", err)
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error waiting for sync to complete, no attestation recorded", "error", err)
+		return
+	}
+	status.Name = workflowName
+
+	p := provenance(status, txID, submitter)
+	for k, v := range p.Parameters {
+		p.Parameters[k] = h.redactor.String(v)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing provenance", "error", err)
+		return
+	}
+
+	err = h.dbClient.CreateExecutionAttestationEntry(ctx, db.ExecutionAttestationEntry{
+		WorkflowName: workflowName,
+		TxID:         txID,
+		Project:      p.Project,
+		Target:       p.Target,
+		Provenance:   string(data),
+		Signature:    base64.StdEncoding.EncodeToString(h.attestationSignature(data)),
+		CreatedAt:    h.now().UTC(),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing attestation", "error", err)
+		return
+	}
+
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      p.Project,
+		Target:       p.Target,
+		WorkflowName: workflowName,
+		Type:         "attestation_recorded",
+		Message:      fmt.Sprintf("attestation of %s sync recorded", p.Outcome),
+		CreatedAt:    h.now().UTC(),
+	})
+}
+
+func (h handler) attestationSignature(data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(h.env.AttestationKey))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// provenance returns the provenance of a completed workflow. The credentials
+// token isn't part of it, the images are of the '*_image_uri' parameters.
+func provenance(s *workflow.Status, txID, submitter string) responses.Provenance {
+	p := responses.Provenance{
+		WorkflowName: s.Name,
+		TxID:         txID,
+		Project:      s.Labels[workflow.LabelProject],
+		Target:       s.Labels[workflow.LabelTarget],
+		Submitter:    submitter,
+		CommitHash:   s.Labels[workflow.LabelCommitHash],
+		Builder:      responses.ProvenanceBuilder{ID: attestationBuilderID, Images: []responses.ProvenanceImage{}},
+		Parameters:   map[string]string{},
+		StartedOn:    unixToRFC3339(s.Created),
+		FinishedOn:   unixToRFC3339(s.Finished),
+		Outcome:      s.Status,
+	}
+
+	names := make([]string, 0, len(s.Parameters))
+	for k := range s.Parameters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := s.Parameters[k]
+		if k == "credentials_token" {
+			continue
+		}
+		p.Parameters[k] = v
+		if strings.HasSuffix(k, "_image_uri") && v != "" {
+			image := responses.ProvenanceImage{Parameter: k, URI: v}
+			if i := strings.Index(v, "@"); i >= 0 {
+				image.Digest = v[i+1:]
+			}
+			p.Builder.Images = append(p.Builder.Images, image)
+		}
+	}
+	return p
+}
+
+// authorizationName returns who the authorization is of, e.g. 'admin'.
+func authorizationName(a *credentials.Authorization) string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.Provider
+}
+
+// unixToRFC3339 returns the unix time in seconds as RFC 3339, empty when it
+// isn't one.
+func unixToRFC3339(s string) string {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+}
+
+// Gets the signed provenance of a completed sync. Attestations outlive their
+// workflows, so they're authorized against the project they were recorded
+// for.
+func (h handler) getExecutionAttestation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+	l := rs.log("op", "get-execution-attestation", "workflow", workflowName)
+
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	e, err := h.dbClient.ReadExecutionAttestationEntry(rs.ctx, workflowName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "attestation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading attestation", "error", err)
+		h.errorResponse(w, "error reading attestation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+			return
+		}
+
+		authorized, err := cp.ProjectAuthorized(e.Project)
+		if err != nil {
+			level.Error(l).Log("message", "error authorizing project", "error", err)
+			h.errorResponse(w, "error authorizing project", http.StatusInternalServerError)
+			return
+		}
+		if !authorized {
+			level.Error(l).Log("message", "attestation not of project", "project", e.Project)
+			h.errorResponse(w, "attestation not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	jsonData, err := json.Marshal(responses.ExecutionAttestation{
+		Provenance:         json.RawMessage(e.Provenance),
+		Signature:          e.Signature,
+		SignatureAlgorithm: attestationSignatureAlgorithm,
+		CreatedAt:          e.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing attestation", "error", err)
+		h.errorResponse(w, "error serializing attestation", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	p := provenance(&workflow.Status{
+		Name:     "project1-target1-abcde",
+		Status:   "succeeded",
+		Created:  "1647252000",
+		Finished: "1647252060",
+		Labels: map[string]string{
+			workflow.LabelProject:    "project1",
+			workflow.LabelTarget:     "target1",
+			workflow.LabelCommitHash: "abc123",
+		},
+		Parameters: map[string]string{
+			"credentials_token":           "token1",
+			"execute_command":             "cdk deploy",
+			"execute_container_image_uri": "argocloudops/argo-cloudops-cdk@sha256:0123abcd",
+			"pre_container_image_uri":     "argocloudops/pre:1",
+		},
+	}, "txid1", "admin")
+
+	assert.Equal(t, responses.Provenance{
+		WorkflowName: "project1-target1-abcde",
+		TxID:         "txid1",
+		Project:      "project1",
+		Target:       "target1",
+		Submitter:    "admin",
+		CommitHash:   "abc123",
+		Builder: responses.ProvenanceBuilder{
+			ID: "cello",
+			Images: []responses.ProvenanceImage{
+				{Parameter: "execute_container_image_uri", URI: "argocloudops/argo-cloudops-cdk@sha256:0123abcd", Digest: "sha256:0123abcd"},
+				{Parameter: "pre_container_image_uri", URI: "argocloudops/pre:1"},
+			},
+		},
+		Parameters: map[string]string{
+			"execute_command":             "cdk deploy",
+			"execute_container_image_uri": "argocloudops/argo-cloudops-cdk@sha256:0123abcd",
+			"pre_container_image_uri":     "argocloudops/pre:1",
+		},
+		StartedOn:  "2022-03-14T10:00:00Z",
+		FinishedOn: "2022-03-14T10:01:00Z",
+		Outcome:    "succeeded",
+	}, p)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Scopes of the actions callers can perform.
+const (
+	scopeAdmin            = "admin"
+	scopeProjectsRead     = "projects:read"
+	scopeProjectsWrite    = "projects:write"
+	scopeTargetsRead      = "targets:read"
+	scopeTargetsWrite     = "targets:write"
+	scopeWorkflowsRead    = "workflows:read"
+	scopeWorkflowsWrite   = "workflows:write"
+	scopeWorkflowsShare   = "workflows:share"
+	scopePoliciesEvaluate = "policies:evaluate"
+	scopeBreakGlassWrite  = "break-glass:write"
+	scopeWorkflowsDestroy = "workflows:destroy"
+)
+
+// scopes returns the scopes of the principal, what the routes of the service
+// allow it. Admins manage projects and targets but can't create workflows,
+// projects can only destroy targets when their settings allow it.
+func (h handler) scopes(principal string, settings types.ProjectSettings) []string {
+	var scopes []string
+	if principal == requests.PrincipalAdmin {
+		scopes = []string{scopeAdmin, scopeProjectsRead, scopeProjectsWrite, scopeTargetsRead, scopeTargetsWrite, scopeWorkflowsRead, scopePoliciesEvaluate}
+		if h.env.BreakGlassMaxTTL > 0 {
+			scopes = append(scopes, scopeBreakGlassWrite)
+		}
+	} else {
+		scopes = []string{scopeWorkflowsRead, scopeWorkflowsWrite, scopePoliciesEvaluate}
+		if settings.Destroy.Allowed {
+			scopes = append(scopes, scopeWorkflowsDestroy)
+		}
+	}
+	if h.env.ShareLinkKey != "" {
+		scopes = append(scopes, scopeWorkflowsShare)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// Returns the resolved identity of the caller, its roles and scopes, what's
+// left of its credentials, the projects and targets it can act on and its
+// support contact.
+func (h handler) getAuthSelf(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-auth-self")
+
+	level.Debug(l).Log("message", "validating authorization header for auth self")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	admin := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)) == nil
+
+	level.Debug(l).Log("message", "creating admin credentials provider")
+	acp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	var resp responses.AuthSelf
+	var projectNames []string
+	if admin {
+		resp.Roles = []string{requests.PrincipalAdmin}
+		resp.Scopes = h.scopes(requests.PrincipalAdmin, types.ProjectSettings{})
+
+		entries, err := h.dbClient.ListProjectEntries(rs.ctx)
+		if err != nil {
+			level.Error(l).Log("message", "error listing projects", "error", err)
+			h.errorResponse(w, "error listing projects", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range entries {
+			projectNames = append(projectNames, e.ProjectID)
+		}
+	} else {
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+			return
+		}
+
+		// Admin credentials with another secret are invalid too.
+		identity, err := cp.Identity()
+		if errors.Is(err, credentials.ErrInvalidCredentials) || (err == nil && identity.Admin) {
+			h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error reading identity", "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error reading identity", http.StatusInternalServerError)
+			return
+		}
+		l = log.With(l, "project", identity.Project)
+
+		resp.Project = identity.Project
+		resp.Roles = []string{requests.PrincipalProject}
+		settings, err := h.projectSettings(rs.ctx, identity.Project)
+		if err != nil {
+			level.Error(l).Log("message", "error reading project settings", "error", err)
+			h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+			return
+		}
+		resp.Scopes = h.scopes(requests.PrincipalProject, settings)
+		if !identity.ExpiresAt.IsZero() {
+			resp.Quota.CredentialsExpireAt = identity.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		resp.Quota.CredentialUsesRemaining = identity.UsesRemaining
+		projectNames = []string{identity.Project}
+	}
+
+	resp.Projects = []responses.AuthProject{}
+	for _, name := range projectNames {
+		targets, err := acp.ListTargets(name)
+		if err != nil {
+			level.Error(l).Log("message", "error listing targets", "project", name, "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error listing targets", http.StatusInternalServerError)
+			return
+		}
+		if targets == nil {
+			targets = []string{}
+		}
+		resp.Projects = append(resp.Projects, responses.AuthProject{Name: name, Targets: targets})
+	}
+	// Admins get the default support contact.
+	resp.Support = h.supportContact(rs.ctx, resp.Project)
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
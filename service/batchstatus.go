@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// batchStatusConcurrency is how many workflow statuses of a
+// BatchWorkflowStatus request are retrieved from Argo at once.
+const batchStatusConcurrency = 10
+
+// Gets the status of many workflows in one request, so dashboards don't poll
+// them one by one. Workflows which don't exist, or aren't of a project of the
+// caller, are reported as not found in the results rather than failing the
+// request.
+func (h handler) batchWorkflowStatus(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "batch-workflow-status")
+
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var bsr requests.BatchWorkflowStatus
+	if err := json.Unmarshal(reqBody, &bsr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+	if err := bsr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Names requested more than once are only retrieved once.
+	type result struct {
+		status *workflow.Status
+		err    error
+	}
+	results := map[string]*result{}
+	for _, name := range bsr.WorkflowNames {
+		results[name] = &result{}
+	}
+
+	level.Debug(l).Log("message", "getting workflow statuses", "workflows", len(results))
+	sem := make(chan struct{}, batchStatusConcurrency)
+	var wg sync.WaitGroup
+	for name, res := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string, res *result) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res.status, res.err = h.argo.Status(rs.ctx, name)
+		}(name, res)
+	}
+	wg.Wait()
+
+	admin := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)) == nil
+	authorizedProjects := map[string]bool{}
+	if !admin {
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+			return
+		}
+
+		for _, res := range results {
+			if res.err != nil {
+				continue
+			}
+			h.resolveWorkflowProject(rs.ctx, l, res.status)
+			projectName := res.status.Labels[workflow.LabelProject]
+			if _, ok := authorizedProjects[projectName]; ok || projectName == "" {
+				continue
+			}
+			authorized, err := cp.ProjectAuthorized(projectName)
+			if err != nil {
+				level.Error(l).Log("message", "error authorizing project", "project", projectName, "error", err)
+				h.errorResponse(w, "error authorizing project", http.StatusInternalServerError)
+				return
+			}
+			authorizedProjects[projectName] = authorized
+		}
+	}
+
+	resp := responses.BatchWorkflowStatus{Workflows: make([]responses.BatchWorkflowStatusResult, 0, len(bsr.WorkflowNames))}
+	for _, name := range bsr.WorkflowNames {
+		res := results[name]
+		item := responses.BatchWorkflowStatusResult{Name: name}
+		switch {
+		case status.Code(res.err) == codes.NotFound:
+			item.Error = "workflow not found"
+		case res.err != nil:
+			level.Error(l).Log("message", "error getting workflow", "workflow", name, "error", res.err)
+			item.Error = "error getting workflow"
+		case !admin && !authorizedProjects[res.status.Labels[workflow.LabelProject]]:
+			item.Error = "workflow not found"
+		default:
+			item.Status = res.status.Status
+			item.Created = res.status.Created
+			item.Finished = res.status.Finished
+		}
+		resp.Workflows = append(resp.Workflows, item)
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow statuses", "error", err)
+		h.errorResponse(w, "error serializing workflow statuses", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Issues break-glass credentials of a target to an admin for manual
+// remediation. The justification is recorded with the request and sent to
+// the project owners, the notification rules of the project. The lease of
+// the credentials is revoked once they expire, see revokeBreakGlass.
+func (h handler) createBreakGlass(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+	txID := r.Header.Get(txIDHeader)
+
+	l := rs.log("op", "create-break-glass", "project", projectName, "target", targetName, "txid", txID)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var bgr requests.BreakGlass
+	if err := json.Unmarshal(reqBody, &bgr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+	if err := bgr.Validate(bgr.ValidateMaxTTL(h.env.BreakGlassMaxTTL)); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ttl := requests.MinBreakGlassTTL
+	if bgr.TTL != "" {
+		ttl, _ = time.ParseDuration(bgr.TTL)
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": targetName}, http.StatusNotFound)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting target credentials")
+	creds, err := cp.GetTargetCredentials(projectName, targetName, ttl)
+	if err != nil {
+		level.Error(l).Log("message", "error getting target credentials", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error getting target credentials", http.StatusInternalServerError)
+		return
+	}
+	if creds.LeaseTTL > 0 {
+		ttl = creds.LeaseTTL
+	}
+
+	now := h.now().UTC()
+	entry := db.BreakGlassEntry{
+		ID:            uuid.NewString(),
+		TxID:          txID,
+		Project:       projectName,
+		Target:        targetName,
+		Justification: bgr.Justification,
+		RemoteAddress: r.RemoteAddr,
+		LeaseID:       creds.LeaseID,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(ttl),
+	}
+	// Credentials aren't handed out without their audit record.
+	if err := h.dbClient.CreateBreakGlassEntry(rs.ctx, entry); err != nil {
+		level.Error(l).Log("message", "error recording break-glass credentials", "error", err)
+		if err := cp.RevokeLease(creds.LeaseID); err != nil {
+			level.Error(l).Log("message", "error revoking unrecorded break-glass credentials", "error", err)
+		}
+		h.errorResponse(w, "error recording break-glass credentials", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := entry.ExpiresAt.Format(time.RFC3339)
+	level.Warn(l).Log("message", "break-glass credentials issued", "id", entry.ID, "justification", bgr.Justification, "remote-address", r.RemoteAddr, "expires", expiresAt)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      txID,
+		Project:   projectName,
+		Target:    targetName,
+		Type:      "break_glass_issued",
+		Message:   fmt.Sprintf("break-glass credentials issued until %s: %s", expiresAt, bgr.Justification),
+		CreatedAt: now,
+	})
+	h.alertBreakGlass(rs.ctx, l, entry)
+
+	jsonData, err := json.Marshal(responses.BreakGlass{
+		ID:              entry.ID,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ExpiresAt:       expiresAt,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing break-glass credentials", "error", err)
+		h.errorResponse(w, "error serializing break-glass credentials", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Gets the audit records of the break-glass credentials of a target.
+func (h handler) getBreakGlass(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "get-break-glass", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListBreakGlassEntries(rs.ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading break-glass records", "error", err)
+		h.errorResponse(w, "error reading break-glass records", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]responses.BreakGlassRecord, 0, len(entries))
+	for _, e := range entries {
+		record := responses.BreakGlassRecord{
+			ID:            e.ID,
+			Justification: e.Justification,
+			RemoteAddress: e.RemoteAddress,
+			CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt:     e.ExpiresAt.UTC().Format(time.RFC3339),
+		}
+		if e.RevokedAt != nil {
+			record.RevokedAt = e.RevokedAt.UTC().Format(time.RFC3339)
+		}
+		records = append(records, record)
+	}
+
+	jsonData, err := json.Marshal(records)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing break-glass records", "error", err)
+		h.errorResponse(w, "error serializing break-glass records", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Alerts the notification rules of the project of break-glass credentials
+// issued for its target, whatever their threshold. Sent alerts are recorded
+// as 'notification_sent' execution events. Errors are logged as the
+// credentials have already been issued.
+func (h handler) alertBreakGlass(ctx context.Context, l log.Logger, e db.BreakGlassEntry) {
+	if h.notifications == nil {
+		level.Warn(l).Log("message", "notifications disabled, project owners won't be alerted of break-glass credentials")
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectNotificationRuleEntries(ctx, e.Project)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project notification rules, project owners won't be alerted of break-glass credentials", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		level.Warn(l).Log("message", "project has no notification rules, project owners won't be alerted of break-glass credentials")
+		return
+	}
+
+	rules := make([]notify.Rule, 0, len(entries))
+	for _, nr := range entries {
+		rules = append(rules, notify.Rule{Type: nr.Type, RoutingKey: nr.RoutingKey})
+	}
+
+	alert := notify.Alert{
+		Project: e.Project,
+		Target:  e.Target,
+		Summary: h.messages.Message(h.messages.DefaultLanguage(), messages.BreakGlassCredentialsNotification, messages.Params{
+			"project": e.Project,
+			"target":  e.Target,
+			"expires": e.ExpiresAt.Format(time.RFC3339),
+		}),
+		DedupKey: fmt.Sprintf("cello/%s/%s/break-glass/%s", e.Project, e.Target, e.ID),
+		Details: map[string]string{
+			"id":             e.ID,
+			"justification":  e.Justification,
+			"remote_address": e.RemoteAddress,
+			"expires_at":     e.ExpiresAt.Format(time.RFC3339),
+		},
+	}
+
+	// The request context is done once the response is written.
+	go h.notifications.Alert(h.argoCtx, alert, rules, func(rule notify.Rule) {
+		h.recordExecutionEvent(h.argoCtx, l, db.ExecutionEvent{
+			TxID:      e.TxID,
+			Project:   e.Project,
+			Target:    e.Target,
+			Type:      "notification_sent",
+			Message:   fmt.Sprintf("%s alerted of break-glass credentials", rule.Type),
+			CreatedAt: h.now().UTC(),
+		})
+	})
+}
+
+// Revokes the leases of expired break-glass credentials every interval until
+// ctx is done.
+func (h handler) watchBreakGlass(ctx context.Context, interval time.Duration) {
+	t := h.clock.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if err := h.revokeBreakGlass(ctx); err != nil {
+			level.Error(h.logger).Log("message", "error revoking break-glass credentials", "error", err)
+		}
+	}
+}
+
+// Revokes the leases of expired break-glass credentials, recording the
+// revocation in their audit record and as 'break_glass_revoked' execution
+// events. Errors of a lease are logged and don't stop the revocation of the
+// others, which is retried on the next call.
+func (h handler) revokeBreakGlass(ctx context.Context) error {
+	entries, err := h.dbClient.ListUnrevokedBreakGlassEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing break-glass credentials: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cp, err := h.adminCredentialsProvider(http.Header{})
+	if err != nil {
+		return fmt.Errorf("error creating credentials provider: %w", err)
+	}
+
+	now := h.now().UTC()
+	for _, e := range entries {
+		if now.Before(e.ExpiresAt) {
+			continue
+		}
+		l := log.With(h.logger, "op", "revoke-break-glass", "project", e.Project, "target", e.Target, "id", e.ID, "txid", e.TxID)
+
+		if err := cp.RevokeLease(e.LeaseID); err != nil {
+			level.Error(l).Log("message", "error revoking break-glass credentials", "error", err)
+			continue
+		}
+		if err := h.dbClient.RevokeBreakGlassEntry(ctx, e.ID, now); err != nil {
+			level.Error(l).Log("message", "error recording break-glass revocation", "error", err)
+			continue
+		}
+		level.Info(l).Log("message", "break-glass credentials revoked")
+
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:      e.TxID,
+			Project:   e.Project,
+			Target:    e.Target,
+			Type:      "break_glass_revoked",
+			Message:   "break-glass credentials revoked",
+			CreatedAt: now,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/go-kit/log/level"
+)
+
+// Returns what the deployment supports: its authorization modes, frameworks,
+// target types, optional features, backends and limits. It doesn't require
+// authorization, so clients can discover them before authenticating.
+func (h handler) getCapabilities(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-capabilities")
+
+	// Targets other than aws_account require Vault.
+	credentialsProvider := env.CredentialsProviderVault
+	if h.env.CredentialsProvider == env.CredentialsProviderAWSSecretsManager {
+		credentialsProvider = env.CredentialsProviderAWSSecretsManager
+	}
+
+	resp := responses.Capabilities{
+		AuthModes:   []string{"vault"},
+		Frameworks:  map[string][]string{},
+		TargetTypes: []string{types.TargetTypeAWSAccount},
+		Features: map[string]bool{
+			"anomaly_detection":  h.anomalies != nil,
+			"attestations":       h.env.AttestationKey != "",
+			"audit_forwarding":   h.audit != nil,
+			"break_glass":        h.env.BreakGlassMaxTTL > 0,
+			"elevations":         h.env.ElevationMaxTTL > 0,
+			"itsm":               h.itsm != nil,
+			"log_archive":        h.logArchive != nil,
+			"run_token_wrapping": h.env.VaultWrapRunTokens,
+			"usage":              h.usage != nil,
+			"workload_identity":  h.serviceAccounts != nil,
+		},
+		Backends: responses.CapabilityBackends{
+			Guardrails:    append([]string{}, h.guardrails.Providers()...),
+			Notifications: append([]string{}, h.notifications.Types()...),
+			Credentials:   credentialsProvider,
+		},
+		Limits: responses.CapabilityLimits{
+			MaxRequestBodyBytes:    h.env.MaxRequestBodyBytes,
+			ManifestBundleMaxBytes: h.env.ManifestBundleMaxBytes,
+			LogChunkMaxBytes:       h.env.LogChunkMaxBytes,
+			MaxWorkflowListLimit:   maxWorkflowListLimit,
+			BreakGlassMaxTTL:       h.env.BreakGlassMaxTTL.String(),
+			ElevationMaxTTL:        h.env.ElevationMaxTTL.String(),
+		},
+	}
+	if h.env.AnonymousReadOnly {
+		resp.AuthModes = append(resp.AuthModes, "anonymous")
+	}
+	if h.env.ShareLinkKey != "" {
+		resp.AuthModes = append(resp.AuthModes, "share_link")
+	}
+	if credentialsProvider == env.CredentialsProviderVault && h.env.VaultGCPEnabled {
+		resp.TargetTypes = append(resp.TargetTypes, types.TargetTypeGCPProject)
+	}
+	if credentialsProvider == env.CredentialsProviderVault && h.env.VaultAzureEnabled {
+		resp.TargetTypes = append(resp.TargetTypes, types.TargetTypeAzureSubscription)
+	}
+	for _, framework := range rs.config.listFrameworks() {
+		resp.Frameworks[framework], _ = rs.config.listTypes(framework)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+)
+
+// manifestParameters are the workflow parameters rendered from the manifest
+// (or request) of the workflow, its framework command and environment
+// variables. Other parameters are compared as parameters.
+var manifestParameters = map[string]bool{
+	"execute_command":              true,
+	"environment_variables_string": true,
+}
+
+// uncomparedParameters are the same for workflows of a target, or secret.
+var uncomparedParameters = map[string]bool{
+	"credentials_token": true,
+	"project_name":      true,
+	"target_name":       true,
+}
+
+// Compares two workflows of the same target, returning the differences in
+// commit, manifest, parameters, duration and outcome.
+func (h handler) compareExecutions(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	nameA, nameB := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	l := rs.log("op", "compare-executions", "a", nameA, "b", nameB)
+
+	if nameA == "" || nameB == "" {
+		h.errorResponse(w, "invalid request, a and b are required", http.StatusBadRequest)
+		return
+	}
+
+	a, ok := h.authorizedWorkflow(w, r, l, nameA)
+	if !ok {
+		return
+	}
+	b, ok := h.authorizedWorkflow(w, r, l, nameB)
+	if !ok {
+		return
+	}
+
+	projectName, targetName := a.Labels[workflow.LabelProject], a.Labels[workflow.LabelTarget]
+	if projectName == "" || targetName == "" || projectName != b.Labels[workflow.LabelProject] || targetName != b.Labels[workflow.LabelTarget] {
+		h.errorResponse(w, "invalid request, workflows must be of the same target", http.StatusBadRequest)
+		return
+	}
+
+	a.Name, b.Name = nameA, nameB
+	resp := compareWorkflows(a, b)
+	resp.Project, resp.Target = projectName, targetName
+	level.Debug(l).Log("message", "compared workflows", "differences", len(resp.Differences))
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing comparison", "error", err)
+		h.errorResponse(w, "error serializing comparison", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// compareWorkflows returns the summaries of the workflows and the fields
+// whose values differ, in the order commit, manifest, parameters (sorted by
+// name), duration and outcome.
+func compareWorkflows(a, b *workflow.Status) responses.CompareExecutions {
+	sa, sb := executionSummary(a), executionSummary(b)
+	resp := responses.CompareExecutions{A: sa, B: sb, Differences: []responses.ExecutionDifference{}}
+
+	diff := func(field, va, vb string) {
+		if va != vb {
+			resp.Differences = append(resp.Differences, responses.ExecutionDifference{Field: field, A: va, B: vb})
+		}
+	}
+
+	diff("commit", sa.CommitHash, sb.CommitHash)
+
+	names := map[string]bool{}
+	for k := range a.Parameters {
+		names[k] = true
+	}
+	for k := range b.Parameters {
+		names[k] = true
+	}
+	var manifest, parameters []string
+	for k := range names {
+		switch {
+		case uncomparedParameters[k]:
+		case manifestParameters[k]:
+			manifest = append(manifest, k)
+		default:
+			parameters = append(parameters, k)
+		}
+	}
+	sort.Strings(manifest)
+	sort.Strings(parameters)
+	for _, k := range manifest {
+		diff("manifest."+k, a.Parameters[k], b.Parameters[k])
+	}
+	for _, k := range parameters {
+		diff("parameters."+k, a.Parameters[k], b.Parameters[k])
+	}
+
+	duration := func(s responses.ExecutionSummary) string {
+		if s.DurationSeconds == 0 {
+			return ""
+		}
+		return (time.Duration(s.DurationSeconds) * time.Second).String()
+	}
+	diff("duration", duration(sa), duration(sb))
+	diff("outcome", sa.Status, sb.Status)
+
+	return resp
+}
+
+// executionSummary returns the summary of the workflow. Workflows which
+// haven't finished have no duration.
+func executionSummary(s *workflow.Status) responses.ExecutionSummary {
+	summary := responses.ExecutionSummary{
+		Name:       s.Name,
+		Status:     s.Status,
+		CommitHash: s.Labels[workflow.LabelCommitHash],
+		Created:    s.Created,
+		Finished:   s.Finished,
+	}
+
+	created, errCreated := strconv.ParseInt(s.Created, 10, 64)
+	finished, errFinished := strconv.ParseInt(s.Finished, 10, 64)
+	if errCreated == nil && errFinished == nil && finished > created && !workflow.IsActive(s.Status) {
+		summary.DurationSeconds = finished - created
+	}
+	return summary
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareWorkflows(t *testing.T) {
+	good := &workflow.Status{
+		Name:     "project1-target1-good",
+		Status:   "succeeded",
+		Created:  "1000",
+		Finished: "1060",
+		Labels:   map[string]string{workflow.LabelCommitHash: "abc123"},
+		Parameters: map[string]string{
+			"credentials_token":           "token1",
+			"execute_command":             "cdk deploy app1",
+			"execute_container_image_uri": "argocloudops/argo-cloudops-cdk:1.87.1",
+			"project_name":                "project1",
+		},
+	}
+	broken := &workflow.Status{
+		Name:     "project1-target1-broken",
+		Status:   "failed",
+		Created:  "2000",
+		Finished: "2150",
+		Labels:   map[string]string{workflow.LabelCommitHash: "def456"},
+		Parameters: map[string]string{
+			"credentials_token":           "token2",
+			"execute_command":             "cdk deploy app2",
+			"execute_container_image_uri": "argocloudops/argo-cloudops-cdk:1.87.1",
+			"pre_container_image_uri":     "argocloudops/pre:1",
+			"project_name":                "project1",
+		},
+	}
+
+	got := compareWorkflows(good, broken)
+	assert.Equal(t, int64(60), got.A.DurationSeconds)
+	assert.Equal(t, "def456", got.B.CommitHash)
+	assert.Equal(t, []responses.ExecutionDifference{
+		{Field: "commit", A: "abc123", B: "def456"},
+		{Field: "manifest.execute_command", A: "cdk deploy app1", B: "cdk deploy app2"},
+		{Field: "parameters.pre_container_image_uri", A: "", B: "argocloudops/pre:1"},
+		{Field: "duration", A: "1m0s", B: "2m30s"},
+		{Field: "outcome", A: "succeeded", B: "failed"},
+	}, got.Differences)
+
+	assert.Empty(t, compareWorkflows(good, good).Differences)
+}
+
+func TestExecutionSummaryActive(t *testing.T) {
+	s := executionSummary(&workflow.Status{Status: "running", Created: "1000", Finished: "-62135596800"})
+	assert.Equal(t, int64(0), s.DurationSeconds)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log/level"
+)
+
+// Buckets of the concurrency data, in minutes.
+const (
+	defaultConcurrencyBucketMinutes = 60
+	minConcurrencyBucketMinutes     = 5
+	maxConcurrencyBucketMinutes     = 24 * 60
+)
+
+// Gets the workflows running during each bucket of the window, in total and by
+// target, for sizing the Argo cluster. Workflows run from their submission
+// until their credentials are revoked on completion; workflows whose
+// completion wasn't recorded count as running for the max TTL of their
+// credentials.
+func (h handler) getConcurrency(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-concurrency")
+
+	level.Debug(l).Log("message", "validating authorization header for concurrency")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	windowHours, ok := h.statsWindowHours(w, r)
+	if !ok {
+		return
+	}
+
+	bucketMinutes := defaultConcurrencyBucketMinutes
+	if v := r.URL.Query().Get("bucket_minutes"); v != "" {
+		bucketMinutes, err = strconv.Atoi(v)
+		if err != nil || bucketMinutes < minConcurrencyBucketMinutes || bucketMinutes > maxConcurrencyBucketMinutes {
+			h.errorResponse(w, fmt.Sprintf("invalid request, bucket_minutes must be between %d and %d", minConcurrencyBucketMinutes, maxConcurrencyBucketMinutes), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Buckets are counted back from the current bucket, oldest first, and
+	// cover at least the window.
+	bucket := time.Duration(bucketMinutes) * time.Minute
+	buckets := (windowHours*60 + bucketMinutes - 1) / bucketMinutes
+	start := h.now().UTC().Truncate(bucket).Add(-time.Duration(buckets-1) * bucket)
+
+	entries, err := h.dbClient.ListConcurrencyBuckets(rs.ctx, start, buckets, bucket, credentials.TokenMaxTTL)
+	if err != nil {
+		level.Error(l).Log("message", "error computing concurrency", "error", err)
+		h.errorResponse(w, "error computing concurrency", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetConcurrency{
+		WindowHours:   windowHours,
+		BucketMinutes: bucketMinutes,
+		Buckets:       make([]responses.ConcurrencyBucket, buckets),
+	}
+	for i := range resp.Buckets {
+		resp.Buckets[i] = responses.ConcurrencyBucket{
+			Start:   start.Add(time.Duration(i) * bucket).Format(time.RFC3339),
+			Targets: []responses.TargetConcurrency{},
+		}
+	}
+	for _, e := range entries {
+		i := int(e.Start.Sub(start) / bucket)
+		if i < 0 || i >= buckets {
+			continue
+		}
+		resp.Buckets[i].Running += e.Running
+		resp.Buckets[i].Targets = append(resp.Buckets[i].Targets, responses.TargetConcurrency{
+			Project: e.Project,
+			Target:  e.Target,
+			Running: e.Running,
+		})
+	}
+	for _, b := range resp.Buckets {
+		if b.Running > resp.MaxRunning {
+			resp.MaxRunning = b.Running
+		}
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing concurrency", "error", err)
+		h.errorResponse(w, "error serializing concurrency", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
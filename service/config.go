@@ -2,11 +2,23 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/feature"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/secretscan"
+	"github.com/cello-proj/cello/service/internal/workflow"
 
 	"gopkg.in/yaml.v2"
 )
@@ -16,14 +28,103 @@ type CommandVariables struct {
 	EnvironmentVariables string
 	InitArguments        string
 	ExecuteArguments     string
+	// HelmArguments are the release name, chart and flags of the Helm
+	// release, empty for other frameworks.
+	HelmArguments string
+	// CloudFormationArguments are the stack, change set and template flags
+	// of the CloudFormation stack, empty for other frameworks.
+	CloudFormationArguments string
+	// AnsibleInventory is the base64 encoded inventory of the target and
+	// AnsiblePlaybook the path of the playbook, empty for other frameworks.
+	AnsibleInventory string
+	AnsiblePlaybook  string
+	// KubectlArguments are the manifests, namespace and selector of kubectl
+	// diff and apply, KubectlPruneArguments the prune flags of kubectl apply.
+	// Both are empty for other frameworks.
+	KubectlArguments      string
+	KubectlPruneArguments string
+	// Parameters are the framework parameters of frameworks registered by
+	// framework definitions, nil for other frameworks.
+	Parameters map[string]string
+}
+
+// frameworkParameters are the typed parameters of the framework of a
+// workflow, set for their framework only.
+type frameworkParameters struct {
+	helm           *requests.HelmRelease
+	cloudFormation *requests.CloudFormationStack
+	ansible        *requests.AnsiblePlaybook
+	// inventory is the rendered inventory of the target for ansible.
+	inventory []byte
+	kubectl   *requests.KubernetesManifests
+	// pruneKinds are the kinds kubectl apply prunes.
+	pruneKinds []string
+	// parameters are the framework parameters of frameworks registered by
+	// framework definitions.
+	parameters map[string]string
 }
 
 // Config represents the configuration.
 type Config struct {
 	Version  string
 	Commands map[string]map[string]string `yaml:"commands"`
+	// WorkflowTemplates are the WorkflowTemplates all projects can create
+	// workflows from. Any WorkflowTemplate is allowed when empty.
+	WorkflowTemplates []string `yaml:"workflow_templates"`
+	// FeatureFlags enables or disables feature flags for all projects,
+	// projects can override them.
+	FeatureFlags map[string]bool `yaml:"feature_flags"`
+	// KubectlPruneKinds are the kinds, as group/version/kind (core for the
+	// core group), syncs of the kubectl framework prune. Defaults to
+	// defaultKubectlPruneKinds.
+	KubectlPruneKinds []string `yaml:"kubectl_prune_kinds"`
+	// Frameworks are frameworks registered declaratively, their commands are
+	// added to Commands when loaded.
+	Frameworks map[string]FrameworkDefinition `yaml:"frameworks"`
+	// FrameworkImages are the image variants of frameworks per platform
+	// (os/arch), the default execute container images of their workflows.
+	FrameworkImages map[string]map[string]string `yaml:"framework_images"`
+	// WorkflowNameTemplate is the template of the names of workflows, which
+	// must use the project and target. Defaults to
+	// workflow.DefaultNameTemplate.
+	WorkflowNameTemplate string `yaml:"workflow_name_template"`
+	// SecretScanRules are regular expressions of secrets by name, scanned for
+	// in workflow requests in addition to secretscan.DefaultRules.
+	SecretScanRules map[string]string `yaml:"secret_scan_rules"`
+	// RouteTimeouts are the timeouts of requests by route class (submission,
+	// stream, read or write), see routeClass. Requests of classes without one
+	// don't time out.
+	RouteTimeouts map[string]time.Duration `yaml:"route_timeouts"`
+	// SlowRequestThreshold is the duration above which requests, except
+	// streams, are logged as slow with the durations of their stages.
+	// Disabled when zero.
+	SlowRequestThreshold time.Duration `yaml:"slow_request_threshold"`
+	// Messages override the user facing messages by language and message
+	// ID, or add languages, see messages.New.
+	Messages map[string]map[string]string `yaml:"messages"`
+	// DefaultLanguage is the language of messages of requests not accepting
+	// any language of the messages, and of notifications. Defaults to
+	// messages.DefaultLanguage.
+	DefaultLanguage string `yaml:"default_language"`
+	// SupportContacts are where users go for help with failures of the
+	// platform by tenant (see Put Project Settings), added to 5xx error
+	// responses. The contact of the '*' tenant is the one of tenants without
+	// their own.
+	SupportContacts map[string]SupportContact `yaml:"support_contacts"`
 }
 
+// defaultKubectlPruneKinds are the kinds pruned by syncs of the kubectl
+// framework unless configured.
+var defaultKubectlPruneKinds = []string{
+	"core/v1/ConfigMap",
+	"core/v1/Service",
+	"apps/v1/Deployment",
+	"apps/v1/StatefulSet",
+	"batch/v1/CronJob",
+}
+
+var pruneKindRegex = regexp.MustCompile(`^[a-z0-9.-]+/v[0-9a-z]+/[A-Z][A-Za-z0-9]*$`)
+
 func loadConfig(configFilePath string) (*Config, error) {
 	f, err := ioutil.ReadFile(configFilePath)
 	if err != nil {
@@ -36,9 +137,68 @@ func loadConfig(configFilePath string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.registerFrameworks(); err != nil {
+		return nil, err
+	}
+	if err := config.validateFrameworkImages(); err != nil {
+		return nil, err
+	}
+	if err := config.validateRouteTimeouts(); err != nil {
+		return nil, err
+	}
+	if err := config.validateSupportContacts(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// validateConfig runs the validate-config command, printing the problems of
+// the service config file (the argument or ARGO_CLOUDOPS_SERVICE_CONFIG), the
+// environment and the config file it points at. It returns the exit code.
+func validateConfig(args []string, out io.Writer) int {
+	path := os.Getenv(env.ServiceConfigVar)
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	vars, errs := env.Check(path)
+	if len(errs) == 0 {
+		config, err := loadConfig(vars.ConfigFilePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config: unable to load '%s', %w", vars.ConfigFilePath, err))
+		} else {
+			if _, err := feature.New(config.FeatureFlags); err != nil {
+				errs = append(errs, fmt.Errorf("config: %w", err))
+			}
+			if _, err := workflow.NewNameTemplate(config.WorkflowNameTemplate); err != nil {
+				errs = append(errs, fmt.Errorf("config: %w", err))
+			}
+			if _, err := secretscan.ParseRules(config.SecretScanRules); err != nil {
+				errs = append(errs, fmt.Errorf("config: %w", err))
+			}
+			if _, err := messages.New(config.DefaultLanguage, config.Messages); err != nil {
+				errs = append(errs, fmt.Errorf("config: %w", err))
+			}
+			for _, k := range config.KubectlPruneKinds {
+				if !pruneKindRegex.MatchString(k) {
+					errs = append(errs, fmt.Errorf("config: kubectl_prune_kinds: '%s' must be group/version/kind", k))
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(out, "error: %s\n", err)
+		}
+		return 1
+	}
+
+	fmt.Fprintln(out, "config is valid")
+	return 0
+}
+
 func (c Config) getCommandDefinition(framework, commandType string) (string, error) {
 	if _, ok := c.Commands[framework]; !ok {
 		return "", fmt.Errorf("unknown framework '%s'", framework)
@@ -51,6 +211,15 @@ func (c Config) getCommandDefinition(framework, commandType string) (string, err
 	return c.Commands[framework][commandType], nil
 }
 
+// kubectlPruneKinds returns the kinds pruned by syncs of the kubectl
+// framework.
+func (c Config) kubectlPruneKinds() []string {
+	if len(c.KubectlPruneKinds) == 0 {
+		return defaultKubectlPruneKinds
+	}
+	return c.KubectlPruneKinds
+}
+
 func (c Config) listFrameworks() []string {
 	keys := []string{}
 	for k := range c.Commands {
@@ -75,7 +244,22 @@ func (c Config) listTypes(framework string) ([]string, error) {
 	return keys, nil
 }
 
-func generateExecuteCommand(commandDefinition, environmentVariablesString string, arguments map[string][]string) (string, error) {
+// allowsWorkflowTemplate reports whether all projects can create workflows from
+// the WorkflowTemplate.
+func (c Config) allowsWorkflowTemplate(name string) bool {
+	if len(c.WorkflowTemplates) == 0 {
+		return true
+	}
+
+	for _, t := range c.WorkflowTemplates {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+func generateExecuteCommand(commandDefinition, environmentVariablesString string, arguments map[string][]string, fp frameworkParameters) (string, error) {
 	initArguments := ""
 	if _, ok := arguments["init"]; ok {
 		initArguments = strings.Join(arguments["init"], " ")
@@ -87,9 +271,20 @@ func generateExecuteCommand(commandDefinition, environmentVariablesString string
 	}
 
 	commandVariables := CommandVariables{
-		EnvironmentVariables: environmentVariablesString,
-		InitArguments:        initArguments,
-		ExecuteArguments:     executeArguments,
+		EnvironmentVariables:    environmentVariablesString,
+		InitArguments:           initArguments,
+		ExecuteArguments:        executeArguments,
+		HelmArguments:           generateHelmArguments(fp.helm),
+		CloudFormationArguments: generateCloudFormationArguments(fp.cloudFormation),
+		Parameters:              fp.parameters,
+	}
+	if fp.ansible != nil {
+		commandVariables.AnsibleInventory = base64.StdEncoding.EncodeToString(fp.inventory)
+		commandVariables.AnsiblePlaybook = fp.ansible.Playbook
+	}
+	if fp.kubectl != nil {
+		commandVariables.KubectlArguments = generateKubectlArguments(fp.kubectl)
+		commandVariables.KubectlPruneArguments = generateKubectlPruneArguments(fp.pruneKinds)
 	}
 
 	var buf bytes.Buffer
@@ -105,3 +300,58 @@ func generateExecuteCommand(commandDefinition, environmentVariablesString string
 
 	return buf.String(), nil
 }
+
+// generateHelmArguments returns the arguments of helm upgrade and helm diff
+// upgrade for the release. The values are validated by the request, so they
+// don't need quoting.
+func generateHelmArguments(helm *requests.HelmRelease) string {
+	if helm == nil {
+		return ""
+	}
+
+	arguments := []string{helm.ReleaseName, helm.Chart}
+	if helm.Version != "" {
+		arguments = append(arguments, "--version", helm.Version)
+	}
+	if helm.ValuesFile != "" {
+		arguments = append(arguments, "--values", helm.ValuesFile)
+	}
+	if helm.Namespace != "" {
+		arguments = append(arguments, "--namespace", helm.Namespace)
+	}
+	return strings.Join(arguments, " ")
+}
+
+// generateCloudFormationArguments returns the arguments of the change set
+// commands for the stack. Like the Helm arguments, they don't need quoting.
+func generateCloudFormationArguments(stack *requests.CloudFormationStack) string {
+	if stack == nil {
+		return ""
+	}
+	return fmt.Sprintf("--stack-name %s --change-set-name %s --template-file %s", stack.StackName, stack.ChangeSetName, stack.TemplateFile)
+}
+
+// generateKubectlArguments returns the arguments of kubectl diff and apply
+// for the manifests. Like the Helm arguments, they don't need quoting.
+func generateKubectlArguments(m *requests.KubernetesManifests) string {
+	arguments := []string{"--filename", m.Path, "--recursive"}
+	if m.Kustomize {
+		arguments = []string{"--kustomize", m.Path}
+	}
+	if m.Namespace != "" {
+		arguments = append(arguments, "--namespace", m.Namespace)
+	}
+	arguments = append(arguments, "--selector", m.Selector)
+	return strings.Join(arguments, " ")
+}
+
+// generateKubectlPruneArguments returns the arguments of kubectl apply
+// pruning the objects of the kinds, matching the selector, which aren't part
+// of the manifests anymore.
+func generateKubectlPruneArguments(kinds []string) string {
+	arguments := []string{"--prune"}
+	for _, k := range kinds {
+		arguments = append(arguments, "--prune-whitelist", k)
+	}
+	return strings.Join(arguments, " ")
+}
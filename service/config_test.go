@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
+	"github.com/cello-proj/cello/internal/requests"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,7 +31,7 @@ func TestGenerateExecuteCommand(t *testing.T) {
 	if err != nil {
 		t.Errorf("get command definition return error %s", err)
 	}
-	result, err := generateExecuteCommand(commandDefinition, "env test=abc", arguments)
+	result, err := generateExecuteCommand(commandDefinition, "env test=abc", arguments, frameworkParameters{})
 	if err != nil {
 		t.Errorf("generateExecuteCommand return error %s", err)
 	}
@@ -40,7 +45,7 @@ func TestGenerateExecuteCommand(t *testing.T) {
 	if err != nil {
 		t.Errorf("get command definition return error %s", err)
 	}
-	result, err = generateExecuteCommand(commandDefinition, "env test=abc", arguments)
+	result, err = generateExecuteCommand(commandDefinition, "env test=abc", arguments, frameworkParameters{})
 	if err != nil {
 		t.Errorf("generateExecuteCommand return error %s", err)
 	}
@@ -50,6 +55,124 @@ func TestGenerateExecuteCommand(t *testing.T) {
 	}
 }
 
+func TestGenerateExecuteCommandHelm(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("Unable to load config %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		commandType string
+		helm        *requests.HelmRelease
+		want        string
+	}{
+		{
+			name:        "diff",
+			commandType: "diff",
+			helm:        &requests.HelmRelease{Chart: "bitnami/nginx", Version: "13.2.1", ReleaseName: "web"},
+			want:        "env test=abc helm-init  && env test=abc helm diff upgrade --allow-unreleased web bitnami/nginx --version 13.2.1 --wait",
+		},
+		{
+			name:        "sync_all_parameters",
+			commandType: "sync",
+			helm: &requests.HelmRelease{
+				Chart:       "oci://registry.example.com/charts/web",
+				Version:     "1.0.0",
+				ValuesFile:  "deploy/values.yaml",
+				ReleaseName: "web",
+				Namespace:   "apps",
+			},
+			want: "env test=abc helm-init  && env test=abc helm upgrade --install --atomic web oci://registry.example.com/charts/web --version 1.0.0 --values deploy/values.yaml --namespace apps --wait",
+		},
+		{
+			name:        "sync_local_chart",
+			commandType: "sync",
+			helm:        &requests.HelmRelease{Chart: "./charts/web", ReleaseName: "web"},
+			want:        "env test=abc helm-init  && env test=abc helm upgrade --install --atomic web ./charts/web --wait",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandDefinition, err := config.getCommandDefinition("helm", tt.commandType)
+			assert.Nil(t, err)
+
+			got, err := generateExecuteCommand(commandDefinition, "env test=abc", map[string][]string{"execute": {"--wait"}}, frameworkParameters{helm: tt.helm})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerateExecuteCommandAnsible(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("Unable to load config %s", err)
+	}
+
+	fp := frameworkParameters{
+		ansible:   &requests.AnsiblePlaybook{Playbook: "playbooks/site.yml"},
+		inventory: []byte("all: {}\n"),
+	}
+
+	commandDefinition, err := config.getCommandDefinition("ansible", "diff")
+	assert.Nil(t, err)
+	got, err := generateExecuteCommand(commandDefinition, "env test=abc", map[string][]string{"execute": {"--limit web"}}, fp)
+	assert.Nil(t, err)
+	assert.Equal(t, "echo YWxsOiB7fQo= | base64 -d > inventory.yml && env test=abc ansible-playbook -i inventory.yml --check --diff playbooks/site.yml --limit web", got)
+
+	commandDefinition, err = config.getCommandDefinition("ansible", "sync")
+	assert.Nil(t, err)
+	got, err = generateExecuteCommand(commandDefinition, "env test=abc", nil, fp)
+	assert.Nil(t, err)
+	assert.Equal(t, "echo YWxsOiB7fQo= | base64 -d > inventory.yml && env test=abc ansible-playbook -i inventory.yml playbooks/site.yml ", got)
+}
+
+func TestGenerateExecuteCommandKubectl(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("Unable to load config %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		commandType string
+		kubectl     *requests.KubernetesManifests
+		want        string
+	}{
+		{
+			name:        "diff_manifests",
+			commandType: "diff",
+			kubectl:     &requests.KubernetesManifests{Path: "deploy", Selector: "app=web"},
+			want:        "env test=abc kube-init  && env test=abc kube-diff --filename deploy --recursive --selector app=web ",
+		},
+		{
+			name:        "sync_kustomization",
+			commandType: "sync",
+			kubectl:     &requests.KubernetesManifests{Path: "deploy/overlays/prod", Kustomize: true, Namespace: "apps", Selector: "app=web,tier=frontend"},
+			want:        "env test=abc kube-init  && env test=abc kubectl apply --kustomize deploy/overlays/prod --namespace apps --selector app=web,tier=frontend --prune --prune-whitelist core/v1/ConfigMap --prune-whitelist apps/v1/Deployment ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandDefinition, err := config.getCommandDefinition("kubectl", tt.commandType)
+			assert.Nil(t, err)
+
+			fp := frameworkParameters{kubectl: tt.kubectl, pruneKinds: []string{"core/v1/ConfigMap", "apps/v1/Deployment"}}
+			got, err := generateExecuteCommand(commandDefinition, "env test=abc", nil, fp)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKubectlPruneKinds(t *testing.T) {
+	assert.Equal(t, defaultKubectlPruneKinds, Config{}.kubectlPruneKinds())
+	assert.Equal(t, []string{"core/v1/Secret"}, Config{KubectlPruneKinds: []string{"core/v1/Secret"}}.kubectlPruneKinds())
+}
+
 // TODO refactor to table driven tests
 func TestGetCommandDefinition(t *testing.T) {
 	config, err := loadConfig(testConfigPath)
@@ -76,5 +199,27 @@ func TestListFrameworks(t *testing.T) {
 		t.Errorf("Unable to load config %s", err)
 	}
 
-	assert.Equal(t, []string{"cdk", "cool-new-framework", "terraform"}, config.listFrameworks())
+	assert.Equal(t, []string{"ansible", "cdk", "cloudformation", "cool-new-framework", "helm", "kubectl", "pulumi", "terraform"}, config.listFrameworks())
+}
+
+func TestAllowsWorkflowTemplate(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Errorf("Unable to load config %s", err)
+	}
+
+	assert.True(t, config.allowsWorkflowTemplate("argo-cloudops-single-step-vault-aws"))
+	assert.False(t, config.allowsWorkflowTemplate("shared-deploy"))
+	assert.True(t, Config{}.allowsWorkflowTemplate("shared-deploy"))
+}
+
+func TestValidateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.yaml")
+	if err := ioutil.WriteFile(path, []byte("port: https\nvault_adress: https://vault:8200\n"), 0600); err != nil {
+		t.Fatalf("unable to write service config %s", err)
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, validateConfig([]string{path}, &out))
+	assert.Equal(t, "error: port: 'https' is not a valid int\nerror: vault_adress: unknown key\n", out.String())
 }
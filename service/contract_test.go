@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/recorder"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// contractsDir holds exchanges recorded with ARGO_CLOUDOPS_RECORD_DIR, replayed
+// against the mocks of the handler tests.
+const contractsDir = "testdata/contracts"
+
+func TestContracts(t *testing.T) {
+	exchanges, err := recorder.Load(contractsDir)
+	if err != nil {
+		t.Fatalf("unable to load contracts: %v", err)
+	}
+
+	authHeaders := map[string]string{
+		"admin": adminAuthHeader,
+		"user":  userAuthHeader,
+	}
+
+	for _, e := range exchanges {
+		e := e
+		t.Run(e.Method+" "+e.Path, func(t *testing.T) {
+			resp := executeRequest(e.Method, e.Path, bytes.NewBuffer(e.Request), authHeaders[e.Authorization])
+			defer resp.Body.Close()
+			assert.Equal(t, e.Status, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.Nil(t, err)
+			assert.Nil(t, recorder.CheckShape(e.Response, body))
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// costAllocationTagsVariable is the environment variable the cost allocation
+// tags of the target are passed to workflows in, as JSON. It's the
+// default_tags variable of terraform configurations, other frameworks can
+// read it as is.
+const costAllocationTagsVariable = "TF_VAR_default_tags"
+
+// Sets the cost allocation tags passed to the workflows of an aws_account
+// target, so the resources they create are tagged for cost reporting.
+func (h handler) putTargetCostAllocationTags(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "put-target-cost-allocation-tags", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var ctr requests.PutTargetCostAllocationTags
+	if err := json.Unmarshal(reqBody, &ctr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := ctr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	target, err := cp.GetTarget(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if target.Type != "aws_account" {
+		h.errorResponse(w, "invalid request, cost allocation tags are only supported by aws_account targets", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := json.Marshal(ctr.Tags)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing cost allocation tags", "error", err)
+		h.errorResponse(w, "error serializing cost allocation tags", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target cost allocation tags")
+	err = h.dbClient.CreateTargetCostAllocationTagsEntry(rs.ctx, db.TargetCostAllocationTagsEntry{
+		Project: projectName,
+		Target:  targetName,
+		Tags:    string(tags),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target cost allocation tags", "error", err)
+		h.errorResponse(w, "error storing target cost allocation tags", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetCostAllocationTags(ctr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the cost allocation tags passed to the workflows of a target.
+func (h handler) getTargetCostAllocationTags(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "get-target-cost-allocation-tags", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	tags, err := h.targetCostAllocationTags(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "cost allocation tags not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target cost allocation tags", "error", err)
+		h.errorResponse(w, "error reading target cost allocation tags", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetCostAllocationTags{Tags: tags})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the cost allocation tags passed to the workflows of a target.
+func (h handler) deleteTargetCostAllocationTags(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "delete-target-cost-allocation-tags", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetCostAllocationTagsEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target cost allocation tags", "error", err)
+		h.errorResponse(w, "error deleting target cost allocation tags", http.StatusInternalServerError)
+		return
+	}
+}
+
+// targetCostAllocationTags returns the cost allocation tags of the target, or
+// upper's ErrNoMoreRows when it has none.
+func (h handler) targetCostAllocationTags(ctx context.Context, projectName, targetName string) (map[string]string, error) {
+	e, err := h.dbClient.ReadTargetCostAllocationTagsEntry(ctx, projectName, targetName)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags map[string]string
+	if err := json.Unmarshal([]byte(e.Tags), &tags); err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+	return tags, nil
+}
+
+// applyCostAllocationTags sets the cost allocation tags of the target of the
+// workflow as an environment variable, replacing the one of the request so
+// every workflow of the target tags its resources the same.
+func (h handler) applyCostAllocationTags(ctx context.Context, l log.Logger, cwr *requests.CreateWorkflow) error {
+	tags, err := h.targetCostAllocationTags(ctx, cwr.ProjectName, cwr.TargetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cwr.EnvironmentVariables[costAllocationTagsVariable]; ok {
+		level.Info(l).Log("message", "replacing environment variable with the cost allocation tags of the target", "variable", costAllocationTagsVariable)
+	}
+	environmentVariables := make(map[string]string, len(cwr.EnvironmentVariables)+1)
+	for k, v := range cwr.EnvironmentVariables {
+		environmentVariables[k] = v
+	}
+	// Tags can't have quotes, see requests.PutTargetCostAllocationTags.
+	environmentVariables[costAllocationTagsVariable] = "'" + string(data) + "'"
+	cwr.EnvironmentVariables = environmentVariables
+	return nil
+}
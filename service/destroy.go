@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Checks the safeguards of destroys beyond their justification and
+// confirmation, which are validated with the request, see destroyAllowed.
+// Writes the error response otherwise.
+func (h handler) allowedDestroy(ctx context.Context, w http.ResponseWriter, r *http.Request, l log.Logger, cwr requests.CreateWorkflow) bool {
+	err := h.destroyAllowed(ctx, cwr)
+	var rejected destroyRejectedError
+	if errors.As(err, &rejected) {
+		level.Warn(l).Log("message", "destroy rejected", "error", err)
+		status := http.StatusForbidden
+		if rejected.id == messages.TargetProtected {
+			status = http.StatusConflict
+		}
+		h.messageResponse(w, r, rejected.id, rejected.params(cwr), status)
+		return false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project settings", "error", err)
+		h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// destroyRejectedError is returned for destroys the settings of the project
+// don't allow.
+type destroyRejectedError struct {
+	id     messages.ID
+	target string
+}
+
+func (e destroyRejectedError) Error() string {
+	if e.id == messages.TargetProtected {
+		return fmt.Sprintf("target '%s' is protected, it can't be destroyed", e.target)
+	}
+	return fmt.Sprintf("project isn't allowed to destroy targets, it requires the %s scope", scopeWorkflowsDestroy)
+}
+
+// params returns the parameters of the message of the error.
+func (e destroyRejectedError) params(cwr requests.CreateWorkflow) messages.Params {
+	p := messages.Params{"project": cwr.ProjectName, "target": cwr.TargetName}
+	if e.id == messages.DestroyNotAllowed {
+		p["scope"] = scopeWorkflowsDestroy
+	}
+	return p
+}
+
+// destroyAllowed is allowedDestroy without the error response: the settings
+// of the project must grant it the workflows:destroy scope and the target
+// mustn't be protected by them. Other types are allowed.
+func (h handler) destroyAllowed(ctx context.Context, cwr requests.CreateWorkflow) error {
+	if cwr.Type != requests.TypeDestroy {
+		return nil
+	}
+
+	settings, err := h.projectSettings(ctx, cwr.ProjectName)
+	if err != nil {
+		return err
+	}
+	if !settings.Destroy.Allowed {
+		return destroyRejectedError{id: messages.DestroyNotAllowed, target: cwr.TargetName}
+	}
+	for _, t := range settings.Destroy.ProtectedTargets {
+		if t == cwr.TargetName {
+			return destroyRejectedError{id: messages.TargetProtected, target: cwr.TargetName}
+		}
+	}
+	return nil
+}
+
+// dualControlApprovers is how many principals other than the submitter must
+// approve dual controlled submissions.
+const dualControlApprovers = 2
+
+// dualControlled returns whether the settings of the project require dual
+// control of the submission, when it's a destroy or to a protected target.
+func dualControlled(settings types.ProjectSettings, cwr requests.CreateWorkflow) bool {
+	if !settings.Destroy.DualControl {
+		return false
+	}
+	if cwr.Type == requests.TypeDestroy {
+		return true
+	}
+	for _, t := range settings.Destroy.ProtectedTargets {
+		if t == cwr.TargetName {
+			return true
+		}
+	}
+	return false
+}
+
+// Records the justification of a submitted destroy as a 'destroy_requested'
+// execution event, so it's audited and sent to the webhooks of the project.
+func (h handler) recordDestroy(ctx context.Context, l log.Logger, txID, principal string, cwr requests.CreateWorkflow, workflowName string) {
+	if cwr.Type != requests.TypeDestroy {
+		return
+	}
+
+	level.Warn(l).Log("message", "destroy submitted", "justification", cwr.Justification)
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "destroy_requested",
+		Message:      fmt.Sprintf("destroy requested by %s: %s", principal, cwr.Justification),
+		CreatedAt:    h.now().UTC(),
+	})
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// elevationKeyPrefix prefixes the ID of elevated access in the key of its
+// token, vault:elevation-<id>:<secret>.
+const elevationKeyPrefix = "elevation-"
+
+// States of elevated access.
+const (
+	elevationPending = "pending"
+	elevationGranted = "granted"
+	elevationExpired = "expired"
+	elevationRevoked = "revoked"
+)
+
+// elevationState returns the state of elevated access at the time.
+func elevationState(e db.ProjectElevationEntry, now time.Time) string {
+	switch {
+	case e.RevokedAt != nil:
+		return elevationRevoked
+	case e.GrantedAt == nil:
+		return elevationPending
+	case !now.Before(*e.ExpiresAt):
+		return elevationExpired
+	default:
+		return elevationGranted
+	}
+}
+
+// elevationTokenHash returns the hash of the secret of a token stored with
+// its elevated access.
+func elevationTokenHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// elevatedPrincipal is the principal of a request authorized by elevated
+// access, the admin limited to the project of the access.
+type elevatedPrincipal struct {
+	// ID is the ID of the elevated access.
+	ID string
+	// Project is the project the admin is limited to.
+	Project string
+}
+
+// inScope returns whether the principal of the request can act on the
+// project, i.e. any project unless the request is authorized by elevated
+// access of another one.
+func (s *requestScope) inScope(project string) bool {
+	return s.elevation == nil || s.elevation.Project == project
+}
+
+// elevationScopeResponse writes the error response of a request authorized
+// by elevated access acting on a project other than its own.
+func (h handler) elevationScopeResponse(w http.ResponseWriter, s *requestScope) {
+	h.errorResponse(w, fmt.Sprintf("elevated access is limited to project '%s'", s.elevation.Project), http.StatusForbidden)
+}
+
+// elevate makes the admin, limited to the project, the principal of a
+// request of a project route authorized by a token of elevated access to the
+// project, when it's granted and neither expired nor revoked. Other tokens of
+// elevated access are left as is, failing authorization like invalid
+// credentials.
+func (h handler) elevate(s *requestScope) {
+	a := s.principal
+	if h.env.ElevationMaxTTL <= 0 || s.principalErr != nil || s.project == "" || !strings.HasPrefix(a.Key, elevationKeyPrefix) {
+		return
+	}
+	id := strings.TrimPrefix(a.Key, elevationKeyPrefix)
+	l := s.log("op", "elevate", "project", s.project, "elevation", id)
+
+	e, err := h.dbClient.ReadProjectElevationEntry(s.ctx, id)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		level.Warn(l).Log("message", "elevated access not found")
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading elevated access", "error", err)
+		return
+	}
+	if e.Project != s.project || subtle.ConstantTimeCompare([]byte(elevationTokenHash(a.Secret)), []byte(e.TokenHash)) != 1 {
+		level.Warn(l).Log("message", "invalid elevated access token")
+		return
+	}
+	if state := elevationState(e, h.now()); state != elevationGranted {
+		level.Warn(l).Log("message", "elevated access isn't granted", "state", state)
+		return
+	}
+
+	s.principal = &credentials.Authorization{Provider: "vault", Key: "admin", Secret: h.env.AdminSecret}
+	s.elevation = &elevatedPrincipal{ID: id, Project: e.Project}
+	s.logger = log.With(s.logger, "elevation", id)
+}
+
+// Records every request made with elevated access, reads included, as an
+// 'elevated_request' execution event of the elevated access.
+func (h handler) recordElevatedRequest(s *requestScope, r *http.Request) {
+	if s.elevation == nil {
+		return
+	}
+
+	h.recordExecutionEvent(s.ctx, s.logger, db.ExecutionEvent{
+		TxID:      s.elevation.ID,
+		Project:   s.elevation.Project,
+		Type:      "elevated_request",
+		Message:   fmt.Sprintf("%s %s with elevated access, transaction %s", r.Method, r.URL.Path, r.Header.Get(txIDHeader)),
+		CreatedAt: h.now().UTC(),
+	})
+}
+
+// Requests temporary admin access of a project to itself, granted by an admin
+// for its TTL, see grantElevation. The token of the access is only returned
+// with the request, its hash is stored with it.
+func (h handler) requestElevation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	txID := r.Header.Get(txIDHeader)
+
+	l := rs.log("op", "request-elevation", "project", projectName, "txid", txID)
+
+	level.Debug(l).Log("message", "validating authorization header for request elevation")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	if a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)) == nil {
+		h.errorResponse(w, "admins can't request elevated access", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var rer requests.RequestElevation
+	if err := json.Unmarshal(reqBody, &rer); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+	if err := rer.Validate(rer.ValidateMaxTTL(h.env.ElevationMaxTTL)); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ttl := requests.MinElevationTTL
+	if rer.TTL != "" {
+		ttl, _ = time.ParseDuration(rer.TTL)
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+	authorized, err := cp.ProjectAuthorized(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error authorizing project", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error authorizing project", http.StatusInternalServerError)
+		return
+	}
+	if !authorized {
+		h.messageResponse(w, r, messages.Unauthorized, nil, http.StatusUnauthorized)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		level.Error(l).Log("message", "error generating elevated access token", "error", err)
+		h.errorResponse(w, "error generating elevated access token", http.StatusInternalServerError)
+		return
+	}
+	secret := hex.EncodeToString(b)
+
+	e := db.ProjectElevationEntry{
+		ID:            uuid.NewString(),
+		Project:       projectName,
+		RequestedBy:   authorizationName(a),
+		Justification: rer.Justification,
+		TTLSeconds:    int64(ttl / time.Second),
+		TokenHash:     elevationTokenHash(secret),
+		CreatedAt:     h.now().UTC(),
+	}
+	if err := h.dbClient.CreateProjectElevationEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error recording elevated access", "error", err)
+		h.errorResponse(w, "error recording elevated access", http.StatusInternalServerError)
+		return
+	}
+
+	level.Warn(l).Log("message", "elevated access requested", "elevation", e.ID, "requested-by", e.RequestedBy, "ttl", ttl.String(), "justification", rer.Justification)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      e.ID,
+		Project:   projectName,
+		Type:      "elevation_requested",
+		Message:   fmt.Sprintf("admin access for %s requested by %s: %s", ttl, e.RequestedBy, rer.Justification),
+		CreatedAt: e.CreatedAt,
+	})
+
+	jsonData, err := json.Marshal(responses.Elevation{
+		ID:    e.ID,
+		Token: fmt.Sprintf("%s:%s%s:%s", credentials.SchemeVault, elevationKeyPrefix, e.ID, secret),
+		State: elevationPending,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing elevated access", "error", err)
+		h.errorResponse(w, "error serializing elevated access", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Gets the audit records of the elevated access of a project.
+func (h handler) getElevations(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-elevations", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectElevationEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading elevated access", "error", err)
+		h.errorResponse(w, "error reading elevated access", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]responses.ElevationRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, h.elevationRecord(e))
+	}
+
+	jsonData, err := json.Marshal(records)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing elevated access", "error", err)
+		h.errorResponse(w, "error serializing elevated access", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Grants pending elevated access for its TTL from now.
+func (h handler) grantElevation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	id := mux.Vars(r)["elevationID"]
+
+	l := rs.log("op", "grant-elevation", "project", projectName, "elevation", id)
+
+	e, ok := h.authorizedElevation(w, r, l, projectName, id)
+	if !ok {
+		return
+	}
+	now := h.now().UTC()
+	if state := elevationState(e, now); state != elevationPending {
+		h.errorResponse(w, fmt.Sprintf("elevated access '%s' is %s, only pending access can be granted", id, state), http.StatusConflict)
+		return
+	}
+
+	a, _ := rs.authorization()
+	grantedBy := authorizationName(a)
+	expiresAt := now.Add(time.Duration(e.TTLSeconds) * time.Second)
+	if err := h.dbClient.GrantProjectElevationEntry(rs.ctx, id, grantedBy, now, expiresAt); err != nil {
+		level.Error(l).Log("message", "error granting elevated access", "error", err)
+		h.errorResponse(w, "error granting elevated access", http.StatusInternalServerError)
+		return
+	}
+	e.GrantedBy, e.GrantedAt, e.ExpiresAt = grantedBy, &now, &expiresAt
+
+	level.Warn(l).Log("message", "elevated access granted", "requested-by", e.RequestedBy, "expires", expiresAt.Format(time.RFC3339))
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      id,
+		Project:   projectName,
+		Type:      "elevation_granted",
+		Message:   fmt.Sprintf("admin access requested by %s granted by %s until %s", e.RequestedBy, grantedBy, expiresAt.Format(time.RFC3339)),
+		CreatedAt: now,
+	})
+
+	jsonData, err := json.Marshal(h.elevationRecord(e))
+	if err != nil {
+		level.Error(l).Log("message", "error serializing elevated access", "error", err)
+		h.errorResponse(w, "error serializing elevated access", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Revokes pending or granted elevated access.
+func (h handler) revokeElevation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	id := mux.Vars(r)["elevationID"]
+
+	l := rs.log("op", "revoke-elevation", "project", projectName, "elevation", id)
+
+	e, ok := h.authorizedElevation(w, r, l, projectName, id)
+	if !ok {
+		return
+	}
+	now := h.now().UTC()
+	if state := elevationState(e, now); state != elevationPending && state != elevationGranted {
+		h.errorResponse(w, fmt.Sprintf("elevated access '%s' is already %s", id, state), http.StatusConflict)
+		return
+	}
+
+	if err := h.dbClient.RevokeProjectElevationEntry(rs.ctx, id, now); err != nil {
+		level.Error(l).Log("message", "error revoking elevated access", "error", err)
+		h.errorResponse(w, "error revoking elevated access", http.StatusInternalServerError)
+		return
+	}
+
+	a, _ := rs.authorization()
+	level.Warn(l).Log("message", "elevated access revoked", "requested-by", e.RequestedBy)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      id,
+		Project:   projectName,
+		Type:      "elevation_revoked",
+		Message:   fmt.Sprintf("admin access requested by %s revoked by %s", e.RequestedBy, authorizationName(a)),
+		CreatedAt: now,
+	})
+}
+
+// authorizedElevation validates the request is from an admin, without
+// elevated access as it can't extend itself, and reads the elevated access
+// of the project, writing the error response otherwise.
+func (h handler) authorizedElevation(w http.ResponseWriter, r *http.Request, l log.Logger, projectName, id string) (db.ProjectElevationEntry, bool) {
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return db.ProjectElevationEntry{}, false
+	}
+	if h.scope(r).elevation != nil {
+		h.errorResponse(w, "elevated access can't grant or revoke elevated access", http.StatusForbidden)
+		return db.ProjectElevationEntry{}, false
+	}
+
+	e, err := h.dbClient.ReadProjectElevationEntry(h.scope(r).ctx, id)
+	if errors.Is(err, upper.ErrNoMoreRows) || (err == nil && e.Project != projectName) {
+		h.errorResponse(w, "elevated access not found", http.StatusNotFound)
+		return db.ProjectElevationEntry{}, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading elevated access", "error", err)
+		h.errorResponse(w, "error reading elevated access", http.StatusInternalServerError)
+		return db.ProjectElevationEntry{}, false
+	}
+	return e, true
+}
+
+// elevationRecord returns the audit record of elevated access.
+func (h handler) elevationRecord(e db.ProjectElevationEntry) responses.ElevationRecord {
+	record := responses.ElevationRecord{
+		ID:            e.ID,
+		RequestedBy:   e.RequestedBy,
+		Justification: e.Justification,
+		TTL:           (time.Duration(e.TTLSeconds) * time.Second).String(),
+		State:         elevationState(e, h.now()),
+		CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339),
+		GrantedBy:     e.GrantedBy,
+	}
+	if e.GrantedAt != nil {
+		record.GrantedAt = e.GrantedAt.UTC().Format(time.RFC3339)
+	}
+	if e.ExpiresAt != nil {
+		record.ExpiresAt = e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if e.RevokedAt != nil {
+		record.RevokedAt = e.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return record
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/encryption"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+// Puts the encryption key of a project, replacing any existing one. Values
+// encrypted with a replaced key are rejected and must be encrypted again.
+func (h handler) putProjectEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-encryption-key", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var pkr requests.PutProjectEncryptionKey
+	if err := json.Unmarshal(reqBody, &pkr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	_, keyID, err := encryption.ParsePublicKey(pkr.PublicKey)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid public key", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	e := db.ProjectEncryptionKeyEntry{
+		Project:          projectName,
+		KeyID:            keyID,
+		PublicKey:        pkr.PublicKey,
+		PrivateKeySecret: pkr.PrivateKeySecret,
+		CreatedAt:        h.now().UTC(),
+	}
+
+	level.Debug(l).Log("message", "storing project encryption key", "key-id", keyID)
+	if err := h.dbClient.CreateProjectEncryptionKeyEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing project encryption key", "error", err)
+		h.errorResponse(w, "error storing project encryption key", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(newProjectEncryptionKeyResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the encryption key of a project
+func (h handler) getProjectEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-encryption-key", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	e, err := h.dbClient.ReadProjectEncryptionKeyEntry(rs.ctx, projectName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "encryption key not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading project encryption key", "error", err)
+		h.errorResponse(w, "error reading project encryption key", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(newProjectEncryptionKeyResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the encryption key of a project. Encrypted values are rejected
+// until a new key is put.
+func (h handler) deleteProjectEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "delete-project-encryption-key", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteProjectEncryptionKeyEntry(rs.ctx, projectName); err != nil {
+		level.Error(l).Log("message", "error deleting project encryption key", "error", err)
+		h.errorResponse(w, "error deleting project encryption key", http.StatusInternalServerError)
+		return
+	}
+}
+
+func newProjectEncryptionKeyResponse(e db.ProjectEncryptionKeyEntry) responses.GetProjectEncryptionKey {
+	return responses.GetProjectEncryptionKey{
+		KeyID:            e.KeyID,
+		PublicKey:        e.PublicKey,
+		PrivateKeySecret: e.PrivateKeySecret,
+		CreatedAt:        e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// encryptedValues are the encrypted environment variables of a workflow and
+// the secret of the private key they're decrypted with in the workflow pod.
+type encryptedValues struct {
+	secret      string
+	environment map[string]string
+}
+
+// Checks the encrypted values of a workflow request were encrypted with the
+// key of its project, returning the request without its encrypted
+// environment variables. Returns false when an error response was written.
+func (h handler) checkEncryptedValues(ctx context.Context, w http.ResponseWriter, l log.Logger, cwr requests.CreateWorkflow) (requests.CreateWorkflow, encryptedValues, bool) {
+	cwr, ev, err := h.extractEncryptedValues(ctx, cwr)
+	if errors.Is(err, encryption.ErrInvalidValue) {
+		level.Error(l).Log("message", "error invalid encrypted value", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return cwr, encryptedValues{}, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project encryption key", "error", err)
+		h.errorResponse(w, "error reading project encryption key", http.StatusInternalServerError)
+		return cwr, encryptedValues{}, false
+	}
+	return cwr, ev, true
+}
+
+// extractEncryptedValues is checkEncryptedValues without the error response.
+// Framework commands set environment variables inline, which would override
+// the decrypted ones, so encrypted environment variables are passed to the
+// workflow separately. Encrypted parameters are left for referenced
+// templates to decrypt.
+func (h handler) extractEncryptedValues(ctx context.Context, cwr requests.CreateWorkflow) (requests.CreateWorkflow, encryptedValues, error) {
+	fields := map[string]string{}
+	for k, v := range cwr.EnvironmentVariables {
+		if encryption.IsEncrypted(v) {
+			fields["environment_variables."+k] = v
+		}
+	}
+	for k, v := range cwr.Parameters {
+		if encryption.IsEncrypted(v) {
+			fields["parameters."+k] = v
+		}
+	}
+	if len(fields) == 0 {
+		return cwr, encryptedValues{}, nil
+	}
+
+	e, err := h.dbClient.ReadProjectEncryptionKeyEntry(ctx, cwr.ProjectName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return cwr, encryptedValues{}, fmt.Errorf("%w, project '%s' has no encryption key", encryption.ErrInvalidValue, cwr.ProjectName)
+	}
+	if err != nil {
+		return cwr, encryptedValues{}, err
+	}
+	pub, keyID, err := encryption.ParsePublicKey(e.PublicKey)
+	if err != nil {
+		return cwr, encryptedValues{}, err
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := encryption.Check(fields[name], pub, keyID); err != nil {
+			return cwr, encryptedValues{}, fmt.Errorf("%s has an %w", name, err)
+		}
+	}
+
+	ev := encryptedValues{secret: e.PrivateKeySecret, environment: map[string]string{}}
+	plain := map[string]string{}
+	for k, v := range cwr.EnvironmentVariables {
+		if encryption.IsEncrypted(v) {
+			ev.environment[k] = v
+		} else {
+			plain[k] = v
+		}
+	}
+	cwr.EnvironmentVariables = plain
+	return cwr, ev, nil
+}
+
+// Adds the parameters the workflow decrypts its encrypted values with, the
+// secret of the private key and the encrypted environment variables as space
+// separated 'NAME=value' pairs.
+func addEncryptedParameters(parameters map[string]string, ev encryptedValues) {
+	if ev.secret == "" {
+		return
+	}
+	parameters["encryption_key_secret"] = ev.secret
+	parameters["encrypted_environment_variables"] = strings.TrimPrefix(generateEnvVariablesString(ev.environment), "env ")
+}
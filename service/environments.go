@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// Puts (creates or replaces) an environment of a project. Targets are in at
+// most one environment, so the rules applying to a target are unambiguous.
+func (h handler) putProjectEnvironment(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	name := mux.Vars(r)["environmentName"]
+
+	l := rs.log("op", "put-project-environment", "project", projectName, "environment", name)
+
+	ctx := rs.ctx
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	// Unknown fields are rejected so typos in the rules aren't silently
+	// ignored, like project settings.
+	var per requests.PutEnvironment
+	dec := json.NewDecoder(bytes.NewReader(reqBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&per); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error deserializing request body, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := per.Validate(per.ValidateName(name)); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating admin credentials provider")
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+	for _, t := range per.Targets {
+		exists, err := cp.TargetExists(projectName, t)
+		if err != nil {
+			level.Error(l).Log("message", "error retrieving target", "error", err)
+			h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": t}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.dbClient.ListProjectEnvironmentEntries(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environments", "error", err)
+		h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+		return
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			continue
+		}
+		other, err := decodeEnvironment(e)
+		if err != nil {
+			level.Error(l).Log("message", "error reading project environments", "error", err)
+			h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+			return
+		}
+		for _, t := range per.Targets {
+			if contains(other.Targets, t) {
+				h.errorResponse(w, fmt.Sprintf("invalid request, target '%s' is in environment '%s'", t, e.Name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	env := normalizeEnvironment(types.Environment(per))
+	data, err := json.Marshal(env)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing project environment", "error", err)
+		h.errorResponse(w, "error serializing project environment", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing project environment")
+	err = h.dbClient.CreateProjectEnvironmentEntry(ctx, db.ProjectEnvironmentEntry{
+		Project:     projectName,
+		Name:        name,
+		Environment: string(data),
+		UpdatedAt:   h.now().UTC(),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing project environment", "error", err)
+		h.errorResponse(w, "error storing project environment", http.StatusInternalServerError)
+		return
+	}
+
+	data, err = json.Marshal(responses.Environment{Name: name, Environment: env})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the environments of a project
+func (h handler) getProjectEnvironments(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-environments", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectEnvironmentEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environments", "error", err)
+		h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+		return
+	}
+
+	resp := []responses.Environment{}
+	for _, e := range entries {
+		env, err := decodeEnvironment(e)
+		if err != nil {
+			level.Error(l).Log("message", "error reading project environments", "error", err)
+			h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+			return
+		}
+		resp = append(resp, responses.Environment{Name: e.Name, Environment: env})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets an environment of a project
+func (h handler) getProjectEnvironment(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	name := mux.Vars(r)["environmentName"]
+
+	l := rs.log("op", "get-project-environment", "project", projectName, "environment", name)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	env, err := h.projectEnvironment(rs.ctx, projectName, name)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "environment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environment", "error", err)
+		h.errorResponse(w, "error reading project environment", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.Environment{Name: name, Environment: env})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes an environment of a project, its targets are kept
+func (h handler) deleteProjectEnvironment(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	name := mux.Vars(r)["environmentName"]
+
+	l := rs.log("op", "delete-project-environment", "project", projectName, "environment", name)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteProjectEnvironmentEntry(rs.ctx, projectName, name); err != nil {
+		level.Error(l).Log("message", "error deleting project environment", "error", err)
+		h.errorResponse(w, "error deleting project environment", http.StatusInternalServerError)
+		return
+	}
+}
+
+// projectEnvironment returns an environment of the project, upper's
+// ErrNoMoreRows when it doesn't exist.
+func (h handler) projectEnvironment(ctx context.Context, projectName, name string) (types.Environment, error) {
+	e, err := h.dbClient.ReadProjectEnvironmentEntry(ctx, projectName, name)
+	if err != nil {
+		return types.Environment{}, err
+	}
+	return decodeEnvironment(e)
+}
+
+// targetEnvironment returns the environment of the target and its name, and
+// false when the target isn't in an environment.
+func (h handler) targetEnvironment(ctx context.Context, projectName, targetName string) (string, types.Environment, bool, error) {
+	entries, err := h.dbClient.ListProjectEnvironmentEntries(ctx, projectName)
+	if err != nil {
+		return "", types.Environment{}, false, err
+	}
+	for _, e := range entries {
+		env, err := decodeEnvironment(e)
+		if err != nil {
+			return "", types.Environment{}, false, err
+		}
+		if contains(env.Targets, targetName) {
+			return e.Name, env, true, nil
+		}
+	}
+	return "", types.Environment{}, false, nil
+}
+
+// invalidEnvironmentError is returned for workflow requests naming an
+// environment they can't be submitted to.
+type invalidEnvironmentError struct {
+	reason string
+}
+
+func (e invalidEnvironmentError) Error() string {
+	return e.reason
+}
+
+// applyEnvironment selects the target of a workflow request naming an
+// environment, the only target of the environment when the request has none,
+// then applies the parameters of the environment of the target. Returns an
+// invalidEnvironmentError when the request can't be submitted to the
+// environment.
+func (h handler) applyEnvironment(ctx context.Context, cwr requests.CreateWorkflow) (requests.CreateWorkflow, error) {
+	var name string
+	var env types.Environment
+	var ok bool
+	var err error
+	if cwr.Environment != "" {
+		name = cwr.Environment
+		env, err = h.projectEnvironment(ctx, cwr.ProjectName, name)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return cwr, invalidEnvironmentError{fmt.Sprintf("environment '%s' not found", name)}
+		}
+		if err != nil {
+			return cwr, err
+		}
+		if cwr.TargetName == "" && len(env.Targets) > 1 {
+			return cwr, invalidEnvironmentError{fmt.Sprintf("target_name is required, environment '%s' has %d targets", name, len(env.Targets))}
+		}
+		if cwr.TargetName == "" {
+			cwr.TargetName = env.Targets[0]
+		}
+		if !contains(env.Targets, cwr.TargetName) {
+			return cwr, invalidEnvironmentError{fmt.Sprintf("target '%s' is not in environment '%s'", cwr.TargetName, name)}
+		}
+		ok = true
+	} else {
+		name, env, ok, err = h.targetEnvironment(ctx, cwr.ProjectName, cwr.TargetName)
+		if err != nil {
+			return cwr, err
+		}
+	}
+	if !ok || len(env.Parameters) == 0 {
+		return cwr, nil
+	}
+
+	parameters := make(map[string]string, len(cwr.Parameters)+len(env.Parameters))
+	for k, v := range env.Parameters {
+		parameters[k] = v
+	}
+	for k, v := range cwr.Parameters {
+		parameters[k] = v
+	}
+	cwr.Parameters = parameters
+	return cwr, nil
+}
+
+// selectEnvironmentTarget applies the environment to the workflow request,
+// see applyEnvironment. Returns false when an error response was written.
+func (h handler) selectEnvironmentTarget(ctx context.Context, w http.ResponseWriter, l log.Logger, cwr requests.CreateWorkflow) (requests.CreateWorkflow, bool) {
+	cwr, err := h.applyEnvironment(ctx, cwr)
+	var invalid invalidEnvironmentError
+	if errors.As(err, &invalid) {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", invalid.reason), http.StatusBadRequest)
+		return cwr, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environments", "error", err)
+		h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+		return cwr, false
+	}
+	return cwr, true
+}
+
+// targetFrozenError is returned for submissions during a freeze window of the
+// environment of the target.
+type targetFrozenError struct {
+	target      string
+	environment string
+	window      types.FreezeWindow
+}
+
+func (e targetFrozenError) Error() string {
+	return fmt.Sprintf("target '%s' of environment '%s' is frozen until %s: %s", e.target, e.environment, e.window.End.UTC().Format(time.RFC3339), e.window.Reason)
+}
+
+// params returns the parameters of the message of the error.
+func (e targetFrozenError) params(projectName string) messages.Params {
+	return messages.Params{"project": projectName, "target": e.target, "environment": e.environment, "until": e.window.End.UTC().Format(time.RFC3339), "reason": e.window.Reason}
+}
+
+// frozenAt returns a targetFrozenError when the time is in a freeze window of
+// the environment of the target.
+func (h handler) frozenAt(ctx context.Context, projectName, targetName string, at time.Time) error {
+	name, env, ok, err := h.targetEnvironment(ctx, projectName, targetName)
+	if err != nil || !ok {
+		return err
+	}
+	if f, frozen := env.FrozenAt(at); frozen {
+		return targetFrozenError{target: targetName, environment: name, window: f}
+	}
+	return nil
+}
+
+// Checks the submission isn't during a freeze window of the environment of
+// the target. Returns false when an error response was written.
+func (h handler) outsideFreezeWindows(ctx context.Context, w http.ResponseWriter, r *http.Request, l log.Logger, projectName, targetName string) bool {
+	err := h.frozenAt(ctx, projectName, targetName, h.now())
+	var frozen targetFrozenError
+	if errors.As(err, &frozen) {
+		level.Info(l).Log("message", "submission during freeze window", "environment", frozen.environment, "until", frozen.window.End)
+		h.messageResponse(w, r, messages.TargetFrozen, frozen.params(projectName), http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environments", "error", err)
+		h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// defaultApproval returns the change control of a target without its own,
+// the one of its environment when it's change controlled, the one of the
+// project settings otherwise.
+func (h handler) defaultApproval(ctx context.Context, projectName, targetName string) (types.ApprovalSettings, error) {
+	_, env, ok, err := h.targetEnvironment(ctx, projectName, targetName)
+	if err != nil {
+		return types.ApprovalSettings{}, err
+	}
+	if ok && env.Approval.ChangeControl {
+		return env.Approval, nil
+	}
+
+	settings, err := h.projectSettings(ctx, projectName)
+	if err != nil {
+		return types.ApprovalSettings{}, err
+	}
+	return settings.Approval, nil
+}
+
+func decodeEnvironment(e db.ProjectEnvironmentEntry) (types.Environment, error) {
+	var env types.Environment
+	if err := json.Unmarshal([]byte(e.Environment), &env); err != nil {
+		return types.Environment{}, fmt.Errorf("error deserializing project environment: %w", err)
+	}
+	return normalizeEnvironment(env), nil
+}
+
+// normalizeEnvironment returns the environment with empty lists and maps
+// instead of nil ones, so they're serialized as such.
+func normalizeEnvironment(e types.Environment) types.Environment {
+	if e.Parameters == nil {
+		e.Parameters = map[string]string{}
+	}
+	if e.FreezeWindows == nil {
+		e.FreezeWindows = []types.FreezeWindow{}
+	}
+	return e
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// States of framework image rotations.
+const (
+	frameworkImageCanary   = "canary"
+	frameworkImagePromoted = "promoted"
+	frameworkImageAborted  = "aborted"
+)
+
+// frameworkImageState returns the state of a framework image rotation.
+func frameworkImageState(e db.FrameworkImageEntry) string {
+	switch {
+	case e.PromotedAt != nil:
+		return frameworkImagePromoted
+	case e.AbortedAt != nil:
+		return frameworkImageAborted
+	default:
+		return frameworkImageCanary
+	}
+}
+
+// frameworkImageDigest returns the digest of the image for the workflows of
+// the project, the digest of the rotation in canary for its canary project,
+// the pinned one otherwise, empty when neither. Entries are in creation order.
+func frameworkImageDigest(entries []db.FrameworkImageEntry, image, project string) (digest string, canary bool) {
+	for _, e := range entries {
+		if e.Image != image {
+			continue
+		}
+		switch frameworkImageState(e) {
+		case frameworkImagePromoted:
+			if !canary {
+				digest = e.Digest
+			}
+		case frameworkImageCanary:
+			if e.CanaryProject == project {
+				digest, canary = e.Digest, true
+			}
+		}
+	}
+	return digest, canary
+}
+
+// pinnedFrameworkImages returns the IDs of the latest promoted rotation of
+// every image.
+func pinnedFrameworkImages(entries []db.FrameworkImageEntry) map[string]bool {
+	latest := map[string]string{}
+	for _, e := range entries {
+		if frameworkImageState(e) == frameworkImagePromoted {
+			latest[e.Image] = e.ID
+		}
+	}
+
+	pinned := make(map[string]bool, len(latest))
+	for _, id := range latest {
+		pinned[id] = true
+	}
+	return pinned
+}
+
+// applyFrameworkImagePin replaces the execute container image of the
+// workflow by the image pinned to its digest for the project of the workflow,
+// see frameworkImageDigest. Images set with a digest are never pinned.
+func (h handler) applyFrameworkImagePin(ctx context.Context, l log.Logger, cwr *requests.CreateWorkflow) error {
+	image := cwr.Parameters["execute_container_image_uri"]
+	if image == "" {
+		return nil
+	}
+
+	entries, err := h.dbClient.ListFrameworkImageEntries(ctx, cwr.Framework)
+	if err != nil {
+		return err
+	}
+	digest, canary := frameworkImageDigest(entries, image, cwr.ProjectName)
+	if digest == "" {
+		return nil
+	}
+
+	level.Info(l).Log("message", "pinning framework image", "image", image, "digest", digest, "canary", canary)
+	parameters := make(types.Parameters, len(cwr.Parameters))
+	for k, v := range cwr.Parameters {
+		parameters[k] = v
+	}
+	parameters["execute_container_image_uri"] = image + "@" + digest
+	cwr.Parameters = parameters
+	return nil
+}
+
+// Gets the image rotations of a framework, its pins and their history.
+func (h handler) getFrameworkImages(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	framework := mux.Vars(r)["framework"]
+
+	l := rs.log("op", "get-framework-images", "framework", framework)
+
+	if !h.authorizedFramework(w, r, l, framework) {
+		return
+	}
+
+	entries, err := h.dbClient.ListFrameworkImageEntries(rs.ctx, framework)
+	if err != nil {
+		level.Error(l).Log("message", "error reading framework images", "error", err)
+		h.errorResponse(w, "error reading framework images", http.StatusInternalServerError)
+		return
+	}
+
+	pinned := pinnedFrameworkImages(entries)
+	images := make([]responses.FrameworkImage, 0, len(entries))
+	for _, e := range entries {
+		images = append(images, frameworkImageResponse(e, pinned[e.ID]))
+	}
+
+	jsonData, err := json.Marshal(images)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing framework images", "error", err)
+		h.errorResponse(w, "error serializing framework images", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Rolls the image of a framework version to a digest, first for the
+// workflows of the canary project, see promoteFrameworkImageRotation. An image
+// has at most one rotation in canary.
+func (h handler) createFrameworkImageRotation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	framework := mux.Vars(r)["framework"]
+
+	l := rs.log("op", "create-framework-image-rotation", "framework", framework)
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+	if _, ok := rs.config.Commands[framework]; !ok {
+		h.errorResponse(w, "framework not found", http.StatusNotFound)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var req requests.CreateFrameworkImageRotation
+	if err := json.Unmarshal(reqBody, &req); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(req.CanaryProject)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error checking project", http.StatusInternalServerError)
+		return
+	}
+	if !projectExists {
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": req.CanaryProject}, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.dbClient.ListFrameworkImageEntries(rs.ctx, framework)
+	if err != nil {
+		level.Error(l).Log("message", "error reading framework images", "error", err)
+		h.errorResponse(w, "error reading framework images", http.StatusInternalServerError)
+		return
+	}
+	pinned := pinnedFrameworkImages(entries)
+	for _, e := range entries {
+		if e.Image != req.Image {
+			continue
+		}
+		if frameworkImageState(e) == frameworkImageCanary {
+			h.errorResponse(w, fmt.Sprintf("image '%s' has rotation '%s' in canary, promote or abort it first", req.Image, e.ID), http.StatusConflict)
+			return
+		}
+		if pinned[e.ID] && e.Digest == req.Digest {
+			h.errorResponse(w, fmt.Sprintf("invalid request, image '%s' is already pinned to digest '%s'", req.Image, req.Digest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	a, _ := rs.authorization()
+	e := db.FrameworkImageEntry{
+		ID:            uuid.NewString(),
+		Framework:     framework,
+		Image:         req.Image,
+		Digest:        req.Digest,
+		CanaryProject: req.CanaryProject,
+		CreatedBy:     authorizationName(a),
+		CreatedAt:     h.now().UTC(),
+	}
+	if err := h.dbClient.CreateFrameworkImageEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error recording framework image rotation", "error", err)
+		h.errorResponse(w, "error recording framework image rotation", http.StatusInternalServerError)
+		return
+	}
+
+	level.Warn(l).Log("message", "framework image rotation started", "rotation", e.ID, "image", e.Image, "digest", e.Digest, "canary-project", e.CanaryProject)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      e.ID,
+		Project:   e.CanaryProject,
+		Type:      "framework_image_canary",
+		Message:   fmt.Sprintf("%s image %s rolled to %s for the canary project by %s", framework, e.Image, e.Digest, e.CreatedBy),
+		CreatedAt: e.CreatedAt,
+	})
+
+	h.writeFrameworkImage(w, l, e, false)
+}
+
+// Promotes a framework image rotation in canary, pinning the image to its
+// digest for every workflow.
+func (h handler) promoteFrameworkImageRotation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	framework, id := mux.Vars(r)["framework"], mux.Vars(r)["rotationID"]
+
+	l := rs.log("op", "promote-framework-image-rotation", "framework", framework, "rotation", id)
+
+	e, ok := h.frameworkImageRotation(w, r, l, framework, id)
+	if !ok {
+		return
+	}
+
+	a, _ := rs.authorization()
+	now := h.now().UTC()
+	promotedBy := authorizationName(a)
+	if err := h.dbClient.PromoteFrameworkImageEntry(rs.ctx, id, promotedBy, now); err != nil {
+		level.Error(l).Log("message", "error promoting framework image rotation", "error", err)
+		h.errorResponse(w, "error promoting framework image rotation", http.StatusInternalServerError)
+		return
+	}
+	e.PromotedBy, e.PromotedAt = promotedBy, &now
+
+	level.Warn(l).Log("message", "framework image rotation promoted", "image", e.Image, "digest", e.Digest)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      id,
+		Project:   e.CanaryProject,
+		Type:      "framework_image_promoted",
+		Message:   fmt.Sprintf("%s image %s pinned to %s for every project by %s", framework, e.Image, e.Digest, promotedBy),
+		CreatedAt: now,
+	})
+
+	h.writeFrameworkImage(w, l, e, true)
+}
+
+// Aborts a framework image rotation in canary, the canary project uses the
+// pinned digest again.
+func (h handler) abortFrameworkImageRotation(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	framework, id := mux.Vars(r)["framework"], mux.Vars(r)["rotationID"]
+
+	l := rs.log("op", "abort-framework-image-rotation", "framework", framework, "rotation", id)
+
+	e, ok := h.frameworkImageRotation(w, r, l, framework, id)
+	if !ok {
+		return
+	}
+
+	a, _ := rs.authorization()
+	now := h.now().UTC()
+	abortedBy := authorizationName(a)
+	if err := h.dbClient.AbortFrameworkImageEntry(rs.ctx, id, abortedBy, now); err != nil {
+		level.Error(l).Log("message", "error aborting framework image rotation", "error", err)
+		h.errorResponse(w, "error aborting framework image rotation", http.StatusInternalServerError)
+		return
+	}
+
+	level.Warn(l).Log("message", "framework image rotation aborted", "image", e.Image, "digest", e.Digest)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:      id,
+		Project:   e.CanaryProject,
+		Type:      "framework_image_aborted",
+		Message:   fmt.Sprintf("%s image %s rotation to %s aborted by %s", framework, e.Image, e.Digest, abortedBy),
+		CreatedAt: now,
+	})
+}
+
+// authorizedFramework validates the request is from an admin and the
+// framework exists, writing the error response otherwise.
+func (h handler) authorizedFramework(w http.ResponseWriter, r *http.Request, l log.Logger, framework string) bool {
+	if _, ok := h.adminProvider(w, r, l); !ok {
+		return false
+	}
+	if _, ok := h.scope(r).config.Commands[framework]; !ok {
+		h.errorResponse(w, "framework not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// frameworkImageRotation validates the request is from an admin and reads
+// the rotation of the framework, which must be in canary, writing the error
+// response otherwise.
+func (h handler) frameworkImageRotation(w http.ResponseWriter, r *http.Request, l log.Logger, framework, id string) (db.FrameworkImageEntry, bool) {
+	if !h.authorizedFramework(w, r, l, framework) {
+		return db.FrameworkImageEntry{}, false
+	}
+
+	e, err := h.dbClient.ReadFrameworkImageEntry(h.scope(r).ctx, id)
+	if errors.Is(err, upper.ErrNoMoreRows) || (err == nil && e.Framework != framework) {
+		h.errorResponse(w, "framework image rotation not found", http.StatusNotFound)
+		return db.FrameworkImageEntry{}, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading framework image rotation", "error", err)
+		h.errorResponse(w, "error reading framework image rotation", http.StatusInternalServerError)
+		return db.FrameworkImageEntry{}, false
+	}
+	if state := frameworkImageState(e); state != frameworkImageCanary {
+		h.errorResponse(w, fmt.Sprintf("framework image rotation '%s' is already %s", id, state), http.StatusConflict)
+		return db.FrameworkImageEntry{}, false
+	}
+	return e, true
+}
+
+func (h handler) writeFrameworkImage(w http.ResponseWriter, l log.Logger, e db.FrameworkImageEntry, pinned bool) {
+	jsonData, err := json.Marshal(frameworkImageResponse(e, pinned))
+	if err != nil {
+		level.Error(l).Log("message", "error serializing framework image", "error", err)
+		h.errorResponse(w, "error serializing framework image", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// frameworkImageResponse returns the response of a framework image rotation.
+func frameworkImageResponse(e db.FrameworkImageEntry, pinned bool) responses.FrameworkImage {
+	resp := responses.FrameworkImage{
+		ID:            e.ID,
+		Image:         e.Image,
+		Digest:        e.Digest,
+		CanaryProject: e.CanaryProject,
+		State:         frameworkImageState(e),
+		Pinned:        pinned,
+		CreatedBy:     e.CreatedBy,
+		CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339),
+		PromotedBy:    e.PromotedBy,
+		AbortedBy:     e.AbortedBy,
+	}
+	if e.PromotedAt != nil {
+		resp.PromotedAt = e.PromotedAt.UTC().Format(time.RFC3339)
+	}
+	if e.AbortedAt != nil {
+		resp.AbortedAt = e.AbortedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/internal/validations"
+)
+
+// FrameworkDefinition registers a framework with the config, without code
+// changes. Its commands can use the command variables and the framework
+// parameters of requests as {{.Parameters.<name>}}.
+type FrameworkDefinition struct {
+	// Image is the execute container image of workflows not setting the
+	// execute_container_image_uri parameter.
+	Image string `yaml:"image"`
+	// Commands are the command templates per type, e.g. diff and sync.
+	Commands map[string]string `yaml:"commands"`
+	// Parameters are the framework parameters requests can set.
+	Parameters map[string]FrameworkParameter `yaml:"parameters"`
+	// EnvironmentVariables are the environment variables requests must set.
+	EnvironmentVariables []string `yaml:"environment_variables"`
+}
+
+// FrameworkParameter is a framework parameter of a FrameworkDefinition.
+type FrameworkParameter struct {
+	Required bool `yaml:"required"`
+	// Pattern is a regular expression values must match. Values are part of
+	// the command, so they're limited to safeParameterValueRegex as well.
+	Pattern     string `yaml:"pattern"`
+	Description string `yaml:"description"`
+}
+
+var (
+	frameworkNameRegex      = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	parameterNameRegex      = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	envVarNameRegex         = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	safeParameterValueRegex = regexp.MustCompile(`^[A-Za-z0-9._/:=@,+-]*$`)
+)
+
+// registerFrameworks validates the framework definitions and adds their
+// commands to the commands of the config.
+func (c *Config) registerFrameworks() error {
+	names := make([]string, 0, len(c.Frameworks))
+	for name := range c.Frameworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def := c.Frameworks[name]
+		if err := def.validate(name); err != nil {
+			return fmt.Errorf("framework '%s': %w", name, err)
+		}
+		if _, ok := c.Commands[name]; ok {
+			return fmt.Errorf("framework '%s': already defined by commands", name)
+		}
+
+		if c.Commands == nil {
+			c.Commands = map[string]map[string]string{}
+		}
+		c.Commands[name] = def.Commands
+	}
+	return nil
+}
+
+func (def FrameworkDefinition) validate(name string) error {
+	if !frameworkNameRegex.MatchString(name) {
+		return errors.New("name must be lowercase alphanumeric dash")
+	}
+	if def.Image != "" && !validations.IsValidImageURI(def.Image) {
+		return fmt.Errorf("image '%s' must be a valid container uri", def.Image)
+	}
+	if len(def.Commands) == 0 {
+		return errors.New("commands are required")
+	}
+
+	params := map[string]string{}
+	for p, fp := range def.Parameters {
+		if !parameterNameRegex.MatchString(p) {
+			return fmt.Errorf("parameter '%s' must be lowercase alphanumeric underscore", p)
+		}
+		if fp.Pattern != "" {
+			if _, err := regexp.Compile(fp.Pattern); err != nil {
+				return fmt.Errorf("parameter '%s' pattern: %w", p, err)
+			}
+		}
+		params[p] = "value"
+	}
+	for _, e := range def.EnvironmentVariables {
+		if !envVarNameRegex.MatchString(e) {
+			return fmt.Errorf("environment variable '%s' must be alphanumeric underscore", e)
+		}
+	}
+
+	// Commands are executed with every parameter set, so references to
+	// unknown variables or parameters fail at load time.
+	for commandType, command := range def.Commands {
+		t, err := template.New(commandType).Option("missingkey=error").Parse(command)
+		if err != nil {
+			return fmt.Errorf("command '%s': %w", commandType, err)
+		}
+		if err := t.Execute(&bytes.Buffer{}, CommandVariables{Parameters: params}); err != nil {
+			return fmt.Errorf("command '%s': %w", commandType, err)
+		}
+	}
+	return nil
+}
+
+// applyFrameworkDefaults sets the image of the framework for the platform of
+// the target, unless the request sets one. The image variant of the platform
+// (linux/amd64 when empty) of the framework_images config takes precedence
+// over the image of the framework definition, frameworks with variants must
+// have one for the platform.
+func (c Config) applyFrameworkDefaults(cwr *requests.CreateWorkflow, platform string) error {
+	if _, ok := cwr.Parameters["execute_container_image_uri"]; ok {
+		return nil
+	}
+
+	image := c.Frameworks[cwr.Framework].Image
+	if images, ok := c.FrameworkImages[cwr.Framework]; ok {
+		if platform == "" {
+			platform = types.DefaultPlatform
+		}
+		variant, ok := images[platform]
+		if !ok {
+			return fmt.Errorf("the %s framework has no image for platform %s", cwr.Framework, platform)
+		}
+		image = variant
+	}
+	if image == "" {
+		return nil
+	}
+
+	if cwr.Parameters == nil {
+		cwr.Parameters = map[string]string{}
+	}
+	cwr.Parameters["execute_container_image_uri"] = image
+	return nil
+}
+
+// validateFrameworkImages validates the image variants of the
+// framework_images config, which must be of known frameworks and platforms.
+func (c Config) validateFrameworkImages() error {
+	frameworks := make([]string, 0, len(c.FrameworkImages))
+	for fw := range c.FrameworkImages {
+		frameworks = append(frameworks, fw)
+	}
+	sort.Strings(frameworks)
+
+	for _, fw := range frameworks {
+		if _, ok := c.Commands[fw]; !ok {
+			return fmt.Errorf("framework_images: unknown framework '%s'", fw)
+		}
+		for platform, image := range c.FrameworkImages[fw] {
+			if !types.IsValidPlatform(platform) {
+				return fmt.Errorf("framework_images: %s platform must be one of '%s'", fw, strings.Join(types.Platforms, " "))
+			}
+			if !validations.IsValidImageURI(image) {
+				return fmt.Errorf("framework_images: %s image '%s' must be a valid container uri", fw, image)
+			}
+		}
+	}
+	return nil
+}
+
+// validateFrameworkRequest is an optional validation of
+// requests.CreateWorkflow, validating the framework parameters and
+// environment variables against the framework definition.
+func (c Config) validateFrameworkRequest(cwr requests.CreateWorkflow) func() error {
+	return func() error {
+		def, ok := c.Frameworks[cwr.Framework]
+		if !ok {
+			if len(cwr.FrameworkParameters) > 0 {
+				return fmt.Errorf("framework_parameters are not supported by the %s framework", cwr.Framework)
+			}
+			return nil
+		}
+
+		for _, e := range def.EnvironmentVariables {
+			if _, ok := cwr.EnvironmentVariables[e]; !ok {
+				return fmt.Errorf("environment variable %s is required by the %s framework", e, cwr.Framework)
+			}
+		}
+
+		names := make([]string, 0, len(cwr.FrameworkParameters))
+		for p := range cwr.FrameworkParameters {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		for _, p := range names {
+			if _, ok := def.Parameters[p]; !ok {
+				return fmt.Errorf("framework parameter %s is not supported by the %s framework", p, cwr.Framework)
+			}
+		}
+
+		names = names[:0]
+		for p := range def.Parameters {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		for _, p := range names {
+			fp := def.Parameters[p]
+			v, ok := cwr.FrameworkParameters[p]
+			if !ok {
+				if fp.Required {
+					return fmt.Errorf("framework parameter %s is required", p)
+				}
+				continue
+			}
+			if !safeParameterValueRegex.MatchString(v) {
+				return fmt.Errorf("framework parameter %s must be alphanumeric or one of '._/:=@,+-'", p)
+			}
+			if fp.Pattern != "" && !regexp.MustCompile(fp.Pattern).MatchString(v) {
+				return fmt.Errorf("framework parameter %s must match '%s'", p, fp.Pattern)
+			}
+		}
+		return nil
+	}
+}
+
+// frameworkParameterValues returns the framework parameters of the request
+// for its command, empty for the unset parameters of the framework
+// definition.
+func (c Config) frameworkParameterValues(cwr requests.CreateWorkflow) map[string]string {
+	def, ok := c.Frameworks[cwr.Framework]
+	if !ok {
+		return nil
+	}
+
+	values := make(map[string]string, len(def.Parameters))
+	for p := range def.Parameters {
+		values[p] = cwr.FrameworkParameters[p]
+	}
+	return values
+}
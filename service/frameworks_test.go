@@ -0,0 +1,239 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterFrameworks(t *testing.T) {
+	command := map[string]string{"sync": "{{.EnvironmentVariables}} run {{.Parameters.stack}} {{.ExecuteArguments}}"}
+	stack := map[string]FrameworkParameter{"stack": {Required: true}}
+
+	tests := []struct {
+		name       string
+		commands   map[string]map[string]string
+		frameworks map[string]FrameworkDefinition
+		wantErr    string
+	}{
+		{
+			name:       "valid",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Image: "argocloudops/pulumi:3.24.1", Commands: command, Parameters: stack}},
+		},
+		{
+			name:       "invalid_name",
+			frameworks: map[string]FrameworkDefinition{"Pulumi": {Commands: command, Parameters: stack}},
+			wantErr:    "framework 'Pulumi': name must be lowercase alphanumeric dash",
+		},
+		{
+			name:       "already_defined",
+			commands:   map[string]map[string]string{"pulumi": command},
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: command, Parameters: stack}},
+			wantErr:    "framework 'pulumi': already defined by commands",
+		},
+		{
+			name:       "missing_commands",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Parameters: stack}},
+			wantErr:    "framework 'pulumi': commands are required",
+		},
+		{
+			name:       "invalid_template",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: map[string]string{"sync": "run {{.Parameters.stack"}, Parameters: stack}},
+			wantErr:    "framework 'pulumi': command 'sync': template: sync:1: unclosed action",
+		},
+		{
+			name:       "unknown_parameter",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: command}},
+			wantErr:    `framework 'pulumi': command 'sync': template: sync:1:43: executing "sync" at <.Parameters.stack>: map has no entry for key "stack"`,
+		},
+		{
+			name:       "unknown_variable",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: map[string]string{"sync": "run {{.Stack}}"}}},
+			wantErr:    `framework 'pulumi': command 'sync': template: sync:1:6: executing "sync" at <.Stack>: can't evaluate field Stack in type main.CommandVariables`,
+		},
+		{
+			name:       "invalid_pattern",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: command, Parameters: map[string]FrameworkParameter{"stack": {Pattern: "^[a-z"}}}},
+			wantErr:    "framework 'pulumi': parameter 'stack' pattern: error parsing regexp: missing closing ]: `[a-z`",
+		},
+		{
+			name:       "invalid_environment_variable",
+			frameworks: map[string]FrameworkDefinition{"pulumi": {Commands: command, Parameters: stack, EnvironmentVariables: []string{"PULUMI-URL"}}},
+			wantErr:    "framework 'pulumi': environment variable 'PULUMI-URL' must be alphanumeric underscore",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Commands: tt.commands, Frameworks: tt.frameworks}
+			err := c.registerFrameworks()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			types, err := c.listTypes("pulumi")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"sync"}, types)
+		})
+	}
+}
+
+func TestValidateFrameworkRequest(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("Unable to load config %s", err)
+	}
+
+	env := map[string]string{"PULUMI_BACKEND_URL": "s3://state"}
+	tests := []struct {
+		name    string
+		cwr     requests.CreateWorkflow
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cwr:  requests.CreateWorkflow{Framework: "pulumi", EnvironmentVariables: env, FrameworkParameters: map[string]string{"stack": "prod"}},
+		},
+		{
+			name:    "missing_environment_variable",
+			cwr:     requests.CreateWorkflow{Framework: "pulumi", FrameworkParameters: map[string]string{"stack": "prod"}},
+			wantErr: "environment variable PULUMI_BACKEND_URL is required by the pulumi framework",
+		},
+		{
+			name:    "missing_parameter",
+			cwr:     requests.CreateWorkflow{Framework: "pulumi", EnvironmentVariables: env},
+			wantErr: "framework parameter stack is required",
+		},
+		{
+			name:    "unknown_parameter",
+			cwr:     requests.CreateWorkflow{Framework: "pulumi", EnvironmentVariables: env, FrameworkParameters: map[string]string{"stack": "prod", "region": "us-west-2"}},
+			wantErr: "framework parameter region is not supported by the pulumi framework",
+		},
+		{
+			name:    "unsafe_value",
+			cwr:     requests.CreateWorkflow{Framework: "pulumi", EnvironmentVariables: env, FrameworkParameters: map[string]string{"stack": "prod;rm"}},
+			wantErr: "framework parameter stack must be alphanumeric or one of '._/:=@,+-'",
+		},
+		{
+			name:    "pattern_mismatch",
+			cwr:     requests.CreateWorkflow{Framework: "pulumi", EnvironmentVariables: env, FrameworkParameters: map[string]string{"stack": "Prod"}},
+			wantErr: "framework parameter stack must match '^[a-z0-9-]+$'",
+		},
+		{
+			name:    "built_in_framework",
+			cwr:     requests.CreateWorkflow{Framework: "cdk", FrameworkParameters: map[string]string{"stack": "prod"}},
+			wantErr: "framework_parameters are not supported by the cdk framework",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config.validateFrameworkRequest(tt.cwr)()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestApplyFrameworkDefaults(t *testing.T) {
+	config, err := loadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("Unable to load config %s", err)
+	}
+
+	tests := []struct {
+		name      string
+		cwr       requests.CreateWorkflow
+		platform  string
+		wantImage string
+		wantErr   string
+	}{
+		{
+			name:      "default_platform",
+			cwr:       requests.CreateWorkflow{Framework: "pulumi"},
+			wantImage: "argocloudops/argo-cloudops-pulumi:3.24.1",
+		},
+		{
+			name:      "platform_variant",
+			cwr:       requests.CreateWorkflow{Framework: "pulumi"},
+			platform:  "linux/arm64",
+			wantImage: "argocloudops/argo-cloudops-pulumi:3.24.1-arm64",
+		},
+		{
+			name:     "no_platform_variant",
+			cwr:      requests.CreateWorkflow{Framework: "pulumi"},
+			platform: "windows/amd64",
+			wantErr:  "the pulumi framework has no image for platform windows/amd64",
+		},
+		{
+			name:      "request_image",
+			cwr:       requests.CreateWorkflow{Framework: "pulumi", Parameters: map[string]string{"execute_container_image_uri": "argocloudops/pulumi:latest"}},
+			platform:  "windows/amd64",
+			wantImage: "argocloudops/pulumi:latest",
+		},
+		{
+			name: "no_image",
+			cwr:  requests.CreateWorkflow{Framework: "terraform"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config.applyFrameworkDefaults(&tt.cwr, tt.platform)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantImage, tt.cwr.Parameters["execute_container_image_uri"])
+		})
+	}
+}
+
+func TestValidateFrameworkImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		images  map[string]map[string]string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			images: map[string]map[string]string{"terraform": {"linux/arm64": "argocloudops/terraform:1.1.0-arm64"}},
+		},
+		{
+			name:    "unknown_framework",
+			images:  map[string]map[string]string{"pulumi": {"linux/arm64": "argocloudops/pulumi:3.24.1"}},
+			wantErr: "framework_images: unknown framework 'pulumi'",
+		},
+		{
+			name:    "invalid_platform",
+			images:  map[string]map[string]string{"terraform": {"darwin/arm64": "argocloudops/terraform:1.1.0"}},
+			wantErr: "framework_images: terraform platform must be one of 'linux/amd64 linux/arm64 windows/amd64'",
+		},
+		{
+			name:    "invalid_image",
+			images:  map[string]map[string]string{"terraform": {"linux/arm64": "terraform image"}},
+			wantErr: "framework_images: terraform image 'terraform image' must be a valid container uri",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				Commands:        map[string]map[string]string{"terraform": {"sync": "terraform apply"}},
+				FrameworkImages: tt.images,
+			}
+			err := config.validateFrameworkImages()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/graphql"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+const (
+	// defaultGraphQLExecutions is the number of executions of a target
+	// returned when the executions field has no limit.
+	defaultGraphQLExecutions = 5
+	// maxGraphQLExecutions is the maximum limit of the executions field.
+	maxGraphQLExecutions = 50
+)
+
+// Executes a read-only GraphQL query over the projects, their targets and
+// their executions, letting clients fetch nested data in one round trip.
+func (h handler) graphQL(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "graphql")
+
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	// Admins can read every project, other users only their own.
+	projectName := ""
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+			return
+		}
+
+		identity, err := cp.Identity()
+		if errors.Is(err, credentials.ErrInvalidCredentials) || (err == nil && identity.Project == "") {
+			h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error authorizing project", "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error authorizing project", http.StatusInternalServerError)
+			return
+		}
+		projectName = identity.Project
+		l = log.With(l, "project", projectName)
+	}
+
+	// Targets are read with the admin credentials, the project the caller can
+	// read was checked above.
+	level.Debug(l).Log("message", "creating admin credentials provider")
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var req graphql.Request
+	if err := json.Unmarshal(reqBody, &req); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Query == "" {
+		h.errorResponse(w, "invalid request, query is required", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "executing query", "operation", req.OperationName)
+	resp := graphql.Execute(rs.ctx, graphQLQuery{h: h, cp: cp, project: projectName}, req)
+	for _, e := range resp.Errors {
+		level.Debug(l).Log("message", "query error", "error", e.Message, "path", fmt.Sprint(e.Path))
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// graphQLQuery is the Query type, the root of the schema. project is the
+// only project which can be read, or empty when all of them can.
+type graphQLQuery struct {
+	h       handler
+	cp      credentials.Provider
+	project string
+}
+
+func (q graphQLQuery) TypeName() string { return "Query" }
+
+func (q graphQLQuery) Field(ctx context.Context, name string, args graphql.Args) (interface{}, error) {
+	switch name {
+	case "projects":
+		if q.project != "" {
+			e, err := q.h.dbClient.ReadProjectEntry(ctx, q.project)
+			if errors.Is(err, upper.ErrNoMoreRows) {
+				return []graphql.Object{}, nil
+			}
+			if err != nil {
+				return nil, errors.New("error retrieving project")
+			}
+			return []graphql.Object{&graphQLProject{q: q, name: q.project, repository: e.Repository}}, nil
+		}
+		entries, err := q.h.dbClient.ListProjectEntries(ctx)
+		if err != nil {
+			return nil, errors.New("error listing projects")
+		}
+		projects := make([]graphql.Object, 0, len(entries))
+		for _, e := range entries {
+			projects = append(projects, &graphQLProject{q: q, name: e.ProjectID, repository: e.Repository})
+		}
+		return projects, nil
+	case "project":
+		projectName, err := args.String("name")
+		if err != nil {
+			return nil, err
+		}
+		// Other projects are null, like projects which don't exist.
+		if q.project != "" && projectName != q.project {
+			return nil, nil
+		}
+		e, err := q.h.dbClient.ReadProjectEntry(ctx, projectName)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.New("error retrieving project")
+		}
+		return &graphQLProject{q: q, name: projectName, repository: e.Repository}, nil
+	}
+	return nil, graphql.ErrUnknownField
+}
+
+// graphQLProject is the Project type.
+type graphQLProject struct {
+	q          graphQLQuery
+	name       string
+	repository string
+}
+
+func (p *graphQLProject) TypeName() string { return "Project" }
+
+func (p *graphQLProject) Field(ctx context.Context, name string, args graphql.Args) (interface{}, error) {
+	switch name {
+	case "name":
+		return p.name, nil
+	case "repository":
+		return p.repository, nil
+	case "targets":
+		names, err := p.q.cp.ListTargets(p.name)
+		if err != nil {
+			return nil, errors.New("error listing targets")
+		}
+		targets := make([]graphql.Object, 0, len(names))
+		for _, n := range names {
+			targets = append(targets, &graphQLTarget{project: p, name: n})
+		}
+		return targets, nil
+	case "target":
+		targetName, err := args.String("name")
+		if err != nil {
+			return nil, err
+		}
+		exists, err := p.q.cp.TargetExists(p.name, targetName)
+		if err != nil {
+			return nil, errors.New("error checking target")
+		}
+		if !exists {
+			return nil, nil
+		}
+		return &graphQLTarget{project: p, name: targetName}, nil
+	}
+	return nil, graphql.ErrUnknownField
+}
+
+// graphQLTarget is the Target type. Its properties and execution notes are
+// read once, when first selected.
+type graphQLTarget struct {
+	project *graphQLProject
+	name    string
+	target  *types.Target
+	notes   *executionNotes
+}
+
+func (t *graphQLTarget) TypeName() string { return "Target" }
+
+func (t *graphQLTarget) Field(ctx context.Context, name string, args graphql.Args) (interface{}, error) {
+	switch name {
+	case "name":
+		return t.name, nil
+	case "project":
+		return t.project.name, nil
+	case "type":
+		if t.target == nil {
+			target, err := t.project.q.cp.GetTarget(t.project.name, t.name)
+			if errors.Is(err, credentials.ErrNotFound) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, errors.New("error retrieving target")
+			}
+			t.target = &target
+		}
+		return t.target.Type, nil
+	case "executions":
+		limit, err := args.Int("limit", defaultGraphQLExecutions)
+		if err != nil {
+			return nil, err
+		}
+		if limit < 0 || limit > maxGraphQLExecutions {
+			return nil, fmt.Errorf("argument \"limit\" must be between 0 and %d", maxGraphQLExecutions)
+		}
+		return t.executions(ctx, limit)
+	}
+	return nil, graphql.ErrUnknownField
+}
+
+// Returns the latest executions of the target, newest first.
+func (t *graphQLTarget) executions(ctx context.Context, limit int) ([]graphql.Object, error) {
+	statuses, err := t.project.q.h.argo.ListByLabels(ctx, map[string]string{
+		workflow.LabelProject: t.project.name,
+		workflow.LabelTarget:  t.name,
+	})
+	if err != nil {
+		return nil, errors.New("error listing workflows")
+	}
+
+	created := func(s workflow.Status) int64 {
+		c, _ := strconv.ParseInt(s.Created, 10, 64)
+		return c
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return created(statuses[i]) > created(statuses[j])
+	})
+	if len(statuses) > limit {
+		statuses = statuses[:limit]
+	}
+
+	executions := make([]graphql.Object, 0, len(statuses))
+	for _, s := range statuses {
+		executions = append(executions, graphQLExecution{target: t, status: s})
+	}
+	return executions, nil
+}
+
+func (t *graphQLTarget) executionNotes(ctx context.Context) (executionNotes, error) {
+	if t.notes == nil {
+		notes, err := t.project.q.h.targetExecutionNotes(ctx, t.project.name, t.name)
+		if err != nil {
+			return executionNotes{}, errors.New("error reading execution notes")
+		}
+		t.notes = &notes
+	}
+	return *t.notes, nil
+}
+
+// graphQLExecution is the Execution type.
+type graphQLExecution struct {
+	target *graphQLTarget
+	status workflow.Status
+}
+
+func (e graphQLExecution) TypeName() string { return "Execution" }
+
+func (e graphQLExecution) Field(ctx context.Context, name string, args graphql.Args) (interface{}, error) {
+	switch name {
+	case "name":
+		return e.status.Name, nil
+	case "status":
+		return e.status.Status, nil
+	case "created":
+		return e.status.Created, nil
+	case "finished":
+		return e.status.Finished, nil
+	case "knownBad":
+		notes, err := e.target.executionNotes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return notes.knownBad[e.status.Name], nil
+	case "notes":
+		notes, err := e.target.executionNotes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects := []graphql.Object{}
+		for _, n := range notes.notes[e.status.Name] {
+			objects = append(objects, graphQLExecutionNote(n))
+		}
+		return objects, nil
+	}
+	return nil, graphql.ErrUnknownField
+}
+
+// graphQLExecutionNote is the ExecutionNote type.
+type graphQLExecutionNote responses.ExecutionNote
+
+func (n graphQLExecutionNote) TypeName() string { return "ExecutionNote" }
+
+func (n graphQLExecutionNote) Field(ctx context.Context, name string, args graphql.Args) (interface{}, error) {
+	switch name {
+	case "author":
+		return n.Author, nil
+	case "note":
+		return n.Note, nil
+	case "incidentUrl":
+		return n.IncidentURL, nil
+	case "knownBad":
+		if n.KnownBad == nil {
+			return nil, nil
+		}
+		return *n.KnownBad, nil
+	case "createdAt":
+		return n.CreatedAt, nil
+	}
+	return nil, graphql.ErrUnknownField
+}
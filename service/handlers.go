@@ -3,33 +3,73 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/anomaly"
+	"github.com/cello-proj/cello/service/internal/audit"
+	"github.com/cello-proj/cello/service/internal/changeset"
+	"github.com/cello-proj/cello/service/internal/circuitbreaker"
+	"github.com/cello-proj/cello/service/internal/clock"
 	"github.com/cello-proj/cello/service/internal/credentials"
 	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/discovery"
 	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/feature"
 	"github.com/cello-proj/cello/service/internal/git"
+	"github.com/cello-proj/cello/service/internal/guardrail"
+	"github.com/cello-proj/cello/service/internal/health"
+	"github.com/cello-proj/cello/service/internal/inventory"
+	"github.com/cello-proj/cello/service/internal/itsm"
+	"github.com/cello-proj/cello/service/internal/logarchive"
+	"github.com/cello-proj/cello/service/internal/manifest"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+	"github.com/cello-proj/cello/service/internal/oci"
+	"github.com/cello-proj/cello/service/internal/recorder"
+	"github.com/cello-proj/cello/service/internal/redact"
+	"github.com/cello-proj/cello/service/internal/schedule"
+	"github.com/cello-proj/cello/service/internal/secretscan"
+	"github.com/cello-proj/cello/service/internal/usage"
+	"github.com/cello-proj/cello/service/internal/webhook"
 	"github.com/cello-proj/cello/service/internal/workflow"
+	"github.com/cello-proj/cello/service/internal/workload"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"gopkg.in/yaml.v2"
+	upper "github.com/upper/db/v4"
 )
 
 // Represents a JWT token.
 type token struct {
 	Token string `json:"token"`
+	// ID is the stable ID of the project the token was created with.
+	ID string `json:"id,omitempty"`
 }
 
-// Represents an error response.
+// Represents an error response. Stage and limit are set when the request
+// exceeded the limit of a stage, e.g. the git fetch timeout.
 type errorResponse struct {
 	ErrorMessage string `json:"error_message"`
+	Stage        string `json:"stage,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+	// Code and Params are the ID and the parameters of the message of the
+	// catalog, when the error message is one, see messageResponse.
+	Code   string          `json:"code,omitempty"`
+	Params messages.Params `json:"params,omitempty"`
+	// Support is added to 5xx error responses, see supportMiddleware.
+	Support *responses.SupportContact `json:"support,omitempty"`
 }
 
 // Generates error response JSON.
@@ -42,7 +82,9 @@ func generateErrorResponseJSON(message string) string {
 	return string(jsonData)
 }
 
-// HTTP handler
+// HTTP handler, holding the dependencies shared by all requests. Handlers use
+// the scope of their request (see requestScope) for request specific ones,
+// argoCtx is only used by work outliving requests.
 type handler struct {
 	logger                 log.Logger
 	newCredentialsProvider func(a credentials.Authorization, env env.Vars, h http.Header, vaultConfig credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error)
@@ -50,78 +92,412 @@ type handler struct {
 	argoCtx                context.Context
 	config                 *Config
 	gitClient              git.Client
+	ociClient              oci.Client
 	env                    env.Vars
 	dbClient               db.Client
+	breakers               []*circuitbreaker.Breaker
+	dependencyChecks       map[string]health.Check
+	guardrails             *guardrail.Monitor
+	itsm                   itsm.Client
+	audit                  *audit.Forwarder
+	anomalies              anomaly.Detector
+	notifications          *notify.Watcher
+	webhooks               *webhook.Sender
+	cron                   workflow.CronWorkflows
+	features               feature.Flags
+	recorder               *recorder.Recorder
+	redactor               *redact.Redactor
+	logArchive             logarchive.Store
+	messages               *messages.Catalog
+	secretScanner          secretscan.Scanner
+	secretScanSalt         []byte
+	serviceAccounts        workload.ServiceAccounts
+	usage                  usage.Reporter
+	publicStats            *publicStatsCache
+	clock                  clock.Clock
+}
+
+// now returns the time of the clock of the handler.
+func (h handler) now() time.Time {
+	return h.clock.Now()
 }
 
 // Service HealthCheck
 func (h *handler) healthCheck(w http.ResponseWriter, r *http.Request) {
-	vaultEndpoint := fmt.Sprintf("%s/v1/sys/health", h.env.VaultAddress)
-	l := h.requestLogger(r, "op", "health-check", "vault-endpoint", vaultEndpoint)
+	rs := h.scope(r)
+	l := rs.log("op", "health-check", "vault-endpoint", h.env.VaultAddress)
 
-	// #nosec
-	response, err := http.Get(vaultEndpoint)
-	if err != nil {
-		level.Error(l).Log("message", "received error connecting to vault", "error", err)
+	name, check := h.credentialsCheck()
+	if err := check(rs.ctx); err != nil {
+		level.Error(l).Log("message", fmt.Sprintf("%s health check failed", name), "error", err)
 		w.WriteHeader(http.StatusServiceUnavailable)
 		fmt.Fprintln(w, "Health check failed")
 		return
 	}
 
-	// We don't care about the body but need to read it all and close it
-	// regardless.
-	// https://golang.org/pkg/net/http/#Client.Do
-	defer response.Body.Close()
-	_, err = ioutil.ReadAll(response.Body)
-	if err != nil {
-		level.Warn(l).Log("message", "unable to read vault body; continuing", "error", err)
-		// Continue on and handle the actual response code from Vault accordingly.
+	fmt.Fprintln(w, "Health check succeeded")
+}
+
+// Represents the readiness of each dependency.
+type readyzResponse struct {
+	Status          string            `json:"status"`
+	Checks          map[string]string `json:"checks"`
+	CircuitBreakers map[string]string `json:"circuit_breakers,omitempty"`
+}
+
+// Service readiness, reporting the same per dependency results as the gRPC
+// health service.
+func (h *handler) readyz(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "readyz")
+
+	results := h.dependencyChecker().Run(rs.ctx)
+
+	resp := readyzResponse{Status: "ok", Checks: map[string]string{}}
+	for name, err := range results {
+		if err != nil {
+			level.Error(l).Log("message", "dependency not ready", "dependency", name, "error", err)
+			resp.Checks[name] = "failed"
+			continue
+		}
+		resp.Checks[name] = "ok"
 	}
 
-	if response.StatusCode != 200 && response.StatusCode != 429 {
-		level.Error(l).Log("message", fmt.Sprintf("received code %d which is not 200 (initialized, unsealed, and active) or 429 (unsealed and standby) when connecting to vault", response.StatusCode))
+	if len(h.breakers) > 0 {
+		resp.CircuitBreakers = map[string]string{}
+		for _, b := range h.breakers {
+			resp.CircuitBreakers[b.Name()] = b.State().String()
+		}
+	}
+
+	if !health.Healthy(results) {
+		resp.Status = "failed"
 		w.WriteHeader(http.StatusServiceUnavailable)
-		fmt.Fprintln(w, "Health check failed")
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing readiness", "error", err)
 		return
 	}
+	fmt.Fprint(w, string(jsonData))
+}
 
-	fmt.Fprintln(w, "Health check succeeded")
+// dependencyChecker returns the dependency checks used for readiness, the
+// credentials store and the dependencyChecks of the handler.
+func (h *handler) dependencyChecker() *health.Checker {
+	c := health.NewChecker()
+	c.Register(h.credentialsCheck())
+	for name, check := range h.dependencyChecks {
+		c.Register(name, check)
+	}
+	return c
+}
+
+// credentialsCheck returns the name and check of the store of the
+// credentials provider, Vault or Secrets Manager.
+func (h *handler) credentialsCheck() (string, health.Check) {
+	if h.env.CredentialsProvider == env.CredentialsProviderAWSSecretsManager {
+		return "secrets_manager", func(ctx context.Context) error {
+			return credentials.PingSecretsManager(h.env)
+		}
+	}
+	vault := discovery.For(h.env.VaultAddress)
+	return "vault", health.NewVaultCheck(vault.URL(), vault.Client(0))
+}
+
+// Windows of the admin stats, in hours.
+const (
+	defaultStatsWindowHours = 24
+	maxStatsWindowHours     = 7 * 24
+	// Number of projects returned as top projects.
+	statsTopProjects = 10
+)
+
+// Gets system wide stats. Workflow stats are of the workflows submitted in
+// the window which Argo still has, latencies are of recent calls through the
+// circuit breakers.
+func (h handler) getAdminStats(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-admin-stats")
+
+	level.Debug(l).Log("message", "validating authorization header for admin stats")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	windowHours, ok := h.statsWindowHours(w, r)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	resp, ok := h.systemStats(rs.ctx, w, l, cp, windowHours)
+	if !ok {
+		return
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing admin stats", "error", err)
+		h.errorResponse(w, "error serializing admin stats", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// statsWindowHours returns the window of stats requests (hours query
+// parameter), writing the error response when invalid.
+func (h handler) statsWindowHours(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("hours")
+	if v == "" {
+		return defaultStatsWindowHours, true
+	}
+	windowHours, err := strconv.Atoi(v)
+	if err != nil || windowHours < 1 || windowHours > maxStatsWindowHours {
+		h.errorResponse(w, fmt.Sprintf("invalid request, hours must be between 1 and %d", maxStatsWindowHours), http.StatusBadRequest)
+		return 0, false
+	}
+	return windowHours, true
+}
+
+// systemStats returns the stats of the window, listing targets with the
+// credentials provider, writing the error response otherwise.
+func (h handler) systemStats(ctx context.Context, w http.ResponseWriter, l log.Logger, cp credentials.Provider, windowHours int) (responses.GetAdminStats, bool) {
+	level.Debug(l).Log("message", "listing projects")
+	projects, err := h.dbClient.ListProjectEntries(ctx)
+	if err != nil {
+		level.Error(l).Log("message", "error listing projects", "error", err)
+		h.errorResponse(w, "error listing projects", http.StatusInternalServerError)
+		return responses.GetAdminStats{}, false
+	}
+
+	// Hours are counted back from the current hour, oldest first.
+	currentHour := h.now().UTC().Truncate(time.Hour)
+	windowStart := currentHour.Add(-time.Duration(windowHours-1) * time.Hour)
+	hourly := make([]int, windowHours)
+
+	resp := responses.GetAdminStats{
+		Projects:           len(projects),
+		WindowHours:        windowHours,
+		SubmissionsPerHour: []responses.HourlySubmissions{},
+		Latencies:          map[string]responses.LatencyStats{},
+		TopProjects:        []responses.ProjectStats{},
+	}
+
+	for _, p := range projects {
+		targets, err := cp.ListTargets(p.ProjectID)
+		if err != nil {
+			level.Error(l).Log("message", "error listing targets", "project", p.ProjectID, "error", err)
+			h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+			return responses.GetAdminStats{}, false
+		}
+		resp.Targets += len(targets)
+
+		statuses, err := h.argo.ListByLabels(ctx, map[string]string{workflow.LabelProject: p.ProjectID})
+		if err != nil {
+			level.Error(l).Log("message", "error listing workflows", "project", p.ProjectID, "error", err)
+			h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
+			return responses.GetAdminStats{}, false
+		}
+
+		var projectStats responses.WorkflowStats
+		for _, s := range statuses {
+			created, err := strconv.ParseInt(s.Created, 10, 64)
+			if err != nil || time.Unix(created, 0).Before(windowStart) {
+				continue
+			}
+			// Workflows created after now (clock skew) count in the current hour.
+			hour := int(time.Unix(created, 0).Sub(windowStart) / time.Hour)
+			if hour >= windowHours {
+				hour = windowHours - 1
+			}
+			hourly[hour]++
+			addWorkflowStats(&projectStats, s.Status)
+		}
+		if projectStats.Submitted == 0 {
+			continue
+		}
+
+		resp.Workflows.Submitted += projectStats.Submitted
+		resp.Workflows.Active += projectStats.Active
+		resp.Workflows.Succeeded += projectStats.Succeeded
+		resp.Workflows.Failed += projectStats.Failed
+		resp.TopProjects = append(resp.TopProjects, responses.ProjectStats{
+			Project:     p.ProjectID,
+			Submitted:   projectStats.Submitted,
+			FailureRate: failureRate(projectStats),
+		})
+	}
+	resp.Workflows.FailureRate = failureRate(resp.Workflows)
+
+	for i, n := range hourly {
+		resp.SubmissionsPerHour = append(resp.SubmissionsPerHour, responses.HourlySubmissions{
+			Hour:        windowStart.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			Submissions: n,
+		})
+	}
+
+	sort.SliceStable(resp.TopProjects, func(i, j int) bool {
+		return resp.TopProjects[i].Submitted > resp.TopProjects[j].Submitted
+	})
+	if len(resp.TopProjects) > statsTopProjects {
+		resp.TopProjects = resp.TopProjects[:statsTopProjects]
+	}
+
+	for _, b := range h.breakers {
+		resp.Latencies[b.Name()] = responses.LatencyStats{
+			P50Ms: durationMs(b.LatencyPercentile(50)),
+			P90Ms: durationMs(b.LatencyPercentile(90)),
+			P99Ms: durationMs(b.LatencyPercentile(99)),
+		}
+	}
+	return resp, true
+}
+
+// Counts a workflow with the status in the stats. Errored workflows count as
+// failed.
+func addWorkflowStats(s *responses.WorkflowStats, status string) {
+	s.Submitted++
+	switch {
+	case workflow.IsActive(status):
+		s.Active++
+	case status == "succeeded":
+		s.Succeeded++
+	default:
+		s.Failed++
+	}
+}
+
+// Returns the rate of failed workflows out of the completed ones, 0 when
+// none completed.
+func failureRate(s responses.WorkflowStats) float64 {
+	completed := s.Succeeded + s.Failed
+	if completed == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(completed)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// submissionIdentityLabels are the labels identifying the workflow of a
+// submission, looked up before retrying submissions which may have created it.
+var submissionIdentityLabels = []string{txIDHeader, workflow.LabelProject, workflow.LabelTarget, workflow.LabelType}
+
+// Maximum number of workflows of a page of List Workflows.
+const maxWorkflowListLimit = 500
+
+// listedWorkflows is a page of the workflows of a target.
+type listedWorkflows struct {
+	Workflows []listedWorkflow `json:"workflows"`
+	// Continue is the token of the following page, empty on the last one.
+	Continue string `json:"continue,omitempty"`
+}
+
+// workflowListOptions returns the page of workflows requested by the limit
+// and continue query parameters.
+func workflowListOptions(r *http.Request) (workflow.ListOptions, error) {
+	var opts workflow.ListOptions
+
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 || limit > maxWorkflowListLimit {
+			return workflow.ListOptions{}, fmt.Errorf("limit must be an integer between 1 and %d", maxWorkflowListLimit)
+		}
+		opts.Limit = limit
+	}
+	opts.Continue = q.Get("continue")
+	return opts, nil
 }
 
 // Lists workflows
 func (h handler) listWorkflows(w http.ResponseWriter, r *http.Request) {
 	// TODO authenticate user can list this workflow once auth figured out
 	// TODO fail if project / target does not exist or are not valid format
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+	projectName := rs.project
 	targetName := vars["targetName"]
 
-	l := h.requestLogger(r, "op", "list-workflows", "project", projectName, "target", targetName)
+	l := rs.log("op", "list-workflows", "project", projectName, "target", targetName)
+
+	opts, err := workflowListOptions(r)
+	if err != nil {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Workflows submitted before the project was renamed are labeled with
+	// its former names.
+	aliases, err := h.dbClient.ListProjectAliasEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error listing project aliases", "error", err)
+		h.errorResponse(w, "error listing project aliases", http.StatusInternalServerError)
+		return
+	}
+	projectNames := []string{projectName}
+	for _, alias := range aliases {
+		if h.clock.Now().Before(alias.ExpiresAt) {
+			projectNames = append(projectNames, alias.Alias)
+		}
+	}
+	selector := map[string][]string{
+		workflow.LabelProject: projectNames,
+		workflow.LabelTarget:  {targetName},
+	}
 
-	level.Debug(l).Log("message", "listing workflows")
-	workflowIDs, err := h.argo.List(h.argoCtx)
+	level.Debug(l).Log("message", "listing workflows", "limit", opts.Limit)
+	statuses, next, err := h.argo.List(rs.ctx, selector, opts)
 	if err != nil {
 		level.Error(l).Log("message", "error listing workflows", "error", err)
 		h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
 		return
 	}
 
-	// Only return workflows the target project / target
-	var workflows []workflow.Status
-	prefix := fmt.Sprintf("%s-%s", projectName, targetName)
-	for _, workflowID := range workflowIDs {
-		if strings.HasPrefix(workflowID, prefix) {
-			workflow, err := h.argo.Status(h.argoCtx, workflowID)
-			if err != nil {
-				level.Error(l).Log("message", "error retrieving workflows", "error", err)
-				h.errorResponse(w, "error retrieving workflows", http.StatusInternalServerError)
-				return
-			}
-			workflows = append(workflows, *workflow)
+	notes, err := h.targetExecutionNotes(rs.ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading execution notes", "error", err)
+		h.errorResponse(w, "error reading execution notes", http.StatusInternalServerError)
+		return
+	}
+
+	var workflows []listedWorkflow
+	for _, status := range statuses {
+		workflows = append(workflows, listedWorkflow{
+			Status:   status,
+			KnownBad: notes.knownBad[status.Name],
+			Notes:    notes.notes[status.Name],
+		})
+	}
+
+	// Paginated requests get the page of workflows with the continue token
+	// of the following page, others only the workflows.
+	var resp interface{} = workflows
+	if opts != (workflow.ListOptions{}) {
+		if workflows == nil {
+			workflows = []listedWorkflow{}
 		}
+		resp = listedWorkflows{Workflows: workflows, Continue: next}
 	}
 
-	jsonData, err := json.Marshal(workflows)
+	jsonData, err := json.Marshal(resp)
 	if err != nil {
 		level.Error(l).Log("message", "error serializing workflow IDs", "error", err)
 		h.errorResponse(w, "error serializing workflow IDs", http.StatusInternalServerError)
@@ -132,33 +508,31 @@ func (h handler) listWorkflows(w http.ResponseWriter, r *http.Request) {
 }
 
 // Creates workflow init params by pulling manifest from given git repo, commit sha, and code path
-func (h handler) loadCreateWorkflowRequestFromGit(repository, commitHash, path string) (requests.CreateWorkflow, error) {
+func (h handler) loadCreateWorkflowRequestFromGit(ctx context.Context, repository, commitHash, path string, sel manifestSelection) (requests.CreateWorkflow, error) {
 	level.Debug(h.logger).Log("message", fmt.Sprintf("retrieving manifest from repository %s at sha %s with path %s", repository, commitHash, path))
-	fileContents, err := h.gitClient.GetManifestFile(repository, commitHash, path)
+	fileContents, err := h.gitClient.GetManifestFile(ctx, repository, commitHash, path)
 	if err != nil {
 		return requests.CreateWorkflow{}, err
 	}
 
-	var cwr requests.CreateWorkflow
-	err = yaml.Unmarshal(fileContents, &cwr)
-	return cwr, err
+	return sel.load(fileContents)
 }
 
 func (h handler) createWorkflowFromGit(w http.ResponseWriter, r *http.Request) {
-	l := h.requestLogger(r, "op", "create-workflow-from-git")
+	rs := h.scope(r)
+	l := rs.log("op", "create-workflow-from-git")
 
-	ctx := r.Context()
+	ctx := rs.ctx
 
 	level.Debug(l).Log("message", "validating authorization header for create workflow from git")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	a, err := rs.authorization()
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
 		return
 	}
 	// TODO we need to ensure this _isn't an admin...
 	if err := a.Validate(); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
 		return
 	}
 
@@ -184,8 +558,7 @@ func (h handler) createWorkflowFromGit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+	projectName := rs.project
 	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, projectName)
 	if err != nil {
 		level.Error(l).Log("message", "error reading project data", "error", err)
@@ -193,791 +566,4462 @@ func (h handler) createWorkflowFromGit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cwr, err := h.loadCreateWorkflowRequestFromGit(projectEntry.Repository, cgwr.CommitHash, cgwr.Path)
+	sel, ok := h.manifestSelection(rs, w, r, l, mux.Vars(r)["targetName"], cgwr.Name, cgwr.Template)
+	if !ok {
+		return
+	}
+
+	gitCtx, cancel := h.stageContext(ctx, stageGitFetch)
+	defer cancel()
+	gitStart := time.Now()
+	cwr, err := h.loadCreateWorkflowRequestFromGit(gitCtx, projectEntry.Repository, cgwr.CommitHash, cgwr.Path, sel)
+	recordStage(ctx, stageGitFetch, gitStart)
+	var perr *types.InvalidParameterError
+	if errors.Is(err, requests.ErrManifestSelection) || errors.Is(err, manifest.ErrRender) || errors.As(err, &perr) {
+		level.Error(l).Log("message", "error loading manifest workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		level.Error(l).Log("message", "error loading workflow data from git", "error", err)
-		h.errorResponse(w, "error loading workflow data from git", http.StatusInternalServerError)
+		h.stageErrorResponse(gitCtx, w, r, stageGitFetch, err, "error loading workflow data from git", http.StatusInternalServerError)
 		return
 	}
 
+	if cgwr.ChangeTicket != "" {
+		cwr.ChangeTicket = cgwr.ChangeTicket
+	}
+
 	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
 
 	level.Debug(l).Log("message", "creating workflow")
-	h.createWorkflowFromRequest(ctx, w, r, a, cwr, l)
+	h.createWorkflowFromRequest(rs, w, r, cwr, cgwr.CommitHash, projectEntry.Repository+":"+cgwr.Path, l)
 }
 
-// Creates a workflow
-func (h handler) createWorkflow(w http.ResponseWriter, r *http.Request) {
-	l := h.requestLogger(r, "op", "create-workflow")
+// Creates workflow init params from the manifest file of an OCI artifact
+func (h handler) loadCreateWorkflowRequestFromOCI(ctx context.Context, ref oci.Reference, creds *oci.Credentials, path string, sel manifestSelection) (requests.CreateWorkflow, error) {
+	level.Debug(h.logger).Log("message", fmt.Sprintf("retrieving manifest from artifact %s with path %s", ref, path))
+	fileContents, err := h.ociClient.GetManifestFile(ctx, ref, creds, path)
+	if err != nil {
+		return requests.CreateWorkflow{}, err
+	}
+
+	return sel.load(fileContents)
+}
 
-	ctx := r.Context()
+// Creates a workflow from the manifest of an OCI artifact, pulled with the
+// registry credentials of the project if it has some
+func (h handler) createWorkflowFromOCI(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
 
-	level.Debug(l).Log("message", "validating authorization header for create workflow")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	l := rs.log("op", "create-workflow-from-oci", "project", projectName, "target", targetName)
+
+	level.Debug(l).Log("message", "validating authorization header for create workflow from oci")
+	a, err := rs.authorization()
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
 		return
 	}
 	if err := a.Validate(); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
 		return
 	}
 
 	level.Debug(l).Log("message", "reading request body")
-	var cwr requests.CreateWorkflow
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		level.Error(l).Log("message", "error reading workflow request data", "error", err)
-		h.errorResponse(w, "error reading workflow request data", http.StatusInternalServerError)
-		return
-	}
-
-	if err := json.Unmarshal(reqBody, &cwr); err != nil {
-		level.Error(l).Log("message", "error deserializing workflow data", "error", err)
-		h.errorResponse(w, "error deserializing workflow data", http.StatusBadRequest)
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
 
-	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
-	level.Debug(l).Log("message", "creating workflow")
-	h.createWorkflowFromRequest(ctx, w, r, a, cwr, l)
-}
-
-// Creates a workflow
-// Context is not currently used as Argo has its own and Vault doesn't
-// currently support it.
-func (h handler) createWorkflowFromRequest(_ context.Context, w http.ResponseWriter, r *http.Request, a *credentials.Authorization, cwr requests.CreateWorkflow, l log.Logger) {
-	types, err := h.config.listTypes(cwr.Framework)
-	if err != nil {
-		level.Error(l).Log("message", "error invalid framework", "error", err)
-		h.errorResponse(
-			w,
-			fmt.Sprintf("invalid request, framework must be one of '%s'", strings.Join(h.config.listFrameworks(), " ")),
-			http.StatusBadRequest,
-		)
+	var cowr requests.CreateOCIWorkflow
+	if err := json.Unmarshal(reqBody, &cowr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "validating workflow parameters")
-	if err := cwr.Validate(
-		cwr.ValidateType(types),
-	); err != nil {
+	if err := cowr.Validate(); err != nil {
 		level.Error(l).Log("message", "error validating request", "error", err)
-		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
 
-	workflowFrom := fmt.Sprintf("workflowtemplate/%s", cwr.WorkflowTemplateName)
-	executeContainerImageURI := cwr.Parameters["execute_container_image_uri"]
-	environmentVariablesString := generateEnvVariablesString(cwr.EnvironmentVariables)
-
-	level.Debug(l).Log("message", "generating command to execute")
-	commandDefinition, err := h.config.getCommandDefinition(cwr.Framework, cwr.Type)
+	ref, err := oci.ParseReference(cowr.Reference)
 	if err != nil {
-		level.Error(l).Log("message", "unable to get command definition", "error", err)
-		h.errorResponse(w, "unable to retrieve command definition", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error parsing artifact reference", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
-	executeCommand, err := generateExecuteCommand(commandDefinition, environmentVariablesString, cwr.Arguments)
+
+	creds, err := h.registryCredentials(rs.ctx, r.Header, projectName, ref.Registry)
 	if err != nil {
-		level.Error(l).Log("message", "unable to generate command", "error", err)
-		h.errorResponse(w, "unable to generate command", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading project registry credentials", "error", err)
+		h.errorResponse(w, "error reading project registry credentials", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "creating new credentials provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
-	if err != nil {
-		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
-		h.errorResponse(w, "bad or unknown credentials provider", http.StatusInternalServerError)
+	sel, ok := h.manifestSelection(rs, w, r, l, targetName, cowr.Name, cowr.Template)
+	if !ok {
 		return
 	}
 
-	level.Debug(l).Log("message", "getting credentials provider token")
-	credentialsToken, err := cp.GetToken()
-	if err != nil {
-		level.Error(l).Log("message", "error getting credentials provider token", "error", err)
-		h.errorResponse(w, "error retrieving credentials provider token", http.StatusInternalServerError)
+	cwr, err := h.loadCreateWorkflowRequestFromOCI(rs.ctx, ref, creds, cowr.Path, sel)
+	var perr *types.InvalidParameterError
+	if errors.Is(err, requests.ErrManifestSelection) || errors.Is(err, manifest.ErrRender) || errors.As(err, &perr) {
+		level.Error(l).Log("message", "error loading manifest workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
-
-	projectExists, err := cp.ProjectExists(cwr.ProjectName)
 	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error loading workflow data from artifact", "reference", ref, "error", err)
+		if errors.Is(err, oci.ErrNotFound) {
+			h.errorResponse(w, "artifact or manifest not found", http.StatusNotFound)
+			return
+		}
+		h.errorResponse(w, "error loading workflow data from artifact", http.StatusInternalServerError)
 		return
 	}
 
-	if !projectExists {
-		level.Error(l).Log("message", "project does not exist", "error", err)
-		h.errorResponse(w, "project does not exist", http.StatusBadRequest)
+	// The artifact can be shared by projects, so its manifest must be for the
+	// project and target of the request.
+	if cwr.ProjectName != projectName || cwr.TargetName != targetName {
+		level.Error(l).Log("message", "manifest is for another project or target", "manifest-project", cwr.ProjectName, "manifest-target", cwr.TargetName)
+		h.errorResponse(w, "invalid request, manifest project and target must match the request", http.StatusBadRequest)
 		return
 	}
 
-	targetExists, err := cp.TargetExists(cwr.ProjectName, cwr.TargetName)
+	if cowr.ChangeTicket != "" {
+		cwr.ChangeTicket = cowr.ChangeTicket
+	}
+
+	level.Debug(l).Log("message", "creating workflow", "reference", ref)
+	h.createWorkflowFromRequest(rs, w, r, cwr, "", ref.Registry+"/"+ref.Repository+":"+cowr.Path, l)
+}
+
+// Creates a workflow
+func (h handler) createWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "create-workflow")
+
+	level.Debug(l).Log("message", "validating authorization header for create workflow")
+	a, err := rs.authorization()
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
 		return
 	}
-	if !targetExists {
-		level.Error(l).Log("message", "target not found")
-		h.errorResponse(w, "target not found", http.StatusBadRequest)
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
 		return
 	}
 
-	level.Debug(l).Log("message", "creating workflow parameters")
-	parameters := workflow.NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, cwr.TargetName, cwr.ProjectName, cwr.Parameters, credentialsToken)
-
-	workflowLabels := map[string]string{txIDHeader: r.Header.Get(txIDHeader)}
-
-	level.Debug(l).Log("message", "creating workflow")
-	workflowName, err := h.argo.Submit(h.argoCtx, workflowFrom, parameters, workflowLabels)
+	level.Debug(l).Log("message", "reading request body")
+	var cwr requests.CreateWorkflow
+	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		level.Error(l).Log("message", "error creating workflow", "error", err)
-		h.errorResponse(w, "error creating workflow", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading workflow request data", "error", err)
+		h.errorResponse(w, "error reading workflow request data", http.StatusInternalServerError)
 		return
 	}
 
-	l = log.With(l, "workflow", workflowName)
-	level.Debug(l).Log("message", "workflow created")
-	tokenHead := credentialsToken[0:8]
-
-	level.Info(l).Log("message", fmt.Sprintf("Received token '%s...'", tokenHead))
-	var cwresp workflow.CreateWorkflowResponse
-	cwresp.WorkflowName = workflowName
-	jsonData, err := json.Marshal(cwresp)
-	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow response", "error", err)
-		h.errorResponse(w, "error serializing workflow response", http.StatusInternalServerError)
+	if err := json.Unmarshal(reqBody, &cwr); err != nil {
+		level.Error(l).Log("message", "error deserializing workflow data", "error", err)
+		var perr *types.InvalidParameterError
+		if errors.As(err, &perr) {
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", perr), http.StatusBadRequest)
+			return
+		}
+		h.errorResponse(w, "error deserializing workflow data", http.StatusBadRequest)
 		return
 	}
-	fmt.Fprintln(w, string(jsonData))
+
+	log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
+	level.Debug(l).Log("message", "creating workflow")
+	h.createWorkflowFromRequest(rs, w, r, cwr, "", "", l)
 }
 
-// Gets a workflow
-func (h handler) getWorkflow(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	workflowName := vars["workflowName"]
-	l := h.requestLogger(r, "op", "get-workflow", "workflow", workflowName)
+// Previews the command and image of a workflow without submitting it
+func (h handler) previewWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "preview-workflow")
 
-	level.Debug(l).Log("message", "getting workflow status")
-	status, err := h.argo.Status(h.argoCtx, workflowName)
+	level.Debug(l).Log("message", "validating authorization header for preview workflow")
+	a, err := rs.authorization()
 	if err != nil {
-		level.Error(l).Log("message", "error getting workflow", "error", err)
-		h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
 		return
 	}
 
+	level.Debug(l).Log("message", "reading request body")
+	var cwr requests.CreateWorkflow
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading workflow request data", "error", err)
+		h.errorResponse(w, "error reading workflow request data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.Unmarshal(reqBody, &cwr); err != nil {
+		level.Error(l).Log("message", "error deserializing workflow data", "error", err)
+		var perr *types.InvalidParameterError
+		if errors.As(err, &perr) {
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", perr), http.StatusBadRequest)
+			return
+		}
+		h.errorResponse(w, "error deserializing workflow data", http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "bad or unknown credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": cwr.ProjectName, "target": cwr.TargetName}, http.StatusBadRequest)
+		return
+	}
+
+	executeCommand, ok := h.generateWorkflowCommand(rs, w, l, &cwr)
+	if !ok {
+		return
+	}
+
+	data, err := json.Marshal(responses.PreviewWorkflow{
+		ExecuteCommand:           executeCommand,
+		ExecuteContainerImageURI: cwr.Parameters["execute_container_image_uri"],
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Validates a workflow request and generates the command it executes,
+// writing the error response otherwise. The framework defaults of the config
+// are applied to the request.
+func (h handler) generateWorkflowCommand(rs *requestScope, w http.ResponseWriter, l log.Logger, cwr *requests.CreateWorkflow) (string, bool) {
+	types, err := rs.config.listTypes(cwr.Framework)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid framework", "error", err)
+		h.errorResponse(
+			w,
+			fmt.Sprintf("invalid request, framework must be one of '%s'", strings.Join(rs.config.listFrameworks(), " ")),
+			http.StatusBadRequest,
+		)
+		return "", false
+	}
+
+	level.Debug(l).Log("message", "reading target platform")
+	scheduling, err := h.targetScheduling(rs.ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return "", false
+	}
+
+	level.Debug(l).Log("message", "validating workflow parameters")
+	if err := rs.config.applyFrameworkDefaults(cwr, scheduling.Platform); err != nil {
+		level.Error(l).Log("message", "error selecting framework image", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		return "", false
+	}
+	if err := cwr.Validate(
+		cwr.ValidateType(types),
+		rs.config.validateFrameworkRequest(*cwr),
+	); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		return "", false
+	}
+
+	level.Debug(l).Log("message", "applying target cost allocation tags")
+	if err := h.applyCostAllocationTags(rs.ctx, l, cwr); err != nil {
+		level.Error(l).Log("message", "error reading target cost allocation tags", "error", err)
+		h.errorResponse(w, "error reading target cost allocation tags", http.StatusInternalServerError)
+		return "", false
+	}
+
+	level.Debug(l).Log("message", "applying framework image pin")
+	if err := h.applyFrameworkImagePin(rs.ctx, l, cwr); err != nil {
+		level.Error(l).Log("message", "error reading framework images", "error", err)
+		h.errorResponse(w, "error reading framework images", http.StatusInternalServerError)
+		return "", false
+	}
+
+	environmentVariablesString := generateEnvVariablesString(cwr.EnvironmentVariables)
+
+	level.Debug(l).Log("message", "generating command to execute")
+	commandDefinition, err := rs.config.getCommandDefinition(cwr.Framework, cwr.Type)
+	if err != nil {
+		level.Error(l).Log("message", "unable to get command definition", "error", err)
+		h.errorResponse(w, "unable to retrieve command definition", http.StatusInternalServerError)
+		return "", false
+	}
+	fp, err := h.frameworkParameters(rs.ctx, *cwr)
+	if errors.Is(err, errNoInventory) {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		return "", false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "unable to get framework parameters", "error", err)
+		h.errorResponse(w, "unable to generate command", http.StatusInternalServerError)
+		return "", false
+	}
+	executeCommand, err := generateExecuteCommand(commandDefinition, environmentVariablesString, cwr.Arguments, fp)
+	if err != nil {
+		level.Error(l).Log("message", "unable to generate command", "error", err)
+		h.errorResponse(w, "unable to generate command", http.StatusInternalServerError)
+		return "", false
+	}
+	return executeCommand, true
+}
+
+// Creates a workflow
+// The request must be authorized by the caller, Vault doesn't currently
+// support contexts. The commit hash is empty when the workflow wasn't created
+// from git, the source (e.g. the repository and path of the manifest) when it
+// wasn't created from a manifest.
+func (h handler) createWorkflowFromRequest(rs *requestScope, w http.ResponseWriter, r *http.Request, cwr requests.CreateWorkflow, commitHash, source string, l log.Logger) {
+	ctx, a := rs.ctx, rs.principal
+
+	origin, err := requestOrigin(r)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid origin", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "selecting environment target")
+	cwr, ok := h.selectEnvironmentTarget(ctx, w, l, cwr)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "checking encrypted values")
+	cwr, encrypted, ok := h.checkEncryptedValues(ctx, w, l, cwr)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "scanning workflow request for secrets")
+	if !h.checkSecrets(w, l, cwr) {
+		return
+	}
+
+	executeCommand, ok := h.generateWorkflowCommand(rs, w, l, &cwr)
+	if !ok {
+		return
+	}
+	executeContainerImageURI := cwr.Parameters["execute_container_image_uri"]
+	environmentVariablesString := generateEnvVariablesString(cwr.EnvironmentVariables)
+
+	level.Debug(l).Log("message", "creating new credentials provider")
+	vaultStart := time.Now()
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "bad or unknown credentials provider", "error", err)
+		h.stageErrorResponse(ctx, w, r, stageVault, err, "bad or unknown credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.stageErrorResponse(ctx, w, r, stageVault, err, "error checking project", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectExists {
+		level.Error(l).Log("message", "project does not exist", "error", err)
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": cwr.ProjectName}, http.StatusBadRequest)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": cwr.ProjectName, "target": cwr.TargetName}, http.StatusBadRequest)
+		return
+	}
+	recordStage(ctx, stageVault, vaultStart)
+
+	level.Debug(l).Log("message", "checking destroy safeguards")
+	if !h.allowedDestroy(ctx, w, r, l, cwr) {
+		return
+	}
+
+	level.Debug(l).Log("message", "checking project business hours")
+	if !h.withinBusinessHours(ctx, w, r, l, cwr.ProjectName, cwr.TargetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "checking environment freeze windows")
+	if !h.outsideFreezeWindows(ctx, w, r, l, cwr.ProjectName, cwr.TargetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "checking target soak time")
+	if !h.soakedDiff(ctx, w, r, l, cwr, commitHash) {
+		return
+	}
+
+	level.Debug(l).Log("message", "checking workflow template")
+	workflowFrom, referenced, ok := h.resolveWorkflowTemplate(ctx, w, l, cwr)
+	if !ok {
+		return
+	}
+
+	workflowLabels := map[string]string{
+		workflow.LabelProject: cwr.ProjectName,
+		workflow.LabelTarget:  cwr.TargetName,
+		workflow.LabelType:    cwr.Type,
+	}
+	if commitHash != "" {
+		workflowLabels[workflow.LabelCommitHash] = commitHash
+
+		policy := h.env.DuplicateSubmissionPolicy
+		if policy == env.DuplicateSubmissionReject || policy == env.DuplicateSubmissionReturn {
+			level.Debug(l).Log("message", "checking for duplicate workflows")
+			duplicate, err := h.findActiveWorkflow(ctx, workflowLabels)
+			if err != nil {
+				level.Error(l).Log("message", "error checking for duplicate workflows", "error", err)
+				h.errorResponse(w, "error checking for duplicate workflows", http.StatusInternalServerError)
+				return
+			}
+
+			if duplicate != "" {
+				level.Info(l).Log("message", "duplicate workflow submission", "policy", policy, "existing-workflow", duplicate)
+				if policy == env.DuplicateSubmissionReject {
+					h.errorResponse(w, fmt.Sprintf("workflow '%s' is already running for this commit and target", duplicate), http.StatusConflict)
+					return
+				}
+
+				jsonData, err := json.Marshal(workflow.CreateWorkflowResponse{WorkflowName: duplicate})
+				if err != nil {
+					level.Error(l).Log("message", "error serializing workflow response", "error", err)
+					h.errorResponse(w, "error serializing workflow response", http.StatusInternalServerError)
+					return
+				}
+				fmt.Fprintln(w, string(jsonData))
+				return
+			}
+		}
+	}
+
+	level.Debug(l).Log("message", "reading project settings")
+	settings, err := h.projectSettings(ctx, cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project settings", "error", err)
+		h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+		return
+	}
+	for k, v := range settings.DefaultLabels {
+		if _, ok := workflowLabels[k]; !ok {
+			workflowLabels[k] = v
+		}
+	}
+
+	txID := r.Header.Get(txIDHeader)
+	workflowLabels[txIDHeader] = txID
+	addCostLabels(workflowLabels, settings, cwr.ProjectName, txID)
+
+	changeSetSummary := h.readChangeSetSummary(ctx, l, cwr)
+	planSummary := h.readPlanSummary(ctx, l, cwr, commitHash)
+
+	level.Debug(l).Log("message", "assessing submission for anomalies")
+	submission := anomaly.Submission{Project: cwr.ProjectName, Target: cwr.TargetName, Source: source, Principal: authorizationName(a), Time: h.now()}
+	assessment, anomalyAction := h.assessSubmission(ctx, l, txID, submission)
+	if anomalyAction != "" {
+		workflowLabels[workflow.LabelAnomalyScore] = strconv.FormatFloat(assessment.Score, 'f', 2, 64)
+	}
+	if anomalyAction == anomalyActionAlert || anomalyAction == anomalyActionApprove {
+		h.alertAnomaly(ctx, l, txID, submission, assessment)
+	}
+	// Only one of them is read, they're of different frameworks.
+	changeDetails := changeSetSummary + planSummary
+	if anomalyAction == anomalyActionApprove {
+		changeDetails = strings.TrimSpace(assessment.String() + "\n\n" + changeDetails)
+	}
+
+	level.Debug(l).Log("message", "checking target change control")
+	change, ok := h.ensureChangeTicket(ctx, w, l, txID, authorizationName(a), cwr, commitHash, changeDetails, anomalyAction == anomalyActionApprove, dualControlled(settings, cwr))
+	if !ok {
+		return
+	}
+	if change.ID != "" {
+		workflowLabels[workflow.LabelChangeTicket] = change.ID
+	}
+
+	level.Debug(l).Log("message", "reading target scheduling")
+	scheduling, err := h.workflowScheduling(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is only minted once the submission passed every check, and
+	// revoked when it fails, so no token outlives a rejected submission.
+	level.Debug(l).Log("message", "getting credentials provider token")
+	wrap := h.env.VaultWrapRunTokens || h.featureEnabled(ctx, l, cwr.ProjectName, feature.WrapRunTokens)
+	runToken, err := cp.GetRunToken(wrap)
+	credentialsToken := runToken.Token
+	if err != nil {
+		level.Error(l).Log("message", "error getting credentials provider token", "error", err)
+		h.stageErrorResponse(ctx, w, r, stageVault, err, "error retrieving credentials provider token", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating workflow parameters")
+	parameters := workflow.NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, cwr.TargetName, cwr.ProjectName, cwr.Parameters, credentialsToken)
+	if wrap {
+		// The templates unwrap the token before exchanging it.
+		parameters["credentials_token_wrapped"] = "true"
+	}
+	addEncryptedParameters(parameters, encrypted)
+	if referenced {
+		addReferencedParameters(parameters, cwr.Parameters)
+	}
+
+	level.Debug(l).Log("message", "creating workflow")
+	retryPolicy := workflow.RetryPolicy{
+		MaxAttempts:    h.env.ArgoSubmitMaxAttempts,
+		InitialBackoff: h.env.ArgoSubmitInitialBackoff,
+		MaxBackoff:     h.env.ArgoSubmitMaxBackoff,
+		IdentityLabels: submissionIdentityLabels,
+	}
+	var annotations map[string]string
+	if origin != nil {
+		annotations = workflow.OriginAnnotations(*origin)
+	}
+	submitCtx, cancel := h.stageContext(ctx, stageArgoSubmit)
+	defer cancel()
+	submitStart := time.Now()
+	workflowName, err := workflow.SubmitWithRetry(submitCtx, h.argo, retryPolicy, workflowFrom, parameters, workflowLabels, annotations, scheduling, func(attempt int, name string, err error) {
+		event := db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: name,
+			Type:         "submit_attempt",
+			Message:      fmt.Sprintf("attempt %d succeeded", attempt),
+			CreatedAt:    h.now().UTC(),
+		}
+		if err != nil {
+			level.Warn(l).Log("message", "workflow submission attempt failed", "attempt", attempt, "error", err)
+			event.Message = fmt.Sprintf("attempt %d failed: %s", attempt, err)
+		}
+		h.recordExecutionEvent(ctx, l, event)
+	})
+	recordStage(ctx, stageArgoSubmit, submitStart)
+	if err != nil {
+		level.Error(l).Log("message", "error creating workflow", "error", err)
+		h.revokeUnsubmittedRunToken(l, runToken)
+		var le *limitError
+		if errors.As(h.stageError(submitCtx, stageArgoSubmit, err), &le) {
+			h.limitErrorResponse(w, r, le, http.StatusGatewayTimeout)
+			return
+		}
+		if errors.Is(err, workflow.ErrNameTooLong) {
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+			return
+		}
+		if workflow.IsTransient(err) {
+			h.errorResponse(w, fmt.Sprintf("error creating workflow, %s", err), http.StatusBadGateway)
+			return
+		}
+		h.errorResponse(w, "error creating workflow", http.StatusInternalServerError)
+		return
+	}
+
+	l = log.With(l, "workflow", workflowName)
+	level.Debug(l).Log("message", "workflow created")
+	h.recordRunToken(ctx, l, txID, cwr.ProjectName, cwr.TargetName, workflowName, runToken)
+	h.watchGuardrail(ctx, l, txID, cwr.ProjectName, cwr.TargetName, workflowName)
+	h.watchHungRun(ctx, l, txID, cwr, workflowName)
+	h.watchNotificationRules(ctx, l, txID, cwr.ProjectName, cwr.TargetName, commitHash, workflowName)
+	h.watchChangeSet(l, txID, cwr, workflowName)
+	h.watchPlan(l, txID, cwr, commitHash, workflowName)
+	h.watchTerraformLock(l, txID, cwr, workflowName)
+	h.watchAttestation(l, txID, authorizationName(a), cwr, workflowName)
+	h.watchLogArchive(l, txID, cwr, workflowName)
+	h.recordDestroy(ctx, l, txID, authorizationName(a), cwr, workflowName)
+
+	if changeSetSummary != "" {
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "change_set_summary",
+			Message:      changeSetSummary,
+			CreatedAt:    h.now().UTC(),
+		})
+	}
+	if planSummary != "" {
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "plan_summary",
+			Message:      planSummary,
+			CreatedAt:    h.now().UTC(),
+		})
+	}
+
+	if origin != nil {
+		h.recordOrigin(ctx, l, txID, cwr, workflowName, *origin)
+	}
+
+	if change.ID != "" {
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "change_ticket",
+			Message:      fmt.Sprintf("change ticket %s %s", change.ID, change.State),
+			CreatedAt:    h.now().UTC(),
+		})
+	}
+	tokenHead := credentialsToken[0:8]
+
+	level.Info(l).Log("message", fmt.Sprintf("Received token '%s...'", tokenHead))
+	var cwresp workflow.CreateWorkflowResponse
+	cwresp.WorkflowName = workflowName
+	cwresp.ChangeTicket = change.ID
+	cwresp.Origin = origin
+	jsonData, err := json.Marshal(cwresp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow response", "error", err)
+		h.errorResponse(w, "error serializing workflow response", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// Records the token of a workflow run and watches the workflow so the token
+// is revoked as soon as it completes. Failures are logged as the token still
+// expires with its TTL.
+func (h handler) recordRunToken(ctx context.Context, l log.Logger, txID, projectName, targetName, workflowName string, rt credentials.RunToken) {
+	rte := db.RunTokenEntry{
+		WorkflowName: workflowName,
+		TxID:         txID,
+		Project:      projectName,
+		Target:       targetName,
+		Accessor:     rt.Accessor,
+		CreatedAt:    h.now().UTC(),
+	}
+	if err := h.dbClient.CreateRunTokenEntry(ctx, rte); err != nil {
+		level.Warn(l).Log("message", "error recording run token", "error", err)
+		return
+	}
+
+	if h.env.RunTokenWatchInterval <= 0 {
+		return
+	}
+
+	// The request context is done once the response is written. The token
+	// expires after its max TTL, so there's no need to watch longer.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, credentials.TokenMaxTTL)
+		defer cancel()
+
+		status, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.RunTokenWatchInterval, func(err error) {
+			level.Warn(l).Log("message", "error getting workflow status", "error", err)
+		})
+		if err != nil {
+			return
+		}
+
+		cp, err := h.adminCredentialsProvider(http.Header{})
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			return
+		}
+		h.revokeRunToken(ctx, l, cp, rte, status.Status)
+	}()
+}
+
+// Revokes the token of a workflow run which failed to be submitted. It was
+// never recorded, so failures are logged and the token expires with its TTL.
+func (h handler) revokeUnsubmittedRunToken(l log.Logger, rt credentials.RunToken) {
+	cp, err := h.adminCredentialsProvider(http.Header{})
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		return
+	}
+	if err := cp.RevokeToken(rt.Accessor); err != nil {
+		level.Error(l).Log("message", "error revoking run token", "error", err)
+		return
+	}
+	level.Info(l).Log("message", "run token of unsubmitted workflow revoked")
+}
+
+// Revokes the token of a completed workflow run, which revokes the leases
+// (e.g. AWS credentials) created with it, and forgets it. Tokens which
+// failed to be revoked are kept so revokeRunTokens retries them.
+func (h handler) revokeRunToken(ctx context.Context, l log.Logger, cp credentials.Provider, rt db.RunTokenEntry, status string) {
+	if err := cp.RevokeToken(rt.Accessor); err != nil {
+		level.Error(l).Log("message", "error revoking run token", "error", err)
+		return
+	}
+	level.Info(l).Log("message", "run token revoked", "status", status)
+
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         rt.TxID,
+		Project:      rt.Project,
+		Target:       rt.Target,
+		WorkflowName: rt.WorkflowName,
+		Type:         "credentials_revoked",
+		Message:      fmt.Sprintf("credentials revoked, workflow %s", status),
+		CreatedAt:    h.now().UTC(),
+	})
+
+	if err := h.dbClient.DeleteRunTokenEntry(ctx, rt.WorkflowName); err != nil {
+		level.Error(l).Log("message", "error deleting run token", "error", err)
+	}
+}
+
+// Revokes the tokens of completed workflow runs every interval until ctx is
+// done.
+func (h handler) watchRunTokens(ctx context.Context, interval time.Duration) {
+	t := h.clock.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if err := h.revokeRunTokens(ctx); err != nil {
+			level.Error(h.logger).Log("message", "error revoking run tokens", "error", err)
+		}
+	}
+}
+
+// Revokes the tokens of workflow runs which are no longer active and weren't
+// revoked when they completed, e.g. because the service restarted. Tokens
+// older than their max TTL expired already and are only forgotten. Errors of
+// a token are logged and don't stop the revocation of the others.
+func (h handler) revokeRunTokens(ctx context.Context) error {
+	runTokens, err := h.dbClient.ListRunTokenEntries(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing run tokens: %w", err)
+	}
+	if len(runTokens) == 0 {
+		return nil
+	}
+
+	cp, err := h.adminCredentialsProvider(http.Header{})
+	if err != nil {
+		return fmt.Errorf("error creating credentials provider: %w", err)
+	}
+
+	for _, rt := range runTokens {
+		l := log.With(h.logger, "op", "revoke-run-token", "project", rt.Project, "target", rt.Target, "workflow", rt.WorkflowName)
+
+		if h.now().Sub(rt.CreatedAt) < credentials.TokenMaxTTL {
+			status, err := h.argo.Status(h.argoCtx, rt.WorkflowName)
+			if err != nil {
+				level.Warn(l).Log("message", "error getting workflow status", "error", err)
+				continue
+			}
+			if workflow.IsActive(status.Status) {
+				continue
+			}
+
+			h.revokeRunToken(ctx, l, cp, rt, status.Status)
+			continue
+		}
+
+		if err := h.dbClient.DeleteRunTokenEntry(ctx, rt.WorkflowName); err != nil {
+			level.Error(l).Log("message", "error deleting run token", "error", err)
+		}
+	}
+	return nil
+}
+
+// findActiveWorkflow returns the name of a workflow which hasn't completed and
+// has all of the labels, or an empty string if there is none.
+func (h handler) findActiveWorkflow(ctx context.Context, workflowLabels map[string]string) (string, error) {
+	statuses, err := h.argo.ListByLabels(ctx, workflowLabels)
+	if err != nil {
+		return "", err
+	}
+
+	for _, status := range statuses {
+		if workflow.IsActive(status.Status) {
+			return status.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// Gets a workflow
+func (h handler) getWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+	l := rs.log("op", "get-workflow", "workflow", workflowName)
+
+	status, ok := h.authorizedWorkflow(w, r, l, workflowName)
+	if !ok {
+		return
+	}
+
+	resp := responses.GetWorkflowStatus{
+		Name:     status.Name,
+		Status:   status.Status,
+		Created:  status.Created,
+		Finished: status.Finished,
+		Origin:   workflow.Origin(status.Annotations),
+	}
+	if h.env.AnonymousReadOnly {
+		publicID, err := h.publicWorkflowID(workflowName)
+		if err != nil {
+			level.Error(l).Log("message", "error generating public id", "error", err)
+			h.errorResponse(w, "error generating public id", http.StatusInternalServerError)
+			return
+		}
+		resp.PublicID = publicID
+	}
+	if workflow.IsActive(status.Status) {
+		resp.Progress = h.workflowProgress(rs.ctx, l, status)
+	}
+	if projectName, targetName := status.Labels[workflow.LabelProject], status.Labels[workflow.LabelTarget]; projectName != "" && targetName != "" {
+		notes, err := h.targetExecutionNotes(rs.ctx, projectName, targetName)
+		if err != nil {
+			level.Error(l).Log("message", "error reading execution notes", "error", err)
+			h.errorResponse(w, "error reading execution notes", http.StatusInternalServerError)
+			return
+		}
+		resp.KnownBad = notes.knownBad[workflowName]
+		resp.Notes = notes.notes[workflowName]
+	}
+
 	level.Debug(l).Log("message", "decoding get workflow response")
-	jsonData, err := json.Marshal(status)
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(jsonData))
+}
+
+// authorizedWorkflow returns the status of the workflow when the request is
+// from an admin or the project of the workflow, writing the error response
+// otherwise. The project of the credentials is authorized before the
+// workflow is read, and workflows which don't exist are not found like the
+// ones of other projects, so their existence isn't disclosed.
+func (h handler) authorizedWorkflow(w http.ResponseWriter, r *http.Request, l log.Logger, workflowName string) (*workflow.Status, bool) {
+	rs := h.scope(r)
+
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return nil, false
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	projectName := ""
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+			return nil, false
+		}
+
+		identity, err := cp.Identity()
+		if errors.Is(err, credentials.ErrInvalidCredentials) || (err == nil && identity.Project == "") {
+			h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+			return nil, false
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error authorizing project", "error", err)
+			h.errorResponse(w, "error authorizing project", http.StatusInternalServerError)
+			return nil, false
+		}
+		projectName = identity.Project
+	}
+
+	level.Debug(l).Log("message", "getting workflow status")
+	status, err := h.argo.Status(rs.ctx, workflowName)
+	if workflow.IsNotFound(err) {
+		level.Debug(l).Log("message", "workflow not found")
+		h.messageResponse(w, r, messages.WorkflowNotFound, messages.Params{"workflow": workflowName}, http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow", "error", err)
+		h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	h.resolveWorkflowProject(rs.ctx, l, status)
+
+	if projectName != "" && status.Labels[workflow.LabelProject] != projectName {
+		level.Error(l).Log("message", "workflow not owned by project", "project", projectName)
+		h.messageResponse(w, r, messages.WorkflowNotFound, messages.Params{"workflow": workflowName}, http.StatusNotFound)
+		return nil, false
+	}
+
+	return status, true
+}
+
+// Gets a target
+func (h handler) getTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target", "project", projectName, "target", targetName)
+
+	// Sensitive values are included unless requested otherwise, e.g. by
+	// Terraform to keep them out of its state.
+	includeSensitive := true
+	if v := r.URL.Query().Get("include_sensitive"); v != "" {
+		var err error
+		if includeSensitive, err = strconv.ParseBool(v); err != nil {
+			h.errorResponse(w, "invalid request, include_sensitive must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "validating authorization header for get target")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": targetName}, http.StatusNotFound)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting target information")
+	targetInfo, err := cp.GetTarget(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target information", "error", err)
+		h.errorResponse(w, "error retrieving target information", http.StatusInternalServerError)
+		return
+	}
+
+	targetID, err := h.resourceID(rs.ctx, resourceKindTarget, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target id", "error", err)
+		h.errorResponse(w, "error reading target id", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResult, err := json.Marshal(newGetTargetResponse(targetID, targetInfo, includeSensitive))
+	if err != nil {
+		level.Error(l).Log("message", "error serializing json target data", "error", err)
+		h.errorResponse(w, "error serializing json target data", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(jsonResult))
+}
+
+// Returns the logs for a workflow
+func (h handler) getWorkflowLogs(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+
+	l := rs.log("op", "get-workflow-logs", "workflow", workflowName)
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	h.writeWorkflowLogs(w, r, l, workflowName)
+}
+
+// Default and maximum number of nodes of a page of Get Workflow Nodes.
+const (
+	defaultWorkflowNodesLimit = 100
+	maxWorkflowNodesLimit     = 500
+)
+
+// workflowNodeOptions returns the page of the nodes of the workflow requested
+// by the limit and continue query parameters.
+func workflowNodeOptions(r *http.Request, workflowName string) (workflow.NodeOptions, error) {
+	opts := workflow.NodeOptions{Limit: defaultWorkflowNodesLimit}
+
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxWorkflowNodesLimit {
+			return workflow.NodeOptions{}, fmt.Errorf("limit must be an integer between 1 and %d", maxWorkflowNodesLimit)
+		}
+		opts.Limit = limit
+	}
+	if token := q.Get("continue"); token != "" {
+		offset, err := decodeContinueToken(workflowName, token)
+		if err != nil {
+			return workflow.NodeOptions{}, err
+		}
+		opts.Offset = offset
+	}
+	return opts, nil
+}
+
+// Returns a page of the nodes of a workflow
+func (h handler) getWorkflowNodes(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+
+	l := rs.log("op", "get-workflow-nodes", "workflow", workflowName)
+
+	opts, err := workflowNodeOptions(r, workflowName)
+	if err != nil {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving workflow nodes", "offset", opts.Offset, "limit", opts.Limit)
+	nodes, err := h.argo.Nodes(rs.ctx, workflowName, opts)
+	if errors.Is(err, workflow.ErrNodesOffloaded) {
+		h.errorResponse(w, "workflow nodes are offloaded, reading them requires ARGO_CLOUDOPS_ARGO_ARTIFACTS_URL", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow nodes", "error", err)
+		h.errorResponse(w, "error getting workflow nodes", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetWorkflowNodes{Nodes: []responses.WorkflowNode{}}
+	for _, n := range nodes.Nodes {
+		resp.Nodes = append(resp.Nodes, responses.WorkflowNode(n))
+	}
+	if nodes.Next > 0 {
+		resp.Continue = encodeContinueToken(workflowName, nodes.Next)
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow nodes", "error", err)
+		h.errorResponse(w, "error serializing workflow nodes", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Streams workflow logs
+func (h handler) getWorkflowLogStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	workflowName := vars["workflowName"]
+
+	l := rs.log("op", "get-workflow-log-stream", "workflow", workflowName)
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving workflow logs", "workflow", workflowName)
+	err := h.argo.LogStream(rs.ctx, workflowName, w)
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logstream", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Returns a new token
+func newArgoCloudOpsToken(provider, key, secret string) *token {
+	return &token{
+		Token: fmt.Sprintf("%s:%s:%s", provider, key, secret),
+	}
+}
+
+// Creates a project
+func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "create-project")
+
+	level.Debug(l).Log("message", "validating authorization header for create project")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	ctx := rs.ctx
+
+	var capp requests.CreateProject
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &capp); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := capp.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "project", capp.Name)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(capp.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+
+	if projectExists {
+		level.Error(l).Log("error", "project already exists")
+		h.errorResponse(w, "project already exists", http.StatusBadRequest)
+		return
+	}
+
+	// Reads of the former names of renamed projects are of the renamed
+	// projects until the aliases expire.
+	alias, ok, err := h.activeProjectAlias(ctx, capp.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project alias", "error", err)
+		h.errorResponse(w, "error reading project alias", http.StatusInternalServerError)
+		return
+	}
+	if ok {
+		h.errorResponse(w, fmt.Sprintf("name is an alias of project '%s' until %s", alias.Project, alias.ExpiresAt.UTC().Format(time.RFC3339)), http.StatusBadRequest)
+		return
+	}
+
+	// The ID is assigned first so a created project has one.
+	projectID, err := h.resourceID(ctx, resourceKindProject, capp.Name, "")
+	if err != nil {
+		level.Error(l).Log("message", "error assigning project id", "error", err)
+		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "inserting into db")
+	err = h.dbClient.CreateProjectEntry(ctx, db.ProjectEntry{
+		ProjectID:  capp.Name,
+		Repository: capp.Repository,
+	})
+	if err != nil {
+		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		return
+	}
+	level.Debug(l).Log("message", "creating project")
+	role, secret, err := cp.CreateProject(capp.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error creating project", "error", err)
+		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving Cello token")
+	t := newArgoCloudOpsToken("vault", role, secret)
+	t.ID = projectID
+	jsonResult, err := json.Marshal(t)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing token", "error", err)
+		h.errorResponse(w, "error serializing token", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonResult))
+}
+
+// Get a project
+func (h handler) getProject(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for get project")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting project")
+	resp, err := cp.GetProject(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving project", "error", err)
+		h.errorResponse(w, "error retrieving project", http.StatusNotFound)
+		return
+	}
+
+	resp.ID, err = h.resourceID(rs.ctx, resourceKindProject, projectName, "")
+	if err != nil {
+		level.Error(l).Log("message", "error reading project id", "error", err)
+		h.errorResponse(w, "error reading project id", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Delete a project
+func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "delete-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for delete project")
+	ctx := rs.ctx
+
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	// Deleting the project would leave its name, and the scope of the
+	// elevated access, to any project created with it.
+	if rs.elevation != nil {
+		h.errorResponse(w, "elevated access can't delete its project", http.StatusForbidden)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "checking if project exists")
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectExists {
+		level.Debug(l).Log("message", "no action required because project does not exist")
+		return
+	}
+
+	level.Debug(l).Log("message", "getting all targets in project")
+	targets, err := cp.ListTargets(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error getting all targets", "error", err)
+		h.errorResponse(w, "error getting all targets", http.StatusInternalServerError)
+		return
+	}
+
+	if len(targets) > 0 {
+		level.Error(l).Log("error", "project has existing targets, not deleting")
+		h.errorResponse(w, "project has existing targets, not deleting", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "deleting project")
+	err = cp.DeleteProject(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error deleting project", "error", err)
+		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(h.logger).Log("message", "deleting from db")
+	if err = h.dbClient.DeleteProjectEntry(ctx, projectName); err != nil {
+		level.Error(l).Log("message", "error deleting project in database", "error", err)
+		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+		return
+	}
+
+	// The project is gone so errors deleting its leftover settings are only
+	// logged.
+	if err := h.dbClient.DeleteResourceIDEntry(ctx, resourceKindProject, projectName, ""); err != nil {
+		level.Warn(l).Log("message", "error deleting project id", "error", err)
+	}
+
+	rules, err := h.dbClient.ListProjectNotificationRuleEntries(ctx, projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing project notification rules", "error", err)
+	}
+	for _, nr := range rules {
+		if err := h.dbClient.DeleteProjectNotificationRuleEntry(ctx, projectName, nr.Type); err != nil {
+			level.Warn(l).Log("message", "error deleting project notification rule", "type", nr.Type, "error", err)
+		}
+	}
+
+	if err := h.dbClient.DeleteProjectBusinessHoursEntry(ctx, projectName); err != nil {
+		level.Warn(l).Log("message", "error deleting project business hours", "error", err)
+	}
+
+	templates, err := h.dbClient.ListProjectWorkflowTemplateEntries(ctx, projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing project workflow templates", "error", err)
+	}
+	for _, wt := range templates {
+		if err := h.dbClient.DeleteProjectWorkflowTemplateEntry(ctx, projectName, wt.Kind, wt.Name); err != nil {
+			level.Warn(l).Log("message", "error deleting project workflow template", "kind", wt.Kind, "workflow-template", wt.Name, "error", err)
+		}
+	}
+
+	flags, err := h.dbClient.ListProjectFeatureFlagEntries(ctx, projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing project feature flags", "error", err)
+	}
+	for _, ff := range flags {
+		if err := h.dbClient.DeleteProjectFeatureFlagEntry(ctx, projectName, ff.Name); err != nil {
+			level.Warn(l).Log("message", "error deleting project feature flag", "feature-flag", ff.Name, "error", err)
+		}
+	}
+
+	registries, err := h.dbClient.ListProjectRegistryCredentialEntries(ctx, projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing project registry credentials", "error", err)
+	}
+	for _, rc := range registries {
+		if err := h.dbClient.DeleteProjectRegistryCredentialEntry(ctx, projectName, rc.Registry); err != nil {
+			level.Warn(l).Log("message", "error deleting project registry credential", "registry", rc.Registry, "error", err)
+			continue
+		}
+		h.deleteRegistryPassword(l, cp, rc)
+	}
+
+	environments, err := h.dbClient.ListProjectEnvironmentEntries(ctx, projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing project environments", "error", err)
+	}
+	for _, e := range environments {
+		if err := h.dbClient.DeleteProjectEnvironmentEntry(ctx, projectName, e.Name); err != nil {
+			level.Warn(l).Log("message", "error deleting project environment", "environment", e.Name, "error", err)
+		}
+	}
+}
+
+// Creates a target
+func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "create-target", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for create target")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.Unauthorized, nil, http.StatusUnauthorized)
+		return
+	}
+	level.Debug(l).Log("message", "reading request body")
+
+	var ctr requests.CreateTarget
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+	}
+
+	if err := json.Unmarshal(reqBody, &ctr); err != nil {
+		level.Error(l).Log("message", "error processing request", "error", err)
+		h.errorResponse(w, "error processing request", http.StatusBadRequest)
+		return
+	}
+
+	if err := types.Target(ctr).Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.targetTypeError(types.Target(ctr)); err != nil {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "target", ctr.Name)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error determining if project exists", "error", err)
+	}
+
+	// TODO Perhaps this should be 404
+	if !projectExists {
+		level.Error(l).Log("message", "project does not exist")
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": projectName}, http.StatusBadRequest)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(projectName, ctr.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if targetExists {
+		level.Error(l).Log("message", "target name must not already exist")
+		h.errorResponse(w, "target name must not already exist", http.StatusBadRequest)
+		return
+	}
+
+	// The ID is assigned first so a created target has one.
+	targetID, err := h.resourceID(rs.ctx, resourceKindTarget, projectName, ctr.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error assigning target id", "error", err)
+		h.errorResponse(w, "error creating target", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating target")
+	err = cp.CreateTarget(projectName, types.Target(ctr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating target", "error", err)
+		h.errorResponse(w, "error creating target", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.CreateTarget{ID: targetID, Warnings: h.vaultPolicyWarnings(l, cp, projectName)})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// targetTypeError returns why targets of the type of the target can't be
+// created with the configuration of the service, nil when they can.
+func (h handler) targetTypeError(target types.Target) error {
+	if target.Type != types.TargetTypeAWSAccount && h.env.CredentialsProvider == env.CredentialsProviderAWSSecretsManager {
+		return fmt.Errorf("%s targets require the vault credentials provider", target.Type)
+	}
+	if target.Type == types.TargetTypeGCPProject && !h.env.VaultGCPEnabled {
+		return errors.New("gcp_project targets require ARGO_CLOUDOPS_VAULT_GCP_ENABLED")
+	}
+	if target.Type == types.TargetTypeAzureSubscription && !h.env.VaultAzureEnabled {
+		return errors.New("azure_subscription targets require ARGO_CLOUDOPS_VAULT_AZURE_ENABLED")
+	}
+	return nil
+}
+
+// Validates a target without creating it, running the checks of createTarget
+// and returning all of their violations at once, e.g. for pipelines managing
+// targets declaratively to validate them in CI.
+func (h handler) validateTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "validate-target", "project", projectName)
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var ctr requests.CreateTarget
+	if err := json.Unmarshal(reqBody, &ctr); err != nil {
+		level.Error(l).Log("message", "error processing request", "error", err)
+		h.errorResponse(w, "error processing request", http.StatusBadRequest)
+		return
+	}
+
+	resp := responses.ValidateTarget{Violations: []string{}}
+	for _, err := range types.Target(ctr).Violations() {
+		resp.Violations = append(resp.Violations, err.Error())
+	}
+	if err := h.targetTypeError(types.Target(ctr)); err != nil {
+		resp.Violations = append(resp.Violations, err.Error())
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error determining if project exists", "error", err)
+		h.errorResponse(w, "error determining if project exists", http.StatusInternalServerError)
+		return
+	}
+	if !projectExists {
+		resp.Violations = append(resp.Violations, "project does not exist")
+	}
+
+	if projectExists && ctr.Name != "" {
+		resp.Exists, err = cp.TargetExists(projectName, ctr.Name)
+		if err != nil {
+			level.Error(l).Log("message", "error retrieving target", "error", err)
+			h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+			return
+		}
+	}
+	resp.Valid = len(resp.Violations) == 0
+
+	level.Debug(l).Log("message", "validated target", "valid", resp.Valid, "violations", len(resp.Violations))
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes a target
+func (h handler) deleteTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target", "project", projectName, "target", targetName)
+
+	level.Debug(l).Log("message", "validating authorization header for delete target")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "deleting target")
+	if err := h.deleteTargetAndSettings(rs.ctx, l, cp, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target", "error", err)
+		h.errorResponse(w, "error deleting target", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Deletes a target, then its settings and schedules. The target is gone once
+// deleted so errors deleting leftover settings are only logged.
+func (h handler) deleteTargetAndSettings(ctx context.Context, l log.Logger, cp credentials.Provider, projectName, targetName string) error {
+	if err := cp.DeleteTarget(projectName, targetName); err != nil {
+		return err
+	}
+
+	if err := h.dbClient.DeleteResourceIDEntry(ctx, resourceKindTarget, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target id", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetGuardrailEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target guardrail", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetChangeControlEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target change control", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetSchedulingEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target scheduling", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetWorkloadIdentityEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target workload identity", "error", err)
+	}
+	if err := h.dbClient.DeleteChangeSetSummaryEntries(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target change set summaries", "error", err)
+	}
+	if err := h.dbClient.DeletePlanSummaryEntries(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target plan summaries", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetInventoryEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target inventory", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetCostAllocationTagsEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target cost allocation tags", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetSoakTimeEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target soak time", "error", err)
+	}
+	if err := h.dbClient.DeleteTargetTerraformStateEntry(ctx, projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target terraform state", "error", err)
+	}
+	if err := cp.DeleteTargetHostCredentials(projectName, targetName); err != nil {
+		level.Warn(l).Log("message", "error deleting target host credentials", "error", err)
+	}
+
+	schedules, err := h.dbClient.ListTargetScheduleEntries(ctx, projectName, targetName)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing target schedules", "error", err)
+	}
+	for _, ts := range schedules {
+		if err := h.deleteSchedule(ctx, projectName, targetName, ts.Name); err != nil {
+			level.Warn(l).Log("message", "error deleting target schedule", "schedule", ts.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// Lists the targets for a project
+func (h handler) listTargets(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "list-targets", "project", projectName)
+
+	// Targets are listed by name unless their IDs are requested.
+	includeIDs := false
+	if v := r.URL.Query().Get("include_ids"); v != "" {
+		var err error
+		if includeIDs, err = strconv.ParseBool(v); err != nil {
+			h.errorResponse(w, "invalid request, include_ids must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "validating authorization header for target list")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "checking if project exists")
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectExists {
+		level.Debug(l).Log("message", "project does not exist")
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": projectName}, http.StatusNotFound)
+		return
+	}
+
+	targets, err := cp.ListTargets(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error listing targets", "error", err)
+		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+		return
+	}
+
+	var resp interface{} = targets
+	if includeIDs {
+		summaries := responses.ListTargets{}
+		for _, name := range targets {
+			id, err := h.resourceID(rs.ctx, resourceKindTarget, projectName, name)
+			if err != nil {
+				level.Error(l).Log("message", "error reading target id", "target", name, "error", err)
+				h.errorResponse(w, "error reading target id", http.StatusInternalServerError)
+				return
+			}
+			summaries = append(summaries, responses.TargetSummary{ID: id, Name: name})
+		}
+		resp = summaries
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing targets", "error", err)
+		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Updates a target
+func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "update-target", "project", projectName, "target", targetName)
+
+	level.Debug(l).Log("message", "validating authorization header for update target")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.Unauthorized, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error determining if project exists", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectExists {
+		level.Error(l).Log("message", "project does not exist")
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": projectName}, http.StatusNotFound)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": targetName}, http.StatusNotFound)
+		return
+	}
+
+	target, err := cp.GetTarget(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving existing target")
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+	targetType := target.Type
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	// merge request data into existing target struct for update data
+	if err := json.Unmarshal(reqBody, &target); err != nil {
+		level.Error(l).Log("message", "error reading target properties data", "error", err)
+		h.errorResponse(w, "error reading target properties data", http.StatusInternalServerError)
+		return
+	}
+	// overwrite updated target with existing target name and type values so request body doesn't overwrite these values
+	target.Name = targetName
+	target.Type = targetType
+
+	if err := target.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// The workload identity must keep assuming the role of the target.
+	if h.serviceAccounts != nil {
+		wi, err := h.dbClient.ReadTargetWorkloadIdentityEntry(rs.ctx, projectName, targetName)
+		if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error reading target workload identity", "error", err)
+			h.errorResponse(w, "error reading target workload identity", http.StatusInternalServerError)
+			return
+		}
+		if err == nil && !h.validServiceAccountRole(rs.ctx, w, l, wi.ServiceAccount, target.Properties.RoleArn) {
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "updating target")
+	err = cp.UpdateTarget(projectName, target)
+	if err != nil {
+		level.Error(l).Log("message", "error updating target", "error", err)
+		h.errorResponse(w, "error updating target", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(target)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Puts (creates or replaces) the guardrail of a target
+func (h handler) putTargetGuardrail(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "put-target-guardrail", "project", projectName, "target", targetName)
+
+	ctx := rs.ctx
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var tgr requests.PutTargetGuardrail
+	if err := json.Unmarshal(reqBody, &tgr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := tgr.Validate(tgr.ValidateProvider(h.guardrails.Providers())); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target guardrail")
+	err = h.dbClient.CreateTargetGuardrailEntry(ctx, db.TargetGuardrailEntry{
+		Project:   projectName,
+		Target:    targetName,
+		Provider:  tgr.Provider,
+		Query:     tgr.Query,
+		Threshold: tgr.Threshold,
+		Operator:  tgr.Operator,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target guardrail", "error", err)
+		h.errorResponse(w, "error storing target guardrail", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetGuardrail(tgr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the guardrail of a target
+func (h handler) getTargetGuardrail(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target-guardrail", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	tg, err := h.dbClient.ReadTargetGuardrailEntry(rs.ctx, projectName, targetName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "guardrail not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading target guardrail", "error", err)
+		h.errorResponse(w, "error reading target guardrail", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetGuardrail{
+		Provider:  tg.Provider,
+		Query:     tg.Query,
+		Threshold: tg.Threshold,
+		Operator:  tg.Operator,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the guardrail of a target
+func (h handler) deleteTargetGuardrail(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target-guardrail", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetGuardrailEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target guardrail", "error", err)
+		h.errorResponse(w, "error deleting target guardrail", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Puts (creates or replaces) the scheduling constraints of a target
+func (h handler) putTargetScheduling(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "put-target-scheduling", "project", projectName, "target", targetName)
+
+	ctx := rs.ctx
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var tsr requests.PutTargetScheduling
+	if err := json.Unmarshal(reqBody, &tsr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := tsr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if tsr.NodeSelector == nil {
+		tsr.NodeSelector = map[string]string{}
+	}
+	if tsr.Tolerations == nil {
+		tsr.Tolerations = []types.Toleration{}
+	}
+
+	nodeSelector, err := json.Marshal(tsr.NodeSelector)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing node selector", "error", err)
+		h.errorResponse(w, "error serializing node selector", http.StatusInternalServerError)
+		return
+	}
+	tolerations, err := json.Marshal(tsr.Tolerations)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing tolerations", "error", err)
+		h.errorResponse(w, "error serializing tolerations", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target scheduling")
+	err = h.dbClient.CreateTargetSchedulingEntry(ctx, db.TargetSchedulingEntry{
+		Project:           projectName,
+		Target:            targetName,
+		NodeSelector:      string(nodeSelector),
+		Tolerations:       string(tolerations),
+		PriorityClassName: tsr.PriorityClassName,
+		RuntimeClassName:  tsr.RuntimeClassName,
+		Platform:          tsr.Platform,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target scheduling", "error", err)
+		h.errorResponse(w, "error storing target scheduling", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetScheduling(tsr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the scheduling constraints of a target
+func (h handler) getTargetScheduling(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target-scheduling", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	s, err := h.targetScheduling(rs.ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return
+	}
+	if s.IsZero() {
+		h.errorResponse(w, "scheduling not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetScheduling{
+		NodeSelector:      s.NodeSelector,
+		Tolerations:       s.Tolerations,
+		PriorityClassName: s.PriorityClassName,
+		RuntimeClassName:  s.RuntimeClassName,
+		Platform:          s.Platform,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the scheduling constraints of a target
+func (h handler) deleteTargetScheduling(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target-scheduling", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetSchedulingEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target scheduling", "error", err)
+		h.errorResponse(w, "error deleting target scheduling", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Reads the scheduling constraints of a target, empty when it has none.
+func (h handler) targetScheduling(ctx context.Context, projectName, targetName string) (workflow.Scheduling, error) {
+	ts, err := h.dbClient.ReadTargetSchedulingEntry(ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return workflow.Scheduling{}, nil
+	}
+	if err != nil {
+		return workflow.Scheduling{}, err
+	}
+
+	s := workflow.Scheduling{
+		PriorityClassName: ts.PriorityClassName,
+		RuntimeClassName:  ts.RuntimeClassName,
+		Platform:          ts.Platform,
+	}
+	if err := json.Unmarshal([]byte(ts.NodeSelector), &s.NodeSelector); err != nil {
+		return workflow.Scheduling{}, fmt.Errorf("error deserializing node selector: %w", err)
+	}
+	if err := json.Unmarshal([]byte(ts.Tolerations), &s.Tolerations); err != nil {
+		return workflow.Scheduling{}, fmt.Errorf("error deserializing tolerations: %w", err)
+	}
+	return s, nil
+}
+
+// Sets the IRSA service account the pods of workflows for a target run as
+func (h handler) putTargetWorkloadIdentity(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	ctx := rs.ctx
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "put-target-workload-identity", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if h.serviceAccounts == nil {
+		h.errorResponse(w, "invalid request, workload identity is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var wir requests.PutTargetWorkloadIdentity
+	if err := json.Unmarshal(reqBody, &wir); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := wir.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	target, err := cp.GetTarget(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "validating service account role")
+	if !h.validServiceAccountRole(ctx, w, l, wir.ServiceAccount, target.Properties.RoleArn) {
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target workload identity")
+	err = h.dbClient.CreateTargetWorkloadIdentityEntry(ctx, db.TargetWorkloadIdentityEntry{
+		Project:        projectName,
+		Target:         targetName,
+		ServiceAccount: wir.ServiceAccount,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target workload identity", "error", err)
+		h.errorResponse(w, "error storing target workload identity", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetWorkloadIdentity(wir))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the IRSA service account the pods of workflows for a target run as
+func (h handler) getTargetWorkloadIdentity(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target-workload-identity", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	wi, err := h.dbClient.ReadTargetWorkloadIdentityEntry(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "workload identity not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target workload identity", "error", err)
+		h.errorResponse(w, "error reading target workload identity", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetWorkloadIdentity{ServiceAccount: wi.ServiceAccount})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the IRSA service account the pods of workflows for a target run as
+func (h handler) deleteTargetWorkloadIdentity(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target-workload-identity", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetWorkloadIdentityEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target workload identity", "error", err)
+		h.errorResponse(w, "error deleting target workload identity", http.StatusInternalServerError)
+		return
+	}
+}
+
+// validServiceAccountRole validates the service account assumes the role of
+// the target, writing the error response otherwise.
+func (h handler) validServiceAccountRole(ctx context.Context, w http.ResponseWriter, l log.Logger, serviceAccount, roleARN string) bool {
+	err := workload.ValidateRole(ctx, h.serviceAccounts, serviceAccount, roleARN)
+	if errors.Is(err, workload.ErrServiceAccountNotFound) || errors.Is(err, workload.ErrRoleMismatch) {
+		level.Error(l).Log("message", "error invalid service account", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading service account", "error", err)
+		h.errorResponse(w, "error reading service account", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// Creates or updates the Ansible inventory of a target, the host credentials
+// are stored in Vault.
+func (h handler) putTargetInventory(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	ctx := rs.ctx
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "put-target-inventory", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var tir requests.PutTargetInventory
+	if err := json.Unmarshal(reqBody, &tir); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := tir.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if tir.Groups == nil {
+		tir.Groups = map[string][]string{}
+	}
+	if tir.Vars == nil {
+		tir.Vars = map[string]string{}
+	}
+
+	hosts, err := json.Marshal(tir.Hosts)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing hosts", "error", err)
+		h.errorResponse(w, "error serializing hosts", http.StatusInternalServerError)
+		return
+	}
+	groups, err := json.Marshal(tir.Groups)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing groups", "error", err)
+		h.errorResponse(w, "error serializing groups", http.StatusInternalServerError)
+		return
+	}
+	inventoryVars, err := json.Marshal(tir.Vars)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing vars", "error", err)
+		h.errorResponse(w, "error serializing vars", http.StatusInternalServerError)
+		return
+	}
+
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target host credentials")
+	err = cp.PutTargetHostCredentials(projectName, targetName, credentials.HostCredentials{
+		User:       tir.User,
+		PrivateKey: tir.PrivateKey,
+		Password:   tir.Password,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target host credentials", "error", err)
+		h.errorResponse(w, "error storing target host credentials", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target inventory")
+	err = h.dbClient.CreateTargetInventoryEntry(ctx, db.TargetInventoryEntry{
+		Project:    projectName,
+		Target:     targetName,
+		Connection: tir.Connection,
+		User:       tir.User,
+		Port:       tir.Port,
+		Hosts:      string(hosts),
+		Groups:     string(groups),
+		Vars:       string(inventoryVars),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target inventory", "error", err)
+		h.errorResponse(w, "error storing target inventory", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetInventory{
+		Connection: tir.Connection,
+		User:       tir.User,
+		Port:       tir.Port,
+		Hosts:      tir.Hosts,
+		Groups:     tir.Groups,
+		Vars:       tir.Vars,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the Ansible inventory of a target, without the host credentials
+func (h handler) getTargetInventory(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target-inventory", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	inv, err := h.targetInventory(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "inventory not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target inventory", "error", err)
+		h.errorResponse(w, "error reading target inventory", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetInventory{
+		Connection: inv.Connection,
+		User:       inv.User,
+		Port:       inv.Port,
+		Hosts:      inv.Hosts,
+		Groups:     inv.Groups,
+		Vars:       inv.Vars,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the Ansible inventory and host credentials of a target
+func (h handler) deleteTargetInventory(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target-inventory", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	if err := cp.DeleteTargetHostCredentials(projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target host credentials", "error", err)
+		h.errorResponse(w, "error deleting target host credentials", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetInventoryEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target inventory", "error", err)
+		h.errorResponse(w, "error deleting target inventory", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Reads the Ansible inventory of a target, upper's ErrNoMoreRows when it has
+// none.
+func (h handler) targetInventory(ctx context.Context, projectName, targetName string) (inventory.Inventory, error) {
+	e, err := h.dbClient.ReadTargetInventoryEntry(ctx, projectName, targetName)
+	if err != nil {
+		return inventory.Inventory{}, err
+	}
+
+	inv := inventory.Inventory{Connection: e.Connection, User: e.User, Port: e.Port}
+	if err := json.Unmarshal([]byte(e.Hosts), &inv.Hosts); err != nil {
+		return inventory.Inventory{}, fmt.Errorf("invalid hosts: %w", err)
+	}
+	if err := json.Unmarshal([]byte(e.Groups), &inv.Groups); err != nil {
+		return inventory.Inventory{}, fmt.Errorf("invalid groups: %w", err)
+	}
+	if err := json.Unmarshal([]byte(e.Vars), &inv.Vars); err != nil {
+		return inventory.Inventory{}, fmt.Errorf("invalid vars: %w", err)
+	}
+	return inv, nil
+}
+
+// errNoInventory is returned by frameworkParameters for ansible workflows of
+// targets without an inventory.
+var errNoInventory = errors.New("target has no inventory")
+
+// Returns the typed parameters of the framework of a workflow, rendering the
+// inventory of the target for ansible and adding the configured prune kinds
+// for kubectl. Frameworks registered by the config get their framework
+// parameters.
+func (h handler) frameworkParameters(ctx context.Context, cwr requests.CreateWorkflow) (frameworkParameters, error) {
+	fp := frameworkParameters{
+		helm:           cwr.Helm,
+		cloudFormation: cwr.CloudFormation,
+		ansible:        cwr.Ansible,
+		kubectl:        cwr.Kubectl,
+		parameters:     h.config.frameworkParameterValues(cwr),
+	}
+	if cwr.Kubectl != nil {
+		fp.pruneKinds = h.config.kubectlPruneKinds()
+	}
+	if cwr.Ansible == nil {
+		return fp, nil
+	}
+
+	inv, err := h.targetInventory(ctx, cwr.ProjectName, cwr.TargetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return frameworkParameters{}, errNoInventory
+	}
+	if err != nil {
+		return frameworkParameters{}, fmt.Errorf("error reading target inventory: %w", err)
+	}
+
+	if fp.inventory, err = inv.Render(); err != nil {
+		return frameworkParameters{}, fmt.Errorf("error rendering target inventory: %w", err)
+	}
+	return fp, nil
+}
+
+// Reads the scheduling constraints and workload identity of the workflows
+// for a target.
+func (h handler) workflowScheduling(ctx context.Context, projectName, targetName string) (workflow.Scheduling, error) {
+	s, err := h.targetScheduling(ctx, projectName, targetName)
+	if err != nil {
+		return workflow.Scheduling{}, err
+	}
+
+	wi, err := h.dbClient.ReadTargetWorkloadIdentityEntry(ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return s, nil
+	}
+	if err != nil {
+		return workflow.Scheduling{}, fmt.Errorf("error reading workload identity: %w", err)
+	}
+	s.ServiceAccountName = wi.ServiceAccount
+	return s, nil
+}
+
+// authorizedAdminTarget validates the request is from an admin and that the
+// target exists, writing the error response otherwise.
+func (h handler) authorizedAdminTarget(w http.ResponseWriter, r *http.Request, l log.Logger, projectName, targetName string) bool {
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := h.scope(r).authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return false
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return false
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return false
+	}
+
+	targetExists, err := cp.TargetExists(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return false
+	}
+	if !targetExists {
+		level.Error(l).Log("message", "target not found")
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": targetName}, http.StatusNotFound)
+		return false
+	}
+
+	return true
+}
+
+// Ensures a submission to a change controlled target has a change ticket,
+// creating one unless the request references an existing ticket, and waits
+// for its approval when the target requires it. Details (e.g. the change set
+// summary) are added to created tickets. Step up submissions (e.g. anomalous
+// ones) are change controlled and require approval whatever the target, dual
+// controlled ones also require the approval of dualControlApprovers distinct
+// principals other than the submitter, which is recorded as a
+// 'dual_control_approved' execution event, or 'dual_control_unmet' when they
+// don't approve in time. Returns false when an error response was written.
+// The change is empty for other targets.
+func (h handler) ensureChangeTicket(ctx context.Context, w http.ResponseWriter, l log.Logger, txID, principal string, cwr requests.CreateWorkflow, commitHash, details string, stepUp, dualControl bool) (itsm.Change, bool) {
+	stepUp = stepUp || dualControl
+	tc, err := h.dbClient.ReadTargetChangeControlEntry(ctx, cwr.ProjectName, cwr.TargetName)
+	if errors.Is(err, upper.ErrNoMoreRows) && stepUp {
+		tc = db.TargetChangeControlEntry{Project: cwr.ProjectName, Target: cwr.TargetName}
+	} else if errors.Is(err, upper.ErrNoMoreRows) {
+		// Targets without their own change control have the one of their
+		// environment or the project settings.
+		approval, err := h.defaultApproval(ctx, cwr.ProjectName, cwr.TargetName)
+		if err != nil {
+			level.Error(l).Log("message", "error reading default change control", "error", err)
+			h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+			return itsm.Change{}, false
+		}
+		if !approval.ChangeControl {
+			return itsm.Change{}, true
+		}
+		tc = db.TargetChangeControlEntry{Project: cwr.ProjectName, Target: cwr.TargetName, RequireApproval: approval.RequireApproval}
+	} else if err != nil {
+		level.Error(l).Log("message", "error reading target change control", "error", err)
+		h.errorResponse(w, "error reading target change control", http.StatusInternalServerError)
+		return itsm.Change{}, false
+	}
+	if stepUp {
+		tc.RequireApproval = true
+	}
+
+	if h.itsm == nil {
+		level.Error(l).Log("message", "target is change controlled but no itsm provider is configured")
+		h.errorResponse(w, "target is change controlled but change tickets aren't configured", http.StatusInternalServerError)
+		return itsm.Change{}, false
+	}
+
+	var change itsm.Change
+	if cwr.ChangeTicket != "" {
+		level.Debug(l).Log("message", "verifying change ticket", "change-ticket", cwr.ChangeTicket)
+		change, err = h.itsm.GetChange(ctx, cwr.ChangeTicket)
+		if errors.Is(err, itsm.ErrNotFound) {
+			h.errorResponse(w, fmt.Sprintf("change ticket '%s' not found", cwr.ChangeTicket), http.StatusBadRequest)
+			return itsm.Change{}, false
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error verifying change ticket", "error", err)
+			h.errorResponse(w, "error verifying change ticket", http.StatusInternalServerError)
+			return itsm.Change{}, false
+		}
+	} else {
+		level.Debug(l).Log("message", "creating change ticket")
+		change, err = h.itsm.CreateChange(ctx, itsm.ChangeRequest{
+			Project:    cwr.ProjectName,
+			Target:     cwr.TargetName,
+			Type:       cwr.Type,
+			CommitHash: commitHash,
+			Summary:    fmt.Sprintf("Cello %s of %s/%s", cwr.Type, cwr.ProjectName, cwr.TargetName),
+			TxID:       txID,
+			Details:    details,
+		})
+		if err != nil {
+			level.Error(l).Log("message", "error creating change ticket", "error", err)
+			h.errorResponse(w, "error creating change ticket", http.StatusInternalServerError)
+			return itsm.Change{}, false
+		}
+	}
+	l = log.With(l, "change-ticket", change.ID)
+
+	waitCtx, cancel := context.WithTimeout(ctx, h.env.ITSMApprovalTimeout)
+	defer cancel()
+
+	if tc.RequireApproval && change.State == itsm.StatePending {
+		level.Info(l).Log("message", "waiting for change ticket approval")
+		change, err = itsm.WaitForApproval(waitCtx, h.itsm, change, h.env.ITSMPollInterval)
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.errorResponse(w, fmt.Sprintf("change ticket '%s' wasn't approved within %s", change.ID, h.env.ITSMApprovalTimeout), http.StatusConflict)
+			return itsm.Change{}, false
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error waiting for change ticket approval", "error", err)
+			h.errorResponse(w, "error waiting for change ticket approval", http.StatusInternalServerError)
+			return itsm.Change{}, false
+		}
+	}
+
+	if change.State == itsm.StateRejected {
+		level.Info(l).Log("message", "change ticket rejected")
+		h.errorResponse(w, fmt.Sprintf("change ticket '%s' is rejected", change.ID), http.StatusConflict)
+		return itsm.Change{}, false
+	}
+
+	if dualControl {
+		level.Info(l).Log("message", "waiting for dual control approvers")
+		approvers, err := itsm.WaitForApprovers(waitCtx, h.itsm, change.ID, dualControlApprovers, principal, h.env.ITSMPollInterval)
+		if errors.Is(err, context.DeadlineExceeded) {
+			level.Warn(l).Log("message", "dual control unmet", "approvers", strings.Join(approvers, ","))
+			h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+				TxID:      txID,
+				Project:   cwr.ProjectName,
+				Target:    cwr.TargetName,
+				Type:      "dual_control_unmet",
+				Message:   fmt.Sprintf("change ticket %s of %s submitted by %s approved by %d of %d principals other than the submitter (%s) within %s", change.ID, cwr.Type, principal, len(approvers), dualControlApprovers, strings.Join(approvers, ", "), h.env.ITSMApprovalTimeout),
+				CreatedAt: h.now().UTC(),
+			})
+			h.errorResponse(w, fmt.Sprintf("change ticket '%s' wasn't approved by %d principals other than the submitter within %s", change.ID, dualControlApprovers, h.env.ITSMApprovalTimeout), http.StatusConflict)
+			return itsm.Change{}, false
+		}
+		if err != nil {
+			level.Error(l).Log("message", "error reading change ticket approvers", "error", err)
+			h.errorResponse(w, "error reading change ticket approvers", http.StatusInternalServerError)
+			return itsm.Change{}, false
+		}
+
+		level.Info(l).Log("message", "dual control approved", "approvers", strings.Join(approvers, ","))
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:      txID,
+			Project:   cwr.ProjectName,
+			Target:    cwr.TargetName,
+			Type:      "dual_control_approved",
+			Message:   fmt.Sprintf("change ticket %s of %s submitted by %s approved by %s", change.ID, cwr.Type, principal, strings.Join(approvers, ", ")),
+			CreatedAt: h.now().UTC(),
+		})
+	}
+
+	return change, true
+}
+
+// Puts (creates or replaces) the change control of a target
+func (h handler) putTargetChangeControl(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "put-target-change-control", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var tcr requests.PutTargetChangeControl
+	if err := json.Unmarshal(reqBody, &tcr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target change control")
+	err = h.dbClient.CreateTargetChangeControlEntry(rs.ctx, db.TargetChangeControlEntry{
+		Project:         projectName,
+		Target:          targetName,
+		RequireApproval: tcr.RequireApproval,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing target change control", "error", err)
+		h.errorResponse(w, "error storing target change control", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetChangeControl(tcr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the change control of a target
+func (h handler) getTargetChangeControl(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "get-target-change-control", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	tc, err := h.dbClient.ReadTargetChangeControlEntry(rs.ctx, projectName, targetName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "change control not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading target change control", "error", err)
+		h.errorResponse(w, "error reading target change control", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetTargetChangeControl{RequireApproval: tc.RequireApproval})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the change control of a target
+func (h handler) deleteTargetChangeControl(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+
+	l := rs.log("op", "delete-target-change-control", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetChangeControlEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target change control", "error", err)
+		h.errorResponse(w, "error deleting target change control", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Watches a submitted workflow when its target has a guardrail. A breach is
+// recorded as a 'failed_by_guardrail' execution event. Errors reading the
+// guardrail are logged as the workflow has already been submitted.
+func (h handler) watchGuardrail(ctx context.Context, l log.Logger, txID, projectName, targetName, workflowName string) {
+	if h.guardrails == nil {
+		return
+	}
+
+	tg, err := h.dbClient.ReadTargetGuardrailEntry(ctx, projectName, targetName)
+	if err != nil {
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Error(l).Log("message", "error reading target guardrail, workflow won't be guarded", "error", err)
+		}
+		return
+	}
+
+	rule := guardrail.Rule{
+		Provider:  tg.Provider,
+		Query:     tg.Query,
+		Threshold: tg.Threshold,
+		Operator:  tg.Operator,
+	}
+
+	level.Debug(l).Log("message", "watching workflow guardrail")
+	// The request context is done once the response is written.
+	go h.guardrails.Watch(h.argoCtx, workflowName, rule, func(b guardrail.Breach) {
+		message := fmt.Sprintf("terminated, %s", b)
+		if b.AfterCompletion {
+			message = fmt.Sprintf("breached after completion, %s", b)
+		}
+
+		h.recordExecutionEvent(h.argoCtx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      projectName,
+			Target:       targetName,
+			WorkflowName: workflowName,
+			Type:         "failed_by_guardrail",
+			Message:      message,
+			CreatedAt:    h.now().UTC(),
+		})
+	})
+}
+
+// Puts (creates or replaces) a notification rule of a project
+func (h handler) putProjectNotificationRule(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-notification-rule", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var nrr requests.PutProjectNotificationRule
+	if err := json.Unmarshal(reqBody, &nrr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := nrr.Validate(nrr.ValidateType(h.notifications.Types())); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing project notification rule")
+	err = h.dbClient.CreateProjectNotificationRuleEntry(rs.ctx, db.ProjectNotificationRuleEntry{
+		Project:             projectName,
+		Type:                nrr.Type,
+		RoutingKey:          nrr.RoutingKey,
+		ConsecutiveFailures: nrr.ConsecutiveFailures,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing project notification rule", "error", err)
+		h.errorResponse(w, "error storing project notification rule", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.ProjectNotificationRule(nrr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the notification rules of a project
+func (h handler) getProjectNotificationRules(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-notification-rules", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	rules, err := h.dbClient.ListProjectNotificationRuleEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project notification rules", "error", err)
+		h.errorResponse(w, "error reading project notification rules", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetProjectNotificationRules{}
+	for _, nr := range rules {
+		resp = append(resp, responses.ProjectNotificationRule{
+			Type:                nr.Type,
+			RoutingKey:          nr.RoutingKey,
+			ConsecutiveFailures: nr.ConsecutiveFailures,
+		})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes a notification rule of a project
+func (h handler) deleteProjectNotificationRule(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	ruleType := vars["ruleType"]
+
+	l := rs.log("op", "delete-project-notification-rule", "project", projectName, "type", ruleType)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteProjectNotificationRuleEntry(rs.ctx, projectName, ruleType); err != nil {
+		level.Error(l).Log("message", "error deleting project notification rule", "error", err)
+		h.errorResponse(w, "error deleting project notification rule", http.StatusInternalServerError)
+		return
+	}
+}
+
+// authorizedAdminProject validates the request is from an admin and that the
+// project exists, writing the error response otherwise.
+func (h handler) authorizedAdminProject(w http.ResponseWriter, r *http.Request, l log.Logger, projectName string) bool {
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := h.scope(r).authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return false
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return false
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return false
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return false
+	}
+	if !projectExists {
+		level.Error(l).Log("message", "project not found")
+		h.messageResponse(w, r, messages.ProjectNotFound, messages.Params{"project": projectName}, http.StatusNotFound)
+		return false
+	}
+
+	return true
+}
+
+// Watches a submitted workflow when its project has notification rules. Sent
+// notifications are recorded as 'notification_sent' execution events. Errors
+// reading the rules are logged as the workflow has already been submitted.
+func (h handler) watchNotificationRules(ctx context.Context, l log.Logger, txID, projectName, targetName, commitHash, workflowName string) {
+	if h.notifications == nil {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectNotificationRuleEntries(ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project notification rules, workflow won't be watched", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	rules := make([]notify.Rule, 0, len(entries))
+	for _, nr := range entries {
+		rules = append(rules, notify.Rule{
+			Type:                nr.Type,
+			RoutingKey:          nr.RoutingKey,
+			ConsecutiveFailures: nr.ConsecutiveFailures,
+		})
+	}
+
+	failure := notify.Failure{
+		Project:      projectName,
+		Target:       targetName,
+		WorkflowName: workflowName,
+		CommitHash:   commitHash,
+		Links:        []notify.Link{notify.WorkflowLink(h.env.ArgoAddress, h.env.ArgoNamespace, workflowName)},
+	}
+	if commitHash != "" {
+		pe, err := h.dbClient.ReadProjectEntry(ctx, projectName)
+		if err != nil {
+			level.Warn(l).Log("message", "error reading project data, notifications won't link the diff", "error", err)
+		} else if link, ok := notify.CommitLink(pe.Repository, commitHash); ok {
+			failure.Links = append(failure.Links, link)
+		}
+	}
+
+	level.Debug(l).Log("message", "watching workflow for notification rules")
+	// The request context is done once the response is written.
+	go h.notifications.Watch(h.argoCtx, failure, rules, func(rule notify.Rule, f notify.Failure) {
+		h.recordExecutionEvent(h.argoCtx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      projectName,
+			Target:       targetName,
+			WorkflowName: workflowName,
+			Type:         "notification_sent",
+			Message:      fmt.Sprintf("%s notified after %d consecutive failures", rule.Type, f.ConsecutiveFailures),
+			CreatedAt:    h.now().UTC(),
+		})
+	})
+}
+
+// Maximum time a cloudformation diff is watched for its change set summary.
+const changeSetWatchTimeout = time.Hour
+
+// Watches a cloudformation diff until it completes to record the summary of
+// the change set it created, see recordChangeSetSummary.
+func (h handler) watchChangeSet(l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	if cwr.CloudFormation == nil || cwr.Type != "diff" || h.env.ChangeSetWatchInterval <= 0 {
+		return
+	}
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, changeSetWatchTimeout)
+		defer cancel()
+		h.recordChangeSetSummary(ctx, l, txID, cwr, workflowName)
+	}()
+}
+
+// Records the summary of the change set created by a completed cloudformation
+// diff, read from its logs, as a 'change_set_summary' execution event and for
+// the change tickets of the sync executing it. Failures are logged as the
+// summary is informational.
+func (h handler) recordChangeSetSummary(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	status, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.ChangeSetWatchInterval, func(err error) {
+		level.Warn(l).Log("message", "error getting workflow status", "error", err)
+	})
+	if err != nil {
+		return
+	}
+	if status.Status != "succeeded" {
+		level.Info(l).Log("message", "diff didn't succeed, no change set summary", "status", status.Status)
+		return
+	}
+
+	logs, err := h.argo.Logs(ctx, workflowName, workflow.LogOptions{})
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logs", "error", err)
+		return
+	}
+	var lines []string
+	if logs != nil {
+		lines = logs.Logs
+	}
+	summary, ok, err := changeset.FromLogs(lines)
+	if err != nil {
+		level.Error(l).Log("message", "error reading change set summary", "error", err)
+		return
+	}
+	if !ok {
+		level.Warn(l).Log("message", "no change set summary in workflow logs")
+		return
+	}
+
+	err = h.dbClient.CreateChangeSetSummaryEntry(ctx, db.ChangeSetSummaryEntry{
+		Project:       cwr.ProjectName,
+		Target:        cwr.TargetName,
+		StackName:     cwr.CloudFormation.StackName,
+		ChangeSetName: cwr.CloudFormation.ChangeSetName,
+		WorkflowName:  workflowName,
+		Summary:       summary.String(),
+		CreatedAt:     h.now().UTC(),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing change set summary", "error", err)
+	}
+
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "change_set_summary",
+		Message:      summary.String(),
+		CreatedAt:    h.now().UTC(),
+	})
+}
+
+// Returns the summary of the change set a cloudformation sync executes,
+// recorded when its diff completed. Empty for other submissions or when
+// there's none, e.g. because the diff is still running.
+func (h handler) readChangeSetSummary(ctx context.Context, l log.Logger, cwr requests.CreateWorkflow) string {
+	if cwr.CloudFormation == nil || cwr.Type != "sync" {
+		return ""
+	}
+
+	cs, err := h.dbClient.ReadChangeSetSummaryEntry(ctx, cwr.ProjectName, cwr.TargetName, cwr.CloudFormation.StackName, cwr.CloudFormation.ChangeSetName)
+	if err != nil {
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Warn(l).Log("message", "error reading change set summary", "error", err)
+		}
+		return ""
+	}
+	return cs.Summary
+}
+
+// Checks a submission to the target is within the business hours of its
+// project, if any. Returns false when an error response was written, naming
+// the next window submissions are allowed in.
+func (h handler) withinBusinessHours(ctx context.Context, w http.ResponseWriter, r *http.Request, l log.Logger, projectName, targetName string) bool {
+	err := h.businessHoursAt(ctx, projectName, targetName, h.now)
+	var outside outsideBusinessHoursError
+	if errors.As(err, &outside) {
+		level.Info(l).Log("message", "submission outside of business hours", "business-hours", outside.hours, "next-window", outside.next)
+		h.messageResponse(w, r, messages.OutsideBusinessHours, outside.params(projectName), http.StatusConflict)
+		return false
+	}
+	if errors.Is(err, errInvalidBusinessHours) {
+		level.Error(l).Log("message", "error invalid project business hours", "error", err)
+		h.errorResponse(w, "error invalid project business hours", http.StatusInternalServerError)
+		return false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project business hours", "error", err)
+		h.errorResponse(w, "error reading project business hours", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// errInvalidBusinessHours is returned for stored business hours which can't
+// be parsed.
+var errInvalidBusinessHours = errors.New("invalid project business hours")
+
+// outsideBusinessHoursError is returned for submissions outside of the
+// business hours of the project of the target.
+type outsideBusinessHoursError struct {
+	target string
+	hours  schedule.BusinessHours
+	next   time.Time
+}
+
+func (e outsideBusinessHoursError) Error() string {
+	return fmt.Sprintf("target '%s' only accepts submissions during business hours (%s), next window opens %s", e.target, e.hours, e.next.Format("Mon 2006-01-02 15:04 MST"))
+}
+
+// params returns the parameters of the message of the error.
+func (e outsideBusinessHoursError) params(projectName string) messages.Params {
+	return messages.Params{"project": projectName, "target": e.target, "hours": e.hours.String(), "next": e.next.Format("Mon 2006-01-02 15:04 MST")}
+}
+
+// businessHoursAt is withinBusinessHours without the error response, for a
+// submission at the time returned by at. It's only called for restricted
+// targets.
+func (h handler) businessHoursAt(ctx context.Context, projectName, targetName string, at func() time.Time) error {
+	entry, err := h.dbClient.ReadProjectBusinessHoursEntry(ctx, projectName)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			return nil
+		}
+		return err
+	}
+
+	if entry.Targets != "" {
+		restricted := false
+		for _, t := range strings.Split(entry.Targets, ",") {
+			restricted = restricted || t == targetName
+		}
+		if !restricted {
+			return nil
+		}
+	}
+
+	bh, err := schedule.NewBusinessHours(entry.Timezone, strings.Split(entry.Days, ","), entry.Start, entry.End)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidBusinessHours, err)
+	}
+
+	now := at()
+	if bh.Contains(now) {
+		return nil
+	}
+	return outsideBusinessHoursError{target: targetName, hours: bh, next: bh.Next(now)}
+}
+
+// Resolves the workflow template a workflow is created from, e.g.
+// 'workflowtemplate/name'. WorkflowTemplates of the config are allowed for all
+// projects, other templates must be allowed for the project and are reported
+// as referenced. Returns false when an error response was written.
+func (h handler) resolveWorkflowTemplate(ctx context.Context, w http.ResponseWriter, l log.Logger, cwr requests.CreateWorkflow) (string, bool, bool) {
+	from, referenced, err := h.workflowTemplateFrom(ctx, cwr)
+	var notAllowed workflowTemplateNotAllowedError
+	if errors.As(err, &notAllowed) {
+		level.Error(l).Log("message", "workflow template not allowed", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error invalid request, %s", err), http.StatusBadRequest)
+		return "", false, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project workflow templates", "error", err)
+		h.errorResponse(w, "error reading project workflow templates", http.StatusInternalServerError)
+		return "", false, false
+	}
+	return from, referenced, true
+}
+
+// workflowTemplateNotAllowedError is returned for workflow templates which
+// aren't allowed for the project.
+type workflowTemplateNotAllowedError struct {
+	kind, name, project string
+}
+
+func (e workflowTemplateNotAllowedError) Error() string {
+	return fmt.Sprintf("%s '%s' isn't allowed for project '%s'", e.kind, e.name, e.project)
+}
+
+// workflowTemplateFrom is resolveWorkflowTemplate without the error response.
+func (h handler) workflowTemplateFrom(ctx context.Context, cwr requests.CreateWorkflow) (string, bool, error) {
+	kind := cwr.WorkflowTemplateKind
+	if kind == "" {
+		kind = workflow.KindWorkflowTemplate
+	}
+	from := fmt.Sprintf("%s/%s", strings.ToLower(kind), cwr.WorkflowTemplateName)
+
+	templates, err := h.dbClient.ListProjectWorkflowTemplateEntries(ctx, cwr.ProjectName)
+	if err != nil {
+		return "", false, err
+	}
+	for _, wt := range templates {
+		if wt.Kind == kind && wt.Name == cwr.WorkflowTemplateName {
+			return from, true, nil
+		}
+	}
+
+	if kind == workflow.KindWorkflowTemplate && h.config.allowsWorkflowTemplate(cwr.WorkflowTemplateName) {
+		return from, false, nil
+	}
+
+	return "", false, workflowTemplateNotAllowedError{kind: kind, name: cwr.WorkflowTemplateName, project: cwr.ProjectName}
+}
+
+// Adds the request parameters to the parameters of a workflow created from a
+// referenced template, which declares its own parameters. They can't override
+// the ones of the service.
+func addReferencedParameters(parameters, requested map[string]string) {
+	for k, v := range requested {
+		if _, ok := parameters[k]; !ok {
+			parameters[k] = v
+		}
+	}
+}
+
+// Puts (allows) a workflow template for a project
+func (h handler) putProjectWorkflowTemplate(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-workflow-template", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var wtr requests.PutProjectWorkflowTemplate
+	if err := json.Unmarshal(reqBody, &wtr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := wtr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing project workflow template")
+	err = h.dbClient.CreateProjectWorkflowTemplateEntry(rs.ctx, db.ProjectWorkflowTemplateEntry{
+		Project: projectName,
+		Kind:    wtr.Kind,
+		Name:    wtr.Name,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing project workflow template", "error", err)
+		h.errorResponse(w, "error storing project workflow template", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.ProjectWorkflowTemplate(wtr))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the workflow templates allowed for a project
+func (h handler) getProjectWorkflowTemplates(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-workflow-templates", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	templates, err := h.dbClient.ListProjectWorkflowTemplateEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project workflow templates", "error", err)
+		h.errorResponse(w, "error reading project workflow templates", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetProjectWorkflowTemplates{}
+	for _, wt := range templates {
+		resp = append(resp, responses.ProjectWorkflowTemplate{Kind: wt.Kind, Name: wt.Name})
+	}
+
+	data, err := json.Marshal(resp)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow", "error", err)
-		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprint(w, string(jsonData))
+	fmt.Fprint(w, string(data))
 }
 
-// Gets a target
-func (h handler) getTarget(w http.ResponseWriter, r *http.Request) {
+// Deletes (disallows) a workflow template of a project
+func (h handler) deleteProjectWorkflowTemplate(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	projectName := vars["projectName"]
-	targetName := vars["targetName"]
+	projectName := rs.project
+	kind := vars["kind"]
+	name := vars["name"]
 
-	l := h.requestLogger(r, "op", "get-target", "project", projectName, "target", targetName)
+	l := rs.log("op", "delete-project-workflow-template", "project", projectName, "kind", kind, "workflow-template", name)
 
-	level.Debug(l).Log("message", "validating authorization header for get target")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
-	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+	if !h.authorizedAdminProject(w, r, l, projectName) {
 		return
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+
+	if err := h.dbClient.DeleteProjectWorkflowTemplateEntry(rs.ctx, projectName, kind, name); err != nil {
+		level.Error(l).Log("message", "error deleting project workflow template", "error", err)
+		h.errorResponse(w, "error deleting project workflow template", http.StatusInternalServerError)
 		return
 	}
+}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
-	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+// Puts (creates or replaces) a feature flag override of a project
+func (h handler) putProjectFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-feature-flag", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
 		return
 	}
 
-	targetExists, err := cp.TargetExists(projectName, targetName)
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
 
-	if !targetExists {
-		level.Error(l).Log("message", "target not found")
-		h.errorResponse(w, "target not found", http.StatusNotFound)
+	var ffr requests.PutProjectFeatureFlag
+	if err := json.Unmarshal(reqBody, &ffr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "getting target information")
-	targetInfo, err := cp.GetTarget(projectName, targetName)
+	if err := ffr.Validate(ffr.ValidateName(feature.Names())); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing project feature flag")
+	err = h.dbClient.CreateProjectFeatureFlagEntry(rs.ctx, db.ProjectFeatureFlagEntry{
+		Project: projectName,
+		Name:    ffr.Name,
+		Enabled: ffr.Enabled,
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target information", "error", err)
-		h.errorResponse(w, "error retrieving target information", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error storing project feature flag", "error", err)
+		h.errorResponse(w, "error storing project feature flag", http.StatusInternalServerError)
 		return
 	}
 
-	jsonResult, err := json.Marshal(targetInfo)
+	data, err := json.Marshal(responses.ProjectFeatureFlag{Name: ffr.Name, Enabled: ffr.Enabled, Source: feature.SourceProject})
 	if err != nil {
-		level.Error(l).Log("message", "error serializing json target data", "error", err)
-		h.errorResponse(w, "error serializing json target data", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprint(w, string(jsonResult))
+	fmt.Fprint(w, string(data))
 }
 
-// Returns the logs for a workflow
-func (h handler) getWorkflowLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	workflowName := vars["workflowName"]
+// Gets the state of every feature flag for a project
+func (h handler) getProjectFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
 
-	l := h.requestLogger(r, "op", "get-workflow-logs", "workflow", workflowName)
+	l := rs.log("op", "get-project-feature-flags", "project", projectName)
 
-	level.Debug(l).Log("message", "retrieving workflow logs")
-	argoWorkflowLogs, err := h.argo.Logs(h.argoCtx, workflowName)
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	overrides, err := h.projectFeatureFlags(rs.ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error getting workflow logs", "error", err)
-		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading project feature flags", "error", err)
+		h.errorResponse(w, "error reading project feature flags", http.StatusInternalServerError)
 		return
 	}
 
-	jsonData, err := json.Marshal(argoWorkflowLogs)
+	resp := responses.GetProjectFeatureFlags{}
+	for _, s := range h.features.States(overrides) {
+		resp = append(resp, responses.ProjectFeatureFlag{Name: s.Name, Enabled: s.Enabled, Source: s.Source})
+	}
+
+	data, err := json.Marshal(resp)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing workflow logs", "error", err)
-		h.errorResponse(w, "error serializing workflow logs", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
-	fmt.Fprintln(w, string(jsonData))
+
+	fmt.Fprint(w, string(data))
 }
 
-// Streams workflow logs
-func (h handler) getWorkflowLogStream(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("X-Accel-Buffering", "no")
+// Deletes a feature flag override of a project
+func (h handler) deleteProjectFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	workflowName := vars["workflowName"]
+	projectName := rs.project
+	name := vars["name"]
 
-	l := h.requestLogger(r, "op", "get-workflow-log-stream", "workflow", workflowName)
+	l := rs.log("op", "delete-project-feature-flag", "project", projectName, "feature-flag", name)
 
-	level.Debug(l).Log("message", "retrieving workflow logs", "workflow", workflowName)
-	err := h.argo.LogStream(h.argoCtx, workflowName, w)
-	if err != nil {
-		level.Error(l).Log("message", "error getting workflow logstream", "error", err)
-		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteProjectFeatureFlagEntry(rs.ctx, projectName, name); err != nil {
+		level.Error(l).Log("message", "error deleting project feature flag", "error", err)
+		h.errorResponse(w, "error deleting project feature flag", http.StatusInternalServerError)
 		return
 	}
 }
 
-// Returns a new token
-func newArgoCloudOpsToken(provider, key, secret string) *token {
-	return &token{
-		Token: fmt.Sprintf("%s:%s:%s", provider, key, secret),
+// Returns the feature flag overrides of a project keyed by name.
+func (h handler) projectFeatureFlags(ctx context.Context, projectName string) (map[string]bool, error) {
+	entries, err := h.dbClient.ListProjectFeatureFlagEntries(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]bool{}
+	for _, ff := range entries {
+		overrides[ff.Name] = ff.Enabled
 	}
+	return overrides, nil
 }
 
-// Creates a project
-func (h handler) createProject(w http.ResponseWriter, r *http.Request) {
-	l := h.requestLogger(r, "op", "create-project")
+// Reports whether the feature flag is enabled for the project, or globally
+// when the project is empty. Errors reading the overrides of the project are
+// logged and fall back to the global state.
+func (h handler) featureEnabled(ctx context.Context, l log.Logger, projectName, name string) bool {
+	if projectName == "" {
+		return h.features.Enabled(name, nil)
+	}
 
-	level.Debug(l).Log("message", "validating authorization header for create project")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	overrides, err := h.projectFeatureFlags(ctx, projectName)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
-		return
+		level.Warn(l).Log("message", "error reading project feature flags", "feature-flag", name, "error", err)
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+	return h.features.Enabled(name, overrides)
+}
+
+// requireFeature responds like an unknown route unless the feature flag is
+// enabled for the project of the route, or globally for routes without one.
+func (h handler) requireFeature(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs := h.scope(r)
+		l := rs.log("op", "require-feature", "project", rs.project, "feature-flag", name)
+
+		if !h.featureEnabled(rs.ctx, l, rs.project, name) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin responds 401 unless the request has the admin authorization.
+func (h handler) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs := h.scope(r)
+
+		a, err := rs.authorization()
+		if err != nil {
+			h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+			return
+		}
+		if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+			h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Puts (creates or replaces) the credentials of a project for a registry
+func (h handler) putProjectRegistryCredential(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-registry-credential", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
 		return
 	}
 
-	ctx := r.Context()
-
-	var capp requests.CreateProject
+	level.Debug(l).Log("message", "reading request body")
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		level.Error(l).Log("message", "error reading request body", "error", err)
-		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
-	if err := json.Unmarshal(reqBody, &capp); err != nil {
-		level.Error(l).Log("message", "error decoding request", "error", err)
-		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+
+	var rcr requests.PutProjectRegistryCredential
+	if err := json.Unmarshal(reqBody, &rcr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
-	if err := capp.Validate(); err != nil {
+
+	if err := rcr.Validate(); err != nil {
 		level.Error(l).Log("message", "error invalid request", "error", err)
-		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
 
-	l = log.With(l, "project", capp.Name)
+	entries, err := h.dbClient.ListProjectRegistryCredentialEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project registry credentials", "error", err)
+		h.errorResponse(w, "error reading project registry credentials", http.StatusInternalServerError)
+		return
+	}
+	former, replaced := findRegistryCredential(entries, rcr.Registry)
 
 	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	cp, err := h.adminCredentialsProvider(r.Header)
 	if err != nil {
 		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
 		return
 	}
 
-	projectExists, err := cp.ProjectExists(capp.Name)
-	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+	// The password is kept by the credentials provider, like every other
+	// secret, under a new reference so the former one is kept until the
+	// entry is replaced.
+	ref := uuid.NewString()
+	level.Debug(l).Log("message", "storing project registry password", "registry", rcr.Registry)
+	if err := cp.PutRegistryPassword(ref, rcr.Password); err != nil {
+		level.Error(l).Log("message", "error storing project registry password", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error storing project registry credential", http.StatusInternalServerError)
 		return
 	}
 
-	if projectExists {
-		level.Error(l).Log("error", "project already exists")
-		h.errorResponse(w, "project already exists", http.StatusBadRequest)
+	level.Debug(l).Log("message", "storing project registry credential", "registry", rcr.Registry)
+	err = h.dbClient.CreateProjectRegistryCredentialEntry(rs.ctx, db.ProjectRegistryCredentialEntry{
+		Project:     projectName,
+		Registry:    rcr.Registry,
+		Username:    rcr.Username,
+		PasswordRef: ref,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing project registry credential", "error", err)
+		if err := cp.DeleteRegistryPassword(ref); err != nil {
+			level.Error(l).Log("message", "error deleting unreferenced project registry password", "error", err)
+		}
+		h.errorResponse(w, "error storing project registry credential", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "inserting into db")
-	err = h.dbClient.CreateProjectEntry(ctx, db.ProjectEntry{
-		ProjectID:  capp.Name,
-		Repository: capp.Repository,
-	})
+	if replaced && former.PasswordRef != "" {
+		if err := cp.DeleteRegistryPassword(former.PasswordRef); err != nil {
+			level.Warn(l).Log("message", "error deleting replaced project registry password", "error", err)
+		}
+	}
+
+	data, err := json.Marshal(responses.ProjectRegistryCredential{Registry: rcr.Registry, Username: rcr.Username})
 	if err != nil {
-		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
-	level.Debug(l).Log("message", "creating project")
-	role, secret, err := cp.CreateProject(capp.Name)
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the registries a project has credentials for
+func (h handler) getProjectRegistryCredentials(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-registry-credentials", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectRegistryCredentialEntries(rs.ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error creating project", "error", err)
-		h.errorResponse(w, "error creating project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading project registry credentials", "error", err)
+		h.errorResponse(w, "error reading project registry credentials", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "retrieving Cello token")
-	t := newArgoCloudOpsToken("vault", role, secret)
-	jsonResult, err := json.Marshal(t)
+	resp := responses.GetProjectRegistryCredentials{}
+	for _, rc := range entries {
+		resp = append(resp, responses.ProjectRegistryCredential{Registry: rc.Registry, Username: rc.Username})
+	}
+
+	data, err := json.Marshal(resp)
 	if err != nil {
-		level.Error(l).Log("message", "error serializing token", "error", err)
-		h.errorResponse(w, "error serializing token", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
-	fmt.Fprint(w, string(jsonResult))
+
+	fmt.Fprint(w, string(data))
 }
 
-// Get a project
-func (h handler) getProject(w http.ResponseWriter, r *http.Request) {
+// Deletes the credentials of a project for a registry
+func (h handler) deleteProjectRegistryCredential(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+	projectName := rs.project
+	registry := vars["registry"]
 
-	l := h.requestLogger(r, "op", "get-project", "project", projectName)
+	l := rs.log("op", "delete-project-registry-credential", "project", projectName, "registry", registry)
 
-	level.Debug(l).Log("message", "validating authorization header for get project")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectRegistryCredentialEntries(rs.ctx, projectName)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		level.Error(l).Log("message", "error reading project registry credentials", "error", err)
+		h.errorResponse(w, "error reading project registry credentials", http.StatusInternalServerError)
 		return
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+	rc, ok := findRegistryCredential(entries, registry)
+	if !ok {
 		return
 	}
 
 	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	cp, err := h.adminCredentialsProvider(r.Header)
 	if err != nil {
 		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "getting project")
-	resp, err := cp.GetProject(projectName)
-	if err != nil {
-		level.Error(l).Log("message", "error retrieving project", "error", err)
-		h.errorResponse(w, "error retrieving project", http.StatusNotFound)
+	if err := h.dbClient.DeleteProjectRegistryCredentialEntry(rs.ctx, projectName, registry); err != nil {
+		level.Error(l).Log("message", "error deleting project registry credential", "error", err)
+		h.errorResponse(w, "error deleting project registry credential", http.StatusInternalServerError)
 		return
 	}
 
-	data, err := json.Marshal(resp)
-	if err != nil {
-		level.Error(l).Log("message", "error creating response", "error", err)
-		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+	// The entry is gone so an error deleting its password is only logged.
+	h.deleteRegistryPassword(l, cp, rc)
+}
+
+// Deletes the password of registry credentials from the credentials
+// provider, errors are logged.
+func (h handler) deleteRegistryPassword(l log.Logger, cp credentials.Provider, rc db.ProjectRegistryCredentialEntry) {
+	if rc.PasswordRef == "" {
 		return
 	}
+	if err := cp.DeleteRegistryPassword(rc.PasswordRef); err != nil {
+		level.Warn(l).Log("message", "error deleting project registry password", "registry", rc.Registry, "error", err)
+	}
+}
 
-	fmt.Fprint(w, string(data))
+// Returns the registry credentials of the entries for the registry, false
+// when there's none.
+func findRegistryCredential(entries []db.ProjectRegistryCredentialEntry, registry string) (db.ProjectRegistryCredentialEntry, bool) {
+	for _, rc := range entries {
+		if rc.Registry == registry {
+			return rc, true
+		}
+	}
+	return db.ProjectRegistryCredentialEntry{}, false
 }
 
-// Delete a project
-func (h handler) deleteProject(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+// Returns the credentials of the project for the registry, nil when it has
+// none. The password is read from the credentials provider.
+func (h handler) registryCredentials(ctx context.Context, header http.Header, projectName, registry string) (*oci.Credentials, error) {
+	entries, err := h.dbClient.ListProjectRegistryCredentialEntries(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := findRegistryCredential(entries, registry)
+	if !ok {
+		return nil, nil
+	}
+
+	cp, err := h.adminCredentialsProvider(header)
+	if err != nil {
+		return nil, fmt.Errorf("error creating credentials provider: %w", err)
+	}
+	password, err := cp.GetRegistryPassword(rc.PasswordRef)
+	if err != nil {
+		return nil, fmt.Errorf("error reading password of registry '%s': %w", registry, err)
+	}
+	return &oci.Credentials{Username: rc.Username, Password: password}, nil
+}
 
-	l := h.requestLogger(r, "op", "delete-project", "project", projectName)
+// Puts (creates or replaces) the business hours of a project
+func (h handler) putProjectBusinessHours(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
 
-	level.Debug(l).Log("message", "validating authorization header for delete project")
-	ctx := r.Context()
+	l := rs.log("op", "put-project-business-hours", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
 
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
 		return
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+
+	var bhr requests.PutProjectBusinessHours
+	if err := json.Unmarshal(reqBody, &bhr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err := bhr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := schedule.NewBusinessHours(bhr.Timezone, bhr.Days, bhr.Start, bhr.End); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	days := make([]string, 0, len(bhr.Days))
+	for _, d := range bhr.Days {
+		days = append(days, strings.ToLower(d))
+	}
+	targets := bhr.Targets
+	if targets == nil {
+		targets = []string{}
+	}
+
+	level.Debug(l).Log("message", "storing project business hours")
+	err = h.dbClient.CreateProjectBusinessHoursEntry(rs.ctx, db.ProjectBusinessHoursEntry{
+		Project:  projectName,
+		Timezone: bhr.Timezone,
+		Days:     strings.Join(days, ","),
+		Start:    bhr.Start,
+		End:      bhr.End,
+		Targets:  strings.Join(targets, ","),
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error storing project business hours", "error", err)
+		h.errorResponse(w, "error storing project business hours", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "checking if project exists")
-	projectExists, err := cp.ProjectExists(projectName)
+	data, err := json.Marshal(responses.GetProjectBusinessHours{
+		Timezone: bhr.Timezone,
+		Days:     days,
+		Start:    bhr.Start,
+		End:      bhr.End,
+		Targets:  targets,
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
 
-	if !projectExists {
-		level.Debug(l).Log("message", "no action required because project does not exist")
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the business hours of a project
+func (h handler) getProjectBusinessHours(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-business-hours", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
 		return
 	}
 
-	level.Debug(l).Log("message", "getting all targets in project")
-	targets, err := cp.ListTargets(projectName)
+	bh, err := h.dbClient.ReadProjectBusinessHoursEntry(rs.ctx, projectName)
 	if err != nil {
-		level.Error(l).Log("message", "error getting all targets", "error", err)
-		h.errorResponse(w, "error getting all targets", http.StatusInternalServerError)
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "business hours not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading project business hours", "error", err)
+		h.errorResponse(w, "error reading project business hours", http.StatusInternalServerError)
 		return
 	}
 
-	if len(targets) > 0 {
-		level.Error(l).Log("error", "project has existing targets, not deleting")
-		h.errorResponse(w, "project has existing targets, not deleting", http.StatusBadRequest)
-		return
+	targets := []string{}
+	if bh.Targets != "" {
+		targets = strings.Split(bh.Targets, ",")
 	}
 
-	level.Debug(l).Log("message", "deleting project")
-	err = cp.DeleteProject(projectName)
+	data, err := json.Marshal(responses.GetProjectBusinessHours{
+		Timezone: bh.Timezone,
+		Days:     strings.Split(bh.Days, ","),
+		Start:    bh.Start,
+		End:      bh.End,
+		Targets:  targets,
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error deleting project", "error", err)
-		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(h.logger).Log("message", "deleting from db")
-	if err = h.dbClient.DeleteProjectEntry(ctx, projectName); err != nil {
-		level.Error(l).Log("message", "error deleting project in database", "error", err)
-		h.errorResponse(w, "error deleting project", http.StatusInternalServerError)
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the business hours of a project
+func (h handler) deleteProjectBusinessHours(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "delete-project-business-hours", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteProjectBusinessHoursEntry(rs.ctx, projectName); err != nil {
+		level.Error(l).Log("message", "error deleting project business hours", "error", err)
+		h.errorResponse(w, "error deleting project business hours", http.StatusInternalServerError)
 		return
 	}
 }
 
-// Creates a target
-func (h handler) createTarget(w http.ResponseWriter, r *http.Request) {
+// Puts (creates or replaces) a schedule of a target, applying its cron
+// workflow. Suspending a schedule suspends its cron workflow.
+func (h handler) putTargetSchedule(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+	projectName := rs.project
+	targetName := vars["targetName"]
+	scheduleName := vars["scheduleName"]
 
-	l := h.requestLogger(r, "op", "create-target", "project", projectName)
+	l := rs.log("op", "put-target-schedule", "project", projectName, "target", targetName, "schedule", scheduleName)
 
-	level.Debug(l).Log("message", "validating authorization header for create target")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
-	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
-		return
-	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "unauthorized", http.StatusUnauthorized)
+	ctx := rs.ctx
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
 		return
 	}
-	level.Debug(l).Log("message", "reading request body")
 
-	var ctr requests.CreateTarget
+	level.Debug(l).Log("message", "reading request body")
 	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		level.Error(l).Log("message", "error reading request data", "error", err)
 		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
 	}
 
-	if err := json.Unmarshal(reqBody, &ctr); err != nil {
-		level.Error(l).Log("message", "error processing request", "error", err)
-		h.errorResponse(w, "error processing request", http.StatusBadRequest)
+	var tsr requests.PutTargetSchedule
+	if err := json.Unmarshal(reqBody, &tsr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := types.Target(ctr).Validate(); err != nil {
+	cronWorkflowName := scheduleCronWorkflowName(projectName, targetName, scheduleName)
+	if err := tsr.Validate(tsr.ValidateCronWorkflowName(cronWorkflowName)); err != nil {
 		level.Error(l).Log("message", "error invalid request", "error", err)
 		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
 		return
 	}
 
-	l = log.With(l, "target", ctr.Name)
+	if _, err := time.LoadLocation(tsr.Timezone); err != nil {
+		level.Error(l).Log("message", "error invalid timezone", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, unknown timezone '%s'", tsr.Timezone), http.StatusBadRequest)
+		return
+	}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	cwr := tsr.Workflow
+	cwr.ProjectName = projectName
+	cwr.TargetName = targetName
+
+	types, err := rs.config.listTypes(cwr.Framework)
 	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error invalid framework", "error", err)
+		h.errorResponse(
+			w,
+			fmt.Sprintf("invalid request, framework must be one of '%s'", strings.Join(rs.config.listFrameworks(), " ")),
+			http.StatusBadRequest,
+		)
 		return
 	}
 
-	projectExists, err := cp.ProjectExists(projectName)
+	scheduling, err := h.targetScheduling(ctx, projectName, targetName)
 	if err != nil {
-		level.Error(l).Log("message", "error determining if project exists", "error", err)
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return
 	}
-
-	// TODO Perhaps this should be 404
-	if !projectExists {
-		level.Error(l).Log("message", "project does not exist")
-		h.errorResponse(w, "project does not exist", http.StatusBadRequest)
+	if err := rs.config.applyFrameworkDefaults(&cwr, scheduling.Platform); err != nil {
+		level.Error(l).Log("message", "error selecting framework image", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, workflow %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := cwr.Validate(cwr.ValidateType(types), rs.config.validateFrameworkRequest(cwr)); err != nil {
+		level.Error(l).Log("message", "error validating workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, workflow %s", err), http.StatusBadRequest)
 		return
 	}
 
-	targetExists, err := cp.TargetExists(projectName, ctr.Name)
-	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+	level.Debug(l).Log("message", "checking workflow template")
+	from, referenced, ok := h.resolveWorkflowTemplate(ctx, w, l, cwr)
+	if !ok {
 		return
 	}
-	if targetExists {
-		level.Error(l).Log("message", "target name must not already exist")
-		h.errorResponse(w, "target name must not already exist", http.StatusBadRequest)
+
+	level.Debug(l).Log("message", "checking encrypted values")
+	if _, _, ok := h.checkEncryptedValues(ctx, w, l, cwr); !ok {
 		return
 	}
 
-	level.Debug(l).Log("message", "creating target")
-	err = cp.CreateTarget(projectName, types.Target(ctr))
+	workflowData, err := json.Marshal(cwr)
 	if err != nil {
-		level.Error(l).Log("message", "error creating target", "error", err)
-		h.errorResponse(w, "error creating target", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprint(w, "{}")
-}
-
-// Deletes a target
-func (h handler) deleteTarget(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	projectName := vars["projectName"]
-	targetName := vars["targetName"]
-
-	l := h.requestLogger(r, "op", "delete-target", "project", projectName, "target", targetName)
+	ts := db.TargetScheduleEntry{
+		Project:  projectName,
+		Target:   targetName,
+		Name:     scheduleName,
+		Cron:     tsr.Cron,
+		Timezone: tsr.Timezone,
+		Suspend:  tsr.Suspend,
+		Workflow: string(workflowData),
+	}
 
-	level.Debug(l).Log("message", "validating authorization header for delete target")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+	level.Debug(l).Log("message", "creating admin credentials provider")
+	cp, err := h.adminCredentialsProvider(r.Header)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
 		return
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+
+	level.Debug(l).Log("message", "applying cron workflow")
+	if err := h.applyScheduleCronWorkflow(ctx, cp, ts, cwr, from, referenced); err != nil {
+		level.Error(l).Log("message", "error applying cron workflow", "error", err)
+		h.errorResponse(w, "error applying cron workflow", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
-	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+	level.Debug(l).Log("message", "storing target schedule")
+	if err := h.dbClient.CreateTargetScheduleEntry(ctx, ts); err != nil {
+		level.Error(l).Log("message", "error storing target schedule", "error", err)
+		h.errorResponse(w, "error storing target schedule", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "deleting target")
-	err = cp.DeleteTarget(projectName, targetName)
+	data, err := json.Marshal(newTargetScheduleResponse(ts))
 	if err != nil {
-		level.Error(l).Log("message", "error deleting target", "error", err)
-		h.errorResponse(w, "error deleting target", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
+
+	fmt.Fprint(w, string(data))
 }
 
-// Lists the targets for a project
-func (h handler) listTargets(w http.ResponseWriter, r *http.Request) {
+// Gets the schedules of a target
+func (h handler) getTargetSchedules(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
 	vars := mux.Vars(r)
-	projectName := vars["projectName"]
+	projectName := rs.project
+	targetName := vars["targetName"]
 
-	l := h.requestLogger(r, "op", "list-targets", "project", projectName)
+	l := rs.log("op", "get-target-schedules", "project", projectName, "target", targetName)
 
-	level.Debug(l).Log("message", "validating authorization header for target list")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
-	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header format", http.StatusUnauthorized)
-		return
-	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
 		return
 	}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	schedules, err := h.dbClient.ListTargetScheduleEntries(rs.ctx, projectName, targetName)
 	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error reading target schedules", "error", err)
+		h.errorResponse(w, "error reading target schedules", http.StatusInternalServerError)
 		return
 	}
 
-	level.Debug(l).Log("message", "checking if project exists")
-	projectExists, err := cp.ProjectExists(projectName)
+	resp := responses.GetTargetSchedules{}
+	for _, ts := range schedules {
+		resp = append(resp, newTargetScheduleResponse(ts))
+	}
+
+	data, err := json.Marshal(resp)
 	if err != nil {
-		level.Error(l).Log("message", "error checking project", "error", err)
-		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
 		return
 	}
 
-	if !projectExists {
-		level.Debug(l).Log("message", "project does not exist")
-		h.errorResponse(w, "project does not exist", http.StatusNotFound)
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes a schedule of a target and its cron workflow
+func (h handler) deleteTargetSchedule(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+	scheduleName := vars["scheduleName"]
+
+	l := rs.log("op", "delete-target-schedule", "project", projectName, "target", targetName, "schedule", scheduleName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
 		return
 	}
 
-	targets, err := cp.ListTargets(projectName)
-	if err != nil {
-		level.Error(l).Log("message", "error listing targets", "error", err)
-		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+	if err := h.deleteSchedule(rs.ctx, projectName, targetName, scheduleName); err != nil {
+		level.Error(l).Log("message", "error deleting target schedule", "error", err)
+		h.errorResponse(w, "error deleting target schedule", http.StatusInternalServerError)
 		return
 	}
+}
 
-	data, err := json.Marshal(targets)
-	if err != nil {
-		level.Error(l).Log("message", "error serializing targets", "error", err)
-		h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
-		return
+// Deletes the cron workflow of a schedule, then the schedule.
+func (h handler) deleteSchedule(ctx context.Context, projectName, targetName, scheduleName string) error {
+	if err := h.cron.Delete(h.argoCtx, scheduleCronWorkflowName(projectName, targetName, scheduleName)); err != nil {
+		return err
 	}
+	return h.dbClient.DeleteTargetScheduleEntry(ctx, projectName, targetName, scheduleName)
+}
 
-	fmt.Fprint(w, string(data))
+// Schedule cron workflows are named after the project, target and schedule.
+func scheduleCronWorkflowName(projectName, targetName, scheduleName string) string {
+	return strings.ToLower(strings.ReplaceAll(fmt.Sprintf("%s-%s-%s", projectName, targetName, scheduleName), "_", "-"))
 }
 
-// Updates a target
-func (h handler) updateTarget(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	projectName := vars["projectName"]
-	targetName := vars["targetName"]
+func newTargetScheduleResponse(ts db.TargetScheduleEntry) responses.TargetSchedule {
+	return responses.TargetSchedule{
+		Name:             ts.Name,
+		Cron:             ts.Cron,
+		Timezone:         ts.Timezone,
+		Suspend:          ts.Suspend,
+		CronWorkflowName: scheduleCronWorkflowName(ts.Project, ts.Target, ts.Name),
+		Workflow:         json.RawMessage(ts.Workflow),
+	}
+}
 
-	l := h.requestLogger(r, "op", "update-target", "project", projectName, "target", targetName)
+// Creates a credentials provider with the admin secret of the service, for
+// work done on behalf of projects.
+func (h handler) adminCredentialsProvider(header http.Header) (credentials.Provider, error) {
+	a := credentials.Authorization{Provider: "vault", Key: "admin", Secret: h.env.AdminSecret}
+	return h.newCredentialsProvider(a, h.env, header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+}
 
-	level.Debug(l).Log("message", "validating authorization header for update target")
-	ah := r.Header.Get("Authorization")
-	a, err := credentials.NewAuthorization(ah)
+// Applies the cron workflow of a schedule with a new project token. Project
+// tokens expire, so cron workflows need to be applied again by syncSchedules
+// before their next run.
+func (h handler) applyScheduleCronWorkflow(ctx context.Context, cp credentials.Provider, ts db.TargetScheduleEntry, cwr requests.CreateWorkflow, from string, referenced bool) error {
+	parameters, scheduling, err := h.projectWorkflowParameters(ctx, cp, cwr, referenced)
 	if err != nil {
-		h.errorResponse(w, "error unauthorized, invalid authorization header", http.StatusUnauthorized)
-		return
+		return err
 	}
-	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
-		h.errorResponse(w, "unauthorized", http.StatusUnauthorized)
-		return
+
+	settings, err := h.projectSettings(ctx, ts.Project)
+	if err != nil {
+		return fmt.Errorf("error reading project settings: %w", err)
 	}
+	labels := map[string]string{
+		workflow.LabelProject:  ts.Project,
+		workflow.LabelTarget:   ts.Target,
+		workflow.LabelType:     cwr.Type,
+		workflow.LabelSchedule: ts.Name,
+	}
+	// Runs of the cron workflow share its labels, so they don't have an
+	// execution id.
+	addCostLabels(labels, settings, ts.Project, "")
+
+	return h.cron.Apply(h.argoCtx, workflow.CronWorkflow{
+		Name:       scheduleCronWorkflowName(ts.Project, ts.Target, ts.Name),
+		Schedule:   ts.Cron,
+		Timezone:   ts.Timezone,
+		Suspend:    ts.Suspend,
+		From:       from,
+		Parameters: parameters,
+		Labels:     labels,
+		Scheduling: scheduling,
+	})
+}
 
-	level.Debug(l).Log("message", "creating credential provider")
-	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+// Generates the parameters and scheduling of a workflow the service submits
+// on behalf of its project (e.g. schedules and rollouts), with a new project
+// token.
+func (h handler) projectWorkflowParameters(ctx context.Context, cp credentials.Provider, cwr requests.CreateWorkflow, referenced bool) (map[string]string, workflow.Scheduling, error) {
+	commandDefinition, err := h.config.getCommandDefinition(cwr.Framework, cwr.Type)
 	if err != nil {
-		level.Error(l).Log("message", "error creating credentials provider", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
-		return
+		return nil, workflow.Scheduling{}, err
 	}
+	return h.projectCommandParameters(ctx, cp, cwr, commandDefinition, referenced)
+}
 
-	projectExists, err := cp.ProjectExists(projectName)
+// projectCommandParameters is projectWorkflowParameters running the command
+// definition instead of the one of the type of the workflow, e.g. for terraform
+// state force-unlocks.
+func (h handler) projectCommandParameters(ctx context.Context, cp credentials.Provider, cwr requests.CreateWorkflow, commandDefinition string, referenced bool) (map[string]string, workflow.Scheduling, error) {
+	cwr, encrypted, err := h.extractEncryptedValues(ctx, cwr)
 	if err != nil {
-		level.Error(l).Log("message", "error determining if project exists", "error", err)
-		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
-		return
+		return nil, workflow.Scheduling{}, err
+	}
+	fp, err := h.frameworkParameters(ctx, cwr)
+	if err != nil {
+		return nil, workflow.Scheduling{}, err
+	}
+	if err := h.applyCostAllocationTags(ctx, h.logger, &cwr); err != nil {
+		return nil, workflow.Scheduling{}, fmt.Errorf("error reading target cost allocation tags: %w", err)
+	}
+	if err := h.applyFrameworkImagePin(ctx, h.logger, &cwr); err != nil {
+		return nil, workflow.Scheduling{}, fmt.Errorf("error reading framework images: %w", err)
+	}
+	environmentVariablesString := generateEnvVariablesString(cwr.EnvironmentVariables)
+	executeCommand, err := generateExecuteCommand(commandDefinition, environmentVariablesString, cwr.Arguments, fp)
+	if err != nil {
+		return nil, workflow.Scheduling{}, err
 	}
 
-	if !projectExists {
-		level.Error(l).Log("message", "project does not exist")
-		h.errorResponse(w, "project does not exist", http.StatusNotFound)
-		return
+	scheduling, err := h.workflowScheduling(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		return nil, workflow.Scheduling{}, fmt.Errorf("error reading target scheduling: %w", err)
 	}
 
-	targetExists, err := cp.TargetExists(projectName, targetName)
+	token, err := cp.GetProjectToken(cwr.ProjectName)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving target", "error", err)
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
-		return
+		return nil, workflow.Scheduling{}, fmt.Errorf("error getting project token: %w", err)
 	}
-	if !targetExists {
-		level.Error(l).Log("message", "target not found")
-		h.errorResponse(w, "target not found", http.StatusNotFound)
-		return
+
+	parameters := workflow.NewParameters(environmentVariablesString, executeCommand, cwr.Parameters["execute_container_image_uri"], cwr.TargetName, cwr.ProjectName, cwr.Parameters, token)
+	addEncryptedParameters(parameters, encrypted)
+	if referenced {
+		addReferencedParameters(parameters, cwr.Parameters)
 	}
+	return parameters, scheduling, nil
+}
 
-	target, err := cp.GetTarget(projectName, targetName)
+// Syncs the schedules every interval until the context is done.
+func (h handler) watchSchedules(ctx context.Context, interval time.Duration) {
+	t := h.clock.NewTicker(interval)
+	defer t.Stop()
+
+	since := h.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		until := h.now()
+		if err := h.syncSchedules(ctx, since, until); err != nil {
+			level.Error(h.logger).Log("message", "error syncing schedules", "error", err)
+			continue
+		}
+		since = until
+	}
+}
+
+// Applies the cron workflows of the schedules which aren't suspended again,
+// refreshing their project tokens, and records the workflows the schedules
+// submitted in [since, until) as execution events. Errors of a schedule are
+// logged and don't stop the sync of the others.
+func (h handler) syncSchedules(ctx context.Context, since, until time.Time) error {
+	schedules, err := h.dbClient.ListScheduleEntries(ctx)
 	if err != nil {
-		level.Error(l).Log("message", "error retrieving existing target")
-		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("error listing schedules: %w", err)
+	}
+	if len(schedules) == 0 {
+		return nil
 	}
-	targetType := target.Type
 
-	level.Debug(l).Log("message", "reading request body")
-	reqBody, err := ioutil.ReadAll(r.Body)
+	cp, err := h.adminCredentialsProvider(http.Header{})
 	if err != nil {
-		level.Error(l).Log("message", "error reading request data", "error", err)
-		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("error creating credentials provider: %w", err)
 	}
 
-	// merge request data into existing target struct for update data
-	if err := json.Unmarshal(reqBody, &target); err != nil {
-		level.Error(l).Log("message", "error reading target properties data", "error", err)
-		h.errorResponse(w, "error reading target properties data", http.StatusInternalServerError)
-		return
+	for _, ts := range schedules {
+		l := log.With(h.logger, "op", "sync-schedule", "project", ts.Project, "target", ts.Target, "schedule", ts.Name)
+
+		if !ts.Suspend {
+			if err := h.reapplySchedule(ctx, cp, ts); err != nil {
+				level.Error(l).Log("message", "error applying cron workflow", "error", err)
+			}
+		}
+
+		h.recordScheduledRuns(ctx, l, ts, since, until)
 	}
-	// overwrite updated target with existing target name and type values so request body doesn't overwrite these values
-	target.Name = targetName
-	target.Type = targetType
+	return nil
+}
 
-	if err := target.Validate(); err != nil {
-		level.Error(l).Log("message", "error invalid request", "error", err)
-		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
-		return
+func (h handler) reapplySchedule(ctx context.Context, cp credentials.Provider, ts db.TargetScheduleEntry) error {
+	var cwr requests.CreateWorkflow
+	if err := json.Unmarshal([]byte(ts.Workflow), &cwr); err != nil {
+		return fmt.Errorf("error deserializing workflow: %w", err)
 	}
 
-	level.Debug(l).Log("message", "updating target")
-	err = cp.UpdateTarget(projectName, target)
+	// The template may no longer be allowed for the project.
+	from, referenced, err := h.workflowTemplateFrom(ctx, cwr)
 	if err != nil {
-		level.Error(l).Log("message", "error updating target", "error", err)
-		h.errorResponse(w, "error updating target", http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	data, err := json.Marshal(target)
+	return h.applyScheduleCronWorkflow(ctx, cp, ts, cwr, from, referenced)
+}
+
+// Records the workflows submitted by a schedule in [since, until). Scheduled
+// runs aren't tied to a request, so their events use the cron workflow name
+// as transaction ID.
+func (h handler) recordScheduledRuns(ctx context.Context, l log.Logger, ts db.TargetScheduleEntry, since, until time.Time) {
+	statuses, err := h.argo.ListByLabels(h.argoCtx, map[string]string{
+		workflow.LabelProject:  ts.Project,
+		workflow.LabelTarget:   ts.Target,
+		workflow.LabelSchedule: ts.Name,
+	})
 	if err != nil {
-		level.Error(l).Log("message", "error creating response", "error", err)
-		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		level.Error(l).Log("message", "error listing scheduled workflows", "error", err)
 		return
 	}
 
-	fmt.Fprint(w, string(data))
+	for _, s := range statuses {
+		created, err := strconv.ParseInt(s.Created, 10, 64)
+		if err != nil {
+			level.Warn(l).Log("message", "error parsing workflow creation time", "workflow", s.Name, "error", err)
+			continue
+		}
+
+		createdAt := time.Unix(created, 0)
+		if createdAt.Before(since) || !createdAt.Before(until) {
+			continue
+		}
+
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         scheduleCronWorkflowName(ts.Project, ts.Target, ts.Name),
+			Project:      ts.Project,
+			Target:       ts.Target,
+			WorkflowName: s.Name,
+			Type:         "scheduled_run",
+			Message:      fmt.Sprintf("submitted by schedule '%s' (%s)", ts.Name, ts.Cron),
+			CreatedAt:    createdAt.UTC(),
+		})
+	}
 }
 
-// Convenience method that writes a failure response in a standard manner
+// Records an execution event. Failures are logged but don't fail the request.
+func (h handler) recordExecutionEvent(ctx context.Context, l log.Logger, event db.ExecutionEvent) {
+	if err := h.dbClient.CreateExecutionEvent(ctx, event); err != nil {
+		level.Warn(l).Log("message", "error recording execution event", "type", event.Type, "error", err)
+	}
+	h.queueWebhookDeliveries(ctx, l, event)
+	if h.audit == nil {
+		return
+	}
+	if err := h.audit.Forward(audit.Event{
+		Time:         event.CreatedAt,
+		Type:         event.Type,
+		TxID:         event.TxID,
+		Project:      event.Project,
+		Target:       event.Target,
+		WorkflowName: event.WorkflowName,
+		Message:      h.redactor.String(event.Message),
+	}); err != nil {
+		level.Warn(l).Log("message", "error forwarding audit event", "type", event.Type, "error", err)
+	}
+}
+
+// Convenience method that writes a failure response in a standard manner,
+// redacting any secret in the message.
 func (h handler) errorResponse(w http.ResponseWriter, message string, httpStatus int) {
-	r := generateErrorResponseJSON(message)
+	r := generateErrorResponseJSON(h.redactor.String(message))
 	w.WriteHeader(httpStatus)
 	fmt.Fprint(w, r)
 }
@@ -987,10 +5031,15 @@ func generateEnvVariablesString(environmentVariables map[string]string) string {
 		return ""
 	}
 
+	keys := make([]string, 0, len(environmentVariables))
+	for k := range environmentVariables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	r := "env"
-	for k, v := range environmentVariables {
-		tmp := r + fmt.Sprintf(" %s=%s", k, v)
-		r = tmp
+	for _, k := range keys {
+		r += fmt.Sprintf(" %s=%s", k, environmentVariables[k])
 	}
 	return r
 }
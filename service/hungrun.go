@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Maximum time a workflow is watched for node progress.
+const hungRunWatchTimeout = 24 * time.Hour
+
+// Watches a submitted workflow when the settings of its project have a hung
+// run timeout, see detectHungRun. Errors reading the settings are logged as
+// the workflow has already been submitted.
+func (h handler) watchHungRun(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	if h.env.HungRunWatchInterval <= 0 {
+		return
+	}
+
+	settings, err := h.projectSettings(ctx, cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project settings, workflow won't be watched for progress", "error", err)
+		return
+	}
+	if settings.HungRun.NoProgressTimeout == "" {
+		return
+	}
+	// Validated with the settings.
+	timeout, _ := time.ParseDuration(settings.HungRun.NoProgressTimeout)
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, hungRunWatchTimeout)
+		defer cancel()
+		h.detectHungRun(ctx, l, txID, cwr, workflowName, timeout, settings.HungRun.Terminate)
+	}()
+}
+
+// Checks the workflow every interval until it completes, the workflow being
+// possibly hung when none of its nodes completed for the timeout. Possibly
+// hung workflows are recorded as a 'possibly_hung' execution event, alerted
+// to the notification rules of the project and terminated when terminate is
+// set, which is recorded as a 'hung_run_terminated' execution event. Workflows
+// are only reported once.
+func (h handler) detectHungRun(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string, timeout time.Duration, terminate bool) {
+	t := h.clock.NewTicker(h.env.HungRunWatchInterval)
+	defer t.Stop()
+
+	var completed, total int64 = -1, -1
+	progressed := h.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		status, err := h.argo.Status(ctx, workflowName)
+		if err != nil {
+			level.Warn(l).Log("message", "error getting workflow status", "error", err)
+			continue
+		}
+		if !workflow.IsActive(status.Status) {
+			return
+		}
+		if status.NodesCompleted != completed || status.NodesTotal != total {
+			completed, total = status.NodesCompleted, status.NodesTotal
+			progressed = h.now()
+			continue
+		}
+
+		stalled := h.now().Sub(progressed)
+		if stalled < timeout {
+			continue
+		}
+
+		stalled = stalled.Round(time.Second)
+		level.Warn(l).Log("message", "workflow possibly hung", "no-progress", stalled.String(), "nodes-completed", completed, "nodes-total", total)
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "possibly_hung",
+			Message:      fmt.Sprintf("no node progress for %s, %d of %d nodes completed", stalled, completed, total),
+			CreatedAt:    h.now().UTC(),
+		})
+		h.alertHungRun(ctx, l, txID, cwr, workflowName, stalled)
+
+		if !terminate {
+			return
+		}
+		if err := h.argo.Terminate(ctx, workflowName); err != nil {
+			level.Error(l).Log("message", "error terminating possibly hung workflow", "error", err)
+			return
+		}
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "hung_run_terminated",
+			Message:      fmt.Sprintf("terminated after no node progress for %s", stalled),
+			CreatedAt:    h.now().UTC(),
+		})
+		return
+	}
+}
+
+// Alerts the notification rules of the project of a possibly hung workflow,
+// whatever their threshold, like anomalous submissions. Sent alerts are
+// recorded as 'notification_sent' execution events.
+func (h handler) alertHungRun(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string, stalled time.Duration) {
+	if h.notifications == nil {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectNotificationRuleEntries(ctx, cwr.ProjectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project notification rules, project owners won't be alerted of the possibly hung workflow", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	rules := make([]notify.Rule, 0, len(entries))
+	for _, nr := range entries {
+		rules = append(rules, notify.Rule{Type: nr.Type, RoutingKey: nr.RoutingKey})
+	}
+
+	alert := notify.Alert{
+		Project: cwr.ProjectName,
+		Target:  cwr.TargetName,
+		Summary: h.messages.Message(h.messages.DefaultLanguage(), messages.PossiblyHungNotification, messages.Params{
+			"project":  cwr.ProjectName,
+			"target":   cwr.TargetName,
+			"workflow": workflowName,
+			"duration": stalled.String(),
+		}),
+		DedupKey: fmt.Sprintf("cello/%s/%s/hung/%s", cwr.ProjectName, cwr.TargetName, workflowName),
+		Details: map[string]string{
+			"txid":     txID,
+			"workflow": workflowName,
+			"link":     notify.WorkflowLink(h.env.ArgoAddress, h.env.ArgoNamespace, workflowName).Href,
+		},
+	}
+
+	h.notifications.Alert(ctx, alert, rules, func(rule notify.Rule) {
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         txID,
+			Project:      cwr.ProjectName,
+			Target:       cwr.TargetName,
+			WorkflowName: workflowName,
+			Type:         "notification_sent",
+			Message:      fmt.Sprintf("%s alerted of possibly hung workflow", rule.Type),
+			CreatedAt:    h.now().UTC(),
+		})
+	})
+}
@@ -0,0 +1,73 @@
+// Package anomaly scores workflow submissions for unusual patterns, e.g.
+// bursts of submissions or submissions at unusual hours, so they can be
+// tagged, alerted or held for approval.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Submission is a workflow submission being assessed.
+type Submission struct {
+	Project string `json:"project"`
+	Target  string `json:"target"`
+	// Source is where the workflow was submitted from, e.g. the repository
+	// and path of a git manifest, empty when submitted directly.
+	Source    string    `json:"source,omitempty"`
+	Principal string    `json:"principal,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Signal is an unusual pattern of a submission, scored from 0 (usual) to 1.
+type Signal struct {
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// Assessment is the anomaly score of a submission, from 0 (usual) to 1, and
+// the signals it's made of.
+type Assessment struct {
+	Score   float64  `json:"score"`
+	Signals []Signal `json:"signals"`
+}
+
+// Reasons returns the reasons of the signals, highest score first.
+func (a Assessment) Reasons() string {
+	signals := append([]Signal(nil), a.Signals...)
+	sort.SliceStable(signals, func(i, j int) bool { return signals[i].Score > signals[j].Score })
+
+	reasons := make([]string, 0, len(signals))
+	for _, s := range signals {
+		reasons = append(reasons, s.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// String returns the score and reasons of the assessment.
+func (a Assessment) String() string {
+	return fmt.Sprintf("anomaly score %.2f: %s", a.Score, a.Reasons())
+}
+
+// Detector assesses submissions, e.g. the built-in Heuristic or an external
+// scoring service.
+type Detector interface {
+	Assess(ctx context.Context, s Submission) (Assessment, error)
+}
+
+// newAssessment returns the assessment of the signals, the sum of their
+// scores capped at 1.
+func newAssessment(signals []Signal) Assessment {
+	a := Assessment{Signals: signals}
+	for _, s := range signals {
+		a.Score += s.Score
+	}
+	if a.Score > 1 {
+		a.Score = 1
+	}
+	return a
+}
@@ -0,0 +1,172 @@
+package anomaly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSources map[string]bool
+
+func (f fakeSources) FirstSource(_ context.Context, s Submission) (bool, error) {
+	if s.Source == "error" {
+		return false, errors.New("db unavailable")
+	}
+	key := s.Target + "/" + s.Source
+	if f[key] {
+		return false, nil
+	}
+	f[key] = true
+	return true, nil
+}
+
+func signalNames(a Assessment) []string {
+	var names []string
+	for _, s := range a.Signals {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestHeuristic(t *testing.T) {
+	noon := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	h := NewHeuristic(HeuristicConfig{
+		RateWindow:      10 * time.Minute,
+		RateThreshold:   2,
+		UsualHoursStart: 7,
+		UsualHoursEnd:   20,
+		Sources:         fakeSources{"target1/github.com/org/repo:manifest.yaml": true},
+	})
+
+	tests := []struct {
+		name        string
+		submission  Submission
+		wantSignals []string
+		wantScore   float64
+		wantErr     bool
+	}{
+		{
+			name:       "usual submission",
+			submission: Submission{Project: "project1", Target: "target1", Source: "github.com/org/repo:manifest.yaml", Time: noon},
+			wantScore:  0,
+		},
+		{
+			name:        "first source",
+			submission:  Submission{Project: "project1", Target: "target1", Source: "github.com/org/repo:other.yaml", Time: noon.Add(time.Minute)},
+			wantSignals: []string{SignalFirstSource},
+			wantScore:   firstSourceScore,
+		},
+		{
+			name:        "rate spike",
+			submission:  Submission{Project: "project1", Target: "target1", Time: noon.Add(2 * time.Minute)},
+			wantSignals: []string{SignalRateSpike},
+			wantScore:   rateSpikeScore,
+		},
+		{
+			name:       "spike over once the window passed",
+			submission: Submission{Project: "project1", Target: "target1", Time: noon.Add(15 * time.Minute)},
+			wantScore:  0,
+		},
+		{
+			name:        "unusual hour",
+			submission:  Submission{Project: "project2", Target: "target1", Time: time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)},
+			wantSignals: []string{SignalUnusualHour},
+			wantScore:   unusualHourScore,
+		},
+		{
+			name:       "error recording source",
+			submission: Submission{Project: "project3", Target: "target1", Source: "error", Time: noon},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := h.Assess(context.Background(), tt.submission)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSignals, signalNames(a))
+			assert.InDelta(t, tt.wantScore, a.Score, 0.001)
+		})
+	}
+}
+
+func TestUsualHourAcrossMidnight(t *testing.T) {
+	h := NewHeuristic(HeuristicConfig{UsualHoursStart: 22, UsualHoursEnd: 6})
+	assert.True(t, h.usualHour(time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, h.usualHour(time.Date(2026, 10, 16, 5, 59, 0, 0, time.UTC)))
+	assert.False(t, h.usualHour(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestAssessmentString(t *testing.T) {
+	a := newAssessment([]Signal{
+		{Name: SignalUnusualHour, Score: unusualHourScore, Reason: "submitted at 03:00 UTC"},
+		{Name: SignalRateSpike, Score: rateSpikeScore, Reason: "30 submissions"},
+		{Name: SignalFirstSource, Score: firstSourceScore, Reason: "first submission"},
+	})
+	assert.Equal(t, 1.0, a.Score)
+	assert.Equal(t, "anomaly score 1.00: 30 submissions; first submission; submitted at 03:00 UTC", a.String())
+}
+
+func TestScorer(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    Assessment
+		wantErr bool
+	}{
+		{
+			name:   "assessed",
+			status: http.StatusOK,
+			body:   `{"score":0.8,"signals":[{"name":"new_principal","score":0.8,"reason":"first submission by alice"}]}`,
+			want:   Assessment{Score: 0.8, Signals: []Signal{{Name: "new_principal", Score: 0.8, Reason: "first submission by alice"}}},
+		},
+		{
+			name:   "score capped",
+			status: http.StatusOK,
+			body:   `{"score":7}`,
+			want:   Assessment{Score: 1},
+		},
+		{
+			name:    "service error",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name:    "invalid response",
+			status:  http.StatusOK,
+			body:    `score`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Submission
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			sub := Submission{Project: "project1", Target: "target1", Principal: "alice", Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+			a, err := NewScorer(srv.URL, srv.Client()).Assess(context.Background(), sub)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, a)
+			assert.Equal(t, sub, got)
+		})
+	}
+}
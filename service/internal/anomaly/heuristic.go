@@ -0,0 +1,146 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Signals of the heuristic.
+const (
+	SignalRateSpike   = "rate_spike"
+	SignalUnusualHour = "unusual_hour"
+	SignalFirstSource = "first_source"
+)
+
+// Scores of the signals, a first source at an unusual hour scores 0.7.
+const (
+	rateSpikeScore   = 0.5
+	unusualHourScore = 0.3
+	firstSourceScore = 0.4
+)
+
+const (
+	defaultRateWindow = 10 * time.Minute
+	// maxTrackedProjects bounds the projects submissions are counted for.
+	maxTrackedProjects = 10000
+)
+
+// Sources records the sources workflows of targets were submitted from.
+type Sources interface {
+	// FirstSource records the source of the submission, reporting whether
+	// it's the first submission of the target from it.
+	FirstSource(ctx context.Context, s Submission) (bool, error)
+}
+
+// HeuristicConfig configures the built-in heuristic.
+type HeuristicConfig struct {
+	// RateWindow is the window submissions of a project are counted in.
+	RateWindow time.Duration
+	// RateThreshold is the submissions of a project in the window above
+	// which they're a spike.
+	RateThreshold int
+	// UsualHoursStart and UsualHoursEnd are the hours (0-24) submissions are
+	// usual in, in Location. Every hour is usual when they're equal.
+	UsualHoursStart int
+	UsualHoursEnd   int
+	Location        *time.Location
+	// Sources, when set, reports submissions from sources the target never
+	// had.
+	Sources Sources
+}
+
+// Heuristic is the built-in Detector, reporting rate spikes, submissions at
+// unusual hours and first submissions of targets from a source. Submissions
+// are counted per instance of the service.
+type Heuristic struct {
+	cfg HeuristicConfig
+
+	mu          sync.Mutex
+	submissions map[string][]time.Time
+}
+
+// NewHeuristic creates the heuristic detector.
+func NewHeuristic(cfg HeuristicConfig) *Heuristic {
+	if cfg.RateWindow <= 0 {
+		cfg.RateWindow = defaultRateWindow
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &Heuristic{cfg: cfg, submissions: map[string][]time.Time{}}
+}
+
+// Assess assesses the submission, recording it for the next ones.
+func (h *Heuristic) Assess(ctx context.Context, s Submission) (Assessment, error) {
+	var signals []Signal
+
+	if n := h.record(s); h.cfg.RateThreshold > 0 && n > h.cfg.RateThreshold {
+		signals = append(signals, Signal{
+			Name:   SignalRateSpike,
+			Score:  rateSpikeScore,
+			Reason: fmt.Sprintf("%d submissions of project %s in %s", n, s.Project, h.cfg.RateWindow),
+		})
+	}
+
+	if !h.usualHour(s.Time) {
+		signals = append(signals, Signal{
+			Name:   SignalUnusualHour,
+			Score:  unusualHourScore,
+			Reason: fmt.Sprintf("submitted at %s, outside %02d:00-%02d:00", s.Time.In(h.cfg.Location).Format("15:04 MST"), h.cfg.UsualHoursStart, h.cfg.UsualHoursEnd),
+		})
+	}
+
+	if h.cfg.Sources != nil && s.Source != "" {
+		first, err := h.cfg.Sources.FirstSource(ctx, s)
+		if err != nil {
+			return Assessment{}, err
+		}
+		if first {
+			signals = append(signals, Signal{
+				Name:   SignalFirstSource,
+				Score:  firstSourceScore,
+				Reason: fmt.Sprintf("first submission to target %s from %s", s.Target, s.Source),
+			})
+		}
+	}
+
+	return newAssessment(signals), nil
+}
+
+// record records the submission, returning the submissions of its project in
+// the rate window, including it.
+func (h *Heuristic) record(s Submission) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	since := s.Time.Add(-h.cfg.RateWindow)
+	times := h.submissions[s.Project]
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(since) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, s.Time)
+
+	if _, ok := h.submissions[s.Project]; !ok && len(h.submissions) >= maxTrackedProjects {
+		h.submissions = map[string][]time.Time{}
+	}
+	h.submissions[s.Project] = kept
+	return len(kept)
+}
+
+func (h *Heuristic) usualHour(t time.Time) bool {
+	start, end := h.cfg.UsualHoursStart, h.cfg.UsualHoursEnd
+	if start == end {
+		return true
+	}
+	hour := t.In(h.cfg.Location).Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	// Windows can span midnight, e.g. 22-06.
+	return hour >= start || hour < end
+}
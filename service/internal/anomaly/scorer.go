@@ -0,0 +1,59 @@
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Scorer is a Detector delegating to an external scoring service. The
+// submission is POSTed as JSON and the service responds with the assessment,
+// e.g. {"score": 0.8, "signals": [{"name": "...", "score": 0.8, "reason": "..."}]}.
+type Scorer struct {
+	url string
+	cl  *http.Client
+}
+
+// NewScorer creates a detector of the scoring service at the URL.
+func NewScorer(url string, cl *http.Client) Scorer {
+	return Scorer{url: url, cl: cl}
+}
+
+// Assess assesses the submission with the scoring service. Scores are capped
+// to 0-1.
+func (s Scorer) Assess(ctx context.Context, sub Submission) (Assessment, error) {
+	body, err := json.Marshal(sub)
+	if err != nil {
+		return Assessment{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return Assessment{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.cl.Do(req)
+	if err != nil {
+		return Assessment{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Assessment{}, fmt.Errorf("unexpected status from anomaly scoring service: %d", resp.StatusCode)
+	}
+
+	var a Assessment
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		return Assessment{}, fmt.Errorf("invalid anomaly scoring service response: %w", err)
+	}
+	switch {
+	case a.Score < 0:
+		a.Score = 0
+	case a.Score > 1:
+		a.Score = 1
+	}
+	return a, nil
+}
@@ -0,0 +1,250 @@
+// Package audit forwards audit events (e.g. workflow submissions and
+// break-glass credentials) to a SIEM, through a local spool so events are
+// delivered once the SIEM is reachable again, including after restarts.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// initialBackoff is the delay before retrying a failed delivery, doubled each
+// failure up to the max backoff.
+const initialBackoff = time.Second
+
+// segmentSuffix is the suffix of spool segments, files of JSON events.
+const segmentSuffix = ".jsonl"
+
+var (
+	spooledEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cello",
+		Name:      "audit_spooled_events",
+		Help:      "Audit events spooled and not yet delivered to the audit sink.",
+	})
+	deliveryFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cello",
+		Name:      "audit_delivery_failures_total",
+		Help:      "Failed deliveries of spooled audit events to the audit sink.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(spooledEvents, deliveryFailures)
+}
+
+// Event is an audit event, the execution events recorded in the database.
+type Event struct {
+	Time         time.Time `json:"time"`
+	Type         string    `json:"type"`
+	TxID         string    `json:"txid,omitempty"`
+	Project      string    `json:"project,omitempty"`
+	Target       string    `json:"target,omitempty"`
+	WorkflowName string    `json:"workflow_name,omitempty"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// Sink delivers audit events to a SIEM, e.g. Splunk or syslog.
+type Sink interface {
+	Send(ctx context.Context, events []Event) error
+}
+
+// Forwarder spools audit events into a directory and delivers them to the
+// sink in the order they were spooled. Events are delivered at least once:
+// those spooled before a restart are delivered after it.
+type Forwarder struct {
+	sink Sink
+	dir  string
+
+	mu      sync.Mutex
+	seq     int64
+	current *os.File
+}
+
+// NewForwarder creates a forwarder to the sink, spooling into the directory,
+// which is created when it doesn't exist.
+func NewForwarder(sink Sink, dir string) (*Forwarder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	f := &Forwarder{sink: sink, dir: dir}
+	segments, err := f.segments()
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, s := range segments {
+		events, err := readSegment(s)
+		if err != nil {
+			return nil, err
+		}
+		pending += len(events)
+		seq, _ := strconv.ParseInt(strings.TrimSuffix(filepath.Base(s), segmentSuffix), 10, 64)
+		if seq > f.seq {
+			f.seq = seq
+		}
+	}
+	spooledEvents.Set(float64(pending))
+	return f, nil
+}
+
+// Forward spools the event, delivered by Run.
+func (f *Forwarder) Forward(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.current == nil {
+		f.seq++
+		file, err := os.OpenFile(f.segmentPath(f.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		f.current = file
+	}
+	if _, err := f.current.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	spooledEvents.Inc()
+	return nil
+}
+
+// Run delivers the spooled events every flush interval until the context is
+// done, retrying failed deliveries with backoff up to the max backoff.
+func (f *Forwarder) Run(ctx context.Context, flushInterval, maxBackoff time.Duration, logger log.Logger) {
+	failures := 0
+	for {
+		wait := flushInterval
+		if err := f.flush(ctx); err != nil {
+			failures++
+			deliveryFailures.Inc()
+			wait = backoff(failures, maxBackoff)
+			level.Error(logger).Log("message", "error delivering audit events", "failures", failures, "retry_in", wait, "error", err)
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// flush delivers the spooled segments in order, deleting each once
+// delivered. Events spooled while flushing go to a new segment.
+func (f *Forwarder) flush(ctx context.Context) error {
+	f.mu.Lock()
+	if f.current != nil {
+		if err := f.current.Close(); err != nil {
+			f.mu.Unlock()
+			return err
+		}
+		f.current = nil
+	}
+	f.mu.Unlock()
+
+	segments, err := f.segments()
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		events, err := readSegment(s)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			if err := f.sink.Send(ctx, events); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(s); err != nil {
+			return err
+		}
+		spooledEvents.Sub(float64(len(events)))
+	}
+	return nil
+}
+
+// segments returns the paths of the spool segments not being written to,
+// oldest first.
+func (f *Forwarder) segments() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	var current string
+	if f.current != nil {
+		current = f.current.Name()
+	}
+	f.mu.Unlock()
+
+	segments := paths[:0]
+	for _, p := range paths {
+		if p != current {
+			segments = append(segments, p)
+		}
+	}
+	// Names are zero padded sequence numbers.
+	sort.Strings(segments)
+	return segments, nil
+}
+
+func (f *Forwarder) segmentPath(seq int64) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// readSegment returns the events of the segment. A truncated last line, e.g.
+// of a crash while spooling, is skipped.
+func readSegment(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// backoff returns the delay before retrying after the number of consecutive
+// failures.
+func backoff(failures int, maxBackoff time.Duration) time.Duration {
+	d := initialBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if maxBackoff > 0 && d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var eventTime = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+type fakeSink struct {
+	err  error
+	sent [][]Event
+}
+
+func (s *fakeSink) Send(_ context.Context, events []Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, events)
+	return nil
+}
+
+func event(txID string) Event {
+	return Event{Time: eventTime, Type: "workflow_submitted", TxID: txID, Project: "project1", Target: "target1"}
+}
+
+func TestForwarder(t *testing.T) {
+	dir := t.TempDir()
+	sink := &fakeSink{err: errors.New("connection refused")}
+	f, err := NewForwarder(sink, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, f.Forward(event("tx-1")))
+	require.NoError(t, f.Forward(event("tx-2")))
+
+	// Events stay spooled while the sink is unavailable.
+	assert.Error(t, f.flush(context.Background()))
+	require.NoError(t, f.Forward(event("tx-3")))
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, paths, 2)
+
+	// Including across restarts, new events are spooled after them.
+	f, err = NewForwarder(sink, dir)
+	require.NoError(t, err)
+	require.NoError(t, f.Forward(event("tx-4")))
+
+	sink.err = nil
+	require.NoError(t, f.flush(context.Background()))
+	assert.Equal(t, [][]Event{
+		{event("tx-1"), event("tx-2")},
+		{event("tx-3")},
+		{event("tx-4")},
+	}, sink.sent)
+	paths, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Empty(t, paths)
+}
+
+func TestReadSegmentTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "00000000000000000001.jsonl")
+	line, _ := json.Marshal(event("tx-1"))
+	require.NoError(t, os.WriteFile(path, append(line, []byte("\n{\"time\":\"2026")...), 0o600))
+
+	events, err := readSegment(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{event("tx-1")}, events)
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		5:  16 * time.Second,
+		10: 5 * time.Minute,
+		50: 5 * time.Minute,
+	}
+	for failures, want := range tests {
+		assert.Equal(t, want, backoff(failures, 5*time.Minute), failures)
+	}
+}
+
+func TestSplunkHEC(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "events sent", status: http.StatusOK},
+		{name: "events rejected", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var auth string
+			var got []splunkEvent
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				dec := json.NewDecoder(r.Body)
+				for {
+					var e splunkEvent
+					if err := dec.Decode(&e); err != nil {
+						break
+					}
+					got = append(got, e)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			s := NewSplunkHEC(srv.URL, "hec-token", "audit", srv.Client())
+			err := s.Send(context.Background(), []Event{event("tx-1"), event("tx-2")})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Splunk hec-token", auth)
+			assert.Equal(t, []splunkEvent{
+				{Time: float64(eventTime.Unix()), Source: "cello", SourceType: "cello:audit", Index: "audit", Event: event("tx-1")},
+				{Time: float64(eventTime.Unix()), Source: "cello", SourceType: "cello:audit", Index: "audit", Event: event("tx-2")},
+			}, got)
+		})
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	// The TLS server is only used for its certificate.
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var n int
+			if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	s := NewSyslog(ln.Addr().String(), srv.Client().Transport.(*http.Transport).TLSClientConfig, 5*time.Second)
+	s.hostname = "cello-0"
+	defer s.Close()
+	require.NoError(t, s.Send(context.Background(), []Event{event("tx-1"), event("tx-2")}))
+
+	for _, txID := range []string{"tx-1", "tx-2"} {
+		msg := <-received
+		prefix := "<110>1 2026-10-16T12:00:00Z cello-0 cello - workflow_submitted - "
+		assert.True(t, strings.HasPrefix(msg, prefix), msg)
+		var got Event
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(msg, prefix)), &got))
+		assert.Equal(t, event(txID), got)
+	}
+}
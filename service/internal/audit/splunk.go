@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SplunkHEC is a Sink sending events to the HTTP Event Collector of Splunk.
+type SplunkHEC struct {
+	url   string
+	token string
+	index string
+	cl    *http.Client
+}
+
+// NewSplunkHEC creates a sink of the event endpoint of the collector (e.g.
+// https://splunk:8088/services/collector/event), authorized with the HEC
+// token. Events go to the default index of the token when index is empty.
+func NewSplunkHEC(url, token, index string, cl *http.Client) SplunkHEC {
+	return SplunkHEC{url: url, token: token, index: index, cl: cl}
+}
+
+type splunkEvent struct {
+	Time       float64 `json:"time"`
+	Source     string  `json:"source"`
+	SourceType string  `json:"sourcetype"`
+	Index      string  `json:"index,omitempty"`
+	Event      Event   `json:"event"`
+}
+
+// Send sends the events in one request, the collector accepts concatenated
+// events.
+func (s SplunkHEC) Send(ctx context.Context, events []Event) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range events {
+		if err := enc.Encode(splunkEvent{
+			Time:       float64(e.Time.UnixNano()) / 1e9,
+			Source:     "cello",
+			SourceType: "cello:audit",
+			Index:      s.index,
+			Event:      e,
+		}); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status sending events to splunk: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// syslogPriority is the priority of the messages, the log audit facility (13)
+// and the informational severity (6).
+const syslogPriority = 13*8 + 6
+
+// Syslog is a Sink sending events as RFC 5424 messages over TLS (RFC 5425).
+// The connection is reused until a send fails.
+type Syslog struct {
+	address  string
+	tls      *tls.Config
+	timeout  time.Duration
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog creates a sink of the syslog server at the address (host:port),
+// verified with the TLS config.
+func NewSyslog(address string, tlsConfig *tls.Config, timeout time.Duration) *Syslog {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return &Syslog{address: address, tls: tlsConfig, timeout: timeout, hostname: hostname}
+}
+
+// Send sends a message per event, its JSON.
+func (s *Syslog) Send(ctx context.Context, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		d := tls.Dialer{NetDialer: &net.Dialer{Timeout: s.timeout}, Config: s.tls}
+		conn, err := d.DialContext(ctx, "tcp", s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	for _, e := range events {
+		msg, err := s.message(e)
+		if err != nil {
+			return err
+		}
+		if s.timeout > 0 {
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		// Messages are framed with their length (octet counting).
+		if _, err := fmt.Fprintf(s.conn, "%d %s", len(msg), msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// message returns the RFC 5424 message of the event, with the type as the
+// message ID.
+func (s *Syslog) message(e Event) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	msgID := e.Type
+	if msgID == "" {
+		msgID = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s cello - %s - %s", syslogPriority, e.Time.UTC().Format(time.RFC3339Nano), s.hostname, msgID, data), nil
+}
+
+// Close closes the connection, if any.
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,131 @@
+// Package bundle reads manifest bundles uploaded to the service, gzipped
+// tarballs of manifests for environments where the service can't reach git.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MaxSize is the max size of a bundle uncompressed, the tarball with its
+// headers and the entries that aren't regular files, so small uploads can't
+// expand to large ones.
+const MaxSize = 10 << 20
+
+var (
+	// ErrInvalid conveys that the bundle isn't a valid gzipped tarball.
+	ErrInvalid = errors.New("bundle must be a gzipped tarball")
+	// ErrNotFound is returned when the bundle doesn't have the file.
+	ErrNotFound = errors.New("not found")
+
+	errTooLarge = fmt.Errorf("bundle is larger than %d bytes uncompressed", MaxSize)
+)
+
+// Bundle are the regular files of a bundle by their path, e.g.
+// manifests/cdk.yaml.
+type Bundle map[string][]byte
+
+// Read reads the regular files of the gzipped tarball, others (e.g.
+// directories or links) are skipped. Files can't be outside of the bundle and
+// the tarball can't exceed MaxSize uncompressed.
+func Read(r io.Reader) (Bundle, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	defer gr.Close()
+
+	// Every byte decompressed counts, whatever the entry it belongs to.
+	lr := &io.LimitedReader{R: gr, N: MaxSize + 1}
+	b := Bundle{}
+	var size int64
+	tr := tar.NewReader(lr)
+	for {
+		hdr, err := tr.Next()
+		if lr.N == 0 {
+			return nil, errTooLarge
+		}
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := cleanPath(hdr.Name)
+		if !ok {
+			return nil, fmt.Errorf("bundle file '%s' is outside of the bundle", hdr.Name)
+		}
+		size += hdr.Size
+		if size > MaxSize {
+			return nil, errTooLarge
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(tr, hdr.Size))
+		if lr.N == 0 {
+			return nil, errTooLarge
+		}
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		b[name] = data
+	}
+}
+
+// cleanPath returns the path of a file relative to the bundle, false when
+// it's outside of it.
+func cleanPath(name string) (string, bool) {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", false
+		}
+	}
+	p := strings.TrimPrefix(path.Clean("/"+name), "/")
+	return p, p != ""
+}
+
+// File returns the file of the bundle at the path, e.g. ./manifests/cdk.yaml
+// for manifests/cdk.yaml.
+func (b Bundle) File(name string) ([]byte, error) {
+	p, ok := cleanPath(name)
+	if !ok {
+		return nil, fmt.Errorf("file '%s': %w", name, ErrNotFound)
+	}
+	data, ok := b[p]
+	if !ok {
+		return nil, fmt.Errorf("file '%s': %w", name, ErrNotFound)
+	}
+	return data, nil
+}
+
+// Paths returns the paths of the files of the bundle, sorted.
+func (b Bundle) Paths() []string {
+	paths := make([]string, 0, len(b))
+	for p := range b {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Verify returns an error when the SHA-256 checksum of the data isn't the
+// hex encoded checksum.
+func Verify(data []byte, checksum string) error {
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != strings.ToLower(checksum) {
+		return fmt.Errorf("sha256 checksum mismatch, got '%s'", got)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type file struct {
+	name     string
+	typeflag byte
+	data     string
+}
+
+func tarball(t *testing.T, files ...file) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		typeflag := f.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		require.Nil(t, tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: typeflag, Mode: 0644, Size: int64(len(f.data))}))
+		_, err := tw.Write([]byte(f.data))
+		require.Nil(t, err)
+	}
+	require.Nil(t, tw.Close())
+	require.Nil(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	data := tarball(t,
+		file{name: "./manifests/", typeflag: tar.TypeDir},
+		file{name: "./manifests/cdk.yaml", data: "framework: cdk"},
+		file{name: "README.md", data: "bundle"},
+		file{name: "latest", typeflag: tar.TypeSymlink},
+	)
+
+	b, err := Read(bytes.NewReader(data))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"README.md", "manifests/cdk.yaml"}, b.Paths())
+
+	got, err := b.File("manifests/cdk.yaml")
+	require.Nil(t, err)
+	assert.Equal(t, "framework: cdk", string(got))
+
+	got, err = b.File("./manifests/cdk.yaml")
+	require.Nil(t, err)
+	assert.Equal(t, "framework: cdk", string(got))
+
+	_, err = b.File("manifests/terraform.yaml")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = b.File("../manifests/cdk.yaml")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("manifest")))
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = Read(bytes.NewReader(tarball(t, file{name: "../cdk.yaml", data: "framework: cdk"})))
+	assert.EqualError(t, err, "bundle file '../cdk.yaml' is outside of the bundle")
+
+	large := string(make([]byte, MaxSize/2+1))
+	_, err = Read(bytes.NewReader(tarball(t, file{name: "a", data: large}, file{name: "b", data: large})))
+	assert.EqualError(t, err, "bundle is larger than 10485760 bytes uncompressed")
+
+	// Files just under the limit don't leave room for the headers.
+	_, err = Read(bytes.NewReader(tarball(t, file{name: "a", data: string(make([]byte, MaxSize-512))})))
+	assert.EqualError(t, err, "bundle is larger than 10485760 bytes uncompressed")
+}
+
+// rawTarball is like tarball with the entries written as they are,
+// whatever their type, as tar.Writer doesn't write some of them.
+func rawTarball(t *testing.T, files ...file) []byte {
+	var tb bytes.Buffer
+	tw := tar.NewWriter(&tb)
+	var offsets []int
+	for _, f := range files {
+		offsets = append(offsets, tb.Len())
+		require.Nil(t, tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.data)), Format: tar.FormatUSTAR}))
+		_, err := tw.Write([]byte(f.data))
+		require.Nil(t, err)
+	}
+	require.Nil(t, tw.Close())
+
+	data := tb.Bytes()
+	for i, f := range files {
+		hdr := data[offsets[i] : offsets[i]+512]
+		hdr[156] = f.typeflag
+		copy(hdr[148:156], "        ")
+		var sum int64
+		for _, c := range hdr {
+			sum += int64(c)
+		}
+		copy(hdr[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err := gw.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, gw.Close())
+	return buf.Bytes()
+}
+
+// paxRecord returns a PAX record of the key of size bytes.
+func paxRecord(key string, size int) string {
+	value := strings.Repeat("a", size-len(key)-len(" =\n")-len(strconv.Itoa(size)))
+	return fmt.Sprintf("%d %s=%s\n", size, key, value)
+}
+
+func TestReadLargeSkippedEntries(t *testing.T) {
+	// Tar readers reject special entries larger than 1 MiB, the limit is
+	// reached with several of them.
+	const special = 1<<20 - 1024
+	var pax, longNames []file
+	for i := 0; i < MaxSize/special+1; i++ {
+		pax = append(pax, file{name: "pax", typeflag: tar.TypeXGlobalHeader, data: paxRecord("comment", special)})
+		longNames = append(longNames, file{name: "name", typeflag: tar.TypeGNULongName, data: string(make([]byte, special))})
+	}
+	cdk := file{name: "cdk.yaml", data: "framework: cdk"}
+	large := string(make([]byte, MaxSize/2+1))
+
+	tests := []struct {
+		name  string
+		files []file
+	}{
+		{name: "pax headers", files: append(pax, cdk)},
+		{name: "gnu long names", files: append(longNames, cdk)},
+		{name: "unknown entries", files: []file{{name: "a", typeflag: 'Z', data: large}, {name: "b", typeflag: 'Z', data: large}, cdk}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(rawTarball(t, tt.files...)))
+			assert.EqualError(t, err, "bundle is larger than 10485760 bytes uncompressed")
+		})
+	}
+
+	// The same entries under the limit are skipped.
+	b, err := Read(bytes.NewReader(rawTarball(t, pax[0], longNames[0], file{name: "a", typeflag: 'Z', data: "skipped"}, cdk)))
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(b))
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("bundle")
+	assert.Nil(t, Verify(data, "1e6ed65d77d6364eeaed5a745ba5c4985ae2b700dd85d7cf7f027bdf294a33fc"))
+	assert.Nil(t, Verify(data, "1E6ED65D77D6364EEAED5A745BA5C4985AE2B700DD85D7CF7F027BDF294A33FC"))
+	assert.EqualError(t, Verify(data, "00"), "sha256 checksum mismatch, got '1e6ed65d77d6364eeaed5a745ba5c4985ae2b700dd85d7cf7f027bdf294a33fc'")
+}
@@ -0,0 +1,61 @@
+// Package changeset reads the summary of CloudFormation change sets created
+// by diffs of the cloudformation framework from their workflow logs.
+package changeset
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LogPrefix prefixes the log line of the summary, printed by the
+// cfn-change-set create command of the cloudformation image.
+const LogPrefix = "CELLO_CHANGE_SET_SUMMARY "
+
+// Change is a resource change of a change set.
+type Change struct {
+	Action            string `json:"action"`
+	LogicalResourceID string `json:"logical_resource_id"`
+	ResourceType      string `json:"resource_type"`
+	// Replacement is True, False or Conditional for modifications.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Summary is the summary of a change set.
+type Summary struct {
+	StackName     string   `json:"stack_name"`
+	ChangeSetName string   `json:"change_set_name"`
+	Status        string   `json:"status"`
+	Changes       []Change `json:"changes"`
+}
+
+// FromLogs returns the last summary of the workflow logs, whose lines are
+// prefixed by the pod name. False when there's none.
+func FromLogs(lines []string) (Summary, bool, error) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		idx := strings.Index(lines[i], LogPrefix)
+		if idx < 0 {
+			continue
+		}
+
+		var s Summary
+		if err := json.Unmarshal([]byte(lines[i][idx+len(LogPrefix):]), &s); err != nil {
+			return Summary{}, false, fmt.Errorf("invalid change set summary: %w", err)
+		}
+		return s, true, nil
+	}
+	return Summary{}, false, nil
+}
+
+// String returns the summary as text, a line per change.
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "change set %s of stack %s (%s), %d changes", s.ChangeSetName, s.StackName, s.Status, len(s.Changes))
+	for _, c := range s.Changes {
+		fmt.Fprintf(&b, "\n%s %s %s", c.Action, c.ResourceType, c.LogicalResourceID)
+		if c.Replacement != "" {
+			fmt.Fprintf(&b, " (replacement %s)", c.Replacement)
+		}
+	}
+	return b.String()
+}
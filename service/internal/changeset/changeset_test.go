@@ -0,0 +1,76 @@
+package changeset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    Summary
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name: "summary",
+			lines: []string{
+				"wf-1: creating change set",
+				`wf-1: CELLO_CHANGE_SET_SUMMARY {"stack_name":"web","change_set_name":"cello-1","status":"CREATE_COMPLETE","changes":[{"action":"Add","logical_resource_id":"Bucket","resource_type":"AWS::S3::Bucket"}]}`,
+			},
+			want: Summary{
+				StackName:     "web",
+				ChangeSetName: "cello-1",
+				Status:        "CREATE_COMPLETE",
+				Changes:       []Change{{Action: "Add", LogicalResourceID: "Bucket", ResourceType: "AWS::S3::Bucket"}},
+			},
+			wantOK: true,
+		},
+		{
+			name: "last_summary",
+			lines: []string{
+				`wf-1: CELLO_CHANGE_SET_SUMMARY {"stack_name":"web","change_set_name":"cello-1"}`,
+				`wf-1: CELLO_CHANGE_SET_SUMMARY {"stack_name":"web","change_set_name":"cello-2"}`,
+			},
+			want:   Summary{StackName: "web", ChangeSetName: "cello-2"},
+			wantOK: true,
+		},
+		{
+			name:  "no_summary",
+			lines: []string{"wf-1: nothing to see"},
+		},
+		{
+			name:    "invalid_summary",
+			lines:   []string{"wf-1: CELLO_CHANGE_SET_SUMMARY {"},
+			wantErr: "invalid change set summary: unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := FromLogs(tt.lines)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	s := Summary{
+		StackName:     "web",
+		ChangeSetName: "cello-1",
+		Status:        "CREATE_COMPLETE",
+		Changes: []Change{
+			{Action: "Add", LogicalResourceID: "Bucket", ResourceType: "AWS::S3::Bucket"},
+			{Action: "Modify", LogicalResourceID: "Function", ResourceType: "AWS::Lambda::Function", Replacement: "False"},
+		},
+	}
+	assert.Equal(t, "change set cello-1 of stack web (CREATE_COMPLETE), 2 changes\nAdd AWS::S3::Bucket Bucket\nModify AWS::Lambda::Function Function (replacement False)", s.String())
+}
@@ -0,0 +1,206 @@
+// Package circuitbreaker stops calling a failing dependency for a while so
+// requests fail fast instead of stacking timeouts, probing the dependency with
+// a single call once the breaker half opens.
+package circuitbreaker
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// State is the state of a Breaker.
+type State int
+
+// Breaker states. The values are exported as the state metric.
+const (
+	// Closed allows calls through.
+	Closed State = iota
+	// HalfOpen allows a single probe call through.
+	HalfOpen
+	// Open rejects calls.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Number of recent call latencies kept for LatencyPercentile.
+const latencySamples = 1000
+
+// ErrOpen is returned when a call is rejected by an open breaker.
+var ErrOpen = errors.New("circuit breaker is open")
+
+var stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cello",
+	Name:      "circuit_breaker_state",
+	Help:      "State of the dependency circuit breaker (0 closed, 1 half-open, 2 open).",
+}, []string{"dependency"})
+
+func init() {
+	prometheus.MustRegister(stateGauge)
+}
+
+// Config configures a Breaker.
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before half opening.
+	OpenTimeout time.Duration
+	// IsFailure determines if an error counts as a dependency failure, e.g.
+	// 'not found' errors don't. Defaults to every error.
+	IsFailure func(error) bool
+}
+
+// Breaker is a circuit breaker for a single dependency.
+type Breaker struct {
+	name string
+	cfg  Config
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	// Ring buffer of recent call latencies, next is the index to overwrite.
+	latencies []time.Duration
+	next      int
+}
+
+// New creates a closed Breaker for the named dependency.
+func New(name string, cfg Config) *Breaker {
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(error) bool { return true }
+	}
+
+	b := &Breaker{name: name, cfg: cfg, now: time.Now}
+	stateGauge.WithLabelValues(name).Set(float64(Closed))
+	return b
+}
+
+// Name returns the name of the dependency.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, half opening the breaker if the open
+// timeout elapsed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.halfOpenIfElapsed()
+	return b.state
+}
+
+// Do calls fn unless the breaker is open, recording the outcome. ErrOpen is
+// returned without calling fn when the breaker is open, or half open with a
+// probe already in flight.
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	start := b.now()
+	err := fn()
+	b.record(err, b.now().Sub(start))
+	return err
+}
+
+// LatencyPercentile returns the p-th percentile (0-100) of the latency of
+// recent calls, or 0 when there were none. Calls rejected by the breaker
+// aren't included.
+func (b *Breaker) LatencyPercentile(p float64) time.Duration {
+	b.mu.Lock()
+	latencies := append([]time.Duration{}, b.latencies...)
+	b.mu.Unlock()
+
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	// Nearest rank.
+	rank := int(math.Ceil(p / 100 * float64(len(latencies))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(latencies) {
+		rank = len(latencies)
+	}
+	return latencies[rank-1]
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.halfOpenIfElapsed()
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) record(err error, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.latencies) < latencySamples {
+		b.latencies = append(b.latencies, latency)
+	} else {
+		b.latencies[b.next] = latency
+		b.next = (b.next + 1) % latencySamples
+	}
+
+	probe := b.state == HalfOpen && b.probing
+	if probe {
+		b.probing = false
+	}
+
+	if err == nil || !b.cfg.IsFailure(err) {
+		b.failures = 0
+		if probe {
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	if probe || b.failures >= b.cfg.FailureThreshold {
+		b.openedAt = b.now()
+		b.setState(Open)
+	}
+}
+
+func (b *Breaker) halfOpenIfElapsed() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.setState(HalfOpen)
+	}
+}
+
+func (b *Breaker) setState(s State) {
+	b.state = s
+	stateGauge.WithLabelValues(b.name).Set(float64(s))
+}
@@ -0,0 +1,132 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/faketest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errDependency = errors.New("dependency error")
+
+func newTestBreaker(now *time.Time) *Breaker {
+	b := New("test", Config{FailureThreshold: 2, OpenTimeout: time.Minute})
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&now)
+
+	calls := 0
+	fail := func() error { calls++; return errDependency }
+
+	assert.Equal(t, errDependency, b.Do(fail))
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, errDependency, b.Do(fail))
+	assert.Equal(t, Open, b.State())
+
+	assert.Equal(t, ErrOpen, b.Do(fail))
+	assert.Equal(t, 2, calls)
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&now)
+
+	assert.Equal(t, errDependency, b.Do(func() error { return errDependency }))
+	assert.Nil(t, b.Do(func() error { return nil }))
+	assert.Equal(t, errDependency, b.Do(func() error { return errDependency }))
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name      string
+		probeErr  error
+		wantState State
+	}{
+		{
+			name:      "probe success closes",
+			wantState: Closed,
+		},
+		{
+			name:      "probe failure reopens",
+			probeErr:  errDependency,
+			wantState: Open,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			b := newTestBreaker(&now)
+			for i := 0; i < 2; i++ {
+				_ = b.Do(func() error { return errDependency })
+			}
+
+			now = now.Add(time.Minute)
+			assert.Equal(t, HalfOpen, b.State())
+
+			// Only a single probe is allowed while half open.
+			err := b.Do(func() error {
+				assert.Equal(t, ErrOpen, b.Do(func() error { return nil }))
+				return tt.probeErr
+			})
+			assert.Equal(t, tt.probeErr, err)
+			assert.Equal(t, tt.wantState, b.State())
+		})
+	}
+}
+
+func TestBreakerIgnoresNonFailures(t *testing.T) {
+	errNotFound := errors.New("not found")
+	b := New("test", Config{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+		IsFailure:        func(err error) bool { return !errors.Is(err, errNotFound) },
+	})
+
+	assert.Equal(t, errNotFound, b.Do(func() error { return errNotFound }))
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestBreakerLatencyPercentile(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&now)
+	assert.Equal(t, time.Duration(0), b.LatencyPercentile(50))
+
+	for i := 1; i <= 10; i++ {
+		d := time.Duration(i) * time.Millisecond
+		assert.Nil(t, b.Do(func() error { now = now.Add(d); return nil }))
+	}
+
+	assert.Equal(t, 5*time.Millisecond, b.LatencyPercentile(50))
+	assert.Equal(t, 9*time.Millisecond, b.LatencyPercentile(90))
+	assert.Equal(t, 10*time.Millisecond, b.LatencyPercentile(99))
+	assert.Equal(t, time.Millisecond, b.LatencyPercentile(0))
+
+	// Only recent calls are kept.
+	for i := 0; i < latencySamples; i++ {
+		assert.Nil(t, b.Do(func() error { now = now.Add(time.Second); return nil }))
+	}
+	assert.Equal(t, time.Second, b.LatencyPercentile(50))
+}
+
+func TestWorkflowWrapper(t *testing.T) {
+	argo := faketest.NewArgo()
+	argo.Always("Status", faketest.Fault{Err: faketest.ErrInjected})
+
+	w := NewWorkflow(argo, New("argo", Config{FailureThreshold: 1, OpenTimeout: time.Minute}))
+
+	_, err := w.Status(context.Background(), "wf")
+	assert.ErrorIs(t, err, faketest.ErrInjected)
+	_, err = w.Status(context.Background(), "wf")
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.Equal(t, 1, argo.Calls("Status"))
+}
@@ -0,0 +1,1002 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/git"
+	"github.com/cello-proj/cello/service/internal/oci"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	upper "github.com/upper/db/v4"
+)
+
+// NewWorkflow wraps a workflow.Workflow with a Breaker.
+func NewWorkflow(w workflow.Workflow, b *Breaker) workflow.Workflow {
+	return breakerWorkflow{next: w, b: b}
+}
+
+type breakerWorkflow struct {
+	next workflow.Workflow
+	b    *Breaker
+}
+
+func (w breakerWorkflow) List(ctx context.Context, selector map[string][]string, opts workflow.ListOptions) (out []workflow.Status, next string, err error) {
+	err = w.b.Do(func() error {
+		out, next, err = w.next.List(ctx, selector, opts)
+		return err
+	})
+	return out, next, err
+}
+
+func (w breakerWorkflow) ListByLabels(ctx context.Context, selector map[string]string) (out []workflow.Status, err error) {
+	err = w.b.Do(func() error {
+		out, err = w.next.ListByLabels(ctx, selector)
+		return err
+	})
+	return out, err
+}
+
+func (w breakerWorkflow) Logs(ctx context.Context, workflowName string, opts workflow.LogOptions) (out *workflow.Logs, err error) {
+	err = w.b.Do(func() error {
+		out, err = w.next.Logs(ctx, workflowName, opts)
+		return err
+	})
+	return out, err
+}
+
+func (w breakerWorkflow) WalkLogs(ctx context.Context, workflowName string, fn func(line string) bool) error {
+	return w.b.Do(func() error {
+		return w.next.WalkLogs(ctx, workflowName, fn)
+	})
+}
+
+// LogStream is long lived so only the breaker state is checked; stream errors
+// aren't recorded.
+func (w breakerWorkflow) LogStream(ctx context.Context, workflowName string, data http.ResponseWriter) error {
+	if w.b.State() == Open {
+		return ErrOpen
+	}
+	return w.next.LogStream(ctx, workflowName, data)
+}
+
+func (w breakerWorkflow) Nodes(ctx context.Context, workflowName string, opts workflow.NodeOptions) (out *workflow.Nodes, err error) {
+	err = w.b.Do(func() error {
+		out, err = w.next.Nodes(ctx, workflowName, opts)
+		return err
+	})
+	return out, err
+}
+
+func (w breakerWorkflow) Status(ctx context.Context, workflowName string) (out *workflow.Status, err error) {
+	err = w.b.Do(func() error {
+		out, err = w.next.Status(ctx, workflowName)
+		return err
+	})
+	return out, err
+}
+
+func (w breakerWorkflow) Submit(ctx context.Context, from string, parameters map[string]string, labels map[string]string, annotations map[string]string, scheduling workflow.Scheduling) (out string, err error) {
+	err = w.b.Do(func() error {
+		out, err = w.next.Submit(ctx, from, parameters, labels, annotations, scheduling)
+		return err
+	})
+	return out, err
+}
+
+func (w breakerWorkflow) Terminate(ctx context.Context, workflowName string) error {
+	return w.b.Do(func() error {
+		return w.next.Terminate(ctx, workflowName)
+	})
+}
+
+// NewCronWorkflows wraps a workflow.CronWorkflows with a Breaker.
+func NewCronWorkflows(c workflow.CronWorkflows, b *Breaker) workflow.CronWorkflows {
+	return breakerCronWorkflows{next: c, b: b}
+}
+
+type breakerCronWorkflows struct {
+	next workflow.CronWorkflows
+	b    *Breaker
+}
+
+func (c breakerCronWorkflows) Apply(ctx context.Context, cw workflow.CronWorkflow) error {
+	return c.b.Do(func() error { return c.next.Apply(ctx, cw) })
+}
+
+func (c breakerCronWorkflows) Delete(ctx context.Context, name string) error {
+	return c.b.Do(func() error { return c.next.Delete(ctx, name) })
+}
+
+// NewGitClient wraps a git.Client with a Breaker.
+func NewGitClient(c git.Client, b *Breaker) git.Client {
+	return breakerGit{next: c, b: b}
+}
+
+type breakerGit struct {
+	next git.Client
+	b    *Breaker
+}
+
+func (g breakerGit) GetManifestFile(ctx context.Context, repository, commitHash, path string) (out []byte, err error) {
+	err = g.b.Do(func() error {
+		out, err = g.next.GetManifestFile(ctx, repository, commitHash, path)
+		return err
+	})
+	return out, err
+}
+
+// NewOCIClient wraps an oci.Client with a Breaker. Missing artifacts and files
+// aren't failures.
+func NewOCIClient(c oci.Client, b *Breaker) oci.Client {
+	return breakerOCI{next: c, b: b}
+}
+
+// IsOCIFailure reports whether an OCI client error is a failure.
+func IsOCIFailure(err error) bool {
+	return !errors.Is(err, oci.ErrNotFound)
+}
+
+type breakerOCI struct {
+	next oci.Client
+	b    *Breaker
+}
+
+func (o breakerOCI) GetManifestFile(ctx context.Context, ref oci.Reference, creds *oci.Credentials, path string) (out []byte, err error) {
+	err = o.b.Do(func() error {
+		out, err = o.next.GetManifestFile(ctx, ref, creds, path)
+		return err
+	})
+	return out, err
+}
+
+// NewDBClient wraps a db.Client with a Breaker. Missing rows aren't failures.
+func NewDBClient(c db.Client, b *Breaker) db.Client {
+	return breakerDB{next: c, b: b}
+}
+
+// IsDBFailure determines if a db error is a dependency failure.
+func IsDBFailure(err error) bool {
+	return !errors.Is(err, upper.ErrNoMoreRows)
+}
+
+type breakerDB struct {
+	next db.Client
+	b    *Breaker
+}
+
+func (d breakerDB) CreateProjectEntry(ctx context.Context, pe db.ProjectEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectEntry(ctx, pe) })
+}
+
+func (d breakerDB) ReadProjectEntry(ctx context.Context, project string) (out db.ProjectEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectEntry(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListProjectEntries(ctx context.Context) (out []db.ProjectEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectEntries(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectEntry(ctx context.Context, project string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectEntry(ctx, project) })
+}
+
+func (d breakerDB) CreateExecutionEvent(ctx context.Context, ee db.ExecutionEvent) error {
+	return d.b.Do(func() error { return d.next.CreateExecutionEvent(ctx, ee) })
+}
+
+func (d breakerDB) CreateTargetGuardrailEntry(ctx context.Context, tg db.TargetGuardrailEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetGuardrailEntry(ctx, tg) })
+}
+
+func (d breakerDB) ReadTargetGuardrailEntry(ctx context.Context, project, target string) (out db.TargetGuardrailEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetGuardrailEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetGuardrailEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetGuardrailEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateTargetChangeControlEntry(ctx context.Context, tc db.TargetChangeControlEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetChangeControlEntry(ctx, tc) })
+}
+
+func (d breakerDB) ReadTargetChangeControlEntry(ctx context.Context, project, target string) (out db.TargetChangeControlEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetChangeControlEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetChangeControlEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetChangeControlEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateProjectNotificationRuleEntry(ctx context.Context, nr db.ProjectNotificationRuleEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectNotificationRuleEntry(ctx, nr) })
+}
+
+func (d breakerDB) ListProjectNotificationRuleEntries(ctx context.Context, project string) (out []db.ProjectNotificationRuleEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectNotificationRuleEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectNotificationRuleEntry(ctx context.Context, project, ruleType string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectNotificationRuleEntry(ctx, project, ruleType) })
+}
+
+func (d breakerDB) CreateProjectBusinessHoursEntry(ctx context.Context, bh db.ProjectBusinessHoursEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectBusinessHoursEntry(ctx, bh) })
+}
+
+func (d breakerDB) ReadProjectBusinessHoursEntry(ctx context.Context, project string) (out db.ProjectBusinessHoursEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectBusinessHoursEntry(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectBusinessHoursEntry(ctx context.Context, project string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectBusinessHoursEntry(ctx, project) })
+}
+
+func (d breakerDB) CreateProjectWorkflowTemplateEntry(ctx context.Context, wt db.ProjectWorkflowTemplateEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectWorkflowTemplateEntry(ctx, wt) })
+}
+
+func (d breakerDB) ListProjectWorkflowTemplateEntries(ctx context.Context, project string) (out []db.ProjectWorkflowTemplateEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectWorkflowTemplateEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectWorkflowTemplateEntry(ctx context.Context, project, kind, name string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectWorkflowTemplateEntry(ctx, project, kind, name) })
+}
+
+func (d breakerDB) CreateProjectFeatureFlagEntry(ctx context.Context, ff db.ProjectFeatureFlagEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectFeatureFlagEntry(ctx, ff) })
+}
+
+func (d breakerDB) ListProjectFeatureFlagEntries(ctx context.Context, project string) (out []db.ProjectFeatureFlagEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectFeatureFlagEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectFeatureFlagEntry(ctx context.Context, project, name string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectFeatureFlagEntry(ctx, project, name) })
+}
+
+func (d breakerDB) CreateProjectRegistryCredentialEntry(ctx context.Context, rc db.ProjectRegistryCredentialEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectRegistryCredentialEntry(ctx, rc) })
+}
+
+func (d breakerDB) ListProjectRegistryCredentialEntries(ctx context.Context, project string) (out []db.ProjectRegistryCredentialEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectRegistryCredentialEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectRegistryCredentialEntry(ctx context.Context, project, registry string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectRegistryCredentialEntry(ctx, project, registry) })
+}
+
+func (d breakerDB) CreateTargetScheduleEntry(ctx context.Context, ts db.TargetScheduleEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetScheduleEntry(ctx, ts) })
+}
+
+func (d breakerDB) ListTargetScheduleEntries(ctx context.Context, project, target string) (out []db.TargetScheduleEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListTargetScheduleEntries(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListScheduleEntries(ctx context.Context) (out []db.TargetScheduleEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListScheduleEntries(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetScheduleEntry(ctx context.Context, project, target, name string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetScheduleEntry(ctx, project, target, name) })
+}
+
+func (d breakerDB) CreateTargetSchedulingEntry(ctx context.Context, e db.TargetSchedulingEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetSchedulingEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetSchedulingEntry(ctx context.Context, project, target string) (out db.TargetSchedulingEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetSchedulingEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetSchedulingEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetSchedulingEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateTargetWorkloadIdentityEntry(ctx context.Context, e db.TargetWorkloadIdentityEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetWorkloadIdentityEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetWorkloadIdentityEntry(ctx context.Context, project, target string) (out db.TargetWorkloadIdentityEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetWorkloadIdentityEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetWorkloadIdentityEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetWorkloadIdentityEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateRunTokenEntry(ctx context.Context, rt db.RunTokenEntry) error {
+	return d.b.Do(func() error { return d.next.CreateRunTokenEntry(ctx, rt) })
+}
+
+func (d breakerDB) ListRunTokenEntries(ctx context.Context) (out []db.RunTokenEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListRunTokenEntries(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteRunTokenEntry(ctx context.Context, workflowName string) error {
+	return d.b.Do(func() error { return d.next.DeleteRunTokenEntry(ctx, workflowName) })
+}
+
+func (d breakerDB) CreateChangeSetSummaryEntry(ctx context.Context, cs db.ChangeSetSummaryEntry) error {
+	return d.b.Do(func() error { return d.next.CreateChangeSetSummaryEntry(ctx, cs) })
+}
+
+func (d breakerDB) ReadChangeSetSummaryEntry(ctx context.Context, project, target, stackName, changeSetName string) (out db.ChangeSetSummaryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadChangeSetSummaryEntry(ctx, project, target, stackName, changeSetName)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteChangeSetSummaryEntries(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteChangeSetSummaryEntries(ctx, project, target) })
+}
+
+func (d breakerDB) CreatePlanSummaryEntry(ctx context.Context, e db.PlanSummaryEntry) error {
+	return d.b.Do(func() error { return d.next.CreatePlanSummaryEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadPlanSummaryEntry(ctx context.Context, workflowName string) (out db.PlanSummaryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadPlanSummaryEntry(ctx, workflowName)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ReadCommitPlanSummaryEntry(ctx context.Context, project, target, commitHash string) (out db.PlanSummaryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadCommitPlanSummaryEntry(ctx, project, target, commitHash)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeletePlanSummaryEntries(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeletePlanSummaryEntries(ctx, project, target) })
+}
+
+func (d breakerDB) CreateTargetInventoryEntry(ctx context.Context, e db.TargetInventoryEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetInventoryEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetInventoryEntry(ctx context.Context, project, target string) (out db.TargetInventoryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetInventoryEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetInventoryEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetInventoryEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateBreakGlassEntry(ctx context.Context, e db.BreakGlassEntry) error {
+	return d.b.Do(func() error { return d.next.CreateBreakGlassEntry(ctx, e) })
+}
+
+func (d breakerDB) ListBreakGlassEntries(ctx context.Context, project, target string) (out []db.BreakGlassEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListBreakGlassEntries(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListUnrevokedBreakGlassEntries(ctx context.Context) (out []db.BreakGlassEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListUnrevokedBreakGlassEntries(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) RevokeBreakGlassEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	return d.b.Do(func() error { return d.next.RevokeBreakGlassEntry(ctx, id, revokedAt) })
+}
+
+func (d breakerDB) CreateProjectSettingsEntry(ctx context.Context, e db.ProjectSettingsEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectSettingsEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadProjectSettingsEntry(ctx context.Context, project string) (out db.ProjectSettingsEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectSettingsEntry(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) CreateProjectEnvironmentEntry(ctx context.Context, e db.ProjectEnvironmentEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectEnvironmentEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadProjectEnvironmentEntry(ctx context.Context, project, name string) (out db.ProjectEnvironmentEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectEnvironmentEntry(ctx, project, name)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListProjectEnvironmentEntries(ctx context.Context, project string) (out []db.ProjectEnvironmentEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectEnvironmentEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectEnvironmentEntry(ctx context.Context, project, name string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectEnvironmentEntry(ctx, project, name) })
+}
+
+func (d breakerDB) CreateTargetCostAllocationTagsEntry(ctx context.Context, e db.TargetCostAllocationTagsEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetCostAllocationTagsEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetCostAllocationTagsEntry(ctx context.Context, project, target string) (out db.TargetCostAllocationTagsEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetCostAllocationTagsEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetCostAllocationTagsEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetCostAllocationTagsEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateTargetSoakTimeEntry(ctx context.Context, e db.TargetSoakTimeEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetSoakTimeEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetSoakTimeEntry(ctx context.Context, project, target string) (out db.TargetSoakTimeEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetSoakTimeEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetSoakTimeEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetSoakTimeEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateTargetTerraformStateEntry(ctx context.Context, e db.TargetTerraformStateEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetTerraformStateEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetTerraformStateEntry(ctx context.Context, project, target string) (out db.TargetTerraformStateEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetTerraformStateEntry(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetTerraformStateEntry(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetTerraformStateEntry(ctx, project, target) })
+}
+
+func (d breakerDB) CreateProjectElevationEntry(ctx context.Context, e db.ProjectElevationEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectElevationEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadProjectElevationEntry(ctx context.Context, id string) (out db.ProjectElevationEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectElevationEntry(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListProjectElevationEntries(ctx context.Context, project string) (out []db.ProjectElevationEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectElevationEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) GrantProjectElevationEntry(ctx context.Context, id, grantedBy string, grantedAt, expiresAt time.Time) error {
+	return d.b.Do(func() error { return d.next.GrantProjectElevationEntry(ctx, id, grantedBy, grantedAt, expiresAt) })
+}
+
+func (d breakerDB) RevokeProjectElevationEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	return d.b.Do(func() error { return d.next.RevokeProjectElevationEntry(ctx, id, revokedAt) })
+}
+
+func (d breakerDB) CreateFrameworkImageEntry(ctx context.Context, e db.FrameworkImageEntry) error {
+	return d.b.Do(func() error { return d.next.CreateFrameworkImageEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadFrameworkImageEntry(ctx context.Context, id string) (out db.FrameworkImageEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadFrameworkImageEntry(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListFrameworkImageEntries(ctx context.Context, framework string) (out []db.FrameworkImageEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListFrameworkImageEntries(ctx, framework)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) PromoteFrameworkImageEntry(ctx context.Context, id, promotedBy string, promotedAt time.Time) error {
+	return d.b.Do(func() error { return d.next.PromoteFrameworkImageEntry(ctx, id, promotedBy, promotedAt) })
+}
+
+func (d breakerDB) AbortFrameworkImageEntry(ctx context.Context, id, abortedBy string, abortedAt time.Time) error {
+	return d.b.Do(func() error { return d.next.AbortFrameworkImageEntry(ctx, id, abortedBy, abortedAt) })
+}
+
+func (d breakerDB) CreateTargetSubmissionSourceEntry(ctx context.Context, e db.TargetSubmissionSourceEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetSubmissionSourceEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadTargetSubmissionSourceEntry(ctx context.Context, project, target, source string) (out db.TargetSubmissionSourceEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadTargetSubmissionSourceEntry(ctx, project, target, source)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) CreateTargetDependencyEntry(ctx context.Context, e db.TargetDependencyEntry) error {
+	return d.b.Do(func() error { return d.next.CreateTargetDependencyEntry(ctx, e) })
+}
+
+func (d breakerDB) ListTargetDependencyEntries(ctx context.Context) (out []db.TargetDependencyEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListTargetDependencyEntries(ctx)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteTargetDependencyEntries(ctx context.Context, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteTargetDependencyEntries(ctx, project, target) })
+}
+
+func (d breakerDB) CreateRolloutEntry(ctx context.Context, e db.RolloutEntry) error {
+	return d.b.Do(func() error { return d.next.CreateRolloutEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadRolloutEntry(ctx context.Context, id string) (out db.RolloutEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadRolloutEntry(ctx, id)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListRolloutEntries(ctx context.Context, status string) (out []db.RolloutEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListRolloutEntries(ctx, status)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) UpdateRolloutEntry(ctx context.Context, e db.RolloutEntry) error {
+	return d.b.Do(func() error { return d.next.UpdateRolloutEntry(ctx, e) })
+}
+
+func (d breakerDB) CreateExecutionNoteEntry(ctx context.Context, e db.ExecutionNoteEntry) error {
+	return d.b.Do(func() error { return d.next.CreateExecutionNoteEntry(ctx, e) })
+}
+
+func (d breakerDB) ListExecutionNoteEntries(ctx context.Context, project, target string) (out []db.ExecutionNoteEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListExecutionNoteEntries(ctx, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) CreateProjectWebhookEntry(ctx context.Context, e db.ProjectWebhookEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectWebhookEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadProjectWebhookEntry(ctx context.Context, project, id string) (out db.ProjectWebhookEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectWebhookEntry(ctx, project, id)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListProjectWebhookEntries(ctx context.Context, project string) (out []db.ProjectWebhookEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectWebhookEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectWebhookEntry(ctx context.Context, project, id string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectWebhookEntry(ctx, project, id) })
+}
+
+func (d breakerDB) CreateWebhookDeliveryEntry(ctx context.Context, e db.WebhookDeliveryEntry) error {
+	return d.b.Do(func() error { return d.next.CreateWebhookDeliveryEntry(ctx, e) })
+}
+
+func (d breakerDB) ListWebhookDeliveryEntries(ctx context.Context, webhookID string, limit int) (out []db.WebhookDeliveryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListWebhookDeliveryEntries(ctx, webhookID, limit)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListDueWebhookDeliveryEntries(ctx context.Context, now time.Time) (out []db.WebhookDeliveryEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListDueWebhookDeliveryEntries(ctx, now)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) UpdateWebhookDeliveryEntry(ctx context.Context, e db.WebhookDeliveryEntry) error {
+	return d.b.Do(func() error { return d.next.UpdateWebhookDeliveryEntry(ctx, e) })
+}
+
+func (d breakerDB) CreateProjectEncryptionKeyEntry(ctx context.Context, e db.ProjectEncryptionKeyEntry) error {
+	return d.b.Do(func() error { return d.next.CreateProjectEncryptionKeyEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadProjectEncryptionKeyEntry(ctx context.Context, project string) (out db.ProjectEncryptionKeyEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectEncryptionKeyEntry(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteProjectEncryptionKeyEntry(ctx context.Context, project string) error {
+	return d.b.Do(func() error { return d.next.DeleteProjectEncryptionKeyEntry(ctx, project) })
+}
+
+func (d breakerDB) CreateResourceIDEntry(ctx context.Context, e db.ResourceIDEntry) error {
+	return d.b.Do(func() error { return d.next.CreateResourceIDEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadResourceIDEntry(ctx context.Context, kind, project, target string) (out db.ResourceIDEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadResourceIDEntry(ctx, kind, project, target)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) DeleteResourceIDEntry(ctx context.Context, kind, project, target string) error {
+	return d.b.Do(func() error { return d.next.DeleteResourceIDEntry(ctx, kind, project, target) })
+}
+
+func (d breakerDB) ListConcurrencyBuckets(ctx context.Context, start time.Time, buckets int, bucket, maxRunning time.Duration) (out []db.ConcurrencyBucket, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListConcurrencyBuckets(ctx, start, buckets, bucket, maxRunning)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) RenameProjectEntry(ctx context.Context, from, to string, aliasExpiresAt time.Time) error {
+	return d.b.Do(func() error { return d.next.RenameProjectEntry(ctx, from, to, aliasExpiresAt) })
+}
+
+func (d breakerDB) ReadProjectAliasEntry(ctx context.Context, alias string) (out db.ProjectAliasEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadProjectAliasEntry(ctx, alias)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) ListProjectAliasEntries(ctx context.Context, project string) (out []db.ProjectAliasEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ListProjectAliasEntries(ctx, project)
+		return err
+	})
+	return out, err
+}
+
+func (d breakerDB) MoveTargetEntry(ctx context.Context, from, to, target string) error {
+	return d.b.Do(func() error { return d.next.MoveTargetEntry(ctx, from, to, target) })
+}
+
+func (d breakerDB) CreateExecutionAttestationEntry(ctx context.Context, e db.ExecutionAttestationEntry) error {
+	return d.b.Do(func() error { return d.next.CreateExecutionAttestationEntry(ctx, e) })
+}
+
+func (d breakerDB) ReadExecutionAttestationEntry(ctx context.Context, workflowName string) (out db.ExecutionAttestationEntry, err error) {
+	err = d.b.Do(func() error {
+		out, err = d.next.ReadExecutionAttestationEntry(ctx, workflowName)
+		return err
+	})
+	return out, err
+}
+
+// ProviderFn creates a credentials.Provider, e.g. credentials.NewVaultProvider.
+type ProviderFn func(a credentials.Authorization, env env.Vars, h http.Header, vaultConfig credentials.VaultConfigFn, fn credentials.VaultSvcFn) (credentials.Provider, error)
+
+// IsCredentialsFailure determines if a credentials provider error is a
+// dependency failure.
+func IsCredentialsFailure(err error) bool {
+	return !errors.Is(err, credentials.ErrNotFound) && !errors.Is(err, credentials.ErrTargetNotFound)
+}
+
+// NewProviderFn wraps the creation (the Vault login) and calls of the
+// providers created by fn with a Breaker.
+func NewProviderFn(fn ProviderFn, b *Breaker) ProviderFn {
+	return func(a credentials.Authorization, env env.Vars, h http.Header, vaultConfig credentials.VaultConfigFn, svcFn credentials.VaultSvcFn) (p credentials.Provider, err error) {
+		err = b.Do(func() error {
+			p, err = fn(a, env, h, vaultConfig, svcFn)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return breakerProvider{next: p, b: b}, nil
+	}
+}
+
+type breakerProvider struct {
+	next credentials.Provider
+	b    *Breaker
+}
+
+func (p breakerProvider) CreateProject(name string) (role, secret string, err error) {
+	err = p.b.Do(func() error {
+		role, secret, err = p.next.CreateProject(name)
+		return err
+	})
+	return role, secret, err
+}
+
+func (p breakerProvider) CreateTarget(projectName string, target types.Target) error {
+	return p.b.Do(func() error { return p.next.CreateTarget(projectName, target) })
+}
+
+func (p breakerProvider) UpdateTarget(projectName string, target types.Target) error {
+	return p.b.Do(func() error { return p.next.UpdateTarget(projectName, target) })
+}
+
+func (p breakerProvider) DeleteProject(name string) error {
+	return p.b.Do(func() error { return p.next.DeleteProject(name) })
+}
+
+func (p breakerProvider) RenameProject(from, to string) (role, secret string, err error) {
+	err = p.b.Do(func() error {
+		role, secret, err = p.next.RenameProject(from, to)
+		return err
+	})
+	return role, secret, err
+}
+
+func (p breakerProvider) MoveTarget(from, to, targetName string) error {
+	return p.b.Do(func() error { return p.next.MoveTarget(from, to, targetName) })
+}
+
+func (p breakerProvider) DeleteTarget(projectName, targetName string) error {
+	return p.b.Do(func() error { return p.next.DeleteTarget(projectName, targetName) })
+}
+
+func (p breakerProvider) GetProject(name string) (out responses.GetProject, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetProject(name)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) GetTarget(projectName, targetName string) (out types.Target, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetTarget(projectName, targetName)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) GetToken() (out string, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetToken()
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) GetProjectToken(projectName string) (out string, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetProjectToken(projectName)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) GetRunToken(wrap bool) (out credentials.RunToken, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetRunToken(wrap)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) RevokeToken(accessor string) error {
+	return p.b.Do(func() error { return p.next.RevokeToken(accessor) })
+}
+
+func (p breakerProvider) ListTargets(projectName string) (out []string, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.ListTargets(projectName)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) ProjectExists(name string) (out bool, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.ProjectExists(name)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) ProjectAuthorized(name string) (out bool, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.ProjectAuthorized(name)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) Identity() (out credentials.Identity, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.Identity()
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) TargetExists(projectName, targetName string) (out bool, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.TargetExists(projectName, targetName)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) PutTargetHostCredentials(projectName, targetName string, c credentials.HostCredentials) error {
+	return p.b.Do(func() error { return p.next.PutTargetHostCredentials(projectName, targetName, c) })
+}
+
+func (p breakerProvider) DeleteTargetHostCredentials(projectName, targetName string) error {
+	return p.b.Do(func() error { return p.next.DeleteTargetHostCredentials(projectName, targetName) })
+}
+
+func (p breakerProvider) PutRegistryPassword(ref, password string) error {
+	return p.b.Do(func() error { return p.next.PutRegistryPassword(ref, password) })
+}
+
+func (p breakerProvider) GetRegistryPassword(ref string) (out string, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetRegistryPassword(ref)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) DeleteRegistryPassword(ref string) error {
+	return p.b.Do(func() error { return p.next.DeleteRegistryPassword(ref) })
+}
+
+func (p breakerProvider) GetTargetCredentials(projectName, targetName string, ttl time.Duration) (out credentials.TargetCredentials, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetTargetCredentials(projectName, targetName, ttl)
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) RevokeLease(leaseID string) error {
+	return p.b.Do(func() error { return p.next.RevokeLease(leaseID) })
+}
+
+func (p breakerProvider) GetPolicyTemplate() (out credentials.PolicyTemplate, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetPolicyTemplate()
+		return err
+	})
+	return out, err
+}
+
+func (p breakerProvider) PutPolicyTemplate(tmpl string) error {
+	return p.b.Do(func() error { return p.next.PutPolicyTemplate(tmpl) })
+}
+
+func (p breakerProvider) DeletePolicyTemplate() error {
+	return p.b.Do(func() error { return p.next.DeletePolicyTemplate() })
+}
+
+func (p breakerProvider) GetPolicyUsage(projectName string) (out credentials.PolicyUsage, err error) {
+	err = p.b.Do(func() error {
+		out, err = p.next.GetPolicyUsage(projectName)
+		return err
+	})
+	return out, err
+}
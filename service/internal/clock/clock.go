@@ -0,0 +1,46 @@
+// Package clock tells the time and ticks for the service, so tests can replace
+// it with a fake clock (see faketest.Clock) and fast-forward time
+// deterministically, e.g. to expire TTLs or trigger background jobs.
+package clock
+
+import "time"
+
+// Clock tells the time and creates tickers.
+type Clock interface {
+	Now() time.Time
+	// NewTicker ticks every interval, like time.NewTicker.
+	NewTicker(interval time.Duration) Ticker
+}
+
+// Ticker delivers ticks until it's stopped.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// New creates the clock of the system.
+func New() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(interval time.Duration) Ticker {
+	return systemTicker{time.NewTicker(interval)}
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTicker) Stop() {
+	t.t.Stop()
+}
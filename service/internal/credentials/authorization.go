@@ -0,0 +1,254 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/validations"
+)
+
+// Scheme is the scheme of an authorization header.
+type Scheme string
+
+// Supported authorization schemes.
+const (
+	// SchemeVault is the legacy `vault:<key>:<secret>` header, the key being
+	// `admin` or the role ID of a project.
+	SchemeVault Scheme = "vault"
+	// SchemeBearer is a `Bearer <jwt>` header.
+	SchemeBearer Scheme = "bearer"
+	// SchemeHMAC is a `HMAC <key id>:<unix timestamp>:<hex signature>` header.
+	SchemeHMAC Scheme = "hmac"
+)
+
+// ErrorCode identifies why an authorization header couldn't be parsed.
+type ErrorCode string
+
+// Authorization header error codes.
+const (
+	ErrCodeEmpty            ErrorCode = "empty"
+	ErrCodeUnknownScheme    ErrorCode = "unknown_scheme"
+	ErrCodeMalformed        ErrorCode = "malformed"
+	ErrCodeEmptySegment     ErrorCode = "empty_segment"
+	ErrCodeInvalidCharacter ErrorCode = "invalid_character"
+)
+
+// ParseError is returned when an authorization header can't be parsed.
+type ParseError struct {
+	Code   ErrorCode
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid authorization header, %s", e.Reason)
+}
+
+func parseError(code ErrorCode, format string, a ...interface{}) *ParseError {
+	return &ParseError{Code: code, Reason: fmt.Sprintf(format, a...)}
+}
+
+// VaultCredentials are the credentials of a vault authorization header.
+type VaultCredentials struct {
+	Key    string
+	Secret string
+}
+
+// BearerToken is the JWT of a bearer authorization header. The signature
+// isn't verified when parsing.
+type BearerToken struct {
+	Raw    string
+	Header map[string]interface{}
+	Claims map[string]interface{}
+}
+
+// HMACSignature is the signature of an HMAC authorization header. The
+// signature isn't verified when parsing.
+type HMACSignature struct {
+	KeyID     string
+	Timestamp time.Time
+	Signature []byte
+}
+
+// ParsedAuthorization is a parsed authorization header. Only the field of
+// its scheme is set.
+type ParsedAuthorization struct {
+	Scheme Scheme
+	Vault  *VaultCredentials
+	Bearer *BearerToken
+	HMAC   *HMACSignature
+}
+
+// ParseAuthorization parses an authorization header. Errors are
+// *ParseError. Credentials are limited to printable ASCII, the secret of a
+// vault header can contain colons.
+func ParseAuthorization(header string) (*ParsedAuthorization, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, parseError(ErrCodeEmpty, "header is empty")
+	}
+
+	if strings.HasPrefix(header, string(SchemeVault)+":") {
+		if err := checkCharacters(header); err != nil {
+			return nil, err
+		}
+		return parseVault(strings.TrimPrefix(header, string(SchemeVault)+":"))
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) < 2 {
+		if i := strings.Index(header, ":"); i > 0 {
+			return nil, parseError(ErrCodeUnknownScheme, "unknown scheme '%s'", header[:i])
+		}
+		return nil, parseError(ErrCodeMalformed, "expected '<scheme> <credentials>' or 'vault:<key>:<secret>'")
+	}
+	if err := checkCharacters(parts[1]); err != nil {
+		return nil, err
+	}
+	switch Scheme(strings.ToLower(parts[0])) {
+	case SchemeBearer:
+		return parseBearer(parts[1])
+	case SchemeHMAC:
+		return parseHMAC(parts[1])
+	default:
+		return nil, parseError(ErrCodeUnknownScheme, "unknown scheme '%s'", parts[0])
+	}
+}
+
+// checkCharacters only allows printable ASCII characters, excluding spaces.
+func checkCharacters(s string) error {
+	for i, r := range s {
+		if r <= ' ' || r > '~' {
+			return parseError(ErrCodeInvalidCharacter, "invalid character at position %d", i)
+		}
+	}
+	return nil
+}
+
+func parseVault(credentials string) (*ParsedAuthorization, error) {
+	parts := strings.SplitN(credentials, ":", 2)
+	if len(parts) < 2 {
+		return nil, parseError(ErrCodeMalformed, "expected 'vault:<key>:<secret>'")
+	}
+	key, secret := parts[0], parts[1]
+	if key == "" {
+		return nil, parseError(ErrCodeEmptySegment, "key is empty")
+	}
+	if secret == "" {
+		return nil, parseError(ErrCodeEmptySegment, "secret is empty")
+	}
+	return &ParsedAuthorization{Scheme: SchemeVault, Vault: &VaultCredentials{Key: key, Secret: secret}}, nil
+}
+
+func parseBearer(token string) (*ParsedAuthorization, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, parseError(ErrCodeMalformed, "bearer token must be a jwt with 3 segments, got %d", len(segments))
+	}
+	names := []string{"header", "payload", "signature"}
+	for i, s := range segments {
+		if s == "" {
+			return nil, parseError(ErrCodeEmptySegment, "jwt %s is empty", names[i])
+		}
+	}
+
+	bt := BearerToken{Raw: token}
+	for i, dst := range []*map[string]interface{}{&bt.Header, &bt.Claims} {
+		data, err := base64.RawURLEncoding.DecodeString(segments[i])
+		if err != nil {
+			return nil, parseError(ErrCodeMalformed, "jwt %s isn't base64url encoded", names[i])
+		}
+		if err := json.Unmarshal(data, dst); err != nil {
+			return nil, parseError(ErrCodeMalformed, "jwt %s isn't a json object", names[i])
+		}
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(segments[2]); err != nil {
+		return nil, parseError(ErrCodeMalformed, "jwt signature isn't base64url encoded")
+	}
+	return &ParsedAuthorization{Scheme: SchemeBearer, Bearer: &bt}, nil
+}
+
+func parseHMAC(credentials string) (*ParsedAuthorization, error) {
+	segments := strings.Split(credentials, ":")
+	if len(segments) != 3 {
+		return nil, parseError(ErrCodeMalformed, "expected 'HMAC <key id>:<timestamp>:<signature>'")
+	}
+	names := []string{"key id", "timestamp", "signature"}
+	for i, s := range segments {
+		if s == "" {
+			return nil, parseError(ErrCodeEmptySegment, "%s is empty", names[i])
+		}
+	}
+
+	ts, err := strconv.ParseInt(segments[1], 10, 64)
+	if err != nil {
+		return nil, parseError(ErrCodeMalformed, "timestamp must be unix seconds")
+	}
+	sig, err := hex.DecodeString(segments[2])
+	if err != nil {
+		return nil, parseError(ErrCodeMalformed, "signature must be hex encoded")
+	}
+	return &ParsedAuthorization{
+		Scheme: SchemeHMAC,
+		HMAC:   &HMACSignature{KeyID: segments[0], Timestamp: time.Unix(ts, 0).UTC(), Signature: sig},
+	}, nil
+}
+
+// Authorization represents a user's authorization token.
+type Authorization struct {
+	Provider string `valid:"required"`
+	Key      string `valid:"required"`
+	Secret   string `valid:"required"`
+}
+
+func (a Authorization) Validate(optionalValidations ...func() error) error {
+	v := []func() error{
+		func() error {
+			if a.Provider != string(SchemeVault) {
+				return errors.New("provider must be vault")
+			}
+			return nil
+		},
+		func() error { return validations.ValidateStruct(a) },
+	}
+
+	v = append(v, optionalValidations...)
+
+	return validations.Validate(v...)
+}
+
+// ValidateAuthorizedAdmin determines if the Authorization is valid and an admin.
+// TODO See if this can be removed when refactoring auth.
+// Optional validation should be passed as parameter to Validate().
+func (a Authorization) ValidateAuthorizedAdmin(adminSecret string) func() error {
+	return func() error {
+		if a.Key != authorizationKeyAdmin {
+			return fmt.Errorf("must be an authorized admin")
+		}
+
+		if a.Secret != adminSecret {
+			return fmt.Errorf("must be an authorized admin, invalid admin secret")
+		}
+
+		return nil
+	}
+}
+
+// NewAuthorization provides an Authorization from a header.
+// This is separate from admin functions which use the admin env var.
+// Only vault credentials are supported by providers, other schemes have
+// their scheme as provider and fail validation.
+func NewAuthorization(authorizationHeader string) (*Authorization, error) {
+	p, err := ParseAuthorization(authorizationHeader)
+	if err != nil {
+		return nil, err
+	}
+	if p.Vault == nil {
+		return &Authorization{Provider: string(p.Scheme)}, nil
+	}
+	return &Authorization{Provider: string(p.Scheme), Key: p.Vault.Key, Secret: p.Vault.Secret}, nil
+}
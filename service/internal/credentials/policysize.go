@@ -0,0 +1,192 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ErrPolicyTooLarge is returned when a path of a policy exceeds the max size
+// of policies on its own, so the policy can't be sharded.
+var ErrPolicyTooLarge = errors.New("policy too large")
+
+// PolicyUsage is the size of the Vault policy of a project, which is sharded
+// across several Vault policies when it exceeds the max size of policies.
+type PolicyUsage struct {
+	// Bytes is the size of the policy rendered from the template.
+	Bytes int
+	// Policies are the names of the Vault policies of the project, the first
+	// one is the policy of projects which aren't sharded.
+	Policies []string
+	// Targets is the number of targets the policy was rendered with.
+	Targets int
+}
+
+// projectPolicyName returns the name of the Vault policy of the shard of the
+// policy of the project. Project names are alphanumeric, so the names of
+// shards can't be the ones of other projects.
+func projectPolicyName(projectName string, shard int) string {
+	if shard == 0 {
+		return fmt.Sprintf("%s-%s", vaultProjectPrefix, projectName)
+	}
+	return fmt.Sprintf("%s-%s-%d", vaultProjectPrefix, projectName, shard)
+}
+
+// ShardPolicy splits the policy into policies of at most maxBytes, keeping
+// path blocks whole. A max of 0 doesn't split it.
+func ShardPolicy(policy string, maxBytes int) ([]string, error) {
+	if maxBytes <= 0 || len(policy) <= maxBytes {
+		return []string{policy}, nil
+	}
+
+	root, err := hcl.Parse(policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy: failed to parse policy: %w", err)
+	}
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("policy: file doesn't contain a root object")
+	}
+
+	// Blocks are cut at the start of the next one, keeping the comments and
+	// formatting of the policy.
+	offsets := make([]int, 0, len(list.Items)+1)
+	for _, item := range list.Items {
+		offsets = append(offsets, item.Pos().Offset)
+	}
+	offsets[0] = 0
+	offsets = append(offsets, len(policy))
+
+	var shards []string
+	var shard strings.Builder
+	for i := 0; i < len(offsets)-1; i++ {
+		block := policy[offsets[i]:offsets[i+1]]
+		if len(block) > maxBytes {
+			return nil, fmt.Errorf("%w, a path is %d bytes, more than the max of %d bytes", ErrPolicyTooLarge, len(block), maxBytes)
+		}
+		if shard.Len()+len(block) > maxBytes {
+			shards = append(shards, shard.String())
+			shard.Reset()
+		}
+		shard.WriteString(block)
+	}
+	return append(shards, shard.String()), nil
+}
+
+// GetPolicyUsage returns the size of the Vault policy of the project, as
+// rendered from the current template and targets.
+func (v VaultProvider) GetPolicyUsage(projectName string) (PolicyUsage, error) {
+	if !v.isAdmin() {
+		return PolicyUsage{}, errors.New("admin credentials must be used to get policy usage")
+	}
+
+	targets, err := v.ListTargets(projectName)
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+	usage, _, err := v.renderProjectPolicies(projectName, targets)
+	return usage, err
+}
+
+// renderProjectPolicies returns the shards of the Vault policy of the project
+// with the targets.
+func (v VaultProvider) renderProjectPolicies(projectName string, targets []string) (PolicyUsage, []string, error) {
+	policy, err := v.projectPolicy(projectName, targets)
+	if err != nil {
+		return PolicyUsage{}, nil, err
+	}
+	shards, err := ShardPolicy(policy, v.policyMaxBytes)
+	if err != nil {
+		return PolicyUsage{}, nil, err
+	}
+
+	usage := PolicyUsage{Bytes: len(policy), Targets: len(targets)}
+	for i := range shards {
+		usage.Policies = append(usage.Policies, projectPolicyName(projectName, i))
+	}
+	return usage, shards, nil
+}
+
+// writeProjectPolicies writes the shards of the Vault policy of the project
+// with the targets.
+func (v VaultProvider) writeProjectPolicies(projectName string, targets []string) (PolicyUsage, error) {
+	usage, shards, err := v.renderProjectPolicies(projectName, targets)
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+	for i, shard := range shards {
+		if err := v.vaultSysSvc.PutPolicy(usage.Policies[i], shard); err != nil {
+			return PolicyUsage{}, err
+		}
+	}
+	return usage, nil
+}
+
+// projectPolicies returns the names of the Vault policies of the approle of
+// the project, its policy when the approle has none.
+func (v VaultProvider) projectPolicies(projectName string) ([]string, error) {
+	sec, err := v.vaultLogicalSvc.Read(genProjectAppRole(projectName))
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []string
+	if sec != nil {
+		values, _ := sec.Data["token_policies"].([]interface{})
+		for _, value := range values {
+			if policy, ok := value.(string); ok {
+				policies = append(policies, policy)
+			}
+		}
+	}
+	if len(policies) == 0 {
+		policies = []string{projectPolicyName(projectName, 0)}
+	}
+	return policies, nil
+}
+
+// syncProjectPolicies writes the Vault policy of the project again with its
+// current targets, e.g. once they changed. The approle of the project is
+// updated when the policy is sharded across more or fewer policies, then the
+// policies it no longer has are deleted.
+func (v VaultProvider) syncProjectPolicies(projectName string) (PolicyUsage, error) {
+	targets, err := v.ListTargets(projectName)
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+	current, err := v.projectPolicies(projectName)
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+
+	usage, err := v.writeProjectPolicies(projectName, targets)
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+
+	sort.Strings(current)
+	policies := append([]string(nil), usage.Policies...)
+	sort.Strings(policies)
+	if strings.Join(current, ",") == strings.Join(policies, ",") {
+		return usage, nil
+	}
+
+	_, err = v.vaultLogicalSvc.Write(genProjectAppRole(projectName), map[string]interface{}{
+		"token_policies": strings.Join(usage.Policies, ","),
+	})
+	if err != nil {
+		return PolicyUsage{}, err
+	}
+	for _, policy := range current {
+		if !contains(usage.Policies, policy) {
+			if err := v.vaultSysSvc.DeletePolicy(policy); err != nil {
+				return PolicyUsage{}, err
+			}
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,142 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShardPolicy(t *testing.T) {
+	policy := "# targets\n" +
+		"path \"aws/sts/a\" { capabilities = [\"read\"] }\n" +
+		"path \"aws/sts/b\" { capabilities = [\"read\"] }\n" +
+		"path \"aws/sts/c\" { capabilities = [\"read\"] }\n"
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		want     []string
+		wantErr  error
+	}{
+		{
+			name: "no max",
+			want: []string{policy},
+		},
+		{
+			name:     "under max",
+			maxBytes: len(policy),
+			want:     []string{policy},
+		},
+		{
+			name:     "sharded",
+			maxBytes: 100,
+			want: []string{
+				"# targets\npath \"aws/sts/a\" { capabilities = [\"read\"] }\npath \"aws/sts/b\" { capabilities = [\"read\"] }\n",
+				"path \"aws/sts/c\" { capabilities = [\"read\"] }\n",
+			},
+		},
+		{
+			name:     "path over max",
+			maxBytes: 40,
+			wantErr:  ErrPolicyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ShardPolicy(policy, tt.maxBytes)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("\nwant error: %v\n got error: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ndid not expect error, got: %v", err)
+			}
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("\nwant: %q\n got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVaultSyncProjectPolicies(t *testing.T) {
+	tmpl := `path "secret/data/{{.Prefix}}-{{.Project}}" { capabilities = ["read"] }
+{{range .Targets}}path "aws/sts/{{$.Prefix}}-{{$.Project}}-target-{{.}}" { capabilities = ["read"] }
+{{end}}`
+
+	tests := []struct {
+		name          string
+		targets       []interface{}
+		tokenPolicies []interface{}
+		wantPolicies  []string
+		wantRoleWrite bool
+		wantDeleted   []string
+	}{
+		{
+			name:          "unchanged",
+			targets:       []interface{}{"argo-cloudops-projects-project1-target-target1"},
+			tokenPolicies: []interface{}{"argo-cloudops-projects-project1"},
+			wantPolicies:  []string{"argo-cloudops-projects-project1"},
+		},
+		{
+			name: "sharded",
+			targets: []interface{}{
+				"argo-cloudops-projects-project1-target-target1",
+				"argo-cloudops-projects-project1-target-target2",
+				"argo-cloudops-projects-project1-target-target3",
+			},
+			tokenPolicies: []interface{}{"argo-cloudops-projects-project1"},
+			wantPolicies:  []string{"argo-cloudops-projects-project1", "argo-cloudops-projects-project1-1"},
+			wantRoleWrite: true,
+		},
+		{
+			name:          "shards removed",
+			tokenPolicies: []interface{}{"argo-cloudops-projects-project1", "argo-cloudops-projects-project1-1"},
+			wantPolicies:  []string{"argo-cloudops-projects-project1"},
+			wantRoleWrite: true,
+			wantDeleted:   []string{"argo-cloudops-projects-project1-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths, deleted []string
+			policies := map[string]string{}
+			v := VaultProvider{
+				roleID: authorizationKeyAdmin,
+				vaultLogicalSvc: &mockVaultLogical{paths: &paths, data: map[string]interface{}{
+					"data":           map[string]interface{}{"template": tmpl},
+					"keys":           tt.targets,
+					"token_policies": tt.tokenPolicies,
+				}},
+				vaultSysSvc:    &mockVaultSys{policies: policies, deleted: &deleted},
+				policyMaxBytes: 200,
+			}
+
+			usage, err := v.syncProjectPolicies("project1")
+			if err != nil {
+				t.Fatalf("\ndid not expect error, got: %v", err)
+			}
+			if !cmp.Equal(usage.Policies, tt.wantPolicies) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantPolicies, usage.Policies)
+			}
+			if usage.Targets != len(tt.targets) {
+				t.Errorf("\nwant: %v\n got: %v", len(tt.targets), usage.Targets)
+			}
+			for _, p := range tt.wantPolicies {
+				if _, ok := policies[p]; !ok {
+					t.Errorf("\npolicy %s not written", p)
+				}
+			}
+			if roleWrite := cmp.Equal(paths, []string{"auth/approle/role/argo-cloudops-projects-project1"}); roleWrite != tt.wantRoleWrite {
+				t.Errorf("\nwant role write: %v\n got writes: %v", tt.wantRoleWrite, paths)
+			}
+			if !cmp.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantDeleted, deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// DefaultPolicyTemplate is the template of the Vault policies of projects
+// unless an admin customized it, see PutPolicyTemplate.
+const DefaultPolicyTemplate = `path "aws/sts/{{.Prefix}}-{{.Project}}-target-*" { capabilities = ["read"] }
+path "secret/data/{{.Prefix}}-{{.Project}}-target-*" { capabilities = ["read"] }`
+
+// ErrInvalidPolicyTemplate is returned putting a policy template which isn't
+// valid, including templates Vault rejects.
+var ErrInvalidPolicyTemplate = errors.New("invalid policy template")
+
+// policyTemplatePath is the path of the customized policy template in the KV
+// (version 2) secrets engine, kind is data or metadata.
+func policyTemplatePath(kind string) string {
+	return fmt.Sprintf("secret/%s/argo-cloudops-policy-template", kind)
+}
+
+// policyTemplateCheckPolicy is the policy customized templates are written to,
+// then deleted, so Vault parses them before they're stored.
+const policyTemplateCheckPolicy = "argo-cloudops-policy-template-check"
+
+// PolicyTemplate is the template of the Vault policies of projects.
+type PolicyTemplate struct {
+	Template string
+	// Default is whether the template is DefaultPolicyTemplate, i.e. it
+	// wasn't customized.
+	Default bool
+}
+
+// policyTemplateData are the variables of policy templates.
+type policyTemplateData struct {
+	// Project is the name of the project.
+	Project string
+	// Prefix prefixes the names of the Vault roles and secrets of projects.
+	Prefix string
+	// Targets are the names of the targets of the project, e.g. for a path
+	// per target. The policy grows with them, see ShardPolicy.
+	Targets []string
+}
+
+// RenderPolicy returns the Vault policy of the project with the targets from
+// the template, which must be a valid policy.
+func RenderPolicy(tmpl, projectName string, targets []string) (string, error) {
+	t, err := template.New("policy").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("policy template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, policyTemplateData{Project: projectName, Prefix: vaultProjectPrefix, Targets: targets}); err != nil {
+		return "", fmt.Errorf("policy template: %w", err)
+	}
+	policy := buf.String()
+	if err := ValidatePolicy(policy); err != nil {
+		return "", err
+	}
+	return policy, nil
+}
+
+// ValidatePolicyTemplate validates the template renders valid policies which
+// differ per project, so projects can't read each other's credentials. The
+// policies of projects without targets must be valid too.
+func ValidatePolicyTemplate(tmpl string) error {
+	a, err := RenderPolicy(tmpl, "project-a", nil)
+	if err != nil {
+		return err
+	}
+	b, err := RenderPolicy(tmpl, "project-b", nil)
+	if err != nil {
+		return err
+	}
+	if a == b {
+		return errors.New("policy template must use {{.Project}}")
+	}
+	_, err = RenderPolicy(tmpl, "project-a", []string{"target-a", "target-b"})
+	return err
+}
+
+// Keys, capabilities and policies of Vault ACL policies, as parsed by Vault.
+var (
+	policyRootKeys    = []string{"name", "path"}
+	policyPathKeys    = []string{"comment", "policy", "capabilities", "allowed_parameters", "denied_parameters", "required_parameters", "min_wrapping_ttl", "max_wrapping_ttl", "mfa_methods", "control_group"}
+	policyCapabilites = []string{"deny", "create", "read", "update", "delete", "list", "sudo", "patch"}
+	policyPolicies    = []string{"deny", "read", "write", "sudo", "list"}
+)
+
+// ValidatePolicy validates the Vault ACL policy the way Vault parses it: an
+// HCL document of path blocks with valid capabilities.
+func ValidatePolicy(policy string) error {
+	root, err := hcl.Parse(policy)
+	if err != nil {
+		return fmt.Errorf("policy: failed to parse policy: %w", err)
+	}
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return errors.New("policy: file doesn't contain a root object")
+	}
+	if err := checkPolicyKeys(list, policyRootKeys); err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+
+	paths := list.Filter("path")
+	if len(paths.Items) == 0 {
+		return errors.New("policy: no paths")
+	}
+	for _, item := range paths.Items {
+		if len(item.Keys) == 0 {
+			return errors.New("policy: path without a name")
+		}
+		key := item.Keys[0].Token.Value().(string)
+
+		body, ok := item.Val.(*ast.ObjectType)
+		if !ok {
+			return fmt.Errorf("policy: path %q: must be a block", key)
+		}
+		if err := checkPolicyKeys(body.List, policyPathKeys); err != nil {
+			return fmt.Errorf("policy: path %q: %w", key, err)
+		}
+
+		var pc struct {
+			Policy       string   `hcl:"policy"`
+			Capabilities []string `hcl:"capabilities"`
+		}
+		if err := hcl.DecodeObject(&pc, item.Val); err != nil {
+			return fmt.Errorf("policy: path %q: %w", key, err)
+		}
+		if pc.Policy == "" && len(pc.Capabilities) == 0 {
+			return fmt.Errorf("policy: path %q: no capabilities", key)
+		}
+		if pc.Policy != "" && !contains(policyPolicies, pc.Policy) {
+			return fmt.Errorf("policy: path %q: invalid policy %q", key, pc.Policy)
+		}
+		for _, c := range pc.Capabilities {
+			if !contains(policyCapabilites, c) {
+				return fmt.Errorf("policy: path %q: invalid capability %q", key, c)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPolicyKeys returns an error for keys of the list which aren't valid.
+func checkPolicyKeys(list *ast.ObjectList, valid []string) error {
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if !contains(valid, key) {
+			return fmt.Errorf("invalid key %q, must be one of '%s'", key, strings.Join(valid, " "))
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPolicyTemplate returns the template of the Vault policies of projects.
+func (v VaultProvider) GetPolicyTemplate() (PolicyTemplate, error) {
+	if !v.isAdmin() {
+		return PolicyTemplate{}, errors.New("admin credentials must be used to get the policy template")
+	}
+	return v.policyTemplate()
+}
+
+func (v VaultProvider) policyTemplate() (PolicyTemplate, error) {
+	sec, err := v.vaultLogicalSvc.Read(policyTemplatePath("data"))
+	if err != nil {
+		return PolicyTemplate{}, err
+	}
+	if sec != nil {
+		if data, ok := sec.Data["data"].(map[string]interface{}); ok {
+			if tmpl, ok := data["template"].(string); ok && tmpl != "" {
+				return PolicyTemplate{Template: tmpl}, nil
+			}
+		}
+	}
+	return PolicyTemplate{Template: DefaultPolicyTemplate, Default: true}, nil
+}
+
+// projectPolicy returns the Vault policy of the project with the targets from
+// the policy template.
+func (v VaultProvider) projectPolicy(projectName string, targets []string) (string, error) {
+	pt, err := v.policyTemplate()
+	if err != nil {
+		return "", err
+	}
+	return RenderPolicy(pt.Template, projectName, targets)
+}
+
+// PutPolicyTemplate customizes the template of the Vault policies of projects
+// created or updated from now on, e.g. when their targets change. The
+// template is validated, then Vault parses an example policy of it before
+// it's stored.
+func (v VaultProvider) PutPolicyTemplate(tmpl string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to put the policy template")
+	}
+	if err := ValidatePolicyTemplate(tmpl); err != nil {
+		return fmt.Errorf("%w, %s", ErrInvalidPolicyTemplate, err)
+	}
+
+	example, err := RenderPolicy(tmpl, "policy-template-check", []string{"policy-template-check"})
+	if err != nil {
+		return fmt.Errorf("%w, %s", ErrInvalidPolicyTemplate, err)
+	}
+	if err := v.vaultSysSvc.PutPolicy(policyTemplateCheckPolicy, example); err != nil {
+		var re *vault.ResponseError
+		if errors.As(err, &re) && re.StatusCode == http.StatusBadRequest {
+			return fmt.Errorf("%w, vault rejected the policy: %s", ErrInvalidPolicyTemplate, strings.Join(re.Errors, ", "))
+		}
+		return err
+	}
+	if err := v.vaultSysSvc.DeletePolicy(policyTemplateCheckPolicy); err != nil {
+		return err
+	}
+
+	_, err = v.vaultLogicalSvc.Write(policyTemplatePath("data"), map[string]interface{}{
+		"data": map[string]interface{}{
+			"template": tmpl,
+		},
+	})
+	return err
+}
+
+// DeletePolicyTemplate restores DefaultPolicyTemplate.
+func (v VaultProvider) DeletePolicyTemplate() error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to delete the policy template")
+	}
+
+	_, err := v.vaultLogicalSvc.Delete(policyTemplatePath("metadata"))
+	return err
+}
@@ -0,0 +1,257 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	vault "github.com/hashicorp/vault/api"
+)
+
+func TestRenderPolicy(t *testing.T) {
+	// The default template renders the policy projects had before templates.
+	want := "path \"aws/sts/argo-cloudops-projects-project1-target-*\" { capabilities = [\"read\"] }\n" +
+		"path \"secret/data/argo-cloudops-projects-project1-target-*\" { capabilities = [\"read\"] }"
+
+	got, err := RenderPolicy(DefaultPolicyTemplate, "project1", nil)
+	if err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if got != want {
+		t.Errorf("\nwant: %v\n got: %v", want, got)
+	}
+}
+
+func TestValidatePolicyTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr string
+	}{
+		{
+			name: "default template",
+			tmpl: DefaultPolicyTemplate,
+		},
+		{
+			name: "parameters and list",
+			tmpl: `path "secret/metadata/{{.Project}}/*" {
+  capabilities = ["list"]
+}
+path "secret/data/{{.Project}}/*" {
+  capabilities = ["read"]
+  allowed_parameters = {
+    "version" = []
+  }
+}`,
+		},
+		{
+			name:    "invalid template",
+			tmpl:    `path "secret/data/{{.Project" { capabilities = ["read"] }`,
+			wantErr: `policy template: template: policy:1: bad character U+0022 '"'`,
+		},
+		{
+			name:    "unknown variable",
+			tmpl:    `path "secret/data/{{.Target}}" { capabilities = ["read"] }`,
+			wantErr: `policy template: template: policy:1:20: executing "policy" at <.Target>: can't evaluate field Target in type credentials.policyTemplateData`,
+		},
+		{
+			name:    "project not used",
+			tmpl:    `path "secret/data/*" { capabilities = ["read"] }`,
+			wantErr: "policy template must use {{.Project}}",
+		},
+		{
+			name:    "invalid hcl",
+			tmpl:    `path "secret/data/{{.Project}}" { capabilities = ["read"]`,
+			wantErr: `policy: failed to parse policy: At 1:56: object expected closing RBRACE got: EOF`,
+		},
+		{
+			name:    "no paths",
+			tmpl:    `name = "{{.Project}}"`,
+			wantErr: "policy: no paths",
+		},
+		{
+			name:    "paths only per target",
+			tmpl:    `{{range .Targets}}path "secret/data/{{$.Project}}/{{.}}" { capabilities = ["read"] }{{end}}`,
+			wantErr: "policy: no paths",
+		},
+		{
+			name: "paths per target",
+			tmpl: `path "secret/data/{{.Project}}" { capabilities = ["read"] }
+{{range .Targets}}path "secret/data/{{$.Project}}/{{.}}" { capabilities = ["read"] }
+{{end}}`,
+		},
+		{
+			name:    "invalid root key",
+			tmpl:    `paths "secret/data/{{.Project}}" { capabilities = ["read"] }`,
+			wantErr: `policy: invalid key "paths", must be one of 'name path'`,
+		},
+		{
+			name:    "invalid path key",
+			tmpl:    `path "secret/data/{{.Project}}" { capability = ["read"] }`,
+			wantErr: `policy: path "secret/data/project-a": invalid key "capability", must be one of 'comment policy capabilities allowed_parameters denied_parameters required_parameters min_wrapping_ttl max_wrapping_ttl mfa_methods control_group'`,
+		},
+		{
+			name:    "no capabilities",
+			tmpl:    `path "secret/data/{{.Project}}" { comment = "none" }`,
+			wantErr: `policy: path "secret/data/project-a": no capabilities`,
+		},
+		{
+			name:    "invalid capability",
+			tmpl:    `path "secret/data/{{.Project}}" { capabilities = ["write"] }`,
+			wantErr: `policy: path "secret/data/project-a": invalid capability "write"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePolicyTemplate(tt.tmpl)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("\nwant error: %v\n got error: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVaultGetPolicyTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		admin    bool
+		data     map[string]interface{}
+		vaultErr error
+		want     PolicyTemplate
+		wantErr  bool
+	}{
+		{
+			name:  "default template",
+			admin: true,
+			want:  PolicyTemplate{Template: DefaultPolicyTemplate, Default: true},
+		},
+		{
+			name:  "customized template",
+			admin: true,
+			data:  map[string]interface{}{"data": map[string]interface{}{"template": "custom"}},
+			want:  PolicyTemplate{Template: "custom"},
+		},
+		{
+			name:    "admin error",
+			wantErr: true,
+		},
+		{
+			name:     "vault error",
+			admin:    true,
+			vaultErr: errTest,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			v := VaultProvider{
+				roleID:          role,
+				vaultLogicalSvc: &mockVaultLogical{data: tt.data, err: tt.vaultErr},
+			}
+
+			got, err := v.GetPolicyTemplate()
+			if err != nil != tt.wantErr {
+				t.Errorf("\nwant error: %v\n got error: %v", tt.wantErr, err)
+			}
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("\nwant: %v\n got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVaultPutPolicyTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       bool
+		tmpl        string
+		vaultSysErr error
+		wantPaths   []string
+		wantInvalid bool
+		wantErr     bool
+	}{
+		{
+			name:      "put template success",
+			admin:     true,
+			tmpl:      `path "secret/data/{{.Project}}/*" { capabilities = ["read"] }`,
+			wantPaths: []string{"secret/data/argo-cloudops-policy-template"},
+		},
+		{
+			name:    "admin error",
+			tmpl:    `path "secret/data/{{.Project}}/*" { capabilities = ["read"] }`,
+			wantErr: true,
+		},
+		{
+			name:        "invalid template",
+			admin:       true,
+			tmpl:        `path "secret/data/*" { capabilities = ["read"] }`,
+			wantInvalid: true,
+			wantErr:     true,
+		},
+		{
+			name:        "vault rejects template",
+			admin:       true,
+			tmpl:        `path "secret/data/{{.Project}}/*" { capabilities = ["read"] }`,
+			vaultSysErr: &vault.ResponseError{StatusCode: 400, Errors: []string{"failed to parse policy"}},
+			wantInvalid: true,
+			wantErr:     true,
+		},
+		{
+			name:        "vault error",
+			admin:       true,
+			tmpl:        `path "secret/data/{{.Project}}/*" { capabilities = ["read"] }`,
+			vaultSysErr: errTest,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var paths []string
+			v := VaultProvider{
+				roleID:          role,
+				vaultLogicalSvc: &mockVaultLogical{paths: &paths},
+				vaultSysSvc:     &mockVaultSys{err: tt.vaultSysErr},
+			}
+
+			err := v.PutPolicyTemplate(tt.tmpl)
+			if err != nil != tt.wantErr {
+				t.Errorf("\nwant error: %v\n got error: %v", tt.wantErr, err)
+			}
+			if errors.Is(err, ErrInvalidPolicyTemplate) != tt.wantInvalid {
+				t.Errorf("\nwant invalid policy template error: %v\n got error: %v", tt.wantInvalid, err)
+			}
+			if !cmp.Equal(paths, tt.wantPaths) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantPaths, paths)
+			}
+		})
+	}
+}
+
+func TestVaultDeletePolicyTemplate(t *testing.T) {
+	v := VaultProvider{roleID: "testRole", vaultLogicalSvc: &mockVaultLogical{}}
+	if err := v.DeletePolicyTemplate(); err == nil {
+		t.Errorf("\nexpected error")
+	}
+
+	v.roleID = authorizationKeyAdmin
+	if err := v.DeletePolicyTemplate(); err != nil {
+		t.Errorf("\ndid not expect error, got: %v", err)
+	}
+}
@@ -0,0 +1,716 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/outbound"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/google/uuid"
+)
+
+// ErrNotSupported conveys that the credentials provider doesn't support the
+// operation, e.g. Vault policies without Vault.
+var ErrNotSupported = errors.New("not supported by the credentials provider")
+
+type secretsManager interface {
+	CreateSecret(*secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error)
+	DeleteSecret(*secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error)
+	GetSecretValue(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+	ListSecretsPages(*secretsmanager.ListSecretsInput, func(*secretsmanager.ListSecretsOutput, bool) bool) error
+	PutSecretValue(*secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error)
+}
+
+type stsAssumer interface {
+	AssumeRole(*sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)
+}
+
+// Tags of the secrets of projects, targets and host credentials, e.g. for
+// the policies of the run role and target roles to match.
+const (
+	awsTagKind    = "cello:kind"
+	awsTagProject = "cello:project"
+	awsTagTarget  = "cello:target"
+	awsTagRoleID  = "cello:role-id"
+)
+
+// Min duration of STS sessions, tokens can't be shorter than TokenMaxTTL.
+const awsMinSessionDuration = 15 * time.Minute
+
+// AWSProvider stores projects and targets as tagged secrets in AWS Secrets
+// Manager, for deployments without Vault. Project credentials are a role ID
+// and secret ID like the ones of Vault approles, the hash of the secret ID
+// is stored. Tokens are STS sessions of the run role tagged with the
+// project, which assume the roles of its targets.
+type AWSProvider struct {
+	roleID     string
+	secretID   string
+	runRoleARN string
+	sm         secretsManager
+	sts        stsAssumer
+	now        func() time.Time
+}
+
+// NewAWSProvider returns a new AWSProvider, with the signature of
+// NewVaultProvider so they're interchangeable. The Vault functions aren't
+// used.
+func NewAWSProvider(a Authorization, env env.Vars, h http.Header, _ VaultConfigFn, _ VaultSvcFn) (Provider, error) {
+	sess, err := awsSession(env)
+	if err != nil {
+		return nil, err
+	}
+	return &AWSProvider{
+		roleID:     a.Key,
+		secretID:   a.Secret,
+		runRoleARN: env.AWSRunRoleARN,
+		sm:         secretsmanager.New(sess),
+		sts:        sts.New(sess),
+		now:        time.Now,
+	}, nil
+}
+
+func awsSession(env env.Vars) (*session.Session, error) {
+	config := aws.NewConfig().WithHTTPClient(outbound.Client(30 * time.Second))
+	if env.AWSSecretsManagerRegion != "" {
+		config = config.WithRegion(env.AWSSecretsManagerRegion)
+	}
+	return session.NewSession(config)
+}
+
+// PingSecretsManager lists a secret, failing when Secrets Manager is
+// unreachable or the service isn't allowed to list secrets.
+func PingSecretsManager(env env.Vars) error {
+	sess, err := awsSession(env)
+	if err != nil {
+		return err
+	}
+	return secretsmanager.New(sess).ListSecretsPages(&secretsmanager.ListSecretsInput{MaxResults: aws.Int64(1)}, func(*secretsmanager.ListSecretsOutput, bool) bool {
+		return false
+	})
+}
+
+func genProjectSecretName(projectName string) string {
+	return fmt.Sprintf("%s/%s", vaultProjectPrefix, projectName)
+}
+
+func genTargetSecretName(projectName, targetName string) string {
+	return fmt.Sprintf("%s/%s/targets/%s", vaultProjectPrefix, projectName, targetName)
+}
+
+func genTargetHostSecretName(projectName, targetName string) string {
+	return genTargetSecretName(projectName, targetName) + "/host"
+}
+
+// genRegistrySecretName returns the name of the secret of the password of
+// registry credentials, outside of the projects so sessions can't read it.
+func genRegistrySecretName(ref string) string {
+	return fmt.Sprintf("argo-cloudops-registries/%s", ref)
+}
+
+// awsProject is the secret of a project.
+type awsProject struct {
+	RoleID       string    `json:"role_id"`
+	SecretIDHash string    `json:"secret_id_hash"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// awsSessionToken is a token, the credentials of an STS session base64
+// encoded as JSON.
+type awsSessionToken struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+func hashSecretID(secretID string) string {
+	sum := sha256.Sum256([]byte(secretID))
+	return hex.EncodeToString(sum[:])
+}
+
+func isAWSNotFound(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException
+}
+
+func awsTags(kv ...string) []*secretsmanager.Tag {
+	tags := []*secretsmanager.Tag{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		tags = append(tags, &secretsmanager.Tag{Key: aws.String(kv[i]), Value: aws.String(kv[i+1])})
+	}
+	return tags
+}
+
+// readSecret unmarshals the JSON of the secret into v, ErrNotFound when the
+// secret doesn't exist.
+func (p AWSProvider) readSecret(name string, v interface{}) error {
+	out, err := p.sm.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+	if isAWSNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(aws.StringValue(out.SecretString)), v)
+}
+
+// createSecret creates the secret with the JSON of v and the tags.
+func (p AWSProvider) createSecret(name string, v interface{}, tags []*secretsmanager.Tag) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = p.sm.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		SecretString: aws.String(string(data)),
+		Tags:         tags,
+	})
+	return err
+}
+
+// putSecret writes the JSON of v as the new version of the secret.
+func (p AWSProvider) putSecret(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = p.sm.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(string(data)),
+	})
+	return err
+}
+
+// deleteSecret deletes the secret without a recovery window, so it can be
+// created again. Secrets that don't exist are ignored.
+func (p AWSProvider) deleteSecret(name string) error {
+	_, err := p.sm.DeleteSecret(&secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(name),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if isAWSNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (p AWSProvider) isAdmin() bool {
+	return p.roleID == authorizationKeyAdmin
+}
+
+func (p AWSProvider) CreateProject(name string) (string, string, error) {
+	if !p.isAdmin() {
+		return "", "", errors.New("admin credentials must be used to create project")
+	}
+	return p.createProject(name)
+}
+
+// createProject creates the secret of the project with new credentials,
+// expiring like the secret IDs of Vault approles.
+func (p AWSProvider) createProject(name string) (string, string, error) {
+	roleID, secretID := uuid.NewString(), uuid.NewString()
+	project := awsProject{
+		RoleID:       roleID,
+		SecretIDHash: hashSecretID(secretID),
+		ExpiresAt:    p.now().Add(8776 * time.Hour).UTC(),
+	}
+	tags := awsTags(awsTagKind, "project", awsTagProject, name, awsTagRoleID, roleID)
+	if err := p.createSecret(genProjectSecretName(name), project, tags); err != nil {
+		return "", "", fmt.Errorf("aws create project error: %w", err)
+	}
+	return roleID, secretID, nil
+}
+
+// CreateTarget creates the secret of an aws_account target, other target
+// types require Vault.
+func (p AWSProvider) CreateTarget(projectName string, target types.Target) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to create target")
+	}
+	if target.Type != types.TargetTypeAWSAccount {
+		return fmt.Errorf("%s targets are %w", target.Type, ErrNotSupported)
+	}
+
+	tags := awsTags(awsTagKind, "target", awsTagProject, projectName, awsTagTarget, target.Name)
+	return p.createSecret(genTargetSecretName(projectName, target.Name), target, tags)
+}
+
+// UpdateTarget writes the target as the new version of its secret.
+func (p AWSProvider) UpdateTarget(projectName string, target types.Target) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to update target")
+	}
+	if target.Type != types.TargetTypeAWSAccount {
+		return fmt.Errorf("%s targets are %w", target.Type, ErrNotSupported)
+	}
+	return p.putSecret(genTargetSecretName(projectName, target.Name), target)
+}
+
+func (p AWSProvider) DeleteProject(name string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete project")
+	}
+	if err := p.deleteSecret(genProjectSecretName(name)); err != nil {
+		return fmt.Errorf("aws delete project error: %w", err)
+	}
+	return nil
+}
+
+// RenameProject moves the targets of the project, with their host
+// credentials, to the new name, then creates the project of the new name
+// with new credentials and deletes the former one.
+func (p AWSProvider) RenameProject(from, to string) (string, string, error) {
+	if !p.isAdmin() {
+		return "", "", errors.New("admin credentials must be used to rename project")
+	}
+
+	targets, err := p.ListTargets(from)
+	if err != nil {
+		return "", "", err
+	}
+	for _, target := range targets {
+		if err := p.renameTarget(from, to, target); err != nil {
+			return "", "", fmt.Errorf("aws rename target %s error: %w", target, err)
+		}
+	}
+
+	roleID, secretID, err := p.createProject(to)
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.deleteSecret(genProjectSecretName(from)); err != nil {
+		return "", "", fmt.Errorf("aws delete project error: %w", err)
+	}
+	return roleID, secretID, nil
+}
+
+// MoveTarget moves a target of the project, with its host credentials, to
+// another project.
+func (p AWSProvider) MoveTarget(from, to, targetName string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to move target")
+	}
+	return p.renameTarget(from, to, targetName)
+}
+
+// renameTarget creates the secrets of the target and its host credentials in
+// another project, then deletes the ones of the project.
+func (p AWSProvider) renameTarget(from, to, targetName string) error {
+	var target types.Target
+	if err := p.readSecret(genTargetSecretName(from, targetName), &target); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrTargetNotFound
+		}
+		return err
+	}
+	tags := awsTags(awsTagKind, "target", awsTagProject, to, awsTagTarget, targetName)
+	if err := p.createSecret(genTargetSecretName(to, targetName), target, tags); err != nil {
+		return err
+	}
+
+	var host map[string]string
+	err := p.readSecret(genTargetHostSecretName(from, targetName), &host)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err == nil {
+		tags := awsTags(awsTagKind, "host_credentials", awsTagProject, to, awsTagTarget, targetName)
+		if err := p.createSecret(genTargetHostSecretName(to, targetName), host, tags); err != nil {
+			return err
+		}
+		if err := p.deleteSecret(genTargetHostSecretName(from, targetName)); err != nil {
+			return err
+		}
+	}
+
+	return p.deleteSecret(genTargetSecretName(from, targetName))
+}
+
+// DeleteTarget deletes the target of the project and its host credentials.
+func (p AWSProvider) DeleteTarget(projectName, targetName string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete target")
+	}
+	if err := p.deleteSecret(genTargetHostSecretName(projectName, targetName)); err != nil {
+		return err
+	}
+	return p.deleteSecret(genTargetSecretName(projectName, targetName))
+}
+
+func (p AWSProvider) GetProject(projectName string) (responses.GetProject, error) {
+	var project awsProject
+	if err := p.readSecret(genProjectSecretName(projectName), &project); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return responses.GetProject{}, ErrNotFound
+		}
+		return responses.GetProject{}, fmt.Errorf("aws get project error: %w", err)
+	}
+	return responses.GetProject{Name: projectName}, nil
+}
+
+func (p AWSProvider) GetTarget(projectName, targetName string) (types.Target, error) {
+	if !p.isAdmin() {
+		return types.Target{}, errors.New("admin credentials must be used to get target information")
+	}
+
+	var target types.Target
+	if err := p.readSecret(genTargetSecretName(projectName, targetName), &target); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return types.Target{}, ErrTargetNotFound
+		}
+		return types.Target{}, fmt.Errorf("aws get target error: %w", err)
+	}
+	return target, nil
+}
+
+// GetToken gets a token of the project of the credentials.
+func (p AWSProvider) GetToken() (string, error) {
+	rt, err := p.GetRunToken(false)
+	return rt.Token, err
+}
+
+// GetRunToken gets a new token for a single workflow run, a session of the
+// run role tagged with the project of the credentials. Sessions can't be
+// response wrapped.
+func (p AWSProvider) GetRunToken(wrap bool) (RunToken, error) {
+	if p.isAdmin() {
+		return RunToken{}, errors.New("admin credentials cannot be used to get tokens")
+	}
+	if wrap {
+		return RunToken{}, fmt.Errorf("wrapped run tokens are %w", ErrNotSupported)
+	}
+
+	project, err := p.authenticate()
+	if err != nil {
+		return RunToken{}, err
+	}
+	return p.projectToken(project)
+}
+
+// GetProjectToken gets a token of the project using admin credentials, e.g.
+// for workflows submitted on behalf of the project.
+func (p AWSProvider) GetProjectToken(projectName string) (string, error) {
+	if !p.isAdmin() {
+		return "", errors.New("admin credentials must be used to get project tokens")
+	}
+	rt, err := p.projectToken(projectName)
+	return rt.Token, err
+}
+
+// projectToken assumes the run role with the project as session tag, which
+// targets roles of the project trust. The tag is transitive, so it's kept
+// when assuming them.
+func (p AWSProvider) projectToken(projectName string) (RunToken, error) {
+	name := fmt.Sprintf("cello-%s-%d", projectName, p.now().Unix())
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	out, err := p.sts.AssumeRole(&sts.AssumeRoleInput{
+		RoleArn:           aws.String(p.runRoleARN),
+		RoleSessionName:   aws.String(name),
+		DurationSeconds:   aws.Int64(int64(awsMinSessionDuration / time.Second)),
+		Tags:              []*sts.Tag{{Key: aws.String(awsTagProject), Value: aws.String(projectName)}},
+		TransitiveTagKeys: []*string{aws.String(awsTagProject)},
+	})
+	if err != nil {
+		return RunToken{}, fmt.Errorf("aws assume run role error: %w", err)
+	}
+
+	data, err := json.Marshal(awsSessionToken{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+	})
+	if err != nil {
+		return RunToken{}, err
+	}
+	return RunToken{
+		Token:    base64.StdEncoding.EncodeToString(data),
+		Accessor: aws.StringValue(out.AssumedRoleUser.AssumedRoleId),
+	}, nil
+}
+
+// RevokeToken is a no-op, STS sessions can't be revoked and expire after 15
+// minutes.
+func (p AWSProvider) RevokeToken(accessor string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to revoke tokens")
+	}
+	return nil
+}
+
+// ListTargets lists the targets of the project, the secrets named after it.
+func (p AWSProvider) ListTargets(project string) ([]string, error) {
+	if !p.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list targets")
+	}
+
+	prefix := genTargetSecretName(project, "")
+	// allow empty array to render json as []
+	list := make([]string, 0)
+	err := p.sm.ListSecretsPages(&secretsmanager.ListSecretsInput{
+		Filters: []*secretsmanager.Filter{{Key: aws.String(secretsmanager.FilterNameStringTypeName), Values: []*string{aws.String(prefix)}}},
+	}, func(out *secretsmanager.ListSecretsOutput, _ bool) bool {
+		for _, s := range out.SecretList {
+			name := strings.TrimPrefix(aws.StringValue(s.Name), prefix)
+			if strings.HasPrefix(aws.StringValue(s.Name), prefix) && !strings.Contains(name, "/") {
+				list = append(list, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("aws list error: %w", err)
+	}
+	return list, nil
+}
+
+func (p AWSProvider) ProjectExists(name string) (bool, error) {
+	_, err := p.GetProject(name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ProjectAuthorized reports whether the credentials of the provider are the
+// ones of the project and haven't expired. It's false for admin credentials
+// and projects that don't exist.
+func (p AWSProvider) ProjectAuthorized(projectName string) (bool, error) {
+	if p.isAdmin() {
+		return false, nil
+	}
+
+	var project awsProject
+	err := p.readSecret(genProjectSecretName(projectName), &project)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("aws read project error: %w", err)
+	}
+	return p.valid(project), nil
+}
+
+// valid reports whether the credentials of the provider are the ones of the
+// project and haven't expired.
+func (p AWSProvider) valid(project awsProject) bool {
+	return project.RoleID == p.roleID &&
+		subtle.ConstantTimeCompare([]byte(project.SecretIDHash), []byte(hashSecretID(p.secretID))) == 1 &&
+		p.now().Before(project.ExpiresAt)
+}
+
+// authenticate returns the project of the credentials, the project with their
+// role ID tag, or ErrInvalidCredentials.
+func (p AWSProvider) authenticate() (string, error) {
+	var names []string
+	err := p.sm.ListSecretsPages(&secretsmanager.ListSecretsInput{
+		Filters: []*secretsmanager.Filter{
+			{Key: aws.String(secretsmanager.FilterNameStringTypeTagKey), Values: []*string{aws.String(awsTagRoleID)}},
+			{Key: aws.String(secretsmanager.FilterNameStringTypeTagValue), Values: []*string{aws.String(p.roleID)}},
+		},
+	}, func(out *secretsmanager.ListSecretsOutput, _ bool) bool {
+		for _, s := range out.SecretList {
+			for _, tag := range s.Tags {
+				if aws.StringValue(tag.Key) == awsTagRoleID && aws.StringValue(tag.Value) == p.roleID {
+					names = append(names, aws.StringValue(s.Name))
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("aws list error: %w", err)
+	}
+	if len(names) != 1 {
+		return "", ErrInvalidCredentials
+	}
+
+	projectName := strings.TrimPrefix(names[0], genProjectSecretName(""))
+	authorized, err := p.ProjectAuthorized(projectName)
+	if err != nil {
+		return "", err
+	}
+	if !authorized {
+		return "", ErrInvalidCredentials
+	}
+	return projectName, nil
+}
+
+// Identity returns who the credentials of the provider are. Returns
+// ErrInvalidCredentials when they aren't the ones of a project or expired.
+func (p AWSProvider) Identity() (Identity, error) {
+	if p.isAdmin() {
+		return Identity{Admin: true}, nil
+	}
+
+	projectName, err := p.authenticate()
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var project awsProject
+	if err := p.readSecret(genProjectSecretName(projectName), &project); err != nil {
+		return Identity{}, fmt.Errorf("aws read project error: %w", err)
+	}
+	return Identity{Project: projectName, ExpiresAt: project.ExpiresAt}, nil
+}
+
+func (p AWSProvider) TargetExists(projectName, targetName string) (bool, error) {
+	_, err := p.GetTarget(projectName, targetName)
+	if errors.Is(err, ErrTargetNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PutTargetHostCredentials stores the host credentials of a target, where
+// sessions of the project can read them.
+func (p AWSProvider) PutTargetHostCredentials(projectName, targetName string, c HostCredentials) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to put target host credentials")
+	}
+
+	name := genTargetHostSecretName(projectName, targetName)
+	host := map[string]string{
+		"user":        c.User,
+		"private_key": c.PrivateKey,
+		"password":    c.Password,
+	}
+	err := p.putSecret(name, host)
+	if isAWSNotFound(err) {
+		tags := awsTags(awsTagKind, "host_credentials", awsTagProject, projectName, awsTagTarget, targetName)
+		return p.createSecret(name, host, tags)
+	}
+	return err
+}
+
+// DeleteTargetHostCredentials deletes the host credentials of a target.
+func (p AWSProvider) DeleteTargetHostCredentials(projectName, targetName string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete target host credentials")
+	}
+	return p.deleteSecret(genTargetHostSecretName(projectName, targetName))
+}
+
+// PutRegistryPassword stores the password of registry credentials under the
+// reference kept with the credentials.
+func (p AWSProvider) PutRegistryPassword(ref, password string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to put registry password")
+	}
+
+	name := genRegistrySecretName(ref)
+	secret := map[string]string{"password": password}
+	err := p.putSecret(name, secret)
+	if isAWSNotFound(err) {
+		return p.createSecret(name, secret, awsTags(awsTagKind, "registry_password"))
+	}
+	return err
+}
+
+// GetRegistryPassword returns the password of registry credentials,
+// ErrNotFound when there's none for the reference.
+func (p AWSProvider) GetRegistryPassword(ref string) (string, error) {
+	if !p.isAdmin() {
+		return "", errors.New("admin credentials must be used to get registry password")
+	}
+
+	var secret map[string]string
+	if err := p.readSecret(genRegistrySecretName(ref), &secret); err != nil {
+		return "", err
+	}
+	return secret["password"], nil
+}
+
+// DeleteRegistryPassword deletes the password of registry credentials.
+func (p AWSProvider) DeleteRegistryPassword(ref string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete registry password")
+	}
+	return p.deleteSecret(genRegistrySecretName(ref))
+}
+
+// GetTargetCredentials assumes the role of the target for the TTL, at least
+// 15 minutes, with its policies as session policies. Sessions have no lease.
+func (p AWSProvider) GetTargetCredentials(projectName, targetName string, ttl time.Duration) (TargetCredentials, error) {
+	if !p.isAdmin() {
+		return TargetCredentials{}, errors.New("admin credentials must be used to get target credentials")
+	}
+
+	target, err := p.GetTarget(projectName, targetName)
+	if err != nil {
+		return TargetCredentials{}, err
+	}
+	if ttl < awsMinSessionDuration {
+		ttl = awsMinSessionDuration
+	}
+
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(target.Properties.RoleArn),
+		RoleSessionName: aws.String(fmt.Sprintf("cello-break-glass-%d", p.now().Unix())),
+		DurationSeconds: aws.Int64(int64(ttl / time.Second)),
+		Tags:            []*sts.Tag{{Key: aws.String(awsTagProject), Value: aws.String(projectName)}},
+	}
+	if target.Properties.PolicyDocument != "" {
+		input.Policy = aws.String(target.Properties.PolicyDocument)
+	}
+	for _, arn := range target.Properties.PolicyArns {
+		input.PolicyArns = append(input.PolicyArns, &sts.PolicyDescriptorType{Arn: aws.String(arn)})
+	}
+
+	out, err := p.sts.AssumeRole(input)
+	if err != nil {
+		return TargetCredentials{}, err
+	}
+	return TargetCredentials{
+		AccessKeyID:     aws.StringValue(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(out.Credentials.SessionToken),
+		LeaseTTL:        ttl,
+	}, nil
+}
+
+// RevokeLease is a no-op, sessions have no lease and expire with their TTL.
+func (p AWSProvider) RevokeLease(leaseID string) error {
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to revoke leases")
+	}
+	return nil
+}
+
+// GetPolicyTemplate isn't supported, policies are Vault policies.
+func (p AWSProvider) GetPolicyTemplate() (PolicyTemplate, error) {
+	return PolicyTemplate{}, fmt.Errorf("vault policy templates are %w", ErrNotSupported)
+}
+
+// PutPolicyTemplate isn't supported, policies are Vault policies.
+func (p AWSProvider) PutPolicyTemplate(string) error {
+	return fmt.Errorf("vault policy templates are %w", ErrNotSupported)
+}
+
+// DeletePolicyTemplate isn't supported, policies are Vault policies.
+func (p AWSProvider) DeletePolicyTemplate() error {
+	return fmt.Errorf("vault policy templates are %w", ErrNotSupported)
+}
+
+// GetPolicyUsage isn't supported, policies are Vault policies.
+func (p AWSProvider) GetPolicyUsage(string) (PolicyUsage, error) {
+	return PolicyUsage{}, fmt.Errorf("vault policies are %w", ErrNotSupported)
+}
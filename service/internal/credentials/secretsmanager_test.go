@@ -0,0 +1,475 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeSecret struct {
+	value string
+	tags  []*secretsmanager.Tag
+}
+
+// fakeSecretsManager stores secrets in memory, matching name filters by
+// prefix and tag filters by any tag like Secrets Manager.
+type fakeSecretsManager struct {
+	secrets map[string]fakeSecret
+	err     error
+}
+
+func newFakeSecretsManager() *fakeSecretsManager {
+	return &fakeSecretsManager{secrets: map[string]fakeSecret{}}
+}
+
+func notFound() error {
+	return awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+}
+
+func (f *fakeSecretsManager) CreateSecret(in *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.secrets[*in.Name]; ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "exists", nil)
+	}
+	f.secrets[*in.Name] = fakeSecret{value: *in.SecretString, tags: in.Tags}
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) DeleteSecret(in *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.secrets[*in.SecretId]; !ok {
+		return nil, notFound()
+	}
+	delete(f.secrets, *in.SecretId)
+	return &secretsmanager.DeleteSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) GetSecretValue(in *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	s, ok := f.secrets[*in.SecretId]
+	if !ok {
+		return nil, notFound()
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(s.value)}, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValue(in *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	s, ok := f.secrets[*in.SecretId]
+	if !ok {
+		return nil, notFound()
+	}
+	s.value = *in.SecretString
+	f.secrets[*in.SecretId] = s
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecretsManager) ListSecretsPages(in *secretsmanager.ListSecretsInput, fn func(*secretsmanager.ListSecretsOutput, bool) bool) error {
+	if f.err != nil {
+		return f.err
+	}
+	names := []string{}
+	for name := range f.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &secretsmanager.ListSecretsOutput{}
+	for _, name := range names {
+		if matchesFilters(name, f.secrets[name].tags, in.Filters) {
+			out.SecretList = append(out.SecretList, &secretsmanager.SecretListEntry{Name: aws.String(name), Tags: f.secrets[name].tags})
+		}
+	}
+	fn(out, true)
+	return nil
+}
+
+func matchesFilters(name string, tags []*secretsmanager.Tag, filters []*secretsmanager.Filter) bool {
+	for _, filter := range filters {
+		value := *filter.Values[0]
+		matched := false
+		switch *filter.Key {
+		case secretsmanager.FilterNameStringTypeName:
+			matched = strings.HasPrefix(name, value)
+		case secretsmanager.FilterNameStringTypeTagKey:
+			for _, tag := range tags {
+				matched = matched || *tag.Key == value
+			}
+		case secretsmanager.FilterNameStringTypeTagValue:
+			for _, tag := range tags {
+				matched = matched || *tag.Value == value
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+type fakeSTS struct {
+	inputs []*sts.AssumeRoleInput
+	err    error
+}
+
+func (f *fakeSTS) AssumeRole(in *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.inputs = append(f.inputs, in)
+	return &sts.AssumeRoleOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{AssumedRoleId: aws.String("AROA:" + *in.RoleSessionName)},
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("ASIA"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+		},
+	}, nil
+}
+
+var testNow = time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestAWSProvider(sm *fakeSecretsManager, st *fakeSTS, roleID, secretID string) AWSProvider {
+	return AWSProvider{
+		roleID:     roleID,
+		secretID:   secretID,
+		runRoleARN: "arn:aws:iam::123456789012:role/cello-run",
+		sm:         sm,
+		sts:        st,
+		now:        func() time.Time { return testNow },
+	}
+}
+
+func testAWSTarget(name string) types.Target {
+	return types.Target{
+		Name: name,
+		Type: types.TargetTypeAWSAccount,
+		Properties: types.TargetProperties{
+			CredentialType: "assumed_role",
+			PolicyArns:     []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			RoleArn:        "arn:aws:iam::123456789012:role/target",
+		},
+	}
+}
+
+func TestAWSProjectCredentials(t *testing.T) {
+	sm := newFakeSecretsManager()
+	admin := newTestAWSProvider(sm, &fakeSTS{}, authorizationKeyAdmin, "")
+
+	roleID, secretID, err := admin.CreateProject("project1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := sm.secrets["argo-cloudops-projects/project1"]; !ok {
+		t.Fatalf("expected project secret, got %v", sm.secrets)
+	}
+	if strings.Contains(sm.secrets["argo-cloudops-projects/project1"].value, secretID) {
+		t.Errorf("expected the secret id to be hashed")
+	}
+
+	tests := []struct {
+		name         string
+		roleID       string
+		secretID     string
+		now          time.Time
+		wantIdentity Identity
+		wantErr      error
+	}{
+		{
+			name:         "valid",
+			roleID:       roleID,
+			secretID:     secretID,
+			now:          testNow,
+			wantIdentity: Identity{Project: "project1", ExpiresAt: testNow.Add(8776 * time.Hour)},
+		},
+		{
+			name:     "invalid secret id",
+			roleID:   roleID,
+			secretID: "invalid",
+			now:      testNow,
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "unknown role id",
+			roleID:   "unknown",
+			secretID: secretID,
+			now:      testNow,
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "expired",
+			roleID:   roleID,
+			secretID: secretID,
+			now:      testNow.Add(8777 * time.Hour),
+			wantErr:  ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestAWSProvider(sm, &fakeSTS{}, tt.roleID, tt.secretID)
+			p.now = func() time.Time { return tt.now }
+
+			identity, err := p.Identity()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.wantIdentity, identity); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+
+			authorized, err := p.ProjectAuthorized("project1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if authorized != (tt.wantErr == nil) {
+				t.Errorf("expected authorized %v, got %v", tt.wantErr == nil, authorized)
+			}
+		})
+	}
+}
+
+func TestAWSRunToken(t *testing.T) {
+	sm := newFakeSecretsManager()
+	admin := newTestAWSProvider(sm, &fakeSTS{}, authorizationKeyAdmin, "")
+	roleID, secretID, err := admin.CreateProject("project1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	st := &fakeSTS{}
+	p := newTestAWSProvider(sm, st, roleID, secretID)
+	rt, err := p.GetRunToken(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(rt.Token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var token awsSessionToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(awsSessionToken{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "session"}, token); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	if rt.Accessor != "AROA:cello-project1-1646092800" {
+		t.Errorf("unexpected accessor %s", rt.Accessor)
+	}
+
+	in := st.inputs[0]
+	if *in.RoleArn != "arn:aws:iam::123456789012:role/cello-run" || *in.DurationSeconds != 900 {
+		t.Errorf("unexpected assume role input %v", in)
+	}
+	if *in.Tags[0].Key != "cello:project" || *in.Tags[0].Value != "project1" || *in.TransitiveTagKeys[0] != "cello:project" {
+		t.Errorf("expected transitive project session tag, got %v", in)
+	}
+
+	if _, err := p.GetRunToken(true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected wrapped run tokens not to be supported, got %v", err)
+	}
+	if _, err := newTestAWSProvider(sm, st, roleID, "invalid").GetRunToken(false); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestAWSTargets(t *testing.T) {
+	sm := newFakeSecretsManager()
+	p := newTestAWSProvider(sm, &fakeSTS{}, authorizationKeyAdmin, "")
+	if _, _, err := p.CreateProject("project1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := p.CreateProject("project10"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, target := range []types.Target{testAWSTarget("target1"), testAWSTarget("target2")} {
+		if err := p.CreateTarget("project1", target); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := p.CreateTarget("project10", testAWSTarget("target3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.PutTargetHostCredentials("project1", "target1", HostCredentials{User: "ansible", Password: "pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gcp := types.Target{Name: "gcp", Type: types.TargetTypeGCPProject}
+	if err := p.CreateTarget("project1", gcp); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected gcp_project targets not to be supported, got %v", err)
+	}
+
+	targets, err := p.ListTargets("project1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"target1", "target2"}, targets); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	updated := testAWSTarget("target1")
+	updated.Properties.PolicyDocument = `{"Version":"2012-10-17"}`
+	if err := p.UpdateTarget("project1", updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := p.GetTarget("project1", "target1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(updated, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	if err := p.MoveTarget("project1", "project10", "target1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists, _ := p.TargetExists("project1", "target1"); exists {
+		t.Errorf("expected target to be moved")
+	}
+	if _, ok := sm.secrets["argo-cloudops-projects/project10/targets/target1/host"]; !ok {
+		t.Errorf("expected host credentials to be moved")
+	}
+
+	if err := p.DeleteTarget("project10", "target1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.GetTarget("project10", "target1"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("expected target not found, got %v", err)
+	}
+	if _, ok := sm.secrets["argo-cloudops-projects/project10/targets/target1/host"]; ok {
+		t.Errorf("expected host credentials to be deleted")
+	}
+}
+
+func TestAWSRenameProject(t *testing.T) {
+	sm := newFakeSecretsManager()
+	p := newTestAWSProvider(sm, &fakeSTS{}, authorizationKeyAdmin, "")
+	formerRoleID, _, err := p.CreateProject("project1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.CreateTarget("project1", testAWSTarget("target1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	roleID, secretID, err := p.RenameProject("project1", "project2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if roleID == formerRoleID {
+		t.Errorf("expected new credentials")
+	}
+	if exists, _ := p.ProjectExists("project1"); exists {
+		t.Errorf("expected former project to be deleted")
+	}
+	targets, _ := p.ListTargets("project2")
+	if diff := cmp.Diff([]string{"target1"}, targets); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	identity, err := newTestAWSProvider(sm, &fakeSTS{}, roleID, secretID).Identity()
+	if err != nil || identity.Project != "project2" {
+		t.Errorf("expected identity of project2, got %v %v", identity, err)
+	}
+}
+
+func TestAWSGetTargetCredentials(t *testing.T) {
+	sm := newFakeSecretsManager()
+	st := &fakeSTS{}
+	p := newTestAWSProvider(sm, st, authorizationKeyAdmin, "")
+	if err := p.CreateTarget("project1", testAWSTarget("target1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := p.GetTargetCredentials("project1", "target1", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := TargetCredentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "session", LeaseTTL: 15 * time.Minute}
+	if diff := cmp.Diff(want, c); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	in := st.inputs[0]
+	if *in.RoleArn != "arn:aws:iam::123456789012:role/target" || *in.DurationSeconds != 900 || *in.PolicyArns[0].Arn != "arn:aws:iam::aws:policy/ReadOnlyAccess" {
+		t.Errorf("unexpected assume role input %v", in)
+	}
+
+	if _, err := p.GetTargetCredentials("project1", "missing", time.Hour); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("expected target not found, got %v", err)
+	}
+}
+
+func TestAWSAdminRequired(t *testing.T) {
+	p := newTestAWSProvider(newFakeSecretsManager(), &fakeSTS{}, "role", "secret")
+
+	if _, _, err := p.CreateProject("project1"); err == nil {
+		t.Errorf("expected create project to require admin credentials")
+	}
+	if err := p.CreateTarget("project1", testAWSTarget("target1")); err == nil {
+		t.Errorf("expected create target to require admin credentials")
+	}
+	if _, err := p.ListTargets("project1"); err == nil {
+		t.Errorf("expected list targets to require admin credentials")
+	}
+	if _, err := p.GetProjectToken("project1"); err == nil {
+		t.Errorf("expected project tokens to require admin credentials")
+	}
+	if _, err := p.GetPolicyTemplate(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected policy templates not to be supported, got %v", err)
+	}
+	if err := p.PutRegistryPassword("ref1", "abcd1234"); err == nil {
+		t.Errorf("expected registry passwords to require admin credentials")
+	}
+	if _, err := p.GetRegistryPassword("ref1"); err == nil {
+		t.Errorf("expected registry passwords to require admin credentials")
+	}
+}
+
+func TestAWSRegistryPassword(t *testing.T) {
+	sm := newFakeSecretsManager()
+	p := newTestAWSProvider(sm, &fakeSTS{}, authorizationKeyAdmin, "")
+
+	if err := p.PutRegistryPassword("ref1", "abcd1234"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := sm.secrets["argo-cloudops-registries/ref1"]; !ok {
+		t.Errorf("expected registry password to be stored")
+	}
+	password, err := p.GetRegistryPassword("ref1")
+	if err != nil || password != "abcd1234" {
+		t.Errorf("expected registry password, got %v %v", password, err)
+	}
+	if _, err := p.GetRegistryPassword("ref2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	if err := p.DeleteRegistryPassword("ref1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.GetRegistryPassword("ref1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected registry password to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package credentials
+
+import (
+	"sync"
+	"time"
+)
+
+// serviceTokens are the tokens of the approle logins of the service, reused
+// by NewVaultSvc until half their TTL elapsed so requests don't each log in.
+// Tokens without a TTL aren't reused.
+var serviceTokens = &tokenCache{tokens: map[string]cachedToken{}, now: time.Now}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+	now    func() time.Time
+}
+
+func (c *tokenCache) get(address, role string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tokens[address+"|"+role]
+	if !ok || !c.now().Before(t.expires) {
+		return "", false
+	}
+	return t.token, true
+}
+
+func (c *tokenCache) put(address, role, token string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[address+"|"+role] = cachedToken{token: token, expires: c.now().Add(ttl / 2)}
+}
@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCache(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &tokenCache{tokens: map[string]cachedToken{}, now: func() time.Time { return now }}
+
+	c.put("addr", "role", "token", time.Hour)
+	c.put("addr", "other", "no-ttl", 0)
+
+	token, ok := c.get("addr", "role")
+	assert.True(t, ok)
+	assert.Equal(t, "token", token)
+
+	_, ok = c.get("addr", "other")
+	assert.False(t, ok)
+
+	now = now.Add(30 * time.Minute)
+	_, ok = c.get("addr", "role")
+	assert.False(t, ok)
+}
+
+func TestNewVaultSvcReusesServiceToken(t *testing.T) {
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logins++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": "s.service", "lease_duration": 3600},
+		})
+	}))
+	defer srv.Close()
+
+	config := NewVaultConfig(&vault.Config{Address: srv.URL}, "role", "secret")
+	config.reuseToken = true
+	for i := 0; i < 2; i++ {
+		svc, err := NewVaultSvc(*config, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "s.service", svc.Token())
+	}
+	assert.Equal(t, 1, logins)
+
+	config.reuseToken = false
+	_, err := NewVaultSvc(*config, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, logins)
+}
@@ -1,14 +1,17 @@
 package credentials
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/responses"
 	"github.com/cello-proj/cello/internal/types"
-	"github.com/cello-proj/cello/internal/validations"
+	"github.com/cello-proj/cello/service/internal/discovery"
 	"github.com/cello-proj/cello/service/internal/env"
 
 	vault "github.com/hashicorp/vault/api"
@@ -24,13 +27,31 @@ type Provider interface {
 	CreateTarget(string, types.Target) error
 	UpdateTarget(string, types.Target) error
 	DeleteProject(string) error
+	RenameProject(string, string) (string, string, error)
+	MoveTarget(string, string, string) error
 	DeleteTarget(string, string) error
 	GetProject(string) (responses.GetProject, error)
 	GetTarget(string, string) (types.Target, error)
 	GetToken() (string, error)
+	GetProjectToken(string) (string, error)
+	GetRunToken(bool) (RunToken, error)
+	RevokeToken(string) error
 	ListTargets(string) ([]string, error)
 	ProjectExists(string) (bool, error)
+	ProjectAuthorized(string) (bool, error)
+	Identity() (Identity, error)
 	TargetExists(string, string) (bool, error)
+	PutTargetHostCredentials(string, string, HostCredentials) error
+	DeleteTargetHostCredentials(string, string) error
+	PutRegistryPassword(string, string) error
+	GetRegistryPassword(string) (string, error)
+	DeleteRegistryPassword(string) error
+	GetTargetCredentials(string, string, time.Duration) (TargetCredentials, error)
+	RevokeLease(string) error
+	GetPolicyTemplate() (PolicyTemplate, error)
+	PutPolicyTemplate(string) error
+	DeletePolicyTemplate() error
+	GetPolicyUsage(string) (PolicyUsage, error)
 }
 
 type vaultLogical interface {
@@ -56,34 +77,92 @@ var (
 	ErrNotFound = errors.New("item not found")
 	// ErrTargetNotFound conveys that the target was not round.
 	ErrTargetNotFound = errors.New("target not found")
+	// ErrInvalidCredentials conveys that Vault doesn't accept the
+	// credentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrGCPNotEnabled conveys that gcp_project targets aren't enabled, see
+	// env.Vars.VaultGCPEnabled.
+	ErrGCPNotEnabled = errors.New("gcp_project targets are not enabled")
+	// ErrAzureNotEnabled conveys that azure_subscription targets aren't
+	// enabled, see env.Vars.VaultAzureEnabled.
+	ErrAzureNotEnabled = errors.New("azure_subscription targets are not enabled")
 )
 
+// RunToken is a token issued for a single workflow run.
+type RunToken struct {
+	// Token is the response wrapping token of the run token when wrapped.
+	Token string
+	// Accessor identifies the run token without granting its access, e.g.
+	// to revoke it.
+	Accessor string
+}
+
 type VaultProvider struct {
 	roleID          string
 	secretID        string
 	vaultLogicalSvc vaultLogical
 	vaultSysSvc     vaultSys
+	// policyMaxBytes is the max size of the Vault policies of projects,
+	// larger policies are sharded. 0 doesn't shard policies.
+	policyMaxBytes int
+	// gcpEnabled reads and writes gcp_project targets in the GCP secrets
+	// engine, which isn't mounted otherwise.
+	gcpEnabled bool
+	// azureEnabled reads and writes azure_subscription targets in the Azure
+	// secrets engine, which isn't mounted otherwise.
+	azureEnabled bool
 }
 
 // NewVaultProvider returns a new VaultProvider
 func NewVaultProvider(a Authorization, env env.Vars, h http.Header, vaultConfigFn VaultConfigFn, vaultSvcFn VaultSvcFn) (Provider, error) {
-	config := vaultConfigFn(&vault.Config{Address: env.VaultAddress}, env.VaultRole, env.VaultSecret)
+	config := vaultConfigFn(vaultClientConfig(env), env.VaultRole, env.VaultSecret)
+	config.reuseToken = env.VaultReuseServiceToken
 	svc, err := vaultSvcFn(*config, h)
 	if err != nil {
 		return nil, err
 	}
+	svc.SetWrappingLookupFunc(wrappingLookupFunc(env.VaultWrapTTL))
 	return &VaultProvider{
 		vaultLogicalSvc: vaultLogical(svc.Logical()),
 		vaultSysSvc:     vaultSys(svc.Sys()),
 		roleID:          a.Key,
 		secretID:        a.Secret,
+		policyMaxBytes:  env.VaultPolicyMaxBytes,
+		gcpEnabled:      env.VaultGCPEnabled,
+		azureEnabled:    env.VaultAzureEnabled,
 	}, nil
 }
 
+// vaultClientConfig returns the config of the Vault client of the env,
+// connecting to the resolved addresses of Vault with the outbound proxy and
+// CA bundle.
+func vaultClientConfig(env env.Vars) *vault.Config {
+	endpoint := discovery.For(env.VaultAddress)
+	return &vault.Config{
+		Address:    endpoint.URL(),
+		Timeout:    env.VaultTimeout,
+		HttpClient: &http.Client{Transport: endpoint.Transport()},
+	}
+}
+
+// wrappingLookupFunc returns the TTL of the response wrapping tokens of run
+// tokens, which have to last until the pods of workflows are scheduled and
+// unwrap them. Nothing else is wrapped.
+func wrappingLookupFunc(ttl time.Duration) vault.WrappingLookupFunc {
+	return func(operation, path string) string {
+		if (operation == http.MethodPut || operation == http.MethodPost) && path == "sys/wrapping/wrap" {
+			return ttl.String()
+		}
+		return ""
+	}
+}
+
 type VaultConfig struct {
 	config *vault.Config
 	role   string
 	secret string
+	// reuseToken reuses the token of the approle login, see serviceTokens.
+	reuseToken bool
 }
 
 type VaultConfigFn func(config *vault.Config, role, secret string) *VaultConfig
@@ -110,6 +189,13 @@ func NewVaultSvc(c VaultConfig, h http.Header) (*vault.Client, error) {
 
 	vaultSvc.SetHeaders(h)
 
+	if c.reuseToken {
+		if token, ok := serviceTokens.get(c.config.Address, c.role); ok {
+			vaultSvc.SetToken(token)
+			return vaultSvc, nil
+		}
+	}
+
 	options := map[string]interface{}{
 		"role_id":   c.role,
 		"secret_id": c.secret,
@@ -120,66 +206,21 @@ func NewVaultSvc(c VaultConfig, h http.Header) (*vault.Client, error) {
 		return nil, err
 	}
 
+	if c.reuseToken {
+		serviceTokens.put(c.config.Address, c.role, sec.Auth.ClientToken, time.Duration(sec.Auth.LeaseDuration)*time.Second)
+	}
 	vaultSvc.SetToken(sec.Auth.ClientToken)
 	return vaultSvc, nil
 }
 
-// Authorization represents a user's authorization token.
-type Authorization struct {
-	Provider string `valid:"required"`
-	Key      string `valid:"required"`
-	Secret   string `valid:"required"`
-}
-
-func (a Authorization) Validate(optionalValidations ...func() error) error {
-	v := []func() error{
-		func() error {
-			if a.Provider != "vault" {
-				return errors.New("provider must be vault")
-			}
-			return nil
-		},
-		func() error { return validations.ValidateStruct(a) },
-	}
-
-	v = append(v, optionalValidations...)
-
-	return validations.Validate(v...)
-}
-
-// ValidateAuthorizedAdmin determines if the Authorization is valid and an admin.
-// TODO See if this can be removed when refactoring auth.
-// Optional validation should be passed as parameter to Validate().
-func (a Authorization) ValidateAuthorizedAdmin(adminSecret string) func() error {
-	return func() error {
-		if a.Key != "admin" {
-			return fmt.Errorf("must be an authorized admin")
-		}
-
-		if a.Secret != adminSecret {
-			return fmt.Errorf("must be an authorized admin, invalid admin secret")
-		}
-
-		return nil
-	}
-}
-
-// NewAuthorization provides an Authorization from a header.
-// This is separate from admin functions which use the admin env var
-func NewAuthorization(authorizationHeader string) (*Authorization, error) {
-	var a Authorization
-	auth := strings.SplitN(authorizationHeader, ":", 3)
-	if len(auth) < 3 {
-		return nil, fmt.Errorf("invalid authorization header")
-	}
-	a.Provider = auth[0]
-	a.Key = auth[1]
-	a.Secret = auth[2]
-	return &a, nil
-}
-
-func (v VaultProvider) createPolicyState(name, policy string) error {
-	return v.vaultSysSvc.PutPolicy(fmt.Sprintf("%s-%s", vaultProjectPrefix, name), policy)
+// PrefetchServiceToken logs into Vault with the approle of the service, so
+// the first request reuses the token when reusing it is enabled. It fails
+// when Vault is unreachable or the approle is misconfigured.
+func PrefetchServiceToken(env env.Vars) error {
+	config := NewVaultConfig(vaultClientConfig(env), env.VaultRole, env.VaultSecret)
+	config.reuseToken = env.VaultReuseServiceToken
+	_, err := NewVaultSvc(*config, nil)
+	return err
 }
 
 func genProjectAppRole(name string) string {
@@ -191,13 +232,12 @@ func (v VaultProvider) CreateProject(name string) (string, string, error) {
 		return "", "", errors.New("admin credentials must be used to create project")
 	}
 
-	policy := defaultVaultReadonlyPolicyAWS(name)
-	err := v.createPolicyState(name, policy)
+	usage, err := v.writeProjectPolicies(name, nil)
 	if err != nil {
 		return "", "", err
 	}
 
-	if err := v.writeProjectState(name); err != nil {
+	if err := v.writeProjectState(name, usage.Policies); err != nil {
 		return "", "", err
 	}
 
@@ -214,7 +254,8 @@ func (v VaultProvider) CreateProject(name string) (string, string, error) {
 	return roleID, secretID, nil
 }
 
-// CreateTarget creates a target for the project.
+// CreateTarget creates a target for the project, then writes the policy of
+// the project again as its template can have paths per target.
 // TODO validate policy and other information is correct in target
 // TODO Validate role exists (if possible, etc)
 func (v VaultProvider) CreateTarget(projectName string, target types.Target) error {
@@ -222,27 +263,112 @@ func (v VaultProvider) CreateTarget(projectName string, target types.Target) err
 		return errors.New("admin credentials must be used to create target")
 	}
 
+	if err := v.writeTarget(projectName, target); err != nil {
+		return err
+	}
+
+	_, err := v.syncProjectPolicies(projectName)
+	return err
+}
+
+// genTargetPath returns the path of the target in the secrets engine of the
+// target type, the AWS role, the GCP impersonated account or the Azure role.
+func genTargetPath(targetType, projectName, targetName string) string {
+	switch targetType {
+	case types.TargetTypeAzureSubscription:
+		return fmt.Sprintf("azure/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
+	case types.TargetTypeGCPProject:
+		return fmt.Sprintf("gcp/impersonated-account/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
+	}
+	return fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
+}
+
+// genTargetListPath returns the path the targets of the target type are
+// listed from.
+func genTargetListPath(targetType string) string {
+	switch targetType {
+	case types.TargetTypeAzureSubscription:
+		return "azure/roles/"
+	case types.TargetTypeGCPProject:
+		return "gcp/impersonated-accounts/"
+	}
+	return "aws/roles/"
+}
+
+// genTargetAzurePath returns the path of the tenant and subscription of an
+// azure_subscription target in the KV (version 2) secrets engine, kind is
+// data or metadata. Roles of the Azure secrets engine don't store them.
+func genTargetAzurePath(kind, projectName, targetName string) string {
+	return fmt.Sprintf("secret/%s/%s-%s-target-%s-azure", kind, vaultProjectPrefix, projectName, targetName)
+}
+
+// targetTypes returns the types of the targets read and written, the ones of
+// the secrets engines enabled.
+func (v VaultProvider) targetTypes() []string {
+	targetTypes := []string{types.TargetTypeAWSAccount}
+	if v.gcpEnabled {
+		targetTypes = append(targetTypes, types.TargetTypeGCPProject)
+	}
+	if v.azureEnabled {
+		targetTypes = append(targetTypes, types.TargetTypeAzureSubscription)
+	}
+	return targetTypes
+}
+
+// writeTarget writes the AWS role of an aws_account target, the GCP
+// impersonated account of a gcp_project target or the Azure role of an
+// azure_subscription target with its tenant and subscription.
+func (v VaultProvider) writeTarget(projectName string, target types.Target) error {
 	options := map[string]interface{}{
 		"credential_type": target.Properties.CredentialType,
 		"policy_arns":     target.Properties.PolicyArns,
 		"policy_document": target.Properties.PolicyDocument,
 		"role_arns":       target.Properties.RoleArn,
 	}
+	switch target.Type {
+	case types.TargetTypeAzureSubscription:
+		if !v.azureEnabled {
+			return ErrAzureNotEnabled
+		}
+		roles, err := json.Marshal(target.Properties.RoleAssignments)
+		if err != nil {
+			return err
+		}
+		if _, err := v.vaultLogicalSvc.Write(genTargetAzurePath("data", projectName, target.Name), map[string]interface{}{
+			"data": map[string]interface{}{
+				"tenant_id":       target.Properties.TenantID,
+				"subscription_id": target.Properties.SubscriptionID,
+			},
+		}); err != nil {
+			return err
+		}
+		// The Azure secrets engine takes the roles as a JSON string.
+		options = map[string]interface{}{"azure_roles": string(roles)}
+	case types.TargetTypeGCPProject:
+		if !v.gcpEnabled {
+			return ErrGCPNotEnabled
+		}
+		options = map[string]interface{}{
+			"service_account_email": target.Properties.ServiceAccountEmail,
+			"token_scopes":          target.Properties.TokenScopes,
+		}
+	}
 
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, target.Name)
-	_, err := v.vaultLogicalSvc.Write(path, options)
+	_, err := v.vaultLogicalSvc.Write(genTargetPath(target.Type, projectName, target.Name), options)
 	return err
 }
 
-func defaultVaultReadonlyPolicyAWS(projectName string) string {
-	return fmt.Sprintf(
-		"path \"aws/sts/argo-cloudops-projects-%s-target-*\" { capabilities = [\"read\"] }",
-		projectName,
-	)
-}
-
-func (v VaultProvider) deletePolicyState(name string) error {
-	return v.vaultSysSvc.DeletePolicy(fmt.Sprintf("%s-%s", vaultProjectPrefix, name))
+// readTarget reads the secrets engine entry of the target and its type, the
+// AWS role or else the GCP impersonated account or the Azure role, of the
+// engines enabled. The secret is nil when the target doesn't exist.
+func (v VaultProvider) readTarget(projectName, targetName string) (*vault.Secret, string, error) {
+	for _, targetType := range v.targetTypes() {
+		sec, err := v.vaultLogicalSvc.Read(genTargetPath(targetType, projectName, targetName))
+		if err != nil || sec != nil {
+			return sec, targetType, err
+		}
+	}
+	return nil, types.TargetTypeAWSAccount, nil
 }
 
 func (v VaultProvider) DeleteProject(name string) error {
@@ -250,10 +376,15 @@ func (v VaultProvider) DeleteProject(name string) error {
 		return errors.New("admin credentials must be used to delete project")
 	}
 
-	err := v.deletePolicyState(name)
+	policies, err := v.projectPolicies(name)
 	if err != nil {
 		return fmt.Errorf("vault delete project error: %w", err)
 	}
+	for _, policy := range policies {
+		if err := v.vaultSysSvc.DeletePolicy(policy); err != nil {
+			return fmt.Errorf("vault delete project error: %w", err)
+		}
+	}
 
 	if _, err = v.vaultLogicalSvc.Delete(genProjectAppRole(name)); err != nil {
 		return fmt.Errorf("vault delete project error: %w", err)
@@ -261,16 +392,157 @@ func (v VaultProvider) DeleteProject(name string) error {
 	return nil
 }
 
+// RenameProject moves the targets of the project, with their host
+// credentials, to the new name, then creates the approle and policies of the
+// new name and deletes the ones of the former name. The approle of the new
+// name has new credentials, which are returned like for CreateProject.
+func (v VaultProvider) RenameProject(from, to string) (string, string, error) {
+	if !v.isAdmin() {
+		return "", "", errors.New("admin credentials must be used to rename project")
+	}
+
+	targets, err := v.ListTargets(from)
+	if err != nil {
+		return "", "", err
+	}
+	for _, target := range targets {
+		if err := v.renameTarget(from, to, target); err != nil {
+			return "", "", fmt.Errorf("vault rename target %s error: %w", target, err)
+		}
+	}
+
+	former, err := v.projectPolicies(from)
+	if err != nil {
+		return "", "", err
+	}
+
+	usage, err := v.writeProjectPolicies(to, targets)
+	if err != nil {
+		return "", "", err
+	}
+	if err := v.writeProjectState(to, usage.Policies); err != nil {
+		return "", "", err
+	}
+
+	secretID, err := v.readSecretID(to)
+	if err != nil {
+		return "", "", err
+	}
+	roleID, err := v.readRoleID(to)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, policy := range former {
+		if err := v.vaultSysSvc.DeletePolicy(policy); err != nil {
+			return "", "", fmt.Errorf("vault delete project error: %w", err)
+		}
+	}
+	if _, err := v.vaultLogicalSvc.Delete(genProjectAppRole(from)); err != nil {
+		return "", "", fmt.Errorf("vault delete project error: %w", err)
+	}
+	return roleID, secretID, nil
+}
+
+// MoveTarget moves a target of the project, with its host credentials, to
+// another project, then writes the policies of both projects again, so only
+// the other project can read its credentials.
+func (v VaultProvider) MoveTarget(from, to, targetName string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to move target")
+	}
+
+	if err := v.renameTarget(from, to, targetName); err != nil {
+		return err
+	}
+	if _, err := v.syncProjectPolicies(to); err != nil {
+		return err
+	}
+	_, err := v.syncProjectPolicies(from)
+	return err
+}
+
+// renameTarget copies the AWS role, GCP impersonated account or Azure role and
+// the host credentials of the target to another project, e.g. the new name of
+// the project, then deletes the ones of the project.
+func (v VaultProvider) renameTarget(from, to, targetName string) error {
+	sec, targetType, err := v.readTarget(from, targetName)
+	if err != nil {
+		return err
+	}
+	if sec == nil {
+		return ErrTargetNotFound
+	}
+	if targetType == types.TargetTypeAzureSubscription {
+		// Azure roles are read with their roles as objects but written as a
+		// JSON string.
+		target, err := v.azureTarget(from, targetName, sec)
+		if err != nil {
+			return err
+		}
+		if err := v.writeTarget(to, target); err != nil {
+			return err
+		}
+		if _, err := v.vaultLogicalSvc.Delete(genTargetAzurePath("metadata", from, targetName)); err != nil {
+			return err
+		}
+	} else if _, err := v.vaultLogicalSvc.Write(genTargetPath(targetType, to, targetName), sec.Data); err != nil {
+		return err
+	}
+
+	host, err := v.vaultLogicalSvc.Read(genTargetHostCredentialsPath("data", from, targetName))
+	if err != nil {
+		return err
+	}
+	if data, ok := hostCredentialsData(host); ok {
+		if _, err := v.vaultLogicalSvc.Write(genTargetHostCredentialsPath("data", to, targetName), map[string]interface{}{"data": data}); err != nil {
+			return err
+		}
+		if _, err := v.vaultLogicalSvc.Delete(genTargetHostCredentialsPath("metadata", from, targetName)); err != nil {
+			return err
+		}
+	}
+
+	_, err = v.vaultLogicalSvc.Delete(genTargetPath(targetType, from, targetName))
+	return err
+}
+
+// hostCredentialsData returns the data of the latest version of host
+// credentials read from the KV secrets engine, and false when there's none.
+func hostCredentialsData(sec *vault.Secret) (map[string]interface{}, bool) {
+	if sec == nil {
+		return nil, false
+	}
+	data, ok := sec.Data["data"].(map[string]interface{})
+	return data, ok && len(data) > 0
+}
+
+// DeleteTarget deletes a target of the project, then writes the policy of the
+// project again without it.
 func (v VaultProvider) DeleteTarget(projectName string, targetName string) error {
 	if !v.isAdmin() {
 		return errors.New("admin credentials must be used to delete target")
 	}
 
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
-	_, err := v.vaultLogicalSvc.Delete(path)
+	for _, targetType := range v.targetTypes() {
+		if _, err := v.vaultLogicalSvc.Delete(genTargetPath(targetType, projectName, targetName)); err != nil {
+			return err
+		}
+	}
+	if v.azureEnabled {
+		if _, err := v.vaultLogicalSvc.Delete(genTargetAzurePath("metadata", projectName, targetName)); err != nil {
+			return err
+		}
+	}
+
+	_, err := v.syncProjectPolicies(projectName)
 	return err
 }
 
+// TokenMaxTTL is the max TTL of project and run tokens, matching
+// vaultTokenMaxTTL.
+const TokenMaxTTL = 10 * time.Minute
+
 const (
 	vaultSecretTTL   = "8776h" // 1 year
 	vaultTokenMaxTTL = "10m"
@@ -296,7 +568,7 @@ func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target,
 		return types.Target{}, errors.New("admin credentials must be used to get target information")
 	}
 
-	sec, err := v.vaultLogicalSvc.Read(fmt.Sprintf("aws/roles/argo-cloudops-projects-%s-target-%s", projectName, targetName))
+	sec, targetType, err := v.readTarget(projectName, targetName)
 	if err != nil {
 		return types.Target{}, fmt.Errorf("vault get target error: %w", err)
 	}
@@ -305,6 +577,17 @@ func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target,
 		return types.Target{}, ErrTargetNotFound
 	}
 
+	switch targetType {
+	case types.TargetTypeAzureSubscription:
+		target, err := v.azureTarget(projectName, targetName, sec)
+		if err != nil {
+			return types.Target{}, fmt.Errorf("vault get target error: %w", err)
+		}
+		return target, nil
+	case types.TargetTypeGCPProject:
+		return gcpTarget(targetName, sec), nil
+	}
+
 	// These should always exist.
 	roleArn := sec.Data["role_arns"].([]interface{})[0].(string)
 	credentialType := sec.Data["credential_type"].(string)
@@ -325,8 +608,7 @@ func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target,
 
 	return types.Target{
 		Name: targetName,
-		// target 'Type' always 'aws_account', currently not stored in Vault
-		Type: "aws_account",
+		Type: types.TargetTypeAWSAccount,
 		Properties: types.TargetProperties{
 			CredentialType: credentialType,
 			PolicyArns:     policies,
@@ -336,6 +618,64 @@ func (v VaultProvider) GetTarget(projectName, targetName string) (types.Target,
 	}, nil
 }
 
+// gcpTarget returns the gcp_project target of the GCP impersonated account.
+// The credential type isn't stored, it's always impersonated_account.
+func gcpTarget(targetName string, sec *vault.Secret) types.Target {
+	target := types.Target{
+		Name: targetName,
+		Type: types.TargetTypeGCPProject,
+		Properties: types.TargetProperties{
+			CredentialType: "impersonated_account",
+			PolicyArns:     []string{},
+		},
+	}
+	target.Properties.ServiceAccountEmail, _ = sec.Data["service_account_email"].(string)
+	scopes, _ := sec.Data["token_scopes"].([]interface{})
+	for _, scope := range scopes {
+		target.Properties.TokenScopes = append(target.Properties.TokenScopes, scope.(string))
+	}
+	return target
+}
+
+// azureTarget returns the azure_subscription target of the Azure role, with
+// the tenant and subscription read from the KV secrets engine. The credential
+// type isn't stored, it's always service_principal.
+func (v VaultProvider) azureTarget(projectName, targetName string, sec *vault.Secret) (types.Target, error) {
+	target := types.Target{
+		Name: targetName,
+		Type: types.TargetTypeAzureSubscription,
+		Properties: types.TargetProperties{
+			CredentialType: "service_principal",
+			PolicyArns:     []string{},
+		},
+	}
+
+	// Vault returns the roles as objects, also accept the JSON string they're
+	// written as.
+	roles, ok := sec.Data["azure_roles"].(string)
+	if !ok {
+		data, err := json.Marshal(sec.Data["azure_roles"])
+		if err != nil {
+			return types.Target{}, err
+		}
+		roles = string(data)
+	}
+	if err := json.Unmarshal([]byte(roles), &target.Properties.RoleAssignments); err != nil {
+		return types.Target{}, fmt.Errorf("invalid azure roles: %w", err)
+	}
+
+	kv, err := v.vaultLogicalSvc.Read(genTargetAzurePath("data", projectName, targetName))
+	if err != nil {
+		return types.Target{}, err
+	}
+	if kv != nil {
+		data, _ := kv.Data["data"].(map[string]interface{})
+		target.Properties.TenantID, _ = data["tenant_id"].(string)
+		target.Properties.SubscriptionID, _ = data["subscription_id"].(string)
+	}
+	return target, nil
+}
+
 func (v VaultProvider) GetToken() (string, error) {
 	if v.isAdmin() {
 		return "", errors.New("admin credentials cannot be used to get tokens")
@@ -355,6 +695,85 @@ func (v VaultProvider) GetToken() (string, error) {
 	return sec.Auth.ClientToken, nil
 }
 
+// GetRunToken gets a new token for a single workflow run. When wrap is true
+// the token is response wrapped, so it can only be unwrapped once and within
+// the wrap TTL (VAULT_WRAP_TTL).
+func (v VaultProvider) GetRunToken(wrap bool) (RunToken, error) {
+	if v.isAdmin() {
+		return RunToken{}, errors.New("admin credentials cannot be used to get tokens")
+	}
+
+	sec, err := v.vaultLogicalSvc.Write("auth/approle/login", map[string]interface{}{
+		"role_id":   v.roleID,
+		"secret_id": v.secretID,
+	})
+	if err != nil {
+		return RunToken{}, err
+	}
+
+	rt := RunToken{Token: sec.Auth.ClientToken, Accessor: sec.Auth.Accessor}
+	if !wrap {
+		return rt, nil
+	}
+
+	wrapped, err := v.vaultLogicalSvc.Write("sys/wrapping/wrap", map[string]interface{}{
+		"token": rt.Token,
+	})
+	if err != nil {
+		return RunToken{}, fmt.Errorf("failed to wrap token: %w", err)
+	}
+	if wrapped == nil || wrapped.WrapInfo == nil {
+		return RunToken{}, errors.New("failed to wrap token: no wrapping token returned")
+	}
+	rt.Token = wrapped.WrapInfo.Token
+	return rt, nil
+}
+
+// RevokeToken revokes the token of the accessor and the leases it created,
+// e.g. once the workflow run it was issued for completes.
+func (v VaultProvider) RevokeToken(accessor string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to revoke tokens")
+	}
+
+	_, err := v.vaultLogicalSvc.Write("auth/token/revoke-accessor", map[string]interface{}{
+		"accessor": accessor,
+	})
+	return err
+}
+
+// GetProjectToken gets a token of the project using admin credentials, e.g.
+// for workflows submitted on behalf of the project. The secret ID used to get
+// it can only be used once.
+func (v VaultProvider) GetProjectToken(projectName string) (string, error) {
+	if !v.isAdmin() {
+		return "", errors.New("admin credentials must be used to get project tokens")
+	}
+
+	roleID, err := v.readRoleID(projectName)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := v.vaultLogicalSvc.Write(fmt.Sprintf("%s/secret-id", genProjectAppRole(projectName)), map[string]interface{}{
+		"num_uses": 1,
+		"ttl":      vaultTokenMaxTTL,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sec, err := v.vaultLogicalSvc.Write("auth/approle/login", map[string]interface{}{
+		"role_id":   roleID,
+		"secret_id": secret.Data["secret_id"],
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return sec.Auth.ClientToken, nil
+}
+
 // TODO See if this can be removed when refactoring auth.
 func (v VaultProvider) isAdmin() bool {
 	return v.roleID == authorizationKeyAdmin
@@ -365,19 +784,22 @@ func (v VaultProvider) ListTargets(project string) ([]string, error) {
 		return nil, errors.New("admin credentials must be used to list targets")
 	}
 
-	sec, err := v.vaultLogicalSvc.List("aws/roles/")
-	if err != nil {
-		return nil, fmt.Errorf("vault list error: %w", err)
-	}
-
 	// allow empty array to render json as []
 	list := make([]string, 0)
-	if sec != nil {
-		for _, target := range sec.Data["keys"].([]interface{}) {
-			value := target.(string)
-			prefix := fmt.Sprintf("argo-cloudops-projects-%s-target-", project)
-			if strings.HasPrefix(value, prefix) {
-				list = append(list, strings.Replace(value, prefix, "", 1))
+	for _, targetType := range v.targetTypes() {
+		sec, err := v.vaultLogicalSvc.List(genTargetListPath(targetType))
+		if err != nil {
+			return nil, fmt.Errorf("vault list error: %w", err)
+		}
+
+		if sec != nil {
+			keys, _ := sec.Data["keys"].([]interface{})
+			for _, target := range keys {
+				value := target.(string)
+				prefix := fmt.Sprintf("argo-cloudops-projects-%s-target-", project)
+				if strings.HasPrefix(value, prefix) {
+					list = append(list, strings.Replace(value, prefix, "", 1))
+				}
 			}
 		}
 	}
@@ -398,6 +820,107 @@ func (v VaultProvider) ProjectExists(name string) (bool, error) {
 	return p.Name != "", nil
 }
 
+// ProjectAuthorized reports whether the credentials of the provider are the
+// ones of the project, i.e. its role ID with a valid secret ID. It's false for
+// admin credentials and projects that don't exist.
+func (v VaultProvider) ProjectAuthorized(projectName string) (bool, error) {
+	if v.isAdmin() {
+		return false, nil
+	}
+
+	sec, err := v.vaultLogicalSvc.Read(fmt.Sprintf("%s/role-id", genProjectAppRole(projectName)))
+	if err != nil {
+		return false, fmt.Errorf("vault read role id error: %w", err)
+	}
+	if sec == nil || sec.Data["role_id"] != v.roleID {
+		return false, nil
+	}
+
+	sec, err = v.vaultLogicalSvc.Write(fmt.Sprintf("%s/secret-id/lookup", genProjectAppRole(projectName)), map[string]interface{}{
+		"secret_id": v.secretID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("vault lookup secret id error: %w", err)
+	}
+	return sec != nil, nil
+}
+
+// Identity is who the credentials of a provider are.
+type Identity struct {
+	Admin bool
+	// Project is the project of project credentials.
+	Project string
+	// ExpiresAt is when the secret ID of project credentials expires, zero
+	// when it doesn't.
+	ExpiresAt time.Time
+	// UsesRemaining is how many more times the secret ID of project
+	// credentials can be used, 0 when unlimited.
+	UsesRemaining int
+}
+
+// Identity returns who the credentials of the provider are. Project
+// credentials log into Vault to read the approle of the project, using the
+// secret ID once, then the secret ID is looked up for its expiry. Returns ErrInvalidCredentials when
+// Vault doesn't accept the credentials.
+func (v VaultProvider) Identity() (Identity, error) {
+	if v.isAdmin() {
+		return Identity{Admin: true}, nil
+	}
+
+	sec, err := v.vaultLogicalSvc.Write("auth/approle/login", map[string]interface{}{
+		"role_id":   v.roleID,
+		"secret_id": v.secretID,
+	})
+	var re *vault.ResponseError
+	if errors.As(err, &re) && (re.StatusCode == http.StatusBadRequest || re.StatusCode == http.StatusForbidden) {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Identity{}, fmt.Errorf("vault login error: %w", err)
+	}
+	if sec == nil || sec.Auth == nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	// Only project approles are named with the prefix.
+	roleName := sec.Auth.Metadata["role_name"]
+	prefix := vaultProjectPrefix + "-"
+	if !strings.HasPrefix(roleName, prefix) || roleName == prefix {
+		return Identity{}, ErrInvalidCredentials
+	}
+	identity := Identity{Project: strings.TrimPrefix(roleName, prefix)}
+
+	// The token of the login isn't used.
+	if _, err := v.vaultLogicalSvc.Write("auth/token/revoke-accessor", map[string]interface{}{
+		"accessor": sec.Auth.Accessor,
+	}); err != nil {
+		return Identity{}, fmt.Errorf("vault revoke token error: %w", err)
+	}
+
+	sec, err = v.vaultLogicalSvc.Write(fmt.Sprintf("%s/secret-id/lookup", genProjectAppRole(identity.Project)), map[string]interface{}{
+		"secret_id": v.secretID,
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("vault lookup secret id error: %w", err)
+	}
+	if sec == nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	// Secret IDs without a TTL expire at the zero time.
+	if s, ok := sec.Data["expiration_time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil && !t.IsZero() {
+			identity.ExpiresAt = t
+		}
+	}
+	if n, ok := sec.Data["secret_id_num_uses"]; ok {
+		if uses, err := strconv.Atoi(fmt.Sprint(n)); err == nil {
+			identity.UsesRemaining = uses
+		}
+	}
+	return identity, nil
+}
+
 func (v VaultProvider) readRoleID(appRoleName string) (string, error) {
 	secret, err := v.vaultLogicalSvc.Read(fmt.Sprintf("%s/role-id", genProjectAppRole(appRoleName)))
 	if err != nil {
@@ -428,25 +951,16 @@ func (v VaultProvider) UpdateTarget(projectName string, target types.Target) err
 		return errors.New("admin credentials must be used to update target")
 	}
 
-	options := map[string]interface{}{
-		"credential_type": target.Properties.CredentialType,
-		"policy_arns":     target.Properties.PolicyArns,
-		"policy_document": target.Properties.PolicyDocument,
-		"role_arns":       target.Properties.RoleArn,
-	}
-
-	path := fmt.Sprintf("aws/roles/%s-%s-target-%s", vaultProjectPrefix, projectName, target.Name)
-	_, err := v.vaultLogicalSvc.Write(path, options)
-	return err
+	return v.writeTarget(projectName, target)
 }
 
-func (v VaultProvider) writeProjectState(name string) error {
+func (v VaultProvider) writeProjectState(name string, policies []string) error {
 	options := map[string]interface{}{
 		"secret_id_ttl":           vaultSecretTTL,
 		"token_max_ttl":           vaultTokenMaxTTL,
 		"token_no_default_policy": "true",
 		"token_num_uses":          vaultTokenNumUses,
-		"token_policies":          fmt.Sprintf("%s-%s", vaultProjectPrefix, name),
+		"token_policies":          strings.Join(policies, ","),
 	}
 
 	_, err := v.vaultLogicalSvc.Write(genProjectAppRole(name), options)
@@ -455,3 +969,157 @@ func (v VaultProvider) writeProjectState(name string) error {
 	}
 	return nil
 }
+
+// HostCredentials are the credentials of the hosts of a target, e.g. for
+// ansible. Either the SSH private key or the WinRM password is set.
+type HostCredentials struct {
+	User       string
+	PrivateKey string
+	Password   string
+}
+
+// genTargetHostCredentialsPath returns the path of the host credentials in the
+// KV (version 2) secrets engine, kind is data or metadata.
+func genTargetHostCredentialsPath(kind, projectName, targetName string) string {
+	return fmt.Sprintf("secret/%s/%s-%s-target-%s-host", kind, vaultProjectPrefix, projectName, targetName)
+}
+
+// PutTargetHostCredentials stores the host credentials of a target in the KV
+// secrets engine, where workflows of the project can read them. The project
+// policy is written again, so projects created before host credentials
+// existed can read them.
+func (v VaultProvider) PutTargetHostCredentials(projectName, targetName string, c HostCredentials) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to put target host credentials")
+	}
+
+	if _, err := v.syncProjectPolicies(projectName); err != nil {
+		return err
+	}
+
+	_, err := v.vaultLogicalSvc.Write(genTargetHostCredentialsPath("data", projectName, targetName), map[string]interface{}{
+		"data": map[string]interface{}{
+			"user":        c.User,
+			"private_key": c.PrivateKey,
+			"password":    c.Password,
+		},
+	})
+	return err
+}
+
+// DeleteTargetHostCredentials deletes the host credentials of a target, all
+// of their versions.
+func (v VaultProvider) DeleteTargetHostCredentials(projectName, targetName string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to delete target host credentials")
+	}
+
+	_, err := v.vaultLogicalSvc.Delete(genTargetHostCredentialsPath("metadata", projectName, targetName))
+	return err
+}
+
+// genRegistryPasswordPath returns the path of the password of registry
+// credentials in the KV (version 2) secrets engine, kind is data or metadata.
+// It's outside of the paths of the projects so workflows can't read it.
+func genRegistryPasswordPath(kind, ref string) string {
+	return fmt.Sprintf("secret/%s/argo-cloudops-registries-%s", kind, ref)
+}
+
+// PutRegistryPassword stores the password of registry credentials in the KV
+// secrets engine under the reference kept with the credentials.
+func (v VaultProvider) PutRegistryPassword(ref, password string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to put registry password")
+	}
+
+	_, err := v.vaultLogicalSvc.Write(genRegistryPasswordPath("data", ref), map[string]interface{}{
+		"data": map[string]interface{}{
+			"password": password,
+		},
+	})
+	return err
+}
+
+// GetRegistryPassword returns the password of registry credentials,
+// ErrNotFound when there's none for the reference.
+func (v VaultProvider) GetRegistryPassword(ref string) (string, error) {
+	if !v.isAdmin() {
+		return "", errors.New("admin credentials must be used to get registry password")
+	}
+
+	sec, err := v.vaultLogicalSvc.Read(genRegistryPasswordPath("data", ref))
+	if err != nil {
+		return "", err
+	}
+	if sec != nil {
+		if data, ok := sec.Data["data"].(map[string]interface{}); ok {
+			if password, ok := data["password"].(string); ok {
+				return password, nil
+			}
+		}
+	}
+	return "", ErrNotFound
+}
+
+// DeleteRegistryPassword deletes the password of registry credentials, all
+// of its versions.
+func (v VaultProvider) DeleteRegistryPassword(ref string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to delete registry password")
+	}
+
+	_, err := v.vaultLogicalSvc.Delete(genRegistryPasswordPath("metadata", ref))
+	return err
+}
+
+// TargetCredentials are AWS credentials of a target issued outside of a
+// workflow run, e.g. for break-glass access, until the lease expires.
+type TargetCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// LeaseID identifies the lease of the credentials, e.g. to revoke it.
+	LeaseID  string
+	LeaseTTL time.Duration
+}
+
+// GetTargetCredentials issues credentials of the target valid for the TTL
+// from the AWS secrets engine.
+func (v VaultProvider) GetTargetCredentials(projectName, targetName string, ttl time.Duration) (TargetCredentials, error) {
+	if !v.isAdmin() {
+		return TargetCredentials{}, errors.New("admin credentials must be used to get target credentials")
+	}
+
+	path := fmt.Sprintf("aws/sts/%s-%s-target-%s", vaultProjectPrefix, projectName, targetName)
+	sec, err := v.vaultLogicalSvc.Write(path, map[string]interface{}{
+		"ttl": ttl.String(),
+	})
+	if err != nil {
+		return TargetCredentials{}, err
+	}
+	if sec == nil {
+		return TargetCredentials{}, ErrTargetNotFound
+	}
+
+	c := TargetCredentials{
+		LeaseID:  sec.LeaseID,
+		LeaseTTL: time.Duration(sec.LeaseDuration) * time.Second,
+	}
+	c.AccessKeyID, _ = sec.Data["access_key"].(string)
+	c.SecretAccessKey, _ = sec.Data["secret_key"].(string)
+	c.SessionToken, _ = sec.Data["security_token"].(string)
+	return c, nil
+}
+
+// RevokeLease revokes the lease of credentials issued by Vault. AWS STS
+// credentials stay valid until they expire.
+func (v VaultProvider) RevokeLease(leaseID string) error {
+	if !v.isAdmin() {
+		return errors.New("admin credentials must be used to revoke leases")
+	}
+
+	_, err := v.vaultLogicalSvc.Write("sys/leases/revoke", map[string]interface{}{
+		"lease_id": leaseID,
+	})
+	return err
+}
@@ -1,8 +1,12 @@
 package credentials
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/cello-proj/cello/internal/types"
 
@@ -111,6 +115,7 @@ func TestVaultCreateTarget(t *testing.T) {
 			v := VaultProvider{
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
+				vaultSysSvc:     &mockVaultSys{},
 			}
 
 			err := v.CreateTarget("test", types.Target{})
@@ -235,6 +240,191 @@ func TestVaultDeleteProject(t *testing.T) {
 	}
 }
 
+func TestVaultRenameProject(t *testing.T) {
+	tests := []struct {
+		name           string
+		admin          bool
+		hostData       map[string]interface{}
+		vaultErr       error
+		vaultPolicyErr error
+		wantWrites     []string
+		wantDeleted    []string
+		errResult      bool
+	}{
+		{
+			name:  "rename project success",
+			admin: true,
+			wantWrites: []string{
+				"aws/roles/argo-cloudops-projects-project2-target-target1",
+				"auth/approle/role/argo-cloudops-projects-project2",
+				"auth/approle/role/argo-cloudops-projects-project2/secret-id",
+			},
+			wantDeleted: []string{"argo-cloudops-projects-project1"},
+		},
+		{
+			name:     "rename project with host credentials",
+			admin:    true,
+			hostData: map[string]interface{}{"user": "ansible"},
+			wantWrites: []string{
+				"aws/roles/argo-cloudops-projects-project2-target-target1",
+				"secret/data/argo-cloudops-projects-project2-target-target1-host",
+				"auth/approle/role/argo-cloudops-projects-project2",
+				"auth/approle/role/argo-cloudops-projects-project2/secret-id",
+			},
+			wantDeleted: []string{"argo-cloudops-projects-project1"},
+		},
+		{
+			name:      "rename project admin error",
+			admin:     false,
+			errResult: true,
+		},
+		{
+			name:      "rename project error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+		{
+			name:           "rename project policy error",
+			admin:          true,
+			vaultPolicyErr: errTest,
+			errResult:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var writes, deleted []string
+			v := VaultProvider{
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, paths: &writes, data: map[string]interface{}{
+					"keys":            []interface{}{"argo-cloudops-projects-project1-target-target1"},
+					"credential_type": "assumed_role",
+					"role_arns":       []interface{}{"arn:aws:iam::012345678901:role/test-role"},
+					"data":            tt.hostData,
+					"role_id":         "roleID",
+					"secret_id":       "secretID",
+					"token_policies":  []interface{}{"argo-cloudops-projects-project1"},
+				}},
+				vaultSysSvc: &mockVaultSys{err: tt.vaultPolicyErr, deleted: &deleted},
+			}
+
+			roleID, secretID, err := v.RenameProject("project1", "project2")
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+				return
+			}
+			if tt.errResult {
+				t.Fatalf("\nexpected error")
+			}
+			if roleID != "roleID" || secretID != "secretID" {
+				t.Errorf("\nwant: roleID secretID\n got: %s %s", roleID, secretID)
+			}
+			if !cmp.Equal(writes, tt.wantWrites) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantWrites, writes)
+			}
+			if !cmp.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantDeleted, deleted)
+			}
+		})
+	}
+}
+
+func TestVaultMoveTarget(t *testing.T) {
+	tests := []struct {
+		name           string
+		admin          bool
+		hostData       map[string]interface{}
+		vaultErr       error
+		vaultPolicyErr error
+		wantWrites     []string
+		errResult      bool
+	}{
+		{
+			name:  "move target success",
+			admin: true,
+			wantWrites: []string{
+				"aws/roles/argo-cloudops-projects-project2-target-target1",
+				"auth/approle/role/argo-cloudops-projects-project2",
+			},
+		},
+		{
+			name:     "move target with host credentials",
+			admin:    true,
+			hostData: map[string]interface{}{"user": "ansible"},
+			wantWrites: []string{
+				"aws/roles/argo-cloudops-projects-project2-target-target1",
+				"secret/data/argo-cloudops-projects-project2-target-target1-host",
+				"auth/approle/role/argo-cloudops-projects-project2",
+			},
+		},
+		{
+			name:      "move target admin error",
+			admin:     false,
+			errResult: true,
+		},
+		{
+			name:      "move target error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+		{
+			name:           "move target policy error",
+			admin:          true,
+			vaultPolicyErr: errTest,
+			errResult:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var writes []string
+			policies := map[string]string{}
+			v := VaultProvider{
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, paths: &writes, data: map[string]interface{}{
+					"keys":            []interface{}{"argo-cloudops-projects-project1-target-target1"},
+					"credential_type": "assumed_role",
+					"role_arns":       []interface{}{"arn:aws:iam::012345678901:role/test-role"},
+					"data":            tt.hostData,
+					"token_policies":  []interface{}{"argo-cloudops-projects-project1"},
+				}},
+				vaultSysSvc: &mockVaultSys{err: tt.vaultPolicyErr, policies: policies},
+			}
+
+			err := v.MoveTarget("project1", "project2", "target1")
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+				return
+			}
+			if tt.errResult {
+				t.Fatalf("\nexpected error")
+			}
+			if !cmp.Equal(writes, tt.wantWrites) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantWrites, writes)
+			}
+			for _, policy := range []string{"argo-cloudops-projects-project1", "argo-cloudops-projects-project2"} {
+				if _, ok := policies[policy]; !ok {
+					t.Errorf("\nexpected policy %s to be written", policy)
+				}
+			}
+		})
+	}
+}
+
 func TestVaultDeleteTarget(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -269,6 +459,7 @@ func TestVaultDeleteTarget(t *testing.T) {
 			v := VaultProvider{
 				roleID:          role,
 				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr},
+				vaultSysSvc:     &mockVaultSys{},
 			}
 
 			err := v.DeleteTarget("testProject", "testTarget")
@@ -340,6 +531,180 @@ func TestVaultGetTarget(t *testing.T) {
 	}
 }
 
+func TestVaultGCPTarget(t *testing.T) {
+	target := types.Target{
+		Name: "target1",
+		Type: types.TargetTypeGCPProject,
+		Properties: types.TargetProperties{
+			CredentialType:      "impersonated_account",
+			PolicyArns:          []string{},
+			ServiceAccountEmail: "deployer@my-project.iam.gserviceaccount.com",
+			TokenScopes:         []string{"https://www.googleapis.com/auth/cloud-platform"},
+		},
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		v := VaultProvider{
+			roleID:          authorizationKeyAdmin,
+			vaultLogicalSvc: &mockVaultLogical{secrets: map[string]map[string]interface{}{}},
+			vaultSysSvc:     &mockVaultSys{},
+		}
+		if err := v.CreateTarget("project1", target); !errors.Is(err, ErrGCPNotEnabled) {
+			t.Errorf("\nwant: %v\n got: %v", ErrGCPNotEnabled, err)
+		}
+	})
+
+	path := "gcp/impersonated-account/argo-cloudops-projects-project1-target-target1"
+	secrets := map[string]map[string]interface{}{
+		"aws/roles/argo-cloudops-projects-project1-target-target2": {
+			"role_arns":       []interface{}{"arn:aws:iam::012345678901:role/test-role"},
+			"credential_type": "assumed_role",
+		},
+		"aws/roles/":                 {"keys": []interface{}{"argo-cloudops-projects-project1-target-target2"}},
+		"gcp/impersonated-accounts/": {"keys": []interface{}{"argo-cloudops-projects-project1-target-target1"}},
+	}
+	v := VaultProvider{
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: &mockVaultLogical{secrets: secrets},
+		vaultSysSvc:     &mockVaultSys{},
+		gcpEnabled:      true,
+	}
+
+	if err := v.CreateTarget("project1", target); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	want := map[string]interface{}{
+		"service_account_email": "deployer@my-project.iam.gserviceaccount.com",
+		"token_scopes":          []string{"https://www.googleapis.com/auth/cloud-platform"},
+	}
+	if !cmp.Equal(secrets[path], want) {
+		t.Errorf("\nwant: %v\n got: %v", want, secrets[path])
+	}
+
+	// Vault returns the scopes as a JSON array.
+	secrets[path]["token_scopes"] = []interface{}{"https://www.googleapis.com/auth/cloud-platform"}
+	got, err := v.GetTarget("project1", "target1")
+	if err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if !cmp.Equal(got, target) {
+		t.Errorf("\nwant: %v\n got: %v", target, got)
+	}
+
+	got, err = v.GetTarget("project1", "target2")
+	if err != nil || got.Type != types.TargetTypeAWSAccount {
+		t.Errorf("\nwant aws_account target, got: %v %v", got, err)
+	}
+
+	targets, err := v.ListTargets("project1")
+	if want := []string{"target2", "target1"}; err != nil || !cmp.Equal(targets, want) {
+		t.Errorf("\nwant: %v\n got: %v %v", want, targets, err)
+	}
+
+	if err := v.DeleteTarget("project1", "target1"); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if _, err := v.GetTarget("project1", "target1"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("\nwant: %v\n got: %v", ErrTargetNotFound, err)
+	}
+}
+
+func TestVaultAzureTarget(t *testing.T) {
+	target := types.Target{
+		Name: "target1",
+		Type: types.TargetTypeAzureSubscription,
+		Properties: types.TargetProperties{
+			CredentialType: "service_principal",
+			PolicyArns:     []string{},
+			TenantID:       "00000000-0000-0000-0000-000000000001",
+			SubscriptionID: "00000000-0000-0000-0000-000000000002",
+			RoleAssignments: []types.AzureRoleAssignment{
+				{RoleName: "Contributor", Scope: "/subscriptions/00000000-0000-0000-0000-000000000002"},
+			},
+		},
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		v := VaultProvider{
+			roleID:          authorizationKeyAdmin,
+			vaultLogicalSvc: &mockVaultLogical{secrets: map[string]map[string]interface{}{}},
+			vaultSysSvc:     &mockVaultSys{},
+		}
+		if err := v.CreateTarget("project1", target); !errors.Is(err, ErrAzureNotEnabled) {
+			t.Errorf("\nwant: %v\n got: %v", ErrAzureNotEnabled, err)
+		}
+	})
+
+	path := "azure/roles/argo-cloudops-projects-project1-target-target1"
+	kvPath := "secret/data/argo-cloudops-projects-project1-target-target1-azure"
+	secrets := map[string]map[string]interface{}{
+		"azure/roles/": {"keys": []interface{}{"argo-cloudops-projects-project1-target-target1"}},
+	}
+	v := VaultProvider{
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: &mockVaultLogical{secrets: secrets},
+		vaultSysSvc:     &mockVaultSys{},
+		azureEnabled:    true,
+	}
+
+	if err := v.CreateTarget("project1", target); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	want := map[string]interface{}{
+		"azure_roles": `[{"role_name":"Contributor","scope":"/subscriptions/00000000-0000-0000-0000-000000000002"}]`,
+	}
+	if !cmp.Equal(secrets[path], want) {
+		t.Errorf("\nwant: %v\n got: %v", want, secrets[path])
+	}
+	want = map[string]interface{}{
+		"data": map[string]interface{}{
+			"tenant_id":       "00000000-0000-0000-0000-000000000001",
+			"subscription_id": "00000000-0000-0000-0000-000000000002",
+		},
+	}
+	if !cmp.Equal(secrets[kvPath], want) {
+		t.Errorf("\nwant: %v\n got: %v", want, secrets[kvPath])
+	}
+
+	// Vault returns the roles as objects.
+	secrets[path]["azure_roles"] = []interface{}{
+		map[string]interface{}{"role_id": "/subscriptions/00000000-0000-0000-0000-000000000002/providers/Microsoft.Authorization/roleDefinitions/b24988ac", "role_name": "Contributor", "scope": "/subscriptions/00000000-0000-0000-0000-000000000002"},
+	}
+	got, err := v.GetTarget("project1", "target1")
+	if err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if !cmp.Equal(got, target) {
+		t.Errorf("\nwant: %v\n got: %v", target, got)
+	}
+
+	targets, err := v.ListTargets("project1")
+	if want := []string{"target1"}; err != nil || !cmp.Equal(targets, want) {
+		t.Errorf("\nwant: %v\n got: %v %v", want, targets, err)
+	}
+
+	if err := v.MoveTarget("project1", "project2", "target1"); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	got, err = v.GetTarget("project2", "target1")
+	if err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if !cmp.Equal(got, target) {
+		t.Errorf("\nwant: %v\n got: %v", target, got)
+	}
+	if _, err := v.GetTarget("project1", "target1"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("\nwant: %v\n got: %v", ErrTargetNotFound, err)
+	}
+
+	if err := v.DeleteTarget("project2", "target1"); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if _, err := v.GetTarget("project2", "target1"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("\nwant: %v\n got: %v", ErrTargetNotFound, err)
+	}
+}
+
 func TestVaultGetToken(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -393,6 +758,156 @@ func TestVaultGetToken(t *testing.T) {
 	}
 }
 
+func TestVaultGetRunToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		wrap      bool
+		vaultErr  error
+		want      RunToken
+		wantPaths []string
+		wantErr   bool
+	}{
+		{
+			name:      "get run token success",
+			want:      RunToken{Token: "secretToken", Accessor: "accessor"},
+			wantPaths: []string{"auth/approle/login"},
+		},
+		{
+			name:      "get wrapped run token success",
+			wrap:      true,
+			want:      RunToken{Token: "wrappingToken", Accessor: "accessor"},
+			wantPaths: []string{"auth/approle/login", "sys/wrapping/wrap"},
+		},
+		{
+			name:    "get run token admin error",
+			admin:   true,
+			wantErr: true,
+		},
+		{
+			name:      "get run token error",
+			vaultErr:  errTest,
+			wantErr:   true,
+			wantPaths: []string{"auth/approle/login"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var paths []string
+			v := VaultProvider{
+				roleID:          role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, token: "secretToken", accessor: "accessor", wrapToken: "wrappingToken", paths: &paths},
+			}
+
+			rt, err := v.GetRunToken(tt.wrap)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.wantErr {
+					t.Errorf("\nexpected error")
+				}
+				if !cmp.Equal(rt, tt.want) {
+					t.Errorf("\nwant: %v\n got: %v", tt.want, rt)
+				}
+			}
+			if !cmp.Equal(paths, tt.wantPaths) {
+				t.Errorf("\nwant paths: %v\n got: %v", tt.wantPaths, paths)
+			}
+		})
+	}
+}
+
+func TestWrappingLookupFunc(t *testing.T) {
+	lookup := wrappingLookupFunc(time.Hour)
+	if got := lookup(http.MethodPost, "sys/wrapping/wrap"); got != "1h0m0s" {
+		t.Errorf("\nwant: %v\n got: %v", "1h0m0s", got)
+	}
+	if got := lookup(http.MethodPost, "auth/approle/login"); got != "" {
+		t.Errorf("\nwant: %v\n got: %v", "", got)
+	}
+}
+
+func TestVaultRevokeToken(t *testing.T) {
+	var paths []string
+	v := VaultProvider{
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: &mockVaultLogical{paths: &paths},
+	}
+	if err := v.RevokeToken("accessor"); err != nil {
+		t.Errorf("\ndid not expect error, got: %v", err)
+	}
+	if want := []string{"auth/token/revoke-accessor"}; !cmp.Equal(paths, want) {
+		t.Errorf("\nwant paths: %v\n got: %v", want, paths)
+	}
+
+	v.roleID = "testRole"
+	if err := v.RevokeToken("accessor"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+}
+
+func TestVaultGetProjectToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		vaultErr  error
+		errResult bool
+	}{
+		{
+			name:  "get project token success",
+			admin: true,
+		},
+		{
+			name:      "get project token not admin error",
+			errResult: true,
+		},
+		{
+			name:      "get project token error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			v := VaultProvider{
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{
+					err:   tt.vaultErr,
+					token: "projectToken",
+					data:  map[string]interface{}{"role_id": "roleID", "secret_id": "secretID"},
+				},
+			}
+
+			token, err := v.GetProjectToken("project1")
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.errResult {
+					t.Errorf("\nexpected error")
+				}
+				if token != "projectToken" {
+					t.Errorf("\nwant: %v\n got: %v", "projectToken", token)
+				}
+			}
+		})
+	}
+}
+
 func TestVaultListTargets(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -509,6 +1024,329 @@ func TestVaultProjectExists(t *testing.T) {
 	}
 }
 
+func TestVaultProjectAuthorized(t *testing.T) {
+	tests := []struct {
+		name      string
+		roleID    string
+		vaultErr  error
+		want      bool
+		expectErr bool
+	}{
+		{
+			name:   "credentials of the project",
+			roleID: "project-role-id",
+			want:   true,
+		},
+		{
+			name:   "credentials of another project",
+			roleID: "other-role-id",
+			want:   false,
+		},
+		{
+			name:   "admin credentials",
+			roleID: authorizationKeyAdmin,
+			want:   false,
+		},
+		{
+			name:      "vault error",
+			roleID:    "project-role-id",
+			vaultErr:  errTest,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := VaultProvider{
+				roleID:   tt.roleID,
+				secretID: "secret-id",
+				vaultLogicalSvc: &mockVaultLogical{
+					data: map[string]interface{}{"role_id": "project-role-id"},
+					err:  tt.vaultErr,
+				},
+			}
+
+			authorized, err := v.ProjectAuthorized("project1")
+			if err != nil {
+				if !tt.expectErr {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.expectErr {
+					t.Errorf("\nexpected error")
+				}
+
+				if !cmp.Equal(authorized, tt.want) {
+					t.Errorf("\nwant: %v\n got: %v", tt.want, authorized)
+				}
+			}
+		})
+	}
+}
+
+func TestVaultIdentity(t *testing.T) {
+	tests := []struct {
+		name      string
+		roleID    string
+		roleName  string
+		data      map[string]interface{}
+		vaultErr  error
+		want      Identity
+		wantPaths []string
+		wantErr   error
+		expectErr bool
+	}{
+		{
+			name:     "project credentials",
+			roleID:   "project-role-id",
+			roleName: "argo-cloudops-projects-project1",
+			data: map[string]interface{}{
+				"expiration_time":    "2027-10-16T12:00:00.5Z",
+				"secret_id_num_uses": json.Number("0"),
+			},
+			want: Identity{Project: "project1", ExpiresAt: time.Date(2027, 10, 16, 12, 0, 0, 500000000, time.UTC)},
+			wantPaths: []string{
+				"auth/approle/login",
+				"auth/token/revoke-accessor",
+				"auth/approle/role/argo-cloudops-projects-project1/secret-id/lookup",
+			},
+		},
+		{
+			name:     "project credentials without expiry",
+			roleID:   "project-role-id",
+			roleName: "argo-cloudops-projects-project1",
+			data: map[string]interface{}{
+				"expiration_time":    "0001-01-01T00:00:00Z",
+				"secret_id_num_uses": json.Number("5"),
+			},
+			want: Identity{Project: "project1", UsesRemaining: 5},
+			wantPaths: []string{
+				"auth/approle/login",
+				"auth/token/revoke-accessor",
+				"auth/approle/role/argo-cloudops-projects-project1/secret-id/lookup",
+			},
+		},
+		{
+			name:   "admin credentials",
+			roleID: authorizationKeyAdmin,
+			want:   Identity{Admin: true},
+		},
+		{
+			name:      "credentials of another approle",
+			roleID:    "service-role-id",
+			roleName:  "argo-cloudops",
+			wantPaths: []string{"auth/approle/login"},
+			wantErr:   ErrInvalidCredentials,
+		},
+		{
+			name:      "credentials vault doesn't accept",
+			roleID:    "project-role-id",
+			vaultErr:  &vault.ResponseError{StatusCode: http.StatusBadRequest, Errors: []string{"invalid role or secret ID"}},
+			wantPaths: []string{"auth/approle/login"},
+			wantErr:   ErrInvalidCredentials,
+		},
+		{
+			name:      "vault error",
+			roleID:    "project-role-id",
+			vaultErr:  errTest,
+			wantPaths: []string{"auth/approle/login"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			v := VaultProvider{
+				roleID:   tt.roleID,
+				secretID: "secret-id",
+				vaultLogicalSvc: &mockVaultLogical{
+					data:     tt.data,
+					metadata: map[string]string{"role_name": tt.roleName},
+					accessor: "accessor",
+					err:      tt.vaultErr,
+					paths:    &paths,
+				},
+			}
+
+			identity, err := v.Identity()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("\nwant error: %v\n got: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				if !tt.expectErr {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.expectErr {
+					t.Errorf("\nexpected error")
+				}
+				if !cmp.Equal(identity, tt.want) {
+					t.Errorf("\nwant: %v\n got: %v", tt.want, identity)
+				}
+			}
+			if !cmp.Equal(paths, tt.wantPaths) {
+				t.Errorf("\nwant paths: %v\n got: %v", tt.wantPaths, paths)
+			}
+		})
+	}
+}
+
+func TestVaultPutTargetHostCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		admin     bool
+		vaultErr  error
+		wantPaths []string
+		errResult bool
+	}{
+		{
+			name:      "put host credentials success",
+			admin:     true,
+			wantPaths: []string{"secret/data/argo-cloudops-projects-project1-target-target1-host"},
+		},
+		{
+			name:      "put host credentials admin error",
+			errResult: true,
+		},
+		{
+			name:      "put host credentials error",
+			admin:     true,
+			vaultErr:  errTest,
+			errResult: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var paths []string
+			v := VaultProvider{
+				roleID:          role,
+				vaultLogicalSvc: &mockVaultLogical{err: tt.vaultErr, paths: &paths},
+				vaultSysSvc:     &mockVaultSys{},
+			}
+
+			err := v.PutTargetHostCredentials("project1", "target1", HostCredentials{User: "deploy", PrivateKey: "key"})
+			if err != nil {
+				if !tt.errResult {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+			} else {
+				if tt.errResult {
+					t.Errorf("\nexpected error")
+				}
+				if !cmp.Equal(paths, tt.wantPaths) {
+					t.Errorf("\nwant: %v\n got: %v", tt.wantPaths, paths)
+				}
+			}
+		})
+	}
+}
+
+func TestVaultDeleteTargetHostCredentials(t *testing.T) {
+	v := VaultProvider{roleID: "testRole", vaultLogicalSvc: &mockVaultLogical{}}
+	if err := v.DeleteTargetHostCredentials("project1", "target1"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+
+	v.roleID = authorizationKeyAdmin
+	if err := v.DeleteTargetHostCredentials("project1", "target1"); err != nil {
+		t.Errorf("\ndid not expect error, got: %v", err)
+	}
+}
+
+func TestVaultGetTargetCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		admin    bool
+		vaultErr error
+		want     TargetCredentials
+		wantErr  bool
+	}{
+		{
+			name:  "get target credentials success",
+			admin: true,
+			want: TargetCredentials{
+				AccessKeyID:     "ASIAEXAMPLE",
+				SecretAccessKey: "secret",
+				SessionToken:    "session",
+				LeaseID:         "aws/sts/argo-cloudops-projects-project1-target-target1/lease",
+				LeaseTTL:        15 * time.Minute,
+			},
+		},
+		{
+			name:    "get target credentials not admin error",
+			wantErr: true,
+		},
+		{
+			name:     "get target credentials error",
+			admin:    true,
+			vaultErr: errTest,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var role = "testRole"
+			if tt.admin {
+				role = authorizationKeyAdmin
+			}
+			var paths []string
+			v := VaultProvider{
+				roleID: role,
+				vaultLogicalSvc: &mockVaultLogical{
+					data:    map[string]interface{}{"access_key": "ASIAEXAMPLE", "secret_key": "secret", "security_token": "session"},
+					leaseID: "aws/sts/argo-cloudops-projects-project1-target-target1/lease",
+					err:     tt.vaultErr,
+					paths:   &paths,
+				},
+			}
+
+			c, err := v.GetTargetCredentials("project1", "target1", 15*time.Minute)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("\ndid not expect error, got: %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Errorf("\nexpected error")
+			}
+			if !cmp.Equal(c, tt.want) {
+				t.Errorf("\nwant: %v\n got: %v", tt.want, c)
+			}
+			if want := []string{"aws/sts/argo-cloudops-projects-project1-target-target1"}; !cmp.Equal(paths, want) {
+				t.Errorf("\nwant paths: %v\n got: %v", want, paths)
+			}
+		})
+	}
+}
+
+func TestVaultRevokeLease(t *testing.T) {
+	var paths []string
+	v := VaultProvider{
+		roleID:          authorizationKeyAdmin,
+		vaultLogicalSvc: &mockVaultLogical{paths: &paths},
+	}
+	if err := v.RevokeLease("lease"); err != nil {
+		t.Errorf("\ndid not expect error, got: %v", err)
+	}
+	if want := []string{"sys/leases/revoke"}; !cmp.Equal(paths, want) {
+		t.Errorf("\nwant paths: %v\n got: %v", want, paths)
+	}
+
+	v.roleID = "testRole"
+	if err := v.RevokeLease("lease"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+}
+
 func TestValidateAuthorizedAdmin(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -594,17 +1432,67 @@ func TestNewAuthorization(t *testing.T) {
 	}
 }
 
+func TestVaultRegistryPassword(t *testing.T) {
+	var paths []string
+	v := VaultProvider{
+		roleID:          "testRole",
+		vaultLogicalSvc: &mockVaultLogical{paths: &paths, secrets: map[string]map[string]interface{}{}},
+	}
+	if err := v.PutRegistryPassword("ref1", "abcd1234"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+	if _, err := v.GetRegistryPassword("ref1"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+	if err := v.DeleteRegistryPassword("ref1"); err == nil {
+		t.Errorf("\nexpected error")
+	}
+
+	v.roleID = authorizationKeyAdmin
+	if err := v.PutRegistryPassword("ref1", "abcd1234"); err != nil {
+		t.Fatalf("\ndid not expect error, got: %v", err)
+	}
+	if want := []string{"secret/data/argo-cloudops-registries-ref1"}; !cmp.Equal(paths, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, paths)
+	}
+
+	password, err := v.GetRegistryPassword("ref1")
+	if err != nil || password != "abcd1234" {
+		t.Errorf("\nwant: abcd1234\n got: %v %v", password, err)
+	}
+	if _, err := v.GetRegistryPassword("ref2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nwant: %v\n got: %v", ErrNotFound, err)
+	}
+	if err := v.DeleteRegistryPassword("ref1"); err != nil {
+		t.Errorf("\ndid not expect error, got: %v", err)
+	}
+}
+
 type mockVaultLogical struct {
 	vault.Logical
-	data  map[string]interface{}
-	token string
-	err   error
+	data      map[string]interface{}
+	token     string
+	accessor  string
+	wrapToken string
+	leaseID   string
+	metadata  map[string]string
+	err       error
+	paths     *[]string
+	// secrets are the data of each path, read or listed, when set instead
+	// of data.
+	secrets map[string]map[string]interface{}
 }
 
 func (m mockVaultLogical) Read(path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	if m.secrets != nil {
+		if data, ok := m.secrets[path]; ok {
+			return &vault.Secret{Data: data}, nil
+		}
+		return nil, nil
+	}
 	return &vault.Secret{Data: m.data}, nil
 }
 
@@ -612,32 +1500,58 @@ func (m mockVaultLogical) List(path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	if m.secrets != nil {
+		if data, ok := m.secrets[path]; ok {
+			return &vault.Secret{Data: data}, nil
+		}
+		return nil, nil
+	}
 	return &vault.Secret{Data: m.data}, nil
 }
 
 func (m mockVaultLogical) Write(path string, data map[string]interface{}) (*vault.Secret, error) {
+	if m.paths != nil {
+		*m.paths = append(*m.paths, path)
+	}
 	if m.err != nil {
 		return nil, m.err
 	}
-	return &vault.Secret{Data: m.data, Auth: &vault.SecretAuth{ClientToken: m.token}}, nil
+	if m.secrets != nil {
+		m.secrets[path] = data
+	}
+
+	sec := &vault.Secret{Data: m.data, LeaseID: m.leaseID, LeaseDuration: 900, Auth: &vault.SecretAuth{ClientToken: m.token, Accessor: m.accessor, Metadata: m.metadata}}
+	if m.wrapToken != "" {
+		sec.WrapInfo = &vault.SecretWrapInfo{Token: m.wrapToken}
+	}
+	return sec, nil
 }
 
 func (m mockVaultLogical) Delete(path string) (*vault.Secret, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	delete(m.secrets, path)
 	return &vault.Secret{}, nil
 }
 
 type mockVaultSys struct {
 	vault.Sys
-	err error
+	err      error
+	policies map[string]string
+	deleted  *[]string
 }
 
 func (m mockVaultSys) PutPolicy(name, rules string) error {
+	if m.policies != nil {
+		m.policies[name] = rules
+	}
 	return m.err
 }
 
 func (m mockVaultSys) DeletePolicy(name string) error {
+	if m.deleted != nil {
+		*m.deleted = append(*m.deleted, name)
+	}
 	return m.err
 }
@@ -2,6 +2,10 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/upper/db/v4"
 	"github.com/upper/db/v4/adapter/postgresql"
@@ -12,41 +16,659 @@ type ProjectEntry struct {
 	Repository string `db:"repository"`
 }
 
+// ExecutionEvent records something that happened while executing a workflow,
+// e.g. a submission attempt. Events are correlated by the request transaction
+// ID as the workflow name isn't known until submission succeeds.
+type ExecutionEvent struct {
+	TxID         string    `db:"txid"`
+	Project      string    `db:"project"`
+	Target       string    `db:"target"`
+	WorkflowName string    `db:"workflow_name"`
+	Type         string    `db:"type"`
+	Message      string    `db:"message"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// TargetGuardrailEntry is a metrics query evaluated while and after a
+// workflow for the target runs. The workflow is terminated when the query
+// result is above or below (Operator) the threshold.
+type TargetGuardrailEntry struct {
+	Project   string  `db:"project"`
+	Target    string  `db:"target"`
+	Provider  string  `db:"provider"`
+	Query     string  `db:"query"`
+	Threshold float64 `db:"threshold"`
+	Operator  string  `db:"operator"`
+}
+
+// TargetChangeControlEntry flags a target as change controlled. Submissions
+// to it require a change ticket, which must be approved when RequireApproval
+// is set.
+type TargetChangeControlEntry struct {
+	Project         string `db:"project"`
+	Target          string `db:"target"`
+	RequireApproval bool   `db:"require_approval"`
+}
+
+// ProjectNotificationRuleEntry notifies the system of the type (e.g.
+// pagerduty) using the routing key when ConsecutiveFailures workflows in a row
+// failed for a target of the project.
+type ProjectNotificationRuleEntry struct {
+	Project             string `db:"project"`
+	Type                string `db:"type"`
+	RoutingKey          string `db:"routing_key"`
+	ConsecutiveFailures int    `db:"consecutive_failures"`
+}
+
+// ProjectBusinessHoursEntry restricts submissions to targets of the project
+// (all targets when Targets is empty) to business hours. Days (e.g. mon,tue)
+// and Targets are comma separated.
+type ProjectBusinessHoursEntry struct {
+	Project  string `db:"project"`
+	Timezone string `db:"timezone"`
+	Days     string `db:"days"`
+	Start    string `db:"start_time"`
+	End      string `db:"end_time"`
+	Targets  string `db:"targets"`
+}
+
+// ProjectWorkflowTemplateEntry allows workflows of the project to reference
+// an existing Argo WorkflowTemplate or ClusterWorkflowTemplate (Kind) by name.
+type ProjectWorkflowTemplateEntry struct {
+	Project string `db:"project"`
+	Kind    string `db:"kind"`
+	Name    string `db:"name"`
+}
+
+// ProjectFeatureFlagEntry overrides the state of a feature flag for a
+// project.
+type ProjectFeatureFlagEntry struct {
+	Project string `db:"project"`
+	Name    string `db:"name"`
+	Enabled bool   `db:"enabled"`
+}
+
+// ProjectRegistryCredentialEntry authenticates the project to an OCI
+// registry when submitting workflows from artifacts. The password is kept by
+// the credentials provider, PasswordRef is its reference there.
+type ProjectRegistryCredentialEntry struct {
+	Project     string `db:"project"`
+	Registry    string `db:"registry"`
+	Username    string `db:"username"`
+	PasswordRef string `db:"password_ref"`
+}
+
+// TargetScheduleEntry is a workflow of the target submitted on the cron
+// schedule by an Argo CronWorkflow. Workflow is the JSON of the workflow
+// request.
+type TargetScheduleEntry struct {
+	Project  string `db:"project"`
+	Target   string `db:"target"`
+	Name     string `db:"name"`
+	Cron     string `db:"cron"`
+	Timezone string `db:"timezone"`
+	Suspend  bool   `db:"suspend"`
+	Workflow string `db:"workflow"`
+}
+
+// TargetSchedulingEntry constrains the nodes the pods of workflows for the
+// target run on and their priority. NodeSelector and Tolerations are JSON.
+type TargetSchedulingEntry struct {
+	Project           string `db:"project"`
+	Target            string `db:"target"`
+	NodeSelector      string `db:"node_selector"`
+	Tolerations       string `db:"tolerations"`
+	PriorityClassName string `db:"priority_class_name"`
+	RuntimeClassName  string `db:"runtime_class_name"`
+	// Platform is the os/arch of the nodes, e.g. linux/arm64.
+	Platform string `db:"platform"`
+}
+
+// TargetWorkloadIdentityEntry is the IRSA service account the pods of
+// workflows for the target run as.
+type TargetWorkloadIdentityEntry struct {
+	Project        string `db:"project"`
+	Target         string `db:"target"`
+	ServiceAccount string `db:"service_account"`
+}
+
+// RunTokenEntry is the Vault token issued for a workflow run, identified by
+// its accessor, until it's revoked once the workflow completes.
+type RunTokenEntry struct {
+	WorkflowName string    `db:"workflow_name"`
+	TxID         string    `db:"txid"`
+	Project      string    `db:"project"`
+	Target       string    `db:"target"`
+	Accessor     string    `db:"accessor"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// BreakGlassEntry is the audit record of break-glass credentials of a target
+// issued to an admin. TxID is the transaction ID of the request, correlating
+// the record with its execution events. It's kept once the lease of the
+// credentials is revoked.
+type BreakGlassEntry struct {
+	ID            string     `db:"id"`
+	TxID          string     `db:"txid"`
+	Project       string     `db:"project"`
+	Target        string     `db:"target"`
+	Justification string     `db:"justification"`
+	RemoteAddress string     `db:"remote_address"`
+	LeaseID       string     `db:"lease_id"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	RevokedAt     *time.Time `db:"revoked_at"`
+}
+
+// ChangeSetSummaryEntry is the summary of a CloudFormation change set created
+// by a diff of the target, included in the change tickets of the sync
+// executing it.
+type ChangeSetSummaryEntry struct {
+	Project       string    `db:"project"`
+	Target        string    `db:"target"`
+	StackName     string    `db:"stack_name"`
+	ChangeSetName string    `db:"change_set_name"`
+	WorkflowName  string    `db:"workflow_name"`
+	Summary       string    `db:"summary"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// PlanSummaryEntry is the summary of the plan of a terraform or cdk diff, for
+// reviewers of the sync of its commit. Summary is JSON.
+type PlanSummaryEntry struct {
+	WorkflowName string    `db:"workflow_name"`
+	Project      string    `db:"project"`
+	Target       string    `db:"target"`
+	CommitHash   string    `db:"commit_hash"`
+	Summary      string    `db:"summary"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// TargetInventoryEntry is the Ansible inventory of the target, the hosts of
+// ansible workflows. Hosts, Groups and Vars are JSON, the credentials of the
+// hosts are stored in Vault.
+type TargetInventoryEntry struct {
+	Project    string `db:"project"`
+	Target     string `db:"target"`
+	Connection string `db:"connection"`
+	User       string `db:"user_name"`
+	Port       int    `db:"port"`
+	Hosts      string `db:"hosts"`
+	Groups     string `db:"groups"`
+	Vars       string `db:"vars"`
+}
+
+// ProjectSettingsEntry is the settings document of the project, JSON of
+// types.ProjectSettings.
+type ProjectSettingsEntry struct {
+	Project   string    `db:"project"`
+	Settings  string    `db:"settings"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ProjectEnvironmentEntry is an environment of the project, JSON of
+// types.Environment.
+type ProjectEnvironmentEntry struct {
+	Project     string    `db:"project"`
+	Name        string    `db:"name"`
+	Environment string    `db:"environment"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// TargetCostAllocationTagsEntry is the cost allocation tags of the target,
+// passed to its workflows to tag the resources they create. Tags is JSON.
+type TargetCostAllocationTagsEntry struct {
+	Project string `db:"project"`
+	Target  string `db:"target"`
+	Tags    string `db:"tags"`
+}
+
+// TargetSoakTimeEntry is how long after a diff of a commit succeeded syncs of
+// it can be submitted to the target, zero when unbounded.
+type TargetSoakTimeEntry struct {
+	Project             string `db:"project"`
+	Target              string `db:"target"`
+	MinSoakSeconds      int64  `db:"min_soak_seconds"`
+	MaxStalenessSeconds int64  `db:"max_staleness_seconds"`
+}
+
+// TargetTerraformStateEntry is the location of the terraform state of the
+// target and the stale lock of it detected in the logs of a failed run, if
+// any. Workflow is the JSON requests.CreateWorkflow of that run, forced
+// unlocks are run like it.
+type TargetTerraformStateEntry struct {
+	Project          string     `db:"project"`
+	Target           string     `db:"target"`
+	Backend          string     `db:"backend"`
+	Location         string     `db:"location"`
+	LockID           string     `db:"lock_id"`
+	LockPath         string     `db:"lock_path"`
+	LockOperation    string     `db:"lock_operation"`
+	LockWho          string     `db:"lock_who"`
+	LockCreatedAt    *time.Time `db:"lock_created_at"`
+	LockWorkflowName string     `db:"lock_workflow_name"`
+	LockDetectedAt   *time.Time `db:"lock_detected_at"`
+	Workflow         string     `db:"workflow"`
+}
+
+// ProjectElevationEntry is a request of a project for temporary admin access
+// to it, identified by the transaction ID of the request. TokenHash is the
+// SHA-256 of the secret of its token, only valid once granted until it
+// expires or is revoked. It's kept afterwards as the audit record.
+type ProjectElevationEntry struct {
+	ID            string     `db:"id"`
+	Project       string     `db:"project"`
+	RequestedBy   string     `db:"requested_by"`
+	Justification string     `db:"justification"`
+	TTLSeconds    int64      `db:"ttl_seconds"`
+	TokenHash     string     `db:"token_hash"`
+	CreatedAt     time.Time  `db:"created_at"`
+	GrantedBy     string     `db:"granted_by"`
+	GrantedAt     *time.Time `db:"granted_at"`
+	ExpiresAt     *time.Time `db:"expires_at"`
+	RevokedAt     *time.Time `db:"revoked_at"`
+}
+
+// FrameworkImageEntry is a rotation of the image of a framework version, an
+// image tag as workflows set it, to a digest. The digest is used by the
+// workflows of the canary project until the rotation is promoted, by every
+// workflow afterwards, or aborted. The latest promoted rotation of an image is
+// its pin, others are kept as its history.
+type FrameworkImageEntry struct {
+	ID            string     `db:"id"`
+	Framework     string     `db:"framework"`
+	Image         string     `db:"image"`
+	Digest        string     `db:"digest"`
+	CanaryProject string     `db:"canary_project"`
+	CreatedBy     string     `db:"created_by"`
+	CreatedAt     time.Time  `db:"created_at"`
+	PromotedBy    string     `db:"promoted_by"`
+	PromotedAt    *time.Time `db:"promoted_at"`
+	AbortedBy     string     `db:"aborted_by"`
+	AbortedAt     *time.Time `db:"aborted_at"`
+}
+
+// ExecutionAttestationEntry is the signed provenance of a completed sync,
+// identified by its workflow. Provenance is JSON, Signature is of its bytes.
+type ExecutionAttestationEntry struct {
+	WorkflowName string    `db:"workflow_name"`
+	TxID         string    `db:"txid"`
+	Project      string    `db:"project"`
+	Target       string    `db:"target"`
+	Provenance   string    `db:"provenance"`
+	Signature    string    `db:"signature"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// TargetSubmissionSourceEntry is a source workflows of the target were
+// submitted from (e.g. the repository and path of a git manifest), with the
+// time of the first submission.
+type TargetSubmissionSourceEntry struct {
+	Project          string    `db:"project"`
+	Target           string    `db:"target"`
+	Source           string    `db:"source"`
+	FirstSubmittedAt time.Time `db:"first_submitted_at"`
+}
+
+// TargetDependencyEntry is a target of another project (or the same) which
+// must deploy successfully before the target in rollouts.
+type TargetDependencyEntry struct {
+	Project          string `db:"project"`
+	Target           string `db:"target"`
+	DependsOnProject string `db:"depends_on_project"`
+	DependsOnTarget  string `db:"depends_on_target"`
+}
+
+// RolloutEntry is a rollout of targets of several projects following their
+// dependencies. Steps is the JSON of its steps and their statuses.
+type RolloutEntry struct {
+	ID        string    `db:"id"`
+	Status    string    `db:"status"`
+	Steps     string    `db:"steps"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ExecutionNoteEntry is a note attached to a past workflow, e.g. a retro note
+// or incident link. KnownBad flags (or clears) the workflow as known-bad,
+// nil when the note doesn't change it.
+type ExecutionNoteEntry struct {
+	ID           string    `db:"id"`
+	WorkflowName string    `db:"workflow_name"`
+	Project      string    `db:"project"`
+	Target       string    `db:"target"`
+	Author       string    `db:"author"`
+	Note         string    `db:"note"`
+	IncidentURL  string    `db:"incident_url"`
+	KnownBad     *bool     `db:"known_bad"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// ProjectWebhookEntry is a callback URL the execution events of the project
+// are delivered to, signed with the secret. Events is a comma separated list
+// of event type filters, empty for every event.
+type ProjectWebhookEntry struct {
+	ID        string    `db:"id"`
+	Project   string    `db:"project"`
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Events    string    `db:"events"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// WebhookDeliveryEntry is the delivery of an event to a webhook. Pending
+// deliveries are attempted once their next attempt is due, ResponseCode and
+// Error are of the last attempt.
+type WebhookDeliveryEntry struct {
+	ID            string    `db:"id"`
+	WebhookID     string    `db:"webhook_id"`
+	Project       string    `db:"project"`
+	EventType     string    `db:"event_type"`
+	Payload       string    `db:"payload"`
+	Status        string    `db:"status"`
+	Attempts      int       `db:"attempts"`
+	ResponseCode  int       `db:"response_code"`
+	Error         string    `db:"error"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+// ProjectEncryptionKeyEntry is the public key values of the project are
+// encrypted with. PrivateKeySecret names the Kubernetes secret holding the
+// private key, which is only mounted into workflow pods.
+type ProjectEncryptionKeyEntry struct {
+	Project          string    `db:"project"`
+	KeyID            string    `db:"key_id"`
+	PublicKey        string    `db:"public_key"`
+	PrivateKeySecret string    `db:"private_key_secret"`
+	CreatedAt        time.Time `db:"created_at"`
+}
+
+// ResourceIDEntry is the stable ID of a project or target (Target is empty
+// for projects), e.g. for Terraform to tell a resource from one recreated
+// with the same name.
+type ResourceIDEntry struct {
+	ID        string    `db:"id"`
+	Kind      string    `db:"kind"`
+	Project   string    `db:"project"`
+	Target    string    `db:"target"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ProjectAliasEntry is a former name of a renamed project, resolved to the
+// project for reads until it expires.
+type ProjectAliasEntry struct {
+	Alias     string    `db:"alias"`
+	Project   string    `db:"project"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// ConcurrencyBucket is the number of workflows of a target running at any
+// time during the bucket starting at Start.
+type ConcurrencyBucket struct {
+	Start   time.Time `db:"bucket"`
+	Project string    `db:"project"`
+	Target  string    `db:"target"`
+	Running int       `db:"running"`
+}
+
 // Client allows for db crud operations
 type Client interface {
 	CreateProjectEntry(ctx context.Context, pe ProjectEntry) error
 	ReadProjectEntry(ctx context.Context, project string) (ProjectEntry, error)
+	ListProjectEntries(ctx context.Context) ([]ProjectEntry, error)
 	DeleteProjectEntry(ctx context.Context, project string) error
+	CreateExecutionEvent(ctx context.Context, ee ExecutionEvent) error
+	CreateTargetGuardrailEntry(ctx context.Context, tg TargetGuardrailEntry) error
+	ReadTargetGuardrailEntry(ctx context.Context, project, target string) (TargetGuardrailEntry, error)
+	DeleteTargetGuardrailEntry(ctx context.Context, project, target string) error
+	CreateTargetChangeControlEntry(ctx context.Context, tc TargetChangeControlEntry) error
+	ReadTargetChangeControlEntry(ctx context.Context, project, target string) (TargetChangeControlEntry, error)
+	DeleteTargetChangeControlEntry(ctx context.Context, project, target string) error
+	CreateProjectNotificationRuleEntry(ctx context.Context, nr ProjectNotificationRuleEntry) error
+	ListProjectNotificationRuleEntries(ctx context.Context, project string) ([]ProjectNotificationRuleEntry, error)
+	DeleteProjectNotificationRuleEntry(ctx context.Context, project, ruleType string) error
+	CreateProjectBusinessHoursEntry(ctx context.Context, bh ProjectBusinessHoursEntry) error
+	ReadProjectBusinessHoursEntry(ctx context.Context, project string) (ProjectBusinessHoursEntry, error)
+	DeleteProjectBusinessHoursEntry(ctx context.Context, project string) error
+	CreateProjectWorkflowTemplateEntry(ctx context.Context, wt ProjectWorkflowTemplateEntry) error
+	ListProjectWorkflowTemplateEntries(ctx context.Context, project string) ([]ProjectWorkflowTemplateEntry, error)
+	DeleteProjectWorkflowTemplateEntry(ctx context.Context, project, kind, name string) error
+	CreateProjectFeatureFlagEntry(ctx context.Context, ff ProjectFeatureFlagEntry) error
+	ListProjectFeatureFlagEntries(ctx context.Context, project string) ([]ProjectFeatureFlagEntry, error)
+	DeleteProjectFeatureFlagEntry(ctx context.Context, project, name string) error
+	CreateProjectRegistryCredentialEntry(ctx context.Context, rc ProjectRegistryCredentialEntry) error
+	ListProjectRegistryCredentialEntries(ctx context.Context, project string) ([]ProjectRegistryCredentialEntry, error)
+	DeleteProjectRegistryCredentialEntry(ctx context.Context, project, registry string) error
+	CreateTargetScheduleEntry(ctx context.Context, ts TargetScheduleEntry) error
+	ListTargetScheduleEntries(ctx context.Context, project, target string) ([]TargetScheduleEntry, error)
+	ListScheduleEntries(ctx context.Context) ([]TargetScheduleEntry, error)
+	DeleteTargetScheduleEntry(ctx context.Context, project, target, name string) error
+	CreateTargetSchedulingEntry(ctx context.Context, e TargetSchedulingEntry) error
+	ReadTargetSchedulingEntry(ctx context.Context, project, target string) (TargetSchedulingEntry, error)
+	DeleteTargetSchedulingEntry(ctx context.Context, project, target string) error
+	CreateTargetWorkloadIdentityEntry(ctx context.Context, e TargetWorkloadIdentityEntry) error
+	ReadTargetWorkloadIdentityEntry(ctx context.Context, project, target string) (TargetWorkloadIdentityEntry, error)
+	DeleteTargetWorkloadIdentityEntry(ctx context.Context, project, target string) error
+	CreateRunTokenEntry(ctx context.Context, rt RunTokenEntry) error
+	ListRunTokenEntries(ctx context.Context) ([]RunTokenEntry, error)
+	DeleteRunTokenEntry(ctx context.Context, workflowName string) error
+	CreateChangeSetSummaryEntry(ctx context.Context, cs ChangeSetSummaryEntry) error
+	ReadChangeSetSummaryEntry(ctx context.Context, project, target, stackName, changeSetName string) (ChangeSetSummaryEntry, error)
+	DeleteChangeSetSummaryEntries(ctx context.Context, project, target string) error
+	CreatePlanSummaryEntry(ctx context.Context, e PlanSummaryEntry) error
+	ReadPlanSummaryEntry(ctx context.Context, workflowName string) (PlanSummaryEntry, error)
+	ReadCommitPlanSummaryEntry(ctx context.Context, project, target, commitHash string) (PlanSummaryEntry, error)
+	DeletePlanSummaryEntries(ctx context.Context, project, target string) error
+	CreateTargetInventoryEntry(ctx context.Context, e TargetInventoryEntry) error
+	ReadTargetInventoryEntry(ctx context.Context, project, target string) (TargetInventoryEntry, error)
+	DeleteTargetInventoryEntry(ctx context.Context, project, target string) error
+	CreateBreakGlassEntry(ctx context.Context, e BreakGlassEntry) error
+	ListBreakGlassEntries(ctx context.Context, project, target string) ([]BreakGlassEntry, error)
+	ListUnrevokedBreakGlassEntries(ctx context.Context) ([]BreakGlassEntry, error)
+	RevokeBreakGlassEntry(ctx context.Context, id string, revokedAt time.Time) error
+	CreateProjectSettingsEntry(ctx context.Context, e ProjectSettingsEntry) error
+	ReadProjectSettingsEntry(ctx context.Context, project string) (ProjectSettingsEntry, error)
+	CreateProjectEnvironmentEntry(ctx context.Context, e ProjectEnvironmentEntry) error
+	ReadProjectEnvironmentEntry(ctx context.Context, project, name string) (ProjectEnvironmentEntry, error)
+	ListProjectEnvironmentEntries(ctx context.Context, project string) ([]ProjectEnvironmentEntry, error)
+	DeleteProjectEnvironmentEntry(ctx context.Context, project, name string) error
+	CreateTargetCostAllocationTagsEntry(ctx context.Context, e TargetCostAllocationTagsEntry) error
+	ReadTargetCostAllocationTagsEntry(ctx context.Context, project, target string) (TargetCostAllocationTagsEntry, error)
+	DeleteTargetCostAllocationTagsEntry(ctx context.Context, project, target string) error
+	CreateTargetSoakTimeEntry(ctx context.Context, e TargetSoakTimeEntry) error
+	ReadTargetSoakTimeEntry(ctx context.Context, project, target string) (TargetSoakTimeEntry, error)
+	DeleteTargetSoakTimeEntry(ctx context.Context, project, target string) error
+	CreateTargetTerraformStateEntry(ctx context.Context, e TargetTerraformStateEntry) error
+	ReadTargetTerraformStateEntry(ctx context.Context, project, target string) (TargetTerraformStateEntry, error)
+	DeleteTargetTerraformStateEntry(ctx context.Context, project, target string) error
+	CreateProjectElevationEntry(ctx context.Context, e ProjectElevationEntry) error
+	ReadProjectElevationEntry(ctx context.Context, id string) (ProjectElevationEntry, error)
+	ListProjectElevationEntries(ctx context.Context, project string) ([]ProjectElevationEntry, error)
+	GrantProjectElevationEntry(ctx context.Context, id, grantedBy string, grantedAt, expiresAt time.Time) error
+	RevokeProjectElevationEntry(ctx context.Context, id string, revokedAt time.Time) error
+	CreateFrameworkImageEntry(ctx context.Context, e FrameworkImageEntry) error
+	ReadFrameworkImageEntry(ctx context.Context, id string) (FrameworkImageEntry, error)
+	ListFrameworkImageEntries(ctx context.Context, framework string) ([]FrameworkImageEntry, error)
+	PromoteFrameworkImageEntry(ctx context.Context, id, promotedBy string, promotedAt time.Time) error
+	AbortFrameworkImageEntry(ctx context.Context, id, abortedBy string, abortedAt time.Time) error
+	CreateExecutionAttestationEntry(ctx context.Context, e ExecutionAttestationEntry) error
+	ReadExecutionAttestationEntry(ctx context.Context, workflowName string) (ExecutionAttestationEntry, error)
+	CreateTargetSubmissionSourceEntry(ctx context.Context, e TargetSubmissionSourceEntry) error
+	ReadTargetSubmissionSourceEntry(ctx context.Context, project, target, source string) (TargetSubmissionSourceEntry, error)
+	CreateTargetDependencyEntry(ctx context.Context, e TargetDependencyEntry) error
+	ListTargetDependencyEntries(ctx context.Context) ([]TargetDependencyEntry, error)
+	DeleteTargetDependencyEntries(ctx context.Context, project, target string) error
+	CreateRolloutEntry(ctx context.Context, e RolloutEntry) error
+	ReadRolloutEntry(ctx context.Context, id string) (RolloutEntry, error)
+	ListRolloutEntries(ctx context.Context, status string) ([]RolloutEntry, error)
+	UpdateRolloutEntry(ctx context.Context, e RolloutEntry) error
+	CreateExecutionNoteEntry(ctx context.Context, e ExecutionNoteEntry) error
+	ListExecutionNoteEntries(ctx context.Context, project, target string) ([]ExecutionNoteEntry, error)
+	CreateProjectWebhookEntry(ctx context.Context, e ProjectWebhookEntry) error
+	ReadProjectWebhookEntry(ctx context.Context, project, id string) (ProjectWebhookEntry, error)
+	ListProjectWebhookEntries(ctx context.Context, project string) ([]ProjectWebhookEntry, error)
+	DeleteProjectWebhookEntry(ctx context.Context, project, id string) error
+	CreateWebhookDeliveryEntry(ctx context.Context, e WebhookDeliveryEntry) error
+	ListWebhookDeliveryEntries(ctx context.Context, webhookID string, limit int) ([]WebhookDeliveryEntry, error)
+	ListDueWebhookDeliveryEntries(ctx context.Context, now time.Time) ([]WebhookDeliveryEntry, error)
+	UpdateWebhookDeliveryEntry(ctx context.Context, e WebhookDeliveryEntry) error
+	CreateProjectEncryptionKeyEntry(ctx context.Context, e ProjectEncryptionKeyEntry) error
+	ReadProjectEncryptionKeyEntry(ctx context.Context, project string) (ProjectEncryptionKeyEntry, error)
+	DeleteProjectEncryptionKeyEntry(ctx context.Context, project string) error
+	CreateResourceIDEntry(ctx context.Context, e ResourceIDEntry) error
+	ReadResourceIDEntry(ctx context.Context, kind, project, target string) (ResourceIDEntry, error)
+	DeleteResourceIDEntry(ctx context.Context, kind, project, target string) error
+	ListConcurrencyBuckets(ctx context.Context, start time.Time, buckets int, bucket, maxRunning time.Duration) ([]ConcurrencyBucket, error)
+	RenameProjectEntry(ctx context.Context, from, to string, aliasExpiresAt time.Time) error
+	ReadProjectAliasEntry(ctx context.Context, alias string) (ProjectAliasEntry, error)
+	ListProjectAliasEntries(ctx context.Context, project string) ([]ProjectAliasEntry, error)
+	MoveTargetEntry(ctx context.Context, from, to, target string) error
 }
 
 // SQLClient allows for db crud operations using postgres db
 type SQLClient struct {
-	host     string
-	database string
-	user     string
-	password string
+	primary    *pool
+	replica    *replica
+	poolConfig PoolConfig
+}
+
+const (
+	ProjectEntryDB           = "projects"
+	ExecutionEventsDB        = "execution_events"
+	TargetGuardrailDB        = "target_guardrails"
+	TargetChangeControlDB    = "target_change_controls"
+	NotificationRuleDB       = "project_notification_rules"
+	BusinessHoursDB          = "project_business_hours"
+	WorkflowTemplateDB       = "project_workflow_templates"
+	FeatureFlagDB            = "project_feature_flags"
+	RegistryCredentialDB     = "project_registry_credentials"
+	TargetScheduleDB         = "target_schedules"
+	TargetSchedulingDB       = "target_scheduling"
+	TargetWorkloadIdentityDB = "target_workload_identities"
+	RunTokenDB               = "run_tokens"
+	ChangeSetSummaryDB       = "target_change_set_summaries"
+	PlanSummaryDB            = "target_plan_summaries"
+	TargetInventoryDB        = "target_inventories"
+	BreakGlassDB             = "target_break_glass"
+	ProjectSettingsDB        = "project_settings"
+	CostAllocationTagsDB     = "target_cost_allocation_tags"
+	ExecutionAttestationDB   = "execution_attestations"
+	SubmissionSourceDB       = "target_submission_sources"
+	TargetDependencyDB       = "target_dependencies"
+	RolloutDB                = "rollouts"
+	ExecutionNoteDB          = "execution_notes"
+	WebhookDB                = "project_webhooks"
+	WebhookDeliveryDB        = "webhook_deliveries"
+	EncryptionKeyDB          = "project_encryption_keys"
+	ResourceIDDB             = "resource_ids"
+	ProjectAliasDB           = "project_aliases"
+	EnvironmentDB            = "project_environments"
+	SoakTimeDB               = "target_soak_times"
+	TerraformStateDB         = "target_terraform_states"
+	ElevationDB              = "project_elevations"
+	FrameworkImageDB         = "framework_images"
+)
+
+// projectTables are the tables with entries of projects, renamed with them.
+var projectTables = []string{
+	ProjectEntryDB,
+	ExecutionEventsDB,
+	TargetGuardrailDB,
+	TargetChangeControlDB,
+	NotificationRuleDB,
+	BusinessHoursDB,
+	WorkflowTemplateDB,
+	FeatureFlagDB,
+	RegistryCredentialDB,
+	TargetScheduleDB,
+	TargetSchedulingDB,
+	TargetWorkloadIdentityDB,
+	RunTokenDB,
+	ChangeSetSummaryDB,
+	PlanSummaryDB,
+	TargetInventoryDB,
+	BreakGlassDB,
+	ProjectSettingsDB,
+	CostAllocationTagsDB,
+	ExecutionAttestationDB,
+	SubmissionSourceDB,
+	TargetDependencyDB,
+	ExecutionNoteDB,
+	WebhookDB,
+	WebhookDeliveryDB,
+	EncryptionKeyDB,
+	ResourceIDDB,
+	EnvironmentDB,
+	SoakTimeDB,
+	TerraformStateDB,
+	ElevationDB,
 }
 
-const ProjectEntryDB = "projects"
+// targetTables are the tables with entries of targets, moved with them.
+var targetTables = []string{
+	ExecutionEventsDB,
+	TargetGuardrailDB,
+	TargetChangeControlDB,
+	TargetScheduleDB,
+	TargetSchedulingDB,
+	TargetWorkloadIdentityDB,
+	RunTokenDB,
+	ChangeSetSummaryDB,
+	PlanSummaryDB,
+	TargetInventoryDB,
+	BreakGlassDB,
+	CostAllocationTagsDB,
+	ExecutionAttestationDB,
+	SubmissionSourceDB,
+	TargetDependencyDB,
+	ExecutionNoteDB,
+	ResourceIDDB,
+	SoakTimeDB,
+	TerraformStateDB,
+}
+
+func NewSQLClient(host, database, user, password string, opts ...Option) (SQLClient, error) {
+	var d SQLClient
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	d.primary = newPool(primaryDB, postgresql.ConnectionURL{
+		Host:     host,
+		Database: database,
+		User:     user,
+		Password: password,
+	}, d.poolConfig)
 
-func NewSQLClient(host, database, user, password string) (SQLClient, error) {
-	return SQLClient{
-		host:     host,
-		database: database,
-		user:     user,
-		password: password,
-	}, nil
+	if d.replica != nil {
+		settings, err := postgresql.ParseURL(d.replica.dsn)
+		if err != nil {
+			// The error would include the DSN and so its password.
+			return SQLClient{}, errors.New("invalid read replica dsn")
+		}
+		d.replica.pool = newPool(replicaDB, settings, d.poolConfig)
+	}
+	return d, nil
 }
 
+// createSession returns the session of the primary, which is shared so
+// closing it doesn't close its connections.
 func (d SQLClient) createSession() (db.Session, error) {
-	settings := postgresql.ConnectionURL{
-		Host:     d.host,
-		Database: d.database,
-		User:     d.user,
-		Password: d.password,
+	return d.primary.session()
+}
+
+// Ping pings the primary, failing when it's unreachable or the credentials
+// are invalid.
+func (d SQLClient) Ping(ctx context.Context) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
 	}
+	defer sess.Close()
 
-	return postgresql.Open(settings)
+	return sess.WithContext(ctx).Ping()
 }
 
 func (d SQLClient) CreateProjectEntry(ctx context.Context, pe ProjectEntry) error {
@@ -82,6 +704,20 @@ func (d SQLClient) ReadProjectEntry(ctx context.Context, project string) (Projec
 	return res, err
 }
 
+// ListProjectEntries reads from the read replica, if any.
+func (d SQLClient) ListProjectEntries(ctx context.Context) ([]ProjectEntry, error) {
+	res := []ProjectEntry{}
+
+	sess, err := d.readSession(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ProjectEntryDB).Find().OrderBy("project").All(&res)
+	return res, err
+}
+
 func (d SQLClient) DeleteProjectEntry(ctx context.Context, project string) error {
 	sess, err := d.createSession()
 	if err != nil {
@@ -91,3 +727,1552 @@ func (d SQLClient) DeleteProjectEntry(ctx context.Context, project string) error
 
 	return sess.WithContext(ctx).Collection(ProjectEntryDB).Find("project", project).Delete()
 }
+
+func (d SQLClient) CreateExecutionEvent(ctx context.Context, ee ExecutionEvent) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(ExecutionEventsDB).Insert(ee)
+	return err
+}
+
+func (d SQLClient) CreateTargetGuardrailEntry(ctx context.Context, tg TargetGuardrailEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetGuardrailDB).Find("project", tg.Project).And("target", tg.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetGuardrailDB).Insert(tg); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetGuardrailEntry(ctx context.Context, project, target string) (TargetGuardrailEntry, error) {
+	res := TargetGuardrailEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetGuardrailDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetGuardrailEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetGuardrailDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateTargetChangeControlEntry(ctx context.Context, tc TargetChangeControlEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetChangeControlDB).Find("project", tc.Project).And("target", tc.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetChangeControlDB).Insert(tc); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetChangeControlEntry(ctx context.Context, project, target string) (TargetChangeControlEntry, error) {
+	res := TargetChangeControlEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetChangeControlDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetChangeControlEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetChangeControlDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateProjectNotificationRuleEntry(ctx context.Context, nr ProjectNotificationRuleEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(NotificationRuleDB).Find("project", nr.Project).And("type", nr.Type).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(NotificationRuleDB).Insert(nr); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ListProjectNotificationRuleEntries(ctx context.Context, project string) ([]ProjectNotificationRuleEntry, error) {
+	res := []ProjectNotificationRuleEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(NotificationRuleDB).Find("project", project).OrderBy("type").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectNotificationRuleEntry(ctx context.Context, project, ruleType string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(NotificationRuleDB).Find("project", project).And("type", ruleType).Delete()
+}
+
+func (d SQLClient) CreateProjectBusinessHoursEntry(ctx context.Context, bh ProjectBusinessHoursEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(BusinessHoursDB).Find("project", bh.Project).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(BusinessHoursDB).Insert(bh); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadProjectBusinessHoursEntry(ctx context.Context, project string) (ProjectBusinessHoursEntry, error) {
+	res := ProjectBusinessHoursEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(BusinessHoursDB).Find("project", project).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectBusinessHoursEntry(ctx context.Context, project string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(BusinessHoursDB).Find("project", project).Delete()
+}
+
+func (d SQLClient) CreateProjectWorkflowTemplateEntry(ctx context.Context, wt ProjectWorkflowTemplateEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(WorkflowTemplateDB).Find("project", wt.Project).And("kind", wt.Kind).And("name", wt.Name).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(WorkflowTemplateDB).Insert(wt); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ListProjectWorkflowTemplateEntries(ctx context.Context, project string) ([]ProjectWorkflowTemplateEntry, error) {
+	res := []ProjectWorkflowTemplateEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(WorkflowTemplateDB).Find("project", project).OrderBy("kind", "name").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectWorkflowTemplateEntry(ctx context.Context, project, kind, name string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(WorkflowTemplateDB).Find("project", project).And("kind", kind).And("name", name).Delete()
+}
+
+func (d SQLClient) CreateProjectFeatureFlagEntry(ctx context.Context, ff ProjectFeatureFlagEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(FeatureFlagDB).Find("project", ff.Project).And("name", ff.Name).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(FeatureFlagDB).Insert(ff); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ListProjectFeatureFlagEntries(ctx context.Context, project string) ([]ProjectFeatureFlagEntry, error) {
+	res := []ProjectFeatureFlagEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(FeatureFlagDB).Find("project", project).OrderBy("name").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectFeatureFlagEntry(ctx context.Context, project, name string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(FeatureFlagDB).Find("project", project).And("name", name).Delete()
+}
+
+func (d SQLClient) CreateProjectRegistryCredentialEntry(ctx context.Context, rc ProjectRegistryCredentialEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(RegistryCredentialDB).Find("project", rc.Project).And("registry", rc.Registry).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(RegistryCredentialDB).Insert(rc); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ListProjectRegistryCredentialEntries(ctx context.Context, project string) ([]ProjectRegistryCredentialEntry, error) {
+	res := []ProjectRegistryCredentialEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(RegistryCredentialDB).Find("project", project).OrderBy("registry").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectRegistryCredentialEntry(ctx context.Context, project, registry string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(RegistryCredentialDB).Find("project", project).And("registry", registry).Delete()
+}
+
+func (d SQLClient) CreateTargetScheduleEntry(ctx context.Context, ts TargetScheduleEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetScheduleDB).Find("project", ts.Project).And("target", ts.Target).And("name", ts.Name).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetScheduleDB).Insert(ts); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ListTargetScheduleEntries(ctx context.Context, project, target string) ([]TargetScheduleEntry, error) {
+	res := []TargetScheduleEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetScheduleDB).Find("project", project).And("target", target).OrderBy("name").All(&res)
+	return res, err
+}
+
+func (d SQLClient) ListScheduleEntries(ctx context.Context) ([]TargetScheduleEntry, error) {
+	res := []TargetScheduleEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetScheduleDB).Find().OrderBy("project", "target", "name").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetScheduleEntry(ctx context.Context, project, target, name string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetScheduleDB).Find("project", project).And("target", target).And("name", name).Delete()
+}
+
+func (d SQLClient) CreateTargetSchedulingEntry(ctx context.Context, e TargetSchedulingEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetSchedulingDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetSchedulingDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetSchedulingEntry(ctx context.Context, project, target string) (TargetSchedulingEntry, error) {
+	res := TargetSchedulingEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetSchedulingDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetSchedulingEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetSchedulingDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateTargetWorkloadIdentityEntry(ctx context.Context, e TargetWorkloadIdentityEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetWorkloadIdentityDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetWorkloadIdentityDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetWorkloadIdentityEntry(ctx context.Context, project, target string) (TargetWorkloadIdentityEntry, error) {
+	res := TargetWorkloadIdentityEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetWorkloadIdentityDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetWorkloadIdentityEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetWorkloadIdentityDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateRunTokenEntry(ctx context.Context, rt RunTokenEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(RunTokenDB).Insert(rt)
+	return err
+}
+
+func (d SQLClient) ListRunTokenEntries(ctx context.Context) ([]RunTokenEntry, error) {
+	res := []RunTokenEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(RunTokenDB).Find().OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteRunTokenEntry(ctx context.Context, workflowName string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(RunTokenDB).Find("workflow_name", workflowName).Delete()
+}
+
+func (d SQLClient) CreateChangeSetSummaryEntry(ctx context.Context, cs ChangeSetSummaryEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(ChangeSetSummaryDB).Find("project", cs.Project).And("target", cs.Target).And("stack_name", cs.StackName).And("change_set_name", cs.ChangeSetName).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(ChangeSetSummaryDB).Insert(cs); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadChangeSetSummaryEntry(ctx context.Context, project, target, stackName, changeSetName string) (ChangeSetSummaryEntry, error) {
+	res := ChangeSetSummaryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ChangeSetSummaryDB).Find("project", project).And("target", target).And("stack_name", stackName).And("change_set_name", changeSetName).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteChangeSetSummaryEntries(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(ChangeSetSummaryDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreatePlanSummaryEntry(ctx context.Context, e PlanSummaryEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(PlanSummaryDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadPlanSummaryEntry(ctx context.Context, workflowName string) (PlanSummaryEntry, error) {
+	res := PlanSummaryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(PlanSummaryDB).Find("workflow_name", workflowName).One(&res)
+	return res, err
+}
+
+// ReadCommitPlanSummaryEntry returns the latest plan summary of the diffs of
+// the commit to the target.
+func (d SQLClient) ReadCommitPlanSummaryEntry(ctx context.Context, project, target, commitHash string) (PlanSummaryEntry, error) {
+	res := PlanSummaryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(PlanSummaryDB).Find("project", project).And("target", target).And("commit_hash", commitHash).OrderBy("-created_at").One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeletePlanSummaryEntries(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(PlanSummaryDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateTargetInventoryEntry(ctx context.Context, e TargetInventoryEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TargetInventoryDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TargetInventoryDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetInventoryEntry(ctx context.Context, project, target string) (TargetInventoryEntry, error) {
+	res := TargetInventoryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetInventoryDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetInventoryEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetInventoryDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateBreakGlassEntry(ctx context.Context, e BreakGlassEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(BreakGlassDB).Insert(e)
+	return err
+}
+
+// ListBreakGlassEntries reads from the read replica, if any.
+func (d SQLClient) ListBreakGlassEntries(ctx context.Context, project, target string) ([]BreakGlassEntry, error) {
+	res := []BreakGlassEntry{}
+
+	sess, err := d.readSession(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(BreakGlassDB).Find("project", project).And("target", target).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) ListUnrevokedBreakGlassEntries(ctx context.Context) ([]BreakGlassEntry, error) {
+	res := []BreakGlassEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(BreakGlassDB).Find(db.Cond{"revoked_at IS": nil}).OrderBy("expires_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) RevokeBreakGlassEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(BreakGlassDB).Find("id", id).Update(map[string]interface{}{"revoked_at": revokedAt})
+}
+
+func (d SQLClient) CreateProjectSettingsEntry(ctx context.Context, e ProjectSettingsEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(ProjectSettingsDB).Find("project", e.Project).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(ProjectSettingsDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadProjectSettingsEntry(ctx context.Context, project string) (ProjectSettingsEntry, error) {
+	res := ProjectSettingsEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ProjectSettingsDB).Find("project", project).One(&res)
+	return res, err
+}
+
+func (d SQLClient) CreateProjectEnvironmentEntry(ctx context.Context, e ProjectEnvironmentEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(EnvironmentDB).Find("project", e.Project).And("name", e.Name).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(EnvironmentDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadProjectEnvironmentEntry(ctx context.Context, project, name string) (ProjectEnvironmentEntry, error) {
+	res := ProjectEnvironmentEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(EnvironmentDB).Find("project", project).And("name", name).One(&res)
+	return res, err
+}
+
+func (d SQLClient) ListProjectEnvironmentEntries(ctx context.Context, project string) ([]ProjectEnvironmentEntry, error) {
+	res := []ProjectEnvironmentEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(EnvironmentDB).Find("project", project).OrderBy("name").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectEnvironmentEntry(ctx context.Context, project, name string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(EnvironmentDB).Find("project", project).And("name", name).Delete()
+}
+
+func (d SQLClient) CreateTargetCostAllocationTagsEntry(ctx context.Context, e TargetCostAllocationTagsEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(CostAllocationTagsDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(CostAllocationTagsDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetCostAllocationTagsEntry(ctx context.Context, project, target string) (TargetCostAllocationTagsEntry, error) {
+	res := TargetCostAllocationTagsEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(CostAllocationTagsDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetCostAllocationTagsEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(CostAllocationTagsDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateTargetSoakTimeEntry(ctx context.Context, e TargetSoakTimeEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(SoakTimeDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(SoakTimeDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetSoakTimeEntry(ctx context.Context, project, target string) (TargetSoakTimeEntry, error) {
+	res := TargetSoakTimeEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(SoakTimeDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetSoakTimeEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(SoakTimeDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateTargetTerraformStateEntry(ctx context.Context, e TargetTerraformStateEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(TerraformStateDB).Find("project", e.Project).And("target", e.Target).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(TerraformStateDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadTargetTerraformStateEntry(ctx context.Context, project, target string) (TargetTerraformStateEntry, error) {
+	res := TargetTerraformStateEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TerraformStateDB).Find("project", project).And("target", target).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetTerraformStateEntry(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TerraformStateDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateProjectElevationEntry(ctx context.Context, e ProjectElevationEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(ElevationDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadProjectElevationEntry(ctx context.Context, id string) (ProjectElevationEntry, error) {
+	res := ProjectElevationEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ElevationDB).Find("id", id).One(&res)
+	return res, err
+}
+
+// ListProjectElevationEntries reads from the read replica, if any.
+func (d SQLClient) ListProjectElevationEntries(ctx context.Context, project string) ([]ProjectElevationEntry, error) {
+	res := []ProjectElevationEntry{}
+
+	sess, err := d.readSession(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ElevationDB).Find("project", project).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) GrantProjectElevationEntry(ctx context.Context, id, grantedBy string, grantedAt, expiresAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(ElevationDB).Find("id", id).Update(map[string]interface{}{
+		"granted_by": grantedBy,
+		"granted_at": grantedAt,
+		"expires_at": expiresAt,
+	})
+}
+
+func (d SQLClient) RevokeProjectElevationEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(ElevationDB).Find("id", id).Update(map[string]interface{}{"revoked_at": revokedAt})
+}
+
+func (d SQLClient) CreateFrameworkImageEntry(ctx context.Context, e FrameworkImageEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(FrameworkImageDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadFrameworkImageEntry(ctx context.Context, id string) (FrameworkImageEntry, error) {
+	res := FrameworkImageEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(FrameworkImageDB).Find("id", id).One(&res)
+	return res, err
+}
+
+func (d SQLClient) ListFrameworkImageEntries(ctx context.Context, framework string) ([]FrameworkImageEntry, error) {
+	res := []FrameworkImageEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(FrameworkImageDB).Find("framework", framework).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) PromoteFrameworkImageEntry(ctx context.Context, id, promotedBy string, promotedAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(FrameworkImageDB).Find("id", id).Update(map[string]interface{}{
+		"promoted_by": promotedBy,
+		"promoted_at": promotedAt,
+	})
+}
+
+func (d SQLClient) AbortFrameworkImageEntry(ctx context.Context, id, abortedBy string, abortedAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(FrameworkImageDB).Find("id", id).Update(map[string]interface{}{
+		"aborted_by": abortedBy,
+		"aborted_at": abortedAt,
+	})
+}
+
+func (d SQLClient) CreateExecutionAttestationEntry(ctx context.Context, e ExecutionAttestationEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(ExecutionAttestationDB).Insert(e)
+	return err
+}
+
+// ReadExecutionAttestationEntry reads from the read replica, if any.
+func (d SQLClient) ReadExecutionAttestationEntry(ctx context.Context, workflowName string) (ExecutionAttestationEntry, error) {
+	res := ExecutionAttestationEntry{}
+
+	sess, err := d.readSession(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ExecutionAttestationDB).Find("workflow_name", workflowName).One(&res)
+	return res, err
+}
+
+func (d SQLClient) CreateTargetSubmissionSourceEntry(ctx context.Context, e TargetSubmissionSourceEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(SubmissionSourceDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadTargetSubmissionSourceEntry(ctx context.Context, project, target, source string) (TargetSubmissionSourceEntry, error) {
+	res := TargetSubmissionSourceEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(SubmissionSourceDB).Find("project", project).And("target", target).And("source", source).One(&res)
+	return res, err
+}
+
+func (d SQLClient) CreateTargetDependencyEntry(ctx context.Context, e TargetDependencyEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(TargetDependencyDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ListTargetDependencyEntries(ctx context.Context) ([]TargetDependencyEntry, error) {
+	res := []TargetDependencyEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(TargetDependencyDB).Find().OrderBy("project", "target", "depends_on_project", "depends_on_target").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteTargetDependencyEntries(ctx context.Context, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(TargetDependencyDB).Find("project", project).And("target", target).Delete()
+}
+
+func (d SQLClient) CreateRolloutEntry(ctx context.Context, e RolloutEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(RolloutDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadRolloutEntry(ctx context.Context, id string) (RolloutEntry, error) {
+	res := RolloutEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(RolloutDB).Find("id", id).One(&res)
+	return res, err
+}
+
+func (d SQLClient) ListRolloutEntries(ctx context.Context, status string) ([]RolloutEntry, error) {
+	res := []RolloutEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(RolloutDB).Find("status", status).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) UpdateRolloutEntry(ctx context.Context, e RolloutEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(RolloutDB).Find("id", e.ID).Update(map[string]interface{}{
+		"status":     e.Status,
+		"steps":      e.Steps,
+		"updated_at": e.UpdatedAt,
+	})
+}
+
+func (d SQLClient) CreateExecutionNoteEntry(ctx context.Context, e ExecutionNoteEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(ExecutionNoteDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ListExecutionNoteEntries(ctx context.Context, project, target string) ([]ExecutionNoteEntry, error) {
+	res := []ExecutionNoteEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ExecutionNoteDB).Find("project", project).And("target", target).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) CreateProjectWebhookEntry(ctx context.Context, e ProjectWebhookEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(WebhookDB).Insert(e)
+	return err
+}
+
+func (d SQLClient) ReadProjectWebhookEntry(ctx context.Context, project, id string) (ProjectWebhookEntry, error) {
+	res := ProjectWebhookEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(WebhookDB).Find("project", project).And("id", id).One(&res)
+	return res, err
+}
+
+func (d SQLClient) ListProjectWebhookEntries(ctx context.Context, project string) ([]ProjectWebhookEntry, error) {
+	res := []ProjectWebhookEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(WebhookDB).Find("project", project).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectWebhookEntry(ctx context.Context, project, id string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(WebhookDB).Find("project", project).And("id", id).Delete()
+}
+
+func (d SQLClient) CreateWebhookDeliveryEntry(ctx context.Context, e WebhookDeliveryEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(WebhookDeliveryDB).Insert(e)
+	return err
+}
+
+// ListWebhookDeliveryEntries returns the latest deliveries of the webhook,
+// newest first.
+func (d SQLClient) ListWebhookDeliveryEntries(ctx context.Context, webhookID string, limit int) ([]WebhookDeliveryEntry, error) {
+	res := []WebhookDeliveryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(WebhookDeliveryDB).Find("webhook_id", webhookID).OrderBy("-created_at").Limit(limit).All(&res)
+	return res, err
+}
+
+// ListDueWebhookDeliveryEntries returns the pending deliveries whose next
+// attempt is due at now, oldest first.
+func (d SQLClient) ListDueWebhookDeliveryEntries(ctx context.Context, now time.Time) ([]WebhookDeliveryEntry, error) {
+	res := []WebhookDeliveryEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(WebhookDeliveryDB).Find(db.Cond{"status": "pending", "next_attempt_at <=": now}).OrderBy("created_at").All(&res)
+	return res, err
+}
+
+func (d SQLClient) UpdateWebhookDeliveryEntry(ctx context.Context, e WebhookDeliveryEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(WebhookDeliveryDB).Find("id", e.ID).Update(map[string]interface{}{
+		"status":          e.Status,
+		"attempts":        e.Attempts,
+		"response_code":   e.ResponseCode,
+		"error":           e.Error,
+		"next_attempt_at": e.NextAttemptAt,
+		"updated_at":      e.UpdatedAt,
+	})
+}
+
+// CreateProjectEncryptionKeyEntry replaces the encryption key of the project.
+func (d SQLClient) CreateProjectEncryptionKeyEntry(ctx context.Context, e ProjectEncryptionKeyEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		if err := sess.Collection(EncryptionKeyDB).Find("project", e.Project).Delete(); err != nil {
+			return err
+		}
+
+		if _, err = sess.Collection(EncryptionKeyDB).Insert(e); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d SQLClient) ReadProjectEncryptionKeyEntry(ctx context.Context, project string) (ProjectEncryptionKeyEntry, error) {
+	res := ProjectEncryptionKeyEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(EncryptionKeyDB).Find("project", project).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteProjectEncryptionKeyEntry(ctx context.Context, project string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(EncryptionKeyDB).Find("project", project).Delete()
+}
+
+// CreateResourceIDEntry inserts the ID of a resource, failing when the
+// resource already has one.
+func (d SQLClient) CreateResourceIDEntry(ctx context.Context, e ResourceIDEntry) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	_, err = sess.WithContext(ctx).Collection(ResourceIDDB).Insert(e)
+	return err
+}
+
+// ReadResourceIDEntry reads from the primary, so IDs are read after they're
+// written.
+func (d SQLClient) ReadResourceIDEntry(ctx context.Context, kind, project, target string) (ResourceIDEntry, error) {
+	res := ResourceIDEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ResourceIDDB).Find(db.Cond{"kind": kind, "project": project, "target": target}).One(&res)
+	return res, err
+}
+
+func (d SQLClient) DeleteResourceIDEntry(ctx context.Context, kind, project, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Collection(ResourceIDDB).Find(db.Cond{"kind": kind, "project": project, "target": target}).Delete()
+}
+
+// RenameProjectEntry renames the project in the entries of every table in a
+// single transaction, keeping the former name as an alias of the project
+// until aliasExpiresAt. Aliases of the project are moved to its new name, and
+// an alias named like the new name is removed.
+func (d SQLClient) RenameProjectEntry(ctx context.Context, from, to string, aliasExpiresAt time.Time) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		for _, table := range projectTables {
+			if err := sess.Collection(table).Find("project", from).Update(map[string]interface{}{"project": to}); err != nil {
+				return err
+			}
+		}
+		if err := sess.Collection(TargetDependencyDB).Find("depends_on_project", from).Update(map[string]interface{}{"depends_on_project": to}); err != nil {
+			return err
+		}
+
+		aliases := sess.Collection(ProjectAliasDB)
+		if err := aliases.Find(db.Cond{"alias IN": []string{from, to}}).Delete(); err != nil {
+			return err
+		}
+		if err := aliases.Find("project", from).Update(map[string]interface{}{"project": to}); err != nil {
+			return err
+		}
+		_, err := aliases.Insert(ProjectAliasEntry{Alias: from, Project: to, ExpiresAt: aliasExpiresAt})
+		return err
+	})
+}
+
+// MoveTargetEntry moves the entries of the target in every table to another
+// project in a single transaction, including the dependencies of other
+// targets on it. The target is removed from the environments of the project,
+// which list their targets by name, and environments left without targets
+// are deleted.
+func (d SQLClient) MoveTargetEntry(ctx context.Context, from, to, target string) error {
+	sess, err := d.createSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.WithContext(ctx).Tx(func(sess db.Session) error {
+		for _, table := range targetTables {
+			if err := sess.Collection(table).Find(db.Cond{"project": from, "target": target}).Update(map[string]interface{}{"project": to}); err != nil {
+				return err
+			}
+		}
+		if err := sess.Collection(TargetDependencyDB).Find(db.Cond{"depends_on_project": from, "depends_on_target": target}).Update(map[string]interface{}{"depends_on_project": to}); err != nil {
+			return err
+		}
+
+		envs := []ProjectEnvironmentEntry{}
+		if err := sess.Collection(EnvironmentDB).Find("project", from).All(&envs); err != nil {
+			return err
+		}
+		for _, e := range envs {
+			environment, removed, empty, err := RemoveEnvironmentTarget(e.Environment, target)
+			if err != nil {
+				return err
+			}
+			res := sess.Collection(EnvironmentDB).Find("project", from).And("name", e.Name)
+			switch {
+			case !removed:
+				continue
+			case empty:
+				err = res.Delete()
+			default:
+				err = res.Update(map[string]interface{}{"environment": environment, "updated_at": time.Now()})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveEnvironmentTarget returns the JSON of the environment without the
+// target, whether it had the target and whether it has no targets left. The
+// other fields of the environment are kept as they are.
+func RemoveEnvironmentTarget(environment, target string) (string, bool, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(environment), &fields); err != nil {
+		return "", false, false, fmt.Errorf("invalid environment: %w", err)
+	}
+	var targets []string
+	if err := json.Unmarshal(fields["targets"], &targets); err != nil {
+		return "", false, false, fmt.Errorf("invalid environment targets: %w", err)
+	}
+
+	kept := []string{}
+	for _, t := range targets {
+		if t != target {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(targets) {
+		return environment, false, len(kept) == 0, nil
+	}
+
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return "", false, false, err
+	}
+	fields["targets"] = data
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return "", false, false, err
+	}
+	return string(result), true, len(kept) == 0, nil
+}
+
+// ReadProjectAliasEntry reads from the primary, so renamed projects are
+// resolved right after they're renamed. Expired aliases are read too.
+func (d SQLClient) ReadProjectAliasEntry(ctx context.Context, alias string) (ProjectAliasEntry, error) {
+	res := ProjectAliasEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ProjectAliasDB).Find("alias", alias).One(&res)
+	return res, err
+}
+
+func (d SQLClient) ListProjectAliasEntries(ctx context.Context, project string) ([]ProjectAliasEntry, error) {
+	res := []ProjectAliasEntry{}
+
+	sess, err := d.createSession()
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	err = sess.WithContext(ctx).Collection(ProjectAliasDB).Find("project", project).OrderBy("alias").All(&res)
+	return res, err
+}
+
+// concurrencyQuery counts the workflows running during each bucket by target.
+// Workflows run from their successful submission to the revocation of their
+// credentials on completion, or for the max running duration when their
+// completion wasn't recorded. Args are the start of the window, its end, the
+// bucket and the max running duration, durations in seconds.
+const concurrencyQuery = `WITH runs AS (
+	SELECT s.project, s.target, s.workflow_name, MIN(s.created_at) AS started_at,
+		COALESCE(MIN(f.created_at), MIN(s.created_at) + make_interval(secs => ?)) AS finished_at
+	FROM execution_events s
+	LEFT JOIN execution_events f ON f.workflow_name = s.workflow_name AND f.type = 'credentials_revoked'
+	WHERE s.type = 'submit_attempt' AND s.workflow_name <> '' AND s.message LIKE '% succeeded'
+		AND s.created_at < ?::timestamptz AND s.created_at > ?::timestamptz - make_interval(secs => ?)
+	GROUP BY s.project, s.target, s.workflow_name
+)
+SELECT b.bucket, r.project, r.target, COUNT(*) AS running
+FROM generate_series(?::timestamptz, ?::timestamptz - make_interval(secs => ?), make_interval(secs => ?)) AS b(bucket)
+JOIN runs r ON r.started_at < b.bucket + make_interval(secs => ?) AND r.finished_at > b.bucket
+GROUP BY b.bucket, r.project, r.target
+ORDER BY b.bucket, r.project, r.target`
+
+// ListConcurrencyBuckets computes the workflows running by target during the
+// buckets of the window from the execution events, leaving out buckets of
+// targets without any. It reads from the read replica, if any.
+func (d SQLClient) ListConcurrencyBuckets(ctx context.Context, start time.Time, buckets int, bucket, maxRunning time.Duration) ([]ConcurrencyBucket, error) {
+	res := []ConcurrencyBucket{}
+
+	sess, err := d.readSession(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer sess.Close()
+
+	end := start.Add(time.Duration(buckets) * bucket)
+	rows, err := sess.WithContext(ctx).SQL().QueryContext(ctx, concurrencyQuery,
+		maxRunning.Seconds(),
+		end, start, maxRunning.Seconds(),
+		start, end, bucket.Seconds(), bucket.Seconds(),
+		bucket.Seconds(),
+	)
+	if err != nil {
+		return res, err
+	}
+	err = sess.SQL().NewIteratorContext(ctx, rows).All(&res)
+	return res, err
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveEnvironmentTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		want        string
+		wantRemoved bool
+		wantEmpty   bool
+		wantErr     bool
+	}{
+		{
+			name:        "removed",
+			environment: `{"targets":["target1","target2"],"parameters":{"region":"us-west-2"}}`,
+			want:        `{"parameters":{"region":"us-west-2"},"targets":["target2"]}`,
+			wantRemoved: true,
+		},
+		{
+			name:        "last target",
+			environment: `{"targets":["target1"],"freeze_windows":[]}`,
+			want:        `{"freeze_windows":[],"targets":[]}`,
+			wantRemoved: true,
+			wantEmpty:   true,
+		},
+		{
+			name:        "other targets",
+			environment: `{"targets":["target2"]}`,
+			want:        `{"targets":["target2"]}`,
+		},
+		{
+			name:        "invalid",
+			environment: `{"targets":"target1"}`,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed, empty, err := RemoveEnvironmentTarget(tt.environment, "target1")
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantRemoved, removed)
+			assert.Equal(t, tt.wantEmpty, empty)
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/postgresql"
+)
+
+// reconnectInitialBackoff is the delay before reconnecting to a database
+// which failed its health check, doubled each failure up to the max backoff.
+const reconnectInitialBackoff = time.Second
+
+// Names of the databases in logs and metrics.
+const (
+	primaryDB = "primary"
+	replicaDB = "replica"
+)
+
+var (
+	dbUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cello",
+		Name:      "db_up",
+		Help:      "Whether the database (primary or replica) passed its last health check.",
+	}, []string{"db"})
+	dbReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cello",
+		Name:      "db_reconnects_total",
+		Help:      "Reconnections to the database (primary or replica) after failed health checks.",
+	}, []string{"db"})
+	dbConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cello",
+		Name:      "db_connections",
+		Help:      "Connections of the pool of the database (primary or replica), by state (in_use or idle).",
+	}, []string{"db", "state"})
+)
+
+func init() {
+	prometheus.MustRegister(dbUp, dbReconnects, dbConnections)
+}
+
+// PoolConfig configures the connection pools of the primary and the read
+// replica. Zero values keep the defaults of database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// WithPool configures the connection pools.
+func WithPool(cfg PoolConfig) Option {
+	return func(d *SQLClient) {
+		d.poolConfig = cfg
+	}
+}
+
+// pool is a session of a database shared by all calls, connected when first
+// used and again once it failed a health check.
+type pool struct {
+	name     string
+	settings postgresql.ConnectionURL
+	cfg      PoolConfig
+	// open opens sessions, replaced in tests.
+	open func(postgresql.ConnectionURL) (db.Session, error)
+
+	mu   sync.Mutex
+	sess db.Session
+}
+
+func newPool(name string, settings postgresql.ConnectionURL, cfg PoolConfig) *pool {
+	return &pool{
+		name:     name,
+		settings: settings,
+		cfg:      cfg,
+		open:     func(s postgresql.ConnectionURL) (db.Session, error) { return postgresql.Open(s) },
+	}
+}
+
+// sharedSession is a session of a pool, which closing doesn't close.
+type sharedSession struct {
+	db.Session
+}
+
+func (sharedSession) Close() error {
+	return nil
+}
+
+// session returns the session of the pool, connecting when it isn't.
+func (p *pool) session() (db.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sess == nil {
+		sess, err := p.open(p.settings)
+		if err != nil {
+			return nil, err
+		}
+		if p.cfg.MaxOpenConns > 0 {
+			sess.SetMaxOpenConns(p.cfg.MaxOpenConns)
+		}
+		if p.cfg.MaxIdleConns > 0 {
+			sess.SetMaxIdleConns(p.cfg.MaxIdleConns)
+		}
+		if p.cfg.ConnMaxLifetime > 0 {
+			sess.SetConnMaxLifetime(p.cfg.ConnMaxLifetime)
+		}
+		if sqlDB, ok := sess.Driver().(*sql.DB); ok && p.cfg.ConnMaxIdleTime > 0 {
+			sqlDB.SetConnMaxIdleTime(p.cfg.ConnMaxIdleTime)
+		}
+		p.sess = sess
+	}
+	return sharedSession{p.sess}, nil
+}
+
+// check pings the database. Sessions failing the ping are closed, so their
+// connections (e.g. to the primary before a failover) aren't reused, and the
+// database is connected again.
+func (p *pool) check(ctx context.Context) error {
+	p.mu.Lock()
+	sess := p.sess
+	p.mu.Unlock()
+
+	if sess != nil {
+		err := sess.WithContext(ctx).Ping()
+		if err == nil {
+			p.recordStats(sess)
+			return nil
+		}
+		p.reset(sess)
+	}
+
+	sess, err := p.session()
+	if err != nil {
+		return err
+	}
+	return sess.WithContext(ctx).Ping()
+}
+
+// reset closes the session unless it was already replaced.
+func (p *pool) reset(sess db.Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sess == sess {
+		p.sess.Close()
+		p.sess = nil
+	}
+}
+
+func (p *pool) recordStats(sess db.Session) {
+	sqlDB, ok := sess.Driver().(*sql.DB)
+	if !ok {
+		return
+	}
+	stats := sqlDB.Stats()
+	dbConnections.WithLabelValues(p.name, "in_use").Set(float64(stats.InUse))
+	dbConnections.WithLabelValues(p.name, "idle").Set(float64(stats.Idle))
+}
+
+// watch checks the database every interval until the context is done,
+// reconnecting with backoff while it fails.
+func (p *pool) watch(ctx context.Context, interval, maxBackoff time.Duration, logger log.Logger) {
+	failures := 0
+	for {
+		wait := interval
+		if err := p.check(ctx); err != nil {
+			failures++
+			wait = reconnectBackoff(failures, maxBackoff)
+			dbUp.WithLabelValues(p.name).Set(0)
+			level.Error(logger).Log("message", "database health check failed, reconnecting", "db", p.name, "failures", failures, "retry_in", wait, "error", err)
+		} else {
+			if failures > 0 {
+				dbReconnects.WithLabelValues(p.name).Inc()
+				level.Info(logger).Log("message", "database reconnected", "db", p.name, "failures", failures)
+			}
+			failures = 0
+			dbUp.WithLabelValues(p.name).Set(1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reconnectBackoff returns the delay before reconnecting after the number of
+// consecutive failures.
+func reconnectBackoff(failures int, maxBackoff time.Duration) time.Duration {
+	d := reconnectInitialBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if maxBackoff > 0 && d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Watch checks the health of the primary and the read replica every interval
+// until the context is done. Databases failing their health check are
+// connected again, with backoff up to the max backoff, so the service
+// recovers from failovers without a restart.
+func (d SQLClient) Watch(ctx context.Context, interval, maxBackoff time.Duration, logger log.Logger) {
+	if d.replica != nil {
+		go d.replica.pool.watch(ctx, interval, maxBackoff, logger)
+	}
+	d.primary.watch(ctx, interval, maxBackoff, logger)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/upper/db/v4"
+	"github.com/upper/db/v4/adapter/postgresql"
+)
+
+type fakeSession struct {
+	db.Session
+	pingErr      error
+	closed       bool
+	maxOpenConns int
+	maxIdleConns int
+	lifetime     time.Duration
+}
+
+func (s *fakeSession) Ping() error                            { return s.pingErr }
+func (s *fakeSession) Close() error                           { s.closed = true; return nil }
+func (s *fakeSession) WithContext(context.Context) db.Session { return s }
+func (s *fakeSession) Driver() interface{}                    { return nil }
+func (s *fakeSession) SetMaxOpenConns(n int)                  { s.maxOpenConns = n }
+func (s *fakeSession) SetMaxIdleConns(n int)                  { s.maxIdleConns = n }
+func (s *fakeSession) SetConnMaxLifetime(d time.Duration)     { s.lifetime = d }
+
+func TestPoolSession(t *testing.T) {
+	var opened []*fakeSession
+	var openErr error
+	p := newPool(primaryDB, postgresql.ConnectionURL{}, PoolConfig{MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: time.Hour})
+	p.open = func(postgresql.ConnectionURL) (db.Session, error) {
+		if openErr != nil {
+			return nil, openErr
+		}
+		s := &fakeSession{}
+		opened = append(opened, s)
+		return s, nil
+	}
+
+	// Sessions are shared, closing them doesn't close the pool.
+	sess, err := p.session()
+	assert.NoError(t, err)
+	assert.NoError(t, sess.Close())
+	_, err = p.session()
+	assert.NoError(t, err)
+	assert.Len(t, opened, 1)
+	assert.False(t, opened[0].closed)
+	assert.Equal(t, 20, opened[0].maxOpenConns)
+	assert.Equal(t, 5, opened[0].maxIdleConns)
+	assert.Equal(t, time.Hour, opened[0].lifetime)
+
+	assert.NoError(t, p.check(context.Background()))
+	assert.Len(t, opened, 1)
+
+	// Failing the health check closes the session and connects again.
+	opened[0].pingErr = errors.New("connection reset by peer")
+	assert.NoError(t, p.check(context.Background()))
+	assert.True(t, opened[0].closed)
+	assert.Len(t, opened, 2)
+
+	// Until the database is back.
+	opened[1].pingErr = errors.New("connection refused")
+	openErr = errors.New("connection refused")
+	assert.Error(t, p.check(context.Background()))
+	assert.Error(t, p.check(context.Background()))
+	openErr = nil
+	assert.NoError(t, p.check(context.Background()))
+	assert.Len(t, opened, 3)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		6:  30 * time.Second,
+		50: 30 * time.Second,
+	}
+	for failures, want := range tests {
+		assert.Equal(t, want, reconnectBackoff(failures, 30*time.Second), failures)
+	}
+}
@@ -0,0 +1,422 @@
+// Package discovery resolves the endpoints of Vault and the Argo server from
+// DNS SRV records or the Consul catalog, balancing requests across their
+// addresses and failing over when one can't be reached.
+//
+// Endpoints are URLs with the srv+ or consul+ prefix on their scheme, e.g.
+// srv+https://_vault._tcp.example.com or consul+https://vault?tag=active,
+// other URLs are used as they are.
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/outbound"
+)
+
+// Kinds of endpoints.
+const (
+	kindStatic = ""
+	kindSRV    = "srv"
+	kindConsul = "consul"
+)
+
+// Config is how endpoints are resolved.
+type Config struct {
+	// ConsulAddress is the HTTP API of the Consul agent, e.g.
+	// http://127.0.0.1:8500, ConsulToken its ACL token.
+	ConsulAddress string
+	ConsulToken   string
+	// RefreshInterval is how long resolved addresses are used before
+	// resolving them again.
+	RefreshInterval time.Duration
+	// FailureCooldown is how long an address failing to connect is skipped,
+	// unless all of the addresses failed.
+	FailureCooldown time.Duration
+}
+
+var (
+	mu        sync.Mutex
+	config    = Config{ConsulAddress: "http://127.0.0.1:8500", RefreshInterval: 30 * time.Second, FailureCooldown: 30 * time.Second}
+	endpoints = map[string]*Endpoint{}
+)
+
+// Configure applies the config to the endpoints returned by For afterwards.
+func Configure(c Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	config = c
+	endpoints = map[string]*Endpoint{}
+}
+
+// For returns the endpoint of the URL. Clients of the same URL share the
+// endpoint, so they balance across and skip the same addresses.
+func For(rawURL string) *Endpoint {
+	t, err := parse(rawURL)
+	if err != nil || t.kind == kindStatic {
+		return &Endpoint{url: rawURL}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if e, ok := endpoints[rawURL]; ok {
+		return e
+	}
+	e := newEndpoint(t, config, resolverFor(t, config), outbound.Transport())
+	endpoints[rawURL] = e
+	return e
+}
+
+// Validate returns why the endpoint URL can't be resolved, nil when it can.
+func Validate(rawURL string) error {
+	_, err := parse(rawURL)
+	return err
+}
+
+// Discovered returns whether the URL names a SRV record or a Consul service,
+// resolved to addresses, rather than a host.
+func Discovered(rawURL string) bool {
+	t, err := parse(rawURL)
+	return err == nil && t.kind != kindStatic
+}
+
+// target is a parsed endpoint URL.
+type target struct {
+	kind string
+	// scheme is the scheme requests use, http or https.
+	scheme string
+	// name is the SRV record or Consul service.
+	name string
+	path string
+	// tag filters the Consul service instances, e.g. active for the active
+	// Vault node.
+	tag string
+	// serverName is the TLS server name of the addresses, their host name
+	// when unset.
+	serverName string
+}
+
+func parse(rawURL string) (target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return target{}, err
+	}
+
+	kind, scheme := kindStatic, u.Scheme
+	if i := strings.Index(u.Scheme, "+"); i >= 0 {
+		kind, scheme = u.Scheme[:i], u.Scheme[i+1:]
+	}
+	switch kind {
+	case kindStatic:
+		return target{}, nil
+	case kindSRV, kindConsul:
+	default:
+		return target{}, fmt.Errorf("url scheme '%s' must be srv+https, srv+http, consul+https or consul+http", u.Scheme)
+	}
+	if scheme != "http" && scheme != "https" {
+		return target{}, fmt.Errorf("url scheme '%s' must be srv+https, srv+http, consul+https or consul+http", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() != "" {
+		if kind == kindSRV {
+			return target{}, errors.New("srv url must name the record without a port, e.g. srv+https://_vault._tcp.example.com")
+		}
+		return target{}, errors.New("consul url must name the service without a port, e.g. consul+https://vault?tag=active")
+	}
+
+	q := u.Query()
+	return target{
+		kind:       kind,
+		scheme:     scheme,
+		name:       u.Hostname(),
+		path:       u.Path,
+		tag:        q.Get("tag"),
+		serverName: q.Get("server_name"),
+	}, nil
+}
+
+// address is a resolved address of an endpoint, lower priorities are
+// preferred.
+type address struct {
+	host     string
+	priority int
+}
+
+type resolver func(ctx context.Context) ([]address, error)
+
+func resolverFor(t target, c Config) resolver {
+	if t.kind == kindSRV {
+		return srvResolver(t.name, net.DefaultResolver.LookupSRV)
+	}
+	return consulResolver(t, c, outbound.Client(10*time.Second))
+}
+
+type lookupSRVFn func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// srvResolver resolves the addresses of the SRV record, ordered by priority
+// and weight.
+func srvResolver(name string, lookup lookupSRVFn) resolver {
+	return func(ctx context.Context) ([]address, error) {
+		_, records, err := lookup(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+
+		var addrs []address
+		for _, r := range records {
+			// A target of "." means the service isn't available.
+			host := strings.TrimSuffix(r.Target, ".")
+			if host == "" {
+				continue
+			}
+			addrs = append(addrs, address{
+				host:     net.JoinHostPort(host, strconv.Itoa(int(r.Port))),
+				priority: int(r.Priority),
+			})
+		}
+		return addrs, nil
+	}
+}
+
+// consulService is an instance of the health endpoint of the Consul catalog.
+type consulService struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// consulResolver resolves the addresses of the instances of the Consul
+// service passing their health checks.
+func consulResolver(t target, c Config, cl *http.Client) resolver {
+	return func(ctx context.Context) ([]address, error) {
+		q := url.Values{"passing": {"true"}}
+		if t.tag != "" {
+			q.Set("tag", t.tag)
+		}
+		u := fmt.Sprintf("%s/v1/health/service/%s?%s", strings.TrimSuffix(c.ConsulAddress, "/"), url.PathEscape(t.name), q.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if c.ConsulToken != "" {
+			req.Header.Set("X-Consul-Token", c.ConsulToken)
+		}
+
+		resp, err := cl.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("received code %d from consul", resp.StatusCode)
+		}
+
+		var services []consulService
+		if err := json.Unmarshal(body, &services); err != nil {
+			return nil, err
+		}
+		var addrs []address
+		for _, s := range services {
+			host := s.Service.Address
+			if host == "" {
+				host = s.Node.Address
+			}
+			addrs = append(addrs, address{host: net.JoinHostPort(host, strconv.Itoa(s.Service.Port))})
+		}
+		return addrs, nil
+	}
+}
+
+// Endpoint is a resolved endpoint, an http.RoundTripper sending requests to
+// its addresses round robin and retrying requests failing to connect on the
+// next address.
+type Endpoint struct {
+	url string
+
+	target    target
+	config    Config
+	resolve   resolver
+	transport http.RoundTripper
+	now       func() time.Time
+
+	mu         sync.Mutex
+	addrs      []address
+	resolvedAt time.Time
+	next       int
+	// down are the addresses which failed to connect, with when.
+	down map[string]time.Time
+}
+
+func newEndpoint(t target, c Config, resolve resolver, tr *http.Transport) *Endpoint {
+	if t.serverName != "" {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		tr.TLSClientConfig.ServerName = t.serverName
+	}
+	return &Endpoint{
+		url:       fmt.Sprintf("%s://%s%s", t.scheme, t.name, t.path),
+		target:    t,
+		config:    c,
+		resolve:   resolve,
+		transport: tr,
+		now:       time.Now,
+		down:      map[string]time.Time{},
+	}
+}
+
+// URL is the URL clients of the endpoint are configured with. The host of
+// resolved endpoints is the SRV record or Consul service, replaced by the
+// resolved addresses when requests are sent.
+func (e *Endpoint) URL() string {
+	return e.url
+}
+
+// Transport returns the transport of the endpoint, the outbound transport
+// for URLs which aren't resolved.
+func (e *Endpoint) Transport() http.RoundTripper {
+	if e.resolve == nil {
+		return outbound.Transport()
+	}
+	return e
+}
+
+// Client returns a client of the endpoint with the timeout, 0 for no
+// timeout.
+func (e *Endpoint) Client(timeout time.Duration) *http.Client {
+	if e.resolve == nil {
+		return outbound.Client(timeout)
+	}
+	return &http.Client{Transport: e, Timeout: timeout}
+}
+
+// RoundTrip sends the request to the next address of the endpoint. Requests
+// failing to connect are sent to the other addresses in turn, when their
+// body can be sent again.
+func (e *Endpoint) RoundTrip(req *http.Request) (*http.Response, error) {
+	if e.resolve == nil {
+		return outbound.Transport().RoundTrip(req)
+	}
+
+	hosts, err := e.hosts(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	var lastErr error
+	for i, host := range hosts {
+		r := req.Clone(req.Context())
+		r.URL.Scheme = e.target.scheme
+		r.URL.Host = host
+		r.Host = e.target.serverName
+		if i > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				break
+			}
+			if r.Body, err = req.GetBody(); err != nil {
+				break
+			}
+		}
+
+		resp, err := e.transport.RoundTrip(r)
+		if err == nil {
+			e.markUp(host)
+			return resp, nil
+		}
+		lastErr = err
+		e.markDown(host)
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// hosts returns the addresses to send a request to in order, the next of the
+// preferred addresses first and the addresses which failed last.
+func (e *Endpoint) hosts(ctx context.Context) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if len(e.addrs) == 0 || now.Sub(e.resolvedAt) >= e.config.RefreshInterval {
+		addrs, err := e.resolve(ctx)
+		switch {
+		case err == nil && len(addrs) > 0:
+			sort.SliceStable(addrs, func(i, j int) bool { return addrs[i].priority < addrs[j].priority })
+			e.addrs = addrs
+		case len(e.addrs) == 0 && err != nil:
+			return nil, fmt.Errorf("unable to resolve %s: %w", e.target.name, err)
+		case len(e.addrs) == 0:
+			return nil, fmt.Errorf("no addresses of %s", e.target.name)
+		}
+		// The previous addresses are used until the next refresh when
+		// resolving fails.
+		e.resolvedAt = now
+	}
+
+	var up, down []address
+	for _, a := range e.addrs {
+		if at, ok := e.down[a.host]; ok && now.Sub(at) < e.config.FailureCooldown {
+			down = append(down, a)
+			continue
+		}
+		up = append(up, a)
+	}
+
+	var hosts []string
+	if len(up) > 0 {
+		// Requests are balanced across the addresses of the lowest priority,
+		// the others are only used on failover.
+		n := 1
+		for n < len(up) && up[n].priority == up[0].priority {
+			n++
+		}
+		start := e.next % n
+		e.next++
+		for i := 0; i < n; i++ {
+			hosts = append(hosts, up[(start+i)%n].host)
+		}
+		for _, a := range up[n:] {
+			hosts = append(hosts, a.host)
+		}
+	}
+	for _, a := range down {
+		hosts = append(hosts, a.host)
+	}
+	return hosts, nil
+}
+
+func (e *Endpoint) markDown(host string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.down[host] = e.now()
+}
+
+func (e *Endpoint) markUp(host string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.down, host)
+}
@@ -0,0 +1,206 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    target
+		wantErr string
+	}{
+		{url: "https://vault.example.com:8200"},
+		{
+			url:  "srv+https://_vault._tcp.example.com",
+			want: target{kind: kindSRV, scheme: "https", name: "_vault._tcp.example.com"},
+		},
+		{
+			url:  "consul+http://argo-server/argo?tag=primary&server_name=argo.example.com",
+			want: target{kind: kindConsul, scheme: "http", name: "argo-server", path: "/argo", tag: "primary", serverName: "argo.example.com"},
+		},
+		{url: "dns+https://vault", wantErr: "url scheme 'dns+https' must be srv+https, srv+http, consul+https or consul+http"},
+		{url: "srv+ftp://_vault._tcp.example.com", wantErr: "url scheme 'srv+ftp' must be srv+https, srv+http, consul+https or consul+http"},
+		{url: "srv+https://_vault._tcp.example.com:8200", wantErr: "srv url must name the record without a port, e.g. srv+https://_vault._tcp.example.com"},
+		{url: "consul+https://", wantErr: "consul url must name the service without a port, e.g. consul+https://vault?tag=active"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := parse(tt.url)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSRVResolver(t *testing.T) {
+	lookup := func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		assert.Equal(t, "_vault._tcp.example.com", name)
+		return "", []*net.SRV{
+			{Target: "vault-1.example.com.", Port: 8200, Priority: 10},
+			{Target: ".", Port: 0, Priority: 10},
+			{Target: "vault-2.example.com.", Port: 8201, Priority: 20},
+		}, nil
+	}
+
+	got, err := srvResolver("_vault._tcp.example.com", lookup)(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []address{
+		{host: "vault-1.example.com:8200", priority: 10},
+		{host: "vault-2.example.com:8201", priority: 20},
+	}, got)
+}
+
+func TestConsulResolver(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/vault", r.URL.Path)
+		assert.Equal(t, url.Values{"passing": {"true"}, "tag": {"active"}}, r.URL.Query())
+		assert.Equal(t, "consul-token", r.Header.Get("X-Consul-Token"))
+		fmt.Fprint(w, `[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8200}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.0.1.2", "Port": 8200}}
+		]`)
+	}))
+	defer consul.Close()
+
+	resolve := consulResolver(target{name: "vault", tag: "active"}, Config{ConsulAddress: consul.URL + "/", ConsulToken: "consul-token"}, consul.Client())
+	got, err := resolve(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []address{{host: "10.0.0.1:8200"}, {host: "10.0.1.2:8200"}}, got)
+}
+
+// testEndpoint returns an endpoint of the addresses with a clock which only
+// moves when the returned func is called.
+func testEndpoint(t *testing.T, c Config, addrs ...address) (*Endpoint, func(time.Duration), *int) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	resolves := 0
+	resolve := func(ctx context.Context) ([]address, error) {
+		resolves++
+		return append([]address(nil), addrs...), nil
+	}
+
+	e := newEndpoint(target{kind: kindSRV, scheme: "http", name: "_argo._tcp.example.com"}, c, resolve, http.DefaultTransport.(*http.Transport).Clone())
+	e.now = func() time.Time { return now }
+	return e, func(d time.Duration) { now = now.Add(d) }, &resolves
+}
+
+func TestEndpointHosts(t *testing.T) {
+	e, advance, resolves := testEndpoint(t, Config{RefreshInterval: time.Minute, FailureCooldown: 30 * time.Second},
+		address{host: "a:80", priority: 10},
+		address{host: "b:80", priority: 10},
+		address{host: "c:80", priority: 20},
+	)
+	assert.Equal(t, "http://_argo._tcp.example.com", e.URL())
+
+	// Addresses of the lowest priority are balanced round robin.
+	for _, want := range [][]string{{"a:80", "b:80", "c:80"}, {"b:80", "a:80", "c:80"}, {"a:80", "b:80", "c:80"}} {
+		got, err := e.hosts(context.Background())
+		require.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Equal(t, 1, *resolves)
+
+	// Failed addresses are tried last until the cooldown passes.
+	e.markDown("a:80")
+	e.markDown("b:80")
+	got, err := e.hosts(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []string{"c:80", "a:80", "b:80"}, got)
+
+	advance(30 * time.Second)
+	got, err = e.hosts(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a:80", "b:80", "c:80"}, got)
+	assert.Equal(t, 1, *resolves)
+
+	advance(30 * time.Second)
+	_, err = e.hosts(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, 2, *resolves)
+}
+
+func TestEndpointHostsResolveError(t *testing.T) {
+	fail := false
+	resolve := func(ctx context.Context) ([]address, error) {
+		if fail {
+			return nil, errors.New("no such host")
+		}
+		return []address{{host: "a:80"}}, nil
+	}
+	e := newEndpoint(target{kind: kindSRV, scheme: "http", name: "_argo._tcp.example.com"}, Config{}, resolve, http.DefaultTransport.(*http.Transport).Clone())
+
+	// The previous addresses are used when resolving them again fails.
+	got, err := e.hosts(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a:80"}, got)
+
+	fail = true
+	got, err = e.hosts(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a:80"}, got)
+
+	e.addrs = nil
+	_, err = e.hosts(context.Background())
+	assert.EqualError(t, err, "unable to resolve _argo._tcp.example.com: no such host")
+}
+
+func TestEndpointRoundTripFailover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	// An address nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	dead := l.Addr().String()
+	l.Close()
+
+	live := strings.TrimPrefix(srv.URL, "http://")
+	e, _, _ := testEndpoint(t, Config{RefreshInterval: time.Minute, FailureCooldown: time.Minute},
+		address{host: dead},
+		address{host: live},
+	)
+
+	for i := 0; i < 2; i++ {
+		resp, err := e.Client(time.Second).Get(e.URL() + "/api/v1/info")
+		require.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Contains(t, e.down, dead)
+
+	// Requests with bodies which can't be sent again aren't retried.
+	e.down = map[string]time.Time{}
+	e.next = 0
+	req, err := http.NewRequest(http.MethodPost, e.URL()+"/api/v1/workflows", strings.NewReader("{}"))
+	require.Nil(t, err)
+	req.GetBody = nil
+	_, err = e.RoundTrip(req)
+	assert.NotNil(t, err)
+
+	e.down = map[string]time.Time{}
+	e.next = 0
+	req, err = http.NewRequest(http.MethodPost, e.URL()+"/api/v1/workflows", strings.NewReader("{}"))
+	require.Nil(t, err)
+	resp, err := e.RoundTrip(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
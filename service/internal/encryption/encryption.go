@@ -0,0 +1,93 @@
+// Package encryption validates values encrypted with the public key of a
+// project. The service never holds the private key, values are only
+// decrypted inside the workflow pod.
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix is the prefix of encrypted values, followed by the key ID and the
+// base64 RSA-OAEP (SHA-256) ciphertext, e.g. "enc:v1:1a2b...:c2VjcmV0...".
+const Prefix = "enc:v1:"
+
+// MinKeyBits is the minimum size of public keys.
+const MinKeyBits = 2048
+
+// ErrInvalidValue is returned for encrypted values which aren't well formed.
+var ErrInvalidValue = errors.New("invalid encrypted value")
+
+// ParsePublicKey parses a PEM encoded RSA public key ("PUBLIC KEY" block),
+// returning the key and its ID.
+func ParsePublicKey(s string) (*rsa.PublicKey, string, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, "", errors.New("public key must be a PEM encoded 'PUBLIC KEY' block")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, "", errors.New("public key must be an RSA key")
+	}
+	if pub.N.BitLen() < MinKeyBits {
+		return nil, "", fmt.Errorf("public key must be at least %d bits", MinKeyBits)
+	}
+	return pub, KeyID(block.Bytes), nil
+}
+
+// KeyID returns the ID of a DER encoded public key, the first 16 hex
+// characters of its SHA-256.
+func KeyID(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// IsEncrypted reports whether the value is an encrypted value.
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, Prefix)
+}
+
+// Check returns an error unless the encrypted value is well formed and was
+// encrypted with the public key with the ID. It can't tell whether the
+// ciphertext decrypts, only the private key can.
+func Check(v string, pub *rsa.PublicKey, keyID string) error {
+	parts := strings.SplitN(strings.TrimPrefix(v, Prefix), ":", 2)
+	if !IsEncrypted(v) || len(parts) != 2 {
+		return fmt.Errorf("%w, must be '%s<key id>:<ciphertext>'", ErrInvalidValue, Prefix)
+	}
+	if parts[0] != keyID {
+		return fmt.Errorf("%w, encrypted with key '%s' instead of the project key '%s'", ErrInvalidValue, parts[0], keyID)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w, ciphertext must be base64 encoded", ErrInvalidValue)
+	}
+	if len(ciphertext) != pub.Size() {
+		return fmt.Errorf("%w, ciphertext must be %d bytes", ErrInvalidValue, pub.Size())
+	}
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the public key with the ID, e.g. for
+// clients submitting encrypted values.
+func Encrypt(pub *rsa.PublicKey, keyID string, plaintext []byte) (string, error) {
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+	return Prefix + keyID + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
@@ -0,0 +1,83 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func publicKeyPEM(t *testing.T, bits int) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, s := publicKeyPEM(t, 2048)
+	pub, keyID, err := ParsePublicKey(s)
+	assert.Nil(t, err)
+	assert.Equal(t, key.PublicKey.N, pub.N)
+	assert.Len(t, keyID, 16)
+
+	_, small := publicKeyPEM(t, 1024)
+	_, _, err = ParsePublicKey(small)
+	assert.EqualError(t, err, "public key must be at least 2048 bits")
+
+	_, _, err = ParsePublicKey("not a key")
+	assert.EqualError(t, err, "public key must be a PEM encoded 'PUBLIC KEY' block")
+
+	_, _, err = ParsePublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")})))
+	assert.True(t, strings.HasPrefix(err.Error(), "invalid public key: "))
+}
+
+func TestEncryptCheck(t *testing.T) {
+	key, s := publicKeyPEM(t, 2048)
+	pub, keyID, err := ParsePublicKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Encrypt(pub, keyID, []byte("hunter2"))
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(v))
+	assert.Nil(t, Check(v, pub, keyID))
+
+	// The private key decrypts the value, as the workflow pod does.
+	ciphertext, _ := base64.StdEncoding.DecodeString(v[strings.LastIndex(v, ":")+1:])
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), nil, key, ciphertext, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", string(plaintext))
+
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{name: "no key id", value: "enc:v1:abc", err: "invalid encrypted value, must be 'enc:v1:<key id>:<ciphertext>'"},
+		{name: "other key", value: "enc:v1:0123456789abcdef:abc", err: "invalid encrypted value, encrypted with key '0123456789abcdef' instead of the project key '" + keyID + "'"},
+		{name: "not base64", value: Prefix + keyID + ":***", err: "invalid encrypted value, ciphertext must be base64 encoded"},
+		{name: "truncated", value: v[:len(v)-8], err: "invalid encrypted value, ciphertext must be 256 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.value, pub, keyID)
+			assert.EqualError(t, err, tt.err)
+			assert.True(t, errors.Is(err, ErrInvalidValue))
+		})
+	}
+}
@@ -2,7 +2,13 @@ package env
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
 	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/discovery"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -11,9 +17,9 @@ const appPrefix = "ARGO_CLOUDOPS"
 
 type Vars struct {
 	AdminSecret    string   `split_words:"true" required:"true"`
-	VaultRole      string   `envconfig:"VAULT_ROLE" required:"true"`
-	VaultSecret    string   `envconfig:"VAULT_SECRET" required:"true"`
-	VaultAddress   string   `envconfig:"VAULT_ADDR" required:"true"`
+	VaultRole      string   `envconfig:"VAULT_ROLE"`
+	VaultSecret    string   `envconfig:"VAULT_SECRET"`
+	VaultAddress   string   `envconfig:"VAULT_ADDR"`
 	ArgoAddress    string   `envconfig:"ARGO_ADDR" required:"true"`
 	ArgoNamespace  string   `envconfig:"WORKFLOW_EXECUTION_NAMESPACE" default:"argo"`
 	ConfigFilePath string   `envconfig:"CONFIG" default:"argo-cloudops.yaml"`
@@ -28,8 +34,269 @@ type Vars struct {
 	DBPassword     string   `split_words:"true" required:"true"`
 	DBName         string   `split_words:"true" required:"true"`
 	ImageURIs      []string `envconfig:"IMAGE_URIS"`
+	// Outbound connections (Vault, git, Argo, notifications and the other
+	// integrations) go through the proxies, falling back to the standard
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables, and trust the CA bundle
+	// in addition to the system CAs.
+	HTTPProxy    string `envconfig:"HTTP_PROXY"`
+	HTTPSProxy   string `envconfig:"HTTPS_PROXY"`
+	NoProxy      string `envconfig:"NO_PROXY"`
+	CABundleFile string `envconfig:"CA_BUNDLE_FILE"`
+	// VAULT_ADDR and the Argo artifacts URL can name DNS SRV records
+	// (srv+https://_vault._tcp.example.com) or services of the Consul agent
+	// (consul+https://vault?tag=active) instead of a host, resolved again
+	// every refresh interval. Requests are balanced across the addresses,
+	// skipping the ones which failed to connect for the failure cooldown.
+	ConsulAddress            string        `envconfig:"CONSUL_HTTP_ADDR" default:"http://127.0.0.1:8500"`
+	ConsulToken              string        `envconfig:"CONSUL_HTTP_TOKEN"`
+	DiscoveryRefreshInterval time.Duration `split_words:"true" default:"30s"`
+	DiscoveryFailureCooldown time.Duration `split_words:"true" default:"30s"`
+	// Reads tolerating replication lag (listing projects, stats and audit
+	// records) go to the read replica of the DSN when set, falling back to
+	// the primary while it's unavailable or lags more than the max lag.
+	DBReplicaDSN    string        `envconfig:"DB_REPLICA_DSN"`
+	DBReplicaMaxLag time.Duration `envconfig:"DB_REPLICA_MAX_LAG" default:"30s"`
+	// Connections of the pools of the primary and the replica. The databases
+	// are pinged every health check interval (0 disables), connecting again
+	// with backoff when the ping fails, e.g. after a failover.
+	DBMaxOpenConns        int           `envconfig:"DB_MAX_OPEN_CONNS" default:"20"`
+	DBMaxIdleConns        int           `envconfig:"DB_MAX_IDLE_CONNS" default:"10"`
+	DBConnMaxLifetime     time.Duration `envconfig:"DB_CONN_MAX_LIFETIME" default:"30m"`
+	DBConnMaxIdleTime     time.Duration `envconfig:"DB_CONN_MAX_IDLE_TIME" default:"5m"`
+	DBHealthCheckInterval time.Duration `envconfig:"DB_HEALTH_CHECK_INTERVAL" default:"10s"`
+	DBReconnectMaxBackoff time.Duration `envconfig:"DB_RECONNECT_MAX_BACKOFF" default:"30s"`
+	// HealthGRPCPort enables the gRPC health checking protocol when non-zero.
+	HealthGRPCPort      int           `split_words:"true"`
+	HealthCheckInterval time.Duration `split_words:"true" default:"10s"`
+	// MetricsPort serves /metrics on a separate plain HTTP listener when
+	// non-zero, instead of to the admin on the service port.
+	MetricsPort int `split_words:"true"`
+	// Load shedding of low priority requests is disabled when the thresholds
+	// are zero.
+	ShedMaxInFlight int           `split_words:"true"`
+	ShedMaxLatency  time.Duration `split_words:"true"`
+	ShedRetryAfter  time.Duration `split_words:"true" default:"5s"`
+	// Dependency circuit breakers are disabled when the threshold is zero.
+	CircuitBreakerFailureThreshold int           `split_words:"true" default:"5"`
+	CircuitBreakerOpenTimeout      time.Duration `split_words:"true" default:"30s"`
+	// Retries of transient workflow submission failures.
+	ArgoSubmitMaxAttempts    int           `split_words:"true" default:"3"`
+	ArgoSubmitInitialBackoff time.Duration `split_words:"true" default:"500ms"`
+	ArgoSubmitMaxBackoff     time.Duration `split_words:"true" default:"5s"`
+	// Workflows no longer live are read from the Argo workflow archive when
+	// enabled. Their logs are read from the artifacts of the Argo server at
+	// the URL, unavailable when empty.
+	ArgoArchiveEnabled bool   `split_words:"true"`
+	ArgoArtifactsURL   string `split_words:"true"`
+	ArgoToken          string `envconfig:"ARGO_TOKEN"`
+	// DuplicateSubmissionPolicy controls git workflow submissions identical
+	// to one still running for the same project, target and commit.
+	DuplicateSubmissionPolicy string `split_words:"true" default:"allow"`
+	// Target guardrail providers are enabled when configured.
+	GuardrailPrometheusAddress string        `split_words:"true"`
+	GuardrailCloudWatchRegion  string        `envconfig:"GUARDRAIL_CLOUDWATCH_REGION"`
+	GuardrailInterval          time.Duration `split_words:"true" default:"30s"`
+	// The resource usage report of workflow pods is read from the Prometheus
+	// server at the address, disabled when unset.
+	UsagePrometheusAddress string `split_words:"true"`
+	// Change tickets for change controlled targets are created in the ITSM
+	// provider (servicenow or jira), disabled when unset.
+	ITSMProvider        string        `envconfig:"ITSM_PROVIDER"`
+	ITSMAddress         string        `envconfig:"ITSM_ADDRESS"`
+	ITSMUser            string        `envconfig:"ITSM_USER"`
+	ITSMToken           string        `envconfig:"ITSM_TOKEN"`
+	ITSMJiraProject     string        `envconfig:"ITSM_JIRA_PROJECT"`
+	ITSMJiraIssueType   string        `envconfig:"ITSM_JIRA_ISSUE_TYPE" default:"Change"`
+	ITSMApprovalTimeout time.Duration `envconfig:"ITSM_APPROVAL_TIMEOUT" default:"15m"`
+	ITSMPollInterval    time.Duration `envconfig:"ITSM_POLL_INTERVAL" default:"30s"`
+	// Audit events are forwarded to the audit sink (splunk or syslog) through
+	// a spool in the audit spool dir, in addition to the db. Disabled when unset.
+	AuditSink           string        `split_words:"true"`
+	AuditSplunkHECURL   string        `envconfig:"AUDIT_SPLUNK_HEC_URL"`
+	AuditSplunkHECToken string        `envconfig:"AUDIT_SPLUNK_HEC_TOKEN"`
+	AuditSplunkIndex    string        `split_words:"true"`
+	AuditSyslogAddress  string        `split_words:"true"`
+	AuditSyslogCAFile   string        `envconfig:"AUDIT_SYSLOG_CA_FILE"`
+	AuditSpoolDir       string        `split_words:"true"`
+	AuditFlushInterval  time.Duration `split_words:"true" default:"5s"`
+	AuditMaxBackoff     time.Duration `split_words:"true" default:"5m"`
+	// Submissions are assessed by the anomaly detector (heuristic or scorer),
+	// disabled when unset. Submissions whose anomaly score (0-1) reaches the
+	// thresholds are tagged, alerted and require an approved change ticket,
+	// 0 disabling alerts and approvals.
+	AnomalyDetector          string        `split_words:"true"`
+	AnomalyScorerURL         string        `envconfig:"ANOMALY_SCORER_URL"`
+	AnomalyRateWindow        time.Duration `split_words:"true" default:"10m"`
+	AnomalyRateThreshold     int           `split_words:"true" default:"20"`
+	AnomalyUsualHoursStart   int           `split_words:"true" default:"7"`
+	AnomalyUsualHoursEnd     int           `split_words:"true" default:"20"`
+	AnomalyTimezone          string        `split_words:"true" default:"UTC"`
+	AnomalyTagThreshold      float64       `split_words:"true" default:"0.3"`
+	AnomalyAlertThreshold    float64       `split_words:"true" default:"0.6"`
+	AnomalyApprovalThreshold float64       `split_words:"true"`
+	// Project notification rules are evaluated once workflows complete.
+	NotificationInterval time.Duration `split_words:"true" default:"30s"`
+	PagerDutyEventsURL   string        `envconfig:"PAGERDUTY_EVENTS_URL" default:"https://events.pagerduty.com/v2/enqueue"`
+	// Cron workflows of target schedules are re-applied with fresh project
+	// tokens, so this must be shorter than the project token TTL (10m).
+	ScheduleSyncInterval time.Duration `split_words:"true" default:"5m"`
+	// Running rollouts are advanced as the workflows of their steps finish.
+	RolloutSyncInterval time.Duration `split_words:"true" default:"30s"`
+	// Events are delivered to project webhooks every delivery interval. Failed
+	// deliveries are retried with backoff, doubling from the initial backoff up
+	// to the max, until the max attempts.
+	WebhookDeliveryInterval time.Duration `split_words:"true" default:"10s"`
+	WebhookMaxAttempts      int           `split_words:"true" default:"8"`
+	WebhookInitialBackoff   time.Duration `split_words:"true" default:"30s"`
+	WebhookMaxBackoff       time.Duration `split_words:"true" default:"1h"`
+	// Targets can set the IRSA service account of their workflow pods when
+	// enabled. The service must run in the cluster to read service accounts.
+	WorkloadIdentityEnabled bool `split_words:"true"`
+	// The token of the approle login of the service is reused until half its
+	// TTL elapsed. Disable when the approle limits the uses of its tokens.
+	VaultReuseServiceToken bool `split_words:"true" default:"true"`
+	// Workflows get a token of their own, revoked once they complete. When
+	// wrapped, workflows get a response wrapping token to unwrap instead,
+	// which expires after the wrap TTL. The TTL must exceed how long pods
+	// of workflows can wait to be scheduled.
+	VaultWrapRunTokens     bool          `split_words:"true"`
+	VaultWrapTTL           time.Duration `split_words:"true" default:"1h"`
+	RunTokenRevokeInterval time.Duration `split_words:"true" default:"30s"`
+	// The Vault policies of projects grow with their targets when the policy
+	// template has paths per target. Creating targets warns once the policy
+	// of the project exceeds the warning size, policies exceeding the max
+	// size are sharded across several Vault policies. 0 disables either.
+	VaultPolicyWarningBytes int `split_words:"true" default:"49152"`
+	VaultPolicyMaxBytes     int `split_words:"true" default:"65536"`
+	// gcp_project targets are impersonated accounts of the GCP secrets
+	// engine mounted at gcp/, only read and written when enabled.
+	VaultGCPEnabled bool `envconfig:"VAULT_GCP_ENABLED"`
+	// azure_subscription targets are roles of the Azure secrets engine
+	// mounted at azure/, only read and written when enabled.
+	VaultAzureEnabled bool `envconfig:"VAULT_AZURE_ENABLED"`
+	// CredentialsProvider stores projects and targets and issues their
+	// credentials, vault or aws_secrets_manager. The Vault variables are only
+	// required by the vault provider.
+	CredentialsProvider string `split_words:"true" default:"vault"`
+	// The aws_secrets_manager provider stores projects and targets as tagged
+	// secrets in the region, the default region of the service when unset.
+	// Tokens are sessions of the run role tagged with the project, which
+	// assume the roles of its targets.
+	AWSSecretsManagerRegion string `envconfig:"AWS_SECRETS_MANAGER_REGION"`
+	AWSRunRoleARN           string `envconfig:"AWS_RUN_ROLE_ARN"`
+	// How long the former name of a renamed project still resolves to it for
+	// reads.
+	ProjectAliasTTL time.Duration `split_words:"true" default:"720h"`
+	// How often workflows are checked for completion to revoke their token,
+	// 0 leaves revocation to RunTokenRevokeInterval.
+	RunTokenWatchInterval time.Duration `split_words:"true" default:"5s"`
+	// How often cloudformation diffs are checked for completion to record the
+	// summary of their change set, 0 disables.
+	ChangeSetWatchInterval time.Duration `split_words:"true" default:"10s"`
+	// How often terraform and cdk diffs are checked for completion to record
+	// the summary of their plan, 0 disables.
+	PlanWatchInterval time.Duration `split_words:"true" default:"10s"`
+	// How often terraform runs of targets with a recorded state location are
+	// checked for completion to detect stale state locks, 0 disables.
+	TerraformLockWatchInterval time.Duration `split_words:"true" default:"10s"`
+	// How often workflows of projects with a hung run timeout are checked for
+	// node progress, 0 disables.
+	HungRunWatchInterval time.Duration `split_words:"true" default:"30s"`
+	// Limits of requests, named with their stage in the error responses of
+	// requests exceeding them. Zero disables a limit.
+	MaxRequestBodyBytes int64         `split_words:"true" default:"1048576"`
+	GitFetchTimeout     time.Duration `split_words:"true" default:"2m"`
+	VaultTimeout        time.Duration `split_words:"true" default:"30s"`
+	ArgoSubmitTimeout   time.Duration `split_words:"true" default:"1m"`
+	// Manifest bundles uploaded to POST /workflows:upload above the size are
+	// rejected, within the max request body size.
+	ManifestBundleMaxBytes int64 `split_words:"true" default:"262144"`
+	// Logs are returned in chunks of at most the size, continued with the
+	// continue token of the response. Zero returns the whole logs.
+	LogChunkMaxBytes int `split_words:"true" default:"4194304"`
+	// Logs of completed workflows are archived to the URL, file:///<dir> or
+	// s3://<bucket>/<prefix>, compressed with the encoding (gzip or zstd) in
+	// frames of up to the frame size, and served from there. Workflows are
+	// checked for completion every watch interval. Empty disables archiving.
+	LogArchiveURL           string        `envconfig:"LOG_ARCHIVE_URL"`
+	LogArchiveEncoding      string        `split_words:"true" default:"zstd"`
+	LogArchiveFrameBytes    int           `split_words:"true" default:"1048576"`
+	LogArchiveWatchInterval time.Duration `split_words:"true" default:"30s"`
+	// Anonymous read only endpoints (public workflow status and stats) are
+	// served without authorization when enabled. Public IDs of workflows are
+	// encrypted with the key. Public stats are computed at most once per
+	// cache TTL, and requested at most at the rate limit (per second) across
+	// clients.
+	AnonymousReadOnly    bool          `split_words:"true"`
+	PublicIDKey          string        `envconfig:"PUBLIC_ID_KEY"`
+	PublicStatsCacheTTL  time.Duration `split_words:"true" default:"1m"`
+	PublicStatsRateLimit float64       `split_words:"true" default:"5"`
+	// Projects can share links to the status and logs of their workflows
+	// when the key signing them is set. Links are of the external URL of the
+	// service, which is required with the key. A zero max TTL disables the
+	// maximum.
+	ShareLinkKey    string        `split_words:"true"`
+	ShareLinkMaxTTL time.Duration `envconfig:"SHARE_LINK_MAX_TTL" default:"24h"`
+	ExternalURL     string        `envconfig:"EXTERNAL_URL"`
+	// Admins can get break-glass credentials of targets valid for up to the
+	// max TTL, 0 disables break-glass access. Their leases are revoked once
+	// expired, checked every revoke interval.
+	BreakGlassMaxTTL         time.Duration `envconfig:"BREAK_GLASS_MAX_TTL" default:"1h"`
+	BreakGlassRevokeInterval time.Duration `envconfig:"BREAK_GLASS_REVOKE_INTERVAL" default:"1m"`
+	// Projects can request admin access to themselves granted by an admin for
+	// up to the max TTL, 0 disables elevated access.
+	ElevationMaxTTL time.Duration `envconfig:"ELEVATION_MAX_TTL" default:"0"`
+	// Syncs get a provenance document signed with the key once they complete,
+	// checked for completion every watch interval.
+	AttestationKey           string        `split_words:"true"`
+	AttestationWatchInterval time.Duration `split_words:"true" default:"30s"`
+	// SecretScanPolicy controls workflow submissions with secrets in their
+	// parameters, arguments or environment variables. Findings are logged as
+	// hashes salted with the salt, random per start when unset.
+	SecretScanPolicy string `split_words:"true" default:"warn"`
+	SecretScanSalt   string `split_words:"true"`
+	// Dependencies are probed at startup, pre-fetching the Vault token of the
+	// service and listing the remote refs of the git repository when set.
+	// Failures are logged (degrade) or stop the service (fail).
+	StartupProbes        string        `split_words:"true" default:"degrade"`
+	StartupProbeTimeout  time.Duration `split_words:"true" default:"30s"`
+	StartupGitRepository string        `split_words:"true"`
+	// Sanitized requests and responses are recorded into the directory when
+	// set, to build contract tests from. Meant for staging instances only.
+	RecordDir string `split_words:"true"`
 }
 
+// Duplicate submission policies.
+const (
+	DuplicateSubmissionAllow  = "allow"
+	DuplicateSubmissionReject = "reject"
+	DuplicateSubmissionReturn = "return"
+)
+
+// Credentials providers.
+const (
+	CredentialsProviderVault             = "vault"
+	CredentialsProviderAWSSecretsManager = "aws_secrets_manager"
+)
+
+// MinVaultWrapTTL is the shortest TTL of response wrapped run tokens, the
+// default of Vault.
+const MinVaultWrapTTL = 5 * time.Minute
+
+// Startup probe modes.
+const (
+	StartupProbesOff     = "off"
+	StartupProbesDegrade = "degrade"
+	StartupProbesFail    = "fail"
+)
+
+// Secret scan policies.
+const (
+	SecretScanOff   = "off"
+	SecretScanWarn  = "warn"
+	SecretScanBlock = "block"
+)
+
 var (
 	instance Vars
 	once     sync.Once
@@ -38,6 +305,12 @@ var (
 
 func GetEnv() (Vars, error) {
 	once.Do(func() {
+		if path := os.Getenv(ServiceConfigVar); path != "" {
+			if err = applyFile(path); err != nil {
+				return
+			}
+		}
+
 		err = envconfig.Process(appPrefix, &instance)
 		if err != nil {
 			return
@@ -51,5 +324,158 @@ func (values Vars) validate() error {
 	if len(values.AdminSecret) < 16 {
 		return errors.New("admin secret must be at least 16 characers long")
 	}
+	if values.AnonymousReadOnly && len(values.PublicIDKey) < 16 {
+		return errors.New("public id key must be at least 16 characters long when anonymous read only is enabled")
+	}
+	if values.PublicStatsCacheTTL <= 0 || values.PublicStatsRateLimit <= 0 {
+		return errors.New("public stats cache ttl and rate limit must be positive")
+	}
+	if values.ShareLinkKey != "" && len(values.ShareLinkKey) < 16 {
+		return errors.New("share link key must be at least 16 characters long")
+	}
+	if values.ShareLinkKey != "" && values.ExternalURL == "" {
+		return errors.New("external url is required when the share link key is set")
+	}
+	if values.AttestationKey != "" && len(values.AttestationKey) < 16 {
+		return errors.New("attestation key must be at least 16 characters long")
+	}
+	if values.AttestationKey != "" && values.AttestationWatchInterval <= 0 {
+		return errors.New("attestation watch interval must be positive")
+	}
+	for name, proxy := range map[string]string{"http proxy": values.HTTPProxy, "https proxy": values.HTTPSProxy} {
+		if u, err := url.Parse(proxy); proxy != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+			return fmt.Errorf("%s must be a url, e.g. http://proxy:3128", name)
+		}
+	}
+	for name, endpoint := range map[string]string{"vault address": values.VaultAddress, "argo artifacts url": values.ArgoArtifactsURL} {
+		if err := discovery.Validate(endpoint); err != nil {
+			return fmt.Errorf("invalid %s, %w", name, err)
+		}
+	}
+	// Argo is reached through the Kubernetes API, its address is only used
+	// for links to the Argo UI, which must name a host.
+	if discovery.Discovered(values.ArgoAddress) {
+		return errors.New("argo addr is only used for links to the argo ui, it must name a host")
+	}
+	if values.DiscoveryRefreshInterval <= 0 {
+		return errors.New("discovery refresh interval must be positive")
+	}
+	if values.DiscoveryFailureCooldown < 0 {
+		return errors.New("discovery failure cooldown must not be negative")
+	}
+	if values.DBReplicaDSN != "" && values.DBReplicaMaxLag <= 0 {
+		return errors.New("db replica max lag must be positive")
+	}
+	if values.BreakGlassMaxTTL != 0 && values.BreakGlassMaxTTL < 15*time.Minute {
+		return errors.New("break glass max ttl must be at least 15m")
+	}
+	if values.ElevationMaxTTL != 0 && values.ElevationMaxTTL < 5*time.Minute {
+		return errors.New("elevation max ttl must be at least 5m")
+	}
+	if values.LogArchiveURL != "" {
+		if values.LogArchiveEncoding != "gzip" && values.LogArchiveEncoding != "zstd" {
+			return errors.New("log archive encoding must be one of 'gzip' or 'zstd'")
+		}
+		if values.LogArchiveFrameBytes <= 0 || values.LogArchiveWatchInterval <= 0 {
+			return errors.New("log archive frame bytes and watch interval must be positive")
+		}
+	}
+	if values.WebhookMaxAttempts < 1 {
+		return errors.New("webhook max attempts must be at least 1")
+	}
+	if values.VaultPolicyWarningBytes < 0 || values.VaultPolicyMaxBytes < 0 {
+		return errors.New("vault policy warning and max bytes can't be negative")
+	}
+	if values.ProjectAliasTTL < 0 {
+		return errors.New("project alias ttl can't be negative")
+	}
+	switch values.DuplicateSubmissionPolicy {
+	case DuplicateSubmissionAllow, DuplicateSubmissionReject, DuplicateSubmissionReturn:
+	default:
+		return fmt.Errorf("duplicate submission policy must be one of '%s', '%s' or '%s'", DuplicateSubmissionAllow, DuplicateSubmissionReject, DuplicateSubmissionReturn)
+	}
+	switch values.SecretScanPolicy {
+	case SecretScanOff, SecretScanWarn, SecretScanBlock:
+	default:
+		return fmt.Errorf("secret scan policy must be one of '%s', '%s' or '%s'", SecretScanOff, SecretScanWarn, SecretScanBlock)
+	}
+	if values.VaultWrapTTL < MinVaultWrapTTL {
+		return fmt.Errorf("vault wrap ttl must be at least %s", MinVaultWrapTTL)
+	}
+	switch values.CredentialsProvider {
+	case CredentialsProviderVault:
+		if values.VaultRole == "" || values.VaultSecret == "" || values.VaultAddress == "" {
+			return errors.New("vault role, secret and address are required for the vault credentials provider")
+		}
+	case CredentialsProviderAWSSecretsManager:
+		if values.AWSRunRoleARN == "" {
+			return errors.New("aws run role arn is required for the aws_secrets_manager credentials provider")
+		}
+		if values.VaultWrapRunTokens {
+			return errors.New("wrapped run tokens require the vault credentials provider")
+		}
+	default:
+		return fmt.Errorf("credentials provider must be one of '%s' or '%s'", CredentialsProviderVault, CredentialsProviderAWSSecretsManager)
+	}
+	switch values.StartupProbes {
+	case StartupProbesOff, StartupProbesDegrade, StartupProbesFail:
+	default:
+		return fmt.Errorf("startup probes must be one of '%s', '%s' or '%s'", StartupProbesOff, StartupProbesDegrade, StartupProbesFail)
+	}
+	switch values.ITSMProvider {
+	case "":
+	case "servicenow", "jira":
+		if values.ITSMAddress == "" {
+			return errors.New("itsm address is required when an itsm provider is set")
+		}
+		if values.ITSMProvider == "jira" && values.ITSMJiraProject == "" {
+			return errors.New("itsm jira project is required for the jira itsm provider")
+		}
+	default:
+		return errors.New("itsm provider must be one of 'servicenow' or 'jira'")
+	}
+	switch values.AuditSink {
+	case "":
+	case "splunk", "syslog":
+		if values.AuditSink == "splunk" && (values.AuditSplunkHECURL == "" || values.AuditSplunkHECToken == "") {
+			return errors.New("audit splunk hec url and token are required for the splunk audit sink")
+		}
+		if values.AuditSink == "syslog" && values.AuditSyslogAddress == "" {
+			return errors.New("audit syslog address is required for the syslog audit sink")
+		}
+		if values.AuditSpoolDir == "" {
+			return errors.New("audit spool dir is required when an audit sink is set")
+		}
+		if values.AuditFlushInterval <= 0 {
+			return errors.New("audit flush interval must be positive")
+		}
+	default:
+		return errors.New("audit sink must be one of 'splunk' or 'syslog'")
+	}
+	switch values.AnomalyDetector {
+	case "", "heuristic":
+	case "scorer":
+		if values.AnomalyScorerURL == "" {
+			return errors.New("anomaly scorer url is required for the scorer anomaly detector")
+		}
+	default:
+		return errors.New("anomaly detector must be one of 'heuristic' or 'scorer'")
+	}
+	if values.AnomalyDetector != "" {
+		if values.AnomalyUsualHoursStart < 0 || values.AnomalyUsualHoursStart > 24 || values.AnomalyUsualHoursEnd < 0 || values.AnomalyUsualHoursEnd > 24 {
+			return errors.New("anomaly usual hours must be between 0 and 24")
+		}
+		if _, err := time.LoadLocation(values.AnomalyTimezone); err != nil {
+			return fmt.Errorf("anomaly timezone is invalid: %w", err)
+		}
+		for _, threshold := range []float64{values.AnomalyTagThreshold, values.AnomalyAlertThreshold, values.AnomalyApprovalThreshold} {
+			if threshold < 0 || threshold > 1 {
+				return errors.New("anomaly thresholds must be between 0 and 1")
+			}
+		}
+		if values.AnomalyApprovalThreshold > 0 && values.ITSMProvider == "" {
+			return errors.New("an itsm provider is required for the anomaly approval threshold")
+		}
+	}
 	return nil
 }
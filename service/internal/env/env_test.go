@@ -4,6 +4,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,6 +26,43 @@ var allEnvVars = []string{
 	"ARGO_CLOUDOPS_GIT_HTTPS_PASS",
 	"ARGO_CLOUDOPS_LOG_LEVEL",
 	"ARGO_CLOUDOPS_PORT",
+	"ARGO_CLOUDOPS_DUPLICATE_SUBMISSION_POLICY",
+	"ARGO_CLOUDOPS_ITSM_PROVIDER",
+	"ARGO_CLOUDOPS_ITSM_ADDRESS",
+	"ARGO_CLOUDOPS_ITSM_JIRA_PROJECT",
+	"ARGO_CLOUDOPS_AUDIT_SINK",
+	"ARGO_CLOUDOPS_AUDIT_SPLUNK_HEC_URL",
+	"ARGO_CLOUDOPS_AUDIT_SPLUNK_HEC_TOKEN",
+	"ARGO_CLOUDOPS_AUDIT_SYSLOG_ADDRESS",
+	"ARGO_CLOUDOPS_AUDIT_SPOOL_DIR",
+	"ARGO_CLOUDOPS_ANOMALY_DETECTOR",
+	"ARGO_CLOUDOPS_ANOMALY_SCORER_URL",
+	"ARGO_CLOUDOPS_ANOMALY_TIMEZONE",
+	"ARGO_CLOUDOPS_ANOMALY_APPROVAL_THRESHOLD",
+	"ARGO_CLOUDOPS_ANONYMOUS_READ_ONLY",
+	"ARGO_CLOUDOPS_PUBLIC_ID_KEY",
+	"ARGO_CLOUDOPS_SHARE_LINK_KEY",
+	"ARGO_CLOUDOPS_BREAK_GLASS_MAX_TTL",
+	"ARGO_CLOUDOPS_ATTESTATION_KEY",
+	"ARGO_CLOUDOPS_SECRET_SCAN_POLICY",
+	"ARGO_CLOUDOPS_STARTUP_PROBES",
+	"ARGO_CLOUDOPS_ARGO_ARCHIVE_ENABLED",
+	"ARGO_CLOUDOPS_ARGO_ARTIFACTS_URL",
+	"ARGO_TOKEN",
+	"ARGO_CLOUDOPS_DB_REPLICA_DSN",
+	"ARGO_CLOUDOPS_DB_REPLICA_MAX_LAG",
+	"ARGO_CLOUDOPS_DB_MAX_OPEN_CONNS",
+	"ARGO_CLOUDOPS_DB_HEALTH_CHECK_INTERVAL",
+	"ARGO_CLOUDOPS_WEBHOOK_MAX_ATTEMPTS",
+	"ARGO_CLOUDOPS_LOG_ARCHIVE_URL",
+	"ARGO_CLOUDOPS_LOG_ARCHIVE_ENCODING",
+	"ARGO_CLOUDOPS_HTTPS_PROXY",
+	"ARGO_CLOUDOPS_CREDENTIALS_PROVIDER",
+	"ARGO_CLOUDOPS_VAULT_WRAP_RUN_TOKENS",
+	"ARGO_CLOUDOPS_VAULT_WRAP_TTL",
+	"ARGO_CLOUDOPS_DISCOVERY_REFRESH_INTERVAL",
+	"AWS_RUN_ROLE_ARN",
+	"HTTPS_PROXY",
 }
 
 func setup() {
@@ -95,6 +133,60 @@ func TestDefaults(t *testing.T) {
 	assert.Equal(t, env.ArgoNamespace, "argo")
 	assert.Equal(t, env.ConfigFilePath, "argo-cloudops.yaml")
 	assert.Equal(t, env.Port, 8443)
+	assert.Equal(t, env.HealthCheckInterval, 10*time.Second)
+	assert.Equal(t, env.ShedRetryAfter, 5*time.Second)
+	assert.Equal(t, env.DuplicateSubmissionPolicy, DuplicateSubmissionAllow)
+	assert.Equal(t, env.SecretScanPolicy, SecretScanWarn)
+	assert.Equal(t, env.GuardrailInterval, 30*time.Second)
+	assert.Equal(t, env.ITSMJiraIssueType, "Change")
+	assert.Equal(t, env.ITSMApprovalTimeout, 15*time.Minute)
+	assert.Equal(t, env.AuditFlushInterval, 5*time.Second)
+	assert.Equal(t, env.AuditMaxBackoff, 5*time.Minute)
+	assert.Equal(t, env.AnomalyRateWindow, 10*time.Minute)
+	assert.Equal(t, env.AnomalyRateThreshold, 20)
+	assert.Equal(t, env.AnomalyUsualHoursStart, 7)
+	assert.Equal(t, env.AnomalyUsualHoursEnd, 20)
+	assert.Equal(t, env.AnomalyTimezone, "UTC")
+	assert.Equal(t, env.AnomalyTagThreshold, 0.3)
+	assert.Equal(t, env.AnomalyAlertThreshold, 0.6)
+	assert.Equal(t, env.AnomalyApprovalThreshold, 0.0)
+	assert.Equal(t, env.NotificationInterval, 30*time.Second)
+	assert.Equal(t, env.PagerDutyEventsURL, "https://events.pagerduty.com/v2/enqueue")
+	assert.Equal(t, env.ScheduleSyncInterval, 5*time.Minute)
+	assert.Equal(t, env.RolloutSyncInterval, 30*time.Second)
+	assert.Equal(t, env.WebhookDeliveryInterval, 10*time.Second)
+	assert.Equal(t, env.WebhookMaxAttempts, 8)
+	assert.Equal(t, env.WebhookInitialBackoff, 30*time.Second)
+	assert.Equal(t, env.WebhookMaxBackoff, time.Hour)
+	assert.False(t, env.WorkloadIdentityEnabled)
+	assert.True(t, env.VaultReuseServiceToken)
+	assert.False(t, env.VaultWrapRunTokens)
+	assert.Equal(t, env.VaultWrapTTL, time.Hour)
+	assert.Equal(t, env.PublicStatsCacheTTL, time.Minute)
+	assert.Equal(t, env.PublicStatsRateLimit, float64(5))
+	assert.Equal(t, env.VaultPolicyWarningBytes, 49152)
+	assert.Equal(t, env.VaultPolicyMaxBytes, 65536)
+	assert.Equal(t, env.ProjectAliasTTL, 720*time.Hour)
+	assert.Equal(t, env.RunTokenRevokeInterval, 30*time.Second)
+	assert.Equal(t, env.RunTokenWatchInterval, 5*time.Second)
+	assert.Equal(t, env.LogChunkMaxBytes, 4194304)
+	assert.Empty(t, env.LogArchiveURL)
+	assert.Equal(t, env.LogArchiveEncoding, "zstd")
+	assert.Equal(t, env.LogArchiveFrameBytes, 1048576)
+	assert.Equal(t, env.LogArchiveWatchInterval, 30*time.Second)
+	assert.False(t, env.ArgoArchiveEnabled)
+	assert.Empty(t, env.ArgoArtifactsURL)
+	assert.Equal(t, env.StartupProbes, StartupProbesDegrade)
+	assert.Equal(t, env.StartupProbeTimeout, 30*time.Second)
+	assert.Empty(t, env.RecordDir)
+	assert.Empty(t, env.DBReplicaDSN)
+	assert.Equal(t, env.DBReplicaMaxLag, 30*time.Second)
+	assert.Equal(t, env.DBMaxOpenConns, 20)
+	assert.Equal(t, env.DBMaxIdleConns, 10)
+	assert.Equal(t, env.DBConnMaxLifetime, 30*time.Minute)
+	assert.Equal(t, env.DBConnMaxIdleTime, 5*time.Minute)
+	assert.Equal(t, env.DBHealthCheckInterval, 10*time.Second)
+	assert.Equal(t, env.DBReconnectMaxBackoff, 30*time.Second)
 }
 
 func TestValidations(t *testing.T) {
@@ -114,6 +206,562 @@ func TestValidations(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDuplicateSubmissionPolicyValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_DUPLICATE_SUBMISSION_POLICY", "ignore")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.Error(t, err)
+}
+
+func TestSecretScanPolicyValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_SECRET_SCAN_POLICY", "reject")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "secret scan policy must be one of 'off', 'warn' or 'block'")
+}
+
+func TestStartupProbesValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_STARTUP_PROBES", "strict")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "startup probes must be one of 'off', 'degrade' or 'fail'")
+}
+
+func TestITSMValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{
+			name: "servicenow",
+			vars: map[string]string{"ARGO_CLOUDOPS_ITSM_PROVIDER": "servicenow", "ARGO_CLOUDOPS_ITSM_ADDRESS": "https://example.service-now.com"},
+		},
+		{
+			name:    "unknown provider",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ITSM_PROVIDER": "remedy", "ARGO_CLOUDOPS_ITSM_ADDRESS": "https://example.com"},
+			wantErr: "itsm provider must be one of 'servicenow' or 'jira'",
+		},
+		{
+			name:    "missing address",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ITSM_PROVIDER": "servicenow"},
+			wantErr: "itsm address is required when an itsm provider is set",
+		},
+		{
+			name:    "missing jira project",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ITSM_PROVIDER": "jira", "ARGO_CLOUDOPS_ITSM_ADDRESS": "https://example.atlassian.net"},
+			wantErr: "itsm jira project is required for the jira itsm provider",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			for k, v := range tt.vars {
+				os.Setenv(k, v)
+			}
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestAuditSinkValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{
+			name: "splunk",
+			vars: map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "splunk", "ARGO_CLOUDOPS_AUDIT_SPLUNK_HEC_URL": "https://splunk:8088/services/collector/event", "ARGO_CLOUDOPS_AUDIT_SPLUNK_HEC_TOKEN": "token", "ARGO_CLOUDOPS_AUDIT_SPOOL_DIR": "/var/spool/cello"},
+		},
+		{
+			name: "syslog",
+			vars: map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "syslog", "ARGO_CLOUDOPS_AUDIT_SYSLOG_ADDRESS": "syslog:6514", "ARGO_CLOUDOPS_AUDIT_SPOOL_DIR": "/var/spool/cello"},
+		},
+		{
+			name:    "unknown sink",
+			vars:    map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "kafka", "ARGO_CLOUDOPS_AUDIT_SPOOL_DIR": "/var/spool/cello"},
+			wantErr: "audit sink must be one of 'splunk' or 'syslog'",
+		},
+		{
+			name:    "missing splunk token",
+			vars:    map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "splunk", "ARGO_CLOUDOPS_AUDIT_SPLUNK_HEC_URL": "https://splunk:8088/services/collector/event", "ARGO_CLOUDOPS_AUDIT_SPOOL_DIR": "/var/spool/cello"},
+			wantErr: "audit splunk hec url and token are required for the splunk audit sink",
+		},
+		{
+			name:    "missing syslog address",
+			vars:    map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "syslog", "ARGO_CLOUDOPS_AUDIT_SPOOL_DIR": "/var/spool/cello"},
+			wantErr: "audit syslog address is required for the syslog audit sink",
+		},
+		{
+			name:    "missing spool dir",
+			vars:    map[string]string{"ARGO_CLOUDOPS_AUDIT_SINK": "syslog", "ARGO_CLOUDOPS_AUDIT_SYSLOG_ADDRESS": "syslog:6514"},
+			wantErr: "audit spool dir is required when an audit sink is set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			for k, v := range tt.vars {
+				os.Setenv(k, v)
+			}
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetectorValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{
+			name: "heuristic",
+			vars: map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "heuristic", "ARGO_CLOUDOPS_ANOMALY_TIMEZONE": "Europe/Paris"},
+		},
+		{
+			name: "scorer",
+			vars: map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "scorer", "ARGO_CLOUDOPS_ANOMALY_SCORER_URL": "https://scorer.example.com/assess"},
+		},
+		{
+			name:    "unknown detector",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "ml"},
+			wantErr: "anomaly detector must be one of 'heuristic' or 'scorer'",
+		},
+		{
+			name:    "missing scorer url",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "scorer"},
+			wantErr: "anomaly scorer url is required for the scorer anomaly detector",
+		},
+		{
+			name:    "invalid timezone",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "heuristic", "ARGO_CLOUDOPS_ANOMALY_TIMEZONE": "Mars/Olympus"},
+			wantErr: "anomaly timezone is invalid: unknown time zone Mars/Olympus",
+		},
+		{
+			name:    "invalid threshold",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "heuristic", "ARGO_CLOUDOPS_ANOMALY_APPROVAL_THRESHOLD": "1.5"},
+			wantErr: "anomaly thresholds must be between 0 and 1",
+		},
+		{
+			name:    "approval without itsm provider",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ANOMALY_DETECTOR": "heuristic", "ARGO_CLOUDOPS_ANOMALY_APPROVAL_THRESHOLD": "0.9"},
+			wantErr: "an itsm provider is required for the anomaly approval threshold",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			for k, v := range tt.vars {
+				os.Setenv(k, v)
+			}
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestAnonymousReadOnlyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{
+			name: "public id key",
+			key:  testSecret,
+		},
+		{
+			name:    "short public id key",
+			key:     "short",
+			wantErr: "public id key must be at least 16 characters long when anonymous read only is enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			os.Setenv("ARGO_CLOUDOPS_ANONYMOUS_READ_ONLY", "true")
+			os.Setenv("ARGO_CLOUDOPS_PUBLIC_ID_KEY", tt.key)
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestShareLinkKeyValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_SHARE_LINK_KEY", "short")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "share link key must be at least 16 characters long")
+}
+
+func TestShareLinkExternalURLValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_SHARE_LINK_KEY", testSecret)
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "external url is required when the share link key is set")
+}
+
+func TestCredentialsProviderValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{
+			name: "vault",
+			vars: map[string]string{"VAULT_ROLE": "vaultRole", "VAULT_SECRET": testSecret, "VAULT_ADDR": "1.2.3.4"},
+		},
+		{
+			name:    "vault without address",
+			vars:    map[string]string{"VAULT_ROLE": "vaultRole", "VAULT_SECRET": testSecret},
+			wantErr: "vault role, secret and address are required for the vault credentials provider",
+		},
+		{
+			name: "aws secrets manager",
+			vars: map[string]string{"ARGO_CLOUDOPS_CREDENTIALS_PROVIDER": "aws_secrets_manager", "AWS_RUN_ROLE_ARN": "arn:aws:iam::123456789012:role/cello-run"},
+		},
+		{
+			name:    "aws secrets manager without run role",
+			vars:    map[string]string{"ARGO_CLOUDOPS_CREDENTIALS_PROVIDER": "aws_secrets_manager"},
+			wantErr: "aws run role arn is required for the aws_secrets_manager credentials provider",
+		},
+		{
+			name: "aws secrets manager with wrapped run tokens",
+			vars: map[string]string{
+				"ARGO_CLOUDOPS_CREDENTIALS_PROVIDER":  "aws_secrets_manager",
+				"AWS_RUN_ROLE_ARN":                    "arn:aws:iam::123456789012:role/cello-run",
+				"ARGO_CLOUDOPS_VAULT_WRAP_RUN_TOKENS": "true",
+			},
+			wantErr: "wrapped run tokens require the vault credentials provider",
+		},
+		{
+			name: "vault wrap ttl shorter than the vault default",
+			vars: map[string]string{
+				"ARGO_CLOUDOPS_VAULT_WRAP_RUN_TOKENS": "true",
+				"ARGO_CLOUDOPS_VAULT_WRAP_TTL":        "1m",
+			},
+			wantErr: "vault wrap ttl must be at least 5m0s",
+		},
+		{
+			name:    "unknown",
+			vars:    map[string]string{"ARGO_CLOUDOPS_CREDENTIALS_PROVIDER": "consul"},
+			wantErr: "credentials provider must be one of 'vault' or 'aws_secrets_manager'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			for k, v := range tt.vars {
+				os.Setenv(k, v)
+			}
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestProxyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr string
+	}{
+		{name: "proxy", key: "ARGO_CLOUDOPS_HTTPS_PROXY", value: "http://proxy:3128"},
+		{name: "standard proxy", key: "HTTPS_PROXY", value: "http://proxy:3128"},
+		{name: "not a url", key: "ARGO_CLOUDOPS_HTTPS_PROXY", value: "proxy:3128", wantErr: "https proxy must be a url, e.g. http://proxy:3128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			os.Setenv(tt.key, tt.value)
+			defer os.Unsetenv(tt.key)
+
+			// When
+			vars, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.value, vars.HTTPSProxy)
+		})
+	}
+}
+
+func TestDiscoveryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		wantErr string
+	}{
+		{name: "srv", vars: map[string]string{"VAULT_ADDR": "srv+https://_vault._tcp.example.com"}},
+		{name: "consul", vars: map[string]string{"VAULT_ADDR": "consul+https://vault?tag=active", "ARGO_CLOUDOPS_ARGO_ARTIFACTS_URL": "consul+https://argo-server"}},
+		{
+			name:    "srv with port",
+			vars:    map[string]string{"VAULT_ADDR": "srv+https://_vault._tcp.example.com:8200"},
+			wantErr: "invalid vault address, srv url must name the record without a port, e.g. srv+https://_vault._tcp.example.com",
+		},
+		{
+			name:    "unknown scheme",
+			vars:    map[string]string{"ARGO_CLOUDOPS_ARGO_ARTIFACTS_URL": "dns+https://argo-server"},
+			wantErr: "invalid argo artifacts url, url scheme 'dns+https' must be srv+https, srv+http, consul+https or consul+http",
+		},
+		{
+			name:    "argo addr",
+			vars:    map[string]string{"ARGO_ADDR": "srv+https://_argo._tcp.example.com"},
+			wantErr: "argo addr is only used for links to the argo ui, it must name a host",
+		},
+		{
+			name:    "refresh interval",
+			vars:    map[string]string{"ARGO_CLOUDOPS_DISCOVERY_REFRESH_INTERVAL": "0s"},
+			wantErr: "discovery refresh interval must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			setup()
+			os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+			os.Setenv("VAULT_ROLE", "vaultRole")
+			os.Setenv("VAULT_SECRET", testSecret)
+			os.Setenv("VAULT_ADDR", "1.2.3.4")
+			os.Setenv("ARGO_ADDR", "2.3.4.5")
+			os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+			for k, v := range tt.vars {
+				os.Setenv(k, v)
+			}
+
+			// When
+			_, err := GetEnv()
+
+			// Then
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestBreakGlassMaxTTLValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_BREAK_GLASS_MAX_TTL", "5m")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "break glass max ttl must be at least 15m")
+}
+
+func TestLogArchiveEncodingValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_LOG_ARCHIVE_URL", "s3://logs-bucket/cello")
+	os.Setenv("ARGO_CLOUDOPS_LOG_ARCHIVE_ENCODING", "br")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "log archive encoding must be one of 'gzip' or 'zstd'")
+}
+
+func TestWebhookMaxAttemptsValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_WEBHOOK_MAX_ATTEMPTS", "0")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "webhook max attempts must be at least 1")
+}
+
+func TestAttestationKeyValidation(t *testing.T) {
+	// Given
+	setup()
+	os.Setenv("ARGO_CLOUDOPS_ADMIN_SECRET", testSecret)
+	os.Setenv("VAULT_ROLE", "vaultRole")
+	os.Setenv("VAULT_SECRET", testSecret)
+	os.Setenv("VAULT_ADDR", "1.2.3.4")
+	os.Setenv("ARGO_ADDR", "2.3.4.5")
+	os.Setenv("ARGO_CLOUDOPS_GIT_AUTH_METHOD", "https")
+	os.Setenv("ARGO_CLOUDOPS_ATTESTATION_KEY", "short")
+
+	// When
+	_, err := GetEnv()
+
+	// Then
+	assert.EqualError(t, err, "attestation key must be at least 16 characters long")
+}
+
 func TestRequiredVars(t *testing.T) {
 	// Given
 	setup()
@@ -0,0 +1,295 @@
+package env
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// ServiceConfigVar is the environment variable with the path of the service
+// config file.
+const ServiceConfigVar = appPrefix + "_SERVICE_CONFIG"
+
+// FileErrors are the problems found in a service config file.
+type FileErrors []error
+
+func (e FileErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fileVar is a variable which can be set in the service config file.
+type fileVar struct {
+	// name of the key in the file, e.g. vault_addr.
+	name string
+	// key and alt are the environment variables of the variable, key is the
+	// one set from the file.
+	key      string
+	alt      string
+	typ      reflect.Type
+	required bool
+}
+
+// Lists the variables of Vars with their environment variables as envconfig
+// names them.
+var fileVarsTemplate = template.Must(template.New("vars").Parse(
+	"{{range .}}{{.Name}}\t{{.Key}}\t{{.Alt}}\t{{.Tags.Get \"required\"}}\n{{end}}"))
+
+// fileVars returns the variables which can be set in the service config file,
+// keyed by name. Names are the environment variables in lower case without
+// the ARGO_CLOUDOPS_ prefix.
+func fileVars() (map[string]fileVar, error) {
+	var buf bytes.Buffer
+	if err := envconfig.Usaget(appPrefix, &Vars{}, &buf, fileVarsTemplate); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(Vars{})
+	vars := map[string]fileVar{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		f, _ := t.FieldByName(fields[0])
+		name := strings.ToLower(strings.TrimPrefix(fields[1], appPrefix+"_"))
+		vars[name] = fileVar{
+			name:     name,
+			key:      fields[1],
+			alt:      fields[2],
+			typ:      f.Type,
+			required: fields[3] == "true",
+		}
+	}
+	return vars, nil
+}
+
+// envName returns the documented environment variable of the variable.
+func (v fileVar) envName() string {
+	if v.alt != "" {
+		return v.alt
+	}
+	return v.key
+}
+
+// isSet returns whether the variable is set in the environment.
+func (v fileVar) isSet() bool {
+	if _, ok := os.LookupEnv(v.key); ok {
+		return true
+	}
+	if v.alt == "" {
+		return false
+	}
+	_, ok := os.LookupEnv(v.alt)
+	return ok
+}
+
+// readFile reads a service config file, returning the environment variable
+// values it sets keyed by environment variable. All problems of the file are
+// returned as FileErrors.
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service config file: %w", err)
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, FileErrors{fmt.Errorf("invalid yaml, %w", err)}
+	}
+
+	vars, err := fileVars()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	var errs FileErrors
+	for _, item := range doc {
+		name := fmt.Sprint(item.Key)
+		v, ok := vars[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown key", name))
+			continue
+		}
+
+		value, err := fileValue(v, item.Value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		values[v.key] = value
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return values, nil
+}
+
+// fileValue returns the value of a variable as envconfig parses it from the
+// environment, resolving secret references.
+func fileValue(v fileVar, raw interface{}) (string, error) {
+	var value string
+	switch raw := raw.(type) {
+	case yaml.MapSlice:
+		ref, err := resolveSecretRef(raw)
+		if err != nil {
+			return "", err
+		}
+		value = ref
+	case []interface{}:
+		if v.typ.Kind() != reflect.Slice {
+			return "", fmt.Errorf("must be a %s, not a list", typeName(v.typ))
+		}
+		items := make([]string, 0, len(raw))
+		for _, item := range raw {
+			s := fmt.Sprint(item)
+			if strings.Contains(s, ",") {
+				return "", fmt.Errorf("list items can't contain ','")
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case nil:
+		return "", fmt.Errorf("must have a value")
+	default:
+		value = fmt.Sprint(raw)
+	}
+
+	if err := checkValue(v.typ, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// resolveSecretRef resolves a secret reference, either `file: <path>` reading
+// the trimmed contents of a file or `env: <name>` reading another environment
+// variable.
+func resolveSecretRef(ref yaml.MapSlice) (string, error) {
+	if len(ref) != 1 {
+		return "", fmt.Errorf("secret references must have a single 'file' or 'env' key")
+	}
+
+	source := fmt.Sprint(ref[0].Value)
+	switch ref[0].Key {
+	case "file":
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file '%s'", source)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case "env":
+		value, ok := os.LookupEnv(source)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable '%s' isn't set", source)
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("unknown secret reference '%v', must be 'file' or 'env'", ref[0].Key)
+	}
+}
+
+// checkValue checks the value can be parsed as the type by envconfig so the
+// error names the key in the file.
+func checkValue(t reflect.Type, value string) error {
+	var err error
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		_, err = time.ParseDuration(value)
+	case t.Kind() == reflect.Int:
+		_, err = strconv.Atoi(value)
+	case t.Kind() == reflect.Bool:
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("'%s' is not a valid %s", value, typeName(t))
+	}
+	return nil
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return "duration (e.g. 30s)"
+	case t.Kind() == reflect.Slice:
+		return "list"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// applyFile sets the environment variables of the service config file which
+// aren't set already, so environment variables override the file.
+func applyFile(path string) error {
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	vars, err := fileVars()
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		value, ok := values[v.key]
+		if !ok || v.isSet() {
+			continue
+		}
+		if err := os.Setenv(v.key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check validates the service config file, when not empty, and the variables
+// it results in with the environment, returning them or every problem found.
+// The file is applied to the environment like GetEnv does.
+func Check(path string) (Vars, []error) {
+	if path != "" {
+		if err := applyFile(path); err != nil {
+			var errs FileErrors
+			if errors.As(err, &errs) {
+				return Vars{}, errs
+			}
+			return Vars{}, []error{err}
+		}
+	}
+
+	vars, err := fileVars()
+	if err != nil {
+		return Vars{}, []error{err}
+	}
+
+	var errs []error
+	for _, v := range vars {
+		if v.required && !v.isSet() {
+			errs = append(errs, fmt.Errorf("%s is required, set it in the service config file or %s", v.name, v.envName()))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return Vars{}, errs
+	}
+
+	var values Vars
+	if err := envconfig.Process(appPrefix, &values); err != nil {
+		return Vars{}, []error{err}
+	}
+	if err := values.validate(); err != nil {
+		return Vars{}, []error{err}
+	}
+	return values, nil
+}
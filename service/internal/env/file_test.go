@@ -0,0 +1,199 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearFileVars unsets every variable the service config file can set, now
+// and once the test completes.
+func clearFileVars(t *testing.T) {
+	clear := func() {
+		vars, _ := fileVars()
+		for _, v := range vars {
+			os.Unsetenv(v.key)
+			if v.alt != "" {
+				os.Unsetenv(v.alt)
+			}
+		}
+		os.Unsetenv(ServiceConfigVar)
+		os.Unsetenv("TEST_DB_PASSWORD")
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	return path
+}
+
+func TestServiceConfigFile(t *testing.T) {
+	// Given
+	setup()
+	clearFileVars(t)
+	secretPath := writeFile(t, "admin_secret", testSecret+"\n")
+	path := writeFile(t, "service.yaml", `
+admin_secret:
+  file: `+secretPath+`
+vault_role: vaultRole
+vault_secret: vaultSecret
+vault_addr: https://vault:8200
+argo_addr: https://argo:2746
+git_auth_method: https
+db_host: localhost
+db_name: argocloudops
+db_user: argoco
+db_password:
+  env: TEST_DB_PASSWORD
+port: 9443
+image_uris:
+  - docker.myco.com/*
+  - argocloudops/*
+schedule_sync_interval: 2m
+workload_identity_enabled: true
+`)
+	os.Setenv(ServiceConfigVar, path)
+	os.Setenv("TEST_DB_PASSWORD", "1234")
+	// Environment variables override the file.
+	os.Setenv("VAULT_ROLE", "envRole")
+
+	// When
+	env, err := GetEnv()
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, testSecret, env.AdminSecret)
+	assert.Equal(t, "envRole", env.VaultRole)
+	assert.Equal(t, "https://vault:8200", env.VaultAddress)
+	assert.Equal(t, "1234", env.DBPassword)
+	assert.Equal(t, 9443, env.Port)
+	assert.Equal(t, []string{"docker.myco.com/*", "argocloudops/*"}, env.ImageURIs)
+	assert.Equal(t, 2*time.Minute, env.ScheduleSyncInterval)
+	assert.True(t, env.WorkloadIdentityEnabled)
+	assert.Equal(t, "argo", env.ArgoNamespace)
+}
+
+func TestServiceConfigFileErrors(t *testing.T) {
+	// Given
+	setup()
+	clearFileVars(t)
+	path := writeFile(t, "service.yaml", `
+vault_adress: https://vault:8200
+port: https
+schedule_sync_interval: 5
+workload_identity_enabled:
+  - true
+db_password:
+  env: TEST_DB_PASSWORD
+admin_secret:
+  vault: secret/cello
+`)
+
+	// When
+	_, errs := Check(path)
+
+	// Then
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	assert.Equal(t, []string{
+		"vault_adress: unknown key",
+		"port: 'https' is not a valid int",
+		"schedule_sync_interval: '5' is not a valid duration (e.g. 30s)",
+		"workload_identity_enabled: must be a bool, not a list",
+		"db_password: secret environment variable 'TEST_DB_PASSWORD' isn't set",
+		"admin_secret: unknown secret reference 'vault', must be 'file' or 'env'",
+	}, msgs)
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("missing required", func(t *testing.T) {
+		// Given
+		setup()
+		clearFileVars(t)
+		path := writeFile(t, "service.yaml", `
+admin_secret: `+testSecret+`
+vault_role: vaultRole
+vault_secret: vaultSecret
+vault_addr: https://vault:8200
+argo_addr: https://argo:2746
+git_auth_method: https
+`)
+
+		// When
+		_, errs := Check(path)
+
+		// Then
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		assert.Equal(t, []string{
+			"db_host is required, set it in the service config file or ARGO_CLOUDOPS_DB_HOST",
+			"db_name is required, set it in the service config file or ARGO_CLOUDOPS_DB_NAME",
+			"db_password is required, set it in the service config file or ARGO_CLOUDOPS_DB_PASSWORD",
+			"db_user is required, set it in the service config file or ARGO_CLOUDOPS_DB_USER",
+		}, msgs)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		// Given
+		setup()
+		clearFileVars(t)
+		path := writeFile(t, "service.yaml", `
+admin_secret: short
+vault_role: vaultRole
+vault_secret: vaultSecret
+vault_addr: https://vault:8200
+argo_addr: https://argo:2746
+git_auth_method: https
+db_host: localhost
+db_name: argocloudops
+db_user: argoco
+db_password: "1234"
+`)
+
+		// When
+		_, errs := Check(path)
+
+		// Then
+		if assert.Len(t, errs, 1) {
+			assert.EqualError(t, errs[0], "admin secret must be at least 16 characers long")
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		// Given
+		setup()
+		clearFileVars(t)
+		path := writeFile(t, "service.yaml", `
+admin_secret: `+testSecret+`
+vault_role: vaultRole
+vault_secret: vaultSecret
+vault_addr: https://vault:8200
+argo_addr: https://argo:2746
+git_auth_method: https
+db_host: localhost
+db_name: argocloudops
+db_user: argoco
+db_password: "1234"
+`)
+
+		// When
+		env, errs := Check(path)
+
+		// Then
+		assert.Empty(t, errs)
+		assert.Equal(t, "argoco", env.DBUser)
+	})
+}
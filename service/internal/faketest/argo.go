@@ -0,0 +1,368 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SubmittedWorkflow is a workflow submitted to the fake Argo.
+type SubmittedWorkflow struct {
+	From        string
+	Parameters  map[string]string
+	Labels      map[string]string
+	Annotations map[string]string
+	Scheduling  workflow.Scheduling
+	Status      workflow.Status
+	Logs        []string
+	// Nodes are the nodes of the workflow in order, see SetNodes.
+	Nodes []workflow.Node
+}
+
+// Argo is a fake workflow.Workflow keeping submitted workflows in memory.
+// Workflow names are generated deterministically. Operations are named after
+// the workflow.Workflow methods.
+type Argo struct {
+	*Script
+
+	mu        sync.Mutex
+	seq       int
+	workflows map[string]*SubmittedWorkflow
+}
+
+// NewArgo creates a fake Argo with no workflows.
+func NewArgo() *Argo {
+	return &Argo{
+		Script:    newScript(),
+		workflows: map[string]*SubmittedWorkflow{},
+	}
+}
+
+// Workflow returns a copy of a submitted workflow.
+func (a *Argo) Workflow(name string) (SubmittedWorkflow, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return SubmittedWorkflow{}, false
+	}
+	return *wf, true
+}
+
+// SetStatus sets the status (e.g. 'succeeded') of a submitted workflow.
+func (a *Argo) SetStatus(name, status string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Status.Status = status
+	return nil
+}
+
+// AddResult adds the result a step of a submitted workflow reported in its
+// result file.
+func (a *Argo) AddResult(name, step, result string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Status.Results = append(wf.Status.Results, workflow.StepResult{Step: step, Result: result})
+	return nil
+}
+
+// SetCreated sets the creation time of a submitted workflow, which defaults
+// to its sequence number.
+func (a *Argo) SetCreated(name string, created time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Status.Created = fmt.Sprint(created.Unix())
+	return nil
+}
+
+// SetFinished sets the time a submitted workflow finished.
+func (a *Argo) SetFinished(name string, finished time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Status.Finished = fmt.Sprint(finished.Unix())
+	return nil
+}
+
+// SetProgress sets the nodes of a submitted workflow which completed, out of
+// its total nodes.
+func (a *Argo) SetProgress(name string, completed, total int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Status.NodesCompleted = completed
+	wf.Status.NodesTotal = total
+	return nil
+}
+
+// AppendLogs adds log lines to a submitted workflow.
+func (a *Argo) AppendLogs(name string, lines ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Logs = append(wf.Logs, lines...)
+	return nil
+}
+
+// List returns a page of the status of the submitted workflows whose labels
+// have one of the values of the selector, sorted by name, whose continue
+// tokens are the offset of the following page.
+func (a *Argo) List(ctx context.Context, selector map[string][]string, opts workflow.ListOptions) ([]workflow.Status, string, error) {
+	if err := a.apply(ctx, "List"); err != nil {
+		return nil, "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	statuses := []workflow.Status{}
+	for _, wf := range a.workflows {
+		if matchLabelValues(wf.Labels, selector) {
+			statuses = append(statuses, wf.Status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	offset := 0
+	if opts.Continue != "" {
+		var err error
+		if offset, err = strconv.Atoi(opts.Continue); err != nil || offset < 0 || offset > len(statuses) {
+			return nil, "", fmt.Errorf("invalid continue token '%s'", opts.Continue)
+		}
+	}
+	statuses = statuses[offset:]
+	if opts.Limit > 0 && int64(len(statuses)) > opts.Limit {
+		return statuses[:opts.Limit], strconv.Itoa(offset + int(opts.Limit)), nil
+	}
+	return statuses, "", nil
+}
+
+func matchLabelValues(labels map[string]string, selector map[string][]string) bool {
+	for k, values := range selector {
+		matched := false
+		for _, v := range values {
+			if labels[k] == v {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ListByLabels returns the status of the submitted workflows having all of the
+// labels in the selector, sorted by name.
+func (a *Argo) ListByLabels(ctx context.Context, selector map[string]string) ([]workflow.Status, error) {
+	if err := a.apply(ctx, "ListByLabels"); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	statuses := []workflow.Status{}
+	for _, wf := range a.workflows {
+		if matchLabels(wf.Labels, selector) {
+			statuses = append(statuses, wf.Status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+func matchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Logs returns the lines of the logs of a submitted workflow selected by the
+// options. Lines are the logs of a single pod named after the workflow.
+func (a *Argo) Logs(ctx context.Context, workflowName string, opts workflow.LogOptions) (*workflow.Logs, error) {
+	if err := a.apply(ctx, "Logs"); err != nil {
+		return nil, err
+	}
+
+	wf, ok := a.Workflow(workflowName)
+	if !ok {
+		return nil, fmt.Errorf("workflow '%s' not found", workflowName)
+	}
+
+	logs := &workflow.Logs{}
+	size := 0
+	for i := opts.From[workflowName].Lines; i < len(wf.Logs); i++ {
+		if opts.MaxBytes > 0 && len(logs.Logs) > 0 && size+len(wf.Logs[i]) > opts.MaxBytes {
+			logs.Next = workflow.LogPositions{workflowName: {Lines: i}}
+			break
+		}
+		logs.Logs = append(logs.Logs, wf.Logs[i])
+		size += len(wf.Logs[i])
+	}
+	return logs, nil
+}
+
+// WalkLogs calls fn with the lines of the logs of a submitted workflow until
+// it returns false.
+func (a *Argo) WalkLogs(ctx context.Context, workflowName string, fn func(line string) bool) error {
+	if err := a.apply(ctx, "WalkLogs"); err != nil {
+		return err
+	}
+
+	wf, ok := a.Workflow(workflowName)
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", workflowName)
+	}
+	for _, line := range wf.Logs {
+		if !fn(line) {
+			break
+		}
+	}
+	return nil
+}
+
+// LogStream writes the logs of a submitted workflow.
+func (a *Argo) LogStream(ctx context.Context, workflowName string, w http.ResponseWriter) error {
+	if err := a.apply(ctx, "LogStream"); err != nil {
+		return err
+	}
+
+	wf, ok := a.Workflow(workflowName)
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", workflowName)
+	}
+	for _, line := range wf.Logs {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// SetNodes sets the nodes of a submitted workflow.
+func (a *Argo) SetNodes(name string, nodes ...workflow.Node) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[name]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", name)
+	}
+	wf.Nodes = append([]workflow.Node(nil), nodes...)
+	return nil
+}
+
+// Nodes returns the page of the nodes of a submitted workflow selected by the
+// options.
+func (a *Argo) Nodes(ctx context.Context, workflowName string, opts workflow.NodeOptions) (*workflow.Nodes, error) {
+	if err := a.apply(ctx, "Nodes"); err != nil {
+		return nil, err
+	}
+
+	wf, ok := a.Workflow(workflowName)
+	if !ok {
+		// Like the Argo server.
+		return nil, status.Errorf(codes.NotFound, "workflow '%s' not found", workflowName)
+	}
+	return workflow.PageNodes(wf.Nodes, opts), nil
+}
+
+// Status returns the status of a submitted workflow.
+func (a *Argo) Status(ctx context.Context, workflowName string) (*workflow.Status, error) {
+	if err := a.apply(ctx, "Status"); err != nil {
+		return nil, err
+	}
+
+	wf, ok := a.Workflow(workflowName)
+	if !ok {
+		// Like the Argo server.
+		return nil, status.Errorf(codes.NotFound, "workflow '%s' not found", workflowName)
+	}
+	s := wf.Status
+	s.Labels = copyMap(wf.Labels)
+	s.Annotations = copyMap(wf.Annotations)
+	s.Parameters = copyMap(wf.Parameters)
+	s.Results = append([]workflow.StepResult(nil), wf.Status.Results...)
+	return &s, nil
+}
+
+// Submit stores a workflow in the 'pending' state, named like Argo's generated
+// names but with a sequence number.
+func (a *Argo) Submit(ctx context.Context, from string, parameters map[string]string, labels map[string]string, annotations map[string]string, scheduling workflow.Scheduling) (string, error) {
+	if err := a.apply(ctx, "Submit"); err != nil {
+		return "", fmt.Errorf("failed to submit workflow: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	name := fmt.Sprintf("%s-%s-%05d", parameters["project_name"], parameters["target_name"], a.seq)
+	a.workflows[name] = &SubmittedWorkflow{
+		From:        from,
+		Parameters:  copyMap(parameters),
+		Labels:      copyMap(labels),
+		Annotations: copyMap(annotations),
+		Scheduling:  scheduling,
+		Status: workflow.Status{
+			Name:    name,
+			Status:  "pending",
+			Created: fmt.Sprint(a.seq),
+		},
+	}
+	return name, nil
+}
+
+// Terminate sets the status of a submitted workflow to 'failed', like Argo
+// does for terminated workflows.
+func (a *Argo) Terminate(ctx context.Context, workflowName string) error {
+	if err := a.apply(ctx, "Terminate"); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	wf, ok := a.workflows[workflowName]
+	if !ok {
+		return fmt.Errorf("workflow '%s' not found", workflowName)
+	}
+	wf.Status.Status = "failed"
+	return nil
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,103 @@
+package faketest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/clock"
+)
+
+// Clock is a fake clock.Clock whose time only moves when advanced. Tickers
+// tick when the time is advanced past their next tick.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewClock creates a fake clock at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker creates a ticker ticking every interval from now.
+func (c *Clock) NewTicker(interval time.Duration) clock.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), interval: interval, next: c.now.Add(interval)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Tickers returns the number of tickers which aren't stopped, e.g. to wait
+// for a background job to start before advancing the clock.
+func (c *Clock) Tickers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.tickers {
+		if !t.stopped() {
+			n++
+		}
+	}
+	return n
+}
+
+// Advance moves the clock forward by d, ticking the tickers whose next tick
+// passed. Like time.Ticker, ticks are dropped when the previous one wasn't
+// received yet.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		t.tick(c.now)
+	}
+}
+
+type fakeTicker struct {
+	c        chan time.Time
+	interval time.Duration
+
+	mu     sync.Mutex
+	next   time.Time
+	closed bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+func (t *fakeTicker) stopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+func (t *fakeTicker) tick(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || now.Before(t.next) {
+		return
+	}
+	for !now.Before(t.next) {
+		t.next = t.next.Add(t.interval)
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
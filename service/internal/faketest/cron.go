@@ -0,0 +1,78 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cello-proj/cello/service/internal/workflow"
+)
+
+// CronWorkflows is a fake workflow.CronWorkflows keeping cron workflows in
+// memory. Triggered cron workflows are submitted to the fake Argo.
+// Operations are named after the workflow.CronWorkflows methods.
+type CronWorkflows struct {
+	*Script
+
+	argo *Argo
+
+	mu    sync.Mutex
+	crons map[string]workflow.CronWorkflow
+}
+
+// NewCronWorkflows creates fake cron workflows submitting to argo.
+func NewCronWorkflows(argo *Argo) *CronWorkflows {
+	return &CronWorkflows{
+		Script: newScript(),
+		argo:   argo,
+		crons:  map[string]workflow.CronWorkflow{},
+	}
+}
+
+// CronWorkflow returns an applied cron workflow.
+func (c *CronWorkflows) CronWorkflow(name string) (workflow.CronWorkflow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cw, ok := c.crons[name]
+	return cw, ok
+}
+
+// Trigger submits a workflow of a cron workflow like Argo does on schedule,
+// returning the workflow name. Suspended cron workflows can't be triggered.
+func (c *CronWorkflows) Trigger(ctx context.Context, name string) (string, error) {
+	cw, ok := c.CronWorkflow(name)
+	if !ok {
+		return "", fmt.Errorf("cron workflow '%s' not found", name)
+	}
+	if cw.Suspend {
+		return "", fmt.Errorf("cron workflow '%s' is suspended", name)
+	}
+	return c.argo.Submit(ctx, cw.From, cw.Parameters, cw.Labels, nil, cw.Scheduling)
+}
+
+// Apply stores a cron workflow, replacing any existing one.
+func (c *CronWorkflows) Apply(ctx context.Context, cw workflow.CronWorkflow) error {
+	if err := c.apply(ctx, "Apply"); err != nil {
+		return err
+	}
+
+	cw.Parameters = copyMap(cw.Parameters)
+	cw.Labels = copyMap(cw.Labels)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.crons[cw.Name] = cw
+	return nil
+}
+
+// Delete removes a cron workflow.
+func (c *CronWorkflows) Delete(ctx context.Context, name string) error {
+	if err := c.apply(ctx, "Delete"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.crons, name)
+	return nil
+}
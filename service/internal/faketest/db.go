@@ -0,0 +1,2175 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/db"
+
+	upper "github.com/upper/db/v4"
+)
+
+// DB is a fake db.Client storing entries in memory. Operations are named after
+// the db.Client methods.
+type DB struct {
+	*Script
+
+	mu         sync.Mutex
+	projects   map[string]db.ProjectEntry
+	events     []db.ExecutionEvent
+	guardrails map[string]db.TargetGuardrailEntry
+	controls   map[string]db.TargetChangeControlEntry
+	rules      map[string]db.ProjectNotificationRuleEntry
+	hours      map[string]db.ProjectBusinessHoursEntry
+	templates  map[string]db.ProjectWorkflowTemplateEntry
+	flags      map[string]db.ProjectFeatureFlagEntry
+	registries map[string]db.ProjectRegistryCredentialEntry
+	schedules  map[string]db.TargetScheduleEntry
+	scheduling map[string]db.TargetSchedulingEntry
+	identities map[string]db.TargetWorkloadIdentityEntry
+	runTokens  []db.RunTokenEntry
+	changeSets map[string]db.ChangeSetSummaryEntry
+	plans      map[string]db.PlanSummaryEntry
+	inventory  map[string]db.TargetInventoryEntry
+	breakGlass []db.BreakGlassEntry
+	settings   map[string]db.ProjectSettingsEntry
+	costTags   map[string]db.TargetCostAllocationTagsEntry
+	attests    map[string]db.ExecutionAttestationEntry
+	sources    map[string]db.TargetSubmissionSourceEntry
+	deps       []db.TargetDependencyEntry
+	rollouts   map[string]db.RolloutEntry
+	notes      []db.ExecutionNoteEntry
+	webhooks   []db.ProjectWebhookEntry
+	deliveries []db.WebhookDeliveryEntry
+	keys       map[string]db.ProjectEncryptionKeyEntry
+	ids        map[string]db.ResourceIDEntry
+	aliases    map[string]db.ProjectAliasEntry
+	envs       map[string]db.ProjectEnvironmentEntry
+	soakTimes  map[string]db.TargetSoakTimeEntry
+	tfStates   map[string]db.TargetTerraformStateEntry
+	elevations []db.ProjectElevationEntry
+	images     []db.FrameworkImageEntry
+}
+
+// NewDB creates an empty fake DB.
+func NewDB() *DB {
+	return &DB{
+		Script:     newScript(),
+		projects:   map[string]db.ProjectEntry{},
+		guardrails: map[string]db.TargetGuardrailEntry{},
+		controls:   map[string]db.TargetChangeControlEntry{},
+		rules:      map[string]db.ProjectNotificationRuleEntry{},
+		hours:      map[string]db.ProjectBusinessHoursEntry{},
+		templates:  map[string]db.ProjectWorkflowTemplateEntry{},
+		flags:      map[string]db.ProjectFeatureFlagEntry{},
+		registries: map[string]db.ProjectRegistryCredentialEntry{},
+		schedules:  map[string]db.TargetScheduleEntry{},
+		scheduling: map[string]db.TargetSchedulingEntry{},
+		identities: map[string]db.TargetWorkloadIdentityEntry{},
+		changeSets: map[string]db.ChangeSetSummaryEntry{},
+		plans:      map[string]db.PlanSummaryEntry{},
+		inventory:  map[string]db.TargetInventoryEntry{},
+		settings:   map[string]db.ProjectSettingsEntry{},
+		costTags:   map[string]db.TargetCostAllocationTagsEntry{},
+		attests:    map[string]db.ExecutionAttestationEntry{},
+		sources:    map[string]db.TargetSubmissionSourceEntry{},
+		rollouts:   map[string]db.RolloutEntry{},
+		keys:       map[string]db.ProjectEncryptionKeyEntry{},
+		ids:        map[string]db.ResourceIDEntry{},
+		aliases:    map[string]db.ProjectAliasEntry{},
+		envs:       map[string]db.ProjectEnvironmentEntry{},
+		soakTimes:  map[string]db.TargetSoakTimeEntry{},
+		tfStates:   map[string]db.TargetTerraformStateEntry{},
+	}
+}
+
+// CreateProjectEntry stores a project entry, replacing any existing one.
+func (d *DB) CreateProjectEntry(ctx context.Context, pe db.ProjectEntry) error {
+	if err := d.apply(ctx, "CreateProjectEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.projects[pe.ProjectID] = pe
+	return nil
+}
+
+// ReadProjectEntry returns a project entry, or upper's ErrNoMoreRows like the
+// SQL client when it doesn't exist.
+func (d *DB) ReadProjectEntry(ctx context.Context, project string) (db.ProjectEntry, error) {
+	if err := d.apply(ctx, "ReadProjectEntry"); err != nil {
+		return db.ProjectEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pe, ok := d.projects[project]
+	if !ok {
+		return db.ProjectEntry{}, upper.ErrNoMoreRows
+	}
+	return pe, nil
+}
+
+// ListProjectEntries returns all project entries ordered by project.
+func (d *DB) ListProjectEntries(ctx context.Context) ([]db.ProjectEntry, error) {
+	if err := d.apply(ctx, "ListProjectEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := []string{}
+	for k := range d.projects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := []db.ProjectEntry{}
+	for _, k := range keys {
+		res = append(res, d.projects[k])
+	}
+	return res, nil
+}
+
+// DeleteProjectEntry removes a project entry.
+func (d *DB) DeleteProjectEntry(ctx context.Context, project string) error {
+	if err := d.apply(ctx, "DeleteProjectEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.projects, project)
+	return nil
+}
+
+// CreateExecutionEvent stores an execution event.
+func (d *DB) CreateExecutionEvent(ctx context.Context, ee db.ExecutionEvent) error {
+	if err := d.apply(ctx, "CreateExecutionEvent"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, ee)
+	return nil
+}
+
+// ExecutionEvents returns the stored execution events in creation order.
+func (d *DB) ExecutionEvents() []db.ExecutionEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]db.ExecutionEvent{}, d.events...)
+}
+
+// CreateTargetGuardrailEntry stores a target guardrail, replacing any existing
+// one.
+func (d *DB) CreateTargetGuardrailEntry(ctx context.Context, tg db.TargetGuardrailEntry) error {
+	if err := d.apply(ctx, "CreateTargetGuardrailEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.guardrails[tg.Project+"/"+tg.Target] = tg
+	return nil
+}
+
+// ReadTargetGuardrailEntry returns a target guardrail, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetGuardrailEntry(ctx context.Context, project, target string) (db.TargetGuardrailEntry, error) {
+	if err := d.apply(ctx, "ReadTargetGuardrailEntry"); err != nil {
+		return db.TargetGuardrailEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tg, ok := d.guardrails[project+"/"+target]
+	if !ok {
+		return db.TargetGuardrailEntry{}, upper.ErrNoMoreRows
+	}
+	return tg, nil
+}
+
+// DeleteTargetGuardrailEntry removes a target guardrail.
+func (d *DB) DeleteTargetGuardrailEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetGuardrailEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.guardrails, project+"/"+target)
+	return nil
+}
+
+// CreateTargetChangeControlEntry stores a target change control, replacing any
+// existing one.
+func (d *DB) CreateTargetChangeControlEntry(ctx context.Context, tc db.TargetChangeControlEntry) error {
+	if err := d.apply(ctx, "CreateTargetChangeControlEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.controls[tc.Project+"/"+tc.Target] = tc
+	return nil
+}
+
+// ReadTargetChangeControlEntry returns a target change control, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetChangeControlEntry(ctx context.Context, project, target string) (db.TargetChangeControlEntry, error) {
+	if err := d.apply(ctx, "ReadTargetChangeControlEntry"); err != nil {
+		return db.TargetChangeControlEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tc, ok := d.controls[project+"/"+target]
+	if !ok {
+		return db.TargetChangeControlEntry{}, upper.ErrNoMoreRows
+	}
+	return tc, nil
+}
+
+// DeleteTargetChangeControlEntry removes a target change control.
+func (d *DB) DeleteTargetChangeControlEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetChangeControlEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.controls, project+"/"+target)
+	return nil
+}
+
+// CreateProjectNotificationRuleEntry stores a project notification rule,
+// replacing any existing one of the same type.
+func (d *DB) CreateProjectNotificationRuleEntry(ctx context.Context, nr db.ProjectNotificationRuleEntry) error {
+	if err := d.apply(ctx, "CreateProjectNotificationRuleEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules[nr.Project+"/"+nr.Type] = nr
+	return nil
+}
+
+// ListProjectNotificationRuleEntries returns the notification rules of a
+// project ordered by type.
+func (d *DB) ListProjectNotificationRuleEntries(ctx context.Context, project string) ([]db.ProjectNotificationRuleEntry, error) {
+	if err := d.apply(ctx, "ListProjectNotificationRuleEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rules := []db.ProjectNotificationRuleEntry{}
+	for _, nr := range d.rules {
+		if nr.Project == project {
+			rules = append(rules, nr)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Type < rules[j].Type })
+	return rules, nil
+}
+
+// DeleteProjectNotificationRuleEntry removes a project notification rule.
+func (d *DB) DeleteProjectNotificationRuleEntry(ctx context.Context, project, ruleType string) error {
+	if err := d.apply(ctx, "DeleteProjectNotificationRuleEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.rules, project+"/"+ruleType)
+	return nil
+}
+
+// CreateProjectBusinessHoursEntry stores project business hours, replacing
+// any existing ones.
+func (d *DB) CreateProjectBusinessHoursEntry(ctx context.Context, bh db.ProjectBusinessHoursEntry) error {
+	if err := d.apply(ctx, "CreateProjectBusinessHoursEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hours[bh.Project] = bh
+	return nil
+}
+
+// ReadProjectBusinessHoursEntry returns project business hours, or upper's
+// ErrNoMoreRows like the SQL client when they don't exist.
+func (d *DB) ReadProjectBusinessHoursEntry(ctx context.Context, project string) (db.ProjectBusinessHoursEntry, error) {
+	if err := d.apply(ctx, "ReadProjectBusinessHoursEntry"); err != nil {
+		return db.ProjectBusinessHoursEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bh, ok := d.hours[project]
+	if !ok {
+		return db.ProjectBusinessHoursEntry{}, upper.ErrNoMoreRows
+	}
+	return bh, nil
+}
+
+// DeleteProjectBusinessHoursEntry removes project business hours.
+func (d *DB) DeleteProjectBusinessHoursEntry(ctx context.Context, project string) error {
+	if err := d.apply(ctx, "DeleteProjectBusinessHoursEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.hours, project)
+	return nil
+}
+
+// CreateProjectWorkflowTemplateEntry allows a workflow template for a
+// project.
+func (d *DB) CreateProjectWorkflowTemplateEntry(ctx context.Context, wt db.ProjectWorkflowTemplateEntry) error {
+	if err := d.apply(ctx, "CreateProjectWorkflowTemplateEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.templates[wt.Project+"/"+wt.Kind+"/"+wt.Name] = wt
+	return nil
+}
+
+// ListProjectWorkflowTemplateEntries returns the workflow templates allowed
+// for a project ordered by kind and name.
+func (d *DB) ListProjectWorkflowTemplateEntries(ctx context.Context, project string) ([]db.ProjectWorkflowTemplateEntry, error) {
+	if err := d.apply(ctx, "ListProjectWorkflowTemplateEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	templates := []db.ProjectWorkflowTemplateEntry{}
+	for _, wt := range d.templates {
+		if wt.Project == project {
+			templates = append(templates, wt)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Kind != templates[j].Kind {
+			return templates[i].Kind < templates[j].Kind
+		}
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// DeleteProjectWorkflowTemplateEntry disallows a workflow template for a
+// project.
+func (d *DB) DeleteProjectWorkflowTemplateEntry(ctx context.Context, project, kind, name string) error {
+	if err := d.apply(ctx, "DeleteProjectWorkflowTemplateEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.templates, project+"/"+kind+"/"+name)
+	return nil
+}
+
+// CreateProjectFeatureFlagEntry stores a feature flag override of a project,
+// replacing any existing one.
+func (d *DB) CreateProjectFeatureFlagEntry(ctx context.Context, ff db.ProjectFeatureFlagEntry) error {
+	if err := d.apply(ctx, "CreateProjectFeatureFlagEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flags[ff.Project+"/"+ff.Name] = ff
+	return nil
+}
+
+// ListProjectFeatureFlagEntries returns the feature flag overrides of a
+// project ordered by name.
+func (d *DB) ListProjectFeatureFlagEntries(ctx context.Context, project string) ([]db.ProjectFeatureFlagEntry, error) {
+	if err := d.apply(ctx, "ListProjectFeatureFlagEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	flags := []db.ProjectFeatureFlagEntry{}
+	for _, ff := range d.flags {
+		if ff.Project == project {
+			flags = append(flags, ff)
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+// DeleteProjectFeatureFlagEntry removes a feature flag override of a project.
+func (d *DB) DeleteProjectFeatureFlagEntry(ctx context.Context, project, name string) error {
+	if err := d.apply(ctx, "DeleteProjectFeatureFlagEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.flags, project+"/"+name)
+	return nil
+}
+
+// CreateProjectRegistryCredentialEntry stores the credentials of a project
+// for a registry, replacing any existing ones.
+func (d *DB) CreateProjectRegistryCredentialEntry(ctx context.Context, rc db.ProjectRegistryCredentialEntry) error {
+	if err := d.apply(ctx, "CreateProjectRegistryCredentialEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.registries[rc.Project+"/"+rc.Registry] = rc
+	return nil
+}
+
+// ListProjectRegistryCredentialEntries returns the registry credentials of a
+// project ordered by registry.
+func (d *DB) ListProjectRegistryCredentialEntries(ctx context.Context, project string) ([]db.ProjectRegistryCredentialEntry, error) {
+	if err := d.apply(ctx, "ListProjectRegistryCredentialEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	registries := []db.ProjectRegistryCredentialEntry{}
+	for _, rc := range d.registries {
+		if rc.Project == project {
+			registries = append(registries, rc)
+		}
+	}
+	sort.Slice(registries, func(i, j int) bool { return registries[i].Registry < registries[j].Registry })
+	return registries, nil
+}
+
+// DeleteProjectRegistryCredentialEntry removes the credentials of a project
+// for a registry.
+func (d *DB) DeleteProjectRegistryCredentialEntry(ctx context.Context, project, registry string) error {
+	if err := d.apply(ctx, "DeleteProjectRegistryCredentialEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.registries, project+"/"+registry)
+	return nil
+}
+
+// CreateTargetScheduleEntry stores a target schedule, replacing any existing
+// one with the same name.
+func (d *DB) CreateTargetScheduleEntry(ctx context.Context, ts db.TargetScheduleEntry) error {
+	if err := d.apply(ctx, "CreateTargetScheduleEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.schedules[ts.Project+"/"+ts.Target+"/"+ts.Name] = ts
+	return nil
+}
+
+// ListTargetScheduleEntries returns the schedules of a target ordered by
+// name.
+func (d *DB) ListTargetScheduleEntries(ctx context.Context, project, target string) ([]db.TargetScheduleEntry, error) {
+	if err := d.apply(ctx, "ListTargetScheduleEntries"); err != nil {
+		return nil, err
+	}
+
+	return d.listSchedules(func(ts db.TargetScheduleEntry) bool {
+		return ts.Project == project && ts.Target == target
+	}), nil
+}
+
+// ListScheduleEntries returns all schedules ordered by project, target and
+// name.
+func (d *DB) ListScheduleEntries(ctx context.Context) ([]db.TargetScheduleEntry, error) {
+	if err := d.apply(ctx, "ListScheduleEntries"); err != nil {
+		return nil, err
+	}
+
+	return d.listSchedules(func(db.TargetScheduleEntry) bool { return true }), nil
+}
+
+func (d *DB) listSchedules(match func(db.TargetScheduleEntry) bool) []db.TargetScheduleEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := []string{}
+	for k, ts := range d.schedules {
+		if match(ts) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	schedules := []db.TargetScheduleEntry{}
+	for _, k := range keys {
+		schedules = append(schedules, d.schedules[k])
+	}
+	return schedules
+}
+
+// DeleteTargetScheduleEntry removes a target schedule.
+func (d *DB) DeleteTargetScheduleEntry(ctx context.Context, project, target, name string) error {
+	if err := d.apply(ctx, "DeleteTargetScheduleEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.schedules, project+"/"+target+"/"+name)
+	return nil
+}
+
+// CreateTargetSchedulingEntry stores a target scheduling, replacing any existing
+// one.
+func (d *DB) CreateTargetSchedulingEntry(ctx context.Context, e db.TargetSchedulingEntry) error {
+	if err := d.apply(ctx, "CreateTargetSchedulingEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scheduling[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetSchedulingEntry returns a target scheduling, or upper's ErrNoMoreRows like
+// the SQL client when it doesn't exist.
+func (d *DB) ReadTargetSchedulingEntry(ctx context.Context, project, target string) (db.TargetSchedulingEntry, error) {
+	if err := d.apply(ctx, "ReadTargetSchedulingEntry"); err != nil {
+		return db.TargetSchedulingEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.scheduling[project+"/"+target]
+	if !ok {
+		return db.TargetSchedulingEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetSchedulingEntry removes a target scheduling.
+func (d *DB) DeleteTargetSchedulingEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetSchedulingEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.scheduling, project+"/"+target)
+	return nil
+}
+
+// CreateTargetWorkloadIdentityEntry stores a target workload identity, replacing any existing
+// one.
+func (d *DB) CreateTargetWorkloadIdentityEntry(ctx context.Context, e db.TargetWorkloadIdentityEntry) error {
+	if err := d.apply(ctx, "CreateTargetWorkloadIdentityEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.identities[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetWorkloadIdentityEntry returns a target workload identity, or upper's ErrNoMoreRows like
+// the SQL client when it doesn't exist.
+func (d *DB) ReadTargetWorkloadIdentityEntry(ctx context.Context, project, target string) (db.TargetWorkloadIdentityEntry, error) {
+	if err := d.apply(ctx, "ReadTargetWorkloadIdentityEntry"); err != nil {
+		return db.TargetWorkloadIdentityEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.identities[project+"/"+target]
+	if !ok {
+		return db.TargetWorkloadIdentityEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetWorkloadIdentityEntry removes a target workload identity.
+func (d *DB) DeleteTargetWorkloadIdentityEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetWorkloadIdentityEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.identities, project+"/"+target)
+	return nil
+}
+
+// CreateRunTokenEntry stores the token of a workflow run.
+func (d *DB) CreateRunTokenEntry(ctx context.Context, rt db.RunTokenEntry) error {
+	if err := d.apply(ctx, "CreateRunTokenEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.runTokens = append(d.runTokens, rt)
+	return nil
+}
+
+// ListRunTokenEntries returns the tokens of workflow runs in creation order.
+func (d *DB) ListRunTokenEntries(ctx context.Context) ([]db.RunTokenEntry, error) {
+	if err := d.apply(ctx, "ListRunTokenEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]db.RunTokenEntry{}, d.runTokens...), nil
+}
+
+// DeleteRunTokenEntry removes the token of a workflow run.
+func (d *DB) DeleteRunTokenEntry(ctx context.Context, workflowName string) error {
+	if err := d.apply(ctx, "DeleteRunTokenEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	runTokens := []db.RunTokenEntry{}
+	for _, rt := range d.runTokens {
+		if rt.WorkflowName != workflowName {
+			runTokens = append(runTokens, rt)
+		}
+	}
+	d.runTokens = runTokens
+	return nil
+}
+
+// CreateChangeSetSummaryEntry stores the summary of a change set, replacing
+// any existing one.
+func (d *DB) CreateChangeSetSummaryEntry(ctx context.Context, cs db.ChangeSetSummaryEntry) error {
+	if err := d.apply(ctx, "CreateChangeSetSummaryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.changeSets[cs.Project+"/"+cs.Target+"/"+cs.StackName+"/"+cs.ChangeSetName] = cs
+	return nil
+}
+
+// ReadChangeSetSummaryEntry returns the summary of a change set, or upper's
+// ErrNoMoreRows when there is none.
+func (d *DB) ReadChangeSetSummaryEntry(ctx context.Context, project, target, stackName, changeSetName string) (db.ChangeSetSummaryEntry, error) {
+	if err := d.apply(ctx, "ReadChangeSetSummaryEntry"); err != nil {
+		return db.ChangeSetSummaryEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cs, ok := d.changeSets[project+"/"+target+"/"+stackName+"/"+changeSetName]
+	if !ok {
+		return db.ChangeSetSummaryEntry{}, upper.ErrNoMoreRows
+	}
+	return cs, nil
+}
+
+// DeleteChangeSetSummaryEntries removes the change set summaries of a target.
+func (d *DB) DeleteChangeSetSummaryEntries(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteChangeSetSummaryEntries"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, cs := range d.changeSets {
+		if cs.Project == project && cs.Target == target {
+			delete(d.changeSets, k)
+		}
+	}
+	return nil
+}
+
+// CreatePlanSummaryEntry stores the plan summary of a diff.
+func (d *DB) CreatePlanSummaryEntry(ctx context.Context, e db.PlanSummaryEntry) error {
+	if err := d.apply(ctx, "CreatePlanSummaryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.plans[e.WorkflowName] = e
+	return nil
+}
+
+// ReadPlanSummaryEntry returns the plan summary of a diff, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadPlanSummaryEntry(ctx context.Context, workflowName string) (db.PlanSummaryEntry, error) {
+	if err := d.apply(ctx, "ReadPlanSummaryEntry"); err != nil {
+		return db.PlanSummaryEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.plans[workflowName]
+	if !ok {
+		return db.PlanSummaryEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// ReadCommitPlanSummaryEntry returns the latest plan summary of the diffs of
+// a commit to a target, or upper's ErrNoMoreRows when there's none.
+func (d *DB) ReadCommitPlanSummaryEntry(ctx context.Context, project, target, commitHash string) (db.PlanSummaryEntry, error) {
+	if err := d.apply(ctx, "ReadCommitPlanSummaryEntry"); err != nil {
+		return db.PlanSummaryEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var latest db.PlanSummaryEntry
+	found := false
+	for _, e := range d.plans {
+		if e.Project != project || e.Target != target || e.CommitHash != commitHash {
+			continue
+		}
+		if !found || e.CreatedAt.After(latest.CreatedAt) {
+			latest, found = e, true
+		}
+	}
+	if !found {
+		return db.PlanSummaryEntry{}, upper.ErrNoMoreRows
+	}
+	return latest, nil
+}
+
+// DeletePlanSummaryEntries removes the plan summaries of a target.
+func (d *DB) DeletePlanSummaryEntries(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeletePlanSummaryEntries"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, e := range d.plans {
+		if e.Project == project && e.Target == target {
+			delete(d.plans, k)
+		}
+	}
+	return nil
+}
+
+// CreateTargetInventoryEntry stores a target inventory, replacing any existing
+// one.
+func (d *DB) CreateTargetInventoryEntry(ctx context.Context, e db.TargetInventoryEntry) error {
+	if err := d.apply(ctx, "CreateTargetInventoryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inventory[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetInventoryEntry returns a target inventory, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetInventoryEntry(ctx context.Context, project, target string) (db.TargetInventoryEntry, error) {
+	if err := d.apply(ctx, "ReadTargetInventoryEntry"); err != nil {
+		return db.TargetInventoryEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.inventory[project+"/"+target]
+	if !ok {
+		return db.TargetInventoryEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetInventoryEntry removes a target inventory.
+func (d *DB) DeleteTargetInventoryEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetInventoryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inventory, project+"/"+target)
+	return nil
+}
+
+// CreateBreakGlassEntry stores the audit record of break-glass credentials.
+func (d *DB) CreateBreakGlassEntry(ctx context.Context, e db.BreakGlassEntry) error {
+	if err := d.apply(ctx, "CreateBreakGlassEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, bg := range d.breakGlass {
+		if bg.ID == e.ID {
+			return fmt.Errorf("break-glass record %s already exists", e.ID)
+		}
+	}
+	d.breakGlass = append(d.breakGlass, e)
+	return nil
+}
+
+// ListBreakGlassEntries returns the break-glass records of a target in
+// creation order.
+func (d *DB) ListBreakGlassEntries(ctx context.Context, project, target string) ([]db.BreakGlassEntry, error) {
+	if err := d.apply(ctx, "ListBreakGlassEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.BreakGlassEntry{}
+	for _, e := range d.breakGlass {
+		if e.Project == project && e.Target == target {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// ListUnrevokedBreakGlassEntries returns the break-glass records whose lease
+// wasn't revoked, in creation order.
+func (d *DB) ListUnrevokedBreakGlassEntries(ctx context.Context) ([]db.BreakGlassEntry, error) {
+	if err := d.apply(ctx, "ListUnrevokedBreakGlassEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.BreakGlassEntry{}
+	for _, e := range d.breakGlass {
+		if e.RevokedAt == nil {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// RevokeBreakGlassEntry records the revocation of the lease of break-glass
+// credentials.
+func (d *DB) RevokeBreakGlassEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	if err := d.apply(ctx, "RevokeBreakGlassEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.breakGlass {
+		if d.breakGlass[i].ID == id {
+			t := revokedAt
+			d.breakGlass[i].RevokedAt = &t
+		}
+	}
+	return nil
+}
+
+// CreateProjectSettingsEntry stores the settings of a project, replacing any
+// existing ones.
+func (d *DB) CreateProjectSettingsEntry(ctx context.Context, e db.ProjectSettingsEntry) error {
+	if err := d.apply(ctx, "CreateProjectSettingsEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.settings[e.Project] = e
+	return nil
+}
+
+// ReadProjectSettingsEntry returns the settings of a project, or upper's
+// ErrNoMoreRows like the SQL client when they don't exist.
+func (d *DB) ReadProjectSettingsEntry(ctx context.Context, project string) (db.ProjectSettingsEntry, error) {
+	if err := d.apply(ctx, "ReadProjectSettingsEntry"); err != nil {
+		return db.ProjectSettingsEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.settings[project]
+	if !ok {
+		return db.ProjectSettingsEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// CreateProjectEnvironmentEntry stores an environment of a project, replacing
+// any existing one.
+func (d *DB) CreateProjectEnvironmentEntry(ctx context.Context, e db.ProjectEnvironmentEntry) error {
+	if err := d.apply(ctx, "CreateProjectEnvironmentEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.envs[e.Project+"/"+e.Name] = e
+	return nil
+}
+
+// ReadProjectEnvironmentEntry returns an environment of a project, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadProjectEnvironmentEntry(ctx context.Context, project, name string) (db.ProjectEnvironmentEntry, error) {
+	if err := d.apply(ctx, "ReadProjectEnvironmentEntry"); err != nil {
+		return db.ProjectEnvironmentEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.envs[project+"/"+name]
+	if !ok {
+		return db.ProjectEnvironmentEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// ListProjectEnvironmentEntries returns the environments of a project ordered
+// by name.
+func (d *DB) ListProjectEnvironmentEntries(ctx context.Context, project string) ([]db.ProjectEnvironmentEntry, error) {
+	if err := d.apply(ctx, "ListProjectEnvironmentEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.ProjectEnvironmentEntry{}
+	for _, e := range d.envs {
+		if e.Project == project {
+			res = append(res, e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// DeleteProjectEnvironmentEntry deletes an environment of a project.
+func (d *DB) DeleteProjectEnvironmentEntry(ctx context.Context, project, name string) error {
+	if err := d.apply(ctx, "DeleteProjectEnvironmentEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.envs, project+"/"+name)
+	return nil
+}
+
+// CreateTargetCostAllocationTagsEntry stores the cost allocation tags of a
+// target, replacing any existing ones.
+func (d *DB) CreateTargetCostAllocationTagsEntry(ctx context.Context, e db.TargetCostAllocationTagsEntry) error {
+	if err := d.apply(ctx, "CreateTargetCostAllocationTagsEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.costTags[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetCostAllocationTagsEntry returns the cost allocation tags of a
+// target, or upper's ErrNoMoreRows like the SQL client when they don't exist.
+func (d *DB) ReadTargetCostAllocationTagsEntry(ctx context.Context, project, target string) (db.TargetCostAllocationTagsEntry, error) {
+	if err := d.apply(ctx, "ReadTargetCostAllocationTagsEntry"); err != nil {
+		return db.TargetCostAllocationTagsEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.costTags[project+"/"+target]
+	if !ok {
+		return db.TargetCostAllocationTagsEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetCostAllocationTagsEntry removes the cost allocation tags of a
+// target.
+func (d *DB) DeleteTargetCostAllocationTagsEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetCostAllocationTagsEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.costTags, project+"/"+target)
+	return nil
+}
+
+// CreateTargetSoakTimeEntry stores the soak time of a target, replacing any
+// existing one.
+func (d *DB) CreateTargetSoakTimeEntry(ctx context.Context, e db.TargetSoakTimeEntry) error {
+	if err := d.apply(ctx, "CreateTargetSoakTimeEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.soakTimes[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetSoakTimeEntry returns the soak time of a target, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetSoakTimeEntry(ctx context.Context, project, target string) (db.TargetSoakTimeEntry, error) {
+	if err := d.apply(ctx, "ReadTargetSoakTimeEntry"); err != nil {
+		return db.TargetSoakTimeEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.soakTimes[project+"/"+target]
+	if !ok {
+		return db.TargetSoakTimeEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetSoakTimeEntry removes the soak time of a target.
+func (d *DB) DeleteTargetSoakTimeEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetSoakTimeEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.soakTimes, project+"/"+target)
+	return nil
+}
+
+// CreateTargetTerraformStateEntry stores the terraform state of a target,
+// replacing any existing one.
+func (d *DB) CreateTargetTerraformStateEntry(ctx context.Context, e db.TargetTerraformStateEntry) error {
+	if err := d.apply(ctx, "CreateTargetTerraformStateEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tfStates[e.Project+"/"+e.Target] = e
+	return nil
+}
+
+// ReadTargetTerraformStateEntry returns the terraform state of a target, or
+// upper's ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetTerraformStateEntry(ctx context.Context, project, target string) (db.TargetTerraformStateEntry, error) {
+	if err := d.apply(ctx, "ReadTargetTerraformStateEntry"); err != nil {
+		return db.TargetTerraformStateEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.tfStates[project+"/"+target]
+	if !ok {
+		return db.TargetTerraformStateEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteTargetTerraformStateEntry removes the terraform state of a target.
+func (d *DB) DeleteTargetTerraformStateEntry(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetTerraformStateEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.tfStates, project+"/"+target)
+	return nil
+}
+
+// CreateProjectElevationEntry stores an elevation request.
+func (d *DB) CreateProjectElevationEntry(ctx context.Context, e db.ProjectElevationEntry) error {
+	if err := d.apply(ctx, "CreateProjectElevationEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.elevations = append(d.elevations, e)
+	return nil
+}
+
+// ReadProjectElevationEntry returns an elevation request, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadProjectElevationEntry(ctx context.Context, id string) (db.ProjectElevationEntry, error) {
+	if err := d.apply(ctx, "ReadProjectElevationEntry"); err != nil {
+		return db.ProjectElevationEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.elevations {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return db.ProjectElevationEntry{}, upper.ErrNoMoreRows
+}
+
+// ListProjectElevationEntries returns the elevation requests of a project in
+// creation order.
+func (d *DB) ListProjectElevationEntries(ctx context.Context, project string) ([]db.ProjectElevationEntry, error) {
+	if err := d.apply(ctx, "ListProjectElevationEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.ProjectElevationEntry{}
+	for _, e := range d.elevations {
+		if e.Project == project {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// GrantProjectElevationEntry records the grant of an elevation request.
+func (d *DB) GrantProjectElevationEntry(ctx context.Context, id, grantedBy string, grantedAt, expiresAt time.Time) error {
+	if err := d.apply(ctx, "GrantProjectElevationEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.elevations {
+		if d.elevations[i].ID == id {
+			g, x := grantedAt, expiresAt
+			d.elevations[i].GrantedBy = grantedBy
+			d.elevations[i].GrantedAt = &g
+			d.elevations[i].ExpiresAt = &x
+		}
+	}
+	return nil
+}
+
+// RevokeProjectElevationEntry records the revocation of an elevation
+// request.
+func (d *DB) RevokeProjectElevationEntry(ctx context.Context, id string, revokedAt time.Time) error {
+	if err := d.apply(ctx, "RevokeProjectElevationEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.elevations {
+		if d.elevations[i].ID == id {
+			t := revokedAt
+			d.elevations[i].RevokedAt = &t
+		}
+	}
+	return nil
+}
+
+// CreateFrameworkImageEntry stores a framework image rotation.
+func (d *DB) CreateFrameworkImageEntry(ctx context.Context, e db.FrameworkImageEntry) error {
+	if err := d.apply(ctx, "CreateFrameworkImageEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.images = append(d.images, e)
+	return nil
+}
+
+// ReadFrameworkImageEntry returns a framework image rotation, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadFrameworkImageEntry(ctx context.Context, id string) (db.FrameworkImageEntry, error) {
+	if err := d.apply(ctx, "ReadFrameworkImageEntry"); err != nil {
+		return db.FrameworkImageEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.images {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return db.FrameworkImageEntry{}, upper.ErrNoMoreRows
+}
+
+// ListFrameworkImageEntries returns the image rotations of a framework in
+// creation order.
+func (d *DB) ListFrameworkImageEntries(ctx context.Context, framework string) ([]db.FrameworkImageEntry, error) {
+	if err := d.apply(ctx, "ListFrameworkImageEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.FrameworkImageEntry{}
+	for _, e := range d.images {
+		if e.Framework == framework {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// PromoteFrameworkImageEntry records the promotion of a framework image
+// rotation.
+func (d *DB) PromoteFrameworkImageEntry(ctx context.Context, id, promotedBy string, promotedAt time.Time) error {
+	if err := d.apply(ctx, "PromoteFrameworkImageEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.images {
+		if d.images[i].ID == id {
+			t := promotedAt
+			d.images[i].PromotedBy = promotedBy
+			d.images[i].PromotedAt = &t
+		}
+	}
+	return nil
+}
+
+// AbortFrameworkImageEntry records the abort of a framework image rotation.
+func (d *DB) AbortFrameworkImageEntry(ctx context.Context, id, abortedBy string, abortedAt time.Time) error {
+	if err := d.apply(ctx, "AbortFrameworkImageEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.images {
+		if d.images[i].ID == id {
+			t := abortedAt
+			d.images[i].AbortedBy = abortedBy
+			d.images[i].AbortedAt = &t
+		}
+	}
+	return nil
+}
+
+// CreateExecutionAttestationEntry stores the attestation of a workflow.
+func (d *DB) CreateExecutionAttestationEntry(ctx context.Context, e db.ExecutionAttestationEntry) error {
+	if err := d.apply(ctx, "CreateExecutionAttestationEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.attests[e.WorkflowName]; ok {
+		return fmt.Errorf("attestation of workflow '%s' already exists", e.WorkflowName)
+	}
+	d.attests[e.WorkflowName] = e
+	return nil
+}
+
+// ReadExecutionAttestationEntry returns the attestation of a workflow, or
+// upper's ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadExecutionAttestationEntry(ctx context.Context, workflowName string) (db.ExecutionAttestationEntry, error) {
+	if err := d.apply(ctx, "ReadExecutionAttestationEntry"); err != nil {
+		return db.ExecutionAttestationEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.attests[workflowName]
+	if !ok {
+		return db.ExecutionAttestationEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// CreateTargetSubmissionSourceEntry stores a submission source of a target,
+// failing like the primary key of the SQL client when it exists.
+func (d *DB) CreateTargetSubmissionSourceEntry(ctx context.Context, e db.TargetSubmissionSourceEntry) error {
+	if err := d.apply(ctx, "CreateTargetSubmissionSourceEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := e.Project + "/" + e.Target + "/" + e.Source
+	if _, ok := d.sources[key]; ok {
+		return fmt.Errorf("submission source %s already exists", key)
+	}
+	d.sources[key] = e
+	return nil
+}
+
+// ReadTargetSubmissionSourceEntry returns a submission source of a target,
+// or upper's ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadTargetSubmissionSourceEntry(ctx context.Context, project, target, source string) (db.TargetSubmissionSourceEntry, error) {
+	if err := d.apply(ctx, "ReadTargetSubmissionSourceEntry"); err != nil {
+		return db.TargetSubmissionSourceEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.sources[project+"/"+target+"/"+source]
+	if !ok {
+		return db.TargetSubmissionSourceEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// CreateTargetDependencyEntry stores a dependency of a target.
+func (d *DB) CreateTargetDependencyEntry(ctx context.Context, e db.TargetDependencyEntry) error {
+	if err := d.apply(ctx, "CreateTargetDependencyEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deps = append(d.deps, e)
+	return nil
+}
+
+// ListTargetDependencyEntries returns the dependencies of every target.
+func (d *DB) ListTargetDependencyEntries(ctx context.Context) ([]db.TargetDependencyEntry, error) {
+	if err := d.apply(ctx, "ListTargetDependencyEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]db.TargetDependencyEntry{}, d.deps...), nil
+}
+
+// DeleteTargetDependencyEntries removes the dependencies of a target.
+func (d *DB) DeleteTargetDependencyEntries(ctx context.Context, project, target string) error {
+	if err := d.apply(ctx, "DeleteTargetDependencyEntries"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.deps[:0]
+	for _, e := range d.deps {
+		if e.Project != project || e.Target != target {
+			kept = append(kept, e)
+		}
+	}
+	d.deps = kept
+	return nil
+}
+
+// CreateRolloutEntry stores a rollout.
+func (d *DB) CreateRolloutEntry(ctx context.Context, e db.RolloutEntry) error {
+	if err := d.apply(ctx, "CreateRolloutEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.rollouts[e.ID]; ok {
+		return fmt.Errorf("rollout %s already exists", e.ID)
+	}
+	d.rollouts[e.ID] = e
+	return nil
+}
+
+// ReadRolloutEntry returns a rollout, or upper's ErrNoMoreRows like the SQL
+// client when it doesn't exist.
+func (d *DB) ReadRolloutEntry(ctx context.Context, id string) (db.RolloutEntry, error) {
+	if err := d.apply(ctx, "ReadRolloutEntry"); err != nil {
+		return db.RolloutEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.rollouts[id]
+	if !ok {
+		return db.RolloutEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// ListRolloutEntries returns the rollouts with the status, oldest first.
+func (d *DB) ListRolloutEntries(ctx context.Context, status string) ([]db.RolloutEntry, error) {
+	if err := d.apply(ctx, "ListRolloutEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.RolloutEntry{}
+	for _, e := range d.rollouts {
+		if e.Status == status {
+			res = append(res, e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res, nil
+}
+
+// UpdateRolloutEntry updates the status and steps of a rollout.
+func (d *DB) UpdateRolloutEntry(ctx context.Context, e db.RolloutEntry) error {
+	if err := d.apply(ctx, "UpdateRolloutEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	existing, ok := d.rollouts[e.ID]
+	if !ok {
+		return upper.ErrNoMoreRows
+	}
+	existing.Status = e.Status
+	existing.Steps = e.Steps
+	existing.UpdatedAt = e.UpdatedAt
+	d.rollouts[e.ID] = existing
+	return nil
+}
+
+// CreateExecutionNoteEntry stores a note of a workflow.
+func (d *DB) CreateExecutionNoteEntry(ctx context.Context, e db.ExecutionNoteEntry) error {
+	if err := d.apply(ctx, "CreateExecutionNoteEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, n := range d.notes {
+		if n.ID == e.ID {
+			return fmt.Errorf("execution note %s already exists", e.ID)
+		}
+	}
+	d.notes = append(d.notes, e)
+	return nil
+}
+
+// ListExecutionNoteEntries returns the notes of the workflows of a target,
+// oldest first.
+func (d *DB) ListExecutionNoteEntries(ctx context.Context, project, target string) ([]db.ExecutionNoteEntry, error) {
+	if err := d.apply(ctx, "ListExecutionNoteEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.ExecutionNoteEntry{}
+	for _, e := range d.notes {
+		if e.Project == project && e.Target == target {
+			res = append(res, e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res, nil
+}
+
+// CreateProjectWebhookEntry stores a webhook of a project.
+func (d *DB) CreateProjectWebhookEntry(ctx context.Context, e db.ProjectWebhookEntry) error {
+	if err := d.apply(ctx, "CreateProjectWebhookEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.webhooks = append(d.webhooks, e)
+	return nil
+}
+
+// ReadProjectWebhookEntry returns a webhook of a project, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadProjectWebhookEntry(ctx context.Context, project, id string) (db.ProjectWebhookEntry, error) {
+	if err := d.apply(ctx, "ReadProjectWebhookEntry"); err != nil {
+		return db.ProjectWebhookEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.webhooks {
+		if e.Project == project && e.ID == id {
+			return e, nil
+		}
+	}
+	return db.ProjectWebhookEntry{}, upper.ErrNoMoreRows
+}
+
+// ListProjectWebhookEntries returns the webhooks of a project, oldest first.
+func (d *DB) ListProjectWebhookEntries(ctx context.Context, project string) ([]db.ProjectWebhookEntry, error) {
+	if err := d.apply(ctx, "ListProjectWebhookEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.ProjectWebhookEntry{}
+	for _, e := range d.webhooks {
+		if e.Project == project {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// DeleteProjectWebhookEntry deletes a webhook of a project.
+func (d *DB) DeleteProjectWebhookEntry(ctx context.Context, project, id string) error {
+	if err := d.apply(ctx, "DeleteProjectWebhookEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.webhooks[:0]
+	for _, e := range d.webhooks {
+		if e.Project != project || e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	d.webhooks = kept
+	return nil
+}
+
+// CreateWebhookDeliveryEntry stores a delivery of an event to a webhook.
+func (d *DB) CreateWebhookDeliveryEntry(ctx context.Context, e db.WebhookDeliveryEntry) error {
+	if err := d.apply(ctx, "CreateWebhookDeliveryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries = append(d.deliveries, e)
+	return nil
+}
+
+// ListWebhookDeliveryEntries returns the latest deliveries of a webhook,
+// newest first.
+func (d *DB) ListWebhookDeliveryEntries(ctx context.Context, webhookID string, limit int) ([]db.WebhookDeliveryEntry, error) {
+	if err := d.apply(ctx, "ListWebhookDeliveryEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.WebhookDeliveryEntry{}
+	for i := len(d.deliveries) - 1; i >= 0 && len(res) < limit; i-- {
+		if d.deliveries[i].WebhookID == webhookID {
+			res = append(res, d.deliveries[i])
+		}
+	}
+	return res, nil
+}
+
+// ListDueWebhookDeliveryEntries returns the pending deliveries whose next
+// attempt is due, oldest first.
+func (d *DB) ListDueWebhookDeliveryEntries(ctx context.Context, now time.Time) ([]db.WebhookDeliveryEntry, error) {
+	if err := d.apply(ctx, "ListDueWebhookDeliveryEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.WebhookDeliveryEntry{}
+	for _, e := range d.deliveries {
+		if e.Status == "pending" && !e.NextAttemptAt.After(now) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// UpdateWebhookDeliveryEntry updates the status and last attempt of a
+// delivery.
+func (d *DB) UpdateWebhookDeliveryEntry(ctx context.Context, e db.WebhookDeliveryEntry) error {
+	if err := d.apply(ctx, "UpdateWebhookDeliveryEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, existing := range d.deliveries {
+		if existing.ID != e.ID {
+			continue
+		}
+		existing.Status = e.Status
+		existing.Attempts = e.Attempts
+		existing.ResponseCode = e.ResponseCode
+		existing.Error = e.Error
+		existing.NextAttemptAt = e.NextAttemptAt
+		existing.UpdatedAt = e.UpdatedAt
+		d.deliveries[i] = existing
+		return nil
+	}
+	return upper.ErrNoMoreRows
+}
+
+// CreateProjectEncryptionKeyEntry stores the encryption key of a project,
+// replacing any existing one.
+func (d *DB) CreateProjectEncryptionKeyEntry(ctx context.Context, e db.ProjectEncryptionKeyEntry) error {
+	if err := d.apply(ctx, "CreateProjectEncryptionKeyEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys[e.Project] = e
+	return nil
+}
+
+// ReadProjectEncryptionKeyEntry returns the encryption key of a project, or
+// upper's ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadProjectEncryptionKeyEntry(ctx context.Context, project string) (db.ProjectEncryptionKeyEntry, error) {
+	if err := d.apply(ctx, "ReadProjectEncryptionKeyEntry"); err != nil {
+		return db.ProjectEncryptionKeyEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.keys[project]
+	if !ok {
+		return db.ProjectEncryptionKeyEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteProjectEncryptionKeyEntry removes the encryption key of a project.
+func (d *DB) DeleteProjectEncryptionKeyEntry(ctx context.Context, project string) error {
+	if err := d.apply(ctx, "DeleteProjectEncryptionKeyEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.keys, project)
+	return nil
+}
+
+// CreateResourceIDEntry stores the ID of a resource, failing like the SQL
+// client when the resource already has one.
+func (d *DB) CreateResourceIDEntry(ctx context.Context, e db.ResourceIDEntry) error {
+	if err := d.apply(ctx, "CreateResourceIDEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := e.Kind + "/" + e.Project + "/" + e.Target
+	if _, ok := d.ids[key]; ok {
+		return fmt.Errorf("resource %s already has an id", key)
+	}
+	d.ids[key] = e
+	return nil
+}
+
+// ReadResourceIDEntry returns the ID of a resource, or upper's ErrNoMoreRows
+// like the SQL client when it has none.
+func (d *DB) ReadResourceIDEntry(ctx context.Context, kind, project, target string) (db.ResourceIDEntry, error) {
+	if err := d.apply(ctx, "ReadResourceIDEntry"); err != nil {
+		return db.ResourceIDEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.ids[kind+"/"+project+"/"+target]
+	if !ok {
+		return db.ResourceIDEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// DeleteResourceIDEntry removes the ID of a resource.
+func (d *DB) DeleteResourceIDEntry(ctx context.Context, kind, project, target string) error {
+	if err := d.apply(ctx, "DeleteResourceIDEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.ids, kind+"/"+project+"/"+target)
+	return nil
+}
+
+// RenameProjectEntry renames the project in every entry and keeps the former
+// name as an alias like the SQL client.
+func (d *DB) RenameProjectEntry(ctx context.Context, from, to string, aliasExpiresAt time.Time) error {
+	if err := d.apply(ctx, "RenameProjectEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Keys of entries start with their project, except the ones of resource
+	// IDs, which start with their kind.
+	rekey := func(k string) string { return to + strings.TrimPrefix(k, from) }
+	if e, ok := d.projects[from]; ok {
+		delete(d.projects, from)
+		e.ProjectID = to
+		d.projects[to] = e
+	}
+	for i := range d.events {
+		if d.events[i].Project == from {
+			d.events[i].Project = to
+		}
+	}
+	for k, e := range d.guardrails {
+		if e.Project == from {
+			delete(d.guardrails, k)
+			e.Project = to
+			d.guardrails[rekey(k)] = e
+		}
+	}
+	for k, e := range d.controls {
+		if e.Project == from {
+			delete(d.controls, k)
+			e.Project = to
+			d.controls[rekey(k)] = e
+		}
+	}
+	for k, e := range d.rules {
+		if e.Project == from {
+			delete(d.rules, k)
+			e.Project = to
+			d.rules[rekey(k)] = e
+		}
+	}
+	for k, e := range d.hours {
+		if e.Project == from {
+			delete(d.hours, k)
+			e.Project = to
+			d.hours[rekey(k)] = e
+		}
+	}
+	for k, e := range d.templates {
+		if e.Project == from {
+			delete(d.templates, k)
+			e.Project = to
+			d.templates[rekey(k)] = e
+		}
+	}
+	for k, e := range d.flags {
+		if e.Project == from {
+			delete(d.flags, k)
+			e.Project = to
+			d.flags[rekey(k)] = e
+		}
+	}
+	for k, e := range d.registries {
+		if e.Project == from {
+			delete(d.registries, k)
+			e.Project = to
+			d.registries[rekey(k)] = e
+		}
+	}
+	for k, e := range d.schedules {
+		if e.Project == from {
+			delete(d.schedules, k)
+			e.Project = to
+			d.schedules[rekey(k)] = e
+		}
+	}
+	for k, e := range d.scheduling {
+		if e.Project == from {
+			delete(d.scheduling, k)
+			e.Project = to
+			d.scheduling[rekey(k)] = e
+		}
+	}
+	for k, e := range d.identities {
+		if e.Project == from {
+			delete(d.identities, k)
+			e.Project = to
+			d.identities[rekey(k)] = e
+		}
+	}
+	for i := range d.runTokens {
+		if d.runTokens[i].Project == from {
+			d.runTokens[i].Project = to
+		}
+	}
+	for k, e := range d.changeSets {
+		if e.Project == from {
+			delete(d.changeSets, k)
+			e.Project = to
+			d.changeSets[rekey(k)] = e
+		}
+	}
+	for k, e := range d.inventory {
+		if e.Project == from {
+			delete(d.inventory, k)
+			e.Project = to
+			d.inventory[rekey(k)] = e
+		}
+	}
+	for i := range d.breakGlass {
+		if d.breakGlass[i].Project == from {
+			d.breakGlass[i].Project = to
+		}
+	}
+	for i := range d.elevations {
+		if d.elevations[i].Project == from {
+			d.elevations[i].Project = to
+		}
+	}
+	for k, e := range d.settings {
+		if e.Project == from {
+			delete(d.settings, k)
+			e.Project = to
+			d.settings[rekey(k)] = e
+		}
+	}
+	for k, e := range d.costTags {
+		if e.Project == from {
+			delete(d.costTags, k)
+			e.Project = to
+			d.costTags[rekey(k)] = e
+		}
+	}
+	for k, e := range d.soakTimes {
+		if e.Project == from {
+			delete(d.soakTimes, k)
+			e.Project = to
+			d.soakTimes[rekey(k)] = e
+		}
+	}
+	for k, e := range d.tfStates {
+		if e.Project == from {
+			delete(d.tfStates, k)
+			e.Project = to
+			d.tfStates[rekey(k)] = e
+		}
+	}
+	for k, e := range d.attests {
+		if e.Project == from {
+			e.Project = to
+			d.attests[k] = e
+		}
+	}
+	for k, e := range d.plans {
+		if e.Project == from {
+			e.Project = to
+			d.plans[k] = e
+		}
+	}
+	for k, e := range d.sources {
+		if e.Project == from {
+			delete(d.sources, k)
+			e.Project = to
+			d.sources[rekey(k)] = e
+		}
+	}
+	for i := range d.deps {
+		if d.deps[i].Project == from {
+			d.deps[i].Project = to
+		}
+		if d.deps[i].DependsOnProject == from {
+			d.deps[i].DependsOnProject = to
+		}
+	}
+	for i := range d.notes {
+		if d.notes[i].Project == from {
+			d.notes[i].Project = to
+		}
+	}
+	for i := range d.webhooks {
+		if d.webhooks[i].Project == from {
+			d.webhooks[i].Project = to
+		}
+	}
+	for i := range d.deliveries {
+		if d.deliveries[i].Project == from {
+			d.deliveries[i].Project = to
+		}
+	}
+	for k, e := range d.keys {
+		if e.Project == from {
+			delete(d.keys, k)
+			e.Project = to
+			d.keys[rekey(k)] = e
+		}
+	}
+	for k, e := range d.ids {
+		if e.Project == from {
+			delete(d.ids, k)
+			e.Project = to
+			d.ids[e.Kind+"/"+e.Project+"/"+e.Target] = e
+		}
+	}
+	for k, e := range d.envs {
+		if e.Project == from {
+			delete(d.envs, k)
+			e.Project = to
+			d.envs[rekey(k)] = e
+		}
+	}
+
+	delete(d.aliases, to)
+	for k, e := range d.aliases {
+		if e.Project == from {
+			e.Project = to
+			d.aliases[k] = e
+		}
+	}
+	d.aliases[from] = db.ProjectAliasEntry{Alias: from, Project: to, ExpiresAt: aliasExpiresAt}
+	return nil
+}
+
+// MoveTargetEntry moves the entries of the target to another project like
+// the SQL client.
+func (d *DB) MoveTargetEntry(ctx context.Context, from, to, target string) error {
+	if err := d.apply(ctx, "MoveTargetEntry"); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rekey := func(k string) string { return to + strings.TrimPrefix(k, from) }
+	for i := range d.events {
+		if d.events[i].Project == from && d.events[i].Target == target {
+			d.events[i].Project = to
+		}
+	}
+	for i := range d.runTokens {
+		if d.runTokens[i].Project == from && d.runTokens[i].Target == target {
+			d.runTokens[i].Project = to
+		}
+	}
+	for i := range d.breakGlass {
+		if d.breakGlass[i].Project == from && d.breakGlass[i].Target == target {
+			d.breakGlass[i].Project = to
+		}
+	}
+	for k, e := range d.guardrails {
+		if e.Project == from && e.Target == target {
+			delete(d.guardrails, k)
+			e.Project = to
+			d.guardrails[rekey(k)] = e
+		}
+	}
+	for k, e := range d.controls {
+		if e.Project == from && e.Target == target {
+			delete(d.controls, k)
+			e.Project = to
+			d.controls[rekey(k)] = e
+		}
+	}
+	for k, e := range d.schedules {
+		if e.Project == from && e.Target == target {
+			delete(d.schedules, k)
+			e.Project = to
+			d.schedules[rekey(k)] = e
+		}
+	}
+	for k, e := range d.scheduling {
+		if e.Project == from && e.Target == target {
+			delete(d.scheduling, k)
+			e.Project = to
+			d.scheduling[rekey(k)] = e
+		}
+	}
+	for k, e := range d.identities {
+		if e.Project == from && e.Target == target {
+			delete(d.identities, k)
+			e.Project = to
+			d.identities[rekey(k)] = e
+		}
+	}
+	for k, e := range d.changeSets {
+		if e.Project == from && e.Target == target {
+			delete(d.changeSets, k)
+			e.Project = to
+			d.changeSets[rekey(k)] = e
+		}
+	}
+	for k, e := range d.inventory {
+		if e.Project == from && e.Target == target {
+			delete(d.inventory, k)
+			e.Project = to
+			d.inventory[rekey(k)] = e
+		}
+	}
+	for k, e := range d.costTags {
+		if e.Project == from && e.Target == target {
+			delete(d.costTags, k)
+			e.Project = to
+			d.costTags[rekey(k)] = e
+		}
+	}
+	for k, e := range d.soakTimes {
+		if e.Project == from && e.Target == target {
+			delete(d.soakTimes, k)
+			e.Project = to
+			d.soakTimes[rekey(k)] = e
+		}
+	}
+	for k, e := range d.tfStates {
+		if e.Project == from && e.Target == target {
+			delete(d.tfStates, k)
+			e.Project = to
+			d.tfStates[rekey(k)] = e
+		}
+	}
+	for k, e := range d.sources {
+		if e.Project == from && e.Target == target {
+			delete(d.sources, k)
+			e.Project = to
+			d.sources[rekey(k)] = e
+		}
+	}
+	for k, e := range d.attests {
+		if e.Project == from && e.Target == target {
+			e.Project = to
+			d.attests[k] = e
+		}
+	}
+	for k, e := range d.plans {
+		if e.Project == from && e.Target == target {
+			e.Project = to
+			d.plans[k] = e
+		}
+	}
+	for i := range d.deps {
+		if d.deps[i].Project == from && d.deps[i].Target == target {
+			d.deps[i].Project = to
+		}
+		if d.deps[i].DependsOnProject == from && d.deps[i].DependsOnTarget == target {
+			d.deps[i].DependsOnProject = to
+		}
+	}
+	for i := range d.notes {
+		if d.notes[i].Project == from && d.notes[i].Target == target {
+			d.notes[i].Project = to
+		}
+	}
+	for k, e := range d.ids {
+		if e.Project == from && e.Target == target {
+			delete(d.ids, k)
+			e.Project = to
+			d.ids[e.Kind+"/"+e.Project+"/"+e.Target] = e
+		}
+	}
+	for k, e := range d.envs {
+		if e.Project != from {
+			continue
+		}
+		environment, removed, empty, err := db.RemoveEnvironmentTarget(e.Environment, target)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !removed:
+		case empty:
+			delete(d.envs, k)
+		default:
+			e.Environment = environment
+			e.UpdatedAt = time.Now()
+			d.envs[k] = e
+		}
+	}
+	return nil
+}
+
+// ReadProjectAliasEntry returns an alias, expired or not, or upper's
+// ErrNoMoreRows like the SQL client when it doesn't exist.
+func (d *DB) ReadProjectAliasEntry(ctx context.Context, alias string) (db.ProjectAliasEntry, error) {
+	if err := d.apply(ctx, "ReadProjectAliasEntry"); err != nil {
+		return db.ProjectAliasEntry{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.aliases[alias]
+	if !ok {
+		return db.ProjectAliasEntry{}, upper.ErrNoMoreRows
+	}
+	return e, nil
+}
+
+// ListProjectAliasEntries returns the aliases of a project ordered by alias.
+func (d *DB) ListProjectAliasEntries(ctx context.Context, project string) ([]db.ProjectAliasEntry, error) {
+	if err := d.apply(ctx, "ListProjectAliasEntries"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := []db.ProjectAliasEntry{}
+	for _, e := range d.aliases {
+		if e.Project == project {
+			res = append(res, e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Alias < res[j].Alias })
+	return res, nil
+}
+
+// ListConcurrencyBuckets computes the workflows running by target during the
+// buckets from the stored execution events like the SQL client.
+func (d *DB) ListConcurrencyBuckets(ctx context.Context, start time.Time, buckets int, bucket, maxRunning time.Duration) ([]db.ConcurrencyBucket, error) {
+	if err := d.apply(ctx, "ListConcurrencyBuckets"); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	type run struct {
+		project, target string
+		started         time.Time
+	}
+	runs := map[string]*run{}
+	finished := map[string]time.Time{}
+	for _, e := range d.events {
+		switch {
+		case e.Type == "credentials_revoked":
+			if f, ok := finished[e.WorkflowName]; !ok || e.CreatedAt.Before(f) {
+				finished[e.WorkflowName] = e.CreatedAt
+			}
+		case e.Type == "submit_attempt" && e.WorkflowName != "" && strings.HasSuffix(e.Message, " succeeded"):
+			if r, ok := runs[e.WorkflowName]; !ok || e.CreatedAt.Before(r.started) {
+				runs[e.WorkflowName] = &run{project: e.Project, target: e.Target, started: e.CreatedAt}
+			}
+		}
+	}
+
+	res := []db.ConcurrencyBucket{}
+	for i := 0; i < buckets; i++ {
+		bucketStart := start.Add(time.Duration(i) * bucket)
+		running := map[[2]string]int{}
+		for name, r := range runs {
+			end, ok := finished[name]
+			if !ok {
+				end = r.started.Add(maxRunning)
+			}
+			if r.started.Before(bucketStart.Add(bucket)) && end.After(bucketStart) {
+				running[[2]string{r.project, r.target}]++
+			}
+		}
+		keys := make([][2]string, 0, len(running))
+		for k := range running {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i][0] != keys[j][0] {
+				return keys[i][0] < keys[j][0]
+			}
+			return keys[i][1] < keys[j][1]
+		})
+		for _, k := range keys {
+			res = append(res, db.ConcurrencyBucket{Start: bucketStart, Project: k[0], Target: k[1], Running: running[k]})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,126 @@
+// Package faketest provides deterministic, in-memory fakes of the service
+// dependencies (Vault, Argo, git and the database) whose latency and failures
+// can be scripted per operation. They are intended for exercising failure
+// handling in tests without running the real backends.
+package faketest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInjected is a generic error for faults that don't need a specific one.
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes how a single call to a fake operation behaves.
+type Fault struct {
+	// Latency is how long the call blocks before returning. The call returns
+	// early with the context error if the context is done first.
+	Latency time.Duration
+	// Err is returned by the call when set.
+	Err error
+}
+
+// Script holds the faults of each operation of a fake. Queued faults are
+// consumed one per call in order; once the queue is empty the default fault
+// for the operation (if any) applies to every call.
+type Script struct {
+	mu       sync.Mutex
+	queued   map[string][]Fault
+	defaults map[string]Fault
+	calls    map[string]int
+}
+
+func newScript() *Script {
+	return &Script{
+		queued:   map[string][]Fault{},
+		defaults: map[string]Fault{},
+		calls:    map[string]int{},
+	}
+}
+
+// Enqueue queues faults for the next calls of an operation.
+func (s *Script) Enqueue(op string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued[op] = append(s.queued[op], faults...)
+}
+
+// Always sets the fault used for every call of an operation once its queue is
+// empty.
+func (s *Script) Always(op string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaults[op] = f
+}
+
+// Reset clears all faults and call counts.
+func (s *Script) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued = map[string][]Fault{}
+	s.defaults = map[string]Fault{}
+	s.calls = map[string]int{}
+}
+
+// Calls returns the number of calls made to an operation.
+func (s *Script) Calls(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// apply records the call and applies its fault.
+func (s *Script) apply(ctx context.Context, op string) error {
+	s.mu.Lock()
+	s.calls[op]++
+	f, ok := s.defaults[op]
+	if q := s.queued[op]; len(q) > 0 {
+		f, ok = q[0], true
+		s.queued[op] = q[1:]
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if f.Latency > 0 {
+		t := time.NewTimer(f.Latency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return f.Err
+}
+
+// Backends bundles a fake of every dependency of the service.
+type Backends struct {
+	Argo            *Argo
+	Cron            *CronWorkflows
+	DB              *DB
+	Git             *Git
+	ITSM            *ITSM
+	ServiceAccounts *ServiceAccounts
+	Vault           *Vault
+}
+
+// NewBackends creates empty fake backends.
+func NewBackends() *Backends {
+	argo := NewArgo()
+	return &Backends{
+		Argo:            argo,
+		Cron:            NewCronWorkflows(argo),
+		DB:              NewDB(),
+		Git:             NewGit(),
+		ITSM:            NewITSM(),
+		ServiceAccounts: NewServiceAccounts(),
+		Vault:           NewVault(),
+	}
+}
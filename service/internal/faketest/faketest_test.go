@@ -0,0 +1,79 @@
+package faketest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScript(t *testing.T) {
+	errOther := errors.New("other")
+
+	s := newScript()
+	s.Enqueue("op", Fault{Err: ErrInjected}, Fault{})
+	s.Always("op", Fault{Err: errOther})
+
+	assert.Equal(t, ErrInjected, s.apply(context.Background(), "op"))
+	assert.Nil(t, s.apply(context.Background(), "op"))
+	assert.Equal(t, errOther, s.apply(context.Background(), "op"))
+	assert.Equal(t, errOther, s.apply(context.Background(), "op"))
+	assert.Nil(t, s.apply(context.Background(), "unscripted"))
+	assert.Equal(t, 4, s.Calls("op"))
+
+	s.Reset()
+	assert.Nil(t, s.apply(context.Background(), "op"))
+	assert.Equal(t, 1, s.Calls("op"))
+}
+
+func TestScriptLatencyHonorsContext(t *testing.T) {
+	s := newScript()
+	s.Always("op", Fault{Latency: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, s.apply(ctx, "op"), context.DeadlineExceeded)
+}
+
+func TestArgoSubmit(t *testing.T) {
+	a := NewArgo()
+
+	name, err := a.Submit(context.Background(), "workflowtemplate/wt", map[string]string{"project_name": "p", "target_name": "t"}, nil, nil, workflow.Scheduling{})
+	assert.Nil(t, err)
+	assert.Equal(t, "p-t-00001", name)
+
+	status, err := a.Status(context.Background(), name)
+	assert.Nil(t, err)
+	assert.Equal(t, "pending", status.Status)
+
+	a.Enqueue("Submit", Fault{Err: ErrInjected})
+	_, err = a.Submit(context.Background(), "workflowtemplate/wt", nil, nil, nil, workflow.Scheduling{})
+	assert.ErrorIs(t, err, ErrInjected)
+}
+
+func TestClock(t *testing.T) {
+	now := time.Date(2022, 3, 14, 10, 0, 0, 0, time.UTC)
+	c := NewClock(now)
+	ticker := c.NewTicker(time.Minute)
+	assert.Equal(t, 1, c.Tickers())
+
+	c.Advance(30 * time.Second)
+	assert.Equal(t, now.Add(30*time.Second), c.Now())
+	assert.Len(t, ticker.C(), 0)
+
+	// Missed ticks are dropped.
+	c.Advance(5 * time.Minute)
+	assert.Equal(t, now.Add(330*time.Second), <-ticker.C())
+	c.Advance(20 * time.Second)
+	assert.Len(t, ticker.C(), 0)
+	c.Advance(10 * time.Second)
+	assert.Len(t, ticker.C(), 1)
+
+	ticker.Stop()
+	assert.Equal(t, 0, c.Tickers())
+}
@@ -0,0 +1,50 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Git is a fake git.Client serving manifest files from memory. The
+// GetManifestFile operation can be scripted.
+type Git struct {
+	*Script
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewGit creates a fake Git with no files.
+func NewGit() *Git {
+	return &Git{
+		Script: newScript(),
+		files:  map[string][]byte{},
+	}
+}
+
+// AddFile makes a file available at the commit of the repository.
+func (g *Git) AddFile(repository, commitHash, path string, contents []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.files[gitFileKey(repository, commitHash, path)] = contents
+}
+
+// GetManifestFile returns a file added with AddFile.
+func (g *Git) GetManifestFile(ctx context.Context, repository, commitHash, path string) ([]byte, error) {
+	if err := g.apply(ctx, "GetManifestFile"); err != nil {
+		return []byte{}, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	contents, ok := g.files[gitFileKey(repository, commitHash, path)]
+	if !ok {
+		return []byte{}, fmt.Errorf("file '%s' not found in '%s' at '%s'", path, repository, commitHash)
+	}
+	return contents, nil
+}
+
+func gitFileKey(repository, commitHash, path string) string {
+	return fmt.Sprintf("%s@%s:%s", repository, commitHash, path)
+}
@@ -0,0 +1,116 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cello-proj/cello/service/internal/itsm"
+)
+
+// ITSM is a fake itsm.Client keeping changes in memory. Created changes are
+// pending and named with a sequence number. Operations are named after the
+// itsm.Client methods.
+type ITSM struct {
+	*Script
+
+	mu        sync.Mutex
+	seq       int
+	changes   map[string]itsm.Change
+	requests  map[string]itsm.ChangeRequest
+	approvers map[string][]string
+}
+
+// NewITSM creates a fake ITSM with no changes.
+func NewITSM() *ITSM {
+	return &ITSM{
+		Script:    newScript(),
+		changes:   map[string]itsm.Change{},
+		requests:  map[string]itsm.ChangeRequest{},
+		approvers: map[string][]string{},
+	}
+}
+
+// AddChange adds an existing change.
+func (i *ITSM) AddChange(change itsm.Change) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.changes[change.ID] = change
+}
+
+// SetState sets the state (e.g. 'approved') of a change.
+func (i *ITSM) SetState(id, state string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	change, ok := i.changes[id]
+	if !ok {
+		return fmt.Errorf("change '%s' not found", id)
+	}
+	change.State = state
+	i.changes[id] = change
+	return nil
+}
+
+// Approve adds the principals to the approvers of a change.
+func (i *ITSM) Approve(id string, approvers ...string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.changes[id]; !ok {
+		return fmt.Errorf("change '%s' not found", id)
+	}
+	i.approvers[id] = append(i.approvers[id], approvers...)
+	return nil
+}
+
+// Request returns the request a change was created for.
+func (i *ITSM) Request(id string) (itsm.ChangeRequest, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	req, ok := i.requests[id]
+	return req, ok
+}
+
+// CreateChange stores a pending change.
+func (i *ITSM) CreateChange(ctx context.Context, req itsm.ChangeRequest) (itsm.Change, error) {
+	if err := i.apply(ctx, "CreateChange"); err != nil {
+		return itsm.Change{}, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.seq++
+	change := itsm.Change{ID: fmt.Sprintf("CHG%07d", i.seq), State: itsm.StatePending}
+	i.changes[change.ID] = change
+	i.requests[change.ID] = req
+	return change, nil
+}
+
+// GetChange returns a change, or itsm.ErrNotFound when it doesn't exist.
+func (i *ITSM) GetChange(ctx context.Context, id string) (itsm.Change, error) {
+	if err := i.apply(ctx, "GetChange"); err != nil {
+		return itsm.Change{}, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	change, ok := i.changes[id]
+	if !ok {
+		return itsm.Change{}, itsm.ErrNotFound
+	}
+	return change, nil
+}
+
+// Approvers returns the approvers of a change, or itsm.ErrNotFound when it
+// doesn't exist.
+func (i *ITSM) Approvers(ctx context.Context, id string) ([]string, error) {
+	if err := i.apply(ctx, "Approvers"); err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.changes[id]; !ok {
+		return nil, itsm.ErrNotFound
+	}
+	return append([]string(nil), i.approvers[id]...), nil
+}
@@ -0,0 +1,707 @@
+package faketest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/env"
+)
+
+const adminKey = "admin"
+
+type vaultProject struct {
+	roleID   string
+	secretID string
+	targets  map[string]types.Target
+	// hosts are the host credentials by target.
+	hosts map[string]credentials.HostCredentials
+}
+
+// Vault is a fake of the Vault backed credentials provider, keeping projects
+// and targets in memory. Use NewProvider in place of
+// credentials.NewVaultProvider. Operations are named after the
+// credentials.Provider methods, plus 'NewProvider' for the Vault login.
+type Vault struct {
+	*Script
+
+	mu       sync.Mutex
+	seq      int
+	projects map[string]*vaultProject
+	// revoked by accessor of the run tokens.
+	revoked map[string]bool
+	// leases are revoked by ID of the leases of target credentials.
+	leases map[string]bool
+	// registries are the passwords of registry credentials by reference.
+	registries map[string]string
+	// policyTemplate is the customized policy template, empty for the
+	// default.
+	policyTemplate string
+}
+
+// NewVault creates a fake Vault with no projects.
+func NewVault() *Vault {
+	return &Vault{
+		Script:     newScript(),
+		projects:   map[string]*vaultProject{},
+		revoked:    map[string]bool{},
+		leases:     map[string]bool{},
+		registries: map[string]string{},
+	}
+}
+
+// RegistryPasswords returns the number of stored registry passwords.
+func (v *Vault) RegistryPasswords() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.registries)
+}
+
+// HostCredentials returns the host credentials of a target, and false for ok
+// when it has none.
+func (v *Vault) HostCredentials(projectName, targetName string) (c credentials.HostCredentials, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if proj, found := v.projects[projectName]; found {
+		c, ok = proj.hosts[targetName]
+	}
+	return c, ok
+}
+
+// RunTokenRevoked returns whether the run token of the accessor was revoked,
+// and false for ok when no run token has the accessor.
+func (v *Vault) RunTokenRevoked(accessor string) (revoked, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	revoked, ok = v.revoked[accessor]
+	return revoked, ok
+}
+
+// LiveRunTokens returns the number of run tokens which weren't revoked.
+func (v *Vault) LiveRunTokens() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	live := 0
+	for _, revoked := range v.revoked {
+		if !revoked {
+			live++
+		}
+	}
+	return live
+}
+
+// LeaseRevoked returns whether the lease of target credentials was revoked,
+// and false for ok when no target credentials have the lease.
+func (v *Vault) LeaseRevoked(leaseID string) (revoked, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	revoked, ok = v.leases[leaseID]
+	return revoked, ok
+}
+
+// NewProvider returns a credentials.Provider for the authorization, matching
+// the signature of credentials.NewVaultProvider.
+func (v *Vault) NewProvider(a credentials.Authorization, _ env.Vars, _ http.Header, _ credentials.VaultConfigFn, _ credentials.VaultSvcFn) (credentials.Provider, error) {
+	if err := v.apply(context.Background(), "NewProvider"); err != nil {
+		return nil, err
+	}
+	return &vaultProvider{vault: v, auth: a}, nil
+}
+
+type vaultProvider struct {
+	vault *Vault
+	auth  credentials.Authorization
+}
+
+func (p *vaultProvider) isAdmin() bool {
+	return p.auth.Key == adminKey
+}
+
+// call applies the scripted fault of the operation and locks the fake for
+// the remainder of the call. The returned func must be called to unlock.
+func (p *vaultProvider) call(op string) (func(), error) {
+	if err := p.vault.apply(context.Background(), op); err != nil {
+		return func() {}, err
+	}
+	p.vault.mu.Lock()
+	return p.vault.mu.Unlock, nil
+}
+
+func (p *vaultProvider) CreateProject(name string) (string, string, error) {
+	unlock, err := p.call("CreateProject")
+	defer unlock()
+	if err != nil {
+		return "", "", err
+	}
+
+	if !p.isAdmin() {
+		return "", "", errors.New("admin credentials must be used to create project")
+	}
+
+	p.vault.seq++
+	proj := &vaultProject{
+		roleID:   fmt.Sprintf("role-%s-%d", name, p.vault.seq),
+		secretID: fmt.Sprintf("secret-%s-%d", name, p.vault.seq),
+		targets:  map[string]types.Target{},
+		hosts:    map[string]credentials.HostCredentials{},
+	}
+	p.vault.projects[name] = proj
+	return proj.roleID, proj.secretID, nil
+}
+
+func (p *vaultProvider) CreateTarget(projectName string, target types.Target) error {
+	unlock, err := p.call("CreateTarget")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to create target")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return credentials.ErrNotFound
+	}
+	proj.targets[target.Name] = target
+	return nil
+}
+
+func (p *vaultProvider) UpdateTarget(projectName string, target types.Target) error {
+	unlock, err := p.call("UpdateTarget")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to update target")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return credentials.ErrNotFound
+	}
+	proj.targets[target.Name] = target
+	return nil
+}
+
+func (p *vaultProvider) DeleteProject(name string) error {
+	unlock, err := p.call("DeleteProject")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete project")
+	}
+
+	delete(p.vault.projects, name)
+	return nil
+}
+
+// RenameProject moves the project with its targets and host credentials to
+// the new name, with new credentials like the Vault approle of the new name.
+func (p *vaultProvider) RenameProject(from, to string) (string, string, error) {
+	unlock, err := p.call("RenameProject")
+	defer unlock()
+	if err != nil {
+		return "", "", err
+	}
+
+	if !p.isAdmin() {
+		return "", "", errors.New("admin credentials must be used to rename project")
+	}
+
+	proj, ok := p.vault.projects[from]
+	if !ok {
+		return "", "", credentials.ErrNotFound
+	}
+	p.vault.seq++
+	proj.roleID = fmt.Sprintf("role-%s-%d", to, p.vault.seq)
+	proj.secretID = fmt.Sprintf("secret-%s-%d", to, p.vault.seq)
+	p.vault.projects[to] = proj
+	delete(p.vault.projects, from)
+	return proj.roleID, proj.secretID, nil
+}
+
+// MoveTarget moves a target of the project with its host credentials to
+// another project.
+func (p *vaultProvider) MoveTarget(from, to, targetName string) error {
+	unlock, err := p.call("MoveTarget")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to move target")
+	}
+
+	src, ok := p.vault.projects[from]
+	if !ok {
+		return credentials.ErrNotFound
+	}
+	dst, ok := p.vault.projects[to]
+	if !ok {
+		return credentials.ErrNotFound
+	}
+	target, ok := src.targets[targetName]
+	if !ok {
+		return credentials.ErrTargetNotFound
+	}
+	dst.targets[targetName] = target
+	delete(src.targets, targetName)
+	if host, ok := src.hosts[targetName]; ok {
+		dst.hosts[targetName] = host
+		delete(src.hosts, targetName)
+	}
+	return nil
+}
+
+func (p *vaultProvider) DeleteTarget(projectName, targetName string) error {
+	unlock, err := p.call("DeleteTarget")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete target")
+	}
+
+	if proj, ok := p.vault.projects[projectName]; ok {
+		delete(proj.targets, targetName)
+	}
+	return nil
+}
+
+func (p *vaultProvider) GetProject(projectName string) (responses.GetProject, error) {
+	unlock, err := p.call("GetProject")
+	defer unlock()
+	if err != nil {
+		return responses.GetProject{}, err
+	}
+
+	if _, ok := p.vault.projects[projectName]; !ok {
+		return responses.GetProject{}, credentials.ErrNotFound
+	}
+	return responses.GetProject{Name: projectName}, nil
+}
+
+func (p *vaultProvider) GetTarget(projectName, targetName string) (types.Target, error) {
+	unlock, err := p.call("GetTarget")
+	defer unlock()
+	if err != nil {
+		return types.Target{}, err
+	}
+
+	if !p.isAdmin() {
+		return types.Target{}, errors.New("admin credentials must be used to get target information")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return types.Target{}, credentials.ErrTargetNotFound
+	}
+	target, ok := proj.targets[targetName]
+	if !ok {
+		return types.Target{}, credentials.ErrTargetNotFound
+	}
+	return target, nil
+}
+
+// GetToken returns a token when the authorization matches the credentials
+// returned by CreateProject.
+func (p *vaultProvider) GetToken() (string, error) {
+	unlock, err := p.call("GetToken")
+	defer unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if p.isAdmin() {
+		return "", errors.New("admin credentials cannot be used to get tokens")
+	}
+
+	for name, proj := range p.vault.projects {
+		if proj.roleID == p.auth.Key && proj.secretID == p.auth.Secret {
+			p.vault.seq++
+			return fmt.Sprintf("fake-token-%s-%d", name, p.vault.seq), nil
+		}
+	}
+	return "", errors.New("invalid role or secret")
+}
+
+// GetRunToken returns a run token when the authorization matches the
+// credentials returned by CreateProject. Wrapped tokens are prefixed with
+// 'fake-wrapped-'.
+func (p *vaultProvider) GetRunToken(wrap bool) (credentials.RunToken, error) {
+	unlock, err := p.call("GetRunToken")
+	defer unlock()
+	if err != nil {
+		return credentials.RunToken{}, err
+	}
+
+	if p.isAdmin() {
+		return credentials.RunToken{}, errors.New("admin credentials cannot be used to get tokens")
+	}
+
+	for name, proj := range p.vault.projects {
+		if proj.roleID == p.auth.Key && proj.secretID == p.auth.Secret {
+			p.vault.seq++
+			rt := credentials.RunToken{
+				Token:    fmt.Sprintf("fake-token-%s-%d", name, p.vault.seq),
+				Accessor: fmt.Sprintf("fake-accessor-%s-%d", name, p.vault.seq),
+			}
+			if wrap {
+				rt.Token = "fake-wrapped-" + rt.Token
+			}
+			p.vault.revoked[rt.Accessor] = false
+			return rt, nil
+		}
+	}
+	return credentials.RunToken{}, errors.New("invalid role or secret")
+}
+
+func (p *vaultProvider) RevokeToken(accessor string) error {
+	unlock, err := p.call("RevokeToken")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to revoke tokens")
+	}
+
+	if _, ok := p.vault.revoked[accessor]; !ok {
+		return fmt.Errorf("invalid accessor '%s'", accessor)
+	}
+	p.vault.revoked[accessor] = true
+	return nil
+}
+
+func (p *vaultProvider) GetProjectToken(projectName string) (string, error) {
+	unlock, err := p.call("GetProjectToken")
+	defer unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if !p.isAdmin() {
+		return "", errors.New("admin credentials must be used to get project tokens")
+	}
+
+	if _, ok := p.vault.projects[projectName]; !ok {
+		return "", fmt.Errorf("project '%s' not found", projectName)
+	}
+	p.vault.seq++
+	return fmt.Sprintf("fake-token-%s-%d", projectName, p.vault.seq), nil
+}
+
+func (p *vaultProvider) ListTargets(projectName string) ([]string, error) {
+	unlock, err := p.call("ListTargets")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isAdmin() {
+		return nil, errors.New("admin credentials must be used to list targets")
+	}
+
+	list := make([]string, 0)
+	if proj, ok := p.vault.projects[projectName]; ok {
+		for name := range proj.targets {
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+func (p *vaultProvider) ProjectExists(name string) (bool, error) {
+	unlock, err := p.call("ProjectExists")
+	defer unlock()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := p.vault.projects[name]
+	return ok, nil
+}
+
+func (p *vaultProvider) ProjectAuthorized(name string) (bool, error) {
+	unlock, err := p.call("ProjectAuthorized")
+	defer unlock()
+	if err != nil {
+		return false, err
+	}
+
+	proj, ok := p.vault.projects[name]
+	return ok && proj.roleID == p.auth.Key && proj.secretID == p.auth.Secret, nil
+}
+
+func (p *vaultProvider) Identity() (credentials.Identity, error) {
+	unlock, err := p.call("Identity")
+	defer unlock()
+	if err != nil {
+		return credentials.Identity{}, err
+	}
+
+	if p.isAdmin() {
+		return credentials.Identity{Admin: true}, nil
+	}
+	for name, proj := range p.vault.projects {
+		if proj.roleID == p.auth.Key && proj.secretID == p.auth.Secret {
+			return credentials.Identity{Project: name}, nil
+		}
+	}
+	return credentials.Identity{}, credentials.ErrInvalidCredentials
+}
+
+func (p *vaultProvider) TargetExists(projectName, targetName string) (bool, error) {
+	unlock, err := p.call("TargetExists")
+	defer unlock()
+	if err != nil {
+		return false, err
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return false, nil
+	}
+	_, ok = proj.targets[targetName]
+	return ok, nil
+}
+
+func (p *vaultProvider) PutTargetHostCredentials(projectName, targetName string, c credentials.HostCredentials) error {
+	unlock, err := p.call("PutTargetHostCredentials")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to put target host credentials")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return credentials.ErrNotFound
+	}
+	proj.hosts[targetName] = c
+	return nil
+}
+
+func (p *vaultProvider) DeleteTargetHostCredentials(projectName, targetName string) error {
+	unlock, err := p.call("DeleteTargetHostCredentials")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete target host credentials")
+	}
+
+	if proj, ok := p.vault.projects[projectName]; ok {
+		delete(proj.hosts, targetName)
+	}
+	return nil
+}
+
+func (p *vaultProvider) PutRegistryPassword(ref, password string) error {
+	unlock, err := p.call("PutRegistryPassword")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to put registry password")
+	}
+
+	p.vault.registries[ref] = password
+	return nil
+}
+
+func (p *vaultProvider) GetRegistryPassword(ref string) (string, error) {
+	unlock, err := p.call("GetRegistryPassword")
+	defer unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if !p.isAdmin() {
+		return "", errors.New("admin credentials must be used to get registry password")
+	}
+
+	password, ok := p.vault.registries[ref]
+	if !ok {
+		return "", credentials.ErrNotFound
+	}
+	return password, nil
+}
+
+func (p *vaultProvider) DeleteRegistryPassword(ref string) error {
+	unlock, err := p.call("DeleteRegistryPassword")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete registry password")
+	}
+
+	delete(p.vault.registries, ref)
+	return nil
+}
+
+// GetTargetCredentials returns fake credentials of an existing target, with
+// leases named 'fake-lease-<project>-<target>-<n>'.
+func (p *vaultProvider) GetTargetCredentials(projectName, targetName string, ttl time.Duration) (credentials.TargetCredentials, error) {
+	unlock, err := p.call("GetTargetCredentials")
+	defer unlock()
+	if err != nil {
+		return credentials.TargetCredentials{}, err
+	}
+
+	if !p.isAdmin() {
+		return credentials.TargetCredentials{}, errors.New("admin credentials must be used to get target credentials")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return credentials.TargetCredentials{}, credentials.ErrTargetNotFound
+	}
+	if _, ok := proj.targets[targetName]; !ok {
+		return credentials.TargetCredentials{}, credentials.ErrTargetNotFound
+	}
+
+	p.vault.seq++
+	c := credentials.TargetCredentials{
+		AccessKeyID:     fmt.Sprintf("FAKEACCESSKEY%d", p.vault.seq),
+		SecretAccessKey: fmt.Sprintf("fake-secret-key-%d", p.vault.seq),
+		SessionToken:    fmt.Sprintf("fake-session-token-%d", p.vault.seq),
+		LeaseID:         fmt.Sprintf("fake-lease-%s-%s-%d", projectName, targetName, p.vault.seq),
+		LeaseTTL:        ttl,
+	}
+	p.vault.leases[c.LeaseID] = false
+	return c, nil
+}
+
+func (p *vaultProvider) RevokeLease(leaseID string) error {
+	unlock, err := p.call("RevokeLease")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to revoke leases")
+	}
+
+	if _, ok := p.vault.leases[leaseID]; !ok {
+		return fmt.Errorf("invalid lease '%s'", leaseID)
+	}
+	p.vault.leases[leaseID] = true
+	return nil
+}
+
+func (p *vaultProvider) GetPolicyTemplate() (credentials.PolicyTemplate, error) {
+	unlock, err := p.call("GetPolicyTemplate")
+	defer unlock()
+	if err != nil {
+		return credentials.PolicyTemplate{}, err
+	}
+
+	if !p.isAdmin() {
+		return credentials.PolicyTemplate{}, errors.New("admin credentials must be used to get the policy template")
+	}
+
+	if p.vault.policyTemplate == "" {
+		return credentials.PolicyTemplate{Template: credentials.DefaultPolicyTemplate, Default: true}, nil
+	}
+	return credentials.PolicyTemplate{Template: p.vault.policyTemplate}, nil
+}
+
+func (p *vaultProvider) PutPolicyTemplate(tmpl string) error {
+	unlock, err := p.call("PutPolicyTemplate")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to put the policy template")
+	}
+
+	if err := credentials.ValidatePolicyTemplate(tmpl); err != nil {
+		return fmt.Errorf("%w, %s", credentials.ErrInvalidPolicyTemplate, err)
+	}
+	p.vault.policyTemplate = tmpl
+	return nil
+}
+
+func (p *vaultProvider) DeletePolicyTemplate() error {
+	unlock, err := p.call("DeletePolicyTemplate")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+
+	if !p.isAdmin() {
+		return errors.New("admin credentials must be used to delete the policy template")
+	}
+
+	p.vault.policyTemplate = ""
+	return nil
+}
+
+func (p *vaultProvider) GetPolicyUsage(projectName string) (credentials.PolicyUsage, error) {
+	unlock, err := p.call("GetPolicyUsage")
+	defer unlock()
+	if err != nil {
+		return credentials.PolicyUsage{}, err
+	}
+
+	if !p.isAdmin() {
+		return credentials.PolicyUsage{}, errors.New("admin credentials must be used to get policy usage")
+	}
+
+	proj, ok := p.vault.projects[projectName]
+	if !ok {
+		return credentials.PolicyUsage{}, credentials.ErrNotFound
+	}
+	targets := make([]string, 0, len(proj.targets))
+	for name := range proj.targets {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+
+	tmpl := p.vault.policyTemplate
+	if tmpl == "" {
+		tmpl = credentials.DefaultPolicyTemplate
+	}
+	policy, err := credentials.RenderPolicy(tmpl, projectName, targets)
+	if err != nil {
+		return credentials.PolicyUsage{}, err
+	}
+	return credentials.PolicyUsage{
+		Bytes:    len(policy),
+		Policies: []string{fmt.Sprintf("argo-cloudops-projects-%s", projectName)},
+		Targets:  len(targets),
+	}, nil
+}
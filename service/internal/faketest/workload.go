@@ -0,0 +1,49 @@
+package faketest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cello-proj/cello/service/internal/workload"
+)
+
+// ServiceAccounts is a fake workload.ServiceAccounts keeping the roles of
+// service accounts in memory. Operations are named after the
+// workload.ServiceAccounts methods.
+type ServiceAccounts struct {
+	*Script
+
+	mu    sync.Mutex
+	roles map[string]string
+}
+
+// NewServiceAccounts creates fake ServiceAccounts with no service accounts.
+func NewServiceAccounts() *ServiceAccounts {
+	return &ServiceAccounts{
+		Script: newScript(),
+		roles:  map[string]string{},
+	}
+}
+
+// AddServiceAccount adds a service account assuming the role.
+func (s *ServiceAccounts) AddServiceAccount(name, roleARN string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[name] = roleARN
+}
+
+// RoleARN returns the role of a service account.
+func (s *ServiceAccounts) RoleARN(ctx context.Context, name string) (string, error) {
+	if err := s.apply(ctx, "RoleARN"); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	role, ok := s.roles[name]
+	if !ok {
+		return "", fmt.Errorf("service account '%s' %w", name, workload.ErrServiceAccountNotFound)
+	}
+	return role, nil
+}
@@ -0,0 +1,103 @@
+// Package feature decides whether features being rolled out are enabled. Flags
+// have a default state which the service config can override globally and
+// projects can override for themselves, so features can be enabled for some
+// projects before every project.
+package feature
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Feature flags.
+const (
+	// AdminStats serves GET /admin/stats and GET /admin/concurrency.
+	AdminStats = "admin-stats"
+	// WrapRunTokens passes workflows of the project a response wrapping token
+	// instead of their Vault token, like ARGO_CLOUDOPS_VAULT_WRAP_RUN_TOKENS
+	// does for every project.
+	WrapRunTokens = "wrap-run-tokens"
+)
+
+// Default states of the flags.
+var defaults = map[string]bool{
+	AdminStats:    false,
+	WrapRunTokens: false,
+}
+
+// Sources of the state of a flag.
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceProject = "project"
+)
+
+// State is the state of a flag and where it comes from.
+type State struct {
+	Name    string
+	Enabled bool
+	Source  string
+}
+
+// Names returns the sorted names of the flags.
+func Names() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Known reports whether the flag exists.
+func Known(name string) bool {
+	_, ok := defaults[name]
+	return ok
+}
+
+// Flags are the global states of the flags. The zero value has the default
+// states.
+type Flags struct {
+	global map[string]bool
+}
+
+// New creates Flags with the defaults overridden by the config, keyed by
+// flag name. Unknown flags are an error.
+func New(config map[string]bool) (Flags, error) {
+	global := map[string]bool{}
+	for name, enabled := range config {
+		if !Known(name) {
+			return Flags{}, fmt.Errorf("unknown feature flag '%s'", name)
+		}
+		global[name] = enabled
+	}
+	return Flags{global: global}, nil
+}
+
+// Enabled reports whether the flag is enabled, preferring the overrides of a
+// project (keyed by flag name) to the global state. Unknown flags are
+// disabled.
+func (f Flags) Enabled(name string, overrides map[string]bool) bool {
+	return f.State(name, overrides).Enabled
+}
+
+// State returns the state of the flag, preferring the overrides of a project
+// (keyed by flag name) to the global state.
+func (f Flags) State(name string, overrides map[string]bool) State {
+	if enabled, ok := overrides[name]; ok && Known(name) {
+		return State{Name: name, Enabled: enabled, Source: SourceProject}
+	}
+	if enabled, ok := f.global[name]; ok {
+		return State{Name: name, Enabled: enabled, Source: SourceConfig}
+	}
+	return State{Name: name, Enabled: defaults[name], Source: SourceDefault}
+}
+
+// States returns the states of every flag ordered by name.
+func (f Flags) States(overrides map[string]bool) []State {
+	states := []State{}
+	for _, name := range Names() {
+		states = append(states, f.State(name, overrides))
+	}
+	return states
+}
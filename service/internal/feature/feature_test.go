@@ -0,0 +1,70 @@
+package feature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(map[string]bool{AdminStats: true})
+	assert.Nil(t, err)
+
+	_, err = New(map[string]bool{"new-backend": true})
+	assert.EqualError(t, err, "unknown feature flag 'new-backend'")
+}
+
+func TestFlagsState(t *testing.T) {
+	f, err := New(map[string]bool{AdminStats: true})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		flag      string
+		overrides map[string]bool
+		want      State
+	}{
+		{
+			name: "default",
+			flag: WrapRunTokens,
+			want: State{Name: WrapRunTokens, Enabled: false, Source: SourceDefault},
+		},
+		{
+			name: "config",
+			flag: AdminStats,
+			want: State{Name: AdminStats, Enabled: true, Source: SourceConfig},
+		},
+		{
+			name:      "project overrides config",
+			flag:      AdminStats,
+			overrides: map[string]bool{AdminStats: false},
+			want:      State{Name: AdminStats, Enabled: false, Source: SourceProject},
+		},
+		{
+			name:      "project overrides default",
+			flag:      WrapRunTokens,
+			overrides: map[string]bool{WrapRunTokens: true},
+			want:      State{Name: WrapRunTokens, Enabled: true, Source: SourceProject},
+		},
+		{
+			name:      "unknown",
+			flag:      "new-backend",
+			overrides: map[string]bool{"new-backend": true},
+			want:      State{Name: "new-backend", Enabled: false, Source: SourceDefault},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, f.State(tt.flag, tt.overrides))
+			assert.Equal(t, tt.want.Enabled, f.Enabled(tt.flag, tt.overrides))
+		})
+	}
+}
+
+func TestFlagsStates(t *testing.T) {
+	assert.Equal(t, []State{
+		{Name: AdminStats, Enabled: false, Source: SourceDefault},
+		{Name: WrapRunTokens, Enabled: true, Source: SourceProject},
+	}, Flags{}.States(map[string]bool{WrapRunTokens: true}))
+}
@@ -1,48 +1,53 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"io/ioutil"
+	nethttp "net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
 // Client allows for retrieving data from git repo
 type Client interface {
-	GetManifestFile(repository, commitHash, path string) ([]byte, error)
+	GetManifestFile(ctx context.Context, repository, commitHash, path string) ([]byte, error)
 }
 
 type gitSvc interface {
-	PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error)
+	PlainClone(ctx context.Context, path string, isBare bool, o *git.CloneOptions) (*git.Repository, error)
 	PlainOpen(path string) (*git.Repository, error)
-	Fetch(r *git.Repository, o *git.FetchOptions) error
+	Fetch(ctx context.Context, r *git.Repository, o *git.FetchOptions) error
 	Worktree(r *git.Repository) (*git.Worktree, error)
 	Checkout(w *git.Worktree, opts *git.CheckoutOptions) error
 }
 
 type gitSvcImpl struct{}
 
-func (g gitSvcImpl) PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
-	return git.PlainClone(path, isBare, o)
+func (g gitSvcImpl) PlainClone(ctx context.Context, path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
+	return git.PlainCloneContext(ctx, path, isBare, o)
 }
 
 func (g gitSvcImpl) PlainOpen(path string) (*git.Repository, error) {
 	return git.PlainOpen(path)
 }
 
-func (g gitSvcImpl) Fetch(r *git.Repository, o *git.FetchOptions) error {
-	return r.Fetch(o)
+func (g gitSvcImpl) Fetch(ctx context.Context, r *git.Repository, o *git.FetchOptions) error {
+	return r.FetchContext(ctx, o)
 }
 
 func (g gitSvcImpl) Worktree(r *git.Repository) (*git.Worktree, error) {
@@ -92,6 +97,12 @@ func NewHTTPSBasicClient(user, pass string, opts ...Option) (BasicClient, error)
 	return newBasicClient(auth, opts...), nil
 }
 
+// UseHTTPClient uses the client for the https repositories of every git
+// client, e.g. one going through a proxy, since go-git transports are global.
+func UseHTTPClient(cl *nethttp.Client) {
+	client.InstallProtocol("https", http.NewClient(cl))
+}
+
 func newBasicClient(auth transport.AuthMethod, opts ...Option) BasicClient {
 	cl := BasicClient{
 		auth:    auth,
@@ -109,7 +120,17 @@ func newBasicClient(auth transport.AuthMethod, opts ...Option) BasicClient {
 	return cl
 }
 
-func (g BasicClient) GetManifestFile(repository, commitHash, path string) ([]byte, error) {
+// Ping lists the remote refs of the repository, failing when it's
+// unreachable or the credentials of the client are rejected.
+func (g BasicClient) Ping(ctx context.Context, repository string) error {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{repository}})
+	_, err := remote.ListContext(ctx, &git.ListOptions{Auth: g.auth})
+	return err
+}
+
+// GetManifestFile returns the file at path of the repository at the commit,
+// cloning or fetching the repository with the context.
+func (g BasicClient) GetManifestFile(ctx context.Context, repository, commitHash, path string) ([]byte, error) {
 	// filePath should only be used for git calls. direct fs calls should use repository directly
 	repPath := strings.ReplaceAll(repository, "/", "")
 	filePath := filepath.Join(g.baseDir, repPath)
@@ -122,8 +143,8 @@ func (g BasicClient) GetManifestFile(repository, commitHash, path string) ([]byt
 	var repo *git.Repository
 
 	if _, err := fs.Stat(g.fs, repPath); os.IsNotExist(err) {
-		// TODO: use context version and make depth configurable
-		repo, err = g.git.PlainClone(filePath, false, &git.CloneOptions{
+		// TODO: make depth configurable
+		repo, err = g.git.PlainClone(ctx, filePath, false, &git.CloneOptions{
 			URL:      repository,
 			Auth:     g.auth,
 			Progress: g.pw,
@@ -136,7 +157,7 @@ func (g BasicClient) GetManifestFile(repository, commitHash, path string) ([]byt
 		if err != nil {
 			return []byte{}, err
 		}
-		err = g.git.Fetch(repo, &git.FetchOptions{
+		err = g.git.Fetch(ctx, repo, &git.FetchOptions{
 			Progress: g.pw,
 			Auth:     g.auth,
 		})
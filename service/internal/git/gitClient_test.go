@@ -1,19 +1,25 @@
 package git
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -28,7 +34,7 @@ type mockGitSvc struct {
 	coErr       error
 }
 
-func (g *mockGitSvc) PlainClone(path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
+func (g *mockGitSvc) PlainClone(ctx context.Context, path string, isBare bool, o *git.CloneOptions) (*git.Repository, error) {
 	g.cloneOpts = o
 
 	if g.pcErr != nil {
@@ -53,7 +59,7 @@ func (g *mockGitSvc) PlainOpen(path string) (*git.Repository, error) {
 	return nil, nil
 }
 
-func (g *mockGitSvc) Fetch(r *git.Repository, o *git.FetchOptions) error {
+func (g *mockGitSvc) Fetch(ctx context.Context, r *git.Repository, o *git.FetchOptions) error {
 	g.fetchOpts = o
 	if g.fetchErr != nil {
 		return g.fetchErr
@@ -166,7 +172,7 @@ func TestGetManifestErrors(t *testing.T) {
 
 			repo := defaultString(tt.repo, "myrepo3")
 			path := defaultString(tt.path, "path/to/manifest.yaml")
-			_, err := cl.GetManifestFile(repo, "123", path)
+			_, err := cl.GetManifestFile(context.Background(), repo, "123", path)
 
 			for _, want := range []error{tt.pc, tt.po, tt.fetch, tt.wt, tt.co} {
 				if want != nil && !errors.Is(err, want) {
@@ -221,7 +227,7 @@ func TestGetManifestFile(t *testing.T) {
 	WithProgressWriter(pw)(&gitClient)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res, err := gitClient.GetManifestFile(tt.repository, tt.commitHash, tt.path)
+			res, err := gitClient.GetManifestFile(context.Background(), tt.repository, tt.commitHash, tt.path)
 			if err != nil {
 				if !tt.errResult {
 					t.Errorf("\ndid not expect error, got: %v", err)
@@ -308,3 +314,33 @@ func defaultString(in, def string) string {
 
 	return in
 }
+
+func TestPing(t *testing.T) {
+	t.Run("lists the refs of the repository", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		assertNoErr(t, err)
+		wt, err := repo.Worktree()
+		assertNoErr(t, err)
+		assertNoErr(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("type: sync\n"), 0600))
+		_, err = wt.Add("manifest.yaml")
+		assertNoErr(t, err)
+		_, err = wt.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+		assertNoErr(t, err)
+
+		assertNoErr(t, newBasicClient(nil).Ping(context.Background(), dir))
+	})
+
+	t.Run("fails when the credentials are rejected", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		cl, err := NewHTTPSBasicClient("user", "wrong")
+		assertNoErr(t, err)
+		if err := cl.Ping(context.Background(), srv.URL+"/repo.git"); err == nil {
+			t.Error("expected error, received nil")
+		}
+	})
+}
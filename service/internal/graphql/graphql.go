@@ -0,0 +1,305 @@
+// Package graphql executes read-only GraphQL queries against a schema of
+// resolver objects. It supports the query subset used by clients fetching
+// nested data: variables, aliases, arguments and the @skip and @include
+// directives. Mutations, subscriptions, fragments and introspection aren't
+// supported.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownField is returned by objects resolving a field they don't have.
+var ErrUnknownField = errors.New("unknown field")
+
+// Object is a value of an object type of the schema.
+type Object interface {
+	// TypeName is the name of the type of the object.
+	TypeName() string
+	// Field resolves the field with the arguments. Objects are resolved to
+	// Object or []Object, scalars to JSON encodable values. Fields the
+	// object doesn't have return ErrUnknownField.
+	Field(ctx context.Context, name string, args Args) (interface{}, error)
+}
+
+// Request is a GraphQL request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is a GraphQL response. Data holds the fields which could be
+// resolved when only some failed.
+type Response struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it occurred at.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Args are the arguments of a field, with variables resolved.
+type Args map[string]interface{}
+
+// String returns the string argument, or an error when it's missing or
+// isn't a string.
+func (a Args) String(name string) (string, error) {
+	s, ok := a[name].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a non-null String", name)
+	}
+	return s, nil
+}
+
+// Int returns the int argument, or def when it's missing.
+func (a Args) Int(name string, def int) (int, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case float64:
+		// Variables decoded from JSON are floats.
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an Int", name)
+}
+
+// Execute executes the query of the request against the root object.
+func Execute(ctx context.Context, root Object, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	vars, err := op.variableValues(req.Variables)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	e := &executor{variables: vars}
+	data := e.selectionSet(ctx, root, op.selection, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+func (d *document) operation(name string) (*operation, error) {
+	var op *operation
+	switch {
+	case name != "":
+		for _, o := range d.operations {
+			if o.name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+	case len(d.operations) > 1:
+		return nil, errors.New("operationName is required for documents with several operations")
+	default:
+		op = d.operations[0]
+	}
+
+	if op.kind != "query" {
+		return nil, fmt.Errorf("%ss aren't supported, only queries are", op.kind)
+	}
+	return op, nil
+}
+
+func (o *operation) variableValues(provided map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range o.variables {
+		v, ok := provided[def.name]
+		if !ok && def.hasDefault {
+			v, ok = def.defaultValue, true
+		}
+		if v == nil && def.nonNull {
+			return nil, fmt.Errorf("variable $%s of non-null type must be provided", def.name)
+		}
+		if ok {
+			vars[def.name] = v
+		}
+	}
+	return vars, nil
+}
+
+type executor struct {
+	variables map[string]interface{}
+	errors    []Error
+}
+
+func (e *executor) fail(path []interface{}, err error) {
+	e.errors = append(e.errors, Error{Message: err.Error(), Path: append([]interface{}{}, path...)})
+}
+
+func (e *executor) selectionSet(ctx context.Context, obj Object, fields []*field, path []interface{}) *result {
+	res := &result{}
+	for _, f := range fields {
+		key := f.responseKey()
+		if res.has(key) {
+			continue
+		}
+
+		include, err := e.included(f)
+		if err != nil {
+			e.fail(append(path, key), err)
+			continue
+		}
+		if !include {
+			continue
+		}
+
+		res.set(key, e.field(ctx, obj, f, append(path, key)))
+	}
+	return res
+}
+
+// included evaluates the @skip and @include directives of the field.
+func (e *executor) included(f *field) (bool, error) {
+	for _, d := range f.directives {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		v, ok := e.value(d.arguments["if"]).(bool)
+		if !ok {
+			return false, fmt.Errorf("argument \"if\" of @%s must be a non-null Boolean", d.name)
+		}
+		if (d.name == "skip") == v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *executor) field(ctx context.Context, obj Object, f *field, path []interface{}) interface{} {
+	if f.name == "__typename" {
+		return obj.TypeName()
+	}
+	if len(f.name) > 1 && f.name[:2] == "__" {
+		e.fail(path, errors.New("introspection isn't supported"))
+		return nil
+	}
+
+	args := Args{}
+	for name, v := range f.arguments {
+		args[name] = e.value(v)
+	}
+
+	v, err := obj.Field(ctx, f.name, args)
+	if errors.Is(err, ErrUnknownField) {
+		e.fail(path, fmt.Errorf("cannot query field %q on type %q", f.name, obj.TypeName()))
+		return nil
+	}
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	return e.complete(ctx, v, f, path)
+}
+
+func (e *executor) complete(ctx context.Context, v interface{}, f *field, path []interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case Object:
+		if f.selection == nil {
+			e.fail(path, fmt.Errorf("field %q of type %q must have a selection of subfields", f.name, v.TypeName()))
+			return nil
+		}
+		return e.selectionSet(ctx, v, f.selection, path)
+	case []Object:
+		list := make([]interface{}, len(v))
+		for i, o := range v {
+			list[i] = e.complete(ctx, o, f, append(path, i))
+		}
+		return list
+	}
+
+	if f.selection != nil {
+		e.fail(path, fmt.Errorf("field %q is a scalar and can't have a selection of subfields", f.name))
+		return nil
+	}
+	return v
+}
+
+// value resolves the variables of an argument value.
+func (e *executor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case variable:
+		return e.variables[string(v)]
+	case enumValue:
+		return string(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = e.value(item)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			obj[k] = e.value(item)
+		}
+		return obj
+	}
+	return v
+}
+
+// result is the result of a selection set, encoded with its fields in the
+// order they were selected.
+type result struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *result) has(key string) bool {
+	_, ok := r.values[key]
+	return ok
+}
+
+func (r *result) set(key string, v interface{}) {
+	if r.values == nil {
+		r.values = map[string]interface{}{}
+	}
+	r.keys = append(r.keys, key)
+	r.values[key] = v
+}
+
+// MarshalJSON encodes the result as an object.
+func (r *result) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range r.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
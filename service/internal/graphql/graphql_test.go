@@ -0,0 +1,277 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testQuery struct{}
+
+func (testQuery) TypeName() string { return "Query" }
+
+func (testQuery) Field(ctx context.Context, name string, args Args) (interface{}, error) {
+	switch name {
+	case "books":
+		limit, err := args.Int("limit", 2)
+		if err != nil {
+			return nil, err
+		}
+		books := []Object{testBook{"Dune", 412}, testBook{"Emma", 474}, testBook{"Ulysses", 730}}
+		if limit < len(books) {
+			books = books[:limit]
+		}
+		return books, nil
+	case "book":
+		title, err := args.String("title")
+		if err != nil {
+			return nil, err
+		}
+		if title == "missing" {
+			return nil, nil
+		}
+		return testBook{title, 100}, nil
+	case "broken":
+		return nil, errors.New("broken")
+	}
+	return nil, ErrUnknownField
+}
+
+type testBook struct {
+	title string
+	pages int
+}
+
+func (testBook) TypeName() string { return "Book" }
+
+func (b testBook) Field(ctx context.Context, name string, args Args) (interface{}, error) {
+	switch name {
+	case "title":
+		return b.title, nil
+	case "pages":
+		return b.pages, nil
+	}
+	return nil, ErrUnknownField
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "nested selection in selection order",
+			req:  Request{Query: `{ books { title pages } }`},
+			want: `{"data":{"books":[{"title":"Dune","pages":412},{"title":"Emma","pages":474}]}}`,
+		},
+		{
+			name: "named query with aliases, arguments and typename",
+			req:  Request{Query: `query Books { all: books(limit: 3) { __typename title } one: book(title: "Emma") { pages } }`},
+			want: `{"data":{"all":[{"__typename":"Book","title":"Dune"},{"__typename":"Book","title":"Emma"},{"__typename":"Book","title":"Ulysses"}],"one":{"pages":100}}}`,
+		},
+		{
+			name: "variables and defaults",
+			req: Request{
+				Query:     `query ($limit: Int = 3, $title: String!) { books(limit: $limit) { title } book(title: $title) { title } }`,
+				Variables: map[string]interface{}{"limit": float64(1), "title": "Emma"},
+			},
+			want: `{"data":{"books":[{"title":"Dune"}],"book":{"title":"Emma"}}}`,
+		},
+		{
+			name: "missing non-null variable",
+			req:  Request{Query: `query ($title: String!) { book(title: $title) { title } }`},
+			want: `{"data":null,"errors":[{"message":"variable $title of non-null type must be provided"}]}`,
+		},
+		{
+			name: "skip and include",
+			req:  Request{Query: `query ($skip: Boolean = true) { books(limit: 1) { title pages @skip(if: $skip) } book(title: "x") @include(if: false) { title } }`},
+			want: `{"data":{"books":[{"title":"Dune"}]}}`,
+		},
+		{
+			name: "null objects",
+			req:  Request{Query: `{ book(title: "missing") { title } }`},
+			want: `{"data":{"book":null}}`,
+		},
+		{
+			name: "partial results with field errors",
+			req:  Request{Query: `{ books(limit: "a") { title } broken book(title: "Emma") { title author } }`},
+			want: `{"data":{"books":null,"broken":null,"book":{"title":"Emma","author":null}},"errors":[{"message":"argument \"limit\" must be an Int","path":["books"]},{"message":"broken","path":["broken"]},{"message":"cannot query field \"author\" on type \"Book\"","path":["book","author"]}]}`,
+		},
+		{
+			name: "objects require a selection",
+			req:  Request{Query: `{ book(title: "Emma") }`},
+			want: `{"data":{"book":null},"errors":[{"message":"field \"book\" of type \"Book\" must have a selection of subfields","path":["book"]}]}`,
+		},
+		{
+			name: "scalars can't have a selection",
+			req:  Request{Query: `{ books(limit: 1) { title { length } } }`},
+			want: `{"data":{"books":[{"title":null}]},"errors":[{"message":"field \"title\" is a scalar and can't have a selection of subfields","path":["books",0,"title"]}]}`,
+		},
+		{
+			name: "operation by name",
+			req:  Request{Query: `query A { books(limit: 1) { title } } query B { book(title: "B") { title } }`, OperationName: "B"},
+			want: `{"data":{"book":{"title":"B"}}}`,
+		},
+		{
+			name: "operation name required",
+			req:  Request{Query: `query A { broken } query B { broken }`},
+			want: `{"data":null,"errors":[{"message":"operationName is required for documents with several operations"}]}`,
+		},
+		{
+			name: "mutations aren't supported",
+			req:  Request{Query: `mutation { broken }`},
+			want: `{"data":null,"errors":[{"message":"mutations aren't supported, only queries are"}]}`,
+		},
+		{
+			name: "fragments aren't supported",
+			req:  Request{Query: "{\n  books { ...bookFields }\n}"},
+			want: `{"data":null,"errors":[{"message":"syntax error at 2:11: fragments aren't supported"}]}`,
+		},
+		{
+			name: "introspection isn't supported",
+			req:  Request{Query: `{ __schema { types { name } } }`},
+			want: `{"data":{"__schema":null},"errors":[{"message":"introspection isn't supported","path":["__schema"]}]}`,
+		},
+		{
+			name: "syntax error",
+			req:  Request{Query: `{ books(limit: ) { title } }`},
+			want: `{"data":null,"errors":[{"message":"syntax error at 1:16: expected a value, found \")\""}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(Execute(context.Background(), testQuery{}, tt.req))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+			// Fields are encoded in the order they were selected.
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`# comment
+		query {
+			f(s: "a\"bé", b: """ block "quoted" """, i: -12, f: 1.5e2, t: true, n: null, e: ENUM, l: [1, 2], o: {k: "v"})
+		}`)
+	assert.NoError(t, err)
+
+	want := map[string]interface{}{
+		"s": "a\"bé",
+		"b": `block "quoted"`,
+		"i": -12,
+		"f": 150.0,
+		"t": true,
+		"n": nil,
+		"e": enumValue("ENUM"),
+		"l": []interface{}{1, 2},
+		"o": map[string]interface{}{"k": "v"},
+	}
+	assert.Equal(t, want, doc.operations[0].selection[0].arguments)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty document", ``, "syntax error at 1:1: expected an operation"},
+		{"only a comment", `# comment`, "syntax error at 1:10: expected an operation"},
+		{"unclosed selection set", `{ books { title }`, "syntax error at 1:18: expected \"}\", found end of document"},
+		{"extra closing brace", `{ books { title } }}`, "syntax error at 1:20: expected an operation, found \"}\""},
+		{"empty selection set", `{}`, "syntax error at 1:2: selection sets must select a field"},
+		{"trailing garbage", `{ books { title } } garbage`, "syntax error at 1:21: expected an operation, found \"garbage\""},
+		{"missing alias field", `{ a: }`, "syntax error at 1:6: expected a name, found \"}\""},
+		{"unexpected character", `{ books % }`, "syntax error at 1:9: unexpected character '%'"},
+		{"unterminated string", `{ book(title: "Emma) { title } }`, "syntax error at 1:15: unterminated string"},
+		{"unterminated block string", `{ book(title: """Emma) { title } }`, "syntax error at 1:15: unterminated string"},
+		{"string with a line break", "{ book(title: \"a\nb\") { title } }", "syntax error at 1:15: unterminated string"},
+		{"invalid escape", `{ book(title: "a\qb") { title } }`, "syntax error at 1:15: invalid escape \\q"},
+		{"invalid unicode escape", `{ book(title: "\u12G4") { title } }`, "syntax error at 1:15: invalid unicode escape"},
+		{"argument without colon", `{ book(title "Emma") { title } }`, "syntax error at 1:14: expected \":\", found \"Emma\""},
+		{"unclosed arguments", `{ books(limit: 1 { title } }`, "syntax error at 1:18: expected a name, found \"{\""},
+		{"unclosed list", `{ books(limit: [1, 2) { title } }`, "syntax error at 1:21: expected a value, found \")\""},
+		{"unclosed object", `{ books(limit: {a: 1) { title } }`, "syntax error at 1:21: expected a name, found \")\""},
+		{"object without names", `{ books(limit: {1: 1}) { title } }`, "syntax error at 1:17: expected a name, found \"1\""},
+		{"int out of range", `{ books(limit: 99999999999999999999) { title } }`, "syntax error at 1:16: invalid int \"99999999999999999999\""},
+		{"float out of range", `{ books(limit: 1.5e999) { title } }`, "syntax error at 1:16: invalid float \"1.5e999\""},
+		{"variable in default value", `query ($l: Int = $x) { books(limit: $l) { title } }`, "syntax error at 1:18: expected a value, found \"$\""},
+		{"unclosed list type", `query ($l: [Int) { books { title } }`, "syntax error at 1:16: expected \"]\", found \")\""},
+		{"operation without selection", `query A`, "syntax error at 1:8: expected \"{\", found end of document"},
+		{"operation directives", `query @dir { books { title } }`, "syntax error at 1:7: operation directives aren't supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.query)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	// nested nests inner n times between open and close.
+	nested := func(open, inner, close string, n int) string {
+		return strings.Repeat(open, n) + inner + strings.Repeat(close, n)
+	}
+
+	tests := []struct {
+		name    string
+		query   func(n int) string
+		maxNest int
+	}{
+		{
+			name:    "selection sets",
+			query:   func(n int) string { return nested("{ a ", "{ b }", "}", n-1) },
+			maxNest: maxDepth,
+		},
+		{
+			name:    "list values",
+			query:   func(n int) string { return "{ f(a: " + nested("[", "1", "]", n) + ") }" },
+			maxNest: maxDepth - 1,
+		},
+		{
+			name:    "object values",
+			query:   func(n int) string { return "{ f(a: " + nested("{a: ", "1", "}", n) + ") }" },
+			maxNest: maxDepth - 1,
+		},
+		{
+			name:    "list types",
+			query:   func(n int) string { return "query ($v: " + nested("[", "Int", "]", n) + ") { f }" },
+			maxNest: maxDepth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.query(tt.maxNest))
+			assert.NoError(t, err)
+
+			_, err = parse(tt.query(tt.maxNest + 1))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "document is nested too deeply, at most 32 levels are supported")
+			}
+
+			// Deeply nested documents fail at the limit, without parsing
+			// the rest of them.
+			_, err = parse(tt.query(1000000))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "document is nested too deeply")
+			}
+		})
+	}
+}
+
+func TestExecuteDeeplyNested(t *testing.T) {
+	req := Request{Query: strings.Repeat("{ books ", 100) + strings.Repeat("}", 100)}
+	got, err := json.Marshal(Execute(context.Background(), testQuery{}, req))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":null,"errors":[{"message":"syntax error at 1:257: document is nested too deeply, at most 32 levels are supported"}]}`, string(got))
+}
@@ -0,0 +1,580 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed query document, only operations are supported.
+type document struct {
+	operations []*operation
+}
+
+type operation struct {
+	kind      string
+	name      string
+	variables []variableDefinition
+	selection []*field
+}
+
+type variableDefinition struct {
+	name         string
+	nonNull      bool
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+type field struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	directives []directive
+	selection  []*field
+}
+
+// responseKey is the key of the field in the result, its alias if any.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type directive struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// variable is a reference to a variable in a value, resolved on execution.
+type variable string
+
+// enumValue is an enum value in a value, resolved to its name.
+type enumValue string
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// maxDepth is the maximum nesting of selection sets, list and object values
+// and list types, bounding the recursion of the parser.
+const maxDepth = 32
+
+type parser struct {
+	src   string
+	pos   int
+	tok   token
+	depth int
+}
+
+// parse parses a query document.
+func parse(src string) (*document, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{}
+	for p.tok.kind != tokenEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+	if len(doc.operations) == 0 {
+		return nil, p.errorf("expected an operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return fmt.Errorf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// enter enters a nested selection set, value or type, failing when it's
+// nested too deeply. leave must be called once it's parsed.
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return p.errorf("document is nested too deeply, at most %d levels are supported", maxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) next() error {
+	// Whitespace, commas and comments are ignored.
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.pos += len("\ufeff")
+		default:
+			return p.lex()
+		}
+	}
+	p.tok = token{kind: tokenEOF, pos: p.pos}
+	return nil
+}
+
+func (p *parser) lex() error {
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunctuator, value: "...", pos: start}
+	case strings.ContainsRune("!$():=@[]{}|", rune(c)):
+		p.pos++
+		p.tok = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		kind := tokenInt
+		p.pos++
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+				kind = tokenFloat
+			} else if !isDigit(c) {
+				break
+			}
+			p.pos++
+		}
+		p.tok = token{kind: kind, value: p.src[start:p.pos], pos: start}
+	case c == '"':
+		s, err := p.lexString()
+		if err != nil {
+			return err
+		}
+		p.tok = token{kind: tokenString, value: s, pos: start}
+	default:
+		p.tok = token{pos: start}
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return p.errorf("unexpected character %q", r)
+	}
+	return nil
+}
+
+func (p *parser) lexString() (string, error) {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.tok = token{pos: start}
+			return "", p.errorf("unterminated string")
+		}
+		s := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += 3 + end + 3
+		return strings.TrimSpace(s), nil
+	}
+
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\n':
+			p.tok = token{pos: start}
+			return "", p.errorf("unterminated string")
+		case '\\':
+			if p.pos+1 >= len(p.src) {
+				p.tok = token{pos: start}
+				return "", p.errorf("unterminated string")
+			}
+			esc := p.src[p.pos+1]
+			p.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					p.tok = token{pos: start}
+					return "", p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.tok = token{pos: start}
+					return "", p.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				p.tok = token{pos: start}
+				return "", p.errorf("invalid escape \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.tok = token{pos: start}
+	return "", p.errorf("unterminated string")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) peek(value string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == value
+}
+
+func (p *parser) expect(value string) error {
+	if !p.peek(value) {
+		return p.errorf("expected %q, found %s", value, p.describe())
+	}
+	return p.next()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected a name, found %s", p.describe())
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokenEOF {
+		return "end of document"
+	}
+	return fmt.Sprintf("%q", p.tok.value)
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: "query"}
+	if p.peek("{") {
+		sel, err := p.parseSelectionSet()
+		op.selection = sel
+		return op, err
+	}
+
+	if p.tok.kind != tokenName {
+		return nil, p.errorf("expected an operation, found %s", p.describe())
+	}
+	switch p.tok.value {
+	case "query", "mutation", "subscription":
+		op.kind = p.tok.value
+	case "fragment":
+		return nil, p.errorf("fragments aren't supported")
+	default:
+		return nil, p.errorf("expected an operation, found %s", p.describe())
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokenName {
+		op.name = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		defs, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.variables = defs
+	}
+
+	if p.peek("@") {
+		return nil, p.errorf("operation directives aren't supported")
+	}
+
+	sel, err := p.parseSelectionSet()
+	op.selection = sel
+	return op, err
+}
+
+func (p *parser) parseVariableDefinitions() ([]variableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []variableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		nonNull, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		def := variableDefinition{name: name, nonNull: nonNull}
+		if p.peek("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+			def.defaultValue, def.hasDefault = v, true
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.next()
+}
+
+// parseType parses a type reference, returning whether it's non null. Types
+// are otherwise checked by the fields the variables are passed to.
+func (p *parser) parseType() (bool, error) {
+	if p.peek("[") {
+		if err := p.enter(); err != nil {
+			return false, err
+		}
+		defer p.leave()
+		if err := p.next(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return false, err
+	}
+
+	if p.peek("!") {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*field, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*field
+	for !p.peek("}") {
+		if p.tok.kind == tokenEOF {
+			return nil, p.errorf("expected %q, found end of document", "}")
+		}
+		if p.peek("...") {
+			return nil, p.errorf("fragments aren't supported")
+		}
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("selection sets must select a field")
+	}
+	return fields, p.next()
+}
+
+func (p *parser) parseField() (*field, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	f := &field{name: name}
+	if p.peek(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		f.alias = name
+		if f.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if f.arguments, err = p.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.peek("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		d := directive{}
+		if d.name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if p.peek("(") {
+			if d.arguments, err = p.parseArguments(); err != nil {
+				return nil, err
+			}
+		}
+		f.directives = append(f.directives, d)
+	}
+
+	if p.peek("{") {
+		if f.selection, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{}
+	for !p.peek(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.next()
+}
+
+// parseValue parses a value, constant ones can't reference variables.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		return variable(name), err
+	case p.peek("["):
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case p.peek("{"):
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.peek("}") {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	case tok.kind == tokenInt:
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			return nil, p.errorf("invalid int %q", tok.value)
+		}
+		return n, p.next()
+	case tok.kind == tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %q", tok.value)
+		}
+		return f, p.next()
+	case tok.kind == tokenString:
+		return tok.value, p.next()
+	case tok.kind == tokenName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.next()
+	}
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
@@ -0,0 +1,57 @@
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+const (
+	cloudWatchPeriod   = 60
+	cloudWatchLookback = 5 * time.Minute
+)
+
+// CloudWatchEvaluator evaluates CloudWatch metric math or Metrics Insights
+// expressions, e.g. SELECT SUM(HTTPCode_Target_5XX_Count) FROM "AWS/ApplicationELB".
+type CloudWatchEvaluator struct {
+	svc cloudwatchiface.CloudWatchAPI
+	now func() time.Time
+}
+
+// NewCloudWatchEvaluator creates a CloudWatchEvaluator.
+func NewCloudWatchEvaluator(svc cloudwatchiface.CloudWatchAPI) CloudWatchEvaluator {
+	return CloudWatchEvaluator{svc: svc, now: time.Now}
+}
+
+// Evaluate returns the latest value of each resulting series over the last
+// few minutes. Series without data are ignored.
+func (c CloudWatchEvaluator) Evaluate(ctx context.Context, query string) ([]float64, error) {
+	end := c.now()
+	out, err := c.svc.GetMetricDataWithContext(ctx, &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(end.Add(-cloudWatchLookback)),
+		EndTime:   aws.Time(end),
+		ScanBy:    aws.String(cloudwatch.ScanByTimestampDescending),
+		MetricDataQueries: []*cloudwatch.MetricDataQuery{
+			{
+				Id:         aws.String("guardrail"),
+				Expression: aws.String(query),
+				Period:     aws.Int64(cloudWatchPeriod),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloudwatch query failed: %w", err)
+	}
+
+	values := []float64{}
+	for _, result := range out.MetricDataResults {
+		if len(result.Values) > 0 {
+			values = append(values, aws.Float64Value(result.Values[0]))
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,165 @@
+// Package guardrail terminates workflows when a metrics query evaluated while
+// (and once after) they run breaches a threshold.
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Rule operators.
+const (
+	OperatorAbove = "above"
+	OperatorBelow = "below"
+)
+
+// Evaluator evaluates a metrics query, returning the value of each resulting
+// series.
+type Evaluator interface {
+	Evaluate(ctx context.Context, query string) ([]float64, error)
+}
+
+// Rule is a metrics query whose values must not be above or below
+// (Operator) the threshold.
+type Rule struct {
+	Provider  string
+	Query     string
+	Threshold float64
+	Operator  string
+}
+
+// breached returns the first value breaching the rule.
+func (r Rule) breached(values []float64) (float64, bool) {
+	for _, v := range values {
+		if (r.Operator == OperatorAbove && v > r.Threshold) || (r.Operator == OperatorBelow && v < r.Threshold) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Breach describes a rule breach. When the workflow had already completed,
+// it wasn't terminated.
+type Breach struct {
+	Rule            Rule
+	Value           float64
+	AfterCompletion bool
+}
+
+func (b Breach) String() string {
+	return fmt.Sprintf("query '%s' value %g is %s threshold %g", b.Rule.Query, b.Value, b.Rule.Operator, b.Rule.Threshold)
+}
+
+// Monitor watches workflows, evaluating their rule with the Evaluator
+// registered for the rule provider.
+type Monitor struct {
+	argo     workflow.Workflow
+	interval time.Duration
+	logger   log.Logger
+
+	mu         sync.RWMutex
+	evaluators map[string]Evaluator
+}
+
+// NewMonitor creates a Monitor with no registered evaluators, evaluating
+// rules every interval.
+func NewMonitor(argo workflow.Workflow, interval time.Duration, logger log.Logger) *Monitor {
+	return &Monitor{
+		argo:       argo,
+		interval:   interval,
+		logger:     logger,
+		evaluators: map[string]Evaluator{},
+	}
+}
+
+// Register adds the evaluator of a provider (e.g. 'prometheus'), replacing
+// any evaluator with the same name.
+func (m *Monitor) Register(provider string, e Evaluator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evaluators[provider] = e
+}
+
+// Providers returns the sorted names of the registered providers. A nil
+// Monitor has none.
+func (m *Monitor) Providers() []string {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	providers := make([]string, 0, len(m.evaluators))
+	for name := range m.evaluators {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// Watch evaluates the rule every interval until the workflow completes or
+// ctx is done. When the rule is breached, the workflow is terminated if it's
+// still active and onBreach is called. The rule is evaluated one last time
+// once the workflow completes.
+func (m *Monitor) Watch(ctx context.Context, workflowName string, rule Rule, onBreach func(Breach)) {
+	l := log.With(m.logger, "workflow", workflowName, "provider", rule.Provider)
+
+	m.mu.RLock()
+	evaluator, ok := m.evaluators[rule.Provider]
+	m.mu.RUnlock()
+	if !ok {
+		level.Error(l).Log("message", "unknown guardrail provider")
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, err := m.argo.Status(ctx, workflowName)
+		if err != nil {
+			level.Warn(l).Log("message", "error getting workflow status", "error", err)
+			continue
+		}
+		active := workflow.IsActive(status.Status)
+
+		values, err := evaluator.Evaluate(ctx, rule.Query)
+		if err != nil {
+			level.Warn(l).Log("message", "error evaluating guardrail query", "error", err)
+			if !active {
+				return
+			}
+			continue
+		}
+
+		if v, breached := rule.breached(values); breached {
+			breach := Breach{Rule: rule, Value: v, AfterCompletion: !active}
+			level.Warn(l).Log("message", "guardrail breached", "breach", breach)
+			if active {
+				if err := m.argo.Terminate(ctx, workflowName); err != nil {
+					level.Error(l).Log("message", "error terminating workflow", "error", err)
+				}
+			}
+			onBreach(breach)
+			return
+		}
+
+		if !active {
+			return
+		}
+	}
+}
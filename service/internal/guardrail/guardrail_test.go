@@ -0,0 +1,226 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/faketest"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type evaluatorFunc func(ctx context.Context, query string) ([]float64, error)
+
+func (f evaluatorFunc) Evaluate(ctx context.Context, query string) ([]float64, error) {
+	return f(ctx, query)
+}
+
+func TestRuleBreached(t *testing.T) {
+	above := Rule{Threshold: 10, Operator: OperatorAbove}
+	below := Rule{Threshold: 10, Operator: OperatorBelow}
+
+	_, breached := above.breached([]float64{1, 10})
+	assert.False(t, breached)
+
+	v, breached := above.breached([]float64{1, 11})
+	assert.True(t, breached)
+	assert.Equal(t, 11.0, v)
+
+	v, breached = below.breached([]float64{12, 9.5})
+	assert.True(t, breached)
+	assert.Equal(t, 9.5, v)
+
+	_, breached = below.breached(nil)
+	assert.False(t, breached)
+}
+
+func TestMonitorWatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       []float64
+		completeAt   int // Evaluation after which the workflow completes.
+		wantBreach   bool
+		wantAfter    bool
+		wantStatus   string
+		wantEvaluate int
+	}{
+		{
+			name:         "breached while running",
+			values:       []float64{0, 0, 5},
+			completeAt:   10,
+			wantBreach:   true,
+			wantStatus:   "failed",
+			wantEvaluate: 3,
+		},
+		{
+			name:         "breached after completion",
+			values:       []float64{0, 5},
+			completeAt:   1,
+			wantBreach:   true,
+			wantAfter:    true,
+			wantStatus:   "succeeded",
+			wantEvaluate: 2,
+		},
+		{
+			name:         "not breached",
+			values:       []float64{0, 0, 0},
+			completeAt:   2,
+			wantStatus:   "succeeded",
+			wantEvaluate: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argo := faketest.NewArgo()
+			name, err := argo.Submit(context.Background(), "workflowtemplate/test", map[string]string{"project_name": "project1", "target_name": "target1"}, nil, nil, workflow.Scheduling{})
+			assert.Nil(t, err)
+			assert.Nil(t, argo.SetStatus(name, "running"))
+
+			evaluations := 0
+			m := NewMonitor(argo, time.Millisecond, log.NewNopLogger())
+			m.Register("test", evaluatorFunc(func(ctx context.Context, query string) ([]float64, error) {
+				v := tt.values[evaluations]
+				evaluations++
+				if evaluations == tt.completeAt {
+					assert.Nil(t, argo.SetStatus(name, "succeeded"))
+				}
+				return []float64{v}, nil
+			}))
+
+			var got *Breach
+			m.Watch(context.Background(), name, Rule{Provider: "test", Query: "errors", Threshold: 1, Operator: OperatorAbove}, func(b Breach) {
+				got = &b
+			})
+
+			assert.Equal(t, tt.wantEvaluate, evaluations)
+			if tt.wantBreach {
+				if assert.NotNil(t, got) {
+					assert.Equal(t, tt.wantAfter, got.AfterCompletion)
+					assert.Equal(t, "query 'errors' value 5 is above threshold 1", got.String())
+				}
+			} else {
+				assert.Nil(t, got)
+			}
+
+			wf, _ := argo.Workflow(name)
+			assert.Equal(t, tt.wantStatus, wf.Status.Status)
+		})
+	}
+}
+
+func TestMonitorWatchUnknownProvider(t *testing.T) {
+	m := NewMonitor(faketest.NewArgo(), time.Millisecond, log.NewNopLogger())
+	m.Watch(context.Background(), "workflow", Rule{Provider: "unknown"}, func(b Breach) {
+		t.Errorf("unexpected breach %v", b)
+	})
+}
+
+func TestMonitorProviders(t *testing.T) {
+	var nilMonitor *Monitor
+	assert.Empty(t, nilMonitor.Providers())
+
+	m := NewMonitor(faketest.NewArgo(), time.Second, log.NewNopLogger())
+	m.Register("prometheus", evaluatorFunc(nil))
+	m.Register("cloudwatch", evaluatorFunc(nil))
+	assert.Equal(t, []string{"cloudwatch", "prometheus"}, m.Providers())
+}
+
+func TestPrometheusEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantValues []float64
+		wantErr    bool
+	}{
+		{
+			name:       "vector",
+			response:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"code":"500"},"value":[1435781451.781,"1.5"]},{"metric":{"code":"503"},"value":[1435781451.781,"3"]}]}}`,
+			wantValues: []float64{1.5, 3},
+		},
+		{
+			name:       "scalar",
+			response:   `{"status":"success","data":{"resultType":"scalar","result":[1435781451.781,"2"]}}`,
+			wantValues: []float64{2},
+		},
+		{
+			name:       "empty vector",
+			response:   `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			wantValues: []float64{},
+		},
+		{
+			name:     "error",
+			response: `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported result type",
+			response: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/query", r.URL.Path)
+				assert.Equal(t, `sum(rate(errors[5m]))`, r.URL.Query().Get("query"))
+				fmt.Fprint(w, tt.response)
+			}))
+			defer srv.Close()
+
+			values, err := NewPrometheusEvaluator(srv.URL, srv.Client()).Evaluate(context.Background(), `sum(rate(errors[5m]))`)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantValues, values)
+		})
+	}
+}
+
+type mockCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	input  *cloudwatch.GetMetricDataInput
+	output *cloudwatch.GetMetricDataOutput
+	err    error
+}
+
+func (m *mockCloudWatch) GetMetricDataWithContext(ctx aws.Context, in *cloudwatch.GetMetricDataInput, opts ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	m.input = in
+	return m.output, m.err
+}
+
+func TestCloudWatchEvaluate(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := &mockCloudWatch{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{
+			{Values: aws.Float64Slice([]float64{4, 2})},
+			{Values: []*float64{}},
+		},
+	}}
+	e := NewCloudWatchEvaluator(svc)
+	e.now = func() time.Time { return now }
+
+	values, err := e.Evaluate(context.Background(), "SUM(METRICS())")
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{4}, values)
+	assert.Equal(t, "SUM(METRICS())", aws.StringValue(svc.input.MetricDataQueries[0].Expression))
+	assert.Equal(t, now.Add(-cloudWatchLookback), aws.TimeValue(svc.input.StartTime))
+
+	svc.err = errors.New("throttled")
+	_, err = e.Evaluate(context.Background(), "SUM(METRICS())")
+	assert.EqualError(t, err, "cloudwatch query failed: throttled")
+}
@@ -0,0 +1,98 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PrometheusEvaluator evaluates instant queries using the Prometheus HTTP API.
+type PrometheusEvaluator struct {
+	address string
+	cl      *http.Client
+}
+
+// NewPrometheusEvaluator creates a PrometheusEvaluator for the Prometheus
+// server at address (e.g. http://prometheus:9090).
+func NewPrometheusEvaluator(address string, cl *http.Client) PrometheusEvaluator {
+	return PrometheusEvaluator{address: address, cl: cl}
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Evaluate returns the value of each series of a vector result, or the value
+// of a scalar result.
+func (p PrometheusEvaluator) Evaluate(ctx context.Context, query string) ([]float64, error) {
+	u := fmt.Sprintf("%s/api/v1/query?query=%s", p.address, url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create prometheus request: %w", err)
+	}
+
+	resp, err := p.cl.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("received error connecting to prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var pr prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("unable to decode prometheus response with code %d: %w", resp.StatusCode, err)
+	}
+
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s", pr.Error)
+	}
+
+	switch pr.Data.ResultType {
+	case "vector":
+		var series []struct {
+			Value [2]interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(pr.Data.Result, &series); err != nil {
+			return nil, fmt.Errorf("unable to decode prometheus vector: %w", err)
+		}
+
+		values := make([]float64, 0, len(series))
+		for _, s := range series {
+			v, err := parseSampleValue(s.Value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case "scalar":
+		var sample [2]interface{}
+		if err := json.Unmarshal(pr.Data.Result, &sample); err != nil {
+			return nil, fmt.Errorf("unable to decode prometheus scalar: %w", err)
+		}
+
+		v, err := parseSampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{v}, nil
+	default:
+		return nil, fmt.Errorf("unsupported prometheus result type '%s'", pr.Data.ResultType)
+	}
+}
+
+// parseSampleValue parses a [<timestamp>, "<value>"] sample.
+func parseSampleValue(sample [2]interface{}) (float64, error) {
+	s, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid prometheus sample value '%v'", sample[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
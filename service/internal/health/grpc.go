@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// OverallService is the service name used by the gRPC health checking
+// protocol for the status of the server as a whole.
+const OverallService = ""
+
+// GRPCServer implements the gRPC health checking protocol (grpc.health.v1)
+// with a status per dependency, kept in sync with the Checker results.
+type GRPCServer struct {
+	checker *Checker
+	logger  log.Logger
+	srv     *grpchealth.Server
+}
+
+// NewGRPCServer creates a GRPCServer. Every service starts as NOT_SERVING
+// until the first Update.
+func NewGRPCServer(checker *Checker, logger log.Logger) *GRPCServer {
+	s := &GRPCServer{
+		checker: checker,
+		logger:  logger,
+		srv:     grpchealth.NewServer(),
+	}
+
+	s.srv.SetServingStatus(OverallService, healthpb.HealthCheckResponse_NOT_SERVING)
+	for _, name := range checker.Names() {
+		s.srv.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return s
+}
+
+// Register registers the health service with a gRPC server.
+func (s *GRPCServer) Register(g *grpc.Server) {
+	healthpb.RegisterHealthServer(g, s.srv)
+}
+
+// Server returns the underlying health server.
+func (s *GRPCServer) Server() healthpb.HealthServer {
+	return s.srv
+}
+
+// Update runs the dependency checks and sets the serving status of each
+// dependency. The overall status is SERVING only when every dependency is.
+func (s *GRPCServer) Update(ctx context.Context) {
+	results := s.checker.Run(ctx)
+	for name, err := range results {
+		if err != nil {
+			level.Warn(s.logger).Log("message", "dependency health check failed", "dependency", name, "error", err)
+			s.srv.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+			continue
+		}
+		s.srv.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	if !Healthy(results) {
+		overall = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	s.srv.SetServingStatus(OverallService, overall)
+}
+
+// Watch calls Update every interval until ctx is done, then marks every
+// service NOT_SERVING so clients drain before shutdown.
+func (s *GRPCServer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.Update(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			s.srv.Shutdown()
+			return
+		case <-ticker.C:
+			s.Update(ctx)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package health provides the dependency checks shared by the HTTP readiness
+// endpoint and the gRPC health checking protocol.
+package health
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Check determines if a dependency is healthy. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+// Checker runs a set of named dependency checks.
+type Checker struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewChecker creates a Checker with no registered checks.
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}}
+}
+
+// Register adds a named check, replacing any check with the same name.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Names returns the sorted names of the registered checks.
+func (c *Checker) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes every registered check and returns the result of each keyed by
+// name. A nil value means the dependency is healthy.
+func (c *Checker) Run(ctx context.Context) map[string]error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := make(map[string]error, len(c.checks))
+	for name, check := range c.checks {
+		results[name] = check(ctx)
+	}
+	return results
+}
+
+// Healthy reports whether all results are healthy.
+func Healthy(results map[string]error) bool {
+	for _, err := range results {
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// NewVaultCheck returns a Check that succeeds when Vault reports it is
+// initialized and unsealed, either active (200) or standby (429).
+func NewVaultCheck(vaultAddress string, cl *http.Client) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/sys/health", vaultAddress), nil)
+		if err != nil {
+			return fmt.Errorf("unable to create vault health request: %w", err)
+		}
+
+		resp, err := cl.Do(req)
+		if err != nil {
+			return fmt.Errorf("received error connecting to vault: %w", err)
+		}
+
+		// We don't care about the body but need to read it all and close it
+		// regardless.
+		// https://golang.org/pkg/net/http/#Client.Do
+		defer resp.Body.Close()
+		_, _ = ioutil.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("received code %d which is not 200 (initialized, unsealed, and active) or 429 (unsealed and standby) when connecting to vault", resp.StatusCode)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCheckerRun(t *testing.T) {
+	c := NewChecker()
+	c.Register("good", func(ctx context.Context) error { return nil })
+	c.Register("bad", func(ctx context.Context) error { return errors.New("down") })
+
+	results := c.Run(context.Background())
+
+	assert.Equal(t, []string{"bad", "good"}, c.Names())
+	assert.Nil(t, results["good"])
+	assert.EqualError(t, results["bad"], "down")
+	assert.False(t, Healthy(results))
+}
+
+func TestVaultCheck(t *testing.T) {
+	tests := []struct {
+		name            string
+		endpoint        string // Used to cause a connection error.
+		vaultStatusCode int
+		wantErr         bool
+	}{
+		{
+			name:            "active",
+			vaultStatusCode: http.StatusOK,
+		},
+		{
+			name:            "standby",
+			vaultStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:            "sealed",
+			vaultStatusCode: http.StatusServiceUnavailable,
+			wantErr:         true,
+		},
+		{
+			name:     "connection error",
+			endpoint: string('\f'),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vaultSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/sys/health", r.URL.Path)
+				w.WriteHeader(tt.vaultStatusCode)
+			}))
+			defer vaultSvc.Close()
+
+			endpoint := vaultSvc.URL
+			if tt.endpoint != "" {
+				endpoint = tt.endpoint
+			}
+
+			err := NewVaultCheck(endpoint, vaultSvc.Client())(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestGRPCServerUpdate(t *testing.T) {
+	healthy := true
+	c := NewChecker()
+	c.Register("vault", func(ctx context.Context) error { return nil })
+	c.Register("argo", func(ctx context.Context) error {
+		if healthy {
+			return nil
+		}
+		return errors.New("down")
+	})
+
+	s := NewGRPCServer(c, log.NewNopLogger())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, s, OverallService))
+
+	s.Update(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, s, OverallService))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, s, "argo"))
+
+	healthy = false
+	s.Update(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, s, OverallService))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, s, "argo"))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, s, "vault"))
+}
+
+func status(t *testing.T, s *GRPCServer, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := s.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("unexpected error checking service '%s': %v", service, err)
+	}
+	return resp.Status
+}
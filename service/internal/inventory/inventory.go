@@ -0,0 +1,78 @@
+// Package inventory renders the Ansible inventory of targets for workflows of
+// the ansible framework.
+package inventory
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Files the setup script of the ansible image writes the host credentials of
+// the target to, referenced by the inventory.
+const (
+	PrivateKeyFile = "/tmp/cello-host-key"
+	PasswordFile   = "/tmp/cello-host-password"
+)
+
+// Inventory is the inventory of a target, without credentials.
+type Inventory struct {
+	// Connection is ssh or winrm.
+	Connection string
+	User       string
+	// Port is the default of the connection when 0.
+	Port   int
+	Hosts  []string
+	Groups map[string][]string
+	Vars   map[string]string
+}
+
+type group struct {
+	Hosts    map[string]struct{} `yaml:"hosts,omitempty"`
+	Vars     yaml.MapSlice       `yaml:"vars,omitempty"`
+	Children map[string]group    `yaml:"children,omitempty"`
+}
+
+// Render returns the inventory as YAML, the connection variables of all
+// hosts pointing to the credential files.
+func (i Inventory) Render() ([]byte, error) {
+	vars := yaml.MapSlice{
+		{Key: "ansible_connection", Value: i.Connection},
+		{Key: "ansible_user", Value: i.User},
+	}
+	if i.Port != 0 {
+		vars = append(vars, yaml.MapItem{Key: "ansible_port", Value: i.Port})
+	}
+	switch i.Connection {
+	case "ssh":
+		vars = append(vars, yaml.MapItem{Key: "ansible_ssh_private_key_file", Value: PrivateKeyFile})
+	case "winrm":
+		vars = append(vars, yaml.MapItem{Key: "ansible_password", Value: "{{ lookup('file', '" + PasswordFile + "') }}"})
+	}
+
+	keys := make([]string, 0, len(i.Vars))
+	for k := range i.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vars = append(vars, yaml.MapItem{Key: k, Value: i.Vars[k]})
+	}
+
+	all := group{Hosts: hostSet(i.Hosts), Vars: vars}
+	if len(i.Groups) > 0 {
+		all.Children = map[string]group{}
+		for name, hosts := range i.Groups {
+			all.Children[name] = group{Hosts: hostSet(hosts)}
+		}
+	}
+	return yaml.Marshal(map[string]group{"all": all})
+}
+
+func hostSet(hosts []string) map[string]struct{} {
+	s := make(map[string]struct{}, len(hosts))
+	for _, h := range hosts {
+		s[h] = struct{}{}
+	}
+	return s
+}
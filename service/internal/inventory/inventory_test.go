@@ -0,0 +1,66 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		inv  Inventory
+		want string
+	}{
+		{
+			name: "ssh",
+			inv: Inventory{
+				Connection: "ssh",
+				User:       "deploy",
+				Hosts:      []string{"web2.example.com", "web1.example.com"},
+				Groups:     map[string][]string{"web": {"web1.example.com"}},
+				Vars:       map[string]string{"app_env": "prod"},
+			},
+			want: `all:
+  hosts:
+    web1.example.com: {}
+    web2.example.com: {}
+  vars:
+    ansible_connection: ssh
+    ansible_user: deploy
+    ansible_ssh_private_key_file: /tmp/cello-host-key
+    app_env: prod
+  children:
+    web:
+      hosts:
+        web1.example.com: {}
+`,
+		},
+		{
+			name: "winrm",
+			inv: Inventory{
+				Connection: "winrm",
+				User:       "deploy",
+				Port:       5986,
+				Hosts:      []string{"win1.example.com"},
+			},
+			want: `all:
+  hosts:
+    win1.example.com: {}
+  vars:
+    ansible_connection: winrm
+    ansible_user: deploy
+    ansible_port: 5986
+    ansible_password: '{{ lookup(''file'', ''/tmp/cello-host-password'') }}'
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.inv.Render()
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
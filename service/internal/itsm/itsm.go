@@ -0,0 +1,122 @@
+// Package itsm creates and verifies change tickets in IT service management
+// systems for submissions to change controlled targets.
+package itsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a change doesn't exist.
+var ErrNotFound = errors.New("change not found")
+
+// Change states.
+const (
+	StatePending  = "pending"
+	StateApproved = "approved"
+	StateRejected = "rejected"
+)
+
+// ChangeRequest describes the submission a change is created for.
+type ChangeRequest struct {
+	Project    string
+	Target     string
+	Type       string
+	CommitHash string
+	Summary    string
+	TxID       string
+	// Details are appended to the description, e.g. the summary of the
+	// change set a sync executes.
+	Details string
+}
+
+// description returns the description of the change, listing the submission.
+func (req ChangeRequest) description() string {
+	d := fmt.Sprintf("project: %s\ntarget: %s\ntype: %s\ncommit: %s\ntransaction: %s", req.Project, req.Target, req.Type, req.CommitHash, req.TxID)
+	if req.Details != "" {
+		d += "\n\n" + req.Details
+	}
+	return d
+}
+
+// Change is a change ticket.
+type Change struct {
+	ID    string
+	State string
+}
+
+// Client creates and gets changes.
+type Client interface {
+	CreateChange(ctx context.Context, req ChangeRequest) (Change, error)
+	GetChange(ctx context.Context, id string) (Change, error)
+	// Approvers returns the principals (user names) who approved a change.
+	Approvers(ctx context.Context, id string) ([]string, error)
+}
+
+// WaitForApproval polls a change every interval until it's no longer pending
+// or ctx is done, returning the last known change.
+func WaitForApproval(ctx context.Context, cl Client, change Change, interval time.Duration) (Change, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for change.State == StatePending {
+		select {
+		case <-ctx.Done():
+			return change, ctx.Err()
+		case <-ticker.C:
+		}
+
+		c, err := cl.GetChange(ctx, change.ID)
+		if err != nil {
+			return change, err
+		}
+		change = c
+	}
+	return change, nil
+}
+
+// WaitForApprovers polls the approvers of a change every interval until n
+// distinct principals other than excluded (e.g. the submitter) approved it or
+// ctx is done, returning the last known of them. Principals are compared case
+// insensitively.
+func WaitForApprovers(ctx context.Context, cl Client, id string, n int, excluded string, interval time.Duration) ([]string, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var approvers []string
+	for {
+		a, err := cl.Approvers(ctx, id)
+		if err != nil {
+			return approvers, err
+		}
+		approvers = distinctApprovers(a, excluded)
+		if len(approvers) >= n {
+			return approvers, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return approvers, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// distinctApprovers returns the approvers other than excluded, without
+// duplicates.
+func distinctApprovers(approvers []string, excluded string) []string {
+	seen := map[string]bool{strings.ToLower(excluded): true}
+	var out []string
+	for _, a := range approvers {
+		k := strings.ToLower(a)
+		if a == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package itsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sequenceClient struct {
+	states    []string
+	approvers [][]string
+	calls     int
+}
+
+func (s *sequenceClient) CreateChange(ctx context.Context, req ChangeRequest) (Change, error) {
+	return Change{}, nil
+}
+
+func (s *sequenceClient) GetChange(ctx context.Context, id string) (Change, error) {
+	state := s.states[s.calls]
+	s.calls++
+	return Change{ID: id, State: state}, nil
+}
+
+func (s *sequenceClient) Approvers(ctx context.Context, id string) ([]string, error) {
+	approvers := s.approvers[s.calls]
+	s.calls++
+	return approvers, nil
+}
+
+func TestWaitForApproval(t *testing.T) {
+	cl := &sequenceClient{states: []string{StatePending, StateApproved}}
+	change, err := WaitForApproval(context.Background(), cl, Change{ID: "CHG1", State: StatePending}, time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, Change{ID: "CHG1", State: StateApproved}, change)
+	assert.Equal(t, 2, cl.calls)
+
+	cl = &sequenceClient{}
+	change, err = WaitForApproval(context.Background(), cl, Change{ID: "CHG1", State: StateRejected}, time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, StateRejected, change.State)
+	assert.Equal(t, 0, cl.calls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	cl = &sequenceClient{states: make([]string, 1000)}
+	for i := range cl.states {
+		cl.states[i] = StatePending
+	}
+	_, err = WaitForApproval(ctx, cl, Change{ID: "CHG1", State: StatePending}, time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForApprovers(t *testing.T) {
+	cl := &sequenceClient{approvers: [][]string{
+		{"alice"},
+		{"alice", "Submitter", "ALICE"},
+		{"alice", "submitter", "bob"},
+	}}
+	approvers, err := WaitForApprovers(context.Background(), cl, "CHG1", 2, "submitter", time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, approvers)
+	assert.Equal(t, 3, cl.calls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	cl = &sequenceClient{approvers: make([][]string, 1000)}
+	for i := range cl.approvers {
+		cl.approvers[i] = []string{"alice", "submitter"}
+	}
+	approvers, err = WaitForApprovers(ctx, cl, "CHG1", 2, "submitter", time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"alice"}, approvers)
+}
+
+func TestServiceNow(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "pass", pass)
+
+		if r.URL.Path == "/api/now/table/sysapproval_approver" {
+			assert.Equal(t, "sysapproval.number=CHG0030001^state=approved", r.URL.Query().Get("sysparm_query"))
+			fmt.Fprint(w, `{"result":[{"approver.user_name":"alice"},{"approver.user_name":"bob"}]}`)
+			return
+		}
+		assert.Equal(t, "/api/now/table/change_request", r.URL.Path)
+
+		switch r.Method {
+		case http.MethodPost:
+			var body map[string]string
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "deploy project1/target1", body["short_description"])
+			assert.Equal(t, "project: project1\ntarget: target1\ntype: \ncommit: \ntransaction: \n\nchange set cello-1 of stack web", body["description"])
+			fmt.Fprint(w, `{"result":{"number":"CHG0030001","approval":"requested"}}`)
+		case http.MethodGet:
+			switch r.URL.Query().Get("sysparm_query") {
+			case "number=CHG0030001":
+				fmt.Fprint(w, `{"result":[{"number":"CHG0030001","approval":"approved"}]}`)
+			case "number=CHG0030002":
+				fmt.Fprint(w, `{"result":[{"number":"CHG0030002","approval":"rejected"}]}`)
+			default:
+				fmt.Fprint(w, `{"result":[]}`)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	sn := NewServiceNow(srv.URL, "user", "pass", srv.Client())
+
+	change, err := sn.CreateChange(context.Background(), ChangeRequest{Project: "project1", Target: "target1", Summary: "deploy project1/target1", Details: "change set cello-1 of stack web"})
+	assert.Nil(t, err)
+	assert.Equal(t, Change{ID: "CHG0030001", State: StatePending}, change)
+
+	change, err = sn.GetChange(context.Background(), "CHG0030001")
+	assert.Nil(t, err)
+	assert.Equal(t, StateApproved, change.State)
+
+	change, err = sn.GetChange(context.Background(), "CHG0030002")
+	assert.Nil(t, err)
+	assert.Equal(t, StateRejected, change.State)
+
+	_, err = sn.GetChange(context.Background(), "CHG0039999")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	approvers, err := sn.Approvers(context.Background(), "CHG0030001")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, approvers)
+}
+
+func TestJira(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ := r.BasicAuth()
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "token", token)
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var body struct {
+				Fields struct {
+					Project   map[string]string `json:"project"`
+					IssueType map[string]string `json:"issuetype"`
+				} `json:"fields"`
+			}
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "OPS", body.Fields.Project["key"])
+			assert.Equal(t, "Change", body.Fields.IssueType["name"])
+			fmt.Fprint(w, `{"key":"OPS-12"}`)
+		case r.URL.Path == "/rest/api/2/issue/OPS-12":
+			fmt.Fprint(w, `{"key":"OPS-12","fields":{"status":{"name":"Approved"}}}`)
+		case r.URL.Path == "/rest/api/2/issue/OPS-13":
+			fmt.Fprint(w, `{"key":"OPS-13","fields":{"status":{"name":"In Review"}}}`)
+		case r.URL.Path == "/rest/servicedeskapi/request/OPS-12/approval":
+			fmt.Fprint(w, `{"values":[{"approvers":[
+				{"approver":{"name":"alice"},"approverDecision":"approved"},
+				{"approver":{"emailAddress":"bob@example.com"},"approverDecision":"approved"},
+				{"approver":{"accountId":"5b10a2844c20165700ede21g"},"approverDecision":"approved"},
+				{"approver":{"name":"carol"},"approverDecision":"pending"}
+			]}]}`)
+		case r.URL.Path == "/rest/api/2/issue/OPS-14":
+			fmt.Fprint(w, `{"key":"OPS-14","fields":{"status":{"name":"Declined"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	j := NewJira(srv.URL, "user", "token", "OPS", "Change", srv.Client())
+
+	change, err := j.CreateChange(context.Background(), ChangeRequest{Summary: "deploy"})
+	assert.Nil(t, err)
+	assert.Equal(t, Change{ID: "OPS-12", State: StatePending}, change)
+
+	for id, want := range map[string]string{"OPS-12": StateApproved, "OPS-13": StatePending, "OPS-14": StateRejected} {
+		change, err = j.GetChange(context.Background(), id)
+		assert.Nil(t, err)
+		assert.Equal(t, want, change.State, id)
+	}
+
+	_, err = j.GetChange(context.Background(), "OPS-99")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	approvers, err := j.Approvers(context.Background(), "OPS-12")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alice", "bob@example.com", "5b10a2844c20165700ede21g"}, approvers)
+
+	_, err = j.Approvers(context.Background(), "OPS-99")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
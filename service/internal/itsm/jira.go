@@ -0,0 +1,146 @@
+package itsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Jira is a Client creating changes as Jira issues. The issue status name
+// determines the change state, 'approved' and 'rejected' or 'declined'
+// (case insensitive), every other status is pending.
+type Jira struct {
+	address   string
+	user      string
+	token     string
+	project   string
+	issueType string
+	cl        *http.Client
+}
+
+// NewJira creates a Jira client for the instance at address, creating issues
+// of issueType (e.g. 'Change') in the project with the key.
+func NewJira(address, user, token, project, issueType string, cl *http.Client) Jira {
+	return Jira{address: address, user: user, token: token, project: project, issueType: issueType, cl: cl}
+}
+
+// CreateChange creates an issue.
+func (j Jira) CreateChange(ctx context.Context, req ChangeRequest) (Change, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     req.Summary,
+			"description": req.description(),
+		},
+	})
+	if err != nil {
+		return Change{}, err
+	}
+
+	var out struct {
+		Key string `json:"key"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue", bytes.NewReader(body), &out); err != nil {
+		return Change{}, err
+	}
+	return Change{ID: out.Key, State: StatePending}, nil
+}
+
+// GetChange gets an issue by key, e.g. OPS-123.
+func (j Jira) GetChange(ctx context.Context, id string) (Change, error) {
+	var out struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Status struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := j.do(ctx, http.MethodGet, fmt.Sprintf("/rest/api/2/issue/%s?fields=status", url.PathEscape(id)), nil, &out); err != nil {
+		return Change{}, err
+	}
+
+	state := StatePending
+	switch strings.ToLower(out.Fields.Status.Name) {
+	case "approved":
+		state = StateApproved
+	case "rejected", "declined":
+		state = StateRejected
+	}
+	return Change{ID: out.Key, State: state}, nil
+}
+
+// Approvers returns the approvers who approved the approvals of an issue,
+// which requires the project to be a Jira Service Management project. They're
+// named by user name, or email address or account ID when the instance
+// doesn't expose it.
+func (j Jira) Approvers(ctx context.Context, id string) ([]string, error) {
+	var out struct {
+		Values []struct {
+			Approvers []struct {
+				Approver struct {
+					Name         string `json:"name"`
+					EmailAddress string `json:"emailAddress"`
+					AccountID    string `json:"accountId"`
+				} `json:"approver"`
+				ApproverDecision string `json:"approverDecision"`
+			} `json:"approvers"`
+		} `json:"values"`
+	}
+	if err := j.do(ctx, http.MethodGet, fmt.Sprintf("/rest/servicedeskapi/request/%s/approval", url.PathEscape(id)), nil, &out); err != nil {
+		return nil, err
+	}
+
+	var approvers []string
+	for _, v := range out.Values {
+		for _, a := range v.Approvers {
+			if a.ApproverDecision != "approved" {
+				continue
+			}
+			switch {
+			case a.Approver.Name != "":
+				approvers = append(approvers, a.Approver.Name)
+			case a.Approver.EmailAddress != "":
+				approvers = append(approvers, a.Approver.EmailAddress)
+			default:
+				approvers = append(approvers, a.Approver.AccountID)
+			}
+		}
+	}
+	return approvers, nil
+}
+
+func (j Jira) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, j.address+path, body)
+	if err != nil {
+		return fmt.Errorf("unable to create jira request: %w", err)
+	}
+	req.SetBasicAuth(j.user, j.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("received error connecting to jira: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("received code %d from jira", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode jira response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package itsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ServiceNow is a Client using the ServiceNow table API for change requests.
+type ServiceNow struct {
+	address  string
+	user     string
+	password string
+	cl       *http.Client
+}
+
+// NewServiceNow creates a ServiceNow client for the instance at address (e.g.
+// https://example.service-now.com).
+func NewServiceNow(address, user, password string, cl *http.Client) ServiceNow {
+	return ServiceNow{address: address, user: user, password: password, cl: cl}
+}
+
+type serviceNowChange struct {
+	Number   string `json:"number"`
+	Approval string `json:"approval"`
+}
+
+// CreateChange creates a normal change request.
+func (s ServiceNow) CreateChange(ctx context.Context, req ChangeRequest) (Change, error) {
+	body, err := json.Marshal(map[string]string{
+		"short_description": req.Summary,
+		"description":       req.description(),
+		"type":              "normal",
+	})
+	if err != nil {
+		return Change{}, err
+	}
+
+	var out struct {
+		Result serviceNowChange `json:"result"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/api/now/table/change_request", bytes.NewReader(body), &out); err != nil {
+		return Change{}, err
+	}
+	return out.Result.change(), nil
+}
+
+// GetChange gets a change request by number, e.g. CHG0030001.
+func (s ServiceNow) GetChange(ctx context.Context, id string) (Change, error) {
+	q := url.Values{}
+	q.Set("sysparm_query", "number="+id)
+	q.Set("sysparm_fields", "number,approval")
+	q.Set("sysparm_limit", "1")
+
+	var out struct {
+		Result []serviceNowChange `json:"result"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/api/now/table/change_request?"+q.Encode(), nil, &out); err != nil {
+		return Change{}, err
+	}
+
+	if len(out.Result) == 0 {
+		return Change{}, ErrNotFound
+	}
+	return out.Result[0].change(), nil
+}
+
+// Approvers returns the user names of the approved approvals of a change
+// request.
+func (s ServiceNow) Approvers(ctx context.Context, id string) ([]string, error) {
+	q := url.Values{}
+	q.Set("sysparm_query", "sysapproval.number="+id+"^state=approved")
+	q.Set("sysparm_fields", "approver.user_name")
+
+	var out struct {
+		Result []struct {
+			UserName string `json:"approver.user_name"`
+		} `json:"result"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/api/now/table/sysapproval_approver?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+
+	approvers := make([]string, 0, len(out.Result))
+	for _, a := range out.Result {
+		approvers = append(approvers, a.UserName)
+	}
+	return approvers, nil
+}
+
+func (c serviceNowChange) change() Change {
+	state := StatePending
+	switch c.Approval {
+	case "approved":
+		state = StateApproved
+	case "rejected":
+		state = StateRejected
+	}
+	return Change{ID: c.Number, State: state}
+}
+
+func (s ServiceNow) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.address+path, body)
+	if err != nil {
+		return fmt.Errorf("unable to create servicenow request: %w", err)
+	}
+	req.SetBasicAuth(s.user, s.password)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("received error connecting to servicenow: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("received code %d from servicenow", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode servicenow response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package loadshed sheds low priority requests when the service is overloaded,
+// preserving capacity for high priority requests such as workflow submissions.
+package loadshed
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Weight given to each new latency sample in the moving average.
+	latencyAlpha = 0.2
+	// Latency samples older than this are ignored. Without it, shedding all
+	// low priority traffic while there are no high priority requests would
+	// never observe the recovered latency.
+	defaultLatencyWindow = 30 * time.Second
+	defaultRetryAfter    = 5 * time.Second
+)
+
+// Config configures a Shedder. Zero thresholds disable the related check.
+type Config struct {
+	// MaxInFlight is the number of in flight requests above which low
+	// priority requests are shed.
+	MaxInFlight int
+	// MaxLatency is the average request latency above which low priority
+	// requests are shed.
+	MaxLatency time.Duration
+	// RetryAfter is returned to shed clients in the Retry-After header.
+	RetryAfter time.Duration
+}
+
+// Shedder tracks in flight requests and their latency.
+type Shedder struct {
+	cfg      Config
+	inFlight int64
+	now      func() time.Time
+
+	mu           sync.Mutex
+	latency      float64 // exponential moving average in nanoseconds
+	lastObserved time.Time
+}
+
+// New creates a Shedder.
+func New(cfg Config) *Shedder {
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = defaultRetryAfter
+	}
+	return &Shedder{cfg: cfg, now: time.Now}
+}
+
+// InFlight returns the number of tracked requests in flight.
+func (s *Shedder) InFlight() int {
+	return int(atomic.LoadInt64(&s.inFlight))
+}
+
+// Latency returns the average latency of recent tracked requests.
+func (s *Shedder) Latency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.now().Sub(s.lastObserved) > defaultLatencyWindow {
+		return 0
+	}
+	return time.Duration(s.latency)
+}
+
+// Observe records the latency of a request or downstream call.
+func (s *Shedder) Observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.now().Sub(s.lastObserved) > defaultLatencyWindow {
+		s.latency = float64(d)
+	} else {
+		s.latency = latencyAlpha*float64(d) + (1-latencyAlpha)*s.latency
+	}
+	s.lastObserved = s.now()
+}
+
+// Overloaded determines if low priority requests should be shed.
+func (s *Shedder) Overloaded() bool {
+	if s.cfg.MaxInFlight > 0 && s.InFlight() >= s.cfg.MaxInFlight {
+		return true
+	}
+	if s.cfg.MaxLatency > 0 && s.Latency() > s.cfg.MaxLatency {
+		return true
+	}
+	return false
+}
+
+// Track counts the request as in flight and records its latency.
+func (s *Shedder) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		start := s.now()
+		defer func() {
+			s.Observe(s.now().Sub(start))
+			atomic.AddInt64(&s.inFlight, -1)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shed rejects the request with a 503 and Retry-After when overloaded.
+func (s *Shedder) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Overloaded() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(s.cfg.RetryAfter.Seconds()))))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		// TODO swallowing error since the message is static.
+		resp, _ := json.Marshal(struct {
+			ErrorMessage string `json:"error_message"`
+		}{"service overloaded, retry later"})
+		fmt.Fprint(w, string(resp))
+	})
+}
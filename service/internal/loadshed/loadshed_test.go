@@ -0,0 +1,65 @@
+package loadshed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShedInFlight(t *testing.T) {
+	s := New(Config{MaxInFlight: 1, RetryAfter: 2 * time.Second})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocking := s.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	low := s.Shed(s.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	go blocking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/workflows", nil))
+	<-started
+
+	resp := httptest.NewRecorder()
+	low.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/workflows/wf", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, "2", resp.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error_message":"service overloaded, retry later"}`, resp.Body.String())
+
+	close(release)
+	assert.Eventually(t, func() bool { return s.InFlight() == 0 }, time.Second, time.Millisecond)
+
+	resp = httptest.NewRecorder()
+	low.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/workflows/wf", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestShedLatency(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(Config{MaxLatency: time.Second})
+	s.now = func() time.Time { return now }
+
+	s.Observe(10 * time.Second)
+	assert.True(t, s.Overloaded())
+
+	// Fast samples bring the average back down.
+	for i := 0; i < 20; i++ {
+		s.Observe(time.Millisecond)
+	}
+	assert.False(t, s.Overloaded())
+
+	// Stale samples are ignored.
+	s.Observe(10 * time.Second)
+	assert.True(t, s.Overloaded())
+	now = now.Add(time.Minute)
+	assert.False(t, s.Overloaded())
+}
+
+func TestShedDisabled(t *testing.T) {
+	s := New(Config{})
+	s.Observe(time.Hour)
+	assert.False(t, s.Overloaded())
+}
@@ -0,0 +1,320 @@
+// Package logarchive archives the logs of completed workflows compressed,
+// with an index of their frames for reading lines from any offset without
+// decompressing the preceding ones.
+//
+// Archives are a sequence of frames, the lines of up to a frame size each
+// compressed independently, gzip members or zstd frames, so the whole archive
+// is a valid gzip or zstd stream too. The index records the first line and
+// compressed offset of every frame.
+package logarchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encodings of archives, named like their HTTP content coding.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Encodings are the supported encodings, the preferred one first.
+var Encodings = []string{Zstd, Gzip}
+
+// ValidEncoding returns whether the encoding is supported.
+func ValidEncoding(encoding string) bool {
+	for _, e := range Encodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// Index is the index of the frames of an archive.
+type Index struct {
+	Encoding string `json:"encoding"`
+	// Lines and Bytes are the number of lines and uncompressed bytes of the
+	// archive, Size its compressed size.
+	Lines  int     `json:"lines"`
+	Bytes  int64   `json:"bytes"`
+	Size   int64   `json:"size"`
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is a compressed frame of an archive.
+type Frame struct {
+	// Line is the offset of the first line of the frame in the logs.
+	Line int `json:"line"`
+	// Offset and Size are the position of the frame in the archive.
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+}
+
+// DataKey returns the key of the archive of the logs of the name.
+func DataKey(name, encoding string) string {
+	switch encoding {
+	case Gzip:
+		return name + ".log.gz"
+	default:
+		return name + ".log.zst"
+	}
+}
+
+// IndexKey returns the key of the index of the archive of the logs of the
+// name, stored once the archive is complete.
+func IndexKey(name string) string {
+	return name + ".index.json"
+}
+
+// Writer writes lines as the frames of an archive.
+type Writer struct {
+	w          io.Writer
+	frameBytes int
+	buf        bytes.Buffer
+	line       int
+	index      Index
+	gzip       *gzip.Writer
+	zstd       *zstd.Encoder
+}
+
+// NewWriter returns a Writer of an archive of the encoding to w, whose frames
+// have the lines of up to frameBytes uncompressed bytes, or a single line
+// when longer.
+func NewWriter(w io.Writer, encoding string, frameBytes int) (*Writer, error) {
+	wr := &Writer{w: w, frameBytes: frameBytes, index: Index{Encoding: encoding, Frames: []Frame{}}}
+	switch encoding {
+	case Gzip:
+		wr.gzip = gzip.NewWriter(nil)
+	case Zstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		wr.zstd = enc
+	default:
+		return nil, fmt.Errorf("unsupported encoding '%s'", encoding)
+	}
+	return wr, nil
+}
+
+// WriteLine writes the line, without its line feed.
+func (w *Writer) WriteLine(line string) error {
+	w.buf.WriteString(line)
+	w.buf.WriteByte('\n')
+	w.line++
+	w.index.Bytes += int64(len(line) + 1)
+	if w.buf.Len() < w.frameBytes {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the buffered lines as a frame.
+func (w *Writer) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	var frame bytes.Buffer
+	if w.gzip != nil {
+		w.gzip.Reset(&frame)
+		if _, err := w.gzip.Write(w.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := w.gzip.Close(); err != nil {
+			return err
+		}
+	} else {
+		frame.Write(w.zstd.EncodeAll(w.buf.Bytes(), nil))
+	}
+
+	if _, err := w.w.Write(frame.Bytes()); err != nil {
+		return err
+	}
+	w.index.Frames = append(w.index.Frames, Frame{
+		Line:   w.index.Lines,
+		Offset: w.index.Size,
+		Size:   int64(frame.Len()),
+	})
+	w.index.Lines = w.line
+	w.index.Size += int64(frame.Len())
+	w.buf.Reset()
+	return nil
+}
+
+// Close writes the buffered lines, returning the index of the archive.
+func (w *Writer) Close() (Index, error) {
+	if err := w.flush(); err != nil {
+		return Index{}, err
+	}
+	if w.zstd != nil {
+		w.zstd.Close()
+	}
+	return w.index, nil
+}
+
+// NewReader returns the uncompressed content of the archive, or of its frames
+// from the offset of one, of the encoding read from r.
+func NewReader(r io.Reader, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding '%s'", encoding)
+	}
+}
+
+// NewEncoder returns a writer compressing to w with the encoding, which must
+// be closed.
+func NewEncoder(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported encoding '%s'", encoding)
+	}
+}
+
+// Archive archives the lines walk calls its function with as the logs of the
+// name, uploaded as they're compressed, then stores the index of the archive.
+// The archive is only read once its index is stored.
+func Archive(ctx context.Context, s Store, name, encoding string, frameBytes int, walk func(fn func(line string) bool) error) (Index, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		err := s.Put(ctx, DataKey(name, encoding), pr)
+		pr.CloseWithError(err)
+		uploaded <- err
+	}()
+
+	idx, err := writeArchive(pw, encoding, frameBytes, walk)
+	pw.CloseWithError(err)
+	if uploadErr := <-uploaded; err == nil {
+		err = uploadErr
+	}
+	if err != nil {
+		return Index{}, err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return Index{}, err
+	}
+	if err := s.Put(ctx, IndexKey(name), bytes.NewReader(data)); err != nil {
+		return Index{}, err
+	}
+	return idx, nil
+}
+
+func writeArchive(w io.Writer, encoding string, frameBytes int, walk func(fn func(line string) bool) error) (Index, error) {
+	aw, err := NewWriter(w, encoding, frameBytes)
+	if err != nil {
+		return Index{}, err
+	}
+
+	var writeErr error
+	err = walk(func(line string) bool {
+		writeErr = aw.WriteLine(line)
+		return writeErr == nil
+	})
+	if writeErr != nil {
+		return Index{}, writeErr
+	}
+	if err != nil {
+		return Index{}, err
+	}
+	return aw.Close()
+}
+
+// ReadIndex returns the index of the archive of the logs of the name,
+// ErrNotFound when they aren't archived.
+func ReadIndex(ctx context.Context, s Store, name string) (Index, error) {
+	r, err := s.Get(ctx, IndexKey(name), 0, -1)
+	if err != nil {
+		return Index{}, err
+	}
+	defer r.Close()
+
+	var idx Index
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return Index{}, fmt.Errorf("error reading index of the archived logs of '%s': %w", name, err)
+	}
+	if !ValidEncoding(idx.Encoding) {
+		return Index{}, fmt.Errorf("unsupported encoding '%s' of the archived logs of '%s'", idx.Encoding, name)
+	}
+	return idx, nil
+}
+
+// ReadLines returns the archived lines of the logs of the name from the
+// offset, of up to maxBytes unless 0, with the offset of the following line
+// when there are more. Only the frames of the lines returned are read.
+func ReadLines(ctx context.Context, s Store, name string, idx Index, offset, maxBytes int) ([]string, int, error) {
+	lines := []string{}
+	if offset >= idx.Lines || len(idx.Frames) == 0 {
+		return lines, 0, nil
+	}
+
+	i := sort.Search(len(idx.Frames), func(i int) bool { return idx.Frames[i].Line > offset }) - 1
+	if i < 0 {
+		return nil, 0, errors.New("invalid index of archived logs")
+	}
+	frame := idx.Frames[i]
+
+	// Lines following the selected ones are never read from the store.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r, err := s.Get(ctx, DataKey(name, idx.Encoding), frame.Offset, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+	dr, err := NewReader(r, idx.Encoding)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer dr.Close()
+
+	br := bufio.NewReader(dr)
+	size := 0
+	for line := frame.Line; line < idx.Lines; line++ {
+		l, err := br.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && l != "") {
+			return nil, 0, fmt.Errorf("error reading archived logs of '%s': %w", name, err)
+		}
+		if line < offset {
+			continue
+		}
+
+		l = strings.TrimSuffix(l, "\n")
+		if maxBytes > 0 && len(lines) > 0 && size+len(l) > maxBytes {
+			return lines, line, nil
+		}
+		lines = append(lines, l)
+		size += len(l)
+	}
+	return lines, 0, nil
+}
@@ -0,0 +1,144 @@
+package logarchive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// offsetStore records the offsets objects are read from.
+type offsetStore struct {
+	Store
+	offsets []int64
+}
+
+func (s *offsetStore) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if !strings.HasSuffix(key, ".index.json") {
+		s.offsets = append(s.offsets, offset)
+	}
+	return s.Store.Get(ctx, key, offset, length)
+}
+
+func walkLines(lines []string) func(fn func(line string) bool) error {
+	return func(fn func(line string) bool) error {
+		for _, l := range lines {
+			if !fn(l) {
+				break
+			}
+		}
+		return nil
+	}
+}
+
+func TestArchive(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("pod-%d: line %03d", i/50, i))
+	}
+
+	for _, encoding := range Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			dir, err := NewDirStore(t.TempDir())
+			require.Nil(t, err)
+			s := &offsetStore{Store: dir}
+
+			// Lines are 16 bytes with their line feed, 7 per frame.
+			idx, err := Archive(context.Background(), s, "wf", encoding, 100, walkLines(lines))
+			require.Nil(t, err)
+			assert.Equal(t, encoding, idx.Encoding)
+			assert.Equal(t, 100, idx.Lines)
+			assert.Equal(t, int64(1600), idx.Bytes)
+			assert.Len(t, idx.Frames, 15)
+			assert.Equal(t, Frame{Line: 7, Offset: idx.Frames[0].Size, Size: idx.Frames[1].Size}, idx.Frames[1])
+
+			read, err := ReadIndex(context.Background(), s, "wf")
+			require.Nil(t, err)
+			assert.Equal(t, idx, read)
+
+			// The whole archive is a valid stream of the encoding.
+			r, err := s.Get(context.Background(), DataKey("wf", encoding), 0, -1)
+			require.Nil(t, err)
+			defer r.Close()
+			dr, err := NewReader(r, encoding)
+			require.Nil(t, err)
+			data, err := ioutil.ReadAll(dr)
+			require.Nil(t, err)
+			assert.Equal(t, strings.Join(lines, "\n")+"\n", string(data))
+
+			got, next, err := ReadLines(context.Background(), s, "wf", idx, 0, 0)
+			require.Nil(t, err)
+			assert.Equal(t, lines, got)
+			assert.Equal(t, 0, next)
+
+			got, next, err = ReadLines(context.Background(), s, "wf", idx, 40, 50)
+			require.Nil(t, err)
+			assert.Equal(t, lines[40:43], got)
+			assert.Equal(t, 43, next)
+			assert.Equal(t, idx.Frames[5].Offset, s.offsets[len(s.offsets)-1])
+
+			got, next, err = ReadLines(context.Background(), s, "wf", idx, 99, 50)
+			require.Nil(t, err)
+			assert.Equal(t, lines[99:], got)
+			assert.Equal(t, 0, next)
+
+			got, _, err = ReadLines(context.Background(), s, "wf", idx, 100, 0)
+			require.Nil(t, err)
+			assert.Empty(t, got)
+		})
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	s, err := NewDirStore(t.TempDir())
+	require.Nil(t, err)
+
+	_, err = Archive(context.Background(), s, "wf", "br", 100, walkLines(nil))
+	assert.EqualError(t, err, "unsupported encoding 'br'")
+
+	walkErr := errors.New("stream closed")
+	_, err = Archive(context.Background(), s, "wf", Zstd, 100, func(fn func(line string) bool) error {
+		fn("first")
+		return walkErr
+	})
+	assert.Equal(t, walkErr, err)
+
+	// Incomplete archives have no index.
+	_, err = ReadIndex(context.Background(), s, "wf")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestDirStore(t *testing.T) {
+	s, err := NewDirStore(t.TempDir())
+	require.Nil(t, err)
+
+	require.Nil(t, s.Put(context.Background(), "key", strings.NewReader("0123456789")))
+	r, err := s.Get(context.Background(), "key", 2, 5)
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Nil(t, r.Close())
+	assert.Equal(t, "23456", string(data))
+
+	_, err = s.Get(context.Background(), "missing", 0, -1)
+	assert.Equal(t, ErrNotFound, err)
+	assert.EqualError(t, s.Put(context.Background(), "../key", strings.NewReader("")), "invalid key '../key'")
+}
+
+func TestNewStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore("file://"+dir, nil)
+	require.Nil(t, err)
+	assert.Equal(t, &DirStore{dir: dir}, s)
+
+	_, err = NewStore("s3:///prefix", nil)
+	assert.EqualError(t, err, "log archive url must have a bucket")
+	_, err = NewStore("gs://bucket", nil)
+	assert.EqualError(t, err, "unsupported log archive url scheme 'gs'")
+}
@@ -0,0 +1,186 @@
+package logarchive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// ErrNotFound is returned getting an object that isn't stored.
+var ErrNotFound = errors.New("archived logs not found")
+
+// Store stores the objects of archives.
+type Store interface {
+	// Put stores the content read from r as the object of the key.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns the content of the object of the key from the offset, of
+	// the length or until its end when negative, which must be closed.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// NewStore returns the store of the URL, file:///<directory> or
+// s3://<bucket>/<prefix>. S3 stores are created with newS3.
+func NewStore(rawURL string, newS3 func() (s3iface.S3API, error)) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewDirStore(u.Path)
+	case "s3":
+		if u.Host == "" {
+			return nil, errors.New("log archive url must have a bucket")
+		}
+		client, err := newS3()
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Store(client, u.Host, strings.Trim(u.Path, "/")), nil
+	default:
+		return nil, fmt.Errorf("unsupported log archive url scheme '%s'", u.Scheme)
+	}
+}
+
+// DirStore stores objects as the files of a directory, e.g. a mounted volume.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore of the directory, created when missing.
+func NewDirStore(dir string) (*DirStore, error) {
+	if dir == "" {
+		return nil, errors.New("log archive directory can't be empty")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+func (d *DirStore) path(key string) (string, error) {
+	if key == "" || filepath.Base(key) != key || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid key '%s'", key)
+	}
+	return filepath.Join(d.dir, key), nil
+}
+
+// Put writes the object to a temporary file renamed once complete.
+func (d *DirStore) Put(ctx context.Context, key string, r io.Reader) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(d.dir, "."+key+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Get returns the content of the file of the object.
+func (d *DirStore) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+// S3Store stores objects in a bucket, under a prefix.
+type S3Store struct {
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3Store returns an S3Store of the bucket, under the prefix unless empty.
+func NewS3Store(client s3iface.S3API, bucket, prefix string) *S3Store {
+	return &S3Store{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+		bucket:   bucket,
+		prefix:   prefix,
+	}
+}
+
+func (s *S3Store) key(key string) string {
+	return path.Join(s.prefix, key)
+}
+
+// Put uploads the object, in parts when large.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader) error {
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+		Body:   r,
+	})
+	return err
+}
+
+// Get downloads the range of the object.
+func (s *S3Store) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	}
+	switch {
+	case length >= 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	out, err := s.client.GetObjectWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
@@ -0,0 +1,60 @@
+// Package manifest renders manifests through Go templates with the sprig
+// functions, so values differing between targets don't need to be
+// preprocessed before calling the API.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/Masterminds/sprig"
+)
+
+// ErrRender conveys that a manifest can't be rendered, e.g. its template is
+// invalid or refers to an undefined key in strict mode.
+var ErrRender = errors.New("manifest can't be rendered")
+
+// Functions of sprig which aren't available to templates, the environment of
+// the service holds its secrets.
+var excludedFuncs = []string{"env", "expandenv"}
+
+// Context is the data manifests are rendered with, e.g.
+// {{ .Parameters.region }} or {{ .Target.Properties.RoleArn }}.
+type Context struct {
+	Project    string
+	Target     types.Target
+	Parameters map[string]string
+}
+
+// Render renders the manifest with the context. In strict mode referring to
+// an undefined parameter is an error, otherwise it renders as empty.
+func Render(data []byte, c Context, strict bool) ([]byte, error) {
+	funcs := sprig.TxtFuncMap()
+	for _, f := range excludedFuncs {
+		delete(funcs, f)
+	}
+
+	missingKey := "missingkey=zero"
+	if strict {
+		missingKey = "missingkey=error"
+	}
+
+	tmpl, err := template.New("manifest").Funcs(funcs).Option(missingKey).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRender, err)
+	}
+
+	if c.Parameters == nil {
+		c.Parameters = map[string]string{}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRender, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	c := Context{
+		Project:    "project1",
+		Target:     types.Target{Name: "target1", Type: "aws_account", Properties: types.TargetProperties{RoleArn: "arn:aws:iam::123456789012:role/deploy"}},
+		Parameters: map[string]string{"region": "us-west-2"},
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		strict  bool
+		want    string
+		wantErr error
+	}{
+		{
+			name: "parameters and target",
+			data: "project_name: {{ .Project }}\ntarget_name: {{ .Target.Name }}\nregion: {{ .Parameters.region | upper }}\nrole: {{ .Target.Properties.RoleArn | quote }}\n",
+			want: "project_name: project1\ntarget_name: target1\nregion: US-WEST-2\nrole: \"arn:aws:iam::123456789012:role/deploy\"\n",
+		},
+		{
+			name: "undefined parameter renders empty",
+			data: "stack: {{ .Parameters.stack }}",
+			want: "stack: ",
+		},
+		{
+			name:   "default of undefined parameter in strict mode",
+			data:   `stack: {{ index .Parameters "stack" | default "main" }}`,
+			strict: true,
+			want:   "stack: main",
+		},
+		{
+			name:    "undefined parameter in strict mode",
+			data:    "stack: {{ .Parameters.stack }}",
+			strict:  true,
+			wantErr: errors.New(`manifest can't be rendered: template: manifest:1:21: executing "manifest" at <.Parameters.stack>: map has no entry for key "stack"`),
+		},
+		{
+			name:    "invalid template",
+			data:    "stack: {{ .Parameters.stack",
+			wantErr: errors.New("manifest can't be rendered: template: manifest:1: unclosed action"),
+		},
+		{
+			name:    "environment isn't available",
+			data:    `secret: {{ env "ARGO_CLOUDOPS_ADMIN_SECRET" }}`,
+			wantErr: errors.New(`manifest can't be rendered: template: manifest:1: function "env" not defined`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render([]byte(tt.data), c, tt.strict)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				assert.True(t, errors.Is(err, ErrRender))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, string(out))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Package messages is the catalog of user facing messages, e.g. the error
+// messages of responses and the summaries of notifications. Messages are
+// text/template templates of named parameters (e.g. the project, target or
+// limit), so portals can present consistent messages, localized with the
+// Accept-Language of requests. English is built in, the service config can
+// override it and add languages.
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultLanguage is the language of the built in messages.
+const DefaultLanguage = "en"
+
+// ID identifies a message, it's returned to clients with the parameters of
+// the message so they can present their own.
+type ID string
+
+// Messages of the catalog.
+const (
+	ProjectNotFound                   ID = "project_not_found"
+	ProjectDoesNotExist               ID = "project_does_not_exist"
+	TargetNotFound                    ID = "target_not_found"
+	WorkflowNotFound                  ID = "workflow_not_found"
+	Unauthorized                      ID = "unauthorized"
+	InvalidAuthorizationHeader        ID = "invalid_authorization_header"
+	InvalidAuthorizationHeaderFormat  ID = "invalid_authorization_header_format"
+	LimitExceeded                     ID = "limit_exceeded"
+	OutsideBusinessHours              ID = "outside_business_hours"
+	TargetFrozen                      ID = "target_frozen"
+	DiffRequired                      ID = "diff_required"
+	DiffSoaking                       ID = "diff_soaking"
+	DestroyNotAllowed                 ID = "destroy_not_allowed"
+	TargetProtected                   ID = "target_protected"
+	ConsecutiveFailuresNotification   ID = "consecutive_failures_notification"
+	BreakGlassCredentialsNotification ID = "break_glass_credentials_notification"
+	AnomalousSubmissionNotification   ID = "anomalous_submission_notification"
+	PossiblyHungNotification          ID = "possibly_hung_notification"
+)
+
+// Params are the parameters of a message by name.
+type Params map[string]string
+
+type message struct {
+	text   string
+	params []string
+}
+
+// builtIn are the English messages, with the parameters they can use.
+var builtIn = map[ID]message{
+	ProjectNotFound:                   {text: "project not found", params: []string{"project"}},
+	ProjectDoesNotExist:               {text: "project does not exist", params: []string{"project"}},
+	TargetNotFound:                    {text: "target not found", params: []string{"project", "target"}},
+	WorkflowNotFound:                  {text: "workflow not found", params: []string{"workflow"}},
+	Unauthorized:                      {text: "unauthorized"},
+	InvalidAuthorizationHeader:        {text: "error unauthorized, invalid authorization header"},
+	InvalidAuthorizationHeaderFormat:  {text: "error unauthorized, invalid authorization header format"},
+	LimitExceeded:                     {text: "{{.stage}} exceeded the limit of {{.limit}}", params: []string{"stage", "limit"}},
+	OutsideBusinessHours:              {text: "target '{{.target}}' only accepts submissions during business hours ({{.hours}}), next window opens {{.next}}", params: []string{"project", "target", "hours", "next"}},
+	TargetFrozen:                      {text: "target '{{.target}}' of environment '{{.environment}}' is frozen until {{.until}}: {{.reason}}", params: []string{"project", "target", "environment", "until", "reason"}},
+	DiffRequired:                      {text: "target '{{.target}}' requires a successful diff of commit '{{.commit}}'{{if .max_staleness}} within {{.max_staleness}}{{end}} before syncing it", params: []string{"project", "target", "commit", "max_staleness"}},
+	DiffSoaking:                       {text: "diff of commit '{{.commit}}' to target '{{.target}}' is soaking, it can be synced from {{.until}}", params: []string{"project", "target", "commit", "until"}},
+	DestroyNotAllowed:                 {text: "project '{{.project}}' isn't allowed to destroy targets, it requires the {{.scope}} scope", params: []string{"project", "target", "scope"}},
+	TargetProtected:                   {text: "target '{{.target}}' is protected, it can't be destroyed", params: []string{"project", "target"}},
+	ConsecutiveFailuresNotification:   {text: "{{.failures}} consecutive cello workflows failed for {{.project}}/{{.target}}", params: []string{"project", "target", "workflow", "failures"}},
+	BreakGlassCredentialsNotification: {text: "break-glass credentials issued for cello target {{.project}}/{{.target}}", params: []string{"project", "target", "expires"}},
+	AnomalousSubmissionNotification:   {text: "anomalous submission to cello target {{.project}}/{{.target}}: {{.reasons}}", params: []string{"project", "target", "score", "reasons"}},
+	PossiblyHungNotification:          {text: "cello workflow {{.workflow}} of {{.project}}/{{.target}} is possibly hung, no progress for {{.duration}}", params: []string{"project", "target", "workflow", "duration"}},
+}
+
+var languageRegex = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Catalog renders messages in the languages it has. A nil Catalog has the
+// built in messages only.
+type Catalog struct {
+	defaultLanguage string
+	// templates are the templates of the messages by language, languages
+	// without a message use the one of the default language.
+	templates map[string]map[ID]*template.Template
+}
+
+var builtInCatalog = mustNew()
+
+func mustNew() *Catalog {
+	c, err := New("", nil)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// New creates a catalog of the built in messages, overridden by the messages
+// by language and ID, e.g. of the service config. Messages can only use the
+// parameters of their ID. The default language (DefaultLanguage when empty)
+// is the language of requests not accepting any of the catalog.
+func New(defaultLanguage string, languages map[string]map[string]string) (*Catalog, error) {
+	if defaultLanguage == "" {
+		defaultLanguage = DefaultLanguage
+	}
+
+	c := &Catalog{defaultLanguage: defaultLanguage, templates: map[string]map[ID]*template.Template{DefaultLanguage: {}}}
+	for id, m := range builtIn {
+		c.templates[DefaultLanguage][id] = template.Must(parse(id, m.text))
+	}
+
+	for lang, msgs := range languages {
+		if !languageRegex.MatchString(lang) {
+			return nil, fmt.Errorf("messages: '%s' must be a language tag, e.g. 'fr' or 'pt-BR'", lang)
+		}
+		if c.templates[lang] == nil {
+			c.templates[lang] = map[ID]*template.Template{}
+		}
+		for name, text := range msgs {
+			id := ID(name)
+			if _, ok := builtIn[id]; !ok {
+				return nil, fmt.Errorf("messages: %s: unknown message '%s'", lang, name)
+			}
+			t, err := parse(id, text)
+			if err != nil {
+				return nil, fmt.Errorf("messages: %s: %s: %w", lang, name, err)
+			}
+			if err := t.Execute(&bytes.Buffer{}, builtIn[id].sample()); err != nil {
+				return nil, fmt.Errorf("messages: %s: %s must only use the parameters '%s'", lang, name, strings.Join(builtIn[id].params, " "))
+			}
+			c.templates[lang][id] = t
+		}
+	}
+
+	if _, ok := c.templates[c.defaultLanguage]; !ok {
+		return nil, fmt.Errorf("default_language: no messages in '%s'", c.defaultLanguage)
+	}
+	return c, nil
+}
+
+func parse(id ID, text string) (*template.Template, error) {
+	return template.New(string(id)).Option("missingkey=error").Parse(text)
+}
+
+// sample returns parameters for all the parameters of the message.
+func (m message) sample() map[string]string {
+	params := map[string]string{}
+	for _, p := range m.params {
+		params[p] = p
+	}
+	return params
+}
+
+// DefaultLanguage returns the default language of the catalog.
+func (c *Catalog) DefaultLanguage() string {
+	if c == nil {
+		return DefaultLanguage
+	}
+	return c.defaultLanguage
+}
+
+// Message returns the message in the language, falling back to the default
+// language then the built in message. Parameters the message doesn't use are
+// ignored.
+func (c *Catalog) Message(lang string, id ID, params Params) string {
+	if c == nil {
+		c = builtInCatalog
+	}
+
+	data := map[string]string{}
+	for _, p := range builtIn[id].params {
+		data[p] = params[p]
+	}
+
+	for _, l := range []string{lang, c.defaultLanguage, DefaultLanguage} {
+		t, ok := c.templates[l][id]
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err == nil {
+			return buf.String()
+		}
+	}
+	return string(id)
+}
+
+// Negotiate returns the language of the catalog best matching the
+// Accept-Language header, e.g. 'fr-CH, fr;q=0.9, en;q=0.8'. Tags match
+// their language when the catalog doesn't have the tag itself, the default
+// language is returned when none match.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	if c == nil {
+		c = builtInCatalog
+	}
+
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{lang: strings.TrimSpace(fields[0]), q: 1}
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				q, err := strconv.ParseFloat(v[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				t.q = q
+			}
+		}
+		if t.lang != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if t.lang == "*" {
+			return c.defaultLanguage
+		}
+		if lang, ok := c.language(t.lang); ok {
+			return lang
+		}
+		if i := strings.Index(t.lang, "-"); i > 0 {
+			if lang, ok := c.language(t.lang[:i]); ok {
+				return lang
+			}
+		}
+	}
+	return c.defaultLanguage
+}
+
+// language returns the language of the catalog matching the tag, ignoring
+// case.
+func (c *Catalog) language(tag string) (string, bool) {
+	for lang := range c.templates {
+		if strings.EqualFold(lang, tag) {
+			return lang, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,109 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name            string
+		defaultLanguage string
+		languages       map[string]map[string]string
+		wantErr         string
+	}{
+		{
+			name:      "built in",
+			languages: nil,
+		},
+		{
+			name:            "language added",
+			defaultLanguage: "fr",
+			languages:       map[string]map[string]string{"fr": {"target_not_found": "cible {{.target}} introuvable"}},
+		},
+		{
+			name:      "invalid language",
+			languages: map[string]map[string]string{"French": {"target_not_found": "cible introuvable"}},
+			wantErr:   "messages: 'French' must be a language tag, e.g. 'fr' or 'pt-BR'",
+		},
+		{
+			name:      "unknown message",
+			languages: map[string]map[string]string{"fr": {"not_a_message": "inconnu"}},
+			wantErr:   "messages: fr: unknown message 'not_a_message'",
+		},
+		{
+			name:      "invalid template",
+			languages: map[string]map[string]string{"fr": {"target_not_found": "cible {{.target"}},
+			wantErr:   "messages: fr: target_not_found: template: target_not_found:1: unclosed action",
+		},
+		{
+			name:      "unknown parameter",
+			languages: map[string]map[string]string{"fr": {"target_not_found": "cible {{.workflow}} introuvable"}},
+			wantErr:   "messages: fr: target_not_found must only use the parameters 'project target'",
+		},
+		{
+			name:            "default language without messages",
+			defaultLanguage: "de",
+			wantErr:         "default_language: no messages in 'de'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.defaultLanguage, tt.languages)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	c, err := New("", map[string]map[string]string{
+		"fr": {"limit_exceeded": "{{.stage}} a dépassé la limite de {{.limit}}"},
+		"en": {"unauthorized": "not authorized"},
+	})
+	assert.NoError(t, err)
+
+	params := Params{"stage": "git fetch", "limit": "2m0s", "unused": "ignored"}
+	assert.Equal(t, "git fetch a dépassé la limite de 2m0s", c.Message("fr", LimitExceeded, params))
+	assert.Equal(t, "git fetch exceeded the limit of 2m0s", c.Message("en", LimitExceeded, params))
+	// Messages missing from a language use the default language.
+	assert.Equal(t, "not authorized", c.Message("fr", Unauthorized, nil))
+	assert.Equal(t, "target not found", c.Message("de", TargetNotFound, nil))
+
+	var builtInOnly *Catalog
+	assert.Equal(t, "unauthorized", builtInOnly.Message("fr", Unauthorized, nil))
+	assert.Equal(t, "3 consecutive cello workflows failed for p/t",
+		builtInOnly.Message(DefaultLanguage, ConsecutiveFailuresNotification, Params{"project": "p", "target": "t", "failures": "3"}))
+}
+
+func TestNegotiate(t *testing.T) {
+	c, err := New("", map[string]map[string]string{
+		"fr":    {"unauthorized": "non autorisé"},
+		"pt-BR": {"unauthorized": "não autorizado"},
+	})
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"":                            "en",
+		"fr":                          "fr",
+		"fr-CH, fr;q=0.9, en;q=0.8":   "fr",
+		"de, en;q=0.5, fr;q=0.7":      "fr",
+		"pt-br":                       "pt-BR",
+		"de, *;q=0.1":                 "en",
+		"fr;q=0, en":                  "en",
+		"ja":                          "en",
+		"en-US,en;q=0.9,fr;q=invalid": "en",
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, c.Negotiate(header), header)
+	}
+
+	frDefault, err := New("fr", map[string]map[string]string{"fr": {}})
+	assert.NoError(t, err)
+	assert.Equal(t, "fr", frDefault.Negotiate("ja"))
+}
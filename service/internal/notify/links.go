@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WorkflowLink links to the workflow and its logs in the Argo UI.
+func WorkflowLink(argoAddress, namespace, workflowName string) Link {
+	return Link{
+		Href: fmt.Sprintf("%s/workflows/%s/%s", strings.TrimSuffix(argoAddress, "/"), namespace, workflowName),
+		Text: "Workflow logs",
+	}
+}
+
+// CommitLink links to the diff of the commit in the web UI of the repository
+// (e.g. https://github.com/org/repo.git or git@github.com:org/repo.git). It
+// returns false when the repository isn't hosted on a web UI.
+func CommitLink(repository, commitHash string) (Link, bool) {
+	base := strings.TrimSuffix(repository, ".git")
+	switch {
+	case strings.HasPrefix(base, "https://"):
+	case strings.HasPrefix(base, "git@"):
+		base = "https://" + strings.Replace(strings.TrimPrefix(base, "git@"), ":", "/", 1)
+	default:
+		return Link{}, false
+	}
+
+	return Link{
+		Href: fmt.Sprintf("%s/commit/%s", base, commitHash),
+		Text: fmt.Sprintf("Diff of %s", commitHash),
+	}, true
+}
@@ -0,0 +1,252 @@
+// Package notify notifies external systems (e.g. PagerDuty) when workflows
+// for a target fail repeatedly.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Notification rule types.
+const (
+	TypePagerDuty = "pagerduty"
+)
+
+// Rule notifies when ConsecutiveFailures workflows in a row failed for a
+// target of the project. RoutingKey addresses the notification in the system
+// of the type, e.g. a PagerDuty integration key.
+type Rule struct {
+	Type                string
+	RoutingKey          string
+	ConsecutiveFailures int
+}
+
+// Link is a link included in a notification.
+type Link struct {
+	Href string
+	Text string
+}
+
+// Failure describes the latest failed workflow of a target.
+type Failure struct {
+	Project             string
+	Target              string
+	WorkflowName        string
+	CommitHash          string
+	ConsecutiveFailures int
+	Links               []Link
+	// Origin is the origin the workflow was submitted with, if any.
+	Origin *types.Origin
+}
+
+// Summary is a one line description of the failure.
+func (f Failure) Summary() string {
+	return fmt.Sprintf("%d consecutive cello workflows failed for %s/%s", f.ConsecutiveFailures, f.Project, f.Target)
+}
+
+// Notifier sends notifications of failures.
+type Notifier interface {
+	Notify(ctx context.Context, rule Rule, f Failure) error
+}
+
+// Alert is a one-off notification about a target, e.g. of break-glass access
+// to its credentials. Alerts with the same dedup key are grouped.
+type Alert struct {
+	Project  string
+	Target   string
+	Summary  string
+	DedupKey string
+	Details  map[string]string
+}
+
+// Alerter is a Notifier which also sends alerts.
+type Alerter interface {
+	Alert(ctx context.Context, rule Rule, a Alert) error
+}
+
+// Watcher waits for workflows to complete, notifying the rules whose
+// threshold of consecutive failures is reached.
+type Watcher struct {
+	argo     workflow.Workflow
+	interval time.Duration
+	logger   log.Logger
+
+	mu        sync.RWMutex
+	notifiers map[string]Notifier
+}
+
+// NewWatcher creates a Watcher checking workflow statuses every interval.
+func NewWatcher(argo workflow.Workflow, interval time.Duration, logger log.Logger) *Watcher {
+	return &Watcher{
+		argo:      argo,
+		interval:  interval,
+		logger:    logger,
+		notifiers: map[string]Notifier{},
+	}
+}
+
+// Register adds the notifier of a rule type.
+func (w *Watcher) Register(ruleType string, n Notifier) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notifiers[ruleType] = n
+}
+
+// Types returns the sorted rule types with a registered notifier. A nil
+// Watcher has none.
+func (w *Watcher) Types() []string {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	types := make([]string, 0, len(w.notifiers))
+	for t := range w.notifiers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Watch waits until the workflow of the failure completes or ctx is done.
+// When it failed, the consecutive failures of its target up to the workflow
+// are counted and every rule reaching its threshold is notified, calling
+// onNotify for each.
+func (w *Watcher) Watch(ctx context.Context, f Failure, rules []Rule, onNotify func(Rule, Failure)) {
+	l := log.With(w.logger, "workflow", f.WorkflowName)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var status *workflow.Status
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var err error
+		status, err = w.argo.Status(ctx, f.WorkflowName)
+		if err != nil {
+			level.Warn(l).Log("message", "error getting workflow status", "error", err)
+			continue
+		}
+		if workflow.IsActive(status.Status) {
+			continue
+		}
+		if !IsFailed(status.Status) {
+			return
+		}
+		break
+	}
+
+	statuses, err := w.argo.ListByLabels(ctx, map[string]string{
+		workflow.LabelProject: f.Project,
+		workflow.LabelTarget:  f.Target,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error listing target workflows", "error", err)
+		return
+	}
+	// Later workflows are counted by their own watch so a streak is only
+	// notified once per workflow.
+	previous := []workflow.Status{}
+	for _, s := range statuses {
+		if created(s) <= created(*status) {
+			previous = append(previous, s)
+		}
+	}
+	f.ConsecutiveFailures = ConsecutiveFailures(previous)
+	f.Origin = workflow.Origin(status.Annotations)
+
+	for _, rule := range rules {
+		if f.ConsecutiveFailures < rule.ConsecutiveFailures {
+			continue
+		}
+
+		w.mu.RLock()
+		n, ok := w.notifiers[rule.Type]
+		w.mu.RUnlock()
+		if !ok {
+			level.Error(l).Log("message", "unknown notification rule type", "type", rule.Type)
+			continue
+		}
+
+		if err := n.Notify(ctx, rule, f); err != nil {
+			level.Error(l).Log("message", "error sending notification", "type", rule.Type, "error", err)
+			continue
+		}
+		level.Info(l).Log("message", "notification sent", "type", rule.Type, "consecutive-failures", f.ConsecutiveFailures)
+		onNotify(rule, f)
+	}
+}
+
+// Alert sends the alert to every rule whatever their threshold, calling
+// onAlert for each. Errors are logged and rules whose notifier can't send
+// alerts are skipped.
+func (w *Watcher) Alert(ctx context.Context, a Alert, rules []Rule, onAlert func(Rule)) {
+	l := log.With(w.logger, "project", a.Project, "target", a.Target)
+
+	for _, rule := range rules {
+		w.mu.RLock()
+		n, ok := w.notifiers[rule.Type]
+		w.mu.RUnlock()
+		alerter, canAlert := n.(Alerter)
+		if !ok || !canAlert {
+			level.Warn(l).Log("message", "notification rule type can't send alerts", "type", rule.Type)
+			continue
+		}
+
+		if err := alerter.Alert(ctx, rule, a); err != nil {
+			level.Error(l).Log("message", "error sending alert", "type", rule.Type, "error", err)
+			continue
+		}
+		level.Info(l).Log("message", "alert sent", "type", rule.Type)
+		onAlert(rule)
+	}
+}
+
+// IsFailed reports whether a completed workflow with the status failed.
+func IsFailed(status string) bool {
+	return status == "failed" || status == "error"
+}
+
+// ConsecutiveFailures counts the most recently created completed workflows
+// which failed, ignoring workflows which are still active.
+func ConsecutiveFailures(statuses []workflow.Status) int {
+	sorted := make([]workflow.Status, len(statuses))
+	copy(sorted, statuses)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return created(sorted[i]) > created(sorted[j])
+	})
+
+	n := 0
+	for _, s := range sorted {
+		if workflow.IsActive(s.Status) {
+			continue
+		}
+		if !IsFailed(s.Status) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func created(s workflow.Status) int64 {
+	// Created is a unix timestamp, unparsable values sort last.
+	c, _ := strconv.ParseInt(s.Created, 10, 64)
+	return c
+}
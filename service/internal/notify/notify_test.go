@@ -0,0 +1,275 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsecutiveFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []workflow.Status
+		want     int
+	}{
+		{
+			name: "none",
+			want: 0,
+		},
+		{
+			name: "latest succeeded",
+			statuses: []workflow.Status{
+				{Status: "failed", Created: "1"},
+				{Status: "succeeded", Created: "3"},
+				{Status: "failed", Created: "2"},
+			},
+			want: 0,
+		},
+		{
+			name: "failures since last success",
+			statuses: []workflow.Status{
+				{Status: "error", Created: "4"},
+				{Status: "succeeded", Created: "1"},
+				{Status: "failed", Created: "3"},
+				{Status: "failed", Created: "2"},
+			},
+			want: 3,
+		},
+		{
+			name: "active workflows are ignored",
+			statuses: []workflow.Status{
+				{Status: "running", Created: "3"},
+				{Status: "failed", Created: "2"},
+				{Status: "succeeded", Created: "1"},
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ConsecutiveFailures(tt.statuses))
+		})
+	}
+}
+
+func TestCommitLink(t *testing.T) {
+	tests := []struct {
+		repository string
+		want       string
+		ok         bool
+	}{
+		{repository: "https://github.com/org/repo.git", want: "https://github.com/org/repo/commit/abc", ok: true},
+		{repository: "git@github.com:org/repo.git", want: "https://github.com/org/repo/commit/abc", ok: true},
+		{repository: "file:///tmp/repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repository, func(t *testing.T) {
+			link, ok := CommitLink(tt.repository, "abc")
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, link.Href)
+		})
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	var got pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	pd := NewPagerDuty(srv.URL, srv.Client())
+	err := pd.Notify(context.Background(), Rule{Type: TypePagerDuty, RoutingKey: "key1", ConsecutiveFailures: 2}, Failure{
+		Project:             "project1",
+		Target:              "target1",
+		WorkflowName:        "wf-1",
+		ConsecutiveFailures: 2,
+		Links:               []Link{{Href: "https://argo/workflows/argo/wf-1", Text: "Workflow logs"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "key1", got.RoutingKey)
+	assert.Equal(t, "trigger", got.EventAction)
+	assert.Equal(t, "cello/project1/target1", got.DedupKey)
+	assert.Equal(t, "2 consecutive cello workflows failed for project1/target1", got.Payload.Summary)
+	assert.Equal(t, []pagerDutyLink{{Href: "https://argo/workflows/argo/wf-1", Text: "Workflow logs"}}, got.Links)
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	err = pd.Notify(context.Background(), Rule{RoutingKey: "key1"}, Failure{})
+	assert.EqualError(t, err, "received code 400 from pagerduty")
+}
+
+func TestPagerDutyOrigin(t *testing.T) {
+	var got pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	pd := NewPagerDuty(srv.URL, srv.Client())
+	err := pd.Notify(context.Background(), Rule{Type: TypePagerDuty, RoutingKey: "key1", ConsecutiveFailures: 1}, Failure{
+		Project:             "project1",
+		Target:              "target1",
+		WorkflowName:        "wf-1",
+		ConsecutiveFailures: 1,
+		Origin:              &types.Origin{CIJobURL: "https://ci.example.com/job/42", TriggeredBy: "jane"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://ci.example.com/job/42", got.Payload.CustomDetails["origin_ci_job_url"])
+	assert.Equal(t, "jane", got.Payload.CustomDetails["origin_triggered_by"])
+	assert.NotContains(t, got.Payload.CustomDetails, "origin_ticket_id")
+	assert.Equal(t, []pagerDutyLink{{Href: "https://ci.example.com/job/42", Text: "CI job"}}, got.Links)
+}
+
+func TestPagerDutyFailureSummary(t *testing.T) {
+	var got pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	pd := NewPagerDuty(srv.URL, srv.Client(), WithFailureSummary(func(f Failure) string {
+		return fmt.Sprintf("%d workflows cello en échec pour %s/%s", f.ConsecutiveFailures, f.Project, f.Target)
+	}))
+	err := pd.Notify(context.Background(), Rule{Type: TypePagerDuty, RoutingKey: "key1", ConsecutiveFailures: 3}, Failure{
+		Project:             "project1",
+		Target:              "target1",
+		ConsecutiveFailures: 3,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "3 workflows cello en échec pour project1/target1", got.Payload.Summary)
+}
+
+func TestPagerDutyAlert(t *testing.T) {
+	var got pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	pd := NewPagerDuty(srv.URL, srv.Client())
+	err := pd.Alert(context.Background(), Rule{Type: TypePagerDuty, RoutingKey: "key1"}, Alert{
+		Project:  "project1",
+		Target:   "target1",
+		Summary:  "break-glass credentials issued for project1/target1",
+		DedupKey: "cello/project1/target1/break-glass/tx1",
+		Details:  map[string]string{"justification": "incident 42"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "key1", got.RoutingKey)
+	assert.Equal(t, "cello/project1/target1/break-glass/tx1", got.DedupKey)
+	assert.Equal(t, "warning", got.Payload.Severity)
+	assert.Equal(t, "break-glass credentials issued for project1/target1", got.Payload.Summary)
+	assert.Equal(t, map[string]string{"justification": "incident 42"}, got.Payload.CustomDetails)
+}
+
+type statusWorkflow struct {
+	workflow.Workflow
+	status   string
+	statuses []workflow.Status
+}
+
+func (s statusWorkflow) Status(ctx context.Context, workflowName string) (*workflow.Status, error) {
+	return &workflow.Status{Name: workflowName, Status: s.status, Created: "2"}, nil
+}
+
+func (s statusWorkflow) ListByLabels(ctx context.Context, selector map[string]string) ([]workflow.Status, error) {
+	return s.statuses, nil
+}
+
+type recordingNotifier struct {
+	mu    sync.Mutex
+	rules []Rule
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, rule Rule, f Failure) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *recordingNotifier) Alert(ctx context.Context, rule Rule, a Alert) error {
+	return r.Notify(ctx, rule, Failure{})
+}
+
+// failureNotifier only sends notifications of failures.
+type failureNotifier struct{}
+
+func (failureNotifier) Notify(ctx context.Context, rule Rule, f Failure) error {
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	failures := []workflow.Status{
+		{Name: "wf-3", Status: "failed", Created: "3"},
+		{Name: "wf-2", Status: "failed", Created: "2"},
+		{Name: "wf-1", Status: "failed", Created: "1"},
+	}
+	rules := []Rule{
+		{Type: TypePagerDuty, RoutingKey: "two", ConsecutiveFailures: 2},
+		{Type: TypePagerDuty, RoutingKey: "three", ConsecutiveFailures: 3},
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		want   []Rule
+	}{
+		{name: "failed", status: "failed", want: []Rule{rules[0]}},
+		{name: "succeeded", status: "succeeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &recordingNotifier{}
+			w := NewWatcher(statusWorkflow{status: tt.status, statuses: failures}, time.Millisecond, log.NewNopLogger())
+			w.Register(TypePagerDuty, n)
+
+			var notified []Failure
+			w.Watch(context.Background(), Failure{Project: "project1", Target: "target1", WorkflowName: "wf-2"}, rules, func(r Rule, f Failure) {
+				notified = append(notified, f)
+			})
+			assert.Equal(t, tt.want, n.rules)
+			for _, f := range notified {
+				assert.Equal(t, 2, f.ConsecutiveFailures)
+			}
+		})
+	}
+}
+
+func TestAlert(t *testing.T) {
+	rules := []Rule{
+		{Type: TypePagerDuty, RoutingKey: "one", ConsecutiveFailures: 5},
+		{Type: "failures-only", RoutingKey: "two", ConsecutiveFailures: 1},
+		{Type: "unknown", RoutingKey: "three", ConsecutiveFailures: 1},
+	}
+
+	n := &recordingNotifier{}
+	w := NewWatcher(statusWorkflow{}, time.Millisecond, log.NewNopLogger())
+	w.Register(TypePagerDuty, n)
+	w.Register("failures-only", failureNotifier{})
+
+	var alerted []Rule
+	w.Alert(context.Background(), Alert{Project: "project1", Target: "target1"}, rules, func(r Rule) {
+		alerted = append(alerted, r)
+	})
+	assert.Equal(t, []Rule{rules[0]}, n.rules)
+	assert.Equal(t, []Rule{rules[0]}, alerted)
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty is a Notifier triggering PagerDuty incidents through the Events
+// API v2. Failures of the same target share a dedup key so they're grouped
+// into one open incident.
+type PagerDuty struct {
+	eventsURL string
+	cl        *http.Client
+	summary   func(Failure) string
+}
+
+// PagerDutyOption configures a PagerDuty notifier.
+type PagerDutyOption func(*PagerDuty)
+
+// WithFailureSummary summarizes failures with the function instead of
+// Failure.Summary, e.g. with a localized message.
+func WithFailureSummary(summary func(Failure) string) PagerDutyOption {
+	return func(p *PagerDuty) {
+		p.summary = summary
+	}
+}
+
+// NewPagerDuty creates a PagerDuty notifier sending events to eventsURL
+// (e.g. PagerDutyEventsURL).
+func NewPagerDuty(eventsURL string, cl *http.Client, opts ...PagerDutyOption) PagerDuty {
+	p := PagerDuty{eventsURL: eventsURL, cl: cl, summary: Failure.Summary}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component"`
+	Group         string            `json:"group"`
+	CustomDetails map[string]string `json:"custom_details"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+	Links       []pagerDutyLink  `json:"links,omitempty"`
+}
+
+// Notify triggers an incident using the routing key of the rule.
+func (p PagerDuty) Notify(ctx context.Context, rule Rule, f Failure) error {
+	event := pagerDutyEvent{
+		RoutingKey:  rule.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    fmt.Sprintf("cello/%s/%s", f.Project, f.Target),
+		Payload: pagerDutyPayload{
+			Summary:   p.summary(f),
+			Source:    "cello",
+			Severity:  "error",
+			Component: f.Target,
+			Group:     f.Project,
+			CustomDetails: map[string]string{
+				"workflow":             f.WorkflowName,
+				"commit":               f.CommitHash,
+				"consecutive_failures": fmt.Sprint(f.ConsecutiveFailures),
+			},
+		},
+	}
+	for _, link := range f.Links {
+		event.Links = append(event.Links, pagerDutyLink(link))
+	}
+	if f.Origin != nil {
+		for k, v := range f.Origin.Fields() {
+			event.Payload.CustomDetails["origin_"+k] = v
+		}
+		if f.Origin.CIJobURL != "" {
+			event.Links = append(event.Links, pagerDutyLink{Href: f.Origin.CIJobURL, Text: "CI job"})
+		}
+	}
+
+	return p.send(ctx, event)
+}
+
+// Alert triggers a warning using the routing key of the rule.
+func (p PagerDuty) Alert(ctx context.Context, rule Rule, a Alert) error {
+	return p.send(ctx, pagerDutyEvent{
+		RoutingKey:  rule.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    a.DedupKey,
+		Payload: pagerDutyPayload{
+			Summary:       a.Summary,
+			Source:        "cello",
+			Severity:      "warning",
+			Component:     a.Target,
+			Group:         a.Project,
+			CustomDetails: a.Details,
+		},
+	})
+}
+
+func (p PagerDuty) send(ctx context.Context, event pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.eventsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create pagerduty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("received error connecting to pagerduty: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("received code %d from pagerduty", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Package oci retrieves files of OCI artifacts, e.g. bundles pushed with ORAS,
+// from registries implementing the OCI distribution API.
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Media types of supported manifests.
+const (
+	MediaTypeImageManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeArtifactManifest = "application/vnd.oci.artifact.manifest.v1+json"
+)
+
+// AnnotationTitle is the file name of a layer, set by ORAS when pushing
+// files.
+const AnnotationTitle = "org.opencontainers.image.title"
+
+const (
+	maxManifestSize = 4 << 20
+	maxFileSize     = 10 << 20
+)
+
+var (
+	digestRegex     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	registryRegex   = regexp.MustCompile(`^[a-zA-Z0-9.-]+(:[0-9]+)?$`)
+	repositoryRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	challengeRegex  = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// ErrNotFound is returned when the artifact or the file doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Reference identifies an artifact by digest, e.g.
+// registry.example.com/team/bundle@sha256:<hex>. Tags aren't supported as
+// they can be moved.
+type Reference struct {
+	Registry   string
+	Repository string
+	Digest     string
+}
+
+// ParseReference parses a reference pinned by a sha256 digest.
+func ParseReference(s string) (Reference, error) {
+	parts := strings.SplitN(s, "@", 2)
+	if len(parts) != 2 || !digestRegex.MatchString(parts[1]) {
+		return Reference{}, errors.New("reference must be pinned by a sha256 digest")
+	}
+
+	name := strings.SplitN(parts[0], "/", 2)
+	if len(name) != 2 || !registryRegex.MatchString(name[0]) {
+		return Reference{}, errors.New("reference must include the registry")
+	}
+	if !repositoryRegex.MatchString(name[1]) {
+		return Reference{}, fmt.Errorf("invalid repository '%s'", name[1])
+	}
+
+	return Reference{Registry: name[0], Repository: name[1], Digest: parts[1]}, nil
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s/%s@%s", r.Registry, r.Repository, r.Digest)
+}
+
+// Credentials authenticate to a registry, used for basic auth or to obtain a
+// bearer token depending on the registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Client retrieves files of OCI artifacts.
+type Client interface {
+	// GetManifestFile returns the file at path, the title of a layer, of the
+	// artifact. Credentials are optional.
+	GetManifestFile(ctx context.Context, ref Reference, creds *Credentials, path string) ([]byte, error)
+}
+
+// HTTPClient is a Client using the OCI distribution API over HTTPS.
+type HTTPClient struct {
+	client *http.Client
+}
+
+// NewHTTPClient creates an HTTPClient sending requests with client.
+func NewHTTPClient(client *http.Client) HTTPClient {
+	return HTTPClient{client: client}
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []descriptor `json:"layers"`
+	// Blobs are the files of artifact manifests.
+	Blobs []descriptor `json:"blobs"`
+}
+
+// GetManifestFile implements Client. The manifest and the file are verified
+// against their digests.
+func (c HTTPClient) GetManifestFile(ctx context.Context, ref Reference, creds *Credentials, path string) ([]byte, error) {
+	s := &session{client: c.client, ref: ref, creds: creds}
+
+	data, err := s.get(ctx, "manifests", ref.Digest, MediaTypeImageManifest+", "+MediaTypeArtifactManifest, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest of '%s': %w", ref, err)
+	}
+	if err := verify(data, ref.Digest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of '%s': %w", ref, err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of '%s': %w", ref, err)
+	}
+
+	for _, d := range append(m.Layers, m.Blobs...) {
+		if d.Annotations[AnnotationTitle] != path {
+			continue
+		}
+		if d.Size > maxFileSize {
+			return nil, fmt.Errorf("file '%s' of '%s' is larger than %d bytes", path, ref, maxFileSize)
+		}
+		if !digestRegex.MatchString(d.Digest) {
+			return nil, fmt.Errorf("file '%s' of '%s' has an unsupported digest '%s'", path, ref, d.Digest)
+		}
+
+		data, err := s.get(ctx, "blobs", d.Digest, "", maxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get file '%s' of '%s': %w", path, ref, err)
+		}
+		if err := verify(data, d.Digest); err != nil {
+			return nil, fmt.Errorf("invalid file '%s' of '%s': %w", path, ref, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("file '%s' of '%s': %w", path, ref, ErrNotFound)
+}
+
+func verify(data []byte, digest string) error {
+	sum := sha256.Sum256(data)
+	if got := "sha256:" + hex.EncodeToString(sum[:]); got != digest {
+		return fmt.Errorf("digest mismatch, got '%s'", got)
+	}
+	return nil
+}
+
+// session holds the authorization obtained from the challenge of the
+// registry, so it's only negotiated once per artifact.
+type session struct {
+	client        *http.Client
+	ref           Reference
+	creds         *Credentials
+	authorization string
+}
+
+func (s *session) get(ctx context.Context, kind, digest, accept string, limit int64) ([]byte, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s/%s", s.ref.Registry, s.ref.Repository, kind, digest)
+
+	resp, err := s.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && s.authorization == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if s.authorization, err = s.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = s.do(ctx, u, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("registry returned status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+func (s *session) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	return s.client.Do(req)
+}
+
+// authorize returns the authorization header answering the challenge of the
+// registry, a basic auth or bearer token one.
+func (s *session) authorize(ctx context.Context, challenge string) (string, error) {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	switch scheme {
+	case "basic":
+		if s.creds == nil {
+			return "", errors.New("registry requires credentials")
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(s.creds.Username, s.creds.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := s.token(ctx, challenge)
+		if err != nil {
+			return "", fmt.Errorf("unable to get registry token: %w", err)
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("unsupported registry authentication '%s'", challenge)
+	}
+}
+
+// token gets a pull token of the repository from the realm of the bearer
+// challenge, anonymously without credentials.
+func (s *session) token(ctx context.Context, challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range challengeRegex.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" || realm.Host == "" {
+		return "", fmt.Errorf("invalid realm '%s'", params["realm"])
+	}
+
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", fmt.Sprintf("repository:%s:pull", s.ref.Repository))
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if s.creds != nil {
+		req.SetBasicAuth(s.creds.Username, s.creds.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	if t.AccessToken != "" {
+		return t.AccessToken, nil
+	}
+	return "", errors.New("token endpoint returned no token")
+}
@@ -0,0 +1,243 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestParseReference(t *testing.T) {
+	d := digest([]byte("manifest"))
+	tests := []struct {
+		name    string
+		ref     string
+		want    Reference
+		wantErr string
+	}{
+		{
+			name: "valid",
+			ref:  "registry.example.com/team/bundle@" + d,
+			want: Reference{Registry: "registry.example.com", Repository: "team/bundle", Digest: d},
+		},
+		{
+			name: "registry_with_port",
+			ref:  "localhost:5000/bundle@" + d,
+			want: Reference{Registry: "localhost:5000", Repository: "bundle", Digest: d},
+		},
+		{
+			name:    "tag",
+			ref:     "registry.example.com/team/bundle:v1",
+			wantErr: "reference must be pinned by a sha256 digest",
+		},
+		{
+			name:    "invalid_digest",
+			ref:     "registry.example.com/team/bundle@sha256:abc",
+			wantErr: "reference must be pinned by a sha256 digest",
+		},
+		{
+			name:    "missing_registry",
+			ref:     "bundle@" + d,
+			wantErr: "reference must include the registry",
+		},
+		{
+			name:    "invalid_repository",
+			ref:     "registry.example.com/Team/bundle@" + d,
+			wantErr: "invalid repository 'Team/bundle'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.ref)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ref, got.String())
+		})
+	}
+}
+
+// registry serves an artifact with a manifest.yaml file, authorizing
+// requests as configured.
+type registry struct {
+	srv      *httptest.Server
+	manifest []byte
+	file     []byte
+	// auth is the authentication required, basic, bearer or none.
+	auth string
+}
+
+func newRegistry(t *testing.T, auth string, file []byte) *registry {
+	reg := &registry{auth: auth, file: file}
+	m := manifest{
+		MediaType: MediaTypeImageManifest,
+		Layers: []descriptor{{
+			MediaType:   "application/vnd.cello.manifest.v1+yaml",
+			Digest:      digest(file),
+			Size:        int64(len(file)),
+			Annotations: map[string]string{AnnotationTitle: "manifest.yaml"},
+		}},
+	}
+	reg.manifest, _ = json.Marshal(m)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "registry.test", r.URL.Query().Get("service"))
+		assert.Equal(t, "repository:team/bundle:pull", r.URL.Query().Get("scope"))
+		fmt.Fprint(w, `{"token":"t0ken"}`)
+	})
+	mux.HandleFunc("/v2/team/bundle/", func(w http.ResponseWriter, r *http.Request) {
+		if !reg.authorized(r) {
+			if reg.auth == "basic" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, reg.srv.URL))
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/team/bundle/manifests/" + digest(reg.manifest):
+			assert.Contains(t, r.Header.Get("Accept"), MediaTypeImageManifest)
+			w.Write(reg.manifest)
+		case "/v2/team/bundle/blobs/" + digest(file):
+			w.Write(reg.file)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	reg.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(reg.srv.Close)
+	return reg
+}
+
+func (reg *registry) authorized(r *http.Request) bool {
+	switch reg.auth {
+	case "basic":
+		user, pass, ok := r.BasicAuth()
+		return ok && user == "user" && pass == "pass"
+	case "bearer":
+		return r.Header.Get("Authorization") == "Bearer t0ken"
+	default:
+		return true
+	}
+}
+
+func (reg *registry) ref() Reference {
+	return Reference{
+		Registry:   strings.TrimPrefix(reg.srv.URL, "https://"),
+		Repository: "team/bundle",
+		Digest:     digest(reg.manifest),
+	}
+}
+
+func TestGetManifestFile(t *testing.T) {
+	file := []byte("project_name: project1\n")
+	creds := &Credentials{Username: "user", Password: "pass"}
+
+	tests := []struct {
+		name    string
+		auth    string
+		creds   *Credentials
+		path    string
+		modify  func(reg *registry, ref *Reference)
+		want    []byte
+		wantErr string
+	}{
+		{
+			name: "anonymous",
+			path: "manifest.yaml",
+			want: file,
+		},
+		{
+			name:  "basic_auth",
+			auth:  "basic",
+			creds: creds,
+			path:  "manifest.yaml",
+			want:  file,
+		},
+		{
+			name:  "bearer_token",
+			auth:  "bearer",
+			creds: creds,
+			path:  "manifest.yaml",
+			want:  file,
+		},
+		{
+			name:    "basic_auth_without_credentials",
+			auth:    "basic",
+			path:    "manifest.yaml",
+			wantErr: "registry requires credentials",
+		},
+		{
+			name:    "bearer_token_invalid_credentials",
+			auth:    "bearer",
+			creds:   &Credentials{Username: "user", Password: "wrong"},
+			path:    "manifest.yaml",
+			wantErr: "token endpoint returned status 401",
+		},
+		{
+			name:    "file_not_found",
+			path:    "other.yaml",
+			wantErr: "file 'other.yaml' of",
+		},
+		{
+			name: "manifest_not_found",
+			path: "manifest.yaml",
+			modify: func(reg *registry, ref *Reference) {
+				ref.Digest = digest([]byte("other"))
+			},
+			wantErr: "not found",
+		},
+		{
+			name: "file_digest_mismatch",
+			path: "manifest.yaml",
+			modify: func(reg *registry, ref *Reference) {
+				reg.file = []byte("tampered")
+			},
+			wantErr: "digest mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newRegistry(t, tt.auth, file)
+			ref := reg.ref()
+			if tt.modify != nil {
+				tt.modify(reg, &ref)
+			}
+
+			got, err := NewHTTPClient(reg.srv.Client()).GetManifestFile(context.Background(), ref, tt.creds, tt.path)
+			if tt.wantErr != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Package outbound configures the proxy and CA bundle of the outbound
+// connections of the service, so Vault, git, Argo, notifications and the
+// other integrations reach their endpoints the same way.
+package outbound
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Config is the proxy and CA bundle of outbound connections. Unset proxies
+// fall back to the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+type Config struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// CABundleFile is a PEM file of certificates trusted in addition to the
+	// system roots, e.g. of an internal CA or a TLS intercepting proxy.
+	CABundleFile string
+}
+
+var (
+	mu        sync.RWMutex
+	transport = http.DefaultTransport.(*http.Transport).Clone()
+	rootCAs   *x509.CertPool
+)
+
+// Configure applies the config to the transports and clients returned by the
+// package. The proxies are also exported as the standard variables, for
+// clients created by libraries, e.g. the Kubernetes client of Argo.
+func Configure(c Config) error {
+	tr, pool, err := newTransport(c, httpproxy.FromEnvironment())
+	if err != nil {
+		return err
+	}
+
+	for k, v := range map[string]string{"HTTP_PROXY": c.HTTPProxy, "HTTPS_PROXY": c.HTTPSProxy, "NO_PROXY": c.NoProxy} {
+		if v == "" {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	transport = tr
+	rootCAs = pool
+	return nil
+}
+
+// newTransport returns a transport using the proxies of the config, falling
+// back to the proxies of the environment, and trusting its CA bundle.
+func newTransport(c Config, env *httpproxy.Config) (*http.Transport, *x509.CertPool, error) {
+	proxy := *env
+	if c.HTTPProxy != "" {
+		proxy.HTTPProxy = c.HTTPProxy
+	}
+	if c.HTTPSProxy != "" {
+		proxy.HTTPSProxy = c.HTTPSProxy
+	}
+	if c.NoProxy != "" {
+		proxy.NoProxy = c.NoProxy
+	}
+	proxyFn := proxy.ProxyFunc()
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxyFn(r.URL)
+	}
+
+	if c.CABundleFile == "" {
+		return tr, nil, nil
+	}
+	pool, err := readCABundle(c.CABundleFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return tr, pool, nil
+}
+
+// readCABundle returns the system roots with the certificates of the file.
+func readCABundle(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ca bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in ca bundle '%s'", file)
+	}
+	return pool, nil
+}
+
+// Transport returns a copy of the configured transport, for clients changing
+// it, e.g. the Vault client.
+func Transport() *http.Transport {
+	mu.RLock()
+	defer mu.RUnlock()
+	return transport.Clone()
+}
+
+// Client returns a client with the configured transport and the timeout, 0
+// for no timeout.
+func Client(timeout time.Duration) *http.Client {
+	mu.RLock()
+	defer mu.RUnlock()
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// RootCAs returns the system roots with the configured CA bundle, nil for
+// the system roots when there's no bundle.
+func RootCAs() *x509.CertPool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return rootCAs
+}
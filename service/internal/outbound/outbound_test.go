@@ -0,0 +1,74 @@
+package outbound
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http/httpproxy"
+)
+
+func TestNewTransportProxy(t *testing.T) {
+	env := &httpproxy.Config{HTTPProxy: "http://env-proxy:3128", HTTPSProxy: "http://env-proxy:3128"}
+	tr, pool, err := newTransport(Config{HTTPSProxy: "http://proxy:3128", NoProxy: "vault.internal"}, env)
+	require.Nil(t, err)
+	assert.Nil(t, pool)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://github.com/cello-proj/cello", want: "http://proxy:3128"},
+		{url: "http://prometheus:9090/api/v1/query", want: "http://env-proxy:3128"},
+		{url: "https://vault.internal:8200/v1/sys/health", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			require.Nil(t, err)
+
+			got, err := tr.Proxy(req)
+			require.Nil(t, err)
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestNewTransportCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	require.Nil(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+
+	_, err := (&http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}).Get(srv.URL)
+	require.NotNil(t, err)
+
+	tr, pool, err := newTransport(Config{CABundleFile: file}, &httpproxy.Config{})
+	require.Nil(t, err)
+	assert.NotNil(t, pool)
+
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewTransportCABundleErrors(t *testing.T) {
+	_, _, err := newTransport(Config{CABundleFile: filepath.Join(t.TempDir(), "missing.pem")}, &httpproxy.Config{})
+	assert.NotNil(t, err)
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	require.Nil(t, os.WriteFile(file, []byte("not a certificate"), 0600))
+	_, _, err = newTransport(Config{CABundleFile: file}, &httpproxy.Config{})
+	assert.EqualError(t, err, "no certificates in ca bundle '"+file+"'")
+}
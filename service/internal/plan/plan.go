@@ -0,0 +1,256 @@
+// Package plan reads the changes planned by diffs of the terraform and cdk
+// frameworks from their workflow logs: the machine readable output of
+// terraform plan -json when the image prints it, the human readable output of
+// terraform plan and cdk diff otherwise.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Frameworks whose plans are read.
+const (
+	Terraform = "terraform"
+	CDK       = "cdk"
+)
+
+// Actions of changes.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionReplace = "replace"
+)
+
+// Change is a planned change of a resource.
+type Change struct {
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	// Address is the terraform address of the resource, the stack and
+	// logical ID of cdk resources.
+	Address string `json:"address"`
+}
+
+// Summary is the summary of a plan. Replacements count as an add and a
+// destroy, like terraform counts them.
+type Summary struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+	// ResourceTypes are the number of changes by resource type.
+	ResourceTypes map[string]int `json:"resource_types"`
+	Changes       []Change       `json:"changes"`
+}
+
+// Supported returns whether the plans of the framework are read.
+func Supported(framework string) bool {
+	return framework == Terraform || framework == CDK
+}
+
+var (
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	terraformChangeRegex  = regexp.MustCompile(`# (\S+) (?:is tainted, so )?(will be created|will be updated in-place|will be destroyed|must be replaced)`)
+	terraformSummaryRegex = regexp.MustCompile(`Plan: \d+ to add|No changes\.`)
+	terraformActions      = map[string]string{
+		"will be created":          ActionCreate,
+		"will be updated in-place": ActionUpdate,
+		"will be destroyed":        ActionDelete,
+		"must be replaced":         ActionReplace,
+	}
+
+	cdkStackRegex        = regexp.MustCompile(`(?:^|: )Stack (\S+)\s*$`)
+	cdkChangeRegex       = regexp.MustCompile(`\[(\+|~|-)\] ((?:AWS|Custom|Alexa)::\S+) (.+)$`)
+	cdkNoDifferenceRegex = regexp.MustCompile(`There were no differences|Number of stacks with differences`)
+	cdkActions           = map[string]string{
+		"+": ActionCreate,
+		"~": ActionUpdate,
+		"-": ActionDelete,
+	}
+)
+
+// terraformJSONPrefix starts the messages of the machine readable UI of
+// terraform.
+const terraformJSONPrefix = `{"@level":`
+
+// FromLogs returns the summary of the plan in the workflow logs of the
+// framework, whose lines are prefixed by the pod name. False when there's
+// none, e.g. because the diff failed before planning.
+func FromLogs(framework string, lines []string) (Summary, bool, error) {
+	content := make([]string, 0, len(lines))
+	for _, line := range lines {
+		content = append(content, ansiRegex.ReplaceAllString(line, ""))
+	}
+
+	switch framework {
+	case Terraform:
+		if s, ok, err := fromTerraformJSON(content); ok || err != nil {
+			return s, ok, err
+		}
+		return fromTerraformText(content)
+	case CDK:
+		return fromCDKText(content)
+	default:
+		return Summary{}, false, fmt.Errorf("plans of framework '%s' aren't supported", framework)
+	}
+}
+
+// terraformMessage is a message of the machine readable UI of terraform.
+type terraformMessage struct {
+	Type   string `json:"type"`
+	Change struct {
+		Action   string `json:"action"`
+		Resource struct {
+			Addr         string `json:"addr"`
+			ResourceType string `json:"resource_type"`
+		} `json:"resource"`
+	} `json:"change"`
+}
+
+func fromTerraformJSON(lines []string) (Summary, bool, error) {
+	var changes []Change
+	found := false
+	for _, line := range lines {
+		idx := strings.Index(line, terraformJSONPrefix)
+		if idx < 0 {
+			continue
+		}
+
+		var m terraformMessage
+		if err := json.Unmarshal([]byte(line[idx:]), &m); err != nil {
+			return Summary{}, false, fmt.Errorf("invalid terraform plan output: %w", err)
+		}
+		switch m.Type {
+		case "planned_change":
+			switch m.Change.Action {
+			case ActionCreate, ActionUpdate, ActionDelete, ActionReplace:
+				changes = append(changes, Change{Action: m.Change.Action, ResourceType: m.Change.Resource.ResourceType, Address: m.Change.Resource.Addr})
+			}
+		case "change_summary":
+			found = true
+		}
+	}
+	if !found {
+		return Summary{}, false, nil
+	}
+	return newSummary(changes), true, nil
+}
+
+func fromTerraformText(lines []string) (Summary, bool, error) {
+	var changes []Change
+	found := false
+	for _, line := range lines {
+		if m := terraformChangeRegex.FindStringSubmatch(line); m != nil {
+			changes = append(changes, Change{Action: terraformActions[m[2]], ResourceType: terraformResourceType(m[1]), Address: m[1]})
+			continue
+		}
+		if terraformSummaryRegex.MatchString(line) {
+			found = true
+		}
+	}
+	if !found {
+		return Summary{}, false, nil
+	}
+	return newSummary(changes), true, nil
+}
+
+// terraformResourceType returns the type of the resource at the address, e.g.
+// aws_s3_bucket of module.web.aws_s3_bucket.logs[0].
+func terraformResourceType(address string) string {
+	parts := strings.Split(address, ".")
+	for len(parts) > 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	if parts[0] == "data" && len(parts) > 1 {
+		return parts[1]
+	}
+	return parts[0]
+}
+
+func fromCDKText(lines []string) (Summary, bool, error) {
+	var changes []Change
+	found := false
+	stack := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if m := cdkStackRegex.FindStringSubmatch(line); m != nil {
+			stack = m[1]
+			continue
+		}
+		if m := cdkChangeRegex.FindStringSubmatch(line); m != nil {
+			found = true
+			action := cdkActions[m[1]]
+			fields := strings.Fields(m[3])
+			if action == ActionUpdate && fields[len(fields)-1] == "replace" {
+				action = ActionReplace
+				fields = fields[:len(fields)-1]
+			}
+			address := fields[len(fields)-1]
+			if stack != "" {
+				address = stack + "/" + address
+			}
+			changes = append(changes, Change{Action: action, ResourceType: m[2], Address: address})
+			continue
+		}
+		if cdkNoDifferenceRegex.MatchString(line) {
+			found = true
+		}
+	}
+	if !found {
+		return Summary{}, false, nil
+	}
+	return newSummary(changes), true, nil
+}
+
+func newSummary(changes []Change) Summary {
+	s := Summary{ResourceTypes: map[string]int{}, Changes: []Change{}}
+	for _, c := range changes {
+		switch c.Action {
+		case ActionCreate:
+			s.Add++
+		case ActionUpdate:
+			s.Change++
+		case ActionDelete:
+			s.Destroy++
+		case ActionReplace:
+			s.Add++
+			s.Destroy++
+		}
+		s.ResourceTypes[c.ResourceType]++
+		s.Changes = append(s.Changes, c)
+	}
+	return s
+}
+
+// String returns the summary as text, the counts of changes by resource type
+// followed by a line per change.
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "plan: %d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)
+
+	types := make([]string, 0, len(s.ResourceTypes))
+	for t := range s.ResourceTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for i, t := range types {
+		if i == 0 {
+			b.WriteString(" (")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%d %s", s.ResourceTypes[t], t)
+	}
+	if len(types) > 0 {
+		b.WriteString(")")
+	}
+
+	for _, c := range s.Changes {
+		fmt.Fprintf(&b, "\n%s %s", c.Action, c.Address)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromLogs(t *testing.T) {
+	tests := []struct {
+		name      string
+		framework string
+		lines     []string
+		want      Summary
+		wantOK    bool
+		wantErr   string
+	}{
+		{
+			name:      "terraform_json",
+			framework: Terraform,
+			lines: []string{
+				`wf-1: {"@level":"info","@message":"Terraform 1.3.0","@module":"terraform.ui","type":"version","terraform":"1.3.0"}`,
+				`wf-1: {"@level":"info","@message":"aws_s3_bucket.logs: Plan to create","@module":"terraform.ui","type":"planned_change","change":{"resource":{"addr":"aws_s3_bucket.logs","resource_type":"aws_s3_bucket"},"action":"create"}}`,
+				`wf-1: {"@level":"info","@message":"aws_instance.web: Plan to replace","@module":"terraform.ui","type":"planned_change","change":{"resource":{"addr":"aws_instance.web","resource_type":"aws_instance"},"action":"replace"}}`,
+				`wf-1: {"@level":"info","@message":"data.aws_ami.ubuntu: Plan to read","@module":"terraform.ui","type":"planned_change","change":{"resource":{"addr":"data.aws_ami.ubuntu","resource_type":"aws_ami"},"action":"read"}}`,
+				`wf-1: {"@level":"info","@message":"Plan: 2 to add, 0 to change, 1 to destroy.","@module":"terraform.ui","type":"change_summary","changes":{"add":2,"change":0,"remove":1,"operation":"plan"}}`,
+			},
+			want: Summary{
+				Add:           2,
+				Destroy:       1,
+				ResourceTypes: map[string]int{"aws_s3_bucket": 1, "aws_instance": 1},
+				Changes: []Change{
+					{Action: ActionCreate, ResourceType: "aws_s3_bucket", Address: "aws_s3_bucket.logs"},
+					{Action: ActionReplace, ResourceType: "aws_instance", Address: "aws_instance.web"},
+				},
+			},
+			wantOK: true,
+		},
+		{
+			name:      "terraform_text",
+			framework: Terraform,
+			lines: []string{
+				"wf-1: Terraform will perform the following actions:",
+				"wf-1:   # module.web.aws_s3_bucket.logs[0] will be created",
+				`wf-1:   + resource "aws_s3_bucket" "logs" {`,
+				"wf-1:   # aws_iam_role.web will be updated in-place",
+				"wf-1:   # aws_instance.web is tainted, so must be replaced",
+				"wf-1:   # aws_sqs_queue.jobs will be destroyed",
+				"wf-1: \x1b[1mPlan:\x1b[0m 2 to add, 1 to change, 2 to destroy.",
+			},
+			want: Summary{
+				Add:           2,
+				Change:        1,
+				Destroy:       2,
+				ResourceTypes: map[string]int{"aws_s3_bucket": 1, "aws_iam_role": 1, "aws_instance": 1, "aws_sqs_queue": 1},
+				Changes: []Change{
+					{Action: ActionCreate, ResourceType: "aws_s3_bucket", Address: "module.web.aws_s3_bucket.logs[0]"},
+					{Action: ActionUpdate, ResourceType: "aws_iam_role", Address: "aws_iam_role.web"},
+					{Action: ActionReplace, ResourceType: "aws_instance", Address: "aws_instance.web"},
+					{Action: ActionDelete, ResourceType: "aws_sqs_queue", Address: "aws_sqs_queue.jobs"},
+				},
+			},
+			wantOK: true,
+		},
+		{
+			name:      "terraform_no_changes",
+			framework: Terraform,
+			lines:     []string{"wf-1: No changes. Your infrastructure matches the configuration."},
+			want:      Summary{ResourceTypes: map[string]int{}, Changes: []Change{}},
+			wantOK:    true,
+		},
+		{
+			name:      "terraform_no_plan",
+			framework: Terraform,
+			lines:     []string{"wf-1: Error: No configuration files"},
+		},
+		{
+			name:      "terraform_invalid_json",
+			framework: Terraform,
+			lines:     []string{`wf-1: {"@level":"info","type":`},
+			wantErr:   "invalid terraform plan output: unexpected end of JSON input",
+		},
+		{
+			name:      "cdk",
+			framework: CDK,
+			lines: []string{
+				"wf-1: Stack web",
+				"wf-1: Resources",
+				"wf-1: [+] AWS::S3::Bucket Logs LogsBucket6F5B1B2C ",
+				"wf-1: [~] AWS::Lambda::Function Handler Handler886CB40B replace",
+				"wf-1: [-] AWS::SQS::Queue Jobs Jobs1A2B3C4D",
+				"wf-1: [+] Parameter BootstrapVersion BootstrapVersion: {\"Type\":\"String\"}",
+				"wf-1: Number of stacks with differences: 1",
+			},
+			want: Summary{
+				Add:           2,
+				Destroy:       2,
+				ResourceTypes: map[string]int{"AWS::S3::Bucket": 1, "AWS::Lambda::Function": 1, "AWS::SQS::Queue": 1},
+				Changes: []Change{
+					{Action: ActionCreate, ResourceType: "AWS::S3::Bucket", Address: "web/LogsBucket6F5B1B2C"},
+					{Action: ActionReplace, ResourceType: "AWS::Lambda::Function", Address: "web/Handler886CB40B"},
+					{Action: ActionDelete, ResourceType: "AWS::SQS::Queue", Address: "web/Jobs1A2B3C4D"},
+				},
+			},
+			wantOK: true,
+		},
+		{
+			name:      "cdk_no_differences",
+			framework: CDK,
+			lines:     []string{"wf-1: Stack web", "wf-1: There were no differences"},
+			want:      Summary{ResourceTypes: map[string]int{}, Changes: []Change{}},
+			wantOK:    true,
+		},
+		{
+			name:      "unsupported_framework",
+			framework: "helm",
+			wantErr:   "plans of framework 'helm' aren't supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := FromLogs(tt.framework, tt.lines)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	s := Summary{
+		Add:           2,
+		Destroy:       1,
+		ResourceTypes: map[string]int{"aws_s3_bucket": 1, "aws_instance": 1},
+		Changes: []Change{
+			{Action: ActionCreate, ResourceType: "aws_s3_bucket", Address: "aws_s3_bucket.logs"},
+			{Action: ActionReplace, ResourceType: "aws_instance", Address: "aws_instance.web"},
+		},
+	}
+	assert.Equal(t, "plan: 2 to add, 0 to change, 1 to destroy (1 aws_instance, 1 aws_s3_bucket)\ncreate aws_s3_bucket.logs\nreplace aws_instance.web", s.String())
+}
@@ -0,0 +1,298 @@
+// Package recorder records sanitized request and response pairs of the API
+// into a directory, which can be replayed as contract tests.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/redact"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Bodies larger than this (e.g. log streams) aren't recorded.
+const maxBodySize = 1 << 20
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// Exchange is a recorded request and response pair.
+type Exchange struct {
+	Method string `json:"method"`
+	// Path includes the query of the request.
+	Path string `json:"path"`
+	// Authorization is the key of the authorization header, admin or user,
+	// the secret isn't recorded. Empty when the request had none.
+	Authorization string          `json:"authorization,omitempty"`
+	Request       json.RawMessage `json:"request,omitempty"`
+	Status        int             `json:"status"`
+	Response      json.RawMessage `json:"response,omitempty"`
+}
+
+// Recorder writes an Exchange file for each JSON request and response.
+type Recorder struct {
+	dir    string
+	logger log.Logger
+
+	mu  sync.Mutex
+	seq int
+}
+
+// New creates a Recorder writing into dir, which is created if missing.
+// Files are numbered after the ones already in dir.
+func New(dir string, logger log.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("unable to create record directory: %w", err)
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, logger: logger, seq: len(existing)}, nil
+}
+
+// Middleware records the requests handled by next. Recording failures are
+// logged and never affect the response.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil {
+			var err error
+			reqBody, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "error reading request data", http.StatusInternalServerError)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		if rw.overflow || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			return
+		}
+		e := Exchange{
+			Method:        r.Method,
+			Path:          r.URL.RequestURI(),
+			Authorization: authorizationKey(r.Header.Get("Authorization")),
+			Request:       Sanitize(reqBody),
+			Status:        rw.status,
+			Response:      Sanitize(rw.body.Bytes()),
+		}
+		if err := rec.write(e); err != nil {
+			level.Warn(rec.logger).Log("message", "error recording request", "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+func (rec *Recorder) write(e Exchange) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	rec.mu.Lock()
+	rec.seq++
+	name := fmt.Sprintf("%04d_%s_%s.json", rec.seq, strings.ToLower(e.Method), strings.Trim(unsafeChars.ReplaceAllString(strings.SplitN(e.Path, "?", 2)[0], "_"), "_"))
+	rec.mu.Unlock()
+
+	return ioutil.WriteFile(filepath.Join(rec.dir, name), append(data, '\n'), 0600)
+}
+
+// authorizationKey returns admin for admin authorization headers, user for
+// any other valid header.
+func authorizationKey(header string) string {
+	p, err := credentials.ParseAuthorization(header)
+	if err != nil {
+		return ""
+	}
+	if p.Vault != nil && p.Vault.Key == "admin" {
+		return "admin"
+	}
+	return "user"
+}
+
+// Sanitize returns the JSON body with the values of secret fields and the
+// secrets of other string values redacted, or nil when the body is empty or
+// not JSON.
+func Sanitize(body []byte) json.RawMessage {
+	var v interface{}
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &v) != nil {
+		return nil
+	}
+	data, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if _, ok := value.(string); ok && redact.Field(k) {
+				v[k] = redact.Redacted
+				continue
+			}
+			v[k] = redactValue(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	case string:
+		return redact.String(v)
+	}
+	return v
+}
+
+// Load reads the exchanges recorded in dir, in the order they were recorded.
+func Load(dir string) ([]Exchange, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	exchanges := make([]Exchange, 0, len(paths))
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var e Exchange
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("invalid exchange '%s': %w", filepath.Base(p), err)
+		}
+		exchanges = append(exchanges, e)
+	}
+	return exchanges, nil
+}
+
+// CheckShape returns an error naming the first difference between the
+// structure of two JSON documents, their fields and the types of values,
+// ignoring the values themselves. Arrays are compared by their first
+// element.
+func CheckShape(want, got []byte) error {
+	var w, g interface{}
+	if len(want) > 0 {
+		if err := json.Unmarshal(want, &w); err != nil {
+			return fmt.Errorf("invalid expected json: %w", err)
+		}
+	}
+	if len(bytes.TrimSpace(got)) > 0 {
+		if err := json.Unmarshal(got, &g); err != nil {
+			return fmt.Errorf("invalid json: %w", err)
+		}
+	}
+	return checkShape("$", w, g)
+}
+
+func checkShape(path string, want, got interface{}) error {
+	if kind(want) != kind(got) {
+		return fmt.Errorf("%s: expected %s, got %s", path, kind(want), kind(got))
+	}
+
+	switch want := want.(type) {
+	case map[string]interface{}:
+		got := got.(map[string]interface{})
+		for _, k := range sortedKeys(want) {
+			value, ok := got[k]
+			if !ok {
+				return fmt.Errorf("%s.%s: missing", path, k)
+			}
+			if err := checkShape(path+"."+k, want[k], value); err != nil {
+				return err
+			}
+		}
+		for _, k := range sortedKeys(got) {
+			if _, ok := want[k]; !ok {
+				return fmt.Errorf("%s.%s: unexpected", path, k)
+			}
+		}
+	case []interface{}:
+		got := got.([]interface{})
+		if len(want) > 0 && len(got) > 0 {
+			return checkShape(path+"[0]", want[0], got[0])
+		}
+	}
+	return nil
+}
+
+func kind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// responseWriter copies the response body while writing it.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	if !rw.overflow {
+		if rw.body.Len()+len(b) > maxBodySize {
+			rw.overflow = true
+			rw.body.Reset()
+		} else {
+			rw.body.Write(b)
+		}
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
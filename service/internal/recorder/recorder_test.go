@@ -0,0 +1,81 @@
+package recorder
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := New(dir, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("unable to create recorder: %v", err)
+	}
+
+	h := rec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "up 1")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"name":"project1","token":"vault:project1:s3cr3t"}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/projects?dry=true", strings.NewReader(`{"name":"project1","secrets":{"vault_password":"p4ss"}}`))
+	req.Header.Set("Authorization", "vault:admin:D34DB33FD34DB33F")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	exchanges, err := Load(dir)
+	assert.Nil(t, err)
+	if assert.Len(t, exchanges, 1) {
+		e := exchanges[0]
+		assert.Equal(t, "/projects?dry=true", e.Path)
+		assert.Equal(t, "admin", e.Authorization)
+		assert.Equal(t, http.StatusCreated, e.Status)
+		assert.JSONEq(t, `{"name":"project1","secrets":{"vault_password":"REDACTED"}}`, string(e.Request))
+		assert.JSONEq(t, `{"name":"project1","token":"REDACTED"}`, string(e.Response))
+	}
+
+	// Numbering continues after existing recordings.
+	rec, _ = New(dir, log.NewNopLogger())
+	assert.Nil(t, rec.write(Exchange{Method: http.MethodGet, Path: "/projects/project1", Status: http.StatusOK}))
+	exchanges, _ = Load(dir)
+	assert.Len(t, exchanges, 2)
+	assert.FileExists(t, dir+"/0002_get_projects_project1.json")
+}
+
+func TestCheckShape(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		got     string
+		wantErr string
+	}{
+		{name: "same shape", want: `{"name":"a","targets":[{"n":1}]}`, got: `{"targets":[{"n":2}],"name":"b"}`},
+		{name: "empty arrays", want: `[{"name":"a"}]`, got: `[]`},
+		{name: "empty bodies", want: ``, got: "\n"},
+		{name: "missing field", want: `{"name":"a","arn":"b"}`, got: `{"name":"a"}`, wantErr: "$.arn: missing"},
+		{name: "unexpected field", want: `{"name":"a"}`, got: `{"name":"a","arn":"b"}`, wantErr: "$.arn: unexpected"},
+		{name: "different type", want: `{"items":[{"n":1}]}`, got: `{"items":[{"n":"1"}]}`, wantErr: "$.items[0].n: expected number, got string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckShape([]byte(tt.want), []byte(tt.got))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
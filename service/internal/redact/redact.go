@@ -0,0 +1,144 @@
+// Package redact removes secrets from log entries, error messages and
+// recorded requests, so a careless error wrapping a token can't leak it.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+)
+
+// Redacted replaces secrets.
+const Redacted = "REDACTED"
+
+// Secrets shorter than this aren't registered, redacting them would mangle
+// unrelated text.
+const minSecretLength = 6
+
+// Fields with a name containing any of these hold secrets.
+var secretFields = []string{"secret", "password", "token", "credentials", "routing_key", "authorization", "private_key"}
+
+// Known secret formats, replaced by their replacement.
+var patterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	// vault:<key>:<secret> authorization headers, keeping the key.
+	{regexp.MustCompile(`\bvault:([^:\s"',]+):[^\s"',]+`), "vault:${1}:" + Redacted},
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-_.~+/]+=*`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)\b(hmac\s+[^:\s]+:[0-9]+:)[0-9a-f]+`), "${1}" + Redacted},
+	// Vault service, batch and recovery tokens, current and legacy formats.
+	{regexp.MustCompile(`\bhv[sbr]\.[A-Za-z0-9_\-]{20,}`), Redacted},
+	{regexp.MustCompile(`\b[sbr]\.[A-Za-z0-9]{24}\b`), Redacted},
+	// AWS access key IDs, e.g. pasted into policy documents.
+	{regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`), Redacted},
+	// <secret field>=<value> and "<secret field>": "<value>".
+	{regexp.MustCompile(`(?i)\b([a-z_]*(?:secret|password|token)[a-z_]*"?\s*[:=]\s*"?)[^"\s,}&]+`), "${1}" + Redacted},
+}
+
+// Field returns whether the field (e.g. a log key or JSON field) holds a
+// secret.
+func Field(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range secretFields {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// String redacts secrets of known formats from s.
+func String(s string) string {
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// Redactor redacts registered secret values, e.g. the admin secret, in
+// addition to secrets of known formats. A nil Redactor only redacts known
+// formats.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// New creates a Redactor of the secrets. Empty and short secrets are ignored.
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(secrets...)
+	return r
+}
+
+// Add registers secrets, e.g. ones obtained at runtime.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longest first so secrets containing others are fully redacted.
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// String redacts the registered secrets and secrets of known formats from s.
+func (r *Redactor) String(s string) string {
+	if r != nil {
+		r.mu.RLock()
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+		r.mu.RUnlock()
+	}
+	return String(s)
+}
+
+// Error returns err with a redacted message, or nil. The original error can
+// still be unwrapped.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Logger returns a logger redacting the values of secret keys and secrets
+// in string and error values before logging to next. Other values are left
+// alone as loggers like level filters depend on their type.
+func (r *Redactor) Logger(next log.Logger) log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		redacted := make([]interface{}, len(keyvals))
+		copy(redacted, keyvals)
+		for i := 1; i < len(redacted); i += 2 {
+			if k, ok := redacted[i-1].(string); ok && Field(k) {
+				redacted[i] = Redacted
+				continue
+			}
+			switch v := redacted[i].(type) {
+			case string:
+				redacted[i] = r.String(v)
+			case error:
+				redacted[i] = r.String(v.Error())
+			}
+		}
+		return next.Log(redacted...)
+	})
+}
@@ -0,0 +1,90 @@
+package rollout
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// References to the outputs of other steps in the values of a step, e.g.
+// '{{steps.project1/target1.outputs.vpc_id}}'.
+var outputReference = regexp.MustCompile(`\{\{\s*steps\.([^.{}\s]+)\.outputs\.([^.{}\s]+)\s*\}\}`)
+
+// Outputs are passed to commands unquoted, so chained values are restricted
+// to characters without a meaning to the shell.
+var chainedValue = regexp.MustCompile(`^[A-Za-z0-9_.:/=@+,-]*$`)
+
+// References returns the keys of the steps whose outputs the values
+// reference, sorted.
+func References(values map[string]string) []string {
+	keys := map[string]bool{}
+	for _, v := range values {
+		for _, m := range outputReference.FindAllStringSubmatch(v, -1) {
+			keys[m[1]] = true
+		}
+	}
+
+	refs := make([]string, 0, len(keys))
+	for k := range keys {
+		refs = append(refs, k)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// ValidateReferences checks the step only references the outputs of the
+// steps it depends on, which have succeeded when it's submitted.
+func (s Step) ValidateReferences(values map[string]string) error {
+	dependsOn := map[string]bool{}
+	for _, dep := range s.DependsOn {
+		dependsOn[dep] = true
+	}
+	for _, ref := range References(values) {
+		if !dependsOn[ref] {
+			return fmt.Errorf("step %s references outputs of step %s it doesn't depend on", s.Key(), ref)
+		}
+	}
+	return nil
+}
+
+// ResolveOutputs returns the values with their references replaced by the
+// outputs of the steps by key.
+func ResolveOutputs(values map[string]string, outputs map[string]map[string]string) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	// Sorted so the same error is returned whatever the map order.
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]string, len(values))
+	for _, k := range names {
+		v := values[k]
+		var err error
+		resolved[k] = outputReference.ReplaceAllStringFunc(v, func(ref string) string {
+			m := outputReference.FindStringSubmatch(ref)
+			out, ok := outputs[m[1]][m[2]]
+			if !ok {
+				if err == nil {
+					err = fmt.Errorf("step %s has no output '%s'", m[1], m[2])
+				}
+				return ref
+			}
+			if !chainedValue.MatchString(out) {
+				if err == nil {
+					err = fmt.Errorf("output '%s' of step %s has characters not allowed in chained values", m[2], m[1])
+				}
+				return ref
+			}
+			return out
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
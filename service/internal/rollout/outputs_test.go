@@ -0,0 +1,81 @@
+package rollout
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferences(t *testing.T) {
+	values := map[string]string{
+		"VPC_ID":    "{{steps.network/prod.outputs.vpc_id}}",
+		"SUBNETS":   "{{ steps.network/prod.outputs.subnet_a }},{{steps.dns/prod.outputs.zone_id}}",
+		"UNCHANGED": "{{workflow.parameters.project_name}}",
+	}
+	assert.Equal(t, []string{"dns/prod", "network/prod"}, References(values))
+	assert.Equal(t, []string{}, References(nil))
+}
+
+func TestValidateReferences(t *testing.T) {
+	s := Step{Project: "app", Target: "prod", DependsOn: []string{"network/prod"}}
+
+	assert.Nil(t, s.ValidateReferences(map[string]string{"VPC_ID": "{{steps.network/prod.outputs.vpc_id}}"}))
+	assert.EqualError(t, s.ValidateReferences(map[string]string{"ZONE_ID": "{{steps.dns/prod.outputs.zone_id}}"}),
+		"step app/prod references outputs of step dns/prod it doesn't depend on")
+}
+
+func TestResolveOutputs(t *testing.T) {
+	outputs := map[string]map[string]string{
+		"network/prod": {"vpc_id": "vpc-0123", "subnet_a": "subnet-a", "name": "prod; rm -rf /"},
+		"dns/prod":     nil,
+	}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name: "resolves references",
+			values: map[string]string{
+				"VPC_ID":  "{{steps.network/prod.outputs.vpc_id}}",
+				"SUBNETS": "{{ steps.network/prod.outputs.subnet_a }},subnet-b",
+				"REGION":  "us-west-2",
+			},
+			want: map[string]string{
+				"VPC_ID":  "vpc-0123",
+				"SUBNETS": "subnet-a,subnet-b",
+				"REGION":  "us-west-2",
+			},
+		},
+		{
+			name:   "nil values",
+			values: nil,
+			want:   nil,
+		},
+		{
+			name:    "missing output",
+			values:  map[string]string{"ZONE_ID": "{{steps.dns/prod.outputs.zone_id}}"},
+			wantErr: errors.New("step dns/prod has no output 'zone_id'"),
+		},
+		{
+			name:    "unsafe output",
+			values:  map[string]string{"NAME": "{{steps.network/prod.outputs.name}}"},
+			wantErr: errors.New("output 'name' of step network/prod has characters not allowed in chained values"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := ResolveOutputs(tt.values, outputs)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, resolved)
+		})
+	}
+}
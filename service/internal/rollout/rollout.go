@@ -0,0 +1,210 @@
+// Package rollout orders the steps of rollouts spanning several projects, a
+// DAG of workflow submissions to targets following the dependencies between
+// targets. Steps are submitted once all their dependencies succeeded, and
+// skipped when one of them failed, so independent branches carry on.
+package rollout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Statuses of steps.
+const (
+	StepPending   = "pending"
+	StepRunning   = "running"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// Statuses of rollouts. Rollouts with failed or skipped steps are failed,
+// reporting which of their steps succeeded.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrCycle is returned when dependencies form a cycle.
+var ErrCycle = errors.New("dependencies form a cycle")
+
+// Key identifies the target of a project in dependencies.
+func Key(project, target string) string {
+	return project + "/" + target
+}
+
+// Step is the submission of a workflow to a target, from the manifest of a
+// commit.
+type Step struct {
+	Project    string `json:"project"`
+	Target     string `json:"target"`
+	CommitHash string `json:"sha"`
+	Path       string `json:"path"`
+	// Name selects the workflow of a manifest with several.
+	Name string `json:"name,omitempty"`
+	// DependsOn are the keys of the steps which must succeed first.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Workflow is the workflow request of the manifest, validated when the
+	// rollout is created.
+	Workflow     json.RawMessage `json:"workflow,omitempty"`
+	Status       string          `json:"status"`
+	WorkflowName string          `json:"workflow_name,omitempty"`
+	Message      string          `json:"message,omitempty"`
+	// Outputs are the outputs the workflow of the step reported once it
+	// succeeded, which the steps depending on it can reference.
+	Outputs map[string]string `json:"outputs,omitempty"`
+}
+
+// Key returns the key of the target of the step.
+func (s Step) Key() string {
+	return Key(s.Project, s.Target)
+}
+
+// Plan returns the pending steps of the targets, depending on the steps of
+// the targets they depend on. Dependencies on targets without a step are
+// ignored, their targets being deployed already.
+func Plan(steps []Step, dependencies map[string][]string) ([]Step, error) {
+	keys := map[string]bool{}
+	for _, s := range steps {
+		if keys[s.Key()] {
+			return nil, fmt.Errorf("target %s has more than one step", s.Key())
+		}
+		keys[s.Key()] = true
+	}
+
+	planned := make([]Step, len(steps))
+	for i, s := range steps {
+		s.Status = StepPending
+		s.DependsOn = nil
+		for _, dep := range dependencies[s.Key()] {
+			if keys[dep] {
+				s.DependsOn = append(s.DependsOn, dep)
+			}
+		}
+		sort.Strings(s.DependsOn)
+		planned[i] = s
+	}
+
+	graph := map[string][]string{}
+	for _, s := range planned {
+		graph[s.Key()] = s.DependsOn
+	}
+	if cycle := FindCycle(graph); cycle != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCycle, strings.Join(cycle, " -> "))
+	}
+	return planned, nil
+}
+
+// FindCycle returns a cycle of the dependencies (key to the keys it depends
+// on), starting and ending with the same key, or nil when there is none.
+func FindCycle(dependencies map[string][]string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		switch state[key] {
+		case visiting:
+			for i, k := range path {
+				if k == key {
+					return append(append([]string(nil), path[i:]...), key)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		state[key] = visiting
+		path = append(path, key)
+		for _, dep := range dependencies[key] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+		return nil
+	}
+
+	// Sorted so the same cycle is reported whatever the map order.
+	keys := make([]string, 0, len(dependencies))
+	for k := range dependencies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if cycle := visit(k); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Advance skips the pending steps with a failed or skipped dependency, and
+// returns the indexes of the pending steps whose dependencies all succeeded,
+// to submit.
+func Advance(steps []Step) []int {
+	status := map[string]string{}
+	for _, s := range steps {
+		status[s.Key()] = s.Status
+	}
+
+	// Skipping a step can skip the steps depending on it, until none is.
+	for skipped := true; skipped; {
+		skipped = false
+		for i, s := range steps {
+			if s.Status != StepPending {
+				continue
+			}
+			for _, dep := range s.DependsOn {
+				if status[dep] == StepFailed || status[dep] == StepSkipped {
+					steps[i].Status = StepSkipped
+					steps[i].Message = fmt.Sprintf("dependency %s %s", dep, status[dep])
+					status[s.Key()] = StepSkipped
+					skipped = true
+					break
+				}
+			}
+		}
+	}
+
+	var ready []int
+	for i, s := range steps {
+		if s.Status != StepPending {
+			continue
+		}
+		succeeded := true
+		for _, dep := range s.DependsOn {
+			if status[dep] != StepSucceeded {
+				succeeded = false
+				break
+			}
+		}
+		if succeeded {
+			ready = append(ready, i)
+		}
+	}
+	return ready
+}
+
+// Status returns the status of a rollout of the steps.
+func Status(steps []Step) string {
+	status := StatusSucceeded
+	for _, s := range steps {
+		switch s.Status {
+		case StepPending, StepRunning:
+			return StatusRunning
+		case StepFailed, StepSkipped:
+			status = StatusFailed
+		}
+	}
+	return status
+}
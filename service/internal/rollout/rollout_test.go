@@ -0,0 +1,129 @@
+package rollout
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func statuses(steps []Step) map[string]string {
+	m := map[string]string{}
+	for _, s := range steps {
+		m[s.Key()] = s.Status
+	}
+	return m
+}
+
+func TestPlan(t *testing.T) {
+	steps := []Step{
+		{Project: "network", Target: "prod"},
+		{Project: "cluster", Target: "prod"},
+		{Project: "app", Target: "prod"},
+	}
+
+	tests := []struct {
+		name         string
+		steps        []Step
+		dependencies map[string][]string
+		want         map[string][]string
+		wantErr      string
+	}{
+		{
+			name:  "dependencies of steps",
+			steps: steps,
+			dependencies: map[string][]string{
+				"cluster/prod": {"network/prod"},
+				"app/prod":     {"cluster/prod", "dns/prod"},
+			},
+			want: map[string][]string{
+				"network/prod": nil,
+				"cluster/prod": {"network/prod"},
+				"app/prod":     {"cluster/prod"},
+			},
+		},
+		{
+			name:  "cycle",
+			steps: steps,
+			dependencies: map[string][]string{
+				"network/prod": {"app/prod"},
+				"cluster/prod": {"network/prod"},
+				"app/prod":     {"cluster/prod"},
+			},
+			wantErr: "dependencies form a cycle: app/prod -> cluster/prod -> network/prod -> app/prod",
+		},
+		{
+			name:    "duplicate target",
+			steps:   append(steps, Step{Project: "app", Target: "prod"}),
+			wantErr: "target app/prod has more than one step",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planned, err := Plan(tt.steps, tt.dependencies)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			got := map[string][]string{}
+			for _, s := range planned {
+				assert.Equal(t, StepPending, s.Status)
+				got[s.Key()] = s.DependsOn
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	assert.Nil(t, FindCycle(map[string][]string{"b/t": {"a/t"}, "c/t": {"a/t", "b/t"}}))
+	assert.Equal(t, []string{"a/t", "a/t"}, FindCycle(map[string][]string{"a/t": {"a/t"}}))
+
+	_, err := Plan([]Step{{Project: "a", Target: "t"}}, map[string][]string{"a/t": {"a/t"}})
+	assert.True(t, errors.Is(err, ErrCycle))
+}
+
+func TestAdvance(t *testing.T) {
+	// network <- cluster <- app, and dns independent of them.
+	steps, err := Plan([]Step{
+		{Project: "network", Target: "prod"},
+		{Project: "cluster", Target: "prod"},
+		{Project: "app", Target: "prod"},
+		{Project: "dns", Target: "prod"},
+	}, map[string][]string{
+		"cluster/prod": {"network/prod"},
+		"app/prod":     {"cluster/prod"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []int{0, 3}, Advance(steps))
+	steps[0].Status = StepRunning
+	steps[3].Status = StepRunning
+	assert.Empty(t, Advance(steps))
+	assert.Equal(t, StatusRunning, Status(steps))
+
+	steps[0].Status = StepSucceeded
+	assert.Equal(t, []int{1}, Advance(steps))
+
+	// A failure skips the steps depending on it, others carry on.
+	steps[1].Status = StepFailed
+	assert.Empty(t, Advance(steps))
+	assert.Equal(t, map[string]string{
+		"network/prod": StepSucceeded,
+		"cluster/prod": StepFailed,
+		"app/prod":     StepSkipped,
+		"dns/prod":     StepRunning,
+	}, statuses(steps))
+	assert.Equal(t, "dependency cluster/prod failed", steps[2].Message)
+	assert.Equal(t, StatusRunning, Status(steps))
+
+	steps[3].Status = StepSucceeded
+	assert.Equal(t, StatusFailed, Status(steps))
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, StatusSucceeded, Status([]Step{{Status: StepSucceeded}, {Status: StepSucceeded}}))
+	assert.Equal(t, StatusRunning, Status([]Step{{Status: StepSucceeded}, {Status: StepPending}}))
+	assert.Equal(t, StatusFailed, Status([]Step{{Status: StepSucceeded}, {Status: StepSkipped}}))
+}
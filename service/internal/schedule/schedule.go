@@ -0,0 +1,122 @@
+// Package schedule evaluates time windows submissions are allowed in, e.g.
+// the business hours of a team.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Timezones are loaded by name and the service image has no zoneinfo.
+	_ "time/tzdata"
+)
+
+// Days are the abbreviated weekday names used by windows.
+var Days = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// BusinessHours is a daily window (e.g. 09:00 to 16:00) on some weekdays in
+// a timezone.
+type BusinessHours struct {
+	location *time.Location
+	days     [7]bool
+	start    time.Duration
+	end      time.Duration
+	desc     string
+}
+
+// NewBusinessHours creates business hours in the timezone (e.g.
+// America/New_York) on the days (e.g. mon) from start until end (e.g. 09:00
+// and 16:00).
+func NewBusinessHours(timezone string, days []string, start, end string) (BusinessHours, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return BusinessHours{}, fmt.Errorf("invalid timezone '%s'", timezone)
+	}
+
+	b := BusinessHours{location: loc}
+	if len(days) == 0 {
+		return BusinessHours{}, fmt.Errorf("at least one day is required")
+	}
+	for _, d := range days {
+		i := dayIndex(d)
+		if i < 0 {
+			return BusinessHours{}, fmt.Errorf("invalid day '%s', must be one of '%s'", d, strings.Join(Days, " "))
+		}
+		b.days[i] = true
+	}
+
+	if b.start, err = parseClock(start); err != nil {
+		return BusinessHours{}, err
+	}
+	if b.end, err = parseClock(end); err != nil {
+		return BusinessHours{}, err
+	}
+	if b.start >= b.end {
+		return BusinessHours{}, fmt.Errorf("start '%s' must be before end '%s'", start, end)
+	}
+
+	var names []string
+	for i, ok := range b.days {
+		if ok {
+			names = append(names, strings.ToUpper(Days[i][:1])+Days[i][1:])
+		}
+	}
+	b.desc = fmt.Sprintf("%s %s-%s %s", strings.Join(names, ","), start, end, timezone)
+	return b, nil
+}
+
+func dayIndex(day string) int {
+	for i, d := range Days {
+		if strings.EqualFold(day, d) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock parses a time of day, e.g. 09:30.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', must be formatted like 09:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String describes the business hours, e.g. 'Mon,Tue 09:00-16:00 UTC'.
+func (b BusinessHours) String() string {
+	return b.desc
+}
+
+// Contains reports whether t is within the business hours.
+func (b BusinessHours) Contains(t time.Time) bool {
+	t = t.In(b.location)
+	if !b.days[t.Weekday()] {
+		return false
+	}
+	since := t.Sub(midnight(t))
+	return since >= b.start && since < b.end
+}
+
+// Next returns the start of the next window after t, in the timezone of the
+// business hours.
+func (b BusinessHours) Next(t time.Time) time.Time {
+	t = t.In(b.location)
+	for i := 0; i <= 7; i++ {
+		day := midnight(t).AddDate(0, 0, i)
+		if !b.days[day.Weekday()] {
+			continue
+		}
+		// Built from the wall clock so DST transitions keep the local time.
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, b.location).Add(b.start)
+		if start.After(t) {
+			return start
+		}
+	}
+	// Unreachable as there is at least one day.
+	return time.Time{}
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
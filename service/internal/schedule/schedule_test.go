@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBusinessHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		days     []string
+		start    string
+		end      string
+		wantErr  string
+		wantDesc string
+	}{
+		{
+			name:     "valid",
+			timezone: "America/New_York",
+			days:     []string{"mon", "tue", "wed", "thu"},
+			start:    "09:00",
+			end:      "16:00",
+			wantDesc: "Mon,Tue,Wed,Thu 09:00-16:00 America/New_York",
+		},
+		{
+			name:     "invalid timezone",
+			timezone: "Mars/Olympus",
+			days:     []string{"mon"},
+			start:    "09:00",
+			end:      "16:00",
+			wantErr:  "invalid timezone 'Mars/Olympus'",
+		},
+		{
+			name:     "invalid day",
+			timezone: "UTC",
+			days:     []string{"monday"},
+			start:    "09:00",
+			end:      "16:00",
+			wantErr:  "invalid day 'monday', must be one of 'sun mon tue wed thu fri sat'",
+		},
+		{
+			name:     "no days",
+			timezone: "UTC",
+			start:    "09:00",
+			end:      "16:00",
+			wantErr:  "at least one day is required",
+		},
+		{
+			name:     "invalid time",
+			timezone: "UTC",
+			days:     []string{"mon"},
+			start:    "9am",
+			end:      "16:00",
+			wantErr:  "invalid time '9am', must be formatted like 09:30",
+		},
+		{
+			name:     "end before start",
+			timezone: "UTC",
+			days:     []string{"mon"},
+			start:    "16:00",
+			end:      "09:00",
+			wantErr:  "start '16:00' must be before end '09:00'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBusinessHours(tt.timezone, tt.days, tt.start, tt.end)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantDesc, b.String())
+		})
+	}
+}
+
+func TestBusinessHours(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	b, err := NewBusinessHours("America/New_York", []string{"mon", "tue", "wed", "thu"}, "09:00", "16:00")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantContains bool
+		wantNext     time.Time
+	}{
+		{
+			name:         "within window",
+			now:          time.Date(2022, 3, 14, 10, 0, 0, 0, ny), // Monday
+			wantContains: true,
+			wantNext:     time.Date(2022, 3, 15, 9, 0, 0, 0, ny),
+		},
+		{
+			name:     "before window",
+			now:      time.Date(2022, 3, 14, 8, 59, 0, 0, ny),
+			wantNext: time.Date(2022, 3, 14, 9, 0, 0, 0, ny),
+		},
+		{
+			name:     "end is exclusive",
+			now:      time.Date(2022, 3, 14, 16, 0, 0, 0, ny),
+			wantNext: time.Date(2022, 3, 15, 9, 0, 0, 0, ny),
+		},
+		{
+			name:     "skips days without window",
+			now:      time.Date(2022, 3, 17, 17, 0, 0, 0, ny), // Thursday
+			wantNext: time.Date(2022, 3, 21, 9, 0, 0, 0, ny),
+		},
+		{
+			name:         "evaluated in the timezone",
+			now:          time.Date(2022, 3, 14, 13, 30, 0, 0, time.UTC),
+			wantContains: true,
+			wantNext:     time.Date(2022, 3, 15, 9, 0, 0, 0, ny),
+		},
+		{
+			name:     "across daylight saving time",
+			now:      time.Date(2022, 3, 10, 17, 0, 0, 0, ny), // Thursday before DST starts
+			wantNext: time.Date(2022, 3, 14, 9, 0, 0, 0, ny),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantContains, b.Contains(tt.now))
+			assert.True(t, tt.wantNext.Equal(b.Next(tt.now)), b.Next(tt.now))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package secretscan finds secrets, e.g. live AWS keys, in the values of
+// workflow requests before they're submitted. Rules are gitleaks style
+// regular expressions; the service config can add its own, and other
+// scanners can be plugged in through the Scanner interface.
+package secretscan
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Finding is a secret found in a field of a request.
+type Finding struct {
+	// Field is the field the secret was found in, e.g. 'parameters.foo'.
+	Field string
+	// Rule is the name of the rule matching the secret.
+	Rule string
+	// Secret is the secret itself, it must never be logged or returned, see
+	// Hash.
+	Secret string
+}
+
+// Scanner finds secrets in the value of a field.
+type Scanner interface {
+	Scan(field, value string) []Finding
+}
+
+// Rule matches secrets of a format. The secret is the first capture group of
+// the regular expression, the whole match when it has none.
+type Rule struct {
+	Name  string
+	Regex *regexp.Regexp
+}
+
+// defaultRules are the formats of secrets users are known to paste into
+// requests. Values of key value fields are scanned as 'key=value', so rules
+// can rely on the name of the key.
+var defaultRules = []Rule{
+	{Name: "aws-access-key-id", Regex: regexp.MustCompile(`\b((?:AKIA|ASIA)[A-Z0-9]{16})\b`)},
+	{Name: "aws-secret-access-key", Regex: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`)},
+	{Name: "github-token", Regex: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,255})\b`)},
+	{Name: "gcp-api-key", Regex: regexp.MustCompile(`\b(AIza[0-9A-Za-z_\-]{35})\b`)},
+	{Name: "private-key", Regex: regexp.MustCompile(`-----BEGIN[A-Z ]* PRIVATE KEY-----`)},
+	{Name: "slack-token", Regex: regexp.MustCompile(`\b(xox[baprs]-[A-Za-z0-9-]{10,})`)},
+	{Name: "vault-token", Regex: regexp.MustCompile(`\b(hv[sb]\.[A-Za-z0-9_\-]{20,})`)},
+}
+
+// DefaultRules returns the default rules.
+func DefaultRules() []Rule {
+	return append([]Rule(nil), defaultRules...)
+}
+
+// ParseRules returns the rules of regular expressions by name, sorted by
+// name, e.g. the rules of the service config.
+func ParseRules(rules map[string]string) ([]Rule, error) {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parsed := make([]Rule, 0, len(rules))
+	for _, name := range names {
+		re, err := regexp.Compile(rules[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret scan rule '%s': %w", name, err)
+		}
+		parsed = append(parsed, Rule{Name: name, Regex: re})
+	}
+	return parsed, nil
+}
+
+type regexScanner struct {
+	rules []Rule
+}
+
+// New returns a scanner of the rules.
+func New(rules ...Rule) Scanner {
+	return regexScanner{rules: rules}
+}
+
+// Scan returns a finding per match of each rule.
+func (s regexScanner) Scan(field, value string) []Finding {
+	var findings []Finding
+	for _, r := range s.rules {
+		for _, m := range r.Regex.FindAllStringSubmatch(value, -1) {
+			secret := m[0]
+			if len(m) > 1 && m[1] != "" {
+				secret = m[1]
+			}
+			findings = append(findings, Finding{Field: field, Rule: r.Name, Secret: secret})
+		}
+	}
+	return findings
+}
+
+// Hash returns the salted hash of a secret, so findings can be logged and
+// correlated without the secret.
+func Hash(salt []byte, secret string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
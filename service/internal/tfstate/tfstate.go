@@ -0,0 +1,104 @@
+// Package tfstate reads the terraform state locks runs failed to acquire from
+// their workflow logs, the lock info terraform prints with the error, as
+// text or in the diagnostics of its machine readable UI.
+package tfstate
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Lock is a lock of the terraform state of a target.
+type Lock struct {
+	ID        string
+	Path      string
+	Operation string
+	// Who holds the lock, user@host, the host being the pod of the run for
+	// workflows.
+	Who     string
+	Version string
+	// Created is zero when terraform's format can't be parsed.
+	Created time.Time
+}
+
+// createdLayout is the layout of the creation time of locks, the one of
+// time.Time.String.
+const createdLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+var (
+	ansiRegex      = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	lockErrorRegex = regexp.MustCompile(`Error acquiring the state lock`)
+	lockInfoRegex  = regexp.MustCompile(`(?:^|[\s│|])(ID|Path|Operation|Who|Version|Created):\s+(.*?)\s*$`)
+)
+
+// terraformJSONPrefix starts the messages of the machine readable UI of
+// terraform.
+const terraformJSONPrefix = `{"@level":`
+
+// diagnosticMessage is a diagnostic of the machine readable UI of terraform.
+type diagnosticMessage struct {
+	Type       string `json:"type"`
+	Diagnostic struct {
+		Summary string `json:"summary"`
+		Detail  string `json:"detail"`
+	} `json:"diagnostic"`
+}
+
+// LockFromLogs returns the lock of the state a run failed to acquire, from
+// its workflow logs. False when the run didn't fail to acquire it.
+func LockFromLogs(lines []string) (Lock, bool) {
+	var content []string
+	for _, line := range lines {
+		line = ansiRegex.ReplaceAllString(line, "")
+		idx := strings.Index(line, terraformJSONPrefix)
+		if idx < 0 {
+			content = append(content, line)
+			continue
+		}
+
+		// Unparsable messages aren't diagnostics of the lock.
+		var m diagnosticMessage
+		if err := json.Unmarshal([]byte(line[idx:]), &m); err != nil || m.Type != "diagnostic" {
+			continue
+		}
+		content = append(content, m.Diagnostic.Summary)
+		content = append(content, strings.Split(m.Diagnostic.Detail, "\n")...)
+	}
+
+	// Fields of locks other runs failed to acquire later are ignored.
+	found := false
+	fields := map[string]string{}
+	for _, line := range content {
+		if !found {
+			found = lockErrorRegex.MatchString(line)
+			continue
+		}
+		if m := lockInfoRegex.FindStringSubmatch(line); m != nil {
+			if _, ok := fields[m[1]]; !ok {
+				fields[m[1]] = m[2]
+			}
+		}
+	}
+
+	lock := Lock{
+		ID:        fields["ID"],
+		Path:      fields["Path"],
+		Operation: fields["Operation"],
+		Who:       fields["Who"],
+		Version:   fields["Version"],
+	}
+	if t, err := time.Parse(createdLayout, fields["Created"]); err == nil {
+		lock.Created = t.UTC()
+	}
+	return lock, lock.ID != ""
+}
+
+var lockIDRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]{0,127}$`)
+
+// ValidLockID returns whether the ID can be a lock ID, safe to pass to
+// terraform force-unlock in a shell. Lock IDs are UUIDs for most backends.
+func ValidLockID(id string) bool {
+	return lockIDRegex.MatchString(id)
+}
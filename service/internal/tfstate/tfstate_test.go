@@ -0,0 +1,86 @@
+package tfstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFromLogs(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		want   Lock
+		wantOK bool
+	}{
+		{
+			name: "text",
+			lines: []string{
+				"\x1b[31m╷\x1b[0m",
+				"\x1b[31m│\x1b[0m \x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mError acquiring the state lock\x1b[0m",
+				"│ Error message: ConditionalCheckFailedException: The conditional request failed",
+				"│ Lock Info:",
+				"│   ID:        9db590f1-b6fe-c5f2-2678-8804f089deba",
+				"│   Path:      tfstate-bucket/project1/terraform.tfstate",
+				"│   Operation: OperationTypeApply",
+				"│   Who:       root@project1-target1-abcde",
+				"│   Version:   1.3.0",
+				"│   Created:   2022-03-14 10:00:00.123456789 +0000 UTC",
+				"│   Info:      ",
+				"╵",
+			},
+			want: Lock{
+				ID:        "9db590f1-b6fe-c5f2-2678-8804f089deba",
+				Path:      "tfstate-bucket/project1/terraform.tfstate",
+				Operation: "OperationTypeApply",
+				Who:       "root@project1-target1-abcde",
+				Version:   "1.3.0",
+				Created:   time.Date(2022, 3, 14, 10, 0, 0, 123456789, time.UTC),
+			},
+			wantOK: true,
+		},
+		{
+			name: "json",
+			lines: []string{
+				`{"@level":"info","@message":"Terraform 1.3.0","@module":"terraform.ui","type":"version","terraform":"1.3.0"}`,
+				`{"@level":"error","@message":"Error: Error acquiring the state lock","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"Error acquiring the state lock","detail":"Error message: ConditionalCheckFailedException: The conditional request failed\nLock Info:\n  ID:        9db590f1-b6fe-c5f2-2678-8804f089deba\n  Path:      tfstate-bucket/project1/terraform.tfstate\n  Operation: OperationTypePlan\n  Who:       root@project1-target1-abcde\n  Version:   1.3.0\n  Created:   2022-03-14 10:00:00 +0000 UTC\n  Info:      \n"},"type":"diagnostic"}`,
+			},
+			want: Lock{
+				ID:        "9db590f1-b6fe-c5f2-2678-8804f089deba",
+				Path:      "tfstate-bucket/project1/terraform.tfstate",
+				Operation: "OperationTypePlan",
+				Who:       "root@project1-target1-abcde",
+				Version:   "1.3.0",
+				Created:   time.Date(2022, 3, 14, 10, 0, 0, 0, time.UTC),
+			},
+			wantOK: true,
+		},
+		{
+			name: "lock info without error",
+			lines: []string{
+				"  ID:        9db590f1-b6fe-c5f2-2678-8804f089deba",
+				"Apply complete! Resources: 1 added, 0 changed, 0 destroyed.",
+			},
+		},
+		{
+			name:  "other error",
+			lines: []string{"Error: No configuration files"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LockFromLogs(tt.lines)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidLockID(t *testing.T) {
+	assert.True(t, ValidLockID("9db590f1-b6fe-c5f2-2678-8804f089deba"))
+	assert.True(t, ValidLockID("1647252000123456"))
+	assert.False(t, ValidLockID("abc; rm -rf /"))
+	assert.False(t, ValidLockID(""))
+}
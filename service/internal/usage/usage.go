@@ -0,0 +1,159 @@
+// Package usage reports the Kubernetes resource usage of workflow pods by
+// the value of their cost labels, for chargeback.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Usage is the resource usage of the pods with a label value.
+type Usage struct {
+	Value          string
+	CPUCoreHours   float64
+	MemoryGiBHours float64
+}
+
+// Reporter reports resource usage.
+type Reporter interface {
+	// Report returns the usage during the window before now by value of the
+	// pod label, sorted by value.
+	Report(ctx context.Context, label string, window time.Duration) ([]Usage, error)
+}
+
+// Resolution of the memory samples integrated over the window.
+const memoryStep = 5 * time.Minute
+
+// PrometheusReporter reports the usage from the cAdvisor container metrics
+// and the kube-state-metrics pod labels of a Prometheus server. Pod labels
+// must be exported by kube-state-metrics (--metric-labels-allowlist).
+type PrometheusReporter struct {
+	address string
+	cl      *http.Client
+}
+
+// NewPrometheusReporter creates a PrometheusReporter for the Prometheus server
+// at address (e.g. http://prometheus:9090).
+func NewPrometheusReporter(address string, cl *http.Client) PrometheusReporter {
+	return PrometheusReporter{address: address, cl: cl}
+}
+
+// Report returns the usage during the window before now by value of the pod
+// label. Pods are attributed by the labels they had during the window.
+func (p PrometheusReporter) Report(ctx context.Context, label string, window time.Duration) ([]Usage, error) {
+	metricLabel := metricLabelName(label)
+	rng := promDuration(window)
+	podLabels := fmt.Sprintf(`max by (namespace, pod, %[1]s) (max_over_time(kube_pod_labels{%[1]s!=""}[%[2]s]))`, metricLabel, rng)
+
+	cpu := fmt.Sprintf(`sum by (%s) (sum by (namespace, pod) (increase(container_cpu_usage_seconds_total{container!="",container!="POD"}[%s])) * on (namespace, pod) group_left(%[1]s) %[3]s) / 3600`,
+		metricLabel, rng, podLabels)
+	memory := fmt.Sprintf(`sum by (%s) (sum_over_time(sum by (namespace, pod) (container_memory_working_set_bytes{container!="",container!="POD"})[%s:%s]) * on (namespace, pod) group_left(%[1]s) %[4]s) * %[5]g / 1073741824`,
+		metricLabel, rng, promDuration(memoryStep), podLabels, memoryStep.Hours())
+
+	cpuByValue, err := p.query(ctx, cpu, metricLabel)
+	if err != nil {
+		return nil, err
+	}
+	memoryByValue, err := p.query(ctx, memory, metricLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	byValue := map[string]*Usage{}
+	get := func(v string) *Usage {
+		if _, ok := byValue[v]; !ok {
+			byValue[v] = &Usage{Value: v}
+		}
+		return byValue[v]
+	}
+	for v, cores := range cpuByValue {
+		get(v).CPUCoreHours = cores
+	}
+	for v, gib := range memoryByValue {
+		get(v).MemoryGiBHours = gib
+	}
+
+	usage := make([]Usage, 0, len(byValue))
+	for _, u := range byValue {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Value < usage[j].Value })
+	return usage, nil
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// query returns the values of the series of an instant vector query by the
+// value of their label.
+func (p PrometheusReporter) query(ctx context.Context, query, label string) (map[string]float64, error) {
+	u := fmt.Sprintf("%s/api/v1/query?query=%s", p.address, url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create prometheus request: %w", err)
+	}
+
+	resp, err := p.cl.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("received error connecting to prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var pr prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("unable to decode prometheus response with code %d: %w", resp.StatusCode, err)
+	}
+
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s", pr.Error)
+	}
+	if pr.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("unsupported prometheus result type '%s'", pr.Data.ResultType)
+	}
+
+	values := map[string]float64{}
+	for _, s := range pr.Data.Result {
+		str, ok := s.Value[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid prometheus sample value '%v'", s.Value[1])
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prometheus sample value '%s': %w", str, err)
+		}
+		if math.IsNaN(v) {
+			continue
+		}
+		values[s.Metric[label]] = v
+	}
+	return values, nil
+}
+
+// metricLabelName returns the name of the kube_pod_labels label of a pod
+// label, e.g. 'label_cello_tenant' for 'cello-tenant'.
+func metricLabelName(label string) string {
+	return "label_" + strings.NewReplacer("-", "_", ".", "_", "/", "_").Replace(label)
+}
+
+// promDuration formats a duration as a Prometheus duration in whole minutes,
+// e.g. '1440m'.
+func promDuration(d time.Duration) string {
+	return fmt.Sprintf("%dm", int64(d/time.Minute))
+}
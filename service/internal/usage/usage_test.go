@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		cpuResponse string
+		memResponse string
+		want        []Usage
+		wantErr     string
+	}{
+		{
+			name:        "usage by value",
+			cpuResponse: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"label_cello_tenant":"team-b"},"value":[1435781451.781,"0.5"]},{"metric":{"label_cello_tenant":"team-a"},"value":[1435781451.781,"2.25"]}]}}`,
+			memResponse: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"label_cello_tenant":"team-a"},"value":[1435781451.781,"4"]},{"metric":{"label_cello_tenant":"team-c"},"value":[1435781451.781,"1"]}]}}`,
+			want: []Usage{
+				{Value: "team-a", CPUCoreHours: 2.25, MemoryGiBHours: 4},
+				{Value: "team-b", CPUCoreHours: 0.5},
+				{Value: "team-c", MemoryGiBHours: 1},
+			},
+		},
+		{
+			name:        "no usage",
+			cpuResponse: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			memResponse: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			want:        []Usage{},
+		},
+		{
+			name:        "error",
+			cpuResponse: `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			wantErr:     "prometheus query failed: parse error",
+		},
+		{
+			name:        "unsupported result type",
+			cpuResponse: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr:     "unsupported prometheus result type 'matrix'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/query", r.URL.Path)
+				query := r.URL.Query().Get("query")
+				assert.Contains(t, query, `max_over_time(kube_pod_labels{label_cello_tenant!=""}[1440m])`)
+				if strings.Contains(query, "container_cpu_usage_seconds_total") {
+					fmt.Fprint(w, tt.cpuResponse)
+					return
+				}
+				assert.Contains(t, query, "container_memory_working_set_bytes")
+				fmt.Fprint(w, tt.memResponse)
+			}))
+			defer srv.Close()
+
+			usage, err := NewPrometheusReporter(srv.URL, srv.Client()).Report(context.Background(), "cello-tenant", 24*time.Hour)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, usage)
+		})
+	}
+}
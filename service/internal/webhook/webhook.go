@@ -0,0 +1,112 @@
+// Package webhook delivers execution events to the callback URLs projects
+// registered, signing each delivery with the secret of its webhook.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Headers of deliveries.
+const (
+	HeaderEvent     = "X-Cello-Event"
+	HeaderDelivery  = "X-Cello-Delivery"
+	HeaderSignature = "X-Cello-Signature-256"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Payload is the JSON body of a delivery, an execution event.
+type Payload struct {
+	DeliveryID   string    `json:"delivery_id"`
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	TxID         string    `json:"txid,omitempty"`
+	Project      string    `json:"project"`
+	Target       string    `json:"target,omitempty"`
+	WorkflowName string    `json:"workflow_name,omitempty"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// Sign returns the signature of the body with the secret, the hex HMAC-SHA256
+// prefixed with its algorithm, e.g. "sha256=1a2b...".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches reports whether the event type matches the filters of a webhook.
+// Webhooks without filters match every event, filters ending with "*" match
+// event types with their prefix, e.g. "rollout_step_*".
+func Matches(filters []string, eventType string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f == eventType || (strings.HasSuffix(f, "*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the next attempt after the number of
+// failed attempts, doubling the initial backoff each attempt up to max.
+func Backoff(attempts int, initial, max time.Duration) time.Duration {
+	d := initial
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// Sender sends deliveries to callback URLs.
+type Sender struct {
+	cl *http.Client
+}
+
+// NewSender creates a sender using the client, which should have a timeout.
+func NewSender(cl *http.Client) *Sender {
+	return &Sender{cl: cl}
+}
+
+// Send posts the body of the delivery to the URL, signed with the secret. It
+// returns the status code of the response, any non 2xx status is an error.
+func (s *Sender) Send(ctx context.Context, url, secret, deliveryID, eventType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cello-webhook")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderSignature, Sign(secret, body))
+
+	resp, err := s.cl.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
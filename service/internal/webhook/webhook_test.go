@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '{"type":"workflow_submitted"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=2e0f3f420b698178c03ec6c54bdfc9be6ceb0adaf63d833434f4006955db2ab3", Sign("secret", []byte(`{"type":"workflow_submitted"}`)))
+	assert.NotEqual(t, Sign("secret", []byte("body")), Sign("other", []byte("body")))
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   []string
+		eventType string
+		want      bool
+	}{
+		{name: "no filters", eventType: "workflow_submitted", want: true},
+		{name: "exact", filters: []string{"execution_note_added", "workflow_submitted"}, eventType: "workflow_submitted", want: true},
+		{name: "prefix", filters: []string{"rollout_step_*"}, eventType: "rollout_step_failed", want: true},
+		{name: "wildcard", filters: []string{"*"}, eventType: "workflow_submitted", want: true},
+		{name: "no match", filters: []string{"rollout_step_*"}, eventType: "workflow_submitted", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Matches(tt.filters, tt.eventType))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1, 30*time.Second, time.Hour))
+	assert.Equal(t, 60*time.Second, Backoff(2, 30*time.Second, time.Hour))
+	assert.Equal(t, 240*time.Second, Backoff(4, 30*time.Second, time.Hour))
+	assert.Equal(t, time.Hour, Backoff(20, 30*time.Second, time.Hour))
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s := NewSender(srv.Client())
+	code, err := s.Send(context.Background(), srv.URL, "secret", "delivery-1", "workflow_submitted", []byte(`{"type":"workflow_submitted"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Equal(t, `{"type":"workflow_submitted"}`, string(body))
+	assert.Equal(t, "workflow_submitted", got.Header.Get(HeaderEvent))
+	assert.Equal(t, "delivery-1", got.Header.Get(HeaderDelivery))
+	assert.Equal(t, Sign("secret", body), got.Header.Get(HeaderSignature))
+
+	status = http.StatusServiceUnavailable
+	code, err = s.Send(context.Background(), srv.URL, "secret", "delivery-2", "workflow_submitted", []byte(`{}`))
+	assert.EqualError(t, err, "unexpected status 503")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
@@ -0,0 +1,210 @@
+package workflow
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	argoWorkflowArchiveAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflowarchive"
+	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mainLogsArtifact is the artifact Argo archives the logs of the main
+// container of a pod as, when archiving logs is enabled.
+const mainLogsArtifact = "main-logs"
+
+// archivePageSize is the number of archived workflows listed per page when
+// looking an archived workflow up by name.
+const archivePageSize = 100
+
+// maxArchivedLogLine is the size of the longest archived log line read,
+// longer lines fail reading the logs.
+const maxArchivedLogLine = 1024 * 1024
+
+// ErrLogsNotArchived is returned getting the logs of an archived workflow
+// whose logs weren't archived, or can't be read without an ArtifactReader.
+var ErrLogsNotArchived = errors.New("logs of the archived workflow are not archived")
+
+// ArtifactReader reads the output artifacts of archived workflows.
+type ArtifactReader interface {
+	OutputArtifact(ctx context.Context, uid, nodeID, artifactName string) (io.ReadCloser, error)
+}
+
+// WithArchive falls back to the workflow archive of Argo for workflows no
+// longer live, e.g. garbage collected once completed. Logs of archived
+// workflows are read with the artifacts reader, which can be nil.
+func WithArchive(archive argoWorkflowArchiveAPIClient.ArchivedWorkflowServiceClient, artifacts ArtifactReader) Option {
+	return func(a *ArgoWorkflow) {
+		a.archive = archive
+		a.artifacts = artifacts
+	}
+}
+
+// archivedWorkflow returns the most recent archived workflow of the name, nil
+// when there's none. The archive can't be filtered by name, so it's listed a
+// page at a time, the most recently started workflows first.
+func (a ArgoWorkflow) archivedWorkflow(ctx context.Context, workflowName string) (*argoWorkflowAPISpec.Workflow, error) {
+	opts := &metav1.ListOptions{FieldSelector: "metadata.namespace=" + a.namespace, Limit: archivePageSize}
+	for {
+		list, err := a.archive.ListArchivedWorkflows(ctx, &argoWorkflowArchiveAPIClient.ListArchivedWorkflowsRequest{ListOptions: opts})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range list.Items {
+			if item.Name == workflowName {
+				return a.archive.GetArchivedWorkflow(ctx, &argoWorkflowArchiveAPIClient.GetArchivedWorkflowRequest{Uid: string(item.UID)})
+			}
+		}
+
+		if list.Continue == "" {
+			return nil, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// listArchived returns a page of the archived workflows matching the label
+// selector, every one when the limit is zero, with the continue token of the
+// following page unless it's the last. Items only have their name, uid, phase
+// and start and finish times.
+func (a ArgoWorkflow) listArchived(ctx context.Context, labelSelector string, limit int64, token string) ([]argoWorkflowAPISpec.Workflow, string, error) {
+	list, err := a.archive.ListArchivedWorkflows(ctx, &argoWorkflowArchiveAPIClient.ListArchivedWorkflowsRequest{
+		ListOptions: &metav1.ListOptions{
+			FieldSelector: "metadata.namespace=" + a.namespace,
+			LabelSelector: labelSelector,
+			Limit:         limit,
+			Continue:      token,
+		},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return list.Items, list.Continue, nil
+}
+
+// archivedPods returns the pods of the workflow whose logs were archived, in
+// the order they started.
+func (a ArgoWorkflow) archivedPods(wf *argoWorkflowAPISpec.Workflow) ([]argoWorkflowAPISpec.NodeStatus, error) {
+	pods := podsByStart(wf, func(node argoWorkflowAPISpec.NodeStatus) bool {
+		return node.Outputs.GetArtifactByName(mainLogsArtifact) != nil
+	})
+	if a.artifacts == nil || len(pods) == 0 {
+		return nil, ErrLogsNotArchived
+	}
+	return pods, nil
+}
+
+// walkArchivedLogs calls fn with the lines of the archived logs of the pods
+// of the workflow until it returns false, read as they're streamed.
+func (a ArgoWorkflow) walkArchivedLogs(ctx context.Context, wf *argoWorkflowAPISpec.Workflow, fn func(line string) bool) error {
+	pods, err := a.archivedPods(wf)
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		podName := pod.ID
+		more, err := a.readArchivedLogs(ctx, string(wf.UID), podName, func(line string) bool {
+			return fn(fmt.Sprintf("%s: %s", podName, line))
+		})
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	return nil
+}
+
+// selectArchivedLogs adds the lines of the archived logs of the pods of the
+// workflow to the selector. Pods whose lines were all read are skipped
+// without reading their logs again.
+func (a ArgoWorkflow) selectArchivedLogs(ctx context.Context, wf *argoWorkflowAPISpec.Workflow, sel *lineSelector) error {
+	pods, err := a.archivedPods(wf)
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		if sel.positions[pod.ID].Done {
+			continue
+		}
+		add := sel.pod(pod.ID)
+		more, err := a.readArchivedLogs(ctx, string(wf.UID), pod.ID, func(line string) bool {
+			return add(time.Time{}, line)
+		})
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		sel.done(pod.ID)
+	}
+	return nil
+}
+
+// readArchivedLogs calls fn with the archived log lines of the pod, returning
+// false once fn did.
+func (a ArgoWorkflow) readArchivedLogs(ctx context.Context, uid, podName string, fn func(line string) bool) (bool, error) {
+	r, err := a.artifacts.OutputArtifact(ctx, uid, podName, mainLogsArtifact)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxArchivedLogLine)
+	for scanner.Scan() {
+		if !fn(scanner.Text()) {
+			return false, nil
+		}
+	}
+	return true, scanner.Err()
+}
+
+// HTTPArtifactReader reads artifacts from the artifact endpoints of the Argo
+// server.
+type HTTPArtifactReader struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPArtifactReader returns an ArtifactReader of the Argo server at the
+// URL, authorized with the token (e.g. ARGO_TOKEN) when set.
+func NewHTTPArtifactReader(baseURL, token string, client *http.Client) *HTTPArtifactReader {
+	return &HTTPArtifactReader{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, client: client}
+}
+
+// OutputArtifact returns the content of the output artifact of the node of
+// the archived workflow, which must be closed.
+func (h *HTTPArtifactReader) OutputArtifact(ctx context.Context, uid, nodeID, artifactName string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/artifacts-by-uid/%s/%s/%s", h.baseURL, url.PathEscape(uid), url.PathEscape(nodeID), url.PathEscape(artifactName))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status getting artifact '%s' of node '%s': %d", artifactName, nodeID, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,124 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	argoCronWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/cronworkflow"
+	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabelSchedule is added to cron workflows and the workflows they submit.
+const LabelSchedule = "cello-schedule"
+
+// CronWorkflow is a workflow Argo submits on a cron schedule.
+type CronWorkflow struct {
+	Name     string
+	Schedule string
+	// Timezone of the schedule, e.g. America/New_York. UTC when empty.
+	Timezone string
+	Suspend  bool
+	// From is the template workflows are submitted from, like Submit, e.g.
+	// workflowtemplate/name.
+	From       string
+	Parameters map[string]string
+	// Labels are added to the cron workflow and the workflows it submits.
+	Labels     map[string]string
+	Scheduling Scheduling
+}
+
+// CronWorkflows interface is used for managing cron workflows.
+type CronWorkflows interface {
+	// Apply creates the cron workflow or updates the existing one.
+	Apply(ctx context.Context, cw CronWorkflow) error
+	// Delete deletes the cron workflow, ignoring cron workflows that don't
+	// exist.
+	Delete(ctx context.Context, name string) error
+}
+
+// NewArgoCronWorkflows creates Argo cron workflows.
+func NewArgoCronWorkflows(cl argoCronWorkflowAPIClient.CronWorkflowServiceClient, n string) CronWorkflows {
+	return &ArgoCronWorkflows{
+		namespace: n,
+		svc:       cl,
+	}
+}
+
+// ArgoCronWorkflows represents Argo CronWorkflows.
+type ArgoCronWorkflows struct {
+	namespace string
+	svc       argoCronWorkflowAPIClient.CronWorkflowServiceClient
+}
+
+// Apply creates or updates a cron workflow.
+func (a ArgoCronWorkflows) Apply(ctx context.Context, cw CronWorkflow) error {
+	spec, err := newCronWorkflowSpec(cw)
+	if err != nil {
+		return err
+	}
+
+	existing, err := a.svc.GetCronWorkflow(ctx, &argoCronWorkflowAPIClient.GetCronWorkflowRequest{
+		Name:      cw.Name,
+		Namespace: a.namespace,
+	})
+	if status.Code(err) == codes.NotFound {
+		_, err = a.svc.CreateCronWorkflow(ctx, &argoCronWorkflowAPIClient.CreateCronWorkflowRequest{
+			Namespace: a.namespace,
+			CronWorkflow: &argoWorkflowAPISpec.CronWorkflow{
+				ObjectMeta: metav1.ObjectMeta{Name: cw.Name, Namespace: a.namespace, Labels: cw.Labels},
+				Spec:       spec,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create cron workflow: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get cron workflow: %w", err)
+	}
+
+	existing.Labels = cw.Labels
+	existing.Spec = spec
+	if _, err := a.svc.UpdateCronWorkflow(ctx, &argoCronWorkflowAPIClient.UpdateCronWorkflowRequest{
+		Namespace:    a.namespace,
+		CronWorkflow: existing,
+	}); err != nil {
+		return fmt.Errorf("failed to update cron workflow: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a cron workflow.
+func (a ArgoCronWorkflows) Delete(ctx context.Context, name string) error {
+	_, err := a.svc.DeleteCronWorkflow(ctx, &argoCronWorkflowAPIClient.DeleteCronWorkflowRequest{
+		Name:      name,
+		Namespace: a.namespace,
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to delete cron workflow: %w", err)
+	}
+	return nil
+}
+
+func newCronWorkflowSpec(cw CronWorkflow) (argoWorkflowAPISpec.CronWorkflowSpec, error) {
+	spec, err := newTemplateWorkflowSpec(cw.From, cw.Parameters, cw.Scheduling)
+	if err != nil {
+		return argoWorkflowAPISpec.CronWorkflowSpec{}, err
+	}
+	if pl := podLabels(cw.Labels); len(pl) > 0 {
+		spec.PodMetadata = &argoWorkflowAPISpec.Metadata{Labels: pl}
+	}
+
+	return argoWorkflowAPISpec.CronWorkflowSpec{
+		Schedule:          cw.Schedule,
+		Timezone:          cw.Timezone,
+		Suspend:           cw.Suspend,
+		ConcurrencyPolicy: argoWorkflowAPISpec.ForbidConcurrent,
+		WorkflowMetadata:  &metav1.ObjectMeta{Labels: cw.Labels},
+		WorkflowSpec:      spec,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	argoCronWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/cronworkflow"
+	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestArgoCronWorkflowsApply(t *testing.T) {
+	cw := CronWorkflow{
+		Name:       "project1-target1-nightly",
+		Schedule:   "0 2 * * *",
+		Timezone:   "America/New_York",
+		From:       "clusterworkflowtemplate/shared-deploy",
+		Parameters: map[string]string{"b": "2", "a": "1"},
+		Labels:     map[string]string{LabelSchedule: "nightly", LabelProject: "project1", LabelTenant: "team-a"},
+	}
+
+	t.Run("creates missing cron workflow", func(t *testing.T) {
+		cl := &mockArgoCronClient{getErr: status.Error(codes.NotFound, "not found")}
+		assert.Nil(t, NewArgoCronWorkflows(cl, "argo").Apply(context.Background(), cw))
+
+		if assert.NotNil(t, cl.created) {
+			assert.Equal(t, "project1-target1-nightly", cl.created.Name)
+			assert.Equal(t, "0 2 * * *", cl.created.Spec.Schedule)
+			assert.Equal(t, v1alpha1.ForbidConcurrent, cl.created.Spec.ConcurrencyPolicy)
+			assert.Equal(t, &v1alpha1.WorkflowTemplateRef{Name: "shared-deploy", ClusterScope: true}, cl.created.Spec.WorkflowSpec.WorkflowTemplateRef)
+			assert.Equal(t, "a", cl.created.Spec.WorkflowSpec.Arguments.Parameters[0].Name)
+			assert.Equal(t, "nightly", cl.created.Spec.WorkflowMetadata.Labels[LabelSchedule])
+			assert.Equal(t, map[string]string{LabelProject: "project1", LabelTenant: "team-a"}, cl.created.Spec.WorkflowSpec.PodMetadata.Labels)
+		}
+	})
+
+	t.Run("updates existing cron workflow", func(t *testing.T) {
+		cl := &mockArgoCronClient{existing: &v1alpha1.CronWorkflow{}}
+		cl.existing.ResourceVersion = "42"
+		suspended := cw
+		suspended.Suspend = true
+		assert.Nil(t, NewArgoCronWorkflows(cl, "argo").Apply(context.Background(), suspended))
+
+		if assert.NotNil(t, cl.updated) {
+			assert.Equal(t, "42", cl.updated.ResourceVersion)
+			assert.True(t, cl.updated.Spec.Suspend)
+		}
+	})
+
+	t.Run("get error", func(t *testing.T) {
+		cl := &mockArgoCronClient{getErr: fmt.Errorf("unavailable")}
+		assert.EqualError(t, NewArgoCronWorkflows(cl, "argo").Apply(context.Background(), cw), "failed to get cron workflow: unavailable")
+	})
+
+	t.Run("unsupported kind", func(t *testing.T) {
+		invalid := cw
+		invalid.From = "cronwf/other"
+		assert.EqualError(t, NewArgoCronWorkflows(&mockArgoCronClient{}, "argo").Apply(context.Background(), invalid), "workflows can't reference 'cronwf'")
+	})
+}
+
+func TestArgoCronWorkflowsDelete(t *testing.T) {
+	cl := &mockArgoCronClient{deleteErr: status.Error(codes.NotFound, "not found")}
+	assert.Nil(t, NewArgoCronWorkflows(cl, "argo").Delete(context.Background(), "missing"))
+
+	cl = &mockArgoCronClient{deleteErr: fmt.Errorf("unavailable")}
+	assert.EqualError(t, NewArgoCronWorkflows(cl, "argo").Delete(context.Background(), "cw"), "failed to delete cron workflow: unavailable")
+}
+
+type mockArgoCronClient struct {
+	argoCronWorkflowAPIClient.CronWorkflowServiceClient
+	existing  *v1alpha1.CronWorkflow
+	getErr    error
+	deleteErr error
+	created   *v1alpha1.CronWorkflow
+	updated   *v1alpha1.CronWorkflow
+}
+
+func (m *mockArgoCronClient) GetCronWorkflow(ctx context.Context, in *argoCronWorkflowAPIClient.GetCronWorkflowRequest, opts ...grpc.CallOption) (*v1alpha1.CronWorkflow, error) {
+	return m.existing, m.getErr
+}
+
+func (m *mockArgoCronClient) CreateCronWorkflow(ctx context.Context, in *argoCronWorkflowAPIClient.CreateCronWorkflowRequest, opts ...grpc.CallOption) (*v1alpha1.CronWorkflow, error) {
+	m.created = in.CronWorkflow
+	return in.CronWorkflow, nil
+}
+
+func (m *mockArgoCronClient) UpdateCronWorkflow(ctx context.Context, in *argoCronWorkflowAPIClient.UpdateCronWorkflowRequest, opts ...grpc.CallOption) (*v1alpha1.CronWorkflow, error) {
+	m.updated = in.CronWorkflow
+	return in.CronWorkflow, nil
+}
+
+func (m *mockArgoCronClient) DeleteCronWorkflow(ctx context.Context, in *argoCronWorkflowAPIClient.DeleteCronWorkflowRequest, opts ...grpc.CallOption) (*argoCronWorkflowAPIClient.CronWorkflowDeletedResponse, error) {
+	return &argoCronWorkflowAPIClient.CronWorkflowDeletedResponse{}, m.deleteErr
+}
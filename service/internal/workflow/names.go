@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultNameTemplate names workflows project-target-random, like before
+// name templates.
+const DefaultNameTemplate = "{{.Project}}-{{.Target}}"
+
+// MaxNamePrefixLength is the length of name prefixes leaving room for the 5
+// random characters Argo appends within the 63 characters Kubernetes allows
+// in the label values workflow names are used in.
+const MaxNamePrefixLength = 58
+
+// ErrNameTooLong is returned when the name prefix of a workflow exceeds
+// MaxNamePrefixLength.
+var ErrNameTooLong = errors.New("workflow name too long")
+
+var defaultNameTemplate = func() *NameTemplate {
+	n, err := NewNameTemplate(DefaultNameTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}()
+
+var (
+	invalidNameCharsRegex = regexp.MustCompile(`[^a-z0-9-]+`)
+	repeatedDashesRegex   = regexp.MustCompile(`-{2,}`)
+)
+
+// NameData is the data of name templates, read from the labels of workflows.
+type NameData struct {
+	Project string
+	Target  string
+	Type    string
+	// Date is the UTC date of the submission, e.g. 20220131.
+	Date string
+	// ShortSHA is the abbreviated commit hash of workflows created from git,
+	// empty otherwise.
+	ShortSHA string
+}
+
+// NameTemplate generates the name prefixes of workflows, to which Argo appends
+// random characters.
+type NameTemplate struct {
+	t *template.Template
+}
+
+// NewNameTemplate parses a name template, DefaultNameTemplate when empty.
+// Templates must use the project and target, so workflow names encode which
+// target they belong to.
+func NewNameTemplate(text string) (*NameTemplate, error) {
+	if text == "" {
+		text = DefaultNameTemplate
+	}
+
+	t, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow name template: %w", err)
+	}
+	n := &NameTemplate{t: t}
+
+	prefix, err := n.render(NameData{Project: "cellomarkerproject", Target: "cellomarkertarget"})
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow name template: %w", err)
+	}
+	if !strings.Contains(prefix, "cellomarkerproject") || !strings.Contains(prefix, "cellomarkertarget") {
+		return nil, errors.New("invalid workflow name template: must use {{.Project}} and {{.Target}}")
+	}
+	return n, nil
+}
+
+// Prefix returns the name prefix of a workflow with the labels submitted at
+// the time. It's lowercase with invalid characters replaced by dashes and
+// ends with a dash, or empty when the labels are.
+func (n *NameTemplate) Prefix(labels map[string]string, now time.Time) (string, error) {
+	sha := labels[LabelCommitHash]
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+
+	prefix, err := n.render(NameData{
+		Project:  labels[LabelProject],
+		Target:   labels[LabelTarget],
+		Type:     labels[LabelType],
+		Date:     now.UTC().Format("20060102"),
+		ShortSHA: sha,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(prefix) > MaxNamePrefixLength {
+		return "", fmt.Errorf("%w, '%s' exceeds %d characters", ErrNameTooLong, prefix, MaxNamePrefixLength)
+	}
+	return prefix, nil
+}
+
+func (n *NameTemplate) render(data NameData) (string, error) {
+	var b bytes.Buffer
+	if err := n.t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	prefix := invalidNameCharsRegex.ReplaceAllString(strings.ToLower(b.String()), "-")
+	prefix = repeatedDashesRegex.ReplaceAllString(prefix, "-")
+	prefix = strings.Trim(prefix, "-")
+	if prefix == "" {
+		// Argo names the workflow after its template.
+		return "", nil
+	}
+	return prefix + "-", nil
+}
@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNameTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "custom",
+			text: "cello-{{.Project}}-{{.Target}}-{{.Type}}-{{.Date}}-{{.ShortSHA}}",
+		},
+		{
+			name:    "missing_target",
+			text:    "{{.Project}}-{{.Date}}",
+			wantErr: "invalid workflow name template: must use {{.Project}} and {{.Target}}",
+		},
+		{
+			name:    "unknown_field",
+			text:    "{{.Project}}-{{.Target}}-{{.Branch}}",
+			wantErr: `invalid workflow name template: template: name:1:27: executing "name" at <.Branch>: can't evaluate field Branch in type workflow.NameData`,
+		},
+		{
+			name:    "invalid",
+			text:    "{{.Project}",
+			wantErr: `invalid workflow name template: template: name:1: bad character U+007D '}'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNameTemplate(tt.text)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestNameTemplatePrefix(t *testing.T) {
+	now := time.Date(2022, 1, 31, 23, 0, 0, 0, time.FixedZone("PST", -8*3600))
+	custom := "cello-{{.Project}}-{{.Target}}-{{.Type}}-{{.Date}}-{{.ShortSHA}}"
+
+	tests := []struct {
+		name    string
+		text    string
+		labels  map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "default",
+			labels: map[string]string{LabelProject: "project1", LabelTarget: "target1"},
+			want:   "project1-target1-",
+		},
+		{
+			name:   "custom",
+			text:   custom,
+			labels: map[string]string{LabelProject: "project1", LabelTarget: "target1", LabelType: "sync", LabelCommitHash: "4b825dc642cb6eb9a060e54bf8d69288fbee4904"},
+			want:   "cello-project1-target1-sync-20220201-4b825dc-",
+		},
+		{
+			name:   "custom_without_commit",
+			text:   custom,
+			labels: map[string]string{LabelProject: "project1", LabelTarget: "target1", LabelType: "diff"},
+			want:   "cello-project1-target1-diff-20220201-",
+		},
+		{
+			name:   "invalid_characters",
+			labels: map[string]string{LabelProject: "Project1", LabelTarget: "TARGET_EXISTS"},
+			want:   "project1-target-exists-",
+		},
+		{
+			name:    "too_long",
+			text:    custom,
+			labels:  map[string]string{LabelProject: "project1project1project1", LabelTarget: "target1target1target1", LabelType: "sync"},
+			wantErr: ErrNameTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewNameTemplate(tt.text)
+			assert.Nil(t, err)
+
+			got, err := n.Prefix(tt.labels, now)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestArgoSubmitNameTemplate(t *testing.T) {
+	n, err := NewNameTemplate("cello-{{.Project}}-{{.Target}}-{{.Type}}")
+	assert.Nil(t, err)
+
+	cl := &mockCreateArgoClient{}
+	name, err := NewArgoWorkflow(cl, "argo", WithNameTemplate(n)).Submit(context.Background(), "workflowtemplate/deploy",
+		map[string]string{"project_name": "project1", "target_name": "target1"}, map[string]string{LabelType: "sync"}, nil, Scheduling{Platform: "linux/amd64"})
+	assert.Nil(t, err)
+	assert.Equal(t, "cello-project1-target1-sync-abcde", name)
+}
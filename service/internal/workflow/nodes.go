@@ -0,0 +1,211 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/argoproj/argo-workflows/v3/persist/sqldb"
+	argoWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
+	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// ErrNodesOffloaded is returned getting the nodes of a workflow whose node
+// status Argo offloaded to its database, which can't be read without an
+// OffloadedNodesReader.
+var ErrNodesOffloaded = errors.New("nodes of the workflow are offloaded to the argo database")
+
+// withoutNodes are the fields of workflows read without their nodes.
+const withoutNodes = "-status.nodes"
+
+// OffloadedNodesReader reads the nodes of workflows whose node status Argo
+// offloaded to its database, e.g. workflows with hundreds of nodes which
+// exceed the size of Kubernetes objects.
+type OffloadedNodesReader interface {
+	OffloadedNodes(ctx context.Context, namespace, workflowName string) (argoWorkflowAPISpec.Nodes, error)
+}
+
+// WithOffloadedNodes reads the nodes of workflows Argo offloaded to its
+// database with the reader. Without, they're read without their nodes.
+func WithOffloadedNodes(r OffloadedNodesReader) Option {
+	return func(a *ArgoWorkflow) {
+		a.offloaded = r
+	}
+}
+
+// Node is a node of a workflow, e.g. a step or the pod running it.
+type Node struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	DisplayName  string `json:"display_name"`
+	Type         string `json:"type"`
+	TemplateName string `json:"template_name"`
+	Phase        string `json:"phase"`
+	Message      string `json:"message"`
+	// Started and Finished are unix timestamps, empty until the node started
+	// or finished.
+	Started  string `json:"started"`
+	Finished string `json:"finished"`
+}
+
+// Nodes represents a page of the nodes of a workflow.
+type Nodes struct {
+	Nodes []Node `json:"nodes"`
+	// Next is the offset of the nodes following the page, zero on the last
+	// one.
+	Next int `json:"-"`
+}
+
+// NodeOptions selects a page of the nodes of a workflow, the zero value
+// selects every node.
+type NodeOptions struct {
+	// Offset is the number of nodes skipped.
+	Offset int
+	// Limit is the maximum number of nodes of the page, unlimited when zero.
+	Limit int
+}
+
+// PageNodes returns the page of the nodes selected by the options.
+func PageNodes(nodes []Node, opts NodeOptions) *Nodes {
+	if opts.Offset >= len(nodes) {
+		return &Nodes{Nodes: []Node{}}
+	}
+	page := &Nodes{Nodes: nodes[opts.Offset:]}
+	if opts.Limit > 0 && len(page.Nodes) > opts.Limit {
+		page.Nodes = page.Nodes[:opts.Limit]
+		page.Next = opts.Offset + opts.Limit
+	}
+	return page
+}
+
+// newNodes returns the nodes of the workflow in the order they started, the
+// ones which haven't started last.
+func newNodes(workflow *argoWorkflowAPISpec.Workflow) []Node {
+	statuses := make([]argoWorkflowAPISpec.NodeStatus, 0, len(workflow.Status.Nodes))
+	for _, n := range workflow.Status.Nodes {
+		statuses = append(statuses, n)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		si, sj := statuses[i].StartedAt, statuses[j].StartedAt
+		if si.IsZero() != sj.IsZero() {
+			return sj.IsZero()
+		}
+		if !si.Equal(&sj) {
+			return si.Before(&sj)
+		}
+		return statuses[i].ID < statuses[j].ID
+	})
+
+	nodes := make([]Node, 0, len(statuses))
+	for _, n := range statuses {
+		node := Node{
+			ID:           n.ID,
+			Name:         n.Name,
+			DisplayName:  n.DisplayName,
+			Type:         string(n.Type),
+			TemplateName: n.TemplateName,
+			Phase:        strings.ToLower(string(n.Phase)),
+			Message:      n.Message,
+		}
+		if !n.StartedAt.IsZero() {
+			node.Started = fmt.Sprint(n.StartedAt.Unix())
+		}
+		if !n.FinishedAt.IsZero() {
+			node.Finished = fmt.Sprint(n.FinishedAt.Unix())
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Nodes returns the page of the nodes of a workflow selected by the options,
+// of the archived workflow when it's no longer live.
+func (a ArgoWorkflow) Nodes(ctx context.Context, workflowName string, opts NodeOptions) (*Nodes, error) {
+	workflow, err := a.workflow(ctx, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.Status.IsOffloadNodeStatus() {
+		return nil, ErrNodesOffloaded
+	}
+	return PageNodes(newNodes(workflow), opts), nil
+}
+
+// getWorkflow returns the live workflow. The nodes Argo offloaded to its
+// database can't be read through the Kubernetes API, those workflows are
+// read without them and their nodes read with the OffloadedNodesReader.
+// Without a reader, the offload node status version of the workflow is
+// kept.
+func (a ArgoWorkflow) getWorkflow(ctx context.Context, workflowName, fields string) (*argoWorkflowAPISpec.Workflow, error) {
+	workflow, err := a.svc.GetWorkflow(ctx, &argoWorkflowAPIClient.WorkflowGetRequest{
+		Name:      workflowName,
+		Namespace: a.namespace,
+		Fields:    fields,
+	})
+	if !errors.Is(err, sqldb.OffloadNotSupportedError) {
+		return workflow, err
+	}
+
+	workflow, err = a.svc.GetWorkflow(ctx, &argoWorkflowAPIClient.WorkflowGetRequest{
+		Name:      workflowName,
+		Namespace: a.namespace,
+		Fields:    withoutNodes,
+	})
+	if err != nil || a.offloaded == nil {
+		return workflow, err
+	}
+
+	nodes, err := a.offloaded.OffloadedNodes(ctx, a.namespace, workflowName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read offloaded nodes: %w", err)
+	}
+	workflow.Status.Nodes = nodes
+	workflow.Status.OffloadNodeStatusVersion = ""
+	return workflow, nil
+}
+
+// HTTPNodeReader reads offloaded nodes from the workflow endpoint of the
+// Argo server, which reads them from the database of Argo.
+type HTTPNodeReader struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPNodeReader returns an OffloadedNodesReader of the Argo server at the
+// URL, authorized with the token (e.g. ARGO_TOKEN) when set.
+func NewHTTPNodeReader(baseURL, token string, client *http.Client) *HTTPNodeReader {
+	return &HTTPNodeReader{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, client: client}
+}
+
+// OffloadedNodes returns the nodes of the workflow.
+func (h *HTTPNodeReader) OffloadedNodes(ctx context.Context, namespace, workflowName string) (argoWorkflowAPISpec.Nodes, error) {
+	u := fmt.Sprintf("%s/api/v1/workflows/%s/%s?fields=status.nodes", h.baseURL, url.PathEscape(namespace), url.PathEscape(workflowName))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status getting nodes of workflow '%s': %d", workflowName, resp.StatusCode)
+	}
+
+	var workflow argoWorkflowAPISpec.Workflow
+	if err := json.NewDecoder(resp.Body).Decode(&workflow); err != nil {
+		return nil, err
+	}
+	return workflow.Status.Nodes, nil
+}
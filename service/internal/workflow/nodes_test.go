@@ -0,0 +1,183 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj/argo-workflows/v3/persist/sqldb"
+	argoWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
+	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testNodes = v1alpha1.Nodes{
+	"wf-3": {ID: "wf-3", Name: "wf[1].apply", DisplayName: "apply", Type: v1alpha1.NodeTypePod, Phase: v1alpha1.NodePending},
+	"wf-2": {ID: "wf-2", Name: "wf[0].plan", DisplayName: "plan", Type: v1alpha1.NodeTypePod, Phase: v1alpha1.NodeSucceeded, StartedAt: v1.Unix(20, 0), FinishedAt: v1.Unix(30, 0)},
+	"wf":   {ID: "wf", Name: "wf", DisplayName: "wf", Type: v1alpha1.NodeTypeSteps, TemplateName: "main", Phase: v1alpha1.NodeRunning, StartedAt: v1.Unix(10, 0)},
+}
+
+// offloadingArgoClient is an Argo client in Kubernetes mode, which can't get
+// workflows whose node status is offloaded unless their nodes are excluded.
+type offloadingArgoClient struct {
+	argoWorkflowAPIClient.WorkflowServiceClient
+	offloaded bool
+	fields    *[]string
+}
+
+func (m offloadingArgoClient) GetWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowGetRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
+	*m.fields = append(*m.fields, in.Fields)
+	wf := &v1alpha1.Workflow{
+		ObjectMeta: v1.ObjectMeta{Name: in.Name},
+		Status:     v1alpha1.WorkflowStatus{Phase: v1alpha1.WorkflowRunning, Nodes: testNodes},
+	}
+	if !m.offloaded {
+		return wf, nil
+	}
+	if in.Fields != withoutNodes {
+		return nil, sqldb.OffloadNotSupportedError
+	}
+	wf.Status.Nodes = nil
+	wf.Status.OffloadNodeStatusVersion = "fnv:1"
+	return wf, nil
+}
+
+type mockNodeReader struct {
+	nodes v1alpha1.Nodes
+	err   error
+}
+
+func (m mockNodeReader) OffloadedNodes(ctx context.Context, namespace, workflowName string) (v1alpha1.Nodes, error) {
+	return m.nodes, m.err
+}
+
+func TestArgoNodes(t *testing.T) {
+	all := []Node{
+		{ID: "wf", Name: "wf", DisplayName: "wf", Type: "Steps", TemplateName: "main", Phase: "running", Started: "10"},
+		{ID: "wf-2", Name: "wf[0].plan", DisplayName: "plan", Type: "Pod", Phase: "succeeded", Started: "20", Finished: "30"},
+		{ID: "wf-3", Name: "wf[1].apply", DisplayName: "apply", Type: "Pod", Phase: "pending"},
+	}
+
+	tests := []struct {
+		name       string
+		offloaded  bool
+		reader     OffloadedNodesReader
+		opts       NodeOptions
+		want       *Nodes
+		wantErr    error
+		wantFields []string
+	}{
+		{
+			name:       "all nodes",
+			want:       &Nodes{Nodes: all},
+			wantFields: []string{""},
+		},
+		{
+			name:       "first page",
+			opts:       NodeOptions{Limit: 2},
+			want:       &Nodes{Nodes: all[:2], Next: 2},
+			wantFields: []string{""},
+		},
+		{
+			name:       "last page",
+			opts:       NodeOptions{Offset: 2, Limit: 2},
+			want:       &Nodes{Nodes: all[2:]},
+			wantFields: []string{""},
+		},
+		{
+			name:       "offset past the nodes",
+			opts:       NodeOptions{Offset: 5},
+			want:       &Nodes{Nodes: []Node{}},
+			wantFields: []string{""},
+		},
+		{
+			name:       "offloaded nodes",
+			offloaded:  true,
+			reader:     mockNodeReader{nodes: testNodes},
+			opts:       NodeOptions{Offset: 1, Limit: 1},
+			want:       &Nodes{Nodes: all[1:2], Next: 2},
+			wantFields: []string{"", withoutNodes},
+		},
+		{
+			name:       "offloaded nodes without reader",
+			offloaded:  true,
+			wantErr:    ErrNodesOffloaded,
+			wantFields: []string{"", withoutNodes},
+		},
+		{
+			name:       "offloaded nodes reader error",
+			offloaded:  true,
+			reader:     mockNodeReader{err: errors.New("unavailable")},
+			wantErr:    errors.New("unable to read offloaded nodes: unavailable"),
+			wantFields: []string{"", withoutNodes},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			opts := []Option{}
+			if tt.reader != nil {
+				opts = append(opts, WithOffloadedNodes(tt.reader))
+			}
+			argoWf := NewArgoWorkflow(offloadingArgoClient{offloaded: tt.offloaded, fields: &fields}, "namespace", opts...)
+
+			got, err := argoWf.Nodes(context.Background(), "wf", tt.opts)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("\nwant: %v\n got: %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !cmp.Equal(got, tt.want) {
+				t.Errorf("\nwant: %v\n got: %v", tt.want, got)
+			}
+			if !cmp.Equal(fields, tt.wantFields) {
+				t.Errorf("\nwant: %v\n got: %v", tt.wantFields, fields)
+			}
+		})
+	}
+}
+
+func TestArgoStatusOffloaded(t *testing.T) {
+	var fields []string
+	argoWf := NewArgoWorkflow(offloadingArgoClient{offloaded: true, fields: &fields}, "namespace")
+
+	got, err := argoWf.Status(context.Background(), "wf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "running" {
+		t.Errorf("\nwant: %v\n got: %v", "running", got.Status)
+	}
+}
+
+func TestHTTPNodeReader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/workflows/namespace/wf" || r.URL.Query().Get("fields") != "status.nodes" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"status":{"nodes":{"wf":{"id":"wf","name":"wf","type":"Steps","phase":"Running"}}}}`)
+	}))
+	defer srv.Close()
+
+	reader := NewHTTPNodeReader(srv.URL+"/", "Bearer token", srv.Client())
+	got, err := reader.OffloadedNodes(context.Background(), "namespace", "wf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := v1alpha1.Nodes{"wf": {ID: "wf", Name: "wf", Type: v1alpha1.NodeTypeSteps, Phase: v1alpha1.NodeRunning}}
+	if !cmp.Equal(got, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, got)
+	}
+
+	if _, err := reader.OffloadedNodes(context.Background(), "namespace", "other"); err == nil {
+		t.Error("expected error, received nil")
+	}
+}
@@ -0,0 +1,31 @@
+package workflow
+
+import (
+	"strings"
+
+	"github.com/cello-proj/cello/internal/types"
+)
+
+// OriginAnnotations returns the annotations of the origin of a workflow, see
+// AnnotationOriginPrefix.
+func OriginAnnotations(o types.Origin) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range o.Fields() {
+		annotations[AnnotationOriginPrefix+strings.ReplaceAll(k, "_", "-")] = v
+	}
+	return annotations
+}
+
+// Origin returns the origin of a workflow from its annotations, nil when it
+// has none.
+func Origin(annotations map[string]string) *types.Origin {
+	o := types.Origin{
+		CIJobURL:    annotations[AnnotationOriginPrefix+"ci-job-url"],
+		TicketID:    annotations[AnnotationOriginPrefix+"ticket-id"],
+		TriggeredBy: annotations[AnnotationOriginPrefix+"triggered-by"],
+	}
+	if o == (types.Origin{}) {
+		return nil
+	}
+	return &o
+}
@@ -0,0 +1,21 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginAnnotations(t *testing.T) {
+	o := types.Origin{CIJobURL: "https://ci.example.com/job/1", TriggeredBy: "jane"}
+
+	annotations := OriginAnnotations(o)
+	assert.Equal(t, map[string]string{
+		"cello-origin-ci-job-url":   "https://ci.example.com/job/1",
+		"cello-origin-triggered-by": "jane",
+	}, annotations)
+	assert.Equal(t, &o, Origin(annotations))
+	assert.Nil(t, Origin(map[string]string{"other": "annotation"}))
+}
@@ -0,0 +1,119 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+// Steps report their result by writing a JSON result (see Result) to
+// ResultFile, which workflow templates expose as the ResultParameter output
+// parameter of the step.
+const (
+	ResultFile      = "/tmp/cello-result.json"
+	ResultParameter = "cello-result"
+)
+
+// Statuses of step results.
+const (
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+	ResultSkipped   = "skipped"
+)
+
+// Max length of the summary of a step result.
+const maxResultSummaryLength = 1024
+
+// Result is the machine readable result of a workflow step, e.g. the IDs of
+// the resources it created.
+type Result struct {
+	Status  string            `json:"status"`
+	Summary string            `json:"summary"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// StepResult is the result a step of a workflow reported, not parsed yet.
+type StepResult struct {
+	Step   string
+	Result string
+}
+
+// ParseResult parses and validates the result of a step. Unknown fields are
+// rejected so typos aren't silently dropped.
+func ParseResult(data string) (Result, error) {
+	dec := json.NewDecoder(bytes.NewBufferString(data))
+	dec.DisallowUnknownFields()
+
+	var r Result
+	if err := dec.Decode(&r); err != nil {
+		return Result{}, fmt.Errorf("result is not valid JSON: %w", err)
+	}
+
+	switch r.Status {
+	case ResultSucceeded, ResultFailed, ResultSkipped:
+	case "":
+		return Result{}, errors.New("result status is required")
+	default:
+		return Result{}, fmt.Errorf("result status must be one of '%s', '%s' or '%s'", ResultSucceeded, ResultFailed, ResultSkipped)
+	}
+
+	if len(r.Summary) > maxResultSummaryLength {
+		return Result{}, fmt.Errorf("result summary must be at most %d characters", maxResultSummaryLength)
+	}
+	for k := range r.Outputs {
+		if k == "" {
+			return Result{}, errors.New("result output names must not be empty")
+		}
+	}
+	if r.Outputs == nil {
+		r.Outputs = map[string]string{}
+	}
+	return r, nil
+}
+
+// Outputs returns the outputs of the valid results, of the later step when
+// steps report the same output.
+func Outputs(results []StepResult) map[string]string {
+	outputs := map[string]string{}
+	for _, sr := range results {
+		r, err := ParseResult(sr.Result)
+		if err != nil {
+			continue
+		}
+		for k, v := range r.Outputs {
+			outputs[k] = v
+		}
+	}
+	return outputs
+}
+
+// results returns the results the pod steps of the workflow reported, in the
+// order the steps started. Steps which didn't write a result are skipped.
+func results(workflow *argoWorkflowAPISpec.Workflow) []StepResult {
+	var nodes []argoWorkflowAPISpec.NodeStatus
+	for _, n := range workflow.Status.Nodes {
+		if n.Type == argoWorkflowAPISpec.NodeTypePod && n.Outputs != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].StartedAt.Equal(&nodes[j].StartedAt) {
+			return nodes[i].ID < nodes[j].ID
+		}
+		return nodes[i].StartedAt.Before(&nodes[j].StartedAt)
+	})
+
+	var rs []StepResult
+	for _, n := range nodes {
+		for _, p := range n.Outputs.Parameters {
+			if p.Name == ResultParameter && p.Value != nil && p.Value.String() != "" {
+				rs = append(rs, StepResult{Step: n.DisplayName, Result: p.Value.String()})
+			}
+		}
+	}
+	return rs
+}
@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Result
+		wantErr error
+	}{
+		{
+			name: "result",
+			data: `{"status":"succeeded","summary":"created bucket","outputs":{"bucket_arn":"arn:aws:s3:::bucket1"}}`,
+			want: Result{Status: ResultSucceeded, Summary: "created bucket", Outputs: map[string]string{"bucket_arn": "arn:aws:s3:::bucket1"}},
+		},
+		{
+			name: "result without outputs",
+			data: `{"status":"skipped"}`,
+			want: Result{Status: ResultSkipped, Outputs: map[string]string{}},
+		},
+		{
+			name:    "invalid json",
+			data:    `status: succeeded`,
+			wantErr: errors.New("result is not valid JSON: invalid character 's' looking for beginning of value"),
+		},
+		{
+			name:    "unknown field",
+			data:    `{"status":"succeeded","output":{}}`,
+			wantErr: errors.New(`result is not valid JSON: json: unknown field "output"`),
+		},
+		{
+			name:    "missing status",
+			data:    `{"summary":"done"}`,
+			wantErr: errors.New("result status is required"),
+		},
+		{
+			name:    "invalid status",
+			data:    `{"status":"ok"}`,
+			wantErr: errors.New("result status must be one of 'succeeded', 'failed' or 'skipped'"),
+		},
+		{
+			name:    "empty output name",
+			data:    `{"status":"succeeded","outputs":{"":"value"}}`,
+			wantErr: errors.New("result output names must not be empty"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseResult(tt.data)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, r)
+		})
+	}
+}
+
+func TestResults(t *testing.T) {
+	started := time.Date(2022, 3, 14, 10, 0, 0, 0, time.UTC)
+	output := func(name, value string) *v1alpha1.Outputs {
+		return &v1alpha1.Outputs{Parameters: []v1alpha1.Parameter{{Name: name, Value: v1alpha1.AnyStringPtr(value)}}}
+	}
+
+	wf := &v1alpha1.Workflow{}
+	wf.Status.Nodes = v1alpha1.Nodes{
+		"wf-1": {ID: "wf-1", DisplayName: "apply", Type: v1alpha1.NodeTypePod, StartedAt: metav1.NewTime(started.Add(time.Minute)), Outputs: output(ResultParameter, `{"status":"succeeded"}`)},
+		"wf-2": {ID: "wf-2", DisplayName: "plan", Type: v1alpha1.NodeTypePod, StartedAt: metav1.NewTime(started), Outputs: output(ResultParameter, `{"status":"skipped"}`)},
+		"wf-3": {ID: "wf-3", DisplayName: "without result", Type: v1alpha1.NodeTypePod, StartedAt: metav1.NewTime(started), Outputs: output(ResultParameter, "")},
+		"wf-4": {ID: "wf-4", DisplayName: "other output", Type: v1alpha1.NodeTypePod, StartedAt: metav1.NewTime(started), Outputs: output("other", "value")},
+		"wf-5": {ID: "wf-5", DisplayName: "steps", Type: v1alpha1.NodeTypeSteps, StartedAt: metav1.NewTime(started), Outputs: output(ResultParameter, `{"status":"failed"}`)},
+	}
+
+	assert.Equal(t, []StepResult{
+		{Step: "plan", Result: `{"status":"skipped"}`},
+		{Step: "apply", Result: `{"status":"succeeded"}`},
+	}, results(wf))
+}
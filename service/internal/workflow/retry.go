@@ -0,0 +1,158 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy configures retries of transient submission failures.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// IdentityLabels are the labels of submitted workflows whose values
+	// together identify the submission, e.g. its transaction id. Failures
+	// which may have created the workflow are only retried once no workflow
+	// with those labels exists, never without.
+	IdentityLabels []string
+}
+
+// sleep waits for the duration or until the context is done. Replaced in
+// tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// IsTransient determines if a submission error is likely to succeed when
+// retried, e.g. Argo or Kubernetes API server unavailability (5xx), resource
+// update conflicts and generated names colliding with existing workflows. Not
+// all of them are retried, see SubmitWithRetry.
+func IsTransient(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		switch se.GRPCStatus().Code() {
+		case codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.AlreadyExists:
+			return true
+		}
+	}
+
+	// Conflicts surface as a message rather than a code.
+	return strings.Contains(err.Error(), "the object has been modified")
+}
+
+// isRejected determines if a transient submission error means the workflow
+// wasn't created, so submitting it again can't create a second one: Argo or
+// the Kubernetes API server couldn't be reached or throttled the request, or
+// the update conflicted. Timeouts and internal errors can happen after the
+// workflow was created.
+func isRejected(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		switch se.GRPCStatus().Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+	}
+	return strings.Contains(err.Error(), "the object has been modified")
+}
+
+// backoff returns the delay before the attempt following the given attempt,
+// doubling each attempt with jitter of up to half of the delay.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// #nosec jitter doesn't need a secure random source
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// SubmitWithRetry submits a workflow, retrying transient failures according to
+// the policy. Failures which may have created the workflow are retried only
+// when no workflow with the IdentityLabels of the policy was created, whose
+// name is returned otherwise. onAttempt is called after every attempt with its
+// number (from 1) and either the workflow name or the error.
+func SubmitWithRetry(ctx context.Context, w Workflow, policy RetryPolicy, from string, parameters map[string]string, labels map[string]string, annotations map[string]string, scheduling Scheduling, onAttempt func(attempt int, workflowName string, err error)) (string, error) {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var name string
+		name, err = w.Submit(ctx, from, parameters, labels, annotations, scheduling)
+		onAttempt(attempt, name, err)
+		if err == nil {
+			return name, nil
+		}
+
+		if !IsTransient(err) || attempt == maxAttempts {
+			break
+		}
+
+		if !isRejected(err) {
+			created, ok := submitted(ctx, w, policy.IdentityLabels, labels)
+			if !ok {
+				break
+			}
+			if created != "" {
+				return created, nil
+			}
+		}
+
+		if sleepErr := sleep(ctx, policy.backoff(attempt)); sleepErr != nil {
+			break
+		}
+	}
+
+	return "", err
+}
+
+// submitted returns the name of the workflow with the values of the identity
+// labels of the labels, empty when there's none. It returns false when the
+// workflow can't be identified, without identity labels or when listing the
+// workflows fails.
+func submitted(ctx context.Context, w Workflow, identityLabels []string, labels map[string]string) (string, bool) {
+	if len(identityLabels) == 0 {
+		return "", false
+	}
+
+	selector := map[string]string{}
+	for _, k := range identityLabels {
+		v, ok := labels[k]
+		if !ok {
+			return "", false
+		}
+		selector[k] = v
+	}
+
+	statuses, err := w.ListByLabels(ctx, selector)
+	if err != nil {
+		return "", false
+	}
+	if len(statuses) > 0 {
+		return statuses[0].Name, true
+	}
+	return "", true
+}
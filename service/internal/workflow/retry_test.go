@@ -0,0 +1,216 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unavailable",
+			err:  fmt.Errorf("failed to submit workflow: %w", status.Error(codes.Unavailable, "down")),
+			want: true,
+		},
+		{
+			name: "internal",
+			err:  status.Error(codes.Internal, "server error"),
+			want: true,
+		},
+		{
+			name: "conflict",
+			err:  errors.New(`Operation cannot be fulfilled: the object has been modified; please apply your changes to the latest version`),
+			want: true,
+		},
+		{
+			name: "generated name collision",
+			err:  status.Error(codes.AlreadyExists, `workflows.argoproj.io "project1-target1-abcde" already exists`),
+			want: true,
+		},
+		{
+			name: "invalid argument",
+			err:  status.Error(codes.InvalidArgument, "bad template"),
+		},
+		{
+			name: "not found",
+			err:  status.Error(codes.NotFound, "template not found"),
+		},
+		{
+			name: "other",
+			err:  errors.New("other"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
+
+type submitResult struct {
+	name string
+	err  error
+}
+
+// mockSubmitWorkflow returns the results in order from Submit, and the
+// created workflows from ListByLabels.
+type mockSubmitWorkflow struct {
+	Workflow
+	results   []submitResult
+	calls     int
+	created   []Status
+	selectors []map[string]string
+}
+
+func (m *mockSubmitWorkflow) ListByLabels(ctx context.Context, selector map[string]string) ([]Status, error) {
+	m.selectors = append(m.selectors, selector)
+	return m.created, nil
+}
+
+func (m *mockSubmitWorkflow) Submit(ctx context.Context, from string, parameters map[string]string, labels map[string]string, annotations map[string]string, scheduling Scheduling) (string, error) {
+	r := m.results[m.calls]
+	m.calls++
+	return r.name, r.err
+}
+
+func (m *mockSubmitWorkflow) LogStream(ctx context.Context, workflowName string, w http.ResponseWriter) error {
+	return nil
+}
+
+func TestSubmitWithRetry(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	invalid := status.Error(codes.InvalidArgument, "bad")
+	timeout := status.Error(codes.DeadlineExceeded, "timeout")
+
+	tests := []struct {
+		name           string
+		maxAttempts    int
+		identityLabels []string
+		results        []submitResult
+		created        []Status
+		wantName       string
+		wantErr        error
+		wantAttempts   []string
+		wantSelectors  []map[string]string
+	}{
+		{
+			name:         "first attempt succeeds",
+			maxAttempts:  3,
+			results:      []submitResult{{name: "wf"}},
+			wantName:     "wf",
+			wantAttempts: []string{"1:<nil>"},
+		},
+		{
+			name:         "transient failure retried",
+			maxAttempts:  3,
+			results:      []submitResult{{err: unavailable}, {err: unavailable}, {name: "wf"}},
+			wantName:     "wf",
+			wantAttempts: []string{"1:" + unavailable.Error(), "2:" + unavailable.Error(), "3:<nil>"},
+		},
+		{
+			name:         "attempts exhausted",
+			maxAttempts:  2,
+			results:      []submitResult{{err: unavailable}, {err: unavailable}},
+			wantErr:      unavailable,
+			wantAttempts: []string{"1:" + unavailable.Error(), "2:" + unavailable.Error()},
+		},
+		{
+			name:         "permanent failure not retried",
+			maxAttempts:  3,
+			results:      []submitResult{{err: invalid}},
+			wantErr:      invalid,
+			wantAttempts: []string{"1:" + invalid.Error()},
+		},
+		{
+			name:           "timeout retried once no workflow was created",
+			maxAttempts:    3,
+			identityLabels: []string{"txid", LabelProject},
+			results:        []submitResult{{err: timeout}, {name: "wf"}},
+			wantName:       "wf",
+			wantAttempts:   []string{"1:" + timeout.Error(), "2:<nil>"},
+			wantSelectors:  []map[string]string{{"txid": "tx1", LabelProject: "project1"}},
+		},
+		{
+			name:           "timeout which created the workflow not retried",
+			maxAttempts:    3,
+			identityLabels: []string{"txid", LabelProject},
+			results:        []submitResult{{err: timeout}},
+			created:        []Status{{Name: "wf"}},
+			wantName:       "wf",
+			wantAttempts:   []string{"1:" + timeout.Error()},
+			wantSelectors:  []map[string]string{{"txid": "tx1", LabelProject: "project1"}},
+		},
+		{
+			name:         "timeout without identity labels not retried",
+			maxAttempts:  3,
+			results:      []submitResult{{err: timeout}},
+			wantErr:      timeout,
+			wantAttempts: []string{"1:" + timeout.Error()},
+		},
+		{
+			name:           "timeout without identity label values not retried",
+			maxAttempts:    3,
+			identityLabels: []string{"other"},
+			results:        []submitResult{{err: timeout}},
+			wantErr:        timeout,
+			wantAttempts:   []string{"1:" + timeout.Error()},
+		},
+		{
+			name:         "zero attempts submits once",
+			results:      []submitResult{{name: "wf"}},
+			wantName:     "wf",
+			wantAttempts: []string{"1:<nil>"},
+		},
+	}
+
+	origSleep := sleep
+	defer func() { sleep = origSleep }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var slept []time.Duration
+			sleep = func(ctx context.Context, d time.Duration) error {
+				slept = append(slept, d)
+				return nil
+			}
+
+			m := &mockSubmitWorkflow{results: tt.results, created: tt.created}
+			policy := RetryPolicy{MaxAttempts: tt.maxAttempts, InitialBackoff: time.Second, MaxBackoff: 4 * time.Second, IdentityLabels: tt.identityLabels}
+			labels := map[string]string{"txid": "tx1", LabelProject: "project1"}
+
+			var attempts []string
+			name, err := SubmitWithRetry(context.Background(), m, policy, "workflowtemplate/wt", nil, labels, nil, Scheduling{}, func(attempt int, _ string, err error) {
+				attempts = append(attempts, fmt.Sprintf("%d:%v", attempt, err))
+			})
+
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantAttempts, attempts)
+			assert.Len(t, slept, len(tt.wantAttempts)-1)
+			assert.Equal(t, tt.wantSelectors, m.selectors)
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 4 * time.Second} {
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, want/2)
+		assert.LessOrEqual(t, d, want)
+	}
+}
@@ -0,0 +1,113 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Scheduling constrains the nodes the pods of a workflow run on, their
+// priority and the service account they run as.
+type Scheduling struct {
+	NodeSelector      map[string]string
+	Tolerations       []types.Toleration
+	PriorityClassName string
+	RuntimeClassName  string
+	// ServiceAccountName is the (IRSA) service account of the pods.
+	ServiceAccountName string
+	// Platform is the os/arch of the nodes, selected by their well known
+	// labels.
+	Platform string
+}
+
+// IsZero returns true when there are no constraints.
+func (s Scheduling) IsZero() bool {
+	return len(s.NodeSelector) == 0 && len(s.Tolerations) == 0 && s.PriorityClassName == "" && s.RuntimeClassName == "" &&
+		s.ServiceAccountName == "" && s.Platform == ""
+}
+
+// apply sets the constraints on the spec. Workflows have no runtime class so
+// it's set with a pod spec patch.
+func (s Scheduling) apply(spec *argoWorkflowAPISpec.WorkflowSpec) error {
+	spec.NodeSelector = s.NodeSelector
+	if s.Platform != "" {
+		parts := strings.SplitN(s.Platform, "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("platform '%s' must be os/arch", s.Platform)
+		}
+		spec.NodeSelector = make(map[string]string, len(s.NodeSelector)+2)
+		for k, v := range s.NodeSelector {
+			spec.NodeSelector[k] = v
+		}
+		spec.NodeSelector[types.LabelOS] = parts[0]
+		spec.NodeSelector[types.LabelArch] = parts[1]
+	}
+	spec.PodPriorityClassName = s.PriorityClassName
+	spec.ServiceAccountName = s.ServiceAccountName
+	for _, t := range s.Tolerations {
+		spec.Tolerations = append(spec.Tolerations, v1.Toleration{
+			Key:               t.Key,
+			Operator:          v1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            v1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+
+	if s.RuntimeClassName != "" {
+		patch, err := json.Marshal(map[string]string{"runtimeClassName": s.RuntimeClassName})
+		if err != nil {
+			return fmt.Errorf("failed to create pod spec patch: %w", err)
+		}
+		spec.PodSpecPatch = string(patch)
+	}
+	return nil
+}
+
+// newTemplateWorkflowSpec creates the spec of a workflow referencing the
+// template it's submitted from, e.g. workflowtemplate/name.
+func newTemplateWorkflowSpec(from string, parameters map[string]string, scheduling Scheduling) (argoWorkflowAPISpec.WorkflowSpec, error) {
+	parts := strings.SplitN(from, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return argoWorkflowAPISpec.WorkflowSpec{}, fmt.Errorf("resource identifier '%s' is malformed. Should be `kind/name`, e.g. workflowtemplate/hello-world", from)
+	}
+
+	ref := &argoWorkflowAPISpec.WorkflowTemplateRef{Name: parts[1]}
+	switch parts[0] {
+	case strings.ToLower(KindWorkflowTemplate):
+	case strings.ToLower(KindClusterWorkflowTemplate):
+		ref.ClusterScope = true
+	default:
+		return argoWorkflowAPISpec.WorkflowSpec{}, fmt.Errorf("workflows can't reference '%s'", parts[0])
+	}
+
+	// Sorted so updates don't reorder the parameters.
+	names := make([]string, 0, len(parameters))
+	for k := range parameters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var params []argoWorkflowAPISpec.Parameter
+	for _, k := range names {
+		params = append(params, argoWorkflowAPISpec.Parameter{
+			Name:  k,
+			Value: argoWorkflowAPISpec.AnyStringPtr(parameters[k]),
+		})
+	}
+
+	spec := argoWorkflowAPISpec.WorkflowSpec{
+		WorkflowTemplateRef: ref,
+		Arguments:           argoWorkflowAPISpec.Arguments{Parameters: params},
+	}
+	if err := scheduling.apply(&spec); err != nil {
+		return argoWorkflowAPISpec.WorkflowSpec{}, err
+	}
+	return spec, nil
+}
@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cello-proj/cello/internal/types"
+
+	argoWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
+	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestArgoSubmitScheduling(t *testing.T) {
+	cl := &mockCreateArgoClient{}
+	scheduling := Scheduling{
+		NodeSelector:       map[string]string{"pool": "deploy"},
+		Tolerations:        []types.Toleration{{Key: "dedicated", Operator: "Equal", Value: "deploy", Effect: "NoSchedule"}},
+		PriorityClassName:  "prod-deploy",
+		RuntimeClassName:   "gvisor",
+		ServiceAccountName: "deploy",
+	}
+
+	name, err := NewArgoWorkflow(cl, "argo").Submit(context.Background(), "workflowtemplate/deploy",
+		map[string]string{"project_name": "project1", "target_name": "target1"}, map[string]string{LabelProject: "project1"}, map[string]string{"cello-origin-ticket-id": "CHG-1"}, scheduling)
+	assert.Nil(t, err)
+	assert.Equal(t, "project1-target1-abcde", name)
+
+	if assert.NotNil(t, cl.created) {
+		spec := cl.created.Spec
+		assert.Equal(t, "project1", cl.created.Labels[LabelProject])
+		assert.Equal(t, map[string]string{"cello-origin-ticket-id": "CHG-1"}, cl.created.Annotations)
+		assert.Equal(t, &v1alpha1.WorkflowTemplateRef{Name: "deploy"}, spec.WorkflowTemplateRef)
+		assert.Equal(t, "project_name", spec.Arguments.Parameters[0].Name)
+		assert.Equal(t, map[string]string{"pool": "deploy"}, spec.NodeSelector)
+		assert.Equal(t, []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "deploy", Effect: v1.TaintEffectNoSchedule}}, spec.Tolerations)
+		assert.Equal(t, "prod-deploy", spec.PodPriorityClassName)
+		assert.Equal(t, `{"runtimeClassName":"gvisor"}`, spec.PodSpecPatch)
+		assert.Equal(t, "deploy", spec.ServiceAccountName)
+	}
+}
+
+func TestArgoSubmitPlatform(t *testing.T) {
+	cl := &mockCreateArgoClient{}
+	scheduling := Scheduling{
+		NodeSelector: map[string]string{"pool": "deploy"},
+		Platform:     "linux/arm64",
+	}
+
+	_, err := NewArgoWorkflow(cl, "argo").Submit(context.Background(), "workflowtemplate/deploy",
+		map[string]string{"project_name": "project1", "target_name": "target1"}, nil, nil, scheduling)
+	assert.Nil(t, err)
+
+	if assert.NotNil(t, cl.created) {
+		assert.Equal(t, map[string]string{"pool": "deploy", "kubernetes.io/os": "linux", "kubernetes.io/arch": "arm64"}, cl.created.Spec.NodeSelector)
+	}
+	assert.Equal(t, map[string]string{"pool": "deploy"}, scheduling.NodeSelector)
+}
+
+func TestArgoSubmitCostLabels(t *testing.T) {
+	cl := &mockCreateArgoClient{}
+	workflowLabels := map[string]string{
+		LabelProject:     "project1",
+		LabelTarget:      "target1",
+		LabelExecutionID: "txid1",
+		LabelTenant:      "team-a",
+		LabelType:        "sync",
+	}
+
+	_, err := NewArgoWorkflow(cl, "argo").Submit(context.Background(), "workflowtemplate/deploy",
+		map[string]string{"project_name": "project1", "target_name": "target1"}, workflowLabels, nil, Scheduling{})
+	assert.Nil(t, err)
+
+	if assert.NotNil(t, cl.created) {
+		assert.Equal(t, workflowLabels, cl.created.Labels)
+		assert.Equal(t, &v1alpha1.Metadata{Labels: map[string]string{
+			LabelProject:     "project1",
+			LabelTarget:      "target1",
+			LabelExecutionID: "txid1",
+			LabelTenant:      "team-a",
+		}}, cl.created.Spec.PodMetadata)
+	}
+}
+
+type mockCreateArgoClient struct {
+	argoWorkflowAPIClient.WorkflowServiceClient
+	created *v1alpha1.Workflow
+}
+
+func (m *mockCreateArgoClient) CreateWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowCreateRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
+	m.created = in.Workflow
+	wf := in.Workflow.DeepCopy()
+	wf.Name = wf.GenerateName + "abcde"
+	return wf, nil
+}
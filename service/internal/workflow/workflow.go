@@ -6,61 +6,312 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/types"
 
 	argoWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
+	argoWorkflowArchiveAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflowarchive"
 	argoWorkflowAPISpec "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 )
 
 const mainContainer = "main"
 
+// Labels added to submitted workflows so they can be found again.
+const (
+	LabelProject      = "cello-project"
+	LabelTarget       = "cello-target"
+	LabelType         = "cello-type"
+	LabelCommitHash   = "cello-commit-hash"
+	LabelChangeTicket = "cello-change-ticket"
+	LabelAnomalyScore = "cello-anomaly-score"
+	LabelRollout      = "cello-rollout"
+	LabelExecutionID  = "cello-execution-id"
+	LabelTenant       = "cello-tenant"
+)
+
+// CostLabels are the labels of workflows also added to their pods, so the
+// resource usage of the pods can be attributed for chargeback.
+var CostLabels = []string{LabelProject, LabelTarget, LabelExecutionID, LabelTenant}
+
+// podLabels returns the cost labels of the workflow labels.
+func podLabels(workflowLabels map[string]string) map[string]string {
+	pl := map[string]string{}
+	for _, k := range CostLabels {
+		if v, ok := workflowLabels[k]; ok {
+			pl[k] = v
+		}
+	}
+	return pl
+}
+
+// AnnotationOriginPrefix is the prefix of the annotations of the origin of
+// submitted workflows, followed by the origin field with dashes, e.g.
+// 'cello-origin-ci-job-url'.
+const AnnotationOriginPrefix = "cello-origin-"
+
+// Kinds of Argo templates workflows are submitted from.
+const (
+	KindWorkflowTemplate        = "WorkflowTemplate"
+	KindClusterWorkflowTemplate = "ClusterWorkflowTemplate"
+)
+
 // Workflow interface is used for interacting with workflow services.
 type Workflow interface {
-	List(ctx context.Context) ([]string, error)
-	Logs(ctx context.Context, workflowName string) (*Logs, error)
+	List(ctx context.Context, selector map[string][]string, opts ListOptions) ([]Status, string, error)
+	ListByLabels(ctx context.Context, selector map[string]string) ([]Status, error)
+	Logs(ctx context.Context, workflowName string, opts LogOptions) (*Logs, error)
+	WalkLogs(ctx context.Context, workflowName string, fn func(line string) bool) error
 	LogStream(ctx context.Context, workflowName string, data http.ResponseWriter) error
+	Nodes(ctx context.Context, workflowName string, opts NodeOptions) (*Nodes, error)
 	Status(ctx context.Context, workflowName string) (*Status, error)
-	Submit(ctx context.Context, from string, parameters map[string]string, labels map[string]string) (string, error)
+	Submit(ctx context.Context, from string, parameters map[string]string, labels map[string]string, annotations map[string]string, scheduling Scheduling) (string, error)
+	Terminate(ctx context.Context, workflowName string) error
+}
+
+// Option configures an ArgoWorkflow.
+type Option func(*ArgoWorkflow)
+
+// WithNameTemplate sets the template of the names of submitted workflows,
+// DefaultNameTemplate otherwise.
+func WithNameTemplate(names *NameTemplate) Option {
+	return func(a *ArgoWorkflow) {
+		a.names = names
+	}
 }
 
 // NewArgoWorkflow creates an Argo workflow.
-func NewArgoWorkflow(cl argoWorkflowAPIClient.WorkflowServiceClient, n string) Workflow {
-	return &ArgoWorkflow{
+func NewArgoWorkflow(cl argoWorkflowAPIClient.WorkflowServiceClient, n string, opts ...Option) Workflow {
+	a := &ArgoWorkflow{
 		namespace: n,
 		svc:       cl,
+		names:     defaultNameTemplate,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // ArgoWorkflow represents an Argo Workflow.
 type ArgoWorkflow struct {
 	namespace string
 	svc       argoWorkflowAPIClient.WorkflowServiceClient
+	names     *NameTemplate
+	// archive and artifacts are the fallback of workflows no longer live,
+	// nil without, see WithArchive.
+	archive   argoWorkflowArchiveAPIClient.ArchivedWorkflowServiceClient
+	artifacts ArtifactReader
+	// offloaded reads the nodes Argo offloaded to its database, nil without,
+	// see WithOffloadedNodes.
+	offloaded OffloadedNodesReader
 }
 
 // Logs represents workflow logs.
 type Logs struct {
 	Logs []string `json:"logs"`
+	// Next is the position of the lines following the selected ones when
+	// LogOptions.MaxBytes was reached, nil when the logs were complete.
+	Next LogPositions `json:"-"`
+}
+
+// LogPosition is the position of the lines of the logs of a pod read.
+type LogPosition struct {
+	// Time is the timestamp of the last line read, zero for archived logs
+	// whose lines have none.
+	Time time.Time `json:"time,omitempty"`
+	// Lines is the number of lines read with the timestamp of the last one.
+	Lines int `json:"lines,omitempty"`
+	// Done is whether every line of the pod was read, only set for archived
+	// logs which no longer change.
+	Done bool `json:"done,omitempty"`
+}
+
+// LogPositions are the positions of the lines of the logs of the pods of a
+// workflow read, by pod.
+type LogPositions map[string]LogPosition
+
+// LogOptions selects the lines of the logs of a workflow, the zero value
+// selects every line.
+type LogOptions struct {
+	// From is the position of the lines of the pods read, which are skipped.
+	From LogPositions
+	// MaxBytes is the size of the lines above which no more lines are
+	// selected, the first line is always selected. Unlimited when zero.
+	MaxBytes int
+}
+
+// ListOptions selects a page of the workflows listed, the zero value selects
+// every workflow.
+type ListOptions struct {
+	// Limit is the maximum number of workflows of the page, unlimited when
+	// zero.
+	Limit int64
+	// Continue is the token of the page returned listing the previous one.
+	Continue string
+}
+
+// archivedContinuePrefix prefixes the continue tokens of the pages of the
+// archived workflows, listed after the live ones.
+const archivedContinuePrefix = "archived/"
+
+// listFields are the fields of the workflows listed, enough for their
+// status without their nodes.
+const listFields = "metadata,items.metadata.name,items.metadata.creationTimestamp,items.metadata.labels,items.metadata.annotations,items.status.phase,items.status.finishedAt,items.status.progress"
+
+// List returns a page of the status of the workflows whose labels have one of
+// the values of the selector, live ones first then archived ones when the
+// archive is enabled, with the continue token of the following page unless
+// it's the last. The workflows are selected and paged by Argo, the continue
+// tokens of the pages of live workflows are the ones of Argo. Pages can have
+// fewer workflows than their limit.
+func (a ArgoWorkflow) List(ctx context.Context, selector map[string][]string, opts ListOptions) ([]Status, string, error) {
+	labelSelector, err := setSelector(selector)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if strings.HasPrefix(opts.Continue, archivedContinuePrefix) {
+		live, _, err := a.listLive(ctx, labelSelector, 0, "")
+		if err != nil {
+			return nil, "", err
+		}
+		return a.listArchivedStatuses(ctx, labelSelector, opts.Limit, strings.TrimPrefix(opts.Continue, archivedContinuePrefix), live)
+	}
+
+	statuses, next, err := a.listLive(ctx, labelSelector, opts.Limit, opts.Continue)
+	if err != nil {
+		return nil, "", err
+	}
+	if a.archive == nil || next != "" {
+		return statuses, next, nil
+	}
+	if opts.Limit > 0 {
+		return statuses, archivedContinuePrefix, nil
+	}
+
+	archived, _, err := a.listArchivedStatuses(ctx, labelSelector, 0, "", statuses)
+	if err != nil {
+		return statuses, "", err
+	}
+	return append(statuses, archived...), "", nil
 }
 
-// List returns a list of workflows.
-func (a ArgoWorkflow) List(ctx context.Context) ([]string, error) {
-	workflowIDs := []string{}
+// setSelector returns the label selector of the workflows whose labels have
+// one of the values of the selector.
+func setSelector(selector map[string][]string) (string, error) {
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	requirements := labels.NewSelector()
+	for _, k := range keys {
+		r, err := labels.NewRequirement(k, selection.In, selector[k])
+		if err != nil {
+			return "", fmt.Errorf("invalid label selector: %w", err)
+		}
+		requirements = requirements.Add(*r)
+	}
+	return requirements.String(), nil
+}
 
+// listLive returns a page of the status of the live workflows matching the
+// label selector.
+func (a ArgoWorkflow) listLive(ctx context.Context, labelSelector string, limit int64, token string) ([]Status, string, error) {
 	workflowListResult, err := a.svc.ListWorkflows(ctx, &argoWorkflowAPIClient.WorkflowListRequest{
 		Namespace: a.namespace,
+		ListOptions: &metav1.ListOptions{
+			LabelSelector: labelSelector,
+			Limit:         limit,
+			Continue:      token,
+		},
+		Fields: listFields,
 	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	statuses := []Status{}
+	for i := range workflowListResult.Items {
+		statuses = append(statuses, newStatus(&workflowListResult.Items[i]))
+	}
+	return statuses, workflowListResult.Continue, nil
+}
 
+// listArchivedStatuses returns a page of the status of the archived workflows
+// matching the label selector, except the live ones.
+func (a ArgoWorkflow) listArchivedStatuses(ctx context.Context, labelSelector string, limit int64, token string, live []Status) ([]Status, string, error) {
+	archived, next, err := a.listArchived(ctx, labelSelector, limit, token)
 	if err != nil {
-		return workflowIDs, err
+		return nil, "", err
 	}
 
-	for _, item := range workflowListResult.Items {
-		workflowIDs = append(workflowIDs, item.ObjectMeta.Name)
+	listed := map[string]bool{}
+	for _, s := range live {
+		listed[s.Name] = true
+	}
+	statuses := []Status{}
+	for i := range archived {
+		if !listed[archived[i].Name] {
+			statuses = append(statuses, newStatus(&archived[i]))
+			listed[archived[i].Name] = true
+		}
 	}
+	if next != "" {
+		next = archivedContinuePrefix + next
+	}
+	return statuses, next, nil
+}
 
-	return workflowIDs, nil
+// ListByLabels returns the status of the workflows matching all of the labels
+// in the selector.
+func (a ArgoWorkflow) ListByLabels(ctx context.Context, selector map[string]string) ([]Status, error) {
+	workflowListResult, err := a.svc.ListWorkflows(ctx, &argoWorkflowAPIClient.WorkflowListRequest{
+		Namespace: a.namespace,
+		ListOptions: &metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(selector).String(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	live := map[string]bool{}
+	for i := range workflowListResult.Items {
+		statuses = append(statuses, newStatus(&workflowListResult.Items[i]))
+		live[workflowListResult.Items[i].Name] = true
+	}
+
+	if a.archive == nil {
+		return statuses, nil
+	}
+	archived, _, err := a.listArchived(ctx, labels.SelectorFromSet(selector).String(), 0, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range archived {
+		if live[archived[i].Name] {
+			continue
+		}
+		// The archive lists workflows without their labels, they're known
+		// to match the selector.
+		st := newStatus(&archived[i])
+		st.Labels = selector
+		statuses = append(statuses, st)
+		live[archived[i].Name] = true
+	}
+	return statuses, nil
 }
 
 // Status represents a workflow status.
@@ -69,31 +320,263 @@ type Status struct {
 	Status   string `json:"status"`
 	Created  string `json:"created"`
 	Finished string `json:"finished"`
+	// Labels are the labels of the workflow, e.g. LabelProject to authorize
+	// access to it.
+	Labels map[string]string `json:"-"`
+	// Annotations are the annotations of the workflow, e.g. of its origin.
+	Annotations map[string]string `json:"-"`
+	// Parameters are the arguments the workflow was submitted with, including
+	// the credentials token.
+	Parameters map[string]string `json:"-"`
+	// NodesCompleted and NodesTotal are the progress of the workflow reported
+	// by Argo, both 0 until the controller reports it.
+	NodesCompleted int64 `json:"-"`
+	NodesTotal     int64 `json:"-"`
+	// Results are the results the steps of the workflow reported, see
+	// ResultFile.
+	Results []StepResult `json:"-"`
+}
+
+// IsActive reports whether a workflow with the status hasn't completed yet.
+// Workflows that haven't been picked up by the controller have no status.
+func IsActive(status string) bool {
+	return status == "" || status == "pending" || status == "running"
 }
 
-// Status returns a workflow status.
+// IsNotFound reports whether the error is of a workflow which doesn't exist,
+// live or archived.
+func IsNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
+
+// WaitForCompletion gets the status of the workflow every interval until it
+// completes, returning its final status, or until ctx is done. Errors getting
+// the status are passed to onError and retried.
+func WaitForCompletion(ctx context.Context, wf Workflow, workflowName string, interval time.Duration, onError func(error)) (*Status, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := wf.Status(ctx, workflowName)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		if !IsActive(status.Status) {
+			return status, nil
+		}
+	}
+}
+
+func newStatus(workflow *argoWorkflowAPISpec.Workflow) Status {
+	return Status{
+		Name:           workflow.Name,
+		Status:         strings.ToLower(string(workflow.Status.Phase)),
+		Created:        fmt.Sprint(workflow.CreationTimestamp.Unix()),
+		Finished:       fmt.Sprint(workflow.Status.FinishedAt.Unix()),
+		Labels:         workflow.Labels,
+		Annotations:    workflow.Annotations,
+		Parameters:     parameters(workflow),
+		NodesCompleted: progress(workflow).N(),
+		NodesTotal:     progress(workflow).M(),
+		Results:        results(workflow),
+	}
+}
+
+// progress returns the N/M progress of the workflow, 0/0 when it isn't
+// reported.
+func progress(workflow *argoWorkflowAPISpec.Workflow) argoWorkflowAPISpec.Progress {
+	if !workflow.Status.Progress.IsValid() {
+		return "0/0"
+	}
+	return workflow.Status.Progress
+}
+
+// parameters returns the arguments of the workflow by name.
+func parameters(workflow *argoWorkflowAPISpec.Workflow) map[string]string {
+	params := map[string]string{}
+	for _, p := range workflow.Spec.Arguments.Parameters {
+		if p.Value != nil {
+			params[p.Name] = p.Value.String()
+		}
+	}
+	return params
+}
+
+// Status returns a workflow status, of the archived workflow when it's no
+// longer live.
 func (a ArgoWorkflow) Status(ctx context.Context, workflowName string) (*Status, error) {
-	workflow, err := a.svc.GetWorkflow(ctx, &argoWorkflowAPIClient.WorkflowGetRequest{
-		Name:      workflowName,
-		Namespace: a.namespace,
-	})
+	workflow, err := a.workflow(ctx, workflowName)
+	if err != nil {
+		return nil, err
+	}
 
+	workflowData := newStatus(workflow)
+	return &workflowData, nil
+}
+
+// workflow returns the workflow, the archived workflow when it's no longer
+// live.
+func (a ArgoWorkflow) workflow(ctx context.Context, workflowName string) (*argoWorkflowAPISpec.Workflow, error) {
+	workflow, err := a.getWorkflow(ctx, workflowName, "")
+	if a.archive != nil && status.Code(err) == codes.NotFound {
+		archived, archiveErr := a.archivedWorkflow(ctx, workflowName)
+		if archiveErr != nil {
+			return nil, archiveErr
+		}
+		if archived != nil {
+			workflow, err = archived, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// Logs returns the lines of the logs of a workflow selected by the options,
+// of the archived logs of the workflow when it's no longer live. Logs are
+// read a pod at a time in the order the pods started, each from its position
+// so the lines of the pods read before aren't read again.
+func (a ArgoWorkflow) Logs(ctx context.Context, workflowName string, opts LogOptions) (*Logs, error) {
+	sel := newLineSelector(opts)
+	workflow, err := a.getWorkflow(ctx, workflowName, "")
+	if a.archive != nil && status.Code(err) == codes.NotFound {
+		archived, archiveErr := a.archivedWorkflow(ctx, workflowName)
+		if archiveErr != nil {
+			return nil, archiveErr
+		}
+		if archived != nil {
+			if err := a.selectArchivedLogs(ctx, archived, sel); err != nil {
+				return nil, err
+			}
+			return &sel.logs, nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if workflow.Status.IsOffloadNodeStatus() {
+		return nil, ErrNodesOffloaded
+	}
 
-	workflowData := Status{
-		Name:     workflowName,
-		Status:   strings.ToLower(string(workflow.Status.Phase)),
-		Created:  fmt.Sprint(workflow.CreationTimestamp.Unix()),
-		Finished: fmt.Sprint(workflow.Status.FinishedAt.Unix()),
+	for _, pod := range podsByStart(workflow, nil) {
+		more, err := a.readLiveLogs(ctx, workflowName, pod.ID, sel.positions[pod.ID], sel.pod(pod.ID))
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
 	}
+	return &sel.logs, nil
+}
 
-	return &workflowData, nil
+// podsByStart returns the pod nodes of the workflow accepted by the filter,
+// every one when nil, in the order they started, the ones which haven't
+// started last.
+func podsByStart(workflow *argoWorkflowAPISpec.Workflow, filter func(node argoWorkflowAPISpec.NodeStatus) bool) []argoWorkflowAPISpec.NodeStatus {
+	var pods []argoWorkflowAPISpec.NodeStatus
+	for _, node := range workflow.Status.Nodes {
+		if node.Type == argoWorkflowAPISpec.NodeTypePod && (filter == nil || filter(node)) {
+			pods = append(pods, node)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		si, sj := pods[i].StartedAt, pods[j].StartedAt
+		if si.IsZero() != sj.IsZero() {
+			return sj.IsZero()
+		}
+		if !si.Equal(&sj) {
+			return si.Before(&sj)
+		}
+		return pods[i].ID < pods[j].ID
+	})
+	return pods
+}
+
+// readLiveLogs calls fn with the lines of the logs of the pod from the
+// position and their timestamp, returning false once fn did. Logs are
+// streamed from the second of the position, the lines of that second read
+// before are skipped by fn.
+func (a ArgoWorkflow) readLiveLogs(ctx context.Context, workflowName, podName string, from LogPosition, fn func(t time.Time, line string) bool) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := &v1.PodLogOptions{
+		Container:  mainContainer,
+		Timestamps: true,
+	}
+	if !from.Time.IsZero() {
+		since := metav1.NewTime(from.Time)
+		opts.SinceTime = &since
+	}
+	stream, err := a.svc.WorkflowLogs(ctx, &argoWorkflowAPIClient.WorkflowLogRequest{
+		Name:       workflowName,
+		Namespace:  a.namespace,
+		PodName:    podName,
+		LogOptions: opts,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		// Lines without a timestamp are positioned with the preceding line.
+		var t time.Time
+		content := event.Content
+		parts := strings.SplitN(content, " ", 2)
+		if len(parts) == 2 {
+			if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+				t, content = ts, parts[1]
+			}
+		}
+		if !fn(t, content) {
+			return false, nil
+		}
+	}
+}
+
+// WalkLogs calls fn with the lines of the logs of a workflow in order, of the
+// archived logs of the workflow when it's no longer live, until fn returns
+// false.
+func (a ArgoWorkflow) WalkLogs(ctx context.Context, workflowName string, fn func(line string) bool) error {
+	err := a.walkLiveLogs(ctx, workflowName, fn)
+	if a.archive == nil || status.Code(err) != codes.NotFound {
+		return err
+	}
+
+	archived, archiveErr := a.archivedWorkflow(ctx, workflowName)
+	if archiveErr != nil {
+		return archiveErr
+	}
+	if archived == nil {
+		return err
+	}
+	return a.walkArchivedLogs(ctx, archived, fn)
 }
 
-// Logs returns logs for a workflow.
-func (a ArgoWorkflow) Logs(ctx context.Context, workflowName string) (*Logs, error) {
+func (a ArgoWorkflow) walkLiveLogs(ctx context.Context, workflowName string, fn func(line string) bool) error {
+	// Lines are read as they're streamed, skipped lines and the lines
+	// following the selected ones are never held in memory.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	stream, err := a.svc.WorkflowLogs(ctx, &argoWorkflowAPIClient.WorkflowLogRequest{
 		Name:      workflowName,
 		Namespace: a.namespace,
@@ -103,24 +586,84 @@ func (a ArgoWorkflow) Logs(ctx context.Context, workflowName string) (*Logs, err
 	})
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var argoWorkflowLogs Logs
 	for {
 		event, err := stream.Recv()
 		if errors.Is(err, io.EOF) {
-			break
+			return nil
 		}
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		argoWorkflowLogs.Logs = append(argoWorkflowLogs.Logs, fmt.Sprintf("%s: %s", event.PodName, event.Content))
+		if !fn(fmt.Sprintf("%s: %s", event.PodName, event.Content)) {
+			return nil
+		}
 	}
+}
+
+// lineSelector selects the lines of logs per LogOptions as they're read,
+// recording the position of the lines of each pod read.
+type lineSelector struct {
+	maxBytes  int
+	positions LogPositions
+	size      int
+	logs      Logs
+}
 
-	return &argoWorkflowLogs, nil
+func newLineSelector(opts LogOptions) *lineSelector {
+	positions := LogPositions{}
+	for pod, pos := range opts.From {
+		positions[pod] = pos
+	}
+	return &lineSelector{maxBytes: opts.MaxBytes, positions: positions}
+}
+
+// pod returns the function adding the lines of the pod, read in order with
+// their timestamp, which skips the lines read before its position and
+// returns false once the following lines aren't selected.
+func (s *lineSelector) pod(podName string) func(t time.Time, line string) bool {
+	from := s.positions[podName]
+	skip := from.Lines
+	return func(t time.Time, line string) bool {
+		pos := s.positions[podName]
+		if t.IsZero() {
+			t = pos.Time
+		}
+		if t.Before(from.Time) {
+			return true
+		}
+		if t.Equal(from.Time) && skip > 0 {
+			skip--
+			return true
+		}
+
+		l := fmt.Sprintf("%s: %s", podName, line)
+		if s.maxBytes > 0 && len(s.logs.Logs) > 0 && s.size+len(l) > s.maxBytes {
+			s.logs.Next = s.positions
+			return false
+		}
+		s.logs.Logs = append(s.logs.Logs, l)
+		s.size += len(l)
+
+		if t.Equal(pos.Time) {
+			pos.Lines++
+		} else {
+			pos = LogPosition{Time: t, Lines: 1}
+		}
+		s.positions[podName] = pos
+		return true
+	}
+}
+
+// done records every line of the pod was read.
+func (s *lineSelector) done(podName string) {
+	pos := s.positions[podName]
+	pos.Done = true
+	s.positions[podName] = pos
 }
 
 // LogStream returns a log stream for a workflow.
@@ -154,20 +697,24 @@ func (a ArgoWorkflow) LogStream(ctx context.Context, workflowName string, w http
 
 			fmt.Fprintf(w, "%s: %s\n", event.GetPodName(), event.GetContent())
 			w.(http.Flusher).Flush()
-			status, err := a.Status(ctx, workflowName)
+			// Only the phase is read, the nodes of large workflows are
+			// never read per line.
+			workflow, err := a.getWorkflow(ctx, workflowName, "status.phase")
 			if err != nil {
 				return err
 			}
 
-			if event == nil && status.Status != "running" && status.Status != "pending" {
+			phase := strings.ToLower(string(workflow.Status.Phase))
+			if event == nil && phase != "running" && phase != "pending" {
 				return nil
 			}
 		}
 	}
 }
 
-// Submit submits a workflow execution.
-func (a ArgoWorkflow) Submit(ctx context.Context, from string, parameters map[string]string, workflowLabels map[string]string) (string, error) {
+// Submit submits a workflow execution. Annotation values can't contain ','
+// or '=', Argo parses the annotations of submit options like labels.
+func (a ArgoWorkflow) Submit(ctx context.Context, from string, parameters map[string]string, workflowLabels map[string]string, annotations map[string]string, scheduling Scheduling) (string, error) {
 	parts := strings.SplitN(from, "/", 2)
 	for _, part := range parts {
 		if part == "" {
@@ -178,22 +725,64 @@ func (a ArgoWorkflow) Submit(ctx context.Context, from string, parameters map[st
 	kind := parts[0]
 	name := parts[1]
 
+	nameLabels := map[string]string{
+		LabelProject: parameters["project_name"],
+		LabelTarget:  parameters["target_name"],
+	}
+	for k, v := range workflowLabels {
+		nameLabels[k] = v
+	}
+	generateNamePrefix, err := a.names.Prefix(nameLabels, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	// Submit options can't constrain scheduling or label pods, so those
+	// workflows are created referencing the template instead.
+	pl := podLabels(workflowLabels)
+	isTemplate := kind == strings.ToLower(KindWorkflowTemplate) || kind == strings.ToLower(KindClusterWorkflowTemplate)
+	if !scheduling.IsZero() || (len(pl) > 0 && isTemplate) {
+		spec, err := newTemplateWorkflowSpec(from, parameters, scheduling)
+		if err != nil {
+			return "", err
+		}
+		if len(pl) > 0 {
+			spec.PodMetadata = &argoWorkflowAPISpec.Metadata{Labels: pl}
+		}
+
+		created, err := a.svc.CreateWorkflow(ctx, &argoWorkflowAPIClient.WorkflowCreateRequest{
+			Namespace: a.namespace,
+			Workflow: &argoWorkflowAPISpec.Workflow{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: generateNamePrefix, Namespace: a.namespace, Labels: workflowLabels, Annotations: annotations},
+				Spec:       spec,
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to submit workflow: %w", err)
+		}
+
+		return strings.ToLower(created.Name), nil
+	}
+
 	var parameterStrings []string
 	for k, v := range parameters {
 		parameterStrings = append(parameterStrings, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	generateNamePrefix := fmt.Sprintf("%s-%s-", parameters["project_name"], parameters["target_name"])
+	submitOptions := &argoWorkflowAPISpec.SubmitOpts{
+		GenerateName: generateNamePrefix,
+		Parameters:   parameterStrings,
+		Labels:       labels.FormatLabels(workflowLabels),
+	}
+	if len(annotations) > 0 {
+		submitOptions.Annotations = labels.FormatLabels(annotations)
+	}
 
 	created, err := a.svc.SubmitWorkflow(ctx, &argoWorkflowAPIClient.WorkflowSubmitRequest{
-		Namespace:    a.namespace,
-		ResourceKind: kind,
-		ResourceName: name,
-		SubmitOptions: &argoWorkflowAPISpec.SubmitOpts{
-			GenerateName: generateNamePrefix,
-			Parameters:   parameterStrings,
-			Labels:       labels.FormatLabels(workflowLabels),
-		},
+		Namespace:     a.namespace,
+		ResourceKind:  kind,
+		ResourceName:  name,
+		SubmitOptions: submitOptions,
 	})
 
 	if err != nil {
@@ -203,8 +792,17 @@ func (a ArgoWorkflow) Submit(ctx context.Context, from string, parameters map[st
 	return strings.ToLower(created.Name), nil
 }
 
+// Terminate immediately stops a workflow without running exit handlers.
+func (a ArgoWorkflow) Terminate(ctx context.Context, workflowName string) error {
+	_, err := a.svc.TerminateWorkflow(ctx, &argoWorkflowAPIClient.WorkflowTerminateRequest{
+		Name:      workflowName,
+		Namespace: a.namespace,
+	})
+	return err
+}
+
 // NewParameters creates workflow parameters.
-func NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, targetName, projectName string, cliParameters map[string]string, credentialsToken string) map[string]string {
+func NewParameters(environmentVariablesString, executeCommand, executeContainerImageURI, targetName, projectName string, cliParameters types.Parameters, credentialsToken string) map[string]string {
 	parameters := map[string]string{
 		"environment_variables_string": environmentVariablesString,
 		"execute_command":              executeCommand,
@@ -235,4 +833,7 @@ func NewParameters(environmentVariablesString, executeCommand, executeContainerI
 // CreateWorkflowResponse creates a workflow response.
 type CreateWorkflowResponse struct {
 	WorkflowName string `json:"workflow_name"`
+	ChangeTicket string `json:"change_ticket,omitempty"`
+	// Origin is the origin of the request, see types.Origin.
+	Origin *types.Origin `json:"origin,omitempty"`
 }
@@ -2,13 +2,23 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	argoWorkflowAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
+	argoWorkflowArchiveAPIClient "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflowarchive"
 	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -39,7 +49,8 @@ func TestArgoWorkflowsList(t *testing.T) {
 				"namespace",
 			)
 
-			out, err := argoWf.List(context.Background())
+			statuses, _, err := argoWf.List(context.Background(), nil, ListOptions{})
+			out := statusNames(statuses)
 
 			if err != nil {
 				if tt.errResult != nil && tt.errResult.Error() != err.Error() {
@@ -62,6 +73,164 @@ func TestArgoWorkflowsList(t *testing.T) {
 	}
 }
 
+// statusNames returns the names of the workflows of the statuses.
+func statusNames(statuses []Status) []string {
+	names := []string{}
+	for _, s := range statuses {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// listRecordingClient records the list requests of workflows, whose pages
+// are followed by the 'next' one.
+type listRecordingClient struct {
+	mockArgoClient
+	requests *[]*argoWorkflowAPIClient.WorkflowListRequest
+}
+
+func (m listRecordingClient) ListWorkflows(ctx context.Context, in *argoWorkflowAPIClient.WorkflowListRequest, opts ...grpc.CallOption) (*v1alpha1.WorkflowList, error) {
+	*m.requests = append(*m.requests, in)
+	list, err := m.mockArgoClient.ListWorkflows(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	list.Continue = "next"
+	return list, nil
+}
+
+func TestArgoListSelector(t *testing.T) {
+	var requests []*argoWorkflowAPIClient.WorkflowListRequest
+	argoWf := NewArgoWorkflow(listRecordingClient{requests: &requests}, "namespace")
+
+	selector := map[string][]string{LabelTarget: {"target1"}, LabelProject: {"project1", "formerproject1"}}
+	got, next, err := argoWf.List(context.Background(), selector, ListOptions{Limit: 2, Continue: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"testWorkflow1"}, statusNames(got)); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	// The continue token of Argo is returned unchanged.
+	if next != "next" {
+		t.Errorf("\nwant: %v\n got: %v", "next", next)
+	}
+
+	want := v1.ListOptions{
+		LabelSelector: "cello-project in (formerproject1,project1),cello-target in (target1)",
+		Limit:         2,
+		Continue:      "token",
+	}
+	if len(requests) != 1 {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if diff := cmp.Diff(want, *requests[0].ListOptions); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+	if requests[0].Fields != listFields {
+		t.Errorf("\nwant: %v\n got: %v", listFields, requests[0].Fields)
+	}
+}
+
+func TestArgoListByLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		result    []Status
+		errResult error
+	}{
+		{
+			name:   "list workflows by labels",
+			result: []Status{{Name: "testWorkflow1", Status: "running"}},
+		},
+		{
+			name:      "list workflows by labels error",
+			err:       fmt.Errorf("list error"),
+			errResult: fmt.Errorf("list error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argoWf := NewArgoWorkflow(
+				mockArgoClient{status: v1alpha1.WorkflowRunning, err: tt.err},
+				"namespace",
+			)
+
+			statuses, err := argoWf.ListByLabels(context.Background(), map[string]string{LabelProject: "project1"})
+			if err != nil {
+				if tt.errResult == nil || tt.errResult.Error() != err.Error() {
+					t.Errorf("\nwant: %v\n got: %v", tt.errResult, err)
+				}
+				return
+			}
+
+			if len(statuses) != len(tt.result) {
+				t.Fatalf("\nwant: %v\n got: %v", tt.result, statuses)
+			}
+			for i := range statuses {
+				if statuses[i].Name != tt.result[i].Name || statuses[i].Status != tt.result[i].Status {
+					t.Errorf("\nwant: %v\n got: %v", tt.result[i], statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	for status, want := range map[string]bool{
+		"":          true,
+		"pending":   true,
+		"running":   true,
+		"succeeded": false,
+		"failed":    false,
+		"error":     false,
+	} {
+		if got := IsActive(status); got != want {
+			t.Errorf("IsActive(%q): want %v, got %v", status, want, got)
+		}
+	}
+}
+
+// mockStatusWorkflow returns the statuses in order from Status, failing when
+// the status is "error".
+type mockStatusWorkflow struct {
+	Workflow
+	statuses []string
+	calls    int
+}
+
+func (m *mockStatusWorkflow) Status(ctx context.Context, workflowName string) (*Status, error) {
+	s := m.statuses[m.calls]
+	m.calls++
+	if s == "error" {
+		return nil, fmt.Errorf("status error")
+	}
+	return &Status{Name: workflowName, Status: s}, nil
+}
+
+func TestWaitForCompletion(t *testing.T) {
+	m := &mockStatusWorkflow{statuses: []string{"pending", "error", "running", "failed"}}
+	var errs int
+
+	status, err := WaitForCompletion(context.Background(), m, "workflow", time.Millisecond, func(error) { errs++ })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Status != "failed" {
+		t.Errorf("\nwant: %v\n got: %v", "failed", status.Status)
+	}
+	if m.calls != 4 || errs != 1 {
+		t.Errorf("want 4 calls and 1 error, got %d calls and %d errors", m.calls, errs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := WaitForCompletion(ctx, &mockStatusWorkflow{}, "workflow", time.Millisecond, func(error) {}); err != context.Canceled {
+		t.Errorf("\nwant: %v\n got: %v", context.Canceled, err)
+	}
+}
+
 func TestArgoStatus(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -117,6 +286,15 @@ func TestArgoStatus(t *testing.T) {
 				if !cmp.Equal(status.Status, tt.result) {
 					t.Errorf("\nwant: %v\n got: %v", tt.result, status.Status)
 				}
+				if status.Labels[LabelProject] != "project1" {
+					t.Errorf("\nwant: %v\n got: %v", "project1", status.Labels[LabelProject])
+				}
+				if status.Parameters["execute_command"] != "cdk diff" {
+					t.Errorf("\nwant: %v\n got: %v", "cdk diff", status.Parameters["execute_command"])
+				}
+				if status.NodesCompleted != 1 || status.NodesTotal != 3 {
+					t.Errorf("\nwant: %v\n got: %v", "1/3", fmt.Sprintf("%d/%d", status.NodesCompleted, status.NodesTotal))
+				}
 			}
 		})
 	}
@@ -147,7 +325,7 @@ func TestArgoSubmit(t *testing.T) {
 				"namespace",
 			)
 
-			workflow, err := argoWf.Submit(context.Background(), "test/test", map[string]string{"param": "value"}, map[string]string{"X-B3-TraceId": "test-txid"})
+			workflow, err := argoWf.Submit(context.Background(), "test/test", map[string]string{"param": "value"}, map[string]string{"X-B3-TraceId": "test-txid"}, nil, Scheduling{})
 			if err != nil {
 				if tt.errResult != nil && tt.errResult.Error() != err.Error() {
 					t.Errorf("\nwant: %v\n got: %v", tt.errResult, err)
@@ -164,25 +342,237 @@ func TestArgoSubmit(t *testing.T) {
 	}
 }
 
+func TestArgoTerminate(t *testing.T) {
+	argoWf := NewArgoWorkflow(mockArgoClient{}, "namespace")
+	if err := argoWf.Terminate(context.Background(), "workflow"); err != nil {
+		t.Errorf("\nwant: %v\n got: %v", nil, err)
+	}
+
+	argoWf = NewArgoWorkflow(mockArgoClient{err: fmt.Errorf("terminate error")}, "namespace")
+	if err := argoWf.Terminate(context.Background(), "workflow"); err == nil || err.Error() != "terminate error" {
+		t.Errorf("\nwant: %v\n got: %v", "terminate error", err)
+	}
+}
+
+// logTime returns the timestamp of the line logged the seconds after the
+// start of the test logs.
+func logTime(seconds float64) time.Time {
+	return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(seconds * float64(time.Second)))
+}
+
+func TestArgoLogs(t *testing.T) {
+	nodes := v1alpha1.Nodes{
+		"wf":   {ID: "wf", Type: v1alpha1.NodeTypeSteps, StartedAt: v1.Unix(5, 0)},
+		"wf-b": {ID: "wf-b", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(20, 0)},
+		"wf-a": {ID: "wf-a", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(10, 0)},
+	}
+	// The lines of the pods are interleaved, Argo streams them in any order.
+	logs := map[string][]podLogLine{
+		"wf-a": {{logTime(1.1), "init"}, {logTime(3.1), "plan"}, {logTime(3.1), "planned"}, {logTime(5.1), "apply"}},
+		"wf-b": {{logTime(2.1), "pull"}, {logTime(4.1), "push"}},
+	}
+
+	tests := []struct {
+		name string
+		opts LogOptions
+		want *Logs
+	}{
+		{
+			name: "all lines",
+			want: &Logs{Logs: []string{"wf-a: init", "wf-a: plan", "wf-a: planned", "wf-a: apply", "wf-b: pull", "wf-b: push"}},
+		},
+		{
+			name: "first chunk",
+			opts: LogOptions{MaxBytes: 25},
+			want: &Logs{
+				Logs: []string{"wf-a: init", "wf-a: plan"},
+				Next: LogPositions{"wf-a": {Time: logTime(3.1), Lines: 1}},
+			},
+		},
+		{
+			name: "continued chunk",
+			opts: LogOptions{From: LogPositions{"wf-a": {Time: logTime(3.1), Lines: 1}}, MaxBytes: 25},
+			want: &Logs{
+				Logs: []string{"wf-a: planned", "wf-a: apply"},
+				Next: LogPositions{"wf-a": {Time: logTime(5.1), Lines: 1}},
+			},
+		},
+		{
+			name: "chunk continued in the following pod",
+			opts: LogOptions{From: LogPositions{"wf-a": {Time: logTime(5.1), Lines: 1}}, MaxBytes: 25},
+			want: &Logs{Logs: []string{"wf-b: pull", "wf-b: push"}},
+		},
+		{
+			name: "line larger than chunk",
+			opts: LogOptions{MaxBytes: 1},
+			want: &Logs{
+				Logs: []string{"wf-a: init"},
+				Next: LogPositions{"wf-a": {Time: logTime(1.1), Lines: 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argoWf := NewArgoWorkflow(&podLogsArgoClient{nodes: nodes, logs: logs}, "namespace")
+			got, err := argoWf.Logs(context.Background(), "wf", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestArgoLogsInterleavedPods(t *testing.T) {
+	client := &podLogsArgoClient{
+		nodes: v1alpha1.Nodes{
+			"wf-a": {ID: "wf-a", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(10, 0)},
+			"wf-b": {ID: "wf-b", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(10, 0)},
+		},
+		logs: map[string][]podLogLine{
+			"wf-a": {{logTime(1.1), "a1"}, {logTime(3.1), "a2"}, {logTime(3.1), "a3"}},
+			"wf-b": {{logTime(1.5), "b1"}, {logTime(2.1), "b2"}, {logTime(4.1), "b3"}},
+		},
+	}
+	argoWf := NewArgoWorkflow(client, "namespace")
+
+	var lines []string
+	opts := LogOptions{MaxBytes: 10}
+	for i := 0; i < 10; i++ {
+		got, err := argoWf.Logs(context.Background(), "wf", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, got.Logs...)
+		if got.Next == nil {
+			break
+		}
+		opts.From = got.Next
+
+		// Lines logged by a pod whose logs were read are read in the
+		// following chunk.
+		if i == 3 {
+			client.logs["wf-a"] = append(client.logs["wf-a"], podLogLine{logTime(6.1), "a4"})
+		}
+	}
+
+	want := []string{"wf-a: a1", "wf-a: a2", "wf-a: a3", "wf-b: b1", "wf-a: a4", "wf-b: b2", "wf-b: b3"}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	// Pods are read from the second of their position rather than from
+	// their first line.
+	wantRequests := []string{
+		"wf-a",
+		"wf-a@00:00:01",
+		"wf-a@00:00:03", "wf-b",
+		"wf-a@00:00:03", "wf-b",
+		"wf-a@00:00:03", "wf-b@00:00:01",
+		"wf-a@00:00:06", "wf-b@00:00:01",
+		"wf-a@00:00:06", "wf-b@00:00:02",
+	}
+	if diff := cmp.Diff(wantRequests, client.requests); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+type podLogLine struct {
+	time    time.Time
+	content string
+}
+
+// podLogsArgoClient is an Argo client streaming the timestamped logs of the
+// pods of a workflow, a pod at a time, recording the pod and since time of
+// the logs requested.
+type podLogsArgoClient struct {
+	argoWorkflowAPIClient.WorkflowServiceClient
+	nodes    v1alpha1.Nodes
+	logs     map[string][]podLogLine
+	requests []string
+}
+
+func (m *podLogsArgoClient) GetWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowGetRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
+	return &v1alpha1.Workflow{
+		ObjectMeta: v1.ObjectMeta{Name: in.Name},
+		Status:     v1alpha1.WorkflowStatus{Phase: v1alpha1.WorkflowRunning, Nodes: m.nodes},
+	}, nil
+}
+
+func (m *podLogsArgoClient) WorkflowLogs(ctx context.Context, in *argoWorkflowAPIClient.WorkflowLogRequest, opts ...grpc.CallOption) (argoWorkflowAPIClient.WorkflowService_WorkflowLogsClient, error) {
+	request := in.PodName
+	var since time.Time
+	if in.LogOptions.SinceTime != nil {
+		// Kubernetes reads logs from the second of the since time.
+		since = in.LogOptions.SinceTime.Time.Truncate(time.Second)
+		request += "@" + since.Format("15:04:05")
+	}
+	m.requests = append(m.requests, request)
+
+	var entries []*argoWorkflowAPIClient.LogEntry
+	for _, l := range m.logs[in.PodName] {
+		if l.time.Before(since) {
+			continue
+		}
+		content := l.content
+		if in.LogOptions.Timestamps {
+			content = l.time.Format(time.RFC3339Nano) + " " + content
+		}
+		entries = append(entries, &argoWorkflowAPIClient.LogEntry{PodName: in.PodName, Content: content})
+	}
+	return &mockLogsClient{entries: entries}, nil
+}
+
 type mockArgoClient struct {
 	argoWorkflowAPIClient.WorkflowServiceClient
 	status v1alpha1.WorkflowPhase
 	err    error
 }
 
+func (m mockArgoClient) WorkflowLogs(ctx context.Context, in *argoWorkflowAPIClient.WorkflowLogRequest, opts ...grpc.CallOption) (argoWorkflowAPIClient.WorkflowService_WorkflowLogsClient, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &mockLogsClient{}, nil
+}
+
+type mockLogsClient struct {
+	grpc.ClientStream
+	entries []*argoWorkflowAPIClient.LogEntry
+}
+
+func (m *mockLogsClient) Recv() (*argoWorkflowAPIClient.LogEntry, error) {
+	if len(m.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := m.entries[0]
+	m.entries = m.entries[1:]
+	return entry, nil
+}
+
 func (m mockArgoClient) ListWorkflows(ctx context.Context, in *argoWorkflowAPIClient.WorkflowListRequest, opts ...grpc.CallOption) (*v1alpha1.WorkflowList, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	return &v1alpha1.WorkflowList{Items: []v1alpha1.Workflow{
-		{TypeMeta: v1.TypeMeta{}, ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1"}}}}, nil
+		{TypeMeta: v1.TypeMeta{}, ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1"}, Status: v1alpha1.WorkflowStatus{Phase: m.status}}}}, nil
 }
 
 func (m mockArgoClient) GetWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowGetRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
-	return &v1alpha1.Workflow{TypeMeta: v1.TypeMeta{}, ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1"}, Status: v1alpha1.WorkflowStatus{Phase: m.status}}, nil
+	return &v1alpha1.Workflow{
+		TypeMeta:   v1.TypeMeta{},
+		ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1", Labels: map[string]string{LabelProject: "project1"}},
+		Spec: v1alpha1.WorkflowSpec{Arguments: v1alpha1.Arguments{Parameters: []v1alpha1.Parameter{
+			{Name: "execute_command", Value: v1alpha1.AnyStringPtr("cdk diff")},
+		}}},
+		Status: v1alpha1.WorkflowStatus{Phase: m.status, Progress: "1/3"},
+	}, nil
 }
 
 func (m mockArgoClient) SubmitWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowSubmitRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
@@ -191,3 +581,212 @@ func (m mockArgoClient) SubmitWorkflow(ctx context.Context, in *argoWorkflowAPIC
 	}
 	return &v1alpha1.Workflow{TypeMeta: v1.TypeMeta{}, ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1"}, Status: v1alpha1.WorkflowStatus{Phase: m.status}}, nil
 }
+
+func (m mockArgoClient) TerminateWorkflow(ctx context.Context, in *argoWorkflowAPIClient.WorkflowTerminateRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &v1alpha1.Workflow{TypeMeta: v1.TypeMeta{}, ObjectMeta: v1.ObjectMeta{Name: in.Name}, Status: v1alpha1.WorkflowStatus{Phase: v1alpha1.WorkflowFailed}}, nil
+}
+
+func TestArgoArchiveFallback(t *testing.T) {
+	notFound := status.Error(codes.NotFound, "workflow not found")
+	archive := &mockArchiveClient{
+		workflows: []v1alpha1.Workflow{
+			{ObjectMeta: v1.ObjectMeta{Name: "other", UID: "uid-other"}},
+			{
+				ObjectMeta: v1.ObjectMeta{Name: "archived", UID: "uid-archived", Labels: map[string]string{LabelProject: "project1"}},
+				Status: v1alpha1.WorkflowStatus{
+					Phase: v1alpha1.WorkflowSucceeded,
+					Nodes: v1alpha1.Nodes{
+						"archived-2": {ID: "archived-2", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(20, 0), Outputs: &v1alpha1.Outputs{Artifacts: v1alpha1.Artifacts{{Name: mainLogsArtifact}}}},
+						"archived-1": {ID: "archived-1", Type: v1alpha1.NodeTypePod, StartedAt: v1.Unix(10, 0), Outputs: &v1alpha1.Outputs{Artifacts: v1alpha1.Artifacts{{Name: mainLogsArtifact}}}},
+						"archived":   {ID: "archived", Type: v1alpha1.NodeTypeSteps},
+					},
+				},
+			},
+			// Archived while still live.
+			{ObjectMeta: v1.ObjectMeta{Name: "testWorkflow1", UID: "uid-testWorkflow1"}},
+		},
+	}
+	artifacts := mockArtifactReader{
+		"uid-archived/archived-1/main-logs": "init\nplan\n",
+		"uid-archived/archived-2/main-logs": "apply\n",
+	}
+	argoWf := NewArgoWorkflow(mockArgoClient{err: notFound}, "namespace", WithArchive(archive, artifacts))
+
+	t.Run("status", func(t *testing.T) {
+		got, err := argoWf.Status(context.Background(), "archived")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "succeeded" || got.Labels[LabelProject] != "project1" {
+			t.Errorf("unexpected status %+v", got)
+		}
+	})
+
+	t.Run("status not archived", func(t *testing.T) {
+		if _, err := argoWf.Status(context.Background(), "missing"); status.Code(err) != codes.NotFound {
+			t.Errorf("\nwant: %v\n got: %v", notFound, err)
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		got, err := argoWf.Logs(context.Background(), "archived", LogOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &Logs{Logs: []string{"archived-1: init", "archived-1: plan", "archived-2: apply"}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		got, err = argoWf.Logs(context.Background(), "archived", LogOptions{From: LogPositions{"archived-1": {Lines: 1}}, MaxBytes: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = &Logs{Logs: []string{"archived-1: plan"}, Next: LogPositions{"archived-1": {Lines: 2, Done: true}}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		// Pods whose logs were all read aren't read again.
+		got, err = argoWf.Logs(context.Background(), "archived", LogOptions{From: LogPositions{"archived-1": {Lines: 2, Done: true}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = &Logs{Logs: []string{"archived-2: apply"}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("logs not archived", func(t *testing.T) {
+		if _, err := argoWf.Logs(context.Background(), "other", LogOptions{}); !errors.Is(err, ErrLogsNotArchived) {
+			t.Errorf("\nwant: %v\n got: %v", ErrLogsNotArchived, err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		argoWf := NewArgoWorkflow(mockArgoClient{}, "namespace", WithArchive(archive, artifacts))
+		got, next, err := argoWf.List(context.Background(), nil, ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"testWorkflow1", "other", "archived"}, statusNames(got)); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+		if next != "" {
+			t.Errorf("unexpected continue token %q", next)
+		}
+	})
+
+	t.Run("list pages", func(t *testing.T) {
+		argoWf := NewArgoWorkflow(mockArgoClient{}, "namespace", WithArchive(archive, artifacts))
+		var pages [][]string
+		opts := ListOptions{Limit: 1}
+		for {
+			got, next, err := argoWf.List(context.Background(), nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages = append(pages, statusNames(got))
+			if next == "" {
+				break
+			}
+			opts.Continue = next
+		}
+		want := [][]string{{"testWorkflow1"}, {"other"}, {"archived"}, {}}
+		if diff := cmp.Diff(want, pages); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("list by labels", func(t *testing.T) {
+		argoWf := NewArgoWorkflow(mockArgoClient{}, "namespace", WithArchive(archive, artifacts))
+		got, err := argoWf.ListByLabels(context.Background(), map[string]string{LabelProject: "project1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, s := range got {
+			names = append(names, s.Name)
+		}
+		if diff := cmp.Diff([]string{"testWorkflow1", "other", "archived"}, names); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+		if got[2].Labels[LabelProject] != "project1" {
+			t.Errorf("unexpected labels %v", got[2].Labels)
+		}
+	})
+}
+
+type mockArchiveClient struct {
+	argoWorkflowArchiveAPIClient.ArchivedWorkflowServiceClient
+	workflows []v1alpha1.Workflow
+}
+
+// ListArchivedWorkflows lists a workflow per page, without their labels and
+// nodes like the archive.
+func (m *mockArchiveClient) ListArchivedWorkflows(ctx context.Context, in *argoWorkflowArchiveAPIClient.ListArchivedWorkflowsRequest, opts ...grpc.CallOption) (*v1alpha1.WorkflowList, error) {
+	offset := 0
+	if in.ListOptions.Continue != "" {
+		offset, _ = strconv.Atoi(in.ListOptions.Continue)
+	}
+
+	list := &v1alpha1.WorkflowList{}
+	end := len(m.workflows)
+	if in.ListOptions.Limit > 0 && offset+1 < end {
+		end = offset + 1
+		list.Continue = strconv.Itoa(end)
+	}
+	for _, wf := range m.workflows[offset:end] {
+		list.Items = append(list.Items, v1alpha1.Workflow{ObjectMeta: v1.ObjectMeta{Name: wf.Name, UID: wf.UID}, Status: v1alpha1.WorkflowStatus{Phase: wf.Status.Phase}})
+	}
+	return list, nil
+}
+
+func (m *mockArchiveClient) GetArchivedWorkflow(ctx context.Context, in *argoWorkflowArchiveAPIClient.GetArchivedWorkflowRequest, opts ...grpc.CallOption) (*v1alpha1.Workflow, error) {
+	for i := range m.workflows {
+		if string(m.workflows[i].UID) == in.Uid {
+			return &m.workflows[i], nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "not found")
+}
+
+type mockArtifactReader map[string]string
+
+func (m mockArtifactReader) OutputArtifact(ctx context.Context, uid, nodeID, artifactName string) (io.ReadCloser, error) {
+	content, ok := m[uid+"/"+nodeID+"/"+artifactName]
+	if !ok {
+		return nil, fmt.Errorf("artifact not found")
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestHTTPArtifactReader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifacts-by-uid/uid/node/main-logs" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "line\n")
+	}))
+	defer srv.Close()
+
+	reader := NewHTTPArtifactReader(srv.URL+"/", "Bearer token", srv.Client())
+	r, err := reader.OutputArtifact(context.Background(), "uid", "node", "main-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, _ := io.ReadAll(r)
+	if string(content) != "line\n" {
+		t.Errorf("\nwant: %q\n got: %q", "line\n", content)
+	}
+
+	if _, err := reader.OutputArtifact(context.Background(), "uid", "other", "main-logs"); err == nil {
+		t.Error("expected error, received nil")
+	}
+}
@@ -0,0 +1,84 @@
+// Package workload reads the workload identities (IRSA service accounts)
+// workflow pods can run with.
+package workload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// RoleARNAnnotation is the annotation of IRSA service accounts naming the IAM
+// role their pods assume.
+const RoleARNAnnotation = "eks.amazonaws.com/role-arn"
+
+var (
+	// ErrServiceAccountNotFound conveys that the service account doesn't
+	// exist.
+	ErrServiceAccountNotFound = errors.New("not found")
+	// ErrRoleMismatch conveys that the service account assumes another role.
+	ErrRoleMismatch = errors.New("assumes role")
+)
+
+// ServiceAccounts reads the IAM roles of service accounts.
+type ServiceAccounts interface {
+	// RoleARN returns the IAM role of a service account, empty when it isn't
+	// annotated with one.
+	RoleARN(ctx context.Context, name string) (string, error)
+}
+
+// NewKubernetesServiceAccounts reads service accounts of the namespace.
+func NewKubernetesServiceAccounts(cl corev1client.ServiceAccountsGetter, namespace string) ServiceAccounts {
+	return kubernetesServiceAccounts{cl: cl, namespace: namespace}
+}
+
+// NewInClusterServiceAccounts reads service accounts of the namespace of the
+// cluster the service runs in.
+func NewInClusterServiceAccounts(namespace string) (ServiceAccounts, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in cluster config: %w", err)
+	}
+
+	cl, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return NewKubernetesServiceAccounts(cl.CoreV1(), namespace), nil
+}
+
+type kubernetesServiceAccounts struct {
+	cl        corev1client.ServiceAccountsGetter
+	namespace string
+}
+
+func (k kubernetesServiceAccounts) RoleARN(ctx context.Context, name string) (string, error) {
+	sa, err := k.cl.ServiceAccounts(k.namespace).Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("service account '%s' %w", name, ErrServiceAccountNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get service account: %w", err)
+	}
+	return sa.Annotations[RoleARNAnnotation], nil
+}
+
+// ValidateRole validates the service account assumes the role. The error
+// wraps ErrServiceAccountNotFound or ErrRoleMismatch when it doesn't.
+func ValidateRole(ctx context.Context, sas ServiceAccounts, name, roleARN string) error {
+	role, err := sas.RoleARN(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if role != roleARN {
+		return fmt.Errorf("service account '%s' %w '%s', not '%s'", name, ErrRoleMismatch, role, roleARN)
+	}
+	return nil
+}
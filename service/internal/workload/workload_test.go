@@ -0,0 +1,85 @@
+package workload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+func TestValidateRole(t *testing.T) {
+	cl := mockServiceAccountsGetter{
+		"deploy": {ObjectMeta: metav1.ObjectMeta{
+			Name:        "deploy",
+			Annotations: map[string]string{RoleARNAnnotation: "arn:aws:iam::012345678901:role/deploy"},
+		}},
+		"default": {ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	}
+	sas := NewKubernetesServiceAccounts(cl, "argo")
+
+	tests := []struct {
+		name           string
+		serviceAccount string
+		roleARN        string
+		wantErr        string
+	}{
+		{
+			name:           "matching role",
+			serviceAccount: "deploy",
+			roleARN:        "arn:aws:iam::012345678901:role/deploy",
+		},
+		{
+			name:           "other role",
+			serviceAccount: "deploy",
+			roleARN:        "arn:aws:iam::012345678901:role/other",
+			wantErr:        "service account 'deploy' assumes role 'arn:aws:iam::012345678901:role/deploy', not 'arn:aws:iam::012345678901:role/other'",
+		},
+		{
+			name:           "no role",
+			serviceAccount: "default",
+			roleARN:        "arn:aws:iam::012345678901:role/deploy",
+			wantErr:        "service account 'default' assumes role '', not 'arn:aws:iam::012345678901:role/deploy'",
+		},
+		{
+			name:           "not found",
+			serviceAccount: "missing",
+			roleARN:        "arn:aws:iam::012345678901:role/deploy",
+			wantErr:        "service account 'missing' not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRole(context.Background(), sas, tt.serviceAccount, tt.roleARN)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+type mockServiceAccountsGetter map[string]*v1.ServiceAccount
+
+func (m mockServiceAccountsGetter) ServiceAccounts(namespace string) corev1client.ServiceAccountInterface {
+	return mockServiceAccountClient{serviceAccounts: m}
+}
+
+type mockServiceAccountClient struct {
+	corev1client.ServiceAccountInterface
+	serviceAccounts map[string]*v1.ServiceAccount
+}
+
+func (m mockServiceAccountClient) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ServiceAccount, error) {
+	sa, ok := m.serviceAccounts[name]
+	if !ok {
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "serviceaccounts"}, name)
+	}
+	return sa, nil
+}
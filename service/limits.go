@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/messages"
+)
+
+// Stages of requests with limits, named with the limit in the error responses
+// of requests exceeding them.
+const (
+	stageRequestBody = "request body"
+	stageGitFetch    = "git fetch"
+	stageVault       = "vault"
+	stageArgoSubmit  = "argo submit"
+	stageBundle      = "manifest bundle"
+)
+
+// limitError is the error of a request exceeding the limit of a stage.
+type limitError struct {
+	stage string
+	limit string
+	err   error
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s exceeded the limit of %s", e.stage, e.limit)
+}
+
+func (e *limitError) Unwrap() error {
+	return e.err
+}
+
+// stageTimeout returns the timeout of the stage, zero when disabled. Vault
+// calls time out with the timeout of the Vault client.
+func (h handler) stageTimeout(stage string) time.Duration {
+	switch stage {
+	case stageGitFetch:
+		return h.env.GitFetchTimeout
+	case stageVault:
+		return h.env.VaultTimeout
+	case stageArgoSubmit:
+		return h.env.ArgoSubmitTimeout
+	default:
+		return 0
+	}
+}
+
+// stageContext returns the context of the stage, timing out after the
+// timeout of the stage.
+func (h handler) stageContext(ctx context.Context, stage string) (context.Context, context.CancelFunc) {
+	timeout := h.stageTimeout(stage)
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// stageError returns the error of the stage as a limitError when the stage
+// timed out, either its context or a call of it, unchanged otherwise.
+func (h handler) stageError(ctx context.Context, stage string, err error) error {
+	timeout := h.stageTimeout(stage)
+	if err == nil || timeout == 0 {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return &limitError{stage: stage, limit: timeout.String(), err: err}
+	}
+	return err
+}
+
+// stageErrorResponse writes the error response of an error of the stage. When
+// the stage timed out, the response is a 504 naming the stage and its limit
+// instead of the message.
+func (h handler) stageErrorResponse(ctx context.Context, w http.ResponseWriter, r *http.Request, stage string, err error, message string, httpStatus int) {
+	var le *limitError
+	if errors.As(h.stageError(ctx, stage, err), &le) {
+		h.limitErrorResponse(w, r, le, http.StatusGatewayTimeout)
+		return
+	}
+	h.errorResponse(w, message, httpStatus)
+}
+
+// limitErrorResponse writes the error response of a request exceeding the
+// limit of a stage.
+func (h handler) limitErrorResponse(w http.ResponseWriter, r *http.Request, le *limitError, httpStatus int) {
+	h.writeErrorResponse(w, r, errorResponse{
+		Stage:  le.stage,
+		Limit:  le.limit,
+		Code:   string(messages.LimitExceeded),
+		Params: messages.Params{"stage": le.stage, "limit": le.limit},
+	}, httpStatus)
+}
+
+// bodyLimitMiddleware rejects requests whose body exceeds
+// MaxRequestBodyBytes with a 413 naming the limit. Bodies are read upfront,
+// so handlers and the recorder read them as usual.
+func (h handler) bodyLimitMiddleware(next http.Handler) http.Handler {
+	limit := h.env.MaxRequestBodyBytes
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		le := &limitError{stage: stageRequestBody, limit: fmt.Sprintf("%d bytes", limit)}
+		if r.ContentLength > limit {
+			h.limitErrorResponse(w, r, le, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			h.errorResponse(w, "error reading request data", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > limit {
+			h.limitErrorResponse(w, r, le, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/env"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestStageError(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		timeout   time.Duration
+		err       error
+		wantLimit string
+	}{
+		{
+			name:      "context_deadline",
+			ctx:       expired,
+			timeout:   time.Minute,
+			err:       errors.New("failed to fetch"),
+			wantLimit: "1m0s",
+		},
+		{
+			name:      "client_timeout",
+			ctx:       context.Background(),
+			timeout:   30 * time.Second,
+			err:       &url.Error{Op: "Put", URL: "https://vault", Err: timeoutError{}},
+			wantLimit: "30s",
+		},
+		{
+			name:    "other_error",
+			ctx:     context.Background(),
+			timeout: time.Minute,
+			err:     errors.New("permission denied"),
+		},
+		{
+			name: "disabled",
+			ctx:  expired,
+			err:  context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler{env: env.Vars{VaultTimeout: tt.timeout}}
+			err := h.stageError(tt.ctx, stageVault, tt.err)
+
+			var le *limitError
+			if tt.wantLimit == "" {
+				assert.False(t, errors.As(err, &le))
+				assert.Equal(t, tt.err, err)
+				return
+			}
+			if assert.True(t, errors.As(err, &le)) {
+				assert.Equal(t, stageVault, le.stage)
+				assert.Equal(t, tt.wantLimit, le.limit)
+				assert.True(t, errors.Is(err, tt.err))
+			}
+		})
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	h := handler{env: env.Vars{MaxRequestBodyBytes: 8}}
+	next := h.bodyLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+
+	tests := []struct {
+		name     string
+		body     string
+		chunked  bool
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "within_limit",
+			body:     "12345678",
+			wantCode: http.StatusOK,
+			wantBody: "12345678",
+		},
+		{
+			name:     "content_length_exceeds_limit",
+			body:     "123456789",
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"error_message":"request body exceeded the limit of 8 bytes","stage":"request body","limit":"8 bytes","code":"limit_exceeded","params":{"limit":"8 bytes","stage":"request body"}}`,
+		},
+		{
+			name:     "chunked_body_exceeds_limit",
+			body:     "123456789",
+			chunked:  true,
+			wantCode: http.StatusRequestEntityTooLarge,
+			wantBody: `{"error_message":"request body exceeded the limit of 8 bytes","stage":"request body","limit":"8 bytes","code":"limit_exceeded","params":{"limit":"8 bytes","stage":"request body"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/workflows", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			next.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/logarchive"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// Maximum time a workflow is watched to archive its logs.
+const logArchiveWatchTimeout = 24 * time.Hour
+
+// Watches a workflow until it completes to archive its logs, see archiveLogs.
+func (h handler) watchLogArchive(l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	if h.logArchive == nil {
+		return
+	}
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, logArchiveWatchTimeout)
+		defer cancel()
+		h.archiveLogs(ctx, l, txID, cwr, workflowName)
+	}()
+}
+
+// Archives the logs of a completed workflow, whatever its outcome, uploaded as
+// they're read and compressed, and records a 'logs_archived' execution event.
+// Failures are logged, logs not archived are still read from Argo.
+func (h handler) archiveLogs(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	_, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.LogArchiveWatchInterval, func(err error) {
+		level.Warn(l).Log("message", "error getting workflow status", "error", err)
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error waiting for workflow to complete, logs not archived", "error", err)
+		return
+	}
+
+	idx, err := logarchive.Archive(ctx, h.logArchive, workflowName, h.env.LogArchiveEncoding, h.env.LogArchiveFrameBytes, func(fn func(line string) bool) error {
+		return h.argo.WalkLogs(ctx, workflowName, fn)
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error archiving workflow logs", "error", err)
+		return
+	}
+
+	level.Info(l).Log("message", "workflow logs archived", "lines", idx.Lines, "bytes", idx.Bytes, "size", idx.Size, "encoding", idx.Encoding)
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "logs_archived",
+		Message:      fmt.Sprintf("archived %d lines of %d bytes as %d bytes of %s in %d frames", idx.Lines, idx.Bytes, idx.Size, idx.Encoding, len(idx.Frames)),
+		CreatedAt:    h.now().UTC(),
+	})
+}
+
+// Returns the whole archived logs of a workflow as text. The archive is
+// returned as stored when the request accepts its encoding, decompressed
+// otherwise.
+func (h handler) getWorkflowLogArchive(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+
+	l := rs.log("op", "get-workflow-log-archive", "workflow", workflowName)
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	idx, err := logarchive.ReadIndex(rs.ctx, h.logArchive, workflowName)
+	if errors.Is(err, logarchive.ErrNotFound) {
+		h.errorResponse(w, "workflow logs are not archived", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading log archive index", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.logArchive.Get(rs.ctx, logarchive.DataKey(workflowName, idx.Encoding), 0, -1)
+	if err != nil {
+		level.Error(l).Log("message", "error reading log archive", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+	defer data.Close()
+
+	var body io.Reader = data
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptedEncoding(r, idx.Encoding) != "" {
+		w.Header().Set("Content-Encoding", idx.Encoding)
+		w.Header().Set("Content-Length", fmt.Sprint(idx.Size))
+	} else {
+		dr, err := logarchive.NewReader(data, idx.Encoding)
+		if err != nil {
+			level.Error(l).Log("message", "error decompressing log archive", "error", err)
+			h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+			return
+		}
+		defer dr.Close()
+		body = dr
+		w.Header().Set("Content-Length", fmt.Sprint(idx.Bytes))
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if _, err := io.Copy(w, body); err != nil {
+		level.Error(l).Log("message", "error writing archived workflow logs", "error", err)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/logarchive"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+var errInvalidContinueToken = errors.New("continue token is invalid")
+
+// encodeContinueToken returns the continue token of the nodes of the workflow
+// following the offset.
+func encodeContinueToken(workflowName string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s/%d", workflowName, offset)))
+}
+
+// decodeContinueToken returns the offset of the continue token, which must be
+// a token of the nodes of the workflow.
+func decodeContinueToken(workflowName, token string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errInvalidContinueToken
+	}
+
+	i := strings.LastIndex(string(data), "/")
+	if i < 0 || string(data[:i]) != workflowName {
+		return 0, errInvalidContinueToken
+	}
+	offset, err := strconv.Atoi(string(data[i+1:]))
+	if err != nil || offset <= 0 {
+		return 0, errInvalidContinueToken
+	}
+	return offset, nil
+}
+
+// logsPosition is the position of a chunk of the logs of a workflow, the
+// offset of its first line in the log archive, or the positions of the lines
+// of the pods of the workflow read from Argo.
+type logsPosition struct {
+	Offset int                   `json:"offset,omitempty"`
+	Pods   workflow.LogPositions `json:"pods,omitempty"`
+}
+
+// encodeLogsToken returns the continue token of the logs of the workflow
+// following the position.
+func encodeLogsToken(workflowName string, pos logsPosition) (string, error) {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(append([]byte(workflowName+"/"), data...)), nil
+}
+
+// decodeLogsToken returns the position of the continue token, which must be a
+// token of the logs of the workflow.
+func decodeLogsToken(workflowName, token string) (logsPosition, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return logsPosition{}, errInvalidContinueToken
+	}
+
+	// Workflow names never contain a slash.
+	i := strings.Index(string(data), "/")
+	if i < 0 || string(data[:i]) != workflowName {
+		return logsPosition{}, errInvalidContinueToken
+	}
+	var pos logsPosition
+	if err := json.Unmarshal(data[i+1:], &pos); err != nil || pos.Offset < 0 || (pos.Offset == 0) == (len(pos.Pods) == 0) {
+		return logsPosition{}, errInvalidContinueToken
+	}
+	return pos, nil
+}
+
+// logOptions returns the position and maximum size of the chunk of logs of
+// the workflow requested by the continue and max_bytes query parameters.
+// Chunks are at most LogChunkMaxBytes, whatever max_bytes is.
+func (h handler) logOptions(r *http.Request, workflowName string) (logsPosition, int, error) {
+	var pos logsPosition
+	maxBytes := h.env.LogChunkMaxBytes
+
+	q := r.URL.Query()
+	if token := q.Get("continue"); token != "" {
+		var err error
+		pos, err = decodeLogsToken(workflowName, token)
+		if err != nil {
+			return logsPosition{}, 0, err
+		}
+	}
+
+	if v := q.Get("max_bytes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return logsPosition{}, 0, errors.New("max_bytes must be a positive integer")
+		}
+		if maxBytes == 0 || n < maxBytes {
+			maxBytes = n
+		}
+	}
+	return pos, maxBytes, nil
+}
+
+// writeWorkflowLogs writes the chunk of the logs of the workflow requested,
+// with the continue token of the following chunk unless the logs were
+// complete.
+func (h handler) writeWorkflowLogs(w http.ResponseWriter, r *http.Request, l log.Logger, workflowName string) {
+	rs := h.scope(r)
+
+	pos, maxBytes, err := h.logOptions(r, workflowName)
+	if err != nil {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "retrieving workflow logs", "offset", pos.Offset, "pods", len(pos.Pods), "max-bytes", maxBytes)
+	lines, next, err := h.workflowLogs(rs.ctx, l, workflowName, pos, maxBytes)
+	if errors.Is(err, errInvalidContinueToken) {
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, workflow.ErrLogsNotArchived) {
+		h.errorResponse(w, "workflow logs are not archived", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, workflow.ErrNodesOffloaded) {
+		h.errorResponse(w, "workflow nodes are offloaded, reading logs requires ARGO_CLOUDOPS_ARGO_ARTIFACTS_URL", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logs", "error", err)
+		h.errorResponse(w, "error getting workflow logs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetLogs{Logs: lines}
+	if next != nil {
+		resp.Continue, err = encodeLogsToken(workflowName, *next)
+		if err != nil {
+			level.Error(l).Log("message", "error encoding continue token", "error", err)
+			h.errorResponse(w, "error serializing workflow logs", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow logs", "error", err)
+		h.errorResponse(w, "error serializing workflow logs", http.StatusInternalServerError)
+		return
+	}
+	if err := writeEncoded(w, r, append(jsonData, '\n')); err != nil {
+		level.Error(l).Log("message", "error writing workflow logs", "error", err)
+	}
+}
+
+// workflowLogs returns the chunk of the logs of the workflow from the
+// position, with the position of the following chunk unless the logs were
+// complete. Logs are read from the log archive once archived, except chunks
+// continuing logs read from Argo, whose lines are ordered differently. Errors
+// reading the index of the archive are logged and the logs read from Argo.
+func (h handler) workflowLogs(ctx context.Context, l log.Logger, workflowName string, pos logsPosition, maxBytes int) ([]string, *logsPosition, error) {
+	if h.logArchive != nil && pos.Pods == nil {
+		idx, err := logarchive.ReadIndex(ctx, h.logArchive, workflowName)
+		if err == nil {
+			lines, next, err := logarchive.ReadLines(ctx, h.logArchive, workflowName, idx, pos.Offset, maxBytes)
+			if err != nil || next == 0 {
+				return lines, nil, err
+			}
+			return lines, &logsPosition{Offset: next}, nil
+		}
+		if !errors.Is(err, logarchive.ErrNotFound) {
+			level.Warn(l).Log("message", "error reading log archive index, reading logs from argo", "error", err)
+		}
+	}
+	if pos.Offset > 0 {
+		// Offsets are positions in the log archive only.
+		return nil, nil, errInvalidContinueToken
+	}
+
+	logs, err := h.argo.Logs(ctx, workflowName, workflow.LogOptions{From: pos.Pods, MaxBytes: maxBytes})
+	if err != nil || logs == nil {
+		return nil, nil, err
+	}
+	if logs.Next == nil {
+		return logs.Logs, nil, nil
+	}
+	return logs.Logs, &logsPosition{Pods: logs.Next}, nil
+}
+
+// acceptedEncoding returns the first of the encodings the Accept-Encoding
+// header of the request accepts, empty when it accepts none.
+func acceptedEncoding(r *http.Request, encodings ...string) string {
+	accepted := map[string]float64{}
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			params := strings.Split(part, ";")
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			q := 1.0
+			for _, p := range params[1:] {
+				kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+				if len(kv) == 2 && kv[0] == "q" {
+					if f, err := strconv.ParseFloat(kv[1], 64); err == nil {
+						q = f
+					}
+				}
+			}
+			accepted[coding] = q
+		}
+	}
+
+	for _, e := range encodings {
+		q, ok := accepted[e]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			return e
+		}
+	}
+	return ""
+}
+
+// writeEncoded writes the data compressed with the first encoding of log
+// archives the request accepts, as is when it accepts none.
+func writeEncoded(w http.ResponseWriter, r *http.Request, data []byte) error {
+	w.Header().Add("Vary", "Accept-Encoding")
+	encoding := acceptedEncoding(r, logarchive.Encodings...)
+	if encoding == "" {
+		_, err := w.Write(data)
+		return err
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	enc, err := logarchive.NewEncoder(w, encoding)
+	if err != nil {
+		return err
+	}
+	if _, err := enc.Write(data); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/logarchive"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding []string
+		want           string
+	}{
+		{name: "none", want: ""},
+		{name: "preferred", acceptEncoding: []string{"gzip, deflate, br, zstd"}, want: logarchive.Zstd},
+		{name: "only_gzip", acceptEncoding: []string{"gzip, deflate"}, want: logarchive.Gzip},
+		{name: "refused", acceptEncoding: []string{"zstd;q=0, gzip;q=0.5"}, want: logarchive.Gzip},
+		{name: "wildcard", acceptEncoding: []string{"*"}, want: logarchive.Zstd},
+		{name: "wildcard_refused", acceptEncoding: []string{"gzip;q=0, *;q=0"}, want: ""},
+		{name: "multiple_headers", acceptEncoding: []string{"br", "GZIP"}, want: logarchive.Gzip},
+		{name: "identity", acceptEncoding: []string{"identity"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/workflows/wf/logs", nil)
+			for _, v := range tt.acceptEncoding {
+				r.Header.Add("Accept-Encoding", v)
+			}
+			assert.Equal(t, tt.want, acceptedEncoding(r, logarchive.Encodings...))
+		})
+	}
+}
+
+func TestLogsToken(t *testing.T) {
+	pos := logsPosition{Pods: workflow.LogPositions{
+		"wf-a": {Time: time.Date(2021, 1, 1, 0, 0, 3, 100, time.UTC), Lines: 2},
+		"wf-b": {Lines: 4, Done: true},
+	}}
+	token, err := encodeLogsToken("wf", pos)
+	assert.Nil(t, err)
+
+	got, err := decodeLogsToken("wf", token)
+	assert.Nil(t, err)
+	assert.Equal(t, pos, got)
+
+	_, err = decodeLogsToken("other", token)
+	assert.Equal(t, errInvalidContinueToken, err)
+
+	token, err = encodeLogsToken("wf", logsPosition{Offset: 10})
+	assert.Nil(t, err)
+	got, err = decodeLogsToken("wf", token)
+	assert.Nil(t, err)
+	assert.Equal(t, logsPosition{Offset: 10}, got)
+
+	for _, token := range []string{"not-a-token", encodeContinueToken("wf", 10)} {
+		_, err = decodeLogsToken("wf", token)
+		assert.Equal(t, errInvalidContinueToken, err)
+	}
+}
@@ -4,20 +4,59 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cello-proj/cello/internal/validations"
+	"github.com/cello-proj/cello/service/internal/anomaly"
+	"github.com/cello-proj/cello/service/internal/audit"
+	"github.com/cello-proj/cello/service/internal/circuitbreaker"
+	"github.com/cello-proj/cello/service/internal/clock"
 	"github.com/cello-proj/cello/service/internal/credentials"
 	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/discovery"
 	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/feature"
 	"github.com/cello-proj/cello/service/internal/git"
+	"github.com/cello-proj/cello/service/internal/guardrail"
+	"github.com/cello-proj/cello/service/internal/health"
+	"github.com/cello-proj/cello/service/internal/itsm"
+	"github.com/cello-proj/cello/service/internal/logarchive"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+	"github.com/cello-proj/cello/service/internal/oci"
+	"github.com/cello-proj/cello/service/internal/outbound"
+	"github.com/cello-proj/cello/service/internal/recorder"
+	"github.com/cello-proj/cello/service/internal/redact"
+	"github.com/cello-proj/cello/service/internal/secretscan"
+	"github.com/cello-proj/cello/service/internal/usage"
+	"github.com/cello-proj/cello/service/internal/webhook"
 	"github.com/cello-proj/cello/service/internal/workflow"
+	"github.com/cello-proj/cello/service/internal/workload"
 
 	"github.com/argoproj/argo-workflows/v3/cmd/argo/commands/client"
+	"github.com/argoproj/argo-workflows/v3/pkg/apiclient"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -33,13 +72,32 @@ func main() {
 		logger = log.With(log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout)), "ts", log.DefaultTimestampUTC)
 	)
 
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		os.Exit(validateConfig(os.Args[2:], os.Stdout))
+	}
+
 	env, err := env.GetEnv()
 	if err != nil {
 		panic(fmt.Sprintf("Unable to initialize environment variables %s", err))
 	}
 
+	// Secrets are redacted from logs and error responses, whatever wraps them.
+	redactor := redact.New(env.AdminSecret, env.VaultSecret, env.DBPassword, env.GitHTTPSPass, env.ITSMToken, env.PublicIDKey, env.ShareLinkKey, env.AttestationKey, env.SecretScanSalt, env.ArgoToken, env.DBReplicaDSN, dsnPassword(env.DBReplicaDSN), env.AuditSplunkHECToken, dsnPassword(env.HTTPProxy), dsnPassword(env.HTTPSProxy), env.ConsulToken)
+	logger = redactor.Logger(logger)
 	setLogLevel(&logger, env.LogLevel)
 
+	// Before any client is created, clients created by libraries read the
+	// proxies once.
+	if err := outbound.Configure(outbound.Config{HTTPProxy: env.HTTPProxy, HTTPSProxy: env.HTTPSProxy, NoProxy: env.NoProxy, CABundleFile: env.CABundleFile}); err != nil {
+		panic(fmt.Sprintf("Unable to configure outbound connections %s", err))
+	}
+	discovery.Configure(discovery.Config{
+		ConsulAddress:   env.ConsulAddress,
+		ConsulToken:     env.ConsulToken,
+		RefreshInterval: env.DiscoveryRefreshInterval,
+		FailureCooldown: env.DiscoveryFailureCooldown,
+	})
+
 	level.Info(logger).Log("message", fmt.Sprintf("loading config '%s'", env.ConfigFilePath))
 	config, err := loadConfig(env.ConfigFilePath)
 	if err != nil {
@@ -47,30 +105,137 @@ func main() {
 	}
 	level.Info(logger).Log("message", fmt.Sprintf("loading config '%s' completed", env.ConfigFilePath))
 
+	features, err := feature.New(config.FeatureFlags)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to load feature flags %s", err))
+	}
+
+	names, err := workflow.NewNameTemplate(config.WorkflowNameTemplate)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to load workflow name template %s", err))
+	}
+
+	secretScanRules, err := secretscan.ParseRules(config.SecretScanRules)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to load secret scan rules %s", err))
+	}
+
+	catalog, err := messages.New(config.DefaultLanguage, config.Messages)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to load messages %s", err))
+	}
+
 	// temp, will rm after config restructure
 	validations.SetImageURIs(env.ImageURIs)
 
 	// The Argo context is needed for any Argo client method calls or else, nil errors.
 	argoCtx, argoClient := client.NewAPIClient()
 
-	dbClient, err := db.NewSQLClient(env.DBHost, env.DBName, env.DBUser, env.DBPassword)
+	dbOpts := []db.Option{db.WithPool(db.PoolConfig{
+		MaxOpenConns:    env.DBMaxOpenConns,
+		MaxIdleConns:    env.DBMaxIdleConns,
+		ConnMaxLifetime: env.DBConnMaxLifetime,
+		ConnMaxIdleTime: env.DBConnMaxIdleTime,
+	})}
+	if env.DBReplicaDSN != "" {
+		dbOpts = append(dbOpts, db.WithReadReplica(env.DBReplicaDSN, env.DBReplicaMaxLag))
+	}
+	dbClient, err := db.NewSQLClient(env.DBHost, env.DBName, env.DBUser, env.DBPassword, dbOpts...)
 	if err != nil {
 		level.Error(logger).Log("message", "error creating db client", "error", err)
 		panic("error creating db client")
 	}
 
+	gitCl := gitClient(env, logger)
+
+	cronWorkflowClient, err := argoClient.NewCronWorkflowServiceClient()
+	if err != nil {
+		level.Error(logger).Log("message", "error creating cron workflow client", "error", err)
+		panic("error creating cron workflow client")
+	}
+
+	argoOpts := []workflow.Option{workflow.WithNameTemplate(names)}
+	if env.ArgoArchiveEnabled {
+		argoOpts = append(argoOpts, archiveOption(argoClient, env, logger))
+	}
+	// Argo offloads the node status of large workflows to its database, which
+	// is only read through the Argo server.
+	if env.ArgoArtifactsURL != "" {
+		argoServer := discovery.For(env.ArgoArtifactsURL)
+		argoOpts = append(argoOpts, workflow.WithOffloadedNodes(workflow.NewHTTPNodeReader(argoServer.URL(), env.ArgoToken, argoServer.Client(time.Minute))))
+	}
+
 	// Any Argo Workflow client method calls need the context returned from NewAPIClient, otherwise
-	// nil errors will occur. Mux sets its params in context, so passing the Argo Workflow context to
-	// setupRouter and applying it to the request will wipe out Mux vars (or any other data Mux sets in its context).
+	// nil errors will occur. Replacing the request context with it would wipe out Mux vars (or any
+	// other data Mux sets in its context), so request scopes carry its values instead.
 	h := handler{
 		logger:                 logger,
-		newCredentialsProvider: credentials.NewVaultProvider,
-		argo:                   workflow.NewArgoWorkflow(argoClient.NewWorkflowServiceClient(), env.ArgoNamespace),
+		newCredentialsProvider: credentialsProvider(env),
+		argo:                   workflow.NewArgoWorkflow(argoClient.NewWorkflowServiceClient(), env.ArgoNamespace, argoOpts...),
 		argoCtx:                argoCtx,
+		cron:                   workflow.NewArgoCronWorkflows(cronWorkflowClient, env.ArgoNamespace),
 		config:                 config,
-		gitClient:              gitClient(env, logger),
+		gitClient:              gitCl,
+		ociClient:              oci.NewHTTPClient(outbound.Client(30 * time.Second)),
 		env:                    env,
 		dbClient:               dbClient,
+		clock:                  clock.New(),
+		features:               features,
+		redactor:               redactor,
+		messages:               catalog,
+		secretScanner:          secretscan.New(append(secretscan.DefaultRules(), secretScanRules...)...),
+		secretScanSalt:         secretScanSalt(env),
+	}
+
+	h.dependencyChecks = dependencyChecks(h, dbClient, gitCl, env)
+	runStartupProbes(h, env, logger)
+
+	if env.CircuitBreakerFailureThreshold > 0 {
+		h = withCircuitBreakers(h, env)
+	}
+
+	h.guardrails = guardrailMonitor(h.argo, env, logger)
+	h.itsm = itsmClient(env)
+	h.audit = auditForwarder(env, logger)
+	h.anomalies = anomalyDetector(env, h.dbClient)
+	h.notifications = notificationWatcher(h.argo, env, catalog, logger)
+	h.webhooks = webhook.NewSender(outbound.Client(10 * time.Second))
+	h.serviceAccounts = serviceAccounts(env, logger)
+	h.logArchive = logArchiveStore(env, logger)
+	if env.AnonymousReadOnly {
+		h.publicStats = newPublicStatsCache(env.PublicStatsCacheTTL, env.PublicStatsRateLimit)
+	}
+	if env.UsagePrometheusAddress != "" {
+		h.usage = usage.NewPrometheusReporter(env.UsagePrometheusAddress, outbound.Client(30*time.Second))
+	}
+
+	if env.RecordDir != "" {
+		rec, err := recorder.New(env.RecordDir, logger)
+		if err != nil {
+			level.Error(logger).Log("message", "error creating request recorder", "error", err)
+			panic("error creating request recorder")
+		}
+		level.Warn(logger).Log("message", "recording requests", "dir", env.RecordDir)
+		h.recorder = rec
+	}
+
+	if env.DBHealthCheckInterval > 0 {
+		go dbClient.Watch(context.Background(), env.DBHealthCheckInterval, env.DBReconnectMaxBackoff, logger)
+	}
+	if h.audit != nil {
+		go h.audit.Run(context.Background(), env.AuditFlushInterval, env.AuditMaxBackoff, logger)
+	}
+	go h.watchSchedules(context.Background(), env.ScheduleSyncInterval)
+	go h.watchRollouts(context.Background(), env.RolloutSyncInterval)
+	go h.watchWebhookDeliveries(context.Background(), env.WebhookDeliveryInterval)
+	go h.watchRunTokens(context.Background(), env.RunTokenRevokeInterval)
+	go h.watchBreakGlass(context.Background(), env.BreakGlassRevokeInterval)
+
+	if env.HealthGRPCPort != 0 {
+		go serveGRPCHealth(context.Background(), h.dependencyChecker(), env, logger)
+	}
+	if env.MetricsPort != 0 {
+		go serveMetrics(env, logger)
 	}
 
 	level.Info(logger).Log("message", "starting web service", "vault addr", env.VaultAddress, "argoAddr", env.ArgoAddress)
@@ -99,6 +264,7 @@ func gitClient(env env.Vars, logger log.Logger) git.BasicClient {
 	}
 
 	if env.GitAuthMethod == "https" {
+		git.UseHTTPClient(outbound.Client(0))
 		cl, err = git.NewHTTPSBasicClient(env.GitHTTPSUser, env.GitHTTPSPass, opts...)
 	} else if env.GitAuthMethod == "ssh" {
 		cl, err = git.NewSSHBasicClient(env.SSHPEMFile, opts...)
@@ -113,3 +279,335 @@ func gitClient(env env.Vars, logger log.Logger) git.BasicClient {
 
 	return cl
 }
+
+// credentialsProvider returns the constructor of the configured credentials
+// provider.
+func credentialsProvider(vars env.Vars) func(credentials.Authorization, env.Vars, http.Header, credentials.VaultConfigFn, credentials.VaultSvcFn) (credentials.Provider, error) {
+	if vars.CredentialsProvider == env.CredentialsProviderAWSSecretsManager {
+		return credentials.NewAWSProvider
+	}
+	return credentials.NewVaultProvider
+}
+
+// dsnPassword returns the password of the database URL, so it's redacted
+// along with the URL.
+func dsnPassword(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// archiveOption returns the option falling back to the Argo workflow archive,
+// reading archived logs from the artifacts of the Argo server when its URL is
+// set.
+func archiveOption(argoClient apiclient.Client, vars env.Vars, logger log.Logger) workflow.Option {
+	archiveClient, err := argoClient.NewArchivedWorkflowServiceClient()
+	if err != nil {
+		level.Error(logger).Log("message", "error creating archived workflow client", "error", err)
+		panic("error creating archived workflow client")
+	}
+
+	var artifacts workflow.ArtifactReader
+	if vars.ArgoArtifactsURL != "" {
+		argoServer := discovery.For(vars.ArgoArtifactsURL)
+		artifacts = workflow.NewHTTPArtifactReader(argoServer.URL(), vars.ArgoToken, argoServer.Client(time.Minute))
+	}
+	return workflow.WithArchive(archiveClient, artifacts)
+}
+
+// startupProbeWorkflow is the workflow the Argo dependency check gets the
+// status of, it's not expected to exist.
+const startupProbeWorkflow = "cello-startup-probe"
+
+// dependencyChecks returns the checks of the dependencies of the handler
+// besides the credentials store, used for readiness and the startup probes.
+// The git check only runs when StartupGitRepository is set.
+func dependencyChecks(h handler, dbClient db.SQLClient, gitCl git.BasicClient, vars env.Vars) map[string]health.Check {
+	checks := map[string]health.Check{
+		"argo": func(ctx context.Context) error {
+			// Checks run outside of requests, e.g. for gRPC health, which
+			// don't carry the values of the Argo context.
+			_, err := h.argo.Status(argoValuesContext{Context: ctx, argo: h.argoCtx}, startupProbeWorkflow)
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		},
+		"db": dbClient.Ping,
+	}
+	if vars.StartupGitRepository != "" {
+		checks["git"] = func(ctx context.Context) error {
+			return gitCl.Ping(ctx, vars.StartupGitRepository)
+		}
+	}
+	return checks
+}
+
+// runStartupProbes probes the dependencies of the handler, before requests
+// would, pre-fetching the Vault token of the service. Failures are logged, or
+// stop the service when probes must not fail.
+func runStartupProbes(h handler, vars env.Vars, logger log.Logger) {
+	if vars.StartupProbes == env.StartupProbesOff {
+		return
+	}
+
+	c := health.NewChecker()
+	for name, check := range h.dependencyChecks {
+		c.Register(name, check)
+	}
+	if vars.CredentialsProvider == env.CredentialsProviderAWSSecretsManager {
+		c.Register("secrets_manager", func(ctx context.Context) error {
+			return credentials.PingSecretsManager(vars)
+		})
+	} else {
+		c.Register("vault", func(ctx context.Context) error {
+			vault := discovery.For(vars.VaultAddress)
+			if err := health.NewVaultCheck(vault.URL(), vault.Client(0))(ctx); err != nil {
+				return err
+			}
+			return credentials.PrefetchServiceToken(vars)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(h.argoCtx, vars.StartupProbeTimeout)
+	defer cancel()
+
+	var failed []string
+	for name, err := range c.Run(ctx) {
+		if err != nil {
+			level.Error(logger).Log("message", "startup probe failed", "dependency", name, "error", err)
+			failed = append(failed, name)
+			continue
+		}
+		level.Info(logger).Log("message", "startup probe succeeded", "dependency", name)
+	}
+
+	if len(failed) > 0 && vars.StartupProbes == env.StartupProbesFail {
+		panic(fmt.Sprintf("startup probes failed %s", strings.Join(failed, ", ")))
+	}
+}
+
+// serveGRPCHealth serves the gRPC health checking protocol, reporting the same
+// dependency results as /readyz.
+func serveGRPCHealth(ctx context.Context, checker *health.Checker, env env.Vars, logger log.Logger) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", env.HealthGRPCPort))
+	if err != nil {
+		level.Error(logger).Log("message", "error listening for grpc health", "error", err)
+		panic("error listening for grpc health")
+	}
+
+	hs := health.NewGRPCServer(checker, logger)
+	go hs.Watch(ctx, env.HealthCheckInterval)
+
+	g := grpc.NewServer()
+	hs.Register(g)
+
+	level.Info(logger).Log("message", "starting grpc health service", "port", env.HealthGRPCPort)
+	if err := g.Serve(lis); err != nil {
+		level.Error(logger).Log("message", "error starting grpc health service", "error", err)
+		panic("error starting grpc health service")
+	}
+}
+
+// serveMetrics serves /metrics without authorization on the internal listener
+// of the metrics port, which must not be exposed outside of the cluster.
+func serveMetrics(env env.Vars, logger log.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	level.Info(logger).Log("message", "starting metrics service", "port", env.MetricsPort)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", env.MetricsPort), mux); err != nil {
+		level.Error(logger).Log("message", "error starting metrics service", "error", err)
+		panic("error starting metrics service")
+	}
+}
+
+// withCircuitBreakers wraps the dependency clients of the handler with a
+// circuit breaker per dependency.
+func withCircuitBreakers(h handler, env env.Vars) handler {
+	newBreaker := func(name string, isFailure func(error) bool) *circuitbreaker.Breaker {
+		b := circuitbreaker.New(name, circuitbreaker.Config{
+			FailureThreshold: env.CircuitBreakerFailureThreshold,
+			OpenTimeout:      env.CircuitBreakerOpenTimeout,
+			IsFailure:        isFailure,
+		})
+		h.breakers = append(h.breakers, b)
+		return b
+	}
+
+	argoBreaker := newBreaker("argo", nil)
+	h.argo = circuitbreaker.NewWorkflow(h.argo, argoBreaker)
+	h.cron = circuitbreaker.NewCronWorkflows(h.cron, argoBreaker)
+	h.dbClient = circuitbreaker.NewDBClient(h.dbClient, newBreaker("db", circuitbreaker.IsDBFailure))
+	h.gitClient = circuitbreaker.NewGitClient(h.gitClient, newBreaker("git", nil))
+	h.ociClient = circuitbreaker.NewOCIClient(h.ociClient, newBreaker("oci", circuitbreaker.IsOCIFailure))
+	h.newCredentialsProvider = circuitbreaker.NewProviderFn(h.newCredentialsProvider, newBreaker("vault", circuitbreaker.IsCredentialsFailure))
+	return h
+}
+
+// guardrailMonitor creates a guardrail.Monitor with the configured providers,
+// or nil when there are none.
+func guardrailMonitor(argo workflow.Workflow, env env.Vars, logger log.Logger) *guardrail.Monitor {
+	if env.GuardrailPrometheusAddress == "" && env.GuardrailCloudWatchRegion == "" {
+		return nil
+	}
+
+	m := guardrail.NewMonitor(argo, env.GuardrailInterval, logger)
+	if env.GuardrailPrometheusAddress != "" {
+		m.Register("prometheus", guardrail.NewPrometheusEvaluator(env.GuardrailPrometheusAddress, outbound.Client(env.GuardrailInterval)))
+	}
+
+	if env.GuardrailCloudWatchRegion != "" {
+		sess, err := session.NewSession(aws.NewConfig().WithRegion(env.GuardrailCloudWatchRegion).WithHTTPClient(outbound.Client(0)))
+		if err != nil {
+			level.Error(logger).Log("message", "error creating aws session", "error", err)
+			panic("error creating aws session")
+		}
+		m.Register("cloudwatch", guardrail.NewCloudWatchEvaluator(cloudwatch.New(sess)))
+	}
+
+	level.Info(logger).Log("message", "guardrails enabled", "providers", strings.Join(m.Providers(), ","))
+	return m
+}
+
+// itsmClient creates the client of the configured ITSM provider, or nil when
+// there is none.
+func itsmClient(env env.Vars) itsm.Client {
+	cl := outbound.Client(30 * time.Second)
+	switch env.ITSMProvider {
+	case "servicenow":
+		return itsm.NewServiceNow(env.ITSMAddress, env.ITSMUser, env.ITSMToken, cl)
+	case "jira":
+		return itsm.NewJira(env.ITSMAddress, env.ITSMUser, env.ITSMToken, env.ITSMJiraProject, env.ITSMJiraIssueType, cl)
+	default:
+		return nil
+	}
+}
+
+// logArchiveStore creates the store logs are archived to, or nil when
+// archiving is disabled. S3 stores use the default AWS credentials and region
+// of the service.
+func logArchiveStore(env env.Vars, logger log.Logger) logarchive.Store {
+	if env.LogArchiveURL == "" {
+		return nil
+	}
+
+	s, err := logarchive.NewStore(env.LogArchiveURL, func() (s3iface.S3API, error) {
+		sess, err := session.NewSession(aws.NewConfig().WithHTTPClient(outbound.Client(0)))
+		if err != nil {
+			return nil, err
+		}
+		return s3.New(sess), nil
+	})
+	if err != nil {
+		level.Error(logger).Log("message", "error creating log archive store", "error", err)
+		panic("error creating log archive store")
+	}
+	return s
+}
+
+// anomalyDetector creates the configured anomaly detector, or nil when there
+// is none. First submissions of targets from a source are recorded in the db.
+func anomalyDetector(env env.Vars, dbClient db.Client) anomaly.Detector {
+	switch env.AnomalyDetector {
+	case "heuristic":
+		// Validated with the env.
+		loc, _ := time.LoadLocation(env.AnomalyTimezone)
+		return anomaly.NewHeuristic(anomaly.HeuristicConfig{
+			RateWindow:      env.AnomalyRateWindow,
+			RateThreshold:   env.AnomalyRateThreshold,
+			UsualHoursStart: env.AnomalyUsualHoursStart,
+			UsualHoursEnd:   env.AnomalyUsualHoursEnd,
+			Location:        loc,
+			Sources:         dbSubmissionSources{db: dbClient},
+		})
+	case "scorer":
+		return anomaly.NewScorer(env.AnomalyScorerURL, outbound.Client(5*time.Second))
+	default:
+		return nil
+	}
+}
+
+// auditForwarder creates the forwarder of audit events to the configured
+// audit sink, or nil when there is none.
+func auditForwarder(env env.Vars, logger log.Logger) *audit.Forwarder {
+	var sink audit.Sink
+	switch env.AuditSink {
+	case "splunk":
+		sink = audit.NewSplunkHEC(env.AuditSplunkHECURL, env.AuditSplunkHECToken, env.AuditSplunkIndex, outbound.Client(30*time.Second))
+	case "syslog":
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: outbound.RootCAs()}
+		if env.AuditSyslogCAFile != "" {
+			pem, err := os.ReadFile(env.AuditSyslogCAFile)
+			if err != nil {
+				level.Error(logger).Log("message", "error reading audit syslog ca file", "error", err)
+				panic("error reading audit syslog ca file")
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				level.Error(logger).Log("message", "no certificates in audit syslog ca file", "file", env.AuditSyslogCAFile)
+				panic("no certificates in audit syslog ca file")
+			}
+		}
+		sink = audit.NewSyslog(env.AuditSyslogAddress, tlsConfig, 30*time.Second)
+	default:
+		return nil
+	}
+
+	f, err := audit.NewForwarder(sink, env.AuditSpoolDir)
+	if err != nil {
+		level.Error(logger).Log("message", "error creating audit spool", "error", err)
+		panic("error creating audit spool")
+	}
+	level.Info(logger).Log("message", "audit events forwarded", "sink", env.AuditSink)
+	return f
+}
+
+// secretScanSalt returns the salt of the hashes of secret scan findings,
+// random when unset so hashes only correlate findings until the next start.
+func secretScanSalt(env env.Vars) []byte {
+	if env.SecretScanSalt != "" {
+		return []byte(env.SecretScanSalt)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		panic(fmt.Sprintf("Unable to generate secret scan salt %s", err))
+	}
+	return salt
+}
+
+// serviceAccounts reads the service accounts of the workflow namespace when
+// workload identity is enabled.
+func serviceAccounts(env env.Vars, logger log.Logger) workload.ServiceAccounts {
+	if !env.WorkloadIdentityEnabled {
+		return nil
+	}
+
+	sas, err := workload.NewInClusterServiceAccounts(env.ArgoNamespace)
+	if err != nil {
+		level.Error(logger).Log("message", "error creating service accounts client", "error", err)
+		panic("error creating service accounts client")
+	}
+	return sas
+}
+
+// notificationWatcher creates a notify.Watcher with every notification rule
+// type, summarizing failures in the default language of the catalog.
+func notificationWatcher(argo workflow.Workflow, env env.Vars, catalog *messages.Catalog, logger log.Logger) *notify.Watcher {
+	summary := func(f notify.Failure) string {
+		return catalog.Message(catalog.DefaultLanguage(), messages.ConsecutiveFailuresNotification, messages.Params{
+			"project":  f.Project,
+			"target":   f.Target,
+			"workflow": f.WorkflowName,
+			"failures": strconv.Itoa(f.ConsecutiveFailures),
+		})
+	}
+
+	nw := notify.NewWatcher(argo, env.NotificationInterval, logger)
+	nw.Register(notify.TypePagerDuty, notify.NewPagerDuty(env.PagerDutyEventsURL, outbound.Client(30*time.Second), notify.WithFailureSummary(summary)))
+	return nw
+}
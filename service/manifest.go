@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/manifest"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// manifestSelection selects the workflow of a manifest, which is rendered
+// first when the request has a template.
+type manifestSelection struct {
+	name     string
+	template *requests.ManifestTemplate
+	context  manifest.Context
+}
+
+// Returns the selected workflow of the manifest.
+func (s manifestSelection) load(data []byte) (requests.CreateWorkflow, error) {
+	if s.template != nil {
+		c := s.context
+		c.Parameters = s.template.Parameters
+
+		var err error
+		data, err = manifest.Render(data, c, s.template.Strict)
+		if err != nil {
+			return requests.CreateWorkflow{}, err
+		}
+	}
+	return requests.SelectManifestWorkflow(data, s.name)
+}
+
+// Returns the selection of a manifest for the project and target of the
+// request. The target is only read when the manifest is rendered, after the
+// authorization of the request is verified for the project as rendering
+// errors can include its properties.
+func (h handler) manifestSelection(rs *requestScope, w http.ResponseWriter, r *http.Request, l log.Logger, targetName, name string, tmpl *requests.ManifestTemplate) (manifestSelection, bool) {
+	s := manifestSelection{name: name, template: tmpl}
+	if tmpl == nil {
+		return s, true
+	}
+
+	projectName := rs.project
+	cp, err := h.newCredentialsProvider(*rs.principal, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return manifestSelection{}, false
+	}
+	authorized, err := cp.ProjectAuthorized(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error authorizing project", "error", err)
+		h.errorResponse(w, "error authorizing project", http.StatusInternalServerError)
+		return manifestSelection{}, false
+	}
+	if !authorized {
+		h.messageResponse(w, r, messages.Unauthorized, nil, http.StatusUnauthorized)
+		return manifestSelection{}, false
+	}
+
+	acp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return manifestSelection{}, false
+	}
+	target, err := acp.GetTarget(projectName, targetName)
+	if errors.Is(err, credentials.ErrTargetNotFound) {
+		h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": projectName, "target": targetName}, http.StatusBadRequest)
+		return manifestSelection{}, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving target", "error", err)
+		h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+		return manifestSelection{}, false
+	}
+
+	s.context = manifest.Context{Project: projectName, Target: target}
+	return s, true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/service/internal/messages"
+)
+
+// messageResponse writes the error response of the message of the catalog,
+// in the language of the request. The response includes the ID and the
+// parameters of the message so clients can present their own.
+func (h handler) messageResponse(w http.ResponseWriter, r *http.Request, id messages.ID, params messages.Params, httpStatus int) {
+	h.writeErrorResponse(w, r, errorResponse{Code: string(id), Params: params}, httpStatus)
+}
+
+// writeErrorResponse writes the error response, with the message of its code
+// rendered in the language of the request.
+func (h handler) writeErrorResponse(w http.ResponseWriter, r *http.Request, er errorResponse, httpStatus int) {
+	lang := h.scope(r).language
+	er.ErrorMessage = h.redactor.String(h.messages.Message(lang, messages.ID(er.Code), er.Params))
+	if len(er.Params) > 0 {
+		params := messages.Params{}
+		for k, v := range er.Params {
+			params[k] = h.redactor.String(v)
+		}
+		er.Params = params
+	}
+
+	data, _ := json.Marshal(er)
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(httpStatus)
+	fmt.Fprint(w, string(data))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageResponse(t *testing.T) {
+	catalog, err := messages.New("", map[string]map[string]string{
+		"fr": {"target_not_found": "cible '{{.target}}' du projet '{{.project}}' introuvable"},
+	})
+	assert.NoError(t, err)
+	h := handler{messages: catalog, redactor: redact.New("secret-project")}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		params         messages.Params
+		wantLanguage   string
+		wantBody       string
+	}{
+		{
+			name:         "default language",
+			params:       messages.Params{"project": "project1", "target": "target1"},
+			wantLanguage: "en",
+			wantBody:     `{"error_message":"target not found","code":"target_not_found","params":{"project":"project1","target":"target1"}}`,
+		},
+		{
+			name:           "accepted language",
+			acceptLanguage: "fr-CH, en;q=0.5",
+			params:         messages.Params{"project": "project1", "target": "target1"},
+			wantLanguage:   "fr",
+			wantBody:       `{"error_message":"cible 'target1' du projet 'project1' introuvable","code":"target_not_found","params":{"project":"project1","target":"target1"}}`,
+		},
+		{
+			name:           "parameters redacted",
+			acceptLanguage: "fr",
+			params:         messages.Params{"project": "secret-project", "target": "target1"},
+			wantLanguage:   "fr",
+			wantBody:       `{"error_message":"cible 'target1' du projet 'REDACTED' introuvable","code":"target_not_found","params":{"project":"REDACTED","target":"target1"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/projects/project1/targets/target1", nil)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+
+			h.messageResponse(w, r, messages.TargetNotFound, tt.params, http.StatusNotFound)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tt.wantLanguage, w.Header().Get("Content-Language"))
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
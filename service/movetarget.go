@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// Moves a target, with its credentials, settings and history, to another
+// project, e.g. when teams reorganize ownership. The move is recorded as a
+// 'target_moved' execution event of both projects.
+func (h handler) moveTarget(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	vars := mux.Vars(r)
+	projectName := rs.project
+	targetName := vars["targetName"]
+	txID := r.Header.Get(txIDHeader)
+	ctx := rs.ctx
+
+	l := rs.log("op", "move-target", "project", projectName, "target", targetName)
+
+	level.Debug(l).Log("message", "validating authorization header for move target")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	var mtr requests.MoveTarget
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &mtr); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := mtr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if !rs.inScope(mtr.Project) {
+		h.elevationScopeResponse(w, rs)
+		return
+	}
+	if mtr.Project == projectName {
+		h.errorResponse(w, "invalid request, project is the project of the target", http.StatusBadRequest)
+		return
+	}
+
+	l = log.With(l, "to", mtr.Project)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	targetExists, err := cp.TargetExists(projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking target", "error", err)
+		h.errorResponse(w, "error checking target", http.StatusInternalServerError)
+		return
+	}
+	if !targetExists {
+		h.errorResponse(w, "target not found", http.StatusNotFound)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(mtr.Project)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+	if !projectExists {
+		h.errorResponse(w, "invalid request, project does not exist", http.StatusBadRequest)
+		return
+	}
+
+	existsInProject, err := cp.TargetExists(mtr.Project, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking target", "error", err)
+		h.errorResponse(w, "error checking target", http.StatusInternalServerError)
+		return
+	}
+	if existsInProject {
+		h.errorResponse(w, fmt.Sprintf("target '%s' already exists in project '%s'", targetName, mtr.Project), http.StatusBadRequest)
+		return
+	}
+
+	// Schedules submit workflows with cron workflows named after the project,
+	// which aren't moved.
+	schedules, err := h.dbClient.ListTargetScheduleEntries(ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error listing target schedules", "error", err)
+		h.errorResponse(w, "error listing target schedules", http.StatusInternalServerError)
+		return
+	}
+	if len(schedules) > 0 {
+		h.errorResponse(w, fmt.Sprintf("target has schedules, delete schedule '%s' before moving the target", schedules[0].Name), http.StatusConflict)
+		return
+	}
+
+	// Environments list their targets by name, so the target would stay in
+	// the environment of the project it was moved from.
+	environment, _, inEnvironment, err := h.targetEnvironment(ctx, projectName, targetName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project environments", "error", err)
+		h.errorResponse(w, "error reading project environments", http.StatusInternalServerError)
+		return
+	}
+	if inEnvironment {
+		h.errorResponse(w, fmt.Sprintf("target is in environment '%s', remove it from the environment before moving the target", environment), http.StatusConflict)
+		return
+	}
+
+	// Running workflows read the credentials of the target by the name of the
+	// project, so they would fail part way through.
+	statuses, err := h.argo.ListByLabels(ctx, map[string]string{
+		workflow.LabelProject: projectName,
+		workflow.LabelTarget:  targetName,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error listing workflows", "error", err)
+		h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
+		return
+	}
+	for _, s := range statuses {
+		if workflow.IsActive(s.Status) {
+			h.errorResponse(w, fmt.Sprintf("workflow '%s' of the target is running", s.Name), http.StatusConflict)
+			return
+		}
+	}
+
+	// The database is moved first, so a move failing in Vault can be retried.
+	level.Debug(l).Log("message", "moving target in db")
+	if err := h.dbClient.MoveTargetEntry(ctx, projectName, mtr.Project, targetName); err != nil {
+		level.Error(l).Log("message", "error moving target in database", "error", err)
+		h.errorResponse(w, "error moving target", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "moving target")
+	if err := cp.MoveTarget(projectName, mtr.Project, targetName); err != nil {
+		level.Error(l).Log("message", "error moving target", "error", err)
+		h.errorResponse(w, "error moving target", http.StatusInternalServerError)
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:      txID,
+		Project:   projectName,
+		Target:    targetName,
+		Type:      "target_moved",
+		Message:   fmt.Sprintf("moved to project '%s'", mtr.Project),
+		CreatedAt: now,
+	})
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:      txID,
+		Project:   mtr.Project,
+		Target:    targetName,
+		Type:      "target_moved",
+		Message:   fmt.Sprintf("moved from project '%s'", projectName),
+		CreatedAt: now,
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Attaches a note to a past workflow, e.g. a retro note or incident link,
+// optionally flagging it as known-bad. Notes are listed with the workflows
+// of the target and recorded as 'execution_note_added' execution events.
+func (h handler) createExecutionNote(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+	txID := r.Header.Get(txIDHeader)
+	l := rs.log("op", "create-execution-note", "workflow", workflowName, "txid", txID)
+
+	status, ok := h.authorizedWorkflow(w, r, l, workflowName)
+	if !ok {
+		return
+	}
+	projectName := status.Labels[workflow.LabelProject]
+	targetName := status.Labels[workflow.LabelTarget]
+	if projectName == "" || targetName == "" {
+		h.errorResponse(w, "invalid request, workflow wasn't submitted by the service", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var cnr requests.CreateExecutionNote
+	if err := json.Unmarshal(reqBody, &cnr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := cnr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	e := db.ExecutionNoteEntry{
+		ID:           uuid.NewString(),
+		WorkflowName: workflowName,
+		Project:      projectName,
+		Target:       targetName,
+		Author:       authorizationName(rs.principal),
+		Note:         cnr.Note,
+		IncidentURL:  cnr.IncidentURL,
+		KnownBad:     cnr.KnownBad,
+		CreatedAt:    h.now().UTC(),
+	}
+
+	level.Debug(l).Log("message", "storing execution note")
+	if err := h.dbClient.CreateExecutionNoteEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing execution note", "error", err)
+		h.errorResponse(w, "error storing execution note", http.StatusInternalServerError)
+		return
+	}
+
+	message := fmt.Sprintf("note added by %s", e.Author)
+	if e.KnownBad != nil && *e.KnownBad {
+		message += ", flagged known-bad"
+	} else if e.KnownBad != nil {
+		message += ", known-bad flag cleared"
+	}
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      projectName,
+		Target:       targetName,
+		WorkflowName: workflowName,
+		Type:         "execution_note_added",
+		Message:      message,
+		CreatedAt:    e.CreatedAt,
+	})
+
+	data, err := json.Marshal(newExecutionNoteResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// executionNotes are the notes of the workflows of a target by workflow
+// name, and whether each workflow is known-bad.
+type executionNotes struct {
+	notes    map[string][]responses.ExecutionNote
+	knownBad map[string]bool
+}
+
+// Reads the notes of the workflows of a target. The latest note setting the
+// known-bad flag of a workflow wins.
+func (h handler) targetExecutionNotes(ctx context.Context, projectName, targetName string) (executionNotes, error) {
+	entries, err := h.dbClient.ListExecutionNoteEntries(ctx, projectName, targetName)
+	if err != nil {
+		return executionNotes{}, err
+	}
+
+	en := executionNotes{notes: map[string][]responses.ExecutionNote{}, knownBad: map[string]bool{}}
+	for _, e := range entries {
+		en.notes[e.WorkflowName] = append(en.notes[e.WorkflowName], newExecutionNoteResponse(e))
+		if e.KnownBad != nil {
+			en.knownBad[e.WorkflowName] = *e.KnownBad
+		}
+	}
+	return en, nil
+}
+
+// listedWorkflow is a workflow of the history of a target with its notes.
+type listedWorkflow struct {
+	workflow.Status
+	KnownBad bool                      `json:"known_bad,omitempty"`
+	Notes    []responses.ExecutionNote `json:"notes,omitempty"`
+}
+
+func newExecutionNoteResponse(e db.ExecutionNoteEntry) responses.ExecutionNote {
+	return responses.ExecutionNote{
+		Author:      e.Author,
+		Note:        e.Note,
+		IncidentURL: e.IncidentURL,
+		KnownBad:    e.KnownBad,
+		CreatedAt:   e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const (
+	// originHeader is the header callers pass the origin of workflow
+	// submissions in, a types.Origin JSON object, e.g. the CI job.
+	originHeader = "X-Cello-Origin"
+	// Maximum length of the origin header.
+	maxOriginHeaderLength = 2048
+)
+
+// requestOrigin returns the validated origin of the request, nil when it has
+// none. Unknown fields are rejected so typos aren't silently dropped.
+func requestOrigin(r *http.Request) (*types.Origin, error) {
+	header := r.Header.Get(originHeader)
+	if header == "" {
+		return nil, nil
+	}
+	if len(header) > maxOriginHeaderLength {
+		return nil, fmt.Errorf("%s header must be at most %d characters", originHeader, maxOriginHeaderLength)
+	}
+
+	var o types.Origin
+	dec := json.NewDecoder(bytes.NewReader([]byte(header)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, fmt.Errorf("%s header must be a JSON object of ci_job_url, ticket_id and triggered_by", originHeader)
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Records the origin of a submitted workflow as an 'origin' execution event,
+// kept after the workflow and its annotations are deleted. Failures are
+// logged as the workflow has already been submitted.
+func (h handler) recordOrigin(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string, o types.Origin) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing origin", "error", err)
+		return
+	}
+
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "origin",
+		Message:      string(data),
+		CreatedAt:    h.now().UTC(),
+	})
+}
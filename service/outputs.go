@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// Gets the results the steps of a workflow reported in their result files,
+// for callers needing machine readable results (e.g. the IDs of created
+// resources) instead of scraping logs. Invalid results are returned with
+// their error.
+func (h handler) getExecutionOutputs(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+	l := rs.log("op", "get-execution-outputs", "workflow", workflowName)
+
+	status, ok := h.authorizedWorkflow(w, r, l, workflowName)
+	if !ok {
+		return
+	}
+
+	resp := responses.ExecutionOutputs{
+		WorkflowName: workflowName,
+		Status:       status.Status,
+		Outputs:      workflow.Outputs(status.Results),
+		Steps:        []responses.StepResult{},
+	}
+	for _, sr := range status.Results {
+		result, err := workflow.ParseResult(sr.Result)
+		if err != nil {
+			level.Warn(l).Log("message", "invalid step result", "step", sr.Step, "error", err)
+			resp.Steps = append(resp.Steps, responses.StepResult{Step: sr.Step, Error: err.Error()})
+			continue
+		}
+
+		resp.Steps = append(resp.Steps, responses.StepResult{
+			Step:    sr.Step,
+			Status:  result.Status,
+			Summary: result.Summary,
+			Outputs: result.Outputs,
+		})
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing execution outputs", "error", err)
+		h.errorResponse(w, "error serializing execution outputs", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
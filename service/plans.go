@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/plan"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// Maximum time a terraform or cdk diff is watched for its plan summary.
+const planWatchTimeout = time.Hour
+
+// Gets the summary of the plan of a terraform or cdk diff, recorded when the
+// diff completed, so reviewers don't have to read its logs.
+func (h handler) getExecutionPlan(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+	l := rs.log("op", "get-execution-plan", "workflow", workflowName)
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	e, err := h.dbClient.ReadPlanSummaryEntry(rs.ctx, workflowName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "plan summary not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading plan summary", "error", err)
+		h.errorResponse(w, "error reading plan summary", http.StatusInternalServerError)
+		return
+	}
+
+	var summary plan.Summary
+	if err := json.Unmarshal([]byte(e.Summary), &summary); err != nil {
+		level.Error(l).Log("message", "error deserializing plan summary", "error", err)
+		h.errorResponse(w, "error reading plan summary", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.ExecutionPlan{
+		WorkflowName:  workflowName,
+		CommitHash:    e.CommitHash,
+		Add:           summary.Add,
+		Change:        summary.Change,
+		Destroy:       summary.Destroy,
+		ResourceTypes: summary.ResourceTypes,
+		Changes:       []responses.PlanChange{},
+		CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for _, c := range summary.Changes {
+		resp.Changes = append(resp.Changes, responses.PlanChange(c))
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing plan summary", "error", err)
+		h.errorResponse(w, "error serializing plan summary", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Watches a terraform or cdk diff until it completes to record the summary of
+// its plan, see recordPlanSummary.
+func (h handler) watchPlan(l log.Logger, txID string, cwr requests.CreateWorkflow, commitHash, workflowName string) {
+	if !plan.Supported(cwr.Framework) || cwr.Type != "diff" || h.env.PlanWatchInterval <= 0 {
+		return
+	}
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, planWatchTimeout)
+		defer cancel()
+		h.recordPlanSummary(ctx, l, txID, cwr, commitHash, workflowName)
+	}()
+}
+
+// Records the summary of the plan of a completed terraform or cdk diff, read
+// from its logs, as a 'plan_summary' execution event and for the change
+// tickets of the syncs of its commit. Failures are logged as the summary is
+// informational.
+func (h handler) recordPlanSummary(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, commitHash, workflowName string) {
+	status, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.PlanWatchInterval, func(err error) {
+		level.Warn(l).Log("message", "error getting workflow status", "error", err)
+	})
+	if err != nil {
+		return
+	}
+	if status.Status != "succeeded" {
+		level.Info(l).Log("message", "diff didn't succeed, no plan summary", "status", status.Status)
+		return
+	}
+
+	logs, err := h.argo.Logs(ctx, workflowName, workflow.LogOptions{})
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow logs", "error", err)
+		return
+	}
+	var lines []string
+	if logs != nil {
+		lines = logs.Logs
+	}
+	summary, ok, err := plan.FromLogs(cwr.Framework, lines)
+	if err != nil {
+		level.Error(l).Log("message", "error reading plan summary", "error", err)
+		return
+	}
+	if !ok {
+		level.Warn(l).Log("message", "no plan in workflow logs")
+		return
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing plan summary", "error", err)
+		return
+	}
+	err = h.dbClient.CreatePlanSummaryEntry(ctx, db.PlanSummaryEntry{
+		WorkflowName: workflowName,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		CommitHash:   commitHash,
+		Summary:      string(data),
+		CreatedAt:    h.now().UTC(),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing plan summary", "error", err)
+	}
+
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "plan_summary",
+		Message:      summary.String(),
+		CreatedAt:    h.now().UTC(),
+	})
+}
+
+// Returns the summary of the plan of the latest diff of the commit a
+// terraform or cdk sync applies. Empty for other submissions or when there's
+// none, e.g. because the diff is still running.
+func (h handler) readPlanSummary(ctx context.Context, l log.Logger, cwr requests.CreateWorkflow, commitHash string) string {
+	if !plan.Supported(cwr.Framework) || cwr.Type != "sync" || commitHash == "" {
+		return ""
+	}
+
+	e, err := h.dbClient.ReadCommitPlanSummaryEntry(ctx, cwr.ProjectName, cwr.TargetName, commitHash)
+	if err != nil {
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Warn(l).Log("message", "error reading plan summary", "error", err)
+		}
+		return ""
+	}
+
+	var summary plan.Summary
+	if err := json.Unmarshal([]byte(e.Summary), &summary); err != nil {
+		level.Warn(l).Log("message", "error deserializing plan summary", "error", err)
+		return ""
+	}
+	return summary.String()
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Policies requests are evaluated against, named in the results.
+const (
+	policyPrincipal        = "principal"
+	policyRequest          = "request"
+	policyProject          = "project"
+	policyTarget           = "target"
+	policyBusinessHours    = "business_hours"
+	policyEnvironment      = "environment"
+	policyFreezeWindow     = "freeze_window"
+	policyWorkflowTemplate = "workflow_template"
+	policyDestroy          = "destroy"
+)
+
+// policyResult returns the result of the policy, failed with the reason when
+// not nil.
+func policyResult(policy string, reason error) responses.PolicyResult {
+	if reason != nil {
+		return responses.PolicyResult{Policy: policy, Message: reason.Error()}
+	}
+	return responses.PolicyResult{Policy: policy, Passed: true}
+}
+
+// Evaluates a hypothetical request against the policies it's subject to,
+// without making it. Admins can evaluate requests of any principal, other
+// callers only the ones of their projects.
+func (h handler) evaluatePolicies(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "evaluate-policies")
+
+	level.Debug(l).Log("message", "validating authorization header for evaluate policies")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+	admin := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)) == nil
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var epr requests.EvaluatePolicies
+	if err := json.Unmarshal(reqBody, &epr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+	if err := epr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	principal := epr.Principal
+	if principal == "" {
+		principal = requests.PrincipalProject
+		if admin {
+			principal = requests.PrincipalAdmin
+		}
+	}
+	if principal == requests.PrincipalAdmin && !admin {
+		h.messageResponse(w, r, messages.Unauthorized, nil, http.StatusUnauthorized)
+		return
+	}
+
+	at := h.now
+	if epr.At != "" {
+		t, _ := time.Parse(time.RFC3339, epr.At)
+		at = func() time.Time { return t }
+	}
+
+	projectName := ""
+	if epr.CreateWorkflow != nil {
+		projectName = epr.CreateWorkflow.ProjectName
+	} else {
+		projectName = epr.CreateTarget.ProjectName
+	}
+	l = log.With(l, "project", projectName, "principal", principal)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	// The policies tell whether targets exist, so other projects are hidden
+	// from project callers.
+	if !admin {
+		authorized, err := cp.ProjectAuthorized(projectName)
+		if err != nil {
+			level.Error(l).Log("message", "error authorizing project", "error", err)
+			h.stageErrorResponse(rs.ctx, w, r, stageVault, err, "error authorizing project", http.StatusInternalServerError)
+			return
+		}
+		if !authorized {
+			h.messageResponse(w, r, messages.ProjectNotFound, messages.Params{"project": projectName}, http.StatusNotFound)
+			return
+		}
+	}
+
+	level.Debug(l).Log("message", "evaluating policies")
+	var results []responses.PolicyResult
+	if epr.CreateWorkflow != nil {
+		results, err = h.createWorkflowPolicies(rs, cp, principal, *epr.CreateWorkflow, at)
+	} else {
+		results, err = h.createTargetPolicies(cp, principal, *epr.CreateTarget)
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error evaluating policies", "error", err)
+		h.errorResponse(w, "error evaluating policies", http.StatusInternalServerError)
+		return
+	}
+
+	allowed := true
+	for _, res := range results {
+		allowed = allowed && res.Passed
+	}
+	level.Info(l).Log("message", "evaluated policies", "allowed", allowed)
+
+	jsonData, err := json.Marshal(responses.EvaluatePolicies{Allowed: allowed, Policies: results})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing policy results", "error", err)
+		h.errorResponse(w, "error serializing policy results", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// createWorkflowPolicies evaluates the policies of creating the workflow, the
+// checks of createWorkflowFromRequest before submitting it. Business hours and
+// freeze windows are checked at the time returned by at.
+func (h handler) createWorkflowPolicies(rs *requestScope, cp credentials.Provider, principal string, cwr requests.CreateWorkflow, at func() time.Time) ([]responses.PolicyResult, error) {
+	ctx := rs.ctx
+
+	var principalErr error
+	if principal == requests.PrincipalAdmin {
+		principalErr = errors.New("workflows must be created with project credentials")
+	}
+	results := []responses.PolicyResult{policyResult(policyPrincipal, principalErr)}
+
+	cwr, err := h.applyEnvironment(ctx, cwr)
+	var invalidEnvironment invalidEnvironmentError
+	if err != nil && !errors.As(err, &invalidEnvironment) {
+		return nil, err
+	}
+	results = append(results, policyResult(policyEnvironment, err))
+
+	invalid, err := h.workflowRequestError(rs, &cwr)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, policyResult(policyRequest, invalid))
+
+	projectExists, err := cp.ProjectExists(cwr.ProjectName)
+	if err != nil {
+		return nil, err
+	}
+	var projectErr error
+	if !projectExists {
+		projectErr = errors.New("project does not exist")
+	}
+	results = append(results, policyResult(policyProject, projectErr))
+
+	targetExists, err := cp.TargetExists(cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		return nil, err
+	}
+	var targetErr error
+	if !targetExists {
+		targetErr = errors.New("target not found")
+	}
+	results = append(results, policyResult(policyTarget, targetErr))
+
+	err = h.businessHoursAt(ctx, cwr.ProjectName, cwr.TargetName, at)
+	var outside outsideBusinessHoursError
+	if err != nil && !errors.As(err, &outside) {
+		return nil, err
+	}
+	results = append(results, policyResult(policyBusinessHours, err))
+
+	err = h.frozenAt(ctx, cwr.ProjectName, cwr.TargetName, at())
+	var frozen targetFrozenError
+	if err != nil && !errors.As(err, &frozen) {
+		return nil, err
+	}
+	results = append(results, policyResult(policyFreezeWindow, err))
+
+	_, _, err = h.workflowTemplateFrom(ctx, cwr)
+	var notAllowed workflowTemplateNotAllowedError
+	if err != nil && !errors.As(err, &notAllowed) {
+		return nil, err
+	}
+	results = append(results, policyResult(policyWorkflowTemplate, err))
+
+	// Only destroys have safeguards of their own.
+	if cwr.Type == requests.TypeDestroy {
+		err = h.destroyAllowed(ctx, cwr)
+		var rejected destroyRejectedError
+		if err != nil && !errors.As(err, &rejected) {
+			return nil, err
+		}
+		results = append(results, policyResult(policyDestroy, err))
+	}
+
+	return results, nil
+}
+
+// workflowRequestError returns why the workflow request is invalid, the
+// validation of generateWorkflowCommand, including the approved images. The
+// error is the one of reading the scheduling of the target.
+func (h handler) workflowRequestError(rs *requestScope, cwr *requests.CreateWorkflow) (error, error) {
+	workflowTypes, err := rs.config.listTypes(cwr.Framework)
+	if err != nil {
+		return fmt.Errorf("framework must be one of '%s'", strings.Join(rs.config.listFrameworks(), " ")), nil
+	}
+
+	scheduling, err := h.targetScheduling(rs.ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.config.applyFrameworkDefaults(cwr, scheduling.Platform); err != nil {
+		return err, nil
+	}
+	return cwr.Validate(cwr.ValidateType(workflowTypes), rs.config.validateFrameworkRequest(*cwr)), nil
+}
+
+// createTargetPolicies evaluates the policies of creating the target, the
+// checks of createTarget.
+func (h handler) createTargetPolicies(cp credentials.Provider, principal string, ctr requests.EvaluateCreateTarget) ([]responses.PolicyResult, error) {
+	var principalErr error
+	if principal != requests.PrincipalAdmin {
+		principalErr = errors.New("targets must be created with admin credentials")
+	}
+	results := []responses.PolicyResult{
+		policyResult(policyPrincipal, principalErr),
+		policyResult(policyRequest, types.Target(ctr.CreateTarget).Validate()),
+	}
+
+	projectExists, err := cp.ProjectExists(ctr.ProjectName)
+	if err != nil {
+		return nil, err
+	}
+	var projectErr error
+	if !projectExists {
+		projectErr = errors.New("project does not exist")
+	}
+	results = append(results, policyResult(policyProject, projectErr))
+
+	targetExists, err := cp.TargetExists(ctr.ProjectName, ctr.Name)
+	if err != nil {
+		return nil, err
+	}
+	var targetErr error
+	if targetExists {
+		targetErr = errors.New("target name must not already exist")
+	}
+	results = append(results, policyResult(policyTarget, targetErr))
+
+	return results, nil
+}
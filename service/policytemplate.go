@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Gets the template of the Vault policies of projects. The policy it renders
+// for the project query parameter is included, to preview a template.
+func (h handler) getVaultPolicyTemplate(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-vault-policy-template")
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "getting vault policy template")
+	pt, err := cp.GetPolicyTemplate()
+	if h.notSupportedResponse(w, err) {
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error getting vault policy template", "error", err)
+		h.errorResponse(w, "error getting vault policy template", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetVaultPolicyTemplate{Template: pt.Template, Default: pt.Default}
+	if projectName := r.URL.Query().Get("project"); projectName != "" {
+		targets, err := cp.ListTargets(projectName)
+		if err != nil {
+			level.Error(l).Log("message", "error listing targets", "error", err)
+			h.errorResponse(w, "error listing targets", http.StatusInternalServerError)
+			return
+		}
+		resp.Policy, err = credentials.RenderPolicy(pt.Template, projectName, targets)
+		if err != nil {
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+// Customizes the template of the Vault policies of projects. Policies are
+// rendered from it when projects are created, or their targets or the host
+// credentials of their targets change, existing policies aren't updated.
+func (h handler) putVaultPolicyTemplate(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "put-vault-policy-template")
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var ptr requests.PutVaultPolicyTemplate
+	if err := json.Unmarshal(reqBody, &ptr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := ptr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing vault policy template")
+	if err := cp.PutPolicyTemplate(ptr.Template); err != nil {
+		if h.notSupportedResponse(w, err) {
+			return
+		}
+		if errors.Is(err, credentials.ErrInvalidPolicyTemplate) {
+			level.Error(l).Log("message", "error invalid request", "error", err)
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+			return
+		}
+		level.Error(l).Log("message", "error storing vault policy template", "error", err)
+		h.errorResponse(w, "error storing vault policy template", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetVaultPolicyTemplate{Template: ptr.Template})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+// Restores the built in template of the Vault policies of projects.
+func (h handler) deleteVaultPolicyTemplate(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "delete-vault-policy-template")
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "deleting vault policy template")
+	if err := cp.DeletePolicyTemplate(); err != nil {
+		if h.notSupportedResponse(w, err) {
+			return
+		}
+		level.Error(l).Log("message", "error deleting vault policy template", "error", err)
+		h.errorResponse(w, "error deleting vault policy template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Gets the size of the Vault policy of a project, with the Vault policies it's
+// sharded across.
+func (h handler) getVaultPolicyUsage(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	l := rs.log("op", "get-vault-policy-usage", "project", projectName)
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error retrieving project", "error", err)
+		h.errorResponse(w, "error retrieving project", http.StatusInternalServerError)
+		return
+	}
+	if !projectExists {
+		h.messageResponse(w, r, messages.ProjectDoesNotExist, messages.Params{"project": projectName}, http.StatusNotFound)
+		return
+	}
+
+	level.Debug(l).Log("message", "getting vault policy usage")
+	usage, err := cp.GetPolicyUsage(projectName)
+	if h.notSupportedResponse(w, err) {
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error getting vault policy usage", "error", err)
+		h.errorResponse(w, "error getting vault policy usage", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetVaultPolicyUsage{
+		Bytes:        usage.Bytes,
+		Policies:     usage.Policies,
+		Targets:      usage.Targets,
+		WarningBytes: h.env.VaultPolicyWarningBytes,
+		MaxBytes:     h.env.VaultPolicyMaxBytes,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(data))
+}
+
+// Returns the warnings about the Vault policy of the project, i.e. when it
+// exceeds the warning size. Errors are only logged, the warnings are advisory.
+func (h handler) vaultPolicyWarnings(l log.Logger, cp credentials.Provider, projectName string) []string {
+	if h.env.VaultPolicyWarningBytes <= 0 {
+		return nil
+	}
+
+	usage, err := cp.GetPolicyUsage(projectName)
+	if err != nil {
+		level.Warn(l).Log("message", "error getting vault policy usage", "error", err)
+		return nil
+	}
+	if usage.Bytes <= h.env.VaultPolicyWarningBytes {
+		return nil
+	}
+
+	level.Warn(l).Log("message", "vault policy of project exceeds the warning size", "bytes", usage.Bytes, "policies", len(usage.Policies))
+	warning := fmt.Sprintf("vault policy of project '%s' is %d bytes, more than the warning size of %d bytes", projectName, usage.Bytes, h.env.VaultPolicyWarningBytes)
+	if len(usage.Policies) > 1 {
+		warning += fmt.Sprintf(", sharded across %d policies", len(usage.Policies))
+	}
+	return []string{warning}
+}
+
+// adminProvider returns the credentials provider of admin requests,
+// writing the error response otherwise.
+func (h handler) adminProvider(w http.ResponseWriter, r *http.Request, l log.Logger) (credentials.Provider, bool) {
+	level.Debug(l).Log("message", "validating authorization header")
+	a, err := h.scope(r).authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return nil, false
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return nil, false
+	}
+	return cp, true
+}
+
+// Responds with 501 when the credentials provider doesn't support the
+// operation, e.g. Vault policies without Vault, reporting whether it did.
+func (h handler) notSupportedResponse(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, credentials.ErrNotSupported) {
+		return false
+	}
+	h.errorResponse(w, err.Error(), http.StatusNotImplemented)
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// progressHistory is how many of the latest succeeded workflows of the same
+// project, target and type the median duration is computed from.
+const progressHistory = 20
+
+// workflowProgress returns the progress of the active workflow from its
+// completed nodes and the median duration of previous workflows. Errors
+// listing previous workflows are logged and leave the median out, as the
+// status is still useful without it.
+func (h handler) workflowProgress(ctx context.Context, l log.Logger, status *workflow.Status) *responses.WorkflowProgress {
+	p := &responses.WorkflowProgress{
+		NodesCompleted: status.NodesCompleted,
+		NodesTotal:     status.NodesTotal,
+	}
+	if p.NodesTotal > 0 {
+		p.Percent = int(p.NodesCompleted * 100 / p.NodesTotal)
+	}
+
+	selector := map[string]string{
+		workflow.LabelProject: status.Labels[workflow.LabelProject],
+		workflow.LabelTarget:  status.Labels[workflow.LabelTarget],
+		workflow.LabelType:    status.Labels[workflow.LabelType],
+	}
+	for _, v := range selector {
+		if v == "" {
+			return p
+		}
+	}
+
+	previous, err := h.argo.ListByLabels(ctx, selector)
+	if err != nil {
+		level.Warn(l).Log("message", "error listing previous workflows, progress won't have an eta", "error", err)
+		return p
+	}
+	median, ok := medianDuration(previous)
+	if !ok {
+		return p
+	}
+	p.MedianDurationSeconds = int64(median / time.Second)
+
+	created, err := strconv.ParseInt(status.Created, 10, 64)
+	if err != nil {
+		return p
+	}
+	start := time.Unix(created, 0).UTC()
+	p.ETA = start.Add(median).Format(time.RFC3339)
+
+	// Nodes are only reported once the controller picked the workflow up.
+	if p.NodesTotal == 0 {
+		elapsed := h.now().Sub(start)
+		p.Percent = int(elapsed * 100 / median)
+		if p.Percent > 99 {
+			p.Percent = 99
+		}
+		if p.Percent < 0 {
+			p.Percent = 0
+		}
+	}
+	return p
+}
+
+// medianDuration returns the median duration of the latest succeeded
+// workflows, false when none succeeded.
+func medianDuration(statuses []workflow.Status) (time.Duration, bool) {
+	type run struct {
+		created  int64
+		duration int64
+	}
+	var runs []run
+	for _, s := range statuses {
+		if s.Status != "succeeded" {
+			continue
+		}
+		created, errCreated := strconv.ParseInt(s.Created, 10, 64)
+		finished, errFinished := strconv.ParseInt(s.Finished, 10, 64)
+		if errCreated != nil || errFinished != nil || finished <= created {
+			continue
+		}
+		runs = append(runs, run{created: created, duration: finished - created})
+	}
+	if len(runs) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].created > runs[j].created })
+	if len(runs) > progressHistory {
+		runs = runs[:progressHistory]
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].duration < runs[j].duration })
+
+	mid := len(runs) / 2
+	median := runs[mid].duration
+	if len(runs)%2 == 0 {
+		median = (runs[mid-1].duration + runs[mid].duration) / 2
+	}
+	return time.Duration(median) * time.Second, true
+}
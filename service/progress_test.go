@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedianDuration(t *testing.T) {
+	run := func(status string, created, seconds int) workflow.Status {
+		return workflow.Status{Status: status, Created: fmt.Sprint(created), Finished: fmt.Sprint(created + seconds)}
+	}
+
+	tests := []struct {
+		name     string
+		statuses []workflow.Status
+		want     time.Duration
+		wantOK   bool
+	}{
+		{
+			name:     "no succeeded workflows",
+			statuses: []workflow.Status{run("failed", 100, 10), {Status: "running", Created: "200"}},
+		},
+		{
+			name:     "odd number of workflows",
+			statuses: []workflow.Status{run("succeeded", 100, 30), run("succeeded", 200, 10), run("failed", 300, 1), run("succeeded", 400, 20)},
+			want:     20 * time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "even number of workflows",
+			statuses: []workflow.Status{run("succeeded", 100, 30), run("succeeded", 200, 10)},
+			want:     20 * time.Second,
+			wantOK:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := medianDuration(tt.statuses)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("only latest workflows", func(t *testing.T) {
+		var statuses []workflow.Status
+		for i := 0; i < progressHistory; i++ {
+			statuses = append(statuses, run("succeeded", 1000+i, 60))
+		}
+		// Older and slower.
+		for i := 0; i < progressHistory; i++ {
+			statuses = append(statuses, run("succeeded", i, 600))
+		}
+		got, _ := medianDuration(statuses)
+		assert.Equal(t, time.Minute, got)
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+// Puts (replaces) the settings document of a project. Unknown fields are
+// rejected so typos aren't silently ignored.
+func (h handler) putProjectSettings(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "put-project-settings", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var psr requests.PutProjectSettings
+	dec := json.NewDecoder(bytes.NewReader(reqBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&psr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error deserializing request body, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := psr.Validate(psr.ValidateNotificationTypes(h.notifications.Types())); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	settings := normalizeProjectSettings(types.ProjectSettings(psr))
+	data, err := json.Marshal(settings)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing project settings", "error", err)
+		h.errorResponse(w, "error serializing project settings", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing project settings")
+	err = h.dbClient.CreateProjectSettingsEntry(rs.ctx, db.ProjectSettingsEntry{
+		Project:   projectName,
+		Settings:  string(data),
+		UpdatedAt: h.now().UTC(),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error storing project settings", "error", err)
+		h.errorResponse(w, "error storing project settings", http.StatusInternalServerError)
+		return
+	}
+
+	data, err = json.Marshal(responses.GetProjectSettings(settings))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the settings document of a project, the defaults when it wasn't put.
+func (h handler) getProjectSettings(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-settings", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	settings, err := h.projectSettings(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project settings", "error", err)
+		h.errorResponse(w, "error reading project settings", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(responses.GetProjectSettings(settings))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// projectSettings returns the settings of the project, the defaults when they
+// weren't put.
+func (h handler) projectSettings(ctx context.Context, projectName string) (types.ProjectSettings, error) {
+	e, err := h.dbClient.ReadProjectSettingsEntry(ctx, projectName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return normalizeProjectSettings(types.ProjectSettings{}), nil
+	}
+	if err != nil {
+		return types.ProjectSettings{}, err
+	}
+
+	var settings types.ProjectSettings
+	if err := json.Unmarshal([]byte(e.Settings), &settings); err != nil {
+		return types.ProjectSettings{}, fmt.Errorf("error deserializing project settings: %w", err)
+	}
+	return normalizeProjectSettings(settings), nil
+}
+
+// addCostLabels adds the labels attributing the resource usage of a workflow
+// to the labels, overriding default labels. The tenant is the project when it
+// isn't set, and executions are identified by their transaction id.
+func addCostLabels(labels map[string]string, settings types.ProjectSettings, projectName, executionID string) {
+	tenant := settings.Tenant
+	if tenant == "" {
+		tenant = projectName
+	}
+	labels[workflow.LabelTenant] = tenant
+	if executionID != "" {
+		labels[workflow.LabelExecutionID] = executionID
+	}
+}
+
+// normalizeProjectSettings returns the settings with empty lists and maps
+// instead of nil ones, so they're serialized as such.
+func normalizeProjectSettings(s types.ProjectSettings) types.ProjectSettings {
+	if s.Notifications == nil {
+		s.Notifications = []types.NotificationDestination{}
+	}
+	if s.DefaultLabels == nil {
+		s.DefaultLabels = map[string]string{}
+	}
+	if s.Destroy.ProtectedTargets == nil {
+		s.Destroy.ProtectedTargets = []string{}
+	}
+	return s
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// errInvalidPublicID is returned for public IDs which weren't issued with the
+// public ID key.
+var errInvalidPublicID = errors.New("invalid public id")
+
+// publicIDCipher returns the AEAD encrypting workflow names into public IDs,
+// keyed by the SHA-256 of the public ID key.
+func (h handler) publicIDCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(h.env.PublicIDKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// publicWorkflowID returns the public ID of the workflow, which anonymous
+// requests get its status with without learning its name, and so its project
+// and target. The nonce is derived from the name, so a workflow always has
+// the same public ID.
+func (h handler) publicWorkflowID(workflowName string) (string, error) {
+	aead, err := h.publicIDCipher()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.env.PublicIDKey))
+	mac.Write([]byte(workflowName))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+
+	sealed := aead.Seal(nonce, nonce, []byte(workflowName), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// workflowNameFromPublicID returns the name of the workflow of the public ID,
+// or errInvalidPublicID.
+func (h handler) workflowNameFromPublicID(publicID string) (string, error) {
+	aead, err := h.publicIDCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(publicID)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errInvalidPublicID
+	}
+	name, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errInvalidPublicID
+	}
+	return string(name), nil
+}
+
+// Gets the status of a workflow by its public ID, without authorization.
+func (h handler) getPublicWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	publicID := mux.Vars(r)["publicID"]
+	l := rs.log("op", "get-public-workflow")
+
+	workflowName, err := h.workflowNameFromPublicID(publicID)
+	if err != nil {
+		level.Debug(l).Log("message", "invalid public id", "error", err)
+		h.messageResponse(w, r, messages.WorkflowNotFound, nil, http.StatusNotFound)
+		return
+	}
+	l = rs.log("op", "get-public-workflow", "workflow", workflowName)
+
+	level.Debug(l).Log("message", "getting workflow status")
+	status, err := h.argo.Status(rs.ctx, workflowName)
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow", "error", err)
+		h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(responses.GetPublicWorkflowStatus{
+		PublicID: publicID,
+		Status:   status.Status,
+		Created:  status.Created,
+		Finished: status.Finished,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// publicStatsCache limits the anonymous requests of the public stats, which
+// are computed at most once per TTL for each window, since computing them
+// lists every project and its workflows.
+type publicStatsCache struct {
+	limiter *rate.Limiter
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[int]publicStatsEntry
+}
+
+type publicStatsEntry struct {
+	stats     responses.GetPublicStats
+	expiresAt time.Time
+}
+
+// newPublicStatsCache returns a cache of the public stats allowing the limit
+// of requests per second.
+func newPublicStatsCache(ttl time.Duration, limit float64) *publicStatsCache {
+	return &publicStatsCache{
+		limiter: rate.NewLimiter(rate.Limit(limit), int(math.Max(1, math.Ceil(limit)))),
+		ttl:     ttl,
+		entries: map[int]publicStatsEntry{},
+	}
+}
+
+// Gets the system stats, without authorization. Unlike the admin stats, they
+// don't name projects. Stats are cached and rate limited, see
+// publicStatsCache.
+func (h handler) getPublicStats(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-public-stats")
+
+	if h.publicStats != nil && !h.publicStats.limiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		h.errorResponse(w, "too many requests, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	windowHours, ok := h.statsWindowHours(w, r)
+	if !ok {
+		return
+	}
+
+	// The stats are computed with the lock held, so concurrent requests of
+	// expired stats only compute them once.
+	if h.publicStats != nil {
+		h.publicStats.mu.Lock()
+		defer h.publicStats.mu.Unlock()
+	}
+	stats, ok := h.cachedPublicStats(windowHours)
+	if !ok {
+		// Headers of anonymous requests aren't passed on to Vault.
+		level.Debug(l).Log("message", "creating credential provider")
+		cp, err := h.adminCredentialsProvider(http.Header{})
+		if err != nil {
+			level.Error(l).Log("message", "error creating credentials provider", "error", err)
+			h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+			return
+		}
+
+		systemStats, ok := h.systemStats(rs.ctx, w, l, cp, windowHours)
+		if !ok {
+			return
+		}
+		stats = responses.GetPublicStats{
+			Projects:           systemStats.Projects,
+			Targets:            systemStats.Targets,
+			WindowHours:        systemStats.WindowHours,
+			Workflows:          systemStats.Workflows,
+			SubmissionsPerHour: systemStats.SubmissionsPerHour,
+		}
+		if h.publicStats != nil {
+			h.publicStats.entries[windowHours] = publicStatsEntry{stats: stats, expiresAt: h.now().Add(h.publicStats.ttl)}
+		}
+	}
+
+	jsonData, err := json.Marshal(stats)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing public stats", "error", err)
+		h.errorResponse(w, "error serializing public stats", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// cachedPublicStats returns the cached stats of the window unless they
+// expired. The lock of the cache must be held.
+func (h handler) cachedPublicStats(windowHours int) (responses.GetPublicStats, bool) {
+	if h.publicStats == nil {
+		return responses.GetPublicStats{}, false
+	}
+	entry, ok := h.publicStats.entries[windowHours]
+	if !ok || !h.now().Before(entry.expiresAt) {
+		return responses.GetPublicStats{}, false
+	}
+	return entry.stats, true
+}
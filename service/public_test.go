@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/faketest"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicWorkflowID(t *testing.T) {
+	h := handler{env: env.Vars{PublicIDKey: "0123456789abcdef"}}
+
+	id, err := h.publicWorkflowID("project1-target1-abcde")
+	assert.Nil(t, err)
+	assert.NotContains(t, id, "project1")
+
+	again, err := h.publicWorkflowID("project1-target1-abcde")
+	assert.Nil(t, err)
+	assert.Equal(t, id, again)
+
+	name, err := h.workflowNameFromPublicID(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "project1-target1-abcde", name)
+
+	tests := []struct {
+		name     string
+		publicID string
+		key      string
+	}{
+		{name: "tampered", publicID: id[:len(id)-1] + "A", key: h.env.PublicIDKey},
+		{name: "other_key", publicID: id, key: "fedcba9876543210"},
+		{name: "not_base64", publicID: "project1-target1-abcde!", key: h.env.PublicIDKey},
+		{name: "too_short", publicID: "abc", key: h.env.PublicIDKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := handler{env: env.Vars{PublicIDKey: tt.key}}.workflowNameFromPublicID(tt.publicID)
+			assert.Equal(t, errInvalidPublicID, err)
+		})
+	}
+}
+
+func TestPublicRoutesDisabled(t *testing.T) {
+	router := setupRouter(handler{logger: log.NewNopLogger()})
+
+	for _, url := range []string{"/public/stats", "/public/workflows/abc"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, url)
+	}
+}
+
+// countingProjectsDB counts the listings of the projects.
+type countingProjectsDB struct {
+	db.Client
+	listed *int
+}
+
+func (d countingProjectsDB) ListProjectEntries(ctx context.Context) ([]db.ProjectEntry, error) {
+	*d.listed++
+	return d.Client.ListProjectEntries(ctx)
+}
+
+func TestPublicStatsCache(t *testing.T) {
+	listed := 0
+	fakeClock := faketest.NewClock(time.Now())
+	h := handler{
+		logger:                 log.NewNopLogger(),
+		newCredentialsProvider: newMockProvider,
+		argo:                   mockWorkflowSvc{},
+		env:                    env.Vars{AdminSecret: testPassword, AnonymousReadOnly: true, PublicIDKey: testPassword},
+		dbClient:               countingProjectsDB{Client: newMockDB(), listed: &listed},
+		clock:                  fakeClock,
+		publicStats:            newPublicStatsCache(time.Minute, 3),
+	}
+	router := setupRouter(h)
+	get := func(url string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w.Code
+	}
+
+	// Stats are computed once per window until they expire.
+	assert.Equal(t, http.StatusOK, get("/public/stats"))
+	assert.Equal(t, http.StatusOK, get("/public/stats"))
+	assert.Equal(t, 1, listed)
+	assert.Equal(t, http.StatusOK, get("/public/stats?hours=48"))
+	assert.Equal(t, 2, listed)
+
+	// Requests exceeding the rate limit are rejected.
+	assert.Equal(t, http.StatusTooManyRequests, get("/public/stats"))
+
+	// The limiter allows another request a third of a second later.
+	fakeClock.Advance(time.Minute)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, get("/public/stats"))
+	assert.Equal(t, 3, listed)
+}
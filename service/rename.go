@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	upper "github.com/upper/db/v4"
+)
+
+// activeProjectAlias returns the alias when the name is the former name of a
+// renamed project and the alias hasn't expired.
+func (h handler) activeProjectAlias(ctx context.Context, name string) (db.ProjectAliasEntry, bool, error) {
+	e, err := h.dbClient.ReadProjectAliasEntry(ctx, name)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return db.ProjectAliasEntry{}, false, nil
+	}
+	if err != nil {
+		return db.ProjectAliasEntry{}, false, err
+	}
+	return e, h.clock.Now().Before(e.ExpiresAt), nil
+}
+
+// resolveProjectAlias returns the project the name resolves to for reads,
+// i.e. the renamed project when the name is an active alias of it, the name
+// otherwise. Errors reading aliases are only logged, as the name is used
+// as-is like before projects could be renamed.
+func (h handler) resolveProjectAlias(ctx context.Context, l log.Logger, name string) string {
+	if h.dbClient == nil || name == "" {
+		return name
+	}
+	e, ok, err := h.activeProjectAlias(ctx, name)
+	if err != nil {
+		level.Warn(l).Log("message", "error reading project alias", "project", name, "error", err)
+		return name
+	}
+	if !ok {
+		return name
+	}
+	return e.Project
+}
+
+// resolveWorkflowProject relabels workflows submitted before their project
+// was renamed with its current name while the former one is an alias of it,
+// so they're authorized and read like the ones submitted after.
+func (h handler) resolveWorkflowProject(ctx context.Context, l log.Logger, status *workflow.Status) {
+	projectName := status.Labels[workflow.LabelProject]
+	if resolved := h.resolveProjectAlias(ctx, l, projectName); resolved != projectName {
+		labels := make(map[string]string, len(status.Labels))
+		for k, v := range status.Labels {
+			labels[k] = v
+		}
+		labels[workflow.LabelProject] = resolved
+		status.Labels = labels
+	}
+}
+
+// Renames a project in the database and Vault. The former name stays an
+// alias of the project for reads for ARGO_CLOUDOPS_PROJECT_ALIAS_TTL, e.g. so
+// links and workflows submitted before keep working. The project has new
+// credentials, which are returned like when it's created.
+func (h handler) renameProject(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	ctx := rs.ctx
+
+	l := rs.log("op", "rename-project", "project", projectName)
+
+	level.Debug(l).Log("message", "validating authorization header for rename project")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	var rpr requests.RenameProject
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request body", "error", err)
+		h.errorResponse(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(reqBody, &rpr); err != nil {
+		level.Error(l).Log("message", "error decoding request", "error", err)
+		h.errorResponse(w, "error decoding request", http.StatusBadRequest)
+		return
+	}
+	if err := rpr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if rpr.Name == projectName {
+		h.errorResponse(w, "invalid request, name is the name of the project", http.StatusBadRequest)
+		return
+	}
+	// Elevated access is limited to the project by its name.
+	if !rs.inScope(rpr.Name) {
+		h.elevationScopeResponse(w, rs)
+		return
+	}
+
+	l = log.With(l, "name", rpr.Name)
+
+	level.Debug(l).Log("message", "creating credential provider")
+	cp, err := h.newCredentialsProvider(*a, h.env, r.Header, credentials.NewVaultConfig, credentials.NewVaultSvc)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	projectExists, err := cp.ProjectExists(projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+	if !projectExists {
+		h.errorResponse(w, "project does not exist", http.StatusNotFound)
+		return
+	}
+
+	nameExists, err := cp.ProjectExists(rpr.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error checking project", "error", err)
+		h.errorResponse(w, "error checking project", http.StatusInternalServerError)
+		return
+	}
+	if nameExists {
+		h.errorResponse(w, "project already exists", http.StatusBadRequest)
+		return
+	}
+
+	// Renaming a project back to a former name takes the alias back.
+	alias, ok, err := h.activeProjectAlias(ctx, rpr.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project alias", "error", err)
+		h.errorResponse(w, "error reading project alias", http.StatusInternalServerError)
+		return
+	}
+	if ok && alias.Project != projectName {
+		h.errorResponse(w, fmt.Sprintf("name is an alias of project '%s' until %s", alias.Project, alias.ExpiresAt.UTC().Format(time.RFC3339)), http.StatusBadRequest)
+		return
+	}
+
+	// Running workflows read the credentials of their targets by the name of
+	// the project, so they would fail part way through.
+	statuses, err := h.argo.ListByLabels(ctx, map[string]string{workflow.LabelProject: projectName})
+	if err != nil {
+		level.Error(l).Log("message", "error listing workflows", "error", err)
+		h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
+		return
+	}
+	for _, s := range statuses {
+		if workflow.IsActive(s.Status) {
+			h.errorResponse(w, fmt.Sprintf("workflow '%s' of the project is running", s.Name), http.StatusConflict)
+			return
+		}
+	}
+
+	// Vault is renamed first, its targets are moved one at a time until the
+	// role of the new name is written, so a rename failing part way can be
+	// retried. The project is renamed back when the database rename fails,
+	// so both keep the same name.
+	level.Debug(l).Log("message", "renaming project")
+	role, secret, err := cp.RenameProject(projectName, rpr.Name)
+	if err != nil {
+		level.Error(l).Log("message", "error renaming project", "error", err)
+		h.errorResponse(w, "error renaming project", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "renaming project in db")
+	if err := h.dbClient.RenameProjectEntry(ctx, projectName, rpr.Name, h.clock.Now().Add(h.env.ProjectAliasTTL)); err != nil {
+		level.Error(l).Log("message", "error renaming project in database", "error", err)
+		if _, _, rollbackErr := cp.RenameProject(rpr.Name, projectName); rollbackErr != nil {
+			level.Error(l).Log("message", "error renaming project back in vault", "error", rollbackErr)
+		}
+		h.errorResponse(w, "error renaming project", http.StatusInternalServerError)
+		return
+	}
+
+	projectID, err := h.resourceID(ctx, resourceKindProject, rpr.Name, "")
+	if err != nil {
+		level.Error(l).Log("message", "error reading project id", "error", err)
+		h.errorResponse(w, "error reading project id", http.StatusInternalServerError)
+		return
+	}
+
+	t := newArgoCloudOpsToken("vault", role, secret)
+	t.ID = projectID
+	jsonResult, err := json.Marshal(t)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing token", "error", err)
+		h.errorResponse(w, "error serializing token", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonResult))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/db"
+
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	upper "github.com/upper/db/v4"
+)
+
+// Kinds of the resources with stable IDs. Webhooks have their own IDs.
+const (
+	resourceKindProject = "project"
+	resourceKindTarget  = "target"
+)
+
+// resourceID returns the stable ID of a project or target (target empty for
+// projects), assigning one the first time it's asked for, e.g. for resources
+// created before they had IDs. IDs are read from the primary, so they're read
+// after they're written.
+func (h handler) resourceID(ctx context.Context, kind, projectName, targetName string) (string, error) {
+	e, err := h.dbClient.ReadResourceIDEntry(ctx, kind, projectName, targetName)
+	if err == nil {
+		return e.ID, nil
+	}
+	if !errors.Is(err, upper.ErrNoMoreRows) {
+		return "", err
+	}
+
+	e = db.ResourceIDEntry{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Project:   projectName,
+		Target:    targetName,
+		CreatedAt: h.now().UTC(),
+	}
+	if err := h.dbClient.CreateResourceIDEntry(ctx, e); err != nil {
+		// The ID was assigned by a concurrent request.
+		if existing, readErr := h.dbClient.ReadResourceIDEntry(ctx, kind, projectName, targetName); readErr == nil {
+			return existing.ID, nil
+		}
+		return "", err
+	}
+	return e.ID, nil
+}
+
+// Lists the projects with their IDs. Projects are read from the primary
+// rather than the read replica, so projects are listed right after they're
+// created, e.g. by Terraform.
+func (h handler) listProjects(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "list-projects")
+
+	ctx := db.WithPrimaryReads(rs.ctx)
+
+	if _, ok := h.adminProvider(w, r, l); !ok {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectEntries(ctx)
+	if err != nil {
+		level.Error(l).Log("message", "error listing projects", "error", err)
+		h.errorResponse(w, "error listing projects", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.ListProjects{}
+	for _, pe := range entries {
+		id, err := h.resourceID(ctx, resourceKindProject, pe.ProjectID, "")
+		if err != nil {
+			level.Error(l).Log("message", "error reading project id", "project", pe.ProjectID, "error", err)
+			h.errorResponse(w, "error reading project id", http.StatusInternalServerError)
+			return
+		}
+		resp = append(resp, responses.ProjectSummary{ID: id, Name: pe.ProjectID, Repository: pe.Repository})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// newGetTargetResponse returns the response of a target, with its policy
+// document unless sensitive values aren't included.
+func newGetTargetResponse(id string, t types.Target, includeSensitive bool) responses.GetTarget {
+	resp := responses.GetTarget{
+		ID:   id,
+		Name: t.Name,
+		Type: t.Type,
+		Properties: responses.GetTargetProperties{
+			CredentialType:      t.Properties.CredentialType,
+			PolicyArns:          t.Properties.PolicyArns,
+			RoleArn:             t.Properties.RoleArn,
+			ServiceAccountEmail: t.Properties.ServiceAccountEmail,
+			TokenScopes:         t.Properties.TokenScopes,
+			TenantID:            t.Properties.TenantID,
+			SubscriptionID:      t.Properties.SubscriptionID,
+			RoleAssignments:     t.Properties.RoleAssignments,
+		},
+	}
+	if includeSensitive {
+		resp.Properties.PolicyDocument = &t.Properties.PolicyDocument
+	} else if t.Properties.PolicyDocument != "" {
+		sum := sha256.Sum256([]byte(t.Properties.PolicyDocument))
+		resp.Properties.PolicyDocumentSHA256 = hex.EncodeToString(sum[:])
+	}
+	return resp
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/notify"
+	"github.com/cello-proj/cello/service/internal/rollout"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// Gets the targets a target depends on in rollouts
+func (h handler) getTargetDependencies(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "get-target-dependencies", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListTargetDependencyEntries(rs.ctx)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target dependencies", "error", err)
+		h.errorResponse(w, "error reading target dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetTargetDependencies{DependsOn: []responses.TargetReference{}}
+	for _, e := range entries {
+		if e.Project == projectName && e.Target == targetName {
+			resp.DependsOn = append(resp.DependsOn, responses.TargetReference{Project: e.DependsOnProject, Target: e.DependsOnTarget})
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Puts (replaces) the targets a target depends on in rollouts. Dependencies
+// forming a cycle with the dependencies of the other targets are rejected.
+func (h handler) putTargetDependencies(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "put-target-dependencies", "project", projectName, "target", targetName)
+
+	ctx := rs.ctx
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var tdr requests.PutTargetDependencies
+	if err := json.Unmarshal(reqBody, &tdr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := tdr.Validate(tdr.ValidateNotSelf(projectName, targetName)); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "creating admin credentials provider")
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+	for _, d := range tdr.DependsOn {
+		exists, err := cp.TargetExists(d.Project, d.Target)
+		if err != nil {
+			level.Error(l).Log("message", "error retrieving target", "error", err)
+			h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": d.Project, "target": d.Target}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.dbClient.ListTargetDependencyEntries(ctx)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target dependencies", "error", err)
+		h.errorResponse(w, "error reading target dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	key := rollout.Key(projectName, targetName)
+	dependencies := targetDependencies(entries)
+	dependencies[key] = nil
+	for _, d := range tdr.DependsOn {
+		dependencies[key] = append(dependencies[key], rollout.Key(d.Project, d.Target))
+	}
+	if cycle := rollout.FindCycle(dependencies); cycle != nil {
+		level.Error(l).Log("message", "error dependencies form a cycle", "cycle", strings.Join(cycle, " -> "))
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s: %s", rollout.ErrCycle, strings.Join(cycle, " -> ")), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "storing target dependencies")
+	if err := h.dbClient.DeleteTargetDependencyEntries(ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error storing target dependencies", "error", err)
+		h.errorResponse(w, "error storing target dependencies", http.StatusInternalServerError)
+		return
+	}
+	resp := responses.GetTargetDependencies{DependsOn: []responses.TargetReference{}}
+	for _, d := range tdr.DependsOn {
+		err := h.dbClient.CreateTargetDependencyEntry(ctx, db.TargetDependencyEntry{
+			Project:          projectName,
+			Target:           targetName,
+			DependsOnProject: d.Project,
+			DependsOnTarget:  d.Target,
+		})
+		if err != nil {
+			level.Error(l).Log("message", "error storing target dependencies", "error", err)
+			h.errorResponse(w, "error storing target dependencies", http.StatusInternalServerError)
+			return
+		}
+		resp.DependsOn = append(resp.DependsOn, responses.TargetReference{Project: d.Project, Target: d.Target})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// targetDependencies returns the keys of the targets each target depends on.
+func targetDependencies(entries []db.TargetDependencyEntry) map[string][]string {
+	dependencies := map[string][]string{}
+	for _, e := range entries {
+		key := rollout.Key(e.Project, e.Target)
+		dependencies[key] = append(dependencies[key], rollout.Key(e.DependsOnProject, e.DependsOnTarget))
+	}
+	return dependencies
+}
+
+// Creates a rollout of the manifests of several projects, submitting their
+// workflows following the dependencies between their targets. Manifests are
+// validated up front, then the steps without pending dependencies are
+// submitted and the others by syncRollouts as their dependencies succeed.
+// The rollout ID is generated by the service, correlating the workflows and
+// execution events of the rollout.
+func (h handler) createRollout(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	txID := r.Header.Get(txIDHeader)
+
+	l := rs.log("op", "create-rollout", "txid", txID)
+
+	ctx := rs.ctx
+
+	cp, ok := h.adminProvider(w, r, l)
+	if !ok {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var crr requests.CreateRollout
+	if err := json.Unmarshal(reqBody, &crr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := crr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	steps := make([]rollout.Step, 0, len(crr.Steps))
+	for _, s := range crr.Steps {
+		step := rollout.Step{Project: s.Project, Target: s.Target, CommitHash: s.CommitHash, Path: s.Path, Name: s.Name}
+		sl := log.With(l, "project", s.Project, "target", s.Target)
+
+		exists, err := cp.TargetExists(s.Project, s.Target)
+		if err != nil {
+			level.Error(sl).Log("message", "error retrieving target", "error", err)
+			h.errorResponse(w, "error retrieving target", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			h.messageResponse(w, r, messages.TargetNotFound, messages.Params{"project": s.Project, "target": s.Target}, http.StatusBadRequest)
+			return
+		}
+
+		cwr, ok := h.rolloutWorkflow(ctx, rs, w, sl, step)
+		if !ok {
+			return
+		}
+		step.Workflow, err = json.Marshal(cwr)
+		if err != nil {
+			level.Error(sl).Log("message", "error serializing workflow", "error", err)
+			h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+			return
+		}
+		steps = append(steps, step)
+	}
+
+	entries, err := h.dbClient.ListTargetDependencyEntries(ctx)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target dependencies", "error", err)
+		h.errorResponse(w, "error reading target dependencies", http.StatusInternalServerError)
+		return
+	}
+	steps, err = rollout.Plan(steps, targetDependencies(entries))
+	if err != nil {
+		level.Error(l).Log("message", "error planning rollout", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+	for _, s := range steps {
+		var cwr requests.CreateWorkflow
+		if err := json.Unmarshal(s.Workflow, &cwr); err != nil {
+			level.Error(l).Log("message", "error deserializing workflow", "error", err)
+			h.errorResponse(w, "error deserializing workflow", http.StatusInternalServerError)
+			return
+		}
+		if err := validateStepReferences(s, cwr); err != nil {
+			level.Error(l).Log("message", "error invalid output reference", "error", err)
+			h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stepsData, err := json.Marshal(steps)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing rollout steps", "error", err)
+		h.errorResponse(w, "error serializing rollout steps", http.StatusInternalServerError)
+		return
+	}
+	now := h.now().UTC()
+	e := db.RolloutEntry{ID: uuid.NewString(), Status: rollout.StatusRunning, Steps: string(stepsData), CreatedAt: now, UpdatedAt: now}
+
+	level.Debug(l).Log("message", "storing rollout")
+	if err := h.dbClient.CreateRolloutEntry(ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing rollout", "error", err)
+		h.errorResponse(w, "error storing rollout", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "submitting rollout steps")
+	if err := h.advanceRollout(ctx, l, cp, &e); err != nil {
+		level.Error(l).Log("message", "error submitting rollout steps", "error", err)
+		h.errorResponse(w, "error submitting rollout steps", http.StatusInternalServerError)
+		return
+	}
+
+	h.rolloutResponse(w, l, e)
+}
+
+// Loads the workflow of a step from the manifest in the git repository of
+// its project and validates it like schedules. Returns false when an error
+// response was written.
+func (h handler) rolloutWorkflow(ctx context.Context, rs *requestScope, w http.ResponseWriter, l log.Logger, step rollout.Step) (requests.CreateWorkflow, bool) {
+	projectEntry, err := h.dbClient.ReadProjectEntry(ctx, step.Project)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project data", "error", err)
+		h.errorResponse(w, "error reading project data", http.StatusInternalServerError)
+		return requests.CreateWorkflow{}, false
+	}
+
+	cwr, err := h.loadCreateWorkflowRequestFromGit(ctx, projectEntry.Repository, step.CommitHash, step.Path, manifestSelection{name: step.Name})
+	if errors.Is(err, requests.ErrManifestSelection) {
+		h.errorResponse(w, fmt.Sprintf("invalid request, step %s %s", step.Key(), err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error loading workflow data from git", "error", err)
+		h.errorResponse(w, "error loading workflow data from git", http.StatusInternalServerError)
+		return requests.CreateWorkflow{}, false
+	}
+	if cwr.ProjectName != step.Project || cwr.TargetName != step.Target {
+		h.errorResponse(w, fmt.Sprintf("invalid request, manifest '%s' of step %s is for target '%s'", step.Path, step.Key(), rollout.Key(cwr.ProjectName, cwr.TargetName)), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, false
+	}
+
+	types, err := rs.config.listTypes(cwr.Framework)
+	if err != nil {
+		level.Error(l).Log("message", "error invalid framework", "error", err)
+		h.errorResponse(
+			w,
+			fmt.Sprintf("invalid request, step %s framework must be one of '%s'", step.Key(), strings.Join(rs.config.listFrameworks(), " ")),
+			http.StatusBadRequest,
+		)
+		return requests.CreateWorkflow{}, false
+	}
+
+	scheduling, err := h.targetScheduling(ctx, step.Project, step.Target)
+	if err != nil {
+		level.Error(l).Log("message", "error reading target scheduling", "error", err)
+		h.errorResponse(w, "error reading target scheduling", http.StatusInternalServerError)
+		return requests.CreateWorkflow{}, false
+	}
+	if err := rs.config.applyFrameworkDefaults(&cwr, scheduling.Platform); err != nil {
+		level.Error(l).Log("message", "error selecting framework image", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, step %s workflow %s", step.Key(), err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, false
+	}
+	if err := cwr.Validate(cwr.ValidateType(types), rs.config.validateFrameworkRequest(cwr)); err != nil {
+		level.Error(l).Log("message", "error validating workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, step %s workflow %s", step.Key(), err), http.StatusBadRequest)
+		return requests.CreateWorkflow{}, false
+	}
+
+	level.Debug(l).Log("message", "checking workflow template")
+	if _, _, ok := h.resolveWorkflowTemplate(ctx, w, l, cwr); !ok {
+		return requests.CreateWorkflow{}, false
+	}
+
+	level.Debug(l).Log("message", "checking encrypted values")
+	if _, _, ok := h.checkEncryptedValues(ctx, w, l, cwr); !ok {
+		return requests.CreateWorkflow{}, false
+	}
+
+	return cwr, true
+}
+
+// Gets a rollout and the status of its steps
+func (h handler) getRollout(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	rolloutID := mux.Vars(r)["rolloutID"]
+
+	l := rs.log("op", "get-rollout", "rollout", rolloutID)
+
+	if _, ok := h.adminProvider(w, r, l); !ok {
+		return
+	}
+
+	e, err := h.dbClient.ReadRolloutEntry(rs.ctx, rolloutID)
+	if err != nil {
+		if errors.Is(err, upper.ErrNoMoreRows) {
+			h.errorResponse(w, "rollout not found", http.StatusNotFound)
+			return
+		}
+		level.Error(l).Log("message", "error reading rollout", "error", err)
+		h.errorResponse(w, "error reading rollout", http.StatusInternalServerError)
+		return
+	}
+
+	h.rolloutResponse(w, l, e)
+}
+
+func (h handler) rolloutResponse(w http.ResponseWriter, l log.Logger, e db.RolloutEntry) {
+	var steps []rollout.Step
+	if err := json.Unmarshal([]byte(e.Steps), &steps); err != nil {
+		level.Error(l).Log("message", "error deserializing rollout steps", "error", err)
+		h.errorResponse(w, "error deserializing rollout steps", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.Rollout{
+		ID:        e.ID,
+		Status:    e.Status,
+		Steps:     make([]responses.RolloutStep, 0, len(steps)),
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: e.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	for _, s := range steps {
+		dependsOn := s.DependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		resp.Steps = append(resp.Steps, responses.RolloutStep{
+			Project:      s.Project,
+			Target:       s.Target,
+			CommitHash:   s.CommitHash,
+			Path:         s.Path,
+			Name:         s.Name,
+			DependsOn:    dependsOn,
+			Status:       s.Status,
+			WorkflowName: s.WorkflowName,
+			Message:      s.Message,
+			Outputs:      s.Outputs,
+		})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Advances the running rollouts every interval until ctx is done.
+func (h handler) watchRollouts(ctx context.Context, interval time.Duration) {
+	t := h.clock.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if err := h.syncRollouts(ctx); err != nil {
+			level.Error(h.logger).Log("message", "error syncing rollouts", "error", err)
+		}
+	}
+}
+
+// Advances the running rollouts, see advanceRollout. Errors of a rollout are
+// logged and don't stop the sync of the others, which is retried on the next
+// call.
+func (h handler) syncRollouts(ctx context.Context) error {
+	entries, err := h.dbClient.ListRolloutEntries(ctx, rollout.StatusRunning)
+	if err != nil {
+		return fmt.Errorf("error listing rollouts: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cp, err := h.adminCredentialsProvider(http.Header{})
+	if err != nil {
+		return fmt.Errorf("error creating credentials provider: %w", err)
+	}
+
+	for _, e := range entries {
+		l := log.With(h.logger, "op", "sync-rollout", "txid", e.ID)
+		if err := h.advanceRollout(ctx, l, cp, &e); err != nil {
+			level.Error(l).Log("message", "error advancing rollout", "error", err)
+		}
+	}
+	return nil
+}
+
+// Advances a rollout and stores it: running steps whose workflow finished
+// are succeeded or failed, steps depending on failed steps are skipped, and
+// steps whose dependencies all succeeded are submitted. Step changes are
+// recorded as 'rollout_step_<status>' execution events with the rollout ID as
+// transaction ID.
+func (h handler) advanceRollout(ctx context.Context, l log.Logger, cp credentials.Provider, e *db.RolloutEntry) error {
+	var steps []rollout.Step
+	if err := json.Unmarshal([]byte(e.Steps), &steps); err != nil {
+		return fmt.Errorf("error deserializing rollout steps: %w", err)
+	}
+	before := make([]string, len(steps))
+	for i, s := range steps {
+		before[i] = s.Status
+	}
+
+	for i, s := range steps {
+		if s.Status != rollout.StepRunning {
+			continue
+		}
+		status, err := h.argo.Status(h.argoCtx, s.WorkflowName)
+		if err != nil {
+			level.Warn(l).Log("message", "error getting rollout step workflow status", "workflow", s.WorkflowName, "error", err)
+			continue
+		}
+		if workflow.IsActive(status.Status) {
+			continue
+		}
+		steps[i].Status = rollout.StepSucceeded
+		if notify.IsFailed(status.Status) {
+			steps[i].Status = rollout.StepFailed
+			steps[i].Message = fmt.Sprintf("workflow %s", status.Status)
+			continue
+		}
+		if outputs := workflow.Outputs(status.Results); len(outputs) > 0 {
+			steps[i].Outputs = outputs
+		}
+	}
+
+	outputs := map[string]map[string]string{}
+	for _, s := range steps {
+		outputs[s.Key()] = s.Outputs
+	}
+	for _, i := range rollout.Advance(steps) {
+		workflowName, err := h.submitRolloutStep(ctx, l, cp, e.ID, steps[i], outputs)
+		if err != nil {
+			level.Error(l).Log("message", "error submitting rollout step", "project", steps[i].Project, "target", steps[i].Target, "error", err)
+			steps[i].Status = rollout.StepFailed
+			steps[i].Message = fmt.Sprintf("error submitting workflow: %s", err)
+			continue
+		}
+		steps[i].Status = rollout.StepRunning
+		steps[i].WorkflowName = workflowName
+	}
+
+	for i, s := range steps {
+		if s.Status == before[i] {
+			continue
+		}
+		message := s.Message
+		if message == "" {
+			message = fmt.Sprintf("step %s %s", s.Key(), s.Status)
+		}
+		h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+			TxID:         e.ID,
+			Project:      s.Project,
+			Target:       s.Target,
+			WorkflowName: s.WorkflowName,
+			Type:         "rollout_step_" + s.Status,
+			Message:      message,
+			CreatedAt:    h.now().UTC(),
+		})
+	}
+
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return fmt.Errorf("error serializing rollout steps: %w", err)
+	}
+	e.Steps = string(data)
+	e.Status = rollout.Status(steps)
+	e.UpdatedAt = h.now().UTC()
+	if e.Status != rollout.StatusRunning {
+		level.Info(l).Log("message", "rollout finished", "status", e.Status)
+	}
+	return h.dbClient.UpdateRolloutEntry(ctx, *e)
+}
+
+// Submits the workflow of a rollout step with a new project token, like
+// schedules. References to the outputs of the steps it depends on are
+// resolved with the outputs by step key.
+func (h handler) submitRolloutStep(ctx context.Context, l log.Logger, cp credentials.Provider, rolloutID string, step rollout.Step, outputs map[string]map[string]string) (string, error) {
+	var cwr requests.CreateWorkflow
+	if err := json.Unmarshal(step.Workflow, &cwr); err != nil {
+		return "", fmt.Errorf("error deserializing workflow: %w", err)
+	}
+
+	var err error
+	if cwr.Parameters, err = rollout.ResolveOutputs(cwr.Parameters, outputs); err != nil {
+		return "", err
+	}
+	if cwr.EnvironmentVariables, err = rollout.ResolveOutputs(cwr.EnvironmentVariables, outputs); err != nil {
+		return "", err
+	}
+
+	// The template may no longer be allowed for the project.
+	from, referenced, err := h.workflowTemplateFrom(ctx, cwr)
+	if err != nil {
+		return "", err
+	}
+
+	parameters, scheduling, err := h.projectWorkflowParameters(ctx, cp, cwr, referenced)
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := h.projectSettings(ctx, step.Project)
+	if err != nil {
+		return "", fmt.Errorf("error reading project settings: %w", err)
+	}
+
+	retryPolicy := workflow.RetryPolicy{
+		MaxAttempts:    h.env.ArgoSubmitMaxAttempts,
+		InitialBackoff: h.env.ArgoSubmitInitialBackoff,
+		MaxBackoff:     h.env.ArgoSubmitMaxBackoff,
+		IdentityLabels: submissionIdentityLabels,
+	}
+	labels := map[string]string{
+		workflow.LabelProject:    step.Project,
+		workflow.LabelTarget:     step.Target,
+		workflow.LabelType:       cwr.Type,
+		workflow.LabelCommitHash: step.CommitHash,
+		workflow.LabelRollout:    rolloutID,
+		txIDHeader:               rolloutID,
+	}
+	addCostLabels(labels, settings, step.Project, rolloutID)
+	return workflow.SubmitWithRetry(h.argoCtx, h.argo, retryPolicy, from, parameters, labels, nil, scheduling, func(attempt int, name string, err error) {
+		if err != nil {
+			level.Warn(l).Log("message", "rollout step submission attempt failed", "project", step.Project, "target", step.Target, "attempt", attempt, "error", err)
+		}
+	})
+}
+
+// validateStepReferences checks the parameters and environment variables of
+// the workflow of a step only reference the outputs of steps it depends on.
+func validateStepReferences(s rollout.Step, cwr requests.CreateWorkflow) error {
+	if err := s.ValidateReferences(cwr.Parameters); err != nil {
+		return err
+	}
+	return s.ValidateReferences(cwr.EnvironmentVariables)
+}
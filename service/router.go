@@ -3,8 +3,12 @@ package main
 import (
 	"net/http"
 
+	"github.com/cello-proj/cello/service/internal/feature"
+	"github.com/cello-proj/cello/service/internal/loadshed"
+
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -15,22 +19,163 @@ func setupRouter(h handler) *mux.Router {
 	r := mux.NewRouter()
 	r.Use(commonMiddleware)
 	r.Use(txIDMiddleware)
+	r.Use(h.supportMiddleware)
+	r.Use(h.routeTimeoutMiddleware)
+	r.Use(h.scopeMiddleware)
+	r.Use(h.bodyLimitMiddleware)
+	if h.recorder != nil {
+		r.Use(h.recorder.Middleware)
+	}
+
+	// Low priority requests (list and status polls) are shed when overloaded
+	// to preserve capacity for submissions. Log streams are long lived, so
+	// they aren't tracked.
+	shedder := loadshed.New(loadshed.Config{
+		MaxInFlight: h.env.ShedMaxInFlight,
+		MaxLatency:  h.env.ShedMaxLatency,
+		RetryAfter:  h.env.ShedRetryAfter,
+	})
+	high := func(f http.HandlerFunc) http.Handler { return shedder.Track(f) }
+	low := func(f http.HandlerFunc) http.Handler { return shedder.Shed(shedder.Track(f)) }
 
-	r.HandleFunc("/workflows", h.createWorkflow).Methods(http.MethodPost)
-	r.HandleFunc("/workflows/{workflowName}", h.getWorkflow).Methods(http.MethodGet)
-	r.HandleFunc("/workflows/{workflowName}/logs", h.getWorkflowLogs).Methods(http.MethodGet)
+	r.Handle("/workflows", high(h.createWorkflow)).Methods(http.MethodPost)
+	r.Handle("/workflows:upload", high(h.createWorkflowFromUpload)).Methods(http.MethodPost)
+	r.Handle("/workflows/preview", low(h.previewWorkflow)).Methods(http.MethodPost)
+	r.Handle("/workflows/status:batch", low(h.batchWorkflowStatus)).Methods(http.MethodPost)
+	r.Handle("/workflows/{workflowName}", low(h.getWorkflow)).Methods(http.MethodGet)
+	r.Handle("/workflows/{workflowName}/logs", low(h.getWorkflowLogs)).Methods(http.MethodGet)
+	r.Handle("/workflows/{workflowName}/nodes", low(h.getWorkflowNodes)).Methods(http.MethodGet)
 	r.HandleFunc("/workflows/{workflowName}/logstream", h.getWorkflowLogStream).Methods(http.MethodGet)
-	r.HandleFunc("/projects", h.createProject).Methods(http.MethodPost)
-	r.HandleFunc("/projects/{projectName}", h.getProject).Methods(http.MethodGet)
-	r.HandleFunc("/projects/{projectName}", h.deleteProject).Methods(http.MethodDelete)
-	r.HandleFunc("/projects/{projectName}/targets", h.listTargets).Methods(http.MethodGet)
-	r.HandleFunc("/projects/{projectName}/targets", h.createTarget).Methods(http.MethodPost)
-	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.getTarget).Methods(http.MethodGet)
-	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.deleteTarget).Methods(http.MethodDelete)
-	r.HandleFunc("/projects/{projectName}/targets/{targetName}", h.updateTarget).Methods(http.MethodPatch)
-	r.HandleFunc("/projects/{projectName}/targets/{targetName}/operations", h.createWorkflowFromGit).Methods(http.MethodPost)
-	r.HandleFunc("/projects/{projectName}/targets/{targetName}/workflows", h.listWorkflows).Methods(http.MethodGet)
+	if h.logArchive != nil {
+		r.Handle("/workflows/{workflowName}/logs/archive", low(h.getWorkflowLogArchive)).Methods(http.MethodGet)
+	}
+	r.Handle("/projects", low(h.listProjects)).Methods(http.MethodGet)
+	r.Handle("/projects", high(h.createProject)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}", low(h.getProject)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}", high(h.deleteProject)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/rename", high(h.renameProject)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets", low(h.listTargets)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets", high(h.createTarget)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets:validate", low(h.validateTarget)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets/{targetName}", low(h.getTarget)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}", high(h.deleteTarget)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}", high(h.updateTarget)).Methods(http.MethodPatch)
+	r.Handle("/projects/{projectName}/targets/{targetName}/move", high(h.moveTarget)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets/{targetName}/change-control", low(h.getTargetChangeControl)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/change-control", high(h.putTargetChangeControl)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/change-control", high(h.deleteTargetChangeControl)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/notification-rules", low(h.getProjectNotificationRules)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/settings", low(h.getProjectSettings)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/settings", high(h.putProjectSettings)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/environments", low(h.getProjectEnvironments)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/environments/{environmentName}", low(h.getProjectEnvironment)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/environments/{environmentName}", high(h.putProjectEnvironment)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/environments/{environmentName}", high(h.deleteProjectEnvironment)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/notification-rules", high(h.putProjectNotificationRule)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/notification-rules/{ruleType}", high(h.deleteProjectNotificationRule)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/webhooks", low(h.getProjectWebhooks)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/webhooks", high(h.createProjectWebhook)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/webhooks/{webhookID}", high(h.deleteProjectWebhook)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/webhooks/{webhookID}/deliveries", low(h.getWebhookDeliveries)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/feature-flags", low(h.getProjectFeatureFlags)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/feature-flags", high(h.putProjectFeatureFlag)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/feature-flags/{name}", high(h.deleteProjectFeatureFlag)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/registry-credentials", low(h.getProjectRegistryCredentials)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/registry-credentials", high(h.putProjectRegistryCredential)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/registry-credentials/{registry}", high(h.deleteProjectRegistryCredential)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/encryption-key", low(h.getProjectEncryptionKey)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/encryption-key", high(h.putProjectEncryptionKey)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/encryption-key", high(h.deleteProjectEncryptionKey)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/vault-policy-usage", low(h.getVaultPolicyUsage)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/business-hours", low(h.getProjectBusinessHours)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/business-hours", high(h.putProjectBusinessHours)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/business-hours", high(h.deleteProjectBusinessHours)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/workflow-templates", low(h.getProjectWorkflowTemplates)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/workflow-templates", high(h.putProjectWorkflowTemplate)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/workflow-templates/{kind}/{name}", high(h.deleteProjectWorkflowTemplate)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/guardrail", low(h.getTargetGuardrail)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/guardrail", high(h.putTargetGuardrail)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/guardrail", high(h.deleteTargetGuardrail)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/scheduling", low(h.getTargetScheduling)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/scheduling", high(h.putTargetScheduling)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/scheduling", high(h.deleteTargetScheduling)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/workload-identity", low(h.getTargetWorkloadIdentity)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/workload-identity", high(h.putTargetWorkloadIdentity)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/workload-identity", high(h.deleteTargetWorkloadIdentity)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/cost-allocation-tags", low(h.getTargetCostAllocationTags)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/cost-allocation-tags", high(h.putTargetCostAllocationTags)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/cost-allocation-tags", high(h.deleteTargetCostAllocationTags)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/soak-time", low(h.getTargetSoakTime)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/soak-time", high(h.putTargetSoakTime)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/soak-time", high(h.deleteTargetSoakTime)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/terraform-state", low(h.getTargetTerraformState)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/terraform-state", high(h.putTargetTerraformState)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/terraform-state", high(h.deleteTargetTerraformState)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/terraform-state/force-unlock", high(h.forceUnlockTargetTerraformState)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets/{targetName}/inventory", low(h.getTargetInventory)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/inventory", high(h.putTargetInventory)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/inventory", high(h.deleteTargetInventory)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/schedules", low(h.getTargetSchedules)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/schedules/{scheduleName}", high(h.putTargetSchedule)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/schedules/{scheduleName}", high(h.deleteTargetSchedule)).Methods(http.MethodDelete)
+	r.Handle("/projects/{projectName}/targets/{targetName}/dependencies", low(h.getTargetDependencies)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/dependencies", high(h.putTargetDependencies)).Methods(http.MethodPut)
+	r.Handle("/projects/{projectName}/targets/{targetName}/operations", high(h.createWorkflowFromGit)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets/{targetName}/oci-operations", high(h.createWorkflowFromOCI)).Methods(http.MethodPost)
+	r.Handle("/projects/{projectName}/targets/{targetName}/workflows", low(h.listWorkflows)).Methods(http.MethodGet)
+	r.Handle("/projects/{projectName}/targets/{targetName}/break-glass", low(h.getBreakGlass)).Methods(http.MethodGet)
+	if h.env.BreakGlassMaxTTL > 0 {
+		r.Handle("/projects/{projectName}/targets/{targetName}/break-glass", high(h.createBreakGlass)).Methods(http.MethodPost)
+	}
+	if h.env.ElevationMaxTTL > 0 {
+		r.Handle("/projects/{projectName}/elevations", low(h.getElevations)).Methods(http.MethodGet)
+		r.Handle("/projects/{projectName}/elevations", high(h.requestElevation)).Methods(http.MethodPost)
+		r.Handle("/projects/{projectName}/elevations/{elevationID}/grant", high(h.grantElevation)).Methods(http.MethodPost)
+		r.Handle("/projects/{projectName}/elevations/{elevationID}", high(h.revokeElevation)).Methods(http.MethodDelete)
+	}
+	r.Handle("/executions/compare", low(h.compareExecutions)).Methods(http.MethodGet)
+	if h.env.AttestationKey != "" {
+		r.Handle("/executions/{workflowName}/attestation", low(h.getExecutionAttestation)).Methods(http.MethodGet)
+	}
+	r.Handle("/executions/{workflowName}/notes", high(h.createExecutionNote)).Methods(http.MethodPost)
+	r.Handle("/executions/{workflowName}/outputs", low(h.getExecutionOutputs)).Methods(http.MethodGet)
+	r.Handle("/executions/{workflowName}/plan", low(h.getExecutionPlan)).Methods(http.MethodGet)
+	r.Handle("/graphql", low(h.graphQL)).Methods(http.MethodPost)
+	r.Handle("/policies/evaluate", low(h.evaluatePolicies)).Methods(http.MethodPost)
+	r.Handle("/auth/self", low(h.getAuthSelf)).Methods(http.MethodGet)
+	r.Handle("/capabilities", low(h.getCapabilities)).Methods(http.MethodGet)
+	r.Handle("/admin/stats", h.requireFeature(feature.AdminStats, low(h.getAdminStats))).Methods(http.MethodGet)
+	r.Handle("/admin/concurrency", h.requireFeature(feature.AdminStats, low(h.getConcurrency))).Methods(http.MethodGet)
+	r.Handle("/admin/scheduled-actions", low(h.getScheduledActions)).Methods(http.MethodGet)
+	if h.usage != nil {
+		r.Handle("/admin/usage", low(h.getUsage)).Methods(http.MethodGet)
+	}
+	r.Handle("/admin/vault-policy-template", low(h.getVaultPolicyTemplate)).Methods(http.MethodGet)
+	r.Handle("/admin/vault-policy-template", high(h.putVaultPolicyTemplate)).Methods(http.MethodPut)
+	r.Handle("/admin/vault-policy-template", high(h.deleteVaultPolicyTemplate)).Methods(http.MethodDelete)
+	r.Handle("/admin/apply", high(h.applyState)).Methods(http.MethodPost)
+	r.Handle("/admin/frameworks/{framework}/images", low(h.getFrameworkImages)).Methods(http.MethodGet)
+	r.Handle("/admin/frameworks/{framework}/images", high(h.createFrameworkImageRotation)).Methods(http.MethodPost)
+	r.Handle("/admin/frameworks/{framework}/images/{rotationID}/promote", high(h.promoteFrameworkImageRotation)).Methods(http.MethodPost)
+	r.Handle("/admin/frameworks/{framework}/images/{rotationID}", high(h.abortFrameworkImageRotation)).Methods(http.MethodDelete)
+	r.Handle("/admin/rollouts", high(h.createRollout)).Methods(http.MethodPost)
+	r.Handle("/admin/rollouts/{rolloutID}", low(h.getRollout)).Methods(http.MethodGet)
+	if h.env.ShareLinkKey != "" {
+		r.Handle("/workflows/{workflowName}/share", high(h.shareWorkflow)).Methods(http.MethodPost)
+		r.Handle("/shared/workflows/{token}", low(h.getSharedWorkflow)).Methods(http.MethodGet)
+		r.Handle("/shared/workflows/{token}/logs", low(h.getSharedWorkflowLogs)).Methods(http.MethodGet)
+	}
+	if h.env.AnonymousReadOnly {
+		r.Handle("/public/workflows/{publicID}", low(h.getPublicWorkflow)).Methods(http.MethodGet)
+		r.Handle("/public/stats", low(h.getPublicStats)).Methods(http.MethodGet)
+	}
 	r.HandleFunc("/health/full", h.healthCheck).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", h.readyz).Methods(http.MethodGet)
+	// Metrics are labeled with projects and targets, so they're only served
+	// to the admin here unless served by the internal listener.
+	if h.env.MetricsPort == 0 {
+		r.Handle("/metrics", h.requireAdmin(promhttp.Handler())).Methods(http.MethodGet)
+	}
 	return r
 }
 
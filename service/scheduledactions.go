@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log/level"
+	"github.com/robfig/cron/v3"
+)
+
+// Types of scheduled actions.
+const (
+	actionScheduleRun          = "schedule_run"
+	actionBreakGlassExpiry     = "break_glass_expiry"
+	actionRunTokenExpiry       = "run_token_expiry"
+	actionWebhookDeliveryRetry = "webhook_delivery_retry"
+)
+
+// Max runs of a schedule returned, so schedules running every minute don't
+// crowd out the other actions.
+const maxScheduledRuns = 100
+
+// Gets the actions the service will take in the window according to its
+// clock: runs of the target schedules which aren't suspended, expiries of
+// break-glass credentials and run tokens, and retries of webhook deliveries.
+// Retries which are due already are returned at their due time.
+func (h handler) getScheduledActions(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-scheduled-actions")
+
+	level.Debug(l).Log("message", "validating authorization header for scheduled actions")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	windowHours, ok := h.statsWindowHours(w, r)
+	if !ok {
+		return
+	}
+
+	now := h.now().UTC()
+	until := now.Add(time.Duration(windowHours) * time.Hour)
+	actions, err := h.scheduledActions(rs.ctx, now, until)
+	if err != nil {
+		level.Error(l).Log("message", "error listing scheduled actions", "error", err)
+		h.errorResponse(w, "error listing scheduled actions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetScheduledActions{
+		Now:         now.Format(time.RFC3339),
+		WindowHours: windowHours,
+		Actions:     []responses.ScheduledAction{},
+	}
+	for _, a := range actions {
+		resp.Actions = append(resp.Actions, responses.ScheduledAction{
+			At:      a.at.UTC().Format(time.RFC3339),
+			Type:    a.typ,
+			Project: a.project,
+			Target:  a.target,
+			Name:    a.name,
+		})
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing scheduled actions", "error", err)
+		h.errorResponse(w, "error serializing scheduled actions", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+type scheduledAction struct {
+	at                    time.Time
+	typ                   string
+	project, target, name string
+}
+
+// scheduledActions returns the actions until the time, soonest first.
+func (h handler) scheduledActions(ctx context.Context, now, until time.Time) ([]scheduledAction, error) {
+	var actions []scheduledAction
+
+	schedules, err := h.dbClient.ListScheduleEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing schedules: %w", err)
+	}
+	for _, ts := range schedules {
+		if ts.Suspend {
+			continue
+		}
+		for _, at := range scheduleRuns(ts.Cron, ts.Timezone, now, until) {
+			actions = append(actions, scheduledAction{at: at, typ: actionScheduleRun, project: ts.Project, target: ts.Target, name: ts.Name})
+		}
+	}
+
+	breakGlass, err := h.dbClient.ListUnrevokedBreakGlassEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing break-glass credentials: %w", err)
+	}
+	for _, e := range breakGlass {
+		if e.ExpiresAt.Before(until) {
+			actions = append(actions, scheduledAction{at: e.ExpiresAt, typ: actionBreakGlassExpiry, project: e.Project, target: e.Target, name: e.ID})
+		}
+	}
+
+	runTokens, err := h.dbClient.ListRunTokenEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing run tokens: %w", err)
+	}
+	for _, rt := range runTokens {
+		if at := rt.CreatedAt.Add(credentials.TokenMaxTTL); at.After(now) && at.Before(until) {
+			actions = append(actions, scheduledAction{at: at, typ: actionRunTokenExpiry, project: rt.Project, target: rt.Target, name: rt.WorkflowName})
+		}
+	}
+
+	deliveries, err := h.dbClient.ListDueWebhookDeliveryEntries(ctx, until)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	for _, e := range deliveries {
+		actions = append(actions, scheduledAction{at: e.NextAttemptAt, typ: actionWebhookDeliveryRetry, project: e.Project, name: e.ID})
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].at.Before(actions[j].at) })
+	return actions, nil
+}
+
+// scheduleRuns returns the times a cron schedule in the timezone (UTC when
+// empty) runs after now and before until, at most maxScheduledRuns. Invalid
+// schedules, which Argo doesn't run either, have none.
+func scheduleRuns(spec, timezone string, now, until time.Time) []time.Time {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil
+	}
+	s, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil
+	}
+
+	var runs []time.Time
+	for t := s.Next(now.In(loc)); !t.IsZero() && t.Before(until) && len(runs) < maxScheduledRuns; t = s.Next(t) {
+		runs = append(runs, t)
+	}
+	return runs
+}
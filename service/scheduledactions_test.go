@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleRuns(t *testing.T) {
+	now := time.Date(2022, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cron     string
+		timezone string
+		until    time.Time
+		want     []string
+	}{
+		{
+			name:  "utc",
+			cron:  "0 2 * * *",
+			until: now.Add(48 * time.Hour),
+			want:  []string{"2022-03-15T02:00:00Z", "2022-03-16T02:00:00Z"},
+		},
+		{
+			name:     "timezone",
+			cron:     "0 2 * * *",
+			timezone: "America/New_York",
+			until:    now.Add(24 * time.Hour),
+			want:     []string{"2022-03-15T06:00:00Z"},
+		},
+		{
+			name:  "descriptor",
+			cron:  "@hourly",
+			until: now.Add(2 * time.Hour),
+			want:  []string{"2022-03-14T11:00:00Z"},
+		},
+		{
+			name:  "invalid cron",
+			cron:  "not a cron",
+			until: now.Add(24 * time.Hour),
+		},
+		{
+			name:     "invalid timezone",
+			cron:     "0 2 * * *",
+			timezone: "Nowhere/Nowhere",
+			until:    now.Add(24 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range scheduleRuns(tt.cron, tt.timezone, now, tt.until) {
+				got = append(got, r.UTC().Format(time.RFC3339))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// Runs are capped.
+	assert.Len(t, scheduleRuns("* * * * *", "", now, now.Add(24*time.Hour)), maxScheduledRuns)
+}
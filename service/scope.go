@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cello-proj/cello/service/internal/credentials"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+)
+
+// requestScope holds the dependencies of a single request. It's created per
+// request by scopeMiddleware, so request scoped state (e.g. caches or spans)
+// can be added here without changing the signature of every handler.
+type requestScope struct {
+	// ctx is the request context, also carrying the values of the Argo
+	// context so Argo calls are canceled with the request.
+	ctx    context.Context
+	logger log.Logger
+	config *Config
+
+	// principal is the authorization of the request, nil when the
+	// authorization header is missing or invalid, see principalErr.
+	principal    *credentials.Authorization
+	principalErr error
+	// elevation is the elevated access authorizing the request, nil
+	// without. The principal is then the admin limited to the project of
+	// the access, see elevate and inScope.
+	elevation *elevatedPrincipal
+
+	// project is the tenant of the request, empty for routes without one.
+	// Reads of a former name of a renamed project are of the project.
+	project string
+
+	// language is the language of the messages of the catalog best matching
+	// the Accept-Language of the request.
+	language string
+}
+
+type requestScopeKey struct{}
+
+// newRequestScope creates the scope of the request.
+func (h handler) newRequestScope(r *http.Request) *requestScope {
+	ctx := r.Context()
+	if h.argoCtx != nil {
+		ctx = argoValuesContext{Context: ctx, argo: h.argoCtx}
+	}
+
+	logger := h.requestLogger(r)
+	project := mux.Vars(r)["projectName"]
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		project = h.resolveProjectAlias(ctx, logger, project)
+	}
+
+	a, err := credentials.NewAuthorization(r.Header.Get("Authorization"))
+	s := &requestScope{
+		ctx:          ctx,
+		logger:       logger,
+		config:       h.config,
+		principal:    a,
+		principalErr: err,
+		project:      project,
+		language:     h.messages.Negotiate(r.Header.Get("Accept-Language")),
+	}
+	h.elevate(s)
+	return s
+}
+
+// scopeMiddleware creates the scope of each request, see scope.
+func (h handler) scopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := h.newRequestScope(r)
+		h.recordElevatedRequest(s, r)
+		ctx := context.WithValue(r.Context(), requestScopeKey{}, s)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// scope returns the scope of the request, created if the request didn't go
+// through scopeMiddleware (e.g. handlers called directly).
+func (h handler) scope(r *http.Request) *requestScope {
+	if s, ok := r.Context().Value(requestScopeKey{}).(*requestScope); ok {
+		return s
+	}
+	return h.newRequestScope(r)
+}
+
+// log returns the request logger with the fields.
+func (s *requestScope) log(fields ...interface{}) log.Logger {
+	return log.With(s.logger, fields...)
+}
+
+// authorization returns the authorization of the request, or the error
+// parsing its header.
+func (s *requestScope) authorization() (*credentials.Authorization, error) {
+	return s.principal, s.principalErr
+}
+
+// argoValuesContext is a context whose values fall back to the ones of the
+// Argo context, which the Argo client needs for its calls.
+type argoValuesContext struct {
+	context.Context
+	argo context.Context
+}
+
+func (c argoValuesContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.argo.Value(key)
+}
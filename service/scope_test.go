@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cello-proj/cello/service/internal/credentials"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type argoKey struct{}
+
+func TestScopeMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		authorization string
+		wantProject   string
+		wantPrincipal *credentials.Authorization
+		wantErr       bool
+	}{
+		{
+			name:          "project_route",
+			url:           "/projects/project1",
+			authorization: userAuthHeader,
+			wantProject:   "project1",
+			wantPrincipal: &credentials.Authorization{Provider: "vault", Key: "user", Secret: testPassword},
+		},
+		{
+			name:          "route_without_project",
+			url:           "/workflows",
+			authorization: adminAuthHeader,
+			wantPrincipal: &credentials.Authorization{Provider: "vault", Key: "admin", Secret: testPassword},
+		},
+		{
+			name:        "missing_authorization",
+			url:         "/projects/project1",
+			wantProject: "project1",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argoCtx := context.WithValue(context.Background(), argoKey{}, "argo")
+			h := handler{logger: log.NewNopLogger(), argoCtx: argoCtx}
+
+			var got *requestScope
+			r := mux.NewRouter()
+			r.Use(h.scopeMiddleware)
+			capture := func(w http.ResponseWriter, r *http.Request) { got = h.scope(r) }
+			r.HandleFunc("/projects/{projectName}", capture)
+			r.HandleFunc("/workflows", capture)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !assert.NotNil(t, got) {
+				return
+			}
+			assert.Equal(t, tt.wantProject, got.project)
+			a, err := got.authorization()
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantPrincipal, a)
+			}
+			assert.Equal(t, "argo", got.ctx.Value(argoKey{}))
+		})
+	}
+}
+
+func TestScopeWithoutMiddleware(t *testing.T) {
+	h := handler{logger: log.NewNopLogger()}
+	req := httptest.NewRequest(http.MethodGet, "/projects/project1", nil)
+	req = mux.SetURLVars(req, map[string]string{"projectName": "project1"})
+
+	s := h.scope(req)
+	assert.Equal(t, "project1", s.project)
+	assert.Equal(t, req.Context(), s.ctx)
+}
+
+func TestScopeContextCanceledWithRequest(t *testing.T) {
+	argoCtx := context.WithValue(context.Background(), argoKey{}, "argo")
+	h := handler{logger: log.NewNopLogger(), argoCtx: argoCtx}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/workflows", nil).WithContext(ctx)
+	s := h.scope(req)
+	cancel()
+
+	<-s.ctx.Done()
+	assert.Equal(t, context.Canceled, s.ctx.Err())
+	assert.Nil(t, argoCtx.Err())
+	assert.Equal(t, "argo", s.ctx.Value(argoKey{}))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/service/internal/bundle"
+	"github.com/cello-proj/cello/service/internal/encryption"
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/secretscan"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// checkSecrets scans the arguments, environment variables and parameters of
+// the workflow request, whether inline or of a manifest, for secrets. Per the
+// secret scan policy, findings are logged as salted hashes or the request is
+// rejected, writing the error response and returning false.
+func (h handler) checkSecrets(w http.ResponseWriter, l log.Logger, cwr requests.CreateWorkflow) bool {
+	if !h.secretScanEnabled() {
+		return true
+	}
+	return h.checkFindings(w, l, scanWorkflowRequest(h.secretScanner, cwr))
+}
+
+// checkBundleSecrets scans the files of an uploaded manifest bundle for
+// secrets like checkSecrets, as fields named after their path.
+func (h handler) checkBundleSecrets(w http.ResponseWriter, l log.Logger, b bundle.Bundle) bool {
+	if !h.secretScanEnabled() {
+		return true
+	}
+
+	var findings []secretscan.Finding
+	for _, p := range b.Paths() {
+		findings = append(findings, h.secretScanner.Scan("bundle."+p, string(b[p]))...)
+	}
+	return h.checkFindings(w, l, findings)
+}
+
+// secretScanEnabled returns whether requests are scanned for secrets.
+func (h handler) secretScanEnabled() bool {
+	policy := h.env.SecretScanPolicy
+	return h.secretScanner != nil && (policy == env.SecretScanWarn || policy == env.SecretScanBlock)
+}
+
+// checkFindings logs the findings of a secret scan, or rejects the request
+// per the secret scan policy, writing the error response and returning false.
+func (h handler) checkFindings(w http.ResponseWriter, l log.Logger, findings []secretscan.Finding) bool {
+	if len(findings) == 0 {
+		return true
+	}
+
+	policy := h.env.SecretScanPolicy
+	found := make([]string, 0, len(findings))
+	for _, f := range findings {
+		level.Warn(l).Log("message", "secret found in workflow request", "policy", policy, "field", f.Field, "rule", f.Rule, "hash", secretscan.Hash(h.secretScanSalt, f.Secret))
+		found = append(found, fmt.Sprintf("%s (%s)", f.Field, f.Rule))
+	}
+
+	if policy == env.SecretScanBlock {
+		h.errorResponse(w, fmt.Sprintf("invalid request, secrets found in %s, pass secrets through the target credentials instead", strings.Join(found, ", ")), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// scanWorkflowRequest returns the findings of the scanner in the request,
+// sorted by field. Values of key value fields are scanned as 'key=value'.
+func scanWorkflowRequest(s secretscan.Scanner, cwr requests.CreateWorkflow) []secretscan.Finding {
+	var findings []secretscan.Finding
+	scanValues := func(prefix string, values map[string]string) {
+		for k, v := range values {
+			if encryption.IsEncrypted(v) {
+				continue
+			}
+			findings = append(findings, s.Scan(prefix+"."+k, k+"="+v)...)
+		}
+	}
+
+	for phase, args := range cwr.Arguments {
+		findings = append(findings, s.Scan("arguments."+phase, strings.Join(args, " "))...)
+	}
+	scanValues("environment_variables", cwr.EnvironmentVariables)
+	scanValues("parameters", cwr.Parameters)
+	scanValues("framework_parameters", cwr.FrameworkParameters)
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Field < findings[j].Field })
+	return findings
+}
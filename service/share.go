@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// defaultShareTTL is how long share links are valid for when the request
+// doesn't set it.
+const defaultShareTTL = time.Hour
+
+var (
+	// errInvalidShareToken is returned for share tokens which weren't signed
+	// with the share link key.
+	errInvalidShareToken = errors.New("invalid share token")
+	// errExpiredShareToken is returned for share tokens past their expiry.
+	errExpiredShareToken = errors.New("share token expired")
+)
+
+// shareClaims are the claims of share tokens, granting read only access to
+// the status and logs of the workflow until they expire.
+type shareClaims struct {
+	Workflow string `json:"workflow"`
+	// Expires is the unix time the token expires at.
+	Expires int64 `json:"exp"`
+}
+
+// shareToken returns the token of the claims, the claims and their signature
+// base64url encoded and separated by a dot.
+func (h handler) shareToken(claims shareClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(h.shareSignature(encoded)), nil
+}
+
+func (h handler) shareSignature(encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(h.env.ShareLinkKey))
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// shareTokenClaims returns the claims of the token, or errInvalidShareToken
+// or errExpiredShareToken.
+func (h handler) shareTokenClaims(token string) (shareClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return shareClaims{}, errInvalidShareToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, h.shareSignature(parts[0])) {
+		return shareClaims{}, errInvalidShareToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return shareClaims{}, errInvalidShareToken
+	}
+	var claims shareClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Workflow == "" {
+		return shareClaims{}, errInvalidShareToken
+	}
+
+	if !h.now().Before(time.Unix(claims.Expires, 0)) {
+		return shareClaims{}, errExpiredShareToken
+	}
+	return claims, nil
+}
+
+// errNoExternalURL is returned creating share links without the external URL
+// of the service. The host of requests can be set by clients, so links can't
+// be relative to it.
+var errNoExternalURL = errors.New("share links require the external url of the service")
+
+// shareBaseURL returns the URL share links are relative to, the external URL
+// of the service.
+func (h handler) shareBaseURL() (string, error) {
+	if h.env.ExternalURL == "" {
+		return "", errNoExternalURL
+	}
+	return strings.TrimSuffix(h.env.ExternalURL, "/"), nil
+}
+
+// Creates a link to the status and logs of a workflow, valid until it expires.
+func (h handler) shareWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName := mux.Vars(r)["workflowName"]
+	l := rs.log("op", "share-workflow", "workflow", workflowName)
+
+	if _, ok := h.authorizedWorkflow(w, r, l, workflowName); !ok {
+		return
+	}
+
+	baseURL, err := h.shareBaseURL()
+	if err != nil {
+		level.Error(l).Log("message", "error creating share link", "error", err)
+		h.errorResponse(w, fmt.Sprintf("error creating share link, %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var swr requests.ShareWorkflow
+	if len(reqBody) > 0 {
+		if err := json.Unmarshal(reqBody, &swr); err != nil {
+			level.Error(l).Log("message", "error deserializing request body", "error", err)
+			h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var optionalValidations []func() error
+	if h.env.ShareLinkMaxTTL > 0 {
+		optionalValidations = append(optionalValidations, swr.ValidateMaxTTL(h.env.ShareLinkMaxTTL))
+	}
+	if err := swr.Validate(optionalValidations...); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultShareTTL
+	if swr.TTL != "" {
+		ttl, _ = time.ParseDuration(swr.TTL)
+	}
+	if h.env.ShareLinkMaxTTL > 0 && ttl > h.env.ShareLinkMaxTTL {
+		ttl = h.env.ShareLinkMaxTTL
+	}
+	expires := h.now().Add(ttl).UTC().Truncate(time.Second)
+
+	token, err := h.shareToken(shareClaims{Workflow: workflowName, Expires: expires.Unix()})
+	if err != nil {
+		level.Error(l).Log("message", "error signing share token", "error", err)
+		h.errorResponse(w, "error signing share token", http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(l).Log("message", "shared workflow", "expires", expires.Format(time.RFC3339))
+	jsonData, err := json.Marshal(responses.ShareWorkflow{
+		URL:       baseURL + "/shared/workflows/" + token,
+		ExpiresAt: expires.Format(time.RFC3339),
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing share link", "error", err)
+		h.errorResponse(w, "error serializing share link", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// sharedWorkflowName returns the name of the workflow of the share token of
+// the request, writing the error response when it's invalid or expired.
+func (h handler) sharedWorkflowName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, err := h.shareTokenClaims(mux.Vars(r)["token"])
+	if errors.Is(err, errExpiredShareToken) {
+		h.errorResponse(w, "share link expired", http.StatusGone)
+		return "", false
+	}
+	if err != nil {
+		h.errorResponse(w, "share link not found", http.StatusNotFound)
+		return "", false
+	}
+	return claims.Workflow, true
+}
+
+// Gets the status of a shared workflow, without authorization.
+func (h handler) getSharedWorkflow(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName, ok := h.sharedWorkflowName(w, r)
+	if !ok {
+		return
+	}
+	l := rs.log("op", "get-shared-workflow", "workflow", workflowName)
+
+	level.Debug(l).Log("message", "getting workflow status")
+	status, err := h.argo.Status(rs.ctx, workflowName)
+	if err != nil {
+		level.Error(l).Log("message", "error getting workflow", "error", err)
+		h.errorResponse(w, "error getting workflow", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.Marshal(responses.GetWorkflowStatus{
+		Name:     workflowName,
+		Status:   status.Status,
+		Created:  status.Created,
+		Finished: status.Finished,
+	})
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		h.errorResponse(w, "error serializing workflow", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
+
+// Gets the logs of a shared workflow, without authorization.
+func (h handler) getSharedWorkflowLogs(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	workflowName, ok := h.sharedWorkflowName(w, r)
+	if !ok {
+		return
+	}
+	l := rs.log("op", "get-shared-workflow-logs", "workflow", workflowName)
+
+	h.writeWorkflowLogs(w, r, l, workflowName)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cello-proj/cello/service/internal/env"
+	"github.com/cello-proj/cello/service/internal/faketest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareToken(t *testing.T) {
+	now := time.Date(2022, 3, 15, 9, 0, 0, 0, time.UTC)
+	h := handler{env: env.Vars{ShareLinkKey: "0123456789abcdef"}, clock: faketest.NewClock(now)}
+
+	token, err := h.shareToken(shareClaims{Workflow: "project1-target1-abcde", Expires: now.Add(time.Hour).Unix()})
+	assert.Nil(t, err)
+
+	claims, err := h.shareTokenClaims(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "project1-target1-abcde", claims.Workflow)
+
+	expired, err := h.shareToken(shareClaims{Workflow: "project1-target1-abcde", Expires: now.Unix()})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		key     string
+		wantErr error
+	}{
+		{name: "tampered", token: "eyJ3b3JrZmxvdyI6Im90aGVyIiwiZXhwIjo5OTk5OTk5OTk5fQ" + token[len(token)-44:], key: h.env.ShareLinkKey, wantErr: errInvalidShareToken},
+		{name: "other_key", token: token, key: "fedcba9876543210", wantErr: errInvalidShareToken},
+		{name: "malformed", token: "abc", key: h.env.ShareLinkKey, wantErr: errInvalidShareToken},
+		{name: "expired", token: expired, key: h.env.ShareLinkKey, wantErr: errExpiredShareToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := handler{env: env.Vars{ShareLinkKey: tt.key}, clock: h.clock}.shareTokenClaims(tt.token)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestShareBaseURL(t *testing.T) {
+	_, err := handler{}.shareBaseURL()
+	assert.Equal(t, errNoExternalURL, err)
+
+	baseURL, err := handler{env: env.Vars{ExternalURL: "https://cello.example.com/"}}.shareBaseURL()
+	assert.Nil(t, err)
+	assert.Equal(t, "https://cello.example.com", baseURL)
+}
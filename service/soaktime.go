@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// Sets the soak time of a target, i.e. how long after a diff of a commit
+// succeeded syncs of the commit can be submitted to the target.
+func (h handler) putTargetSoakTime(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "put-target-soak-time", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var str requests.PutTargetSoakTime
+	if err := json.Unmarshal(reqBody, &str); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := str.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validated above, empty durations are unbounded.
+	minSoak, _ := time.ParseDuration(str.MinSoak)
+	maxStaleness, _ := time.ParseDuration(str.MaxStaleness)
+	e := db.TargetSoakTimeEntry{
+		Project:             projectName,
+		Target:              targetName,
+		MinSoakSeconds:      int64(minSoak.Seconds()),
+		MaxStalenessSeconds: int64(maxStaleness.Seconds()),
+	}
+
+	level.Debug(l).Log("message", "storing target soak time")
+	if err := h.dbClient.CreateTargetSoakTimeEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing target soak time", "error", err)
+		h.errorResponse(w, "error storing target soak time", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(soakTimeResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the soak time of a target.
+func (h handler) getTargetSoakTime(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "get-target-soak-time", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	e, err := h.dbClient.ReadTargetSoakTimeEntry(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "soak time not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target soak time", "error", err)
+		h.errorResponse(w, "error reading target soak time", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(soakTimeResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the soak time of a target.
+func (h handler) deleteTargetSoakTime(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "delete-target-soak-time", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetSoakTimeEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target soak time", "error", err)
+		h.errorResponse(w, "error deleting target soak time", http.StatusInternalServerError)
+		return
+	}
+}
+
+func soakTimeResponse(e db.TargetSoakTimeEntry) responses.GetTargetSoakTime {
+	var resp responses.GetTargetSoakTime
+	if e.MinSoakSeconds > 0 {
+		resp.MinSoak = (time.Duration(e.MinSoakSeconds) * time.Second).String()
+	}
+	if e.MaxStalenessSeconds > 0 {
+		resp.MaxStaleness = (time.Duration(e.MaxStalenessSeconds) * time.Second).String()
+	}
+	return resp
+}
+
+// lastSucceededDiff returns when the latest successful diff of the commit to
+// the target finished, false when there's none.
+func (h handler) lastSucceededDiff(ctx context.Context, projectName, targetName, commitHash string) (time.Time, bool, error) {
+	statuses, err := h.argo.ListByLabels(ctx, map[string]string{
+		workflow.LabelProject:    projectName,
+		workflow.LabelTarget:     targetName,
+		workflow.LabelType:       "diff",
+		workflow.LabelCommitHash: commitHash,
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	var last int64
+	for _, s := range statuses {
+		if s.Status != "succeeded" {
+			continue
+		}
+		finished, err := strconv.ParseInt(s.Finished, 10, 64)
+		if err != nil {
+			continue
+		}
+		if finished > last {
+			last = finished
+		}
+	}
+	if last == 0 {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(last, 0), true, nil
+}
+
+// soakedDiff checks syncs to a target with a soak time are of a commit whose
+// diff succeeded at least the min soak and at most the max staleness of the
+// target ago, responding with a conflict otherwise. Syncs not from git can't
+// be matched with a diff, so they're rejected.
+func (h handler) soakedDiff(ctx context.Context, w http.ResponseWriter, r *http.Request, l log.Logger, cwr requests.CreateWorkflow, commitHash string) bool {
+	if cwr.Type != "sync" {
+		return true
+	}
+
+	e, err := h.dbClient.ReadTargetSoakTimeEntry(ctx, cwr.ProjectName, cwr.TargetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		return true
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target soak time", "error", err)
+		h.errorResponse(w, "error reading target soak time", http.StatusInternalServerError)
+		return false
+	}
+
+	if commitHash == "" {
+		h.errorResponse(w, fmt.Sprintf("target '%s' has a soak time, syncs of it must be of a git commit", cwr.TargetName), http.StatusConflict)
+		return false
+	}
+
+	finished, ok, err := h.lastSucceededDiff(ctx, cwr.ProjectName, cwr.TargetName, commitHash)
+	if err != nil {
+		level.Error(l).Log("message", "error listing diff workflows", "error", err)
+		h.errorResponse(w, "error listing diff workflows", http.StatusInternalServerError)
+		return false
+	}
+
+	soak := soakTimeResponse(e)
+	now := h.now()
+	maxStaleness := time.Duration(e.MaxStalenessSeconds) * time.Second
+	if !ok || (maxStaleness > 0 && now.Sub(finished) > maxStaleness) {
+		level.Info(l).Log("message", "sync without a recent successful diff", "commit", commitHash, "found", ok)
+		h.messageResponse(w, r, messages.DiffRequired, messages.Params{"project": cwr.ProjectName, "target": cwr.TargetName, "commit": commitHash, "max_staleness": soak.MaxStaleness}, http.StatusConflict)
+		return false
+	}
+
+	until := finished.Add(time.Duration(e.MinSoakSeconds) * time.Second)
+	if now.Before(until) {
+		level.Info(l).Log("message", "sync of a soaking diff", "commit", commitHash, "until", until)
+		h.messageResponse(w, r, messages.DiffSoaking, messages.Params{"project": cwr.ProjectName, "target": cwr.TargetName, "commit": commitHash, "until": until.UTC().Format(time.RFC3339)}, http.StatusConflict)
+		return false
+	}
+	return true
+}
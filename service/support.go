@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+
+	"github.com/cello-proj/cello/internal/responses"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+)
+
+// defaultSupportTenant is the tenant of the support contact of tenants
+// without their own.
+const defaultSupportTenant = "*"
+
+// SupportContact is where users go for help with failures of the platform,
+// e.g. the on-call of the team operating it.
+type SupportContact struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+	URL   string `yaml:"url"`
+	// Chat is a chat channel, e.g. #platform-support.
+	Chat string `yaml:"chat"`
+}
+
+// validateSupportContacts validates the support contacts of the config, which
+// must have an email, url or chat.
+func (c Config) validateSupportContacts() error {
+	for tenant, sc := range c.SupportContacts {
+		if sc.Email == "" && sc.URL == "" && sc.Chat == "" {
+			return fmt.Errorf("support_contacts: contact of '%s' requires an email, url or chat", tenant)
+		}
+		if sc.Email != "" {
+			if _, err := mail.ParseAddress(sc.Email); err != nil {
+				return fmt.Errorf("support_contacts: email of '%s' is invalid", tenant)
+			}
+		}
+		if sc.URL != "" {
+			if u, err := url.Parse(sc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("support_contacts: url of '%s' must be an http(s) url", tenant)
+			}
+		}
+	}
+	return nil
+}
+
+// supportContact returns the support contact of the tenant of the project,
+// the default one when the project is empty or its tenant has none. Nil when
+// there's neither. Errors reading the settings of the project are logged and
+// its name is used as tenant, like for projects without one.
+func (h handler) supportContact(ctx context.Context, projectName string) *responses.SupportContact {
+	if h.config == nil || len(h.config.SupportContacts) == 0 {
+		return nil
+	}
+
+	sc, ok := SupportContact{}, false
+	if projectName != "" {
+		tenant := projectName
+		settings, err := h.projectSettings(ctx, projectName)
+		if err != nil {
+			level.Warn(h.logger).Log("message", "error reading project settings, support contact of the project name", "project", projectName, "error", err)
+		} else if settings.Tenant != "" {
+			tenant = settings.Tenant
+		}
+		sc, ok = h.config.SupportContacts[tenant]
+	}
+	if !ok {
+		if sc, ok = h.config.SupportContacts[defaultSupportTenant]; !ok {
+			return nil
+		}
+	}
+	return &responses.SupportContact{Name: sc.Name, Email: sc.Email, URL: sc.URL, Chat: sc.Chat}
+}
+
+// supportMiddleware adds the support contact of the tenant of the project of
+// the request to its 5xx error responses, so users hitting failures of the
+// platform know where to go. Streams and other responses are written as is.
+func (h handler) supportMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.config == nil || len(h.config.SupportContacts) == 0 || routeClass(r) == routeClassStream {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &supportWriter{ResponseWriter: w, h: h, r: r}
+		next.ServeHTTP(sw, r)
+		if sw.status >= http.StatusInternalServerError && !sw.wroteHeader {
+			sw.ResponseWriter.WriteHeader(sw.status)
+		}
+	})
+}
+
+// supportWriter holds back the header of 5xx responses until their body is
+// written, so the support contact can be added to it.
+type supportWriter struct {
+	http.ResponseWriter
+	h handler
+	r *http.Request
+
+	status      int
+	wroteHeader bool
+}
+
+func (w *supportWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status < http.StatusInternalServerError {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *supportWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.wroteHeader {
+		return w.ResponseWriter.Write(b)
+	}
+
+	// Error responses are written at once, other bodies are written as is.
+	w.wroteHeader = true
+	data := b
+	var er errorResponse
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&er); err == nil && er.ErrorMessage != "" && er.Support == nil {
+		if er.Support = w.h.supportContact(w.r.Context(), mux.Vars(w.r)["projectName"]); er.Support != nil {
+			data, _ = json.Marshal(er)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(data); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportMiddleware(t *testing.T) {
+	h := handler{
+		logger:   log.NewNopLogger(),
+		dbClient: mockDB{},
+		config: &Config{
+			SupportContacts: map[string]SupportContact{
+				"*":             {Name: "Platform", URL: "https://support.example.com"},
+				"team-payments": {Name: "Payments platform", Email: "payments-platform@example.com", Chat: "#payments-platform"},
+			},
+		},
+	}
+
+	r := mux.NewRouter()
+	r.Use(h.supportMiddleware)
+	r.HandleFunc("/projects/{projectName}/fail", func(w http.ResponseWriter, r *http.Request) {
+		h.errorResponse(w, "error reading project", http.StatusInternalServerError)
+	})
+	r.HandleFunc("/projects/{projectName}/missing", func(w http.ResponseWriter, r *http.Request) {
+		h.errorResponse(w, "project not found", http.StatusNotFound)
+	})
+	r.HandleFunc("/projects/{projectName}/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+	})
+	r.HandleFunc("/workflows/{workflowName}/logstream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("line 1\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "tenant_contact",
+			path:     "/projects/projectwithsettings/fail",
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error_message":"error reading project","support":{"name":"Payments platform","email":"payments-platform@example.com","chat":"#payments-platform"}}`,
+		},
+		{
+			name:     "default_contact",
+			path:     "/projects/projectalreadyexists/fail",
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error_message":"error reading project","support":{"name":"Platform","url":"https://support.example.com"}}`,
+		},
+		{
+			name:     "client_error",
+			path:     "/projects/projectalreadyexists/missing",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error_message":"project not found"}`,
+		},
+		{
+			name:     "not_error_response",
+			path:     "/projects/projectalreadyexists/unavailable",
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "unavailable",
+		},
+		{
+			name:     "stream",
+			path:     "/workflows/wf/logstream",
+			wantCode: http.StatusOK,
+			wantBody: "line 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestValidateSupportContacts(t *testing.T) {
+	assert.NoError(t, Config{SupportContacts: map[string]SupportContact{"*": {Email: "platform@example.com"}}}.validateSupportContacts())
+	assert.EqualError(t, Config{SupportContacts: map[string]SupportContact{"*": {Name: "Platform"}}}.validateSupportContacts(),
+		"support_contacts: contact of '*' requires an email, url or chat")
+	assert.EqualError(t, Config{SupportContacts: map[string]SupportContact{"team": {Email: "platform"}}}.validateSupportContacts(),
+		"support_contacts: email of 'team' is invalid")
+	assert.EqualError(t, Config{SupportContacts: map[string]SupportContact{"team": {URL: "ftp://support.example.com"}}}.validateSupportContacts(),
+		"support_contacts: url of 'team' must be an http(s) url")
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/credentials"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/tfstate"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// Maximum time a terraform run is watched for stale state locks.
+const terraformLockWatchTimeout = 6 * time.Hour
+
+// terraformForceUnlockType is the type label of force-unlock workflows.
+const terraformForceUnlockType = "force-unlock"
+
+// terraformForceUnlockCommand is the command definition of force-unlock
+// workflows, formatted with the lock ID. Lock IDs are validated with
+// tfstate.ValidLockID when detected.
+const terraformForceUnlockCommand = "{{.EnvironmentVariables}} terraform init {{.InitArguments}} && {{.EnvironmentVariables}} terraform force-unlock -force %s"
+
+// Sets the backend and location of the terraform state of a target, so stale
+// locks of it are detected in the logs of failed runs.
+func (h handler) putTargetTerraformState(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "put-target-terraform-state", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var ptr requests.PutTargetTerraformState
+	if err := json.Unmarshal(reqBody, &ptr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := ptr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// The stale lock detected, if any, is kept.
+	e, err := h.dbClient.ReadTargetTerraformStateEntry(rs.ctx, projectName, targetName)
+	if err != nil && !errors.Is(err, upper.ErrNoMoreRows) {
+		level.Error(l).Log("message", "error reading target terraform state", "error", err)
+		h.errorResponse(w, "error reading target terraform state", http.StatusInternalServerError)
+		return
+	}
+	e.Project = projectName
+	e.Target = targetName
+	e.Backend = ptr.Backend
+	e.Location = ptr.Location
+
+	level.Debug(l).Log("message", "storing target terraform state")
+	if err := h.dbClient.CreateTargetTerraformStateEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing target terraform state", "error", err)
+		h.errorResponse(w, "error storing target terraform state", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(terraformStateResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the terraform state of a target with the stale lock detected, if any.
+func (h handler) getTargetTerraformState(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "get-target-terraform-state", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	e, err := h.dbClient.ReadTargetTerraformStateEntry(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "terraform state not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target terraform state", "error", err)
+		h.errorResponse(w, "error reading target terraform state", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(terraformStateResponse(e))
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes the terraform state of a target, which stops the detection of its
+// stale locks.
+func (h handler) deleteTargetTerraformState(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+
+	l := rs.log("op", "delete-target-terraform-state", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	if err := h.dbClient.DeleteTargetTerraformStateEntry(rs.ctx, projectName, targetName); err != nil {
+		level.Error(l).Log("message", "error deleting target terraform state", "error", err)
+		h.errorResponse(w, "error deleting target terraform state", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Releases the stale lock of the terraform state of a target with a workflow
+// running terraform force-unlock, submitted like the failed run which detected
+// the lock. The lock ID must be the one of the stale lock, so a lock acquired
+// since isn't released.
+func (h handler) forceUnlockTargetTerraformState(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	targetName := mux.Vars(r)["targetName"]
+	txID := r.Header.Get(txIDHeader)
+
+	l := rs.log("op", "force-unlock-target-terraform-state", "project", projectName, "target", targetName)
+
+	if !h.authorizedAdminTarget(w, r, l, projectName, targetName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var fur requests.ForceUnlockTerraformState
+	if err := json.Unmarshal(reqBody, &fur); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := fur.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	e, err := h.dbClient.ReadTargetTerraformStateEntry(rs.ctx, projectName, targetName)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "terraform state not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading target terraform state", "error", err)
+		h.errorResponse(w, "error reading target terraform state", http.StatusInternalServerError)
+		return
+	}
+	if e.LockID == "" {
+		h.errorResponse(w, "no stale terraform state lock detected", http.StatusConflict)
+		return
+	}
+	if e.LockID != fur.LockID {
+		h.errorResponse(w, fmt.Sprintf("lock_id doesn't match the stale terraform state lock '%s'", e.LockID), http.StatusConflict)
+		return
+	}
+
+	// The lock may be held by a run submitted since it was detected.
+	active, err := h.findActiveWorkflow(rs.ctx, map[string]string{workflow.LabelProject: projectName, workflow.LabelTarget: targetName})
+	if err != nil {
+		level.Error(l).Log("message", "error listing workflows", "error", err)
+		h.errorResponse(w, "error listing workflows", http.StatusInternalServerError)
+		return
+	}
+	if active != "" {
+		h.errorResponse(w, fmt.Sprintf("workflow '%s' of the target is running, it may hold the lock", active), http.StatusConflict)
+		return
+	}
+
+	var cwr requests.CreateWorkflow
+	if err := json.Unmarshal([]byte(e.Workflow), &cwr); err != nil {
+		level.Error(l).Log("message", "error deserializing workflow", "error", err)
+		h.errorResponse(w, "error reading target terraform state", http.StatusInternalServerError)
+		return
+	}
+
+	cp, err := h.adminCredentialsProvider(r.Header)
+	if err != nil {
+		level.Error(l).Log("message", "error creating credentials provider", "error", err)
+		h.errorResponse(w, "error creating credentials provider", http.StatusInternalServerError)
+		return
+	}
+
+	workflowName, err := h.submitTerraformForceUnlock(rs.ctx, l, cp, txID, cwr, e.LockID)
+	if err != nil {
+		level.Error(l).Log("message", "error creating workflow", "error", err)
+		if workflow.IsTransient(err) {
+			h.errorResponse(w, fmt.Sprintf("error creating workflow, %s", err), http.StatusBadGateway)
+			return
+		}
+		h.errorResponse(w, "error creating workflow", http.StatusInternalServerError)
+		return
+	}
+
+	l = log.With(l, "workflow", workflowName)
+	level.Info(l).Log("message", "terraform state force-unlock submitted", "lock_id", e.LockID)
+	h.recordExecutionEvent(rs.ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      projectName,
+		Target:       targetName,
+		WorkflowName: workflowName,
+		Type:         "terraform_state_force_unlock",
+		Message:      fmt.Sprintf("force-unlock of terraform state lock %s held by %s requested", e.LockID, e.LockWho),
+		CreatedAt:    h.now().UTC(),
+	})
+	h.watchTerraformLock(l, txID, cwr, workflowName)
+
+	data, err := json.Marshal(responses.ExecuteWorkflow{WorkflowName: workflowName})
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Submits the force-unlock workflow of the lock with a new project token, with
+// the template, image and credentials of the run which detected it.
+func (h handler) submitTerraformForceUnlock(ctx context.Context, l log.Logger, cp credentials.Provider, txID string, cwr requests.CreateWorkflow, lockID string) (string, error) {
+	// The template may no longer be allowed for the project.
+	from, referenced, err := h.workflowTemplateFrom(ctx, cwr)
+	if err != nil {
+		return "", err
+	}
+
+	parameters, scheduling, err := h.projectCommandParameters(ctx, cp, cwr, fmt.Sprintf(terraformForceUnlockCommand, lockID), referenced)
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := h.projectSettings(ctx, cwr.ProjectName)
+	if err != nil {
+		return "", fmt.Errorf("error reading project settings: %w", err)
+	}
+
+	retryPolicy := workflow.RetryPolicy{
+		MaxAttempts:    h.env.ArgoSubmitMaxAttempts,
+		InitialBackoff: h.env.ArgoSubmitInitialBackoff,
+		MaxBackoff:     h.env.ArgoSubmitMaxBackoff,
+		IdentityLabels: submissionIdentityLabels,
+	}
+	labels := map[string]string{
+		workflow.LabelProject: cwr.ProjectName,
+		workflow.LabelTarget:  cwr.TargetName,
+		workflow.LabelType:    terraformForceUnlockType,
+		txIDHeader:            txID,
+	}
+	addCostLabels(labels, settings, cwr.ProjectName, txID)
+	return workflow.SubmitWithRetry(h.argoCtx, h.argo, retryPolicy, from, parameters, labels, nil, scheduling, func(attempt int, name string, err error) {
+		if err != nil {
+			level.Warn(l).Log("message", "force-unlock submission attempt failed", "attempt", attempt, "error", err)
+		}
+	})
+}
+
+// Watches a terraform run of a target with a terraform state until it
+// completes to detect stale locks, see recordTerraformLock.
+func (h handler) watchTerraformLock(l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	if cwr.Framework != "terraform" || h.env.TerraformLockWatchInterval <= 0 {
+		return
+	}
+
+	// The request context is done once the response is written.
+	go func() {
+		ctx, cancel := context.WithTimeout(h.argoCtx, terraformLockWatchTimeout)
+		defer cancel()
+		h.recordTerraformLock(ctx, l, txID, cwr, workflowName)
+	}()
+}
+
+// Records the lock of the terraform state a failed run couldn't acquire, read
+// from its logs, when no workflow of the target is running to hold it, as a
+// 'terraform_state_locked' execution event and for the terraform state of the
+// target. Successful runs acquired the lock, so the stale lock recorded is
+// cleared. Targets without a terraform state are skipped.
+func (h handler) recordTerraformLock(ctx context.Context, l log.Logger, txID string, cwr requests.CreateWorkflow, workflowName string) {
+	if _, err := h.dbClient.ReadTargetTerraformStateEntry(ctx, cwr.ProjectName, cwr.TargetName); err != nil {
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Warn(l).Log("message", "error reading target terraform state", "error", err)
+		}
+		return
+	}
+
+	status, err := workflow.WaitForCompletion(ctx, h.argo, workflowName, h.env.TerraformLockWatchInterval, func(err error) {
+		level.Warn(l).Log("message", "error getting workflow status", "error", err)
+	})
+	if err != nil {
+		return
+	}
+
+	var lock tfstate.Lock
+	if status.Status != "succeeded" {
+		logs, err := h.argo.Logs(ctx, workflowName, workflow.LogOptions{})
+		if err != nil {
+			level.Error(l).Log("message", "error getting workflow logs", "error", err)
+			return
+		}
+		var lines []string
+		if logs != nil {
+			lines = logs.Logs
+		}
+		var ok bool
+		if lock, ok = tfstate.LockFromLogs(lines); !ok {
+			return
+		}
+		if !tfstate.ValidLockID(lock.ID) {
+			level.Warn(l).Log("message", "invalid terraform state lock id", "lock_id", lock.ID)
+			return
+		}
+
+		active, err := h.findActiveWorkflow(ctx, map[string]string{workflow.LabelProject: cwr.ProjectName, workflow.LabelTarget: cwr.TargetName})
+		if err != nil {
+			level.Error(l).Log("message", "error listing workflows", "error", err)
+			return
+		}
+		if active != "" {
+			level.Info(l).Log("message", "terraform state locked by a running workflow", "lock_id", lock.ID, "running", active)
+			return
+		}
+	}
+
+	// Read again, the terraform state may have changed while the run was
+	// watched.
+	e, err := h.dbClient.ReadTargetTerraformStateEntry(ctx, cwr.ProjectName, cwr.TargetName)
+	if err != nil {
+		if !errors.Is(err, upper.ErrNoMoreRows) {
+			level.Warn(l).Log("message", "error reading target terraform state", "error", err)
+		}
+		return
+	}
+
+	if lock.ID == "" {
+		if e.LockID == "" {
+			return
+		}
+		level.Info(l).Log("message", "clearing stale terraform state lock", "lock_id", e.LockID)
+		e = db.TargetTerraformStateEntry{Project: e.Project, Target: e.Target, Backend: e.Backend, Location: e.Location}
+		if err := h.dbClient.CreateTargetTerraformStateEntry(ctx, e); err != nil {
+			level.Error(l).Log("message", "error storing target terraform state", "error", err)
+		}
+		return
+	}
+
+	data, err := json.Marshal(cwr)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing workflow", "error", err)
+		return
+	}
+	detected := h.now().UTC()
+	e.LockID = lock.ID
+	e.LockPath = lock.Path
+	e.LockOperation = lock.Operation
+	e.LockWho = lock.Who
+	e.LockCreatedAt = nil
+	if !lock.Created.IsZero() {
+		e.LockCreatedAt = &lock.Created
+	}
+	e.LockWorkflowName = workflowName
+	e.LockDetectedAt = &detected
+	e.Workflow = string(data)
+	if err := h.dbClient.CreateTargetTerraformStateEntry(ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing target terraform state", "error", err)
+		return
+	}
+
+	level.Warn(l).Log("message", "stale terraform state lock detected", "lock_id", lock.ID, "who", lock.Who)
+	h.recordExecutionEvent(ctx, l, db.ExecutionEvent{
+		TxID:         txID,
+		Project:      cwr.ProjectName,
+		Target:       cwr.TargetName,
+		WorkflowName: workflowName,
+		Type:         "terraform_state_locked",
+		Message:      fmt.Sprintf("terraform state %s is locked by stale lock %s held by %s, it can be force-unlocked", e.Location, lock.ID, lock.Who),
+		CreatedAt:    detected,
+	})
+}
+
+func terraformStateResponse(e db.TargetTerraformStateEntry) responses.GetTargetTerraformState {
+	resp := responses.GetTargetTerraformState{Backend: e.Backend, Location: e.Location}
+	if e.LockID == "" {
+		return resp
+	}
+
+	resp.Lock = &responses.TerraformStateLock{
+		ID:           e.LockID,
+		Path:         e.LockPath,
+		Operation:    e.LockOperation,
+		Who:          e.LockWho,
+		WorkflowName: e.LockWorkflowName,
+	}
+	if e.LockCreatedAt != nil {
+		resp.Lock.CreatedAt = e.LockCreatedAt.UTC().Format(time.RFC3339)
+	}
+	if e.LockDetectedAt != nil {
+		resp.Lock.DetectedAt = e.LockDetectedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
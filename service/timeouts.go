@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Classes of routes, each with a timeout of its own in the service config.
+const (
+	routeClassSubmission = "submission"
+	routeClassStream     = "stream"
+	routeClassRead       = "read"
+	routeClassWrite      = "write"
+)
+
+var routeClasses = []string{routeClassSubmission, routeClassStream, routeClassRead, routeClassWrite}
+
+// submissionRoutes are the routes submitting workflows.
+var submissionRoutes = map[string]bool{
+	"/workflows": true,
+	"/projects/{projectName}/targets/{targetName}/operations":     true,
+	"/projects/{projectName}/targets/{targetName}/oci-operations": true,
+}
+
+var slowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cello",
+	Name:      "slow_requests_total",
+	Help:      "Requests exceeding the slow request threshold, by route class.",
+}, []string{"route_class"})
+
+func init() {
+	prometheus.MustRegister(slowRequests)
+}
+
+// routeClass returns the class of the route of the request. Log streams are
+// streamed, POST requests of submissionRoutes submit and other GET requests
+// read.
+func routeClass(r *http.Request) string {
+	var tmpl string
+	if route := mux.CurrentRoute(r); route != nil {
+		tmpl, _ = route.GetPathTemplate()
+	}
+
+	switch {
+	case strings.HasSuffix(tmpl, "/logstream"):
+		return routeClassStream
+	case r.Method == http.MethodPost && submissionRoutes[tmpl]:
+		return routeClassSubmission
+	case r.Method == http.MethodGet:
+		return routeClassRead
+	default:
+		return routeClassWrite
+	}
+}
+
+// validateRouteTimeouts validates the route timeouts and the slow request
+// threshold of the config.
+func (c Config) validateRouteTimeouts() error {
+	for class, timeout := range c.RouteTimeouts {
+		known := false
+		for _, rc := range routeClasses {
+			known = known || rc == class
+		}
+		if !known {
+			return fmt.Errorf("route_timeouts: route class must be one of '%s'", strings.Join(routeClasses, " "))
+		}
+		if timeout < 0 {
+			return fmt.Errorf("route_timeouts: %s timeout must not be negative", class)
+		}
+	}
+	if c.SlowRequestThreshold < 0 {
+		return errors.New("slow_request_threshold must not be negative")
+	}
+	return nil
+}
+
+// stageTimings are the durations of the stages of a request, e.g. the Vault
+// calls of a submission, see recordStage.
+type stageTimings struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+type stageTimingsKey struct{}
+
+// recordStage adds the duration since start to the stage of the request of
+// the context, if any.
+func recordStage(ctx context.Context, stage string, start time.Time) {
+	t, ok := ctx.Value(stageTimingsKey{}).(*stageTimings)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[stage] += time.Since(start)
+}
+
+// logFields returns the durations of the stages as log fields, sorted by
+// stage.
+func (t *stageTimings) logFields() []interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stages := make([]string, 0, len(t.durations))
+	for stage := range t.durations {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	fields := make([]interface{}, 0, 2*len(stages))
+	for _, stage := range stages {
+		fields = append(fields, strings.ReplaceAll(stage, " ", "-"), t.durations[stage].String())
+	}
+	return fields
+}
+
+// routeTimeoutMiddleware times out requests after the timeout of their route
+// class and logs requests exceeding the slow request threshold with the
+// durations of their stages. Responses are buffered like with
+// http.TimeoutHandler, so a timed out request gets a 504 naming its limit
+// instead of a partial response. Streams aren't buffered, they end at their
+// timeout.
+func (h handler) routeTimeoutMiddleware(next http.Handler) http.Handler {
+	var (
+		timeouts  map[string]time.Duration
+		threshold time.Duration
+	)
+	if h.config != nil {
+		timeouts = h.config.RouteTimeouts
+		threshold = h.config.SlowRequestThreshold
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := routeClass(r)
+		timings := &stageTimings{durations: map[string]time.Duration{}}
+		ctx := context.WithValue(r.Context(), stageTimingsKey{}, timings)
+
+		start := time.Now()
+		if timeout := timeouts[class]; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			if class == routeClassStream {
+				next.ServeHTTP(w, r.WithContext(ctx))
+			} else {
+				h.serveWithTimeout(ctx, w, r.WithContext(ctx), next, &limitError{stage: class + " request", limit: timeout.String()})
+			}
+		} else {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		duration := time.Since(start)
+		if threshold <= 0 || duration < threshold || class == routeClassStream {
+			return
+		}
+		slowRequests.WithLabelValues(class).Inc()
+		fields := append([]interface{}{"message", "slow request", "method", r.Method, "path", r.URL.Path, "route-class", class, "duration", duration.String()}, timings.logFields()...)
+		level.Warn(h.requestLogger(r)).Log(fields...)
+	})
+}
+
+// serveWithTimeout serves the request into a buffer, writing the buffered
+// response when the handler returns before the context is done, the error
+// response of the limit when it times out.
+func (h handler) serveWithTimeout(ctx context.Context, w http.ResponseWriter, r *http.Request, next http.Handler, le *limitError) {
+	tw := &timeoutWriter{header: http.Header{}}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		next.ServeHTTP(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		dst := w.Header()
+		for k, vv := range tw.header {
+			dst[k] = vv
+		}
+		if tw.code == 0 {
+			tw.code = http.StatusOK
+		}
+		w.WriteHeader(tw.code)
+		w.Write(tw.buf.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		tw.timedOut = true
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			h.limitErrorResponse(w, r, le, http.StatusGatewayTimeout)
+		}
+	}
+}
+
+// timeoutWriter buffers the response of a handler, discarding writes once
+// the request timed out.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestRouteClass(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodPost, path: "/workflows", want: routeClassSubmission},
+		{method: http.MethodPost, path: "/projects/p/targets/t/operations", want: routeClassSubmission},
+		{method: http.MethodGet, path: "/workflows/wf/logstream", want: routeClassStream},
+		{method: http.MethodGet, path: "/workflows/wf", want: routeClassRead},
+		{method: http.MethodPost, path: "/workflows/preview", want: routeClassWrite},
+		{method: http.MethodDelete, path: "/projects/p", want: routeClassWrite},
+	}
+
+	r := mux.NewRouter()
+	var got string
+	record := func(w http.ResponseWriter, r *http.Request) { got = routeClass(r) }
+	r.HandleFunc("/workflows", record)
+	r.HandleFunc("/workflows/preview", record)
+	r.HandleFunc("/workflows/{workflowName}", record)
+	r.HandleFunc("/workflows/{workflowName}/logstream", record)
+	r.HandleFunc("/projects/{projectName}", record)
+	r.HandleFunc("/projects/{projectName}/targets/{targetName}/operations", record)
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRouteTimeoutMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	h := handler{
+		logger: log.NewLogfmtLogger(&logs),
+		config: &Config{
+			RouteTimeouts:        map[string]time.Duration{routeClassRead: 50 * time.Millisecond, routeClassStream: 50 * time.Millisecond},
+			SlowRequestThreshold: 20 * time.Millisecond,
+		},
+	}
+
+	r := mux.NewRouter()
+	r.Use(h.routeTimeoutMiddleware)
+	r.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "fast")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("done"))
+	})
+	r.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		time.Sleep(30 * time.Millisecond)
+		recordStage(r.Context(), stageVault, start)
+		w.Write([]byte("done"))
+	})
+	r.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte("too late"))
+	})
+	r.HandleFunc("/{workflowName}/logstream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("line 1\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	t.Run("completed_before_timeout", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "fast", w.Header().Get("X-Test"))
+		assert.Equal(t, "done", w.Body.String())
+	})
+
+	t.Run("slow_request_logged_with_stages", func(t *testing.T) {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, logs.String(), "message=\"slow request\"")
+		assert.Contains(t, logs.String(), "route-class=read")
+		assert.Contains(t, logs.String(), "vault=")
+	})
+
+	t.Run("timed_out", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hang", nil))
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, `{"error_message":"read request exceeded the limit of 50ms","stage":"read request","limit":"50ms","code":"limit_exceeded","params":{"limit":"50ms","stage":"read request"}}`, w.Body.String())
+	})
+
+	t.Run("stream_not_buffered", func(t *testing.T) {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wf/logstream", nil))
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "line 1\n", w.Body.String())
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("no_timeout_for_class", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/fast", nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	})
+}
+
+func TestRouteTimeoutsConfig(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte("route_timeouts:\n  submission: 2m\n  read: 10s\nslow_request_threshold: 5s\n"), &config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{routeClassSubmission: 2 * time.Minute, routeClassRead: 10 * time.Second}, config.RouteTimeouts)
+	assert.Equal(t, 5*time.Second, config.SlowRequestThreshold)
+}
+
+func TestValidateRouteTimeouts(t *testing.T) {
+	assert.NoError(t, Config{RouteTimeouts: map[string]time.Duration{routeClassSubmission: time.Minute}}.validateRouteTimeouts())
+	assert.EqualError(t, Config{RouteTimeouts: map[string]time.Duration{"metadata": time.Minute}}.validateRouteTimeouts(),
+		"route_timeouts: route class must be one of 'submission stream read write'")
+	assert.EqualError(t, Config{RouteTimeouts: map[string]time.Duration{routeClassRead: -time.Second}}.validateRouteTimeouts(),
+		"route_timeouts: read timeout must not be negative")
+	assert.EqualError(t, Config{SlowRequestThreshold: -time.Second}.validateRouteTimeouts(),
+		"slow_request_threshold must not be negative")
+}
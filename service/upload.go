@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/types"
+	"github.com/cello-proj/cello/service/internal/bundle"
+	"github.com/cello-proj/cello/service/internal/messages"
+
+	"github.com/go-kit/log/level"
+)
+
+// Size of the multipart forms of bundle uploads kept in memory, larger
+// bundles are stored in temporary files until the request completes.
+const uploadMemoryBytes = 32 << 10
+
+// Creates a workflow from the manifest of an uploaded bundle, for
+// environments where the service can't reach git. The bundle is a gzipped
+// tarball verified against its checksum and scanned for secrets, stored only
+// for the request, and its manifest is submitted like one from git.
+func (h handler) createWorkflowFromUpload(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "create-workflow-from-upload")
+
+	level.Debug(l).Log("message", "validating authorization header for create workflow from upload")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	level.Debug(l).Log("message", "reading multipart form")
+	if err := r.ParseMultipartForm(uploadMemoryBytes); err != nil {
+		level.Error(l).Log("message", "error reading multipart form", "error", err)
+		h.errorResponse(w, "invalid request, request must be a multipart form with a bundle file", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	cuwr := requests.CreateUploadWorkflow{
+		SHA256:       r.FormValue("sha256"),
+		Path:         r.FormValue("path"),
+		Name:         r.FormValue("name"),
+		ChangeTicket: r.FormValue("change_ticket"),
+	}
+	if err := cuwr.Validate(); err != nil {
+		level.Error(l).Log("message", "error validating request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("bundle")
+	if err != nil {
+		level.Error(l).Log("message", "error reading bundle file", "error", err)
+		h.errorResponse(w, "invalid request, request must be a multipart form with a bundle file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if limit := h.env.ManifestBundleMaxBytes; limit > 0 && header.Size > limit {
+		h.limitErrorResponse(w, r, &limitError{stage: stageBundle, limit: fmt.Sprintf("%d bytes", limit)}, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		level.Error(l).Log("message", "error reading bundle file", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+	if err := bundle.Verify(data, cuwr.SHA256); err != nil {
+		level.Error(l).Log("message", "error verifying bundle", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	b, err := bundle.Read(bytes.NewReader(data))
+	if err != nil {
+		level.Error(l).Log("message", "error reading bundle", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "scanning bundle for secrets")
+	if !h.checkBundleSecrets(w, l, b) {
+		return
+	}
+
+	fileContents, err := b.File(cuwr.Path)
+	if errors.Is(err, bundle.ErrNotFound) {
+		h.errorResponse(w, fmt.Sprintf("invalid request, bundle %s", err), http.StatusBadRequest)
+		return
+	}
+
+	cwr, err := manifestSelection{name: cuwr.Name}.load(fileContents)
+	var perr *types.InvalidParameterError
+	if errors.Is(err, requests.ErrManifestSelection) || errors.As(err, &perr) {
+		level.Error(l).Log("message", "error loading manifest workflow", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error loading workflow data from bundle", "error", err)
+		h.errorResponse(w, "error loading workflow data from bundle", http.StatusInternalServerError)
+		return
+	}
+
+	if cuwr.ChangeTicket != "" {
+		cwr.ChangeTicket = cuwr.ChangeTicket
+	}
+
+	l = rs.log("op", "create-workflow-from-upload", "project", cwr.ProjectName, "target", cwr.TargetName, "framework", cwr.Framework, "type", cwr.Type, "workflow-template", cwr.WorkflowTemplateName)
+	level.Debug(l).Log("message", "creating workflow", "bundle", header.Filename, "sha256", cuwr.SHA256)
+	h.createWorkflowFromRequest(rs, w, r, cwr, "", "upload@sha256:"+cuwr.SHA256+":"+cuwr.Path, l)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/messages"
+	"github.com/cello-proj/cello/service/internal/workflow"
+
+	"github.com/go-kit/log/level"
+)
+
+// Cost labels the usage report can be aggregated by.
+var usageLabels = map[string]string{
+	"tenant":       workflow.LabelTenant,
+	"project":      workflow.LabelProject,
+	"target":       workflow.LabelTarget,
+	"execution_id": workflow.LabelExecutionID,
+}
+
+// Gets the resource usage of workflow pods during the window by value of a
+// cost label (tenant by default), for chargeback.
+func (h handler) getUsage(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	l := rs.log("op", "get-usage")
+
+	level.Debug(l).Log("message", "validating authorization header for usage")
+	a, err := rs.authorization()
+	if err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeaderFormat, nil, http.StatusUnauthorized)
+		return
+	}
+	if err := a.Validate(a.ValidateAuthorizedAdmin(h.env.AdminSecret)); err != nil {
+		h.messageResponse(w, r, messages.InvalidAuthorizationHeader, nil, http.StatusUnauthorized)
+		return
+	}
+
+	windowHours, ok := h.statsWindowHours(w, r)
+	if !ok {
+		return
+	}
+
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "tenant"
+	}
+	label, ok := usageLabels[by]
+	if !ok {
+		h.errorResponse(w, "invalid request, by must be one of 'tenant', 'project', 'target' or 'execution_id'", http.StatusBadRequest)
+		return
+	}
+
+	level.Debug(l).Log("message", "reporting usage", "by", by)
+	report, err := h.usage.Report(rs.ctx, label, time.Duration(windowHours)*time.Hour)
+	if err != nil {
+		level.Error(l).Log("message", "error reporting usage", "error", err)
+		h.errorResponse(w, "error reporting usage", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetUsage{WindowHours: windowHours, By: by, Usage: []responses.LabelUsage{}}
+	for _, u := range report {
+		resp.Usage = append(resp.Usage, responses.LabelUsage{
+			Value:          u.Value,
+			CPUCoreHours:   u.CPUCoreHours,
+			MemoryGiBHours: u.MemoryGiBHours,
+		})
+	}
+
+	jsonData, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error serializing usage", "error", err)
+		h.errorResponse(w, "error serializing usage", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(jsonData))
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cello-proj/cello/internal/requests"
+	"github.com/cello-proj/cello/internal/responses"
+	"github.com/cello-proj/cello/service/internal/db"
+	"github.com/cello-proj/cello/service/internal/webhook"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	upper "github.com/upper/db/v4"
+)
+
+// maxWebhookDeliveries is the number of latest deliveries of a webhook
+// returned by its delivery log.
+const maxWebhookDeliveries = 100
+
+// Registers a webhook of a project, delivering its execution events to the
+// callback URL. The secret signing the deliveries is generated unless given,
+// it's only returned in the response.
+func (h handler) createProjectWebhook(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "create-project-webhook", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	level.Debug(l).Log("message", "reading request body")
+	reqBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		level.Error(l).Log("message", "error reading request data", "error", err)
+		h.errorResponse(w, "error reading request data", http.StatusInternalServerError)
+		return
+	}
+
+	var cwr requests.CreateProjectWebhook
+	if err := json.Unmarshal(reqBody, &cwr); err != nil {
+		level.Error(l).Log("message", "error deserializing request body", "error", err)
+		h.errorResponse(w, "error deserializing request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := cwr.Validate(); err != nil {
+		level.Error(l).Log("message", "error invalid request", "error", err)
+		h.errorResponse(w, fmt.Sprintf("invalid request, %s", err), http.StatusBadRequest)
+		return
+	}
+
+	secret := cwr.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			level.Error(l).Log("message", "error generating webhook secret", "error", err)
+			h.errorResponse(w, "error generating webhook secret", http.StatusInternalServerError)
+			return
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	e := db.ProjectWebhookEntry{
+		ID:        uuid.NewString(),
+		Project:   projectName,
+		URL:       cwr.URL,
+		Secret:    secret,
+		Events:    strings.Join(cwr.Events, ","),
+		CreatedAt: h.now().UTC(),
+	}
+
+	level.Debug(l).Log("message", "storing project webhook", "webhook", e.ID)
+	if err := h.dbClient.CreateProjectWebhookEntry(rs.ctx, e); err != nil {
+		level.Error(l).Log("message", "error storing project webhook", "error", err)
+		h.errorResponse(w, "error storing project webhook", http.StatusInternalServerError)
+		return
+	}
+
+	resp := newProjectWebhookResponse(e)
+	resp.Secret = e.Secret
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Gets the webhooks of a project, without their secrets.
+func (h handler) getProjectWebhooks(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+
+	l := rs.log("op", "get-project-webhooks", "project", projectName)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	entries, err := h.dbClient.ListProjectWebhookEntries(rs.ctx, projectName)
+	if err != nil {
+		level.Error(l).Log("message", "error reading project webhooks", "error", err)
+		h.errorResponse(w, "error reading project webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetProjectWebhooks{}
+	for _, e := range entries {
+		resp = append(resp, newProjectWebhookResponse(e))
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// Deletes a webhook of a project. Its pending deliveries fail.
+func (h handler) deleteProjectWebhook(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	webhookID := mux.Vars(r)["webhookID"]
+
+	l := rs.log("op", "delete-project-webhook", "project", projectName, "webhook", webhookID)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if !h.webhookExists(w, r, l, projectName, webhookID) {
+		return
+	}
+
+	level.Debug(l).Log("message", "deleting project webhook")
+	if err := h.dbClient.DeleteProjectWebhookEntry(rs.ctx, projectName, webhookID); err != nil {
+		level.Error(l).Log("message", "error deleting project webhook", "error", err)
+		h.errorResponse(w, "error deleting project webhook", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Gets the latest deliveries of a webhook of a project, newest first.
+func (h handler) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	rs := h.scope(r)
+	projectName := rs.project
+	webhookID := mux.Vars(r)["webhookID"]
+
+	l := rs.log("op", "get-webhook-deliveries", "project", projectName, "webhook", webhookID)
+
+	if !h.authorizedAdminProject(w, r, l, projectName) {
+		return
+	}
+
+	if !h.webhookExists(w, r, l, projectName, webhookID) {
+		return
+	}
+
+	entries, err := h.dbClient.ListWebhookDeliveryEntries(rs.ctx, webhookID, maxWebhookDeliveries)
+	if err != nil {
+		level.Error(l).Log("message", "error reading webhook deliveries", "error", err)
+		h.errorResponse(w, "error reading webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	resp := responses.GetWebhookDeliveries{}
+	for _, e := range entries {
+		d := responses.WebhookDelivery{
+			ID:           e.ID,
+			EventType:    e.EventType,
+			Status:       e.Status,
+			Attempts:     e.Attempts,
+			ResponseCode: e.ResponseCode,
+			Error:        e.Error,
+			CreatedAt:    e.CreatedAt.UTC().Format(time.RFC3339),
+			UpdatedAt:    e.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		if e.Status == webhook.StatusPending {
+			d.NextAttemptAt = e.NextAttemptAt.UTC().Format(time.RFC3339)
+		}
+		resp = append(resp, d)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(l).Log("message", "error creating response", "error", err)
+		h.errorResponse(w, "error creating response object", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// webhookExists checks the webhook of the project exists, writing the error
+// response otherwise.
+func (h handler) webhookExists(w http.ResponseWriter, r *http.Request, l log.Logger, projectName, webhookID string) bool {
+	_, err := h.dbClient.ReadProjectWebhookEntry(h.scope(r).ctx, projectName, webhookID)
+	if errors.Is(err, upper.ErrNoMoreRows) {
+		h.errorResponse(w, "webhook not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		level.Error(l).Log("message", "error reading project webhook", "error", err)
+		h.errorResponse(w, "error reading project webhook", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func newProjectWebhookResponse(e db.ProjectWebhookEntry) responses.ProjectWebhook {
+	events := []string{}
+	if e.Events != "" {
+		events = strings.Split(e.Events, ",")
+	}
+	return responses.ProjectWebhook{
+		ID:        e.ID,
+		URL:       e.URL,
+		Events:    events,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// Queues a delivery of the event to each webhook of its project matching it,
+// delivered by watchWebhookDeliveries. Errors are logged as the event has
+// already been recorded.
+func (h handler) queueWebhookDeliveries(ctx context.Context, l log.Logger, event db.ExecutionEvent) {
+	if event.Project == "" {
+		return
+	}
+
+	webhooks, err := h.dbClient.ListProjectWebhookEntries(ctx, event.Project)
+	if err != nil {
+		level.Warn(l).Log("message", "error reading project webhooks", "type", event.Type, "error", err)
+		return
+	}
+
+	for _, wh := range webhooks {
+		var filters []string
+		if wh.Events != "" {
+			filters = strings.Split(wh.Events, ",")
+		}
+		if !webhook.Matches(filters, event.Type) {
+			continue
+		}
+
+		id := uuid.NewString()
+		payload, err := json.Marshal(webhook.Payload{
+			DeliveryID:   id,
+			Type:         event.Type,
+			Time:         event.CreatedAt.UTC(),
+			TxID:         event.TxID,
+			Project:      event.Project,
+			Target:       event.Target,
+			WorkflowName: event.WorkflowName,
+			Message:      h.redactor.String(event.Message),
+		})
+		if err != nil {
+			level.Warn(l).Log("message", "error creating webhook payload", "webhook", wh.ID, "error", err)
+			continue
+		}
+
+		now := h.now().UTC()
+		if err := h.dbClient.CreateWebhookDeliveryEntry(ctx, db.WebhookDeliveryEntry{
+			ID:            id,
+			WebhookID:     wh.ID,
+			Project:       wh.Project,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        webhook.StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}); err != nil {
+			level.Warn(l).Log("message", "error queueing webhook delivery", "webhook", wh.ID, "error", err)
+		}
+	}
+}
+
+// Delivers the due webhook deliveries every interval until ctx is done.
+func (h handler) watchWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	t := h.clock.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if err := h.deliverWebhooks(ctx); err != nil {
+			level.Error(h.logger).Log("message", "error delivering webhooks", "error", err)
+		}
+	}
+}
+
+// Attempts the pending deliveries which are due, oldest first. Failed
+// attempts are retried with backoff until the max attempts, deliveries of
+// deleted webhooks fail.
+func (h handler) deliverWebhooks(ctx context.Context) error {
+	if h.webhooks == nil {
+		return nil
+	}
+
+	entries, err := h.dbClient.ListDueWebhookDeliveryEntries(ctx, h.now().UTC())
+	if err != nil {
+		return fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+
+	for _, e := range entries {
+		l := log.With(h.logger, "op", "deliver-webhook", "project", e.Project, "webhook", e.WebhookID, "delivery", e.ID)
+
+		wh, err := h.dbClient.ReadProjectWebhookEntry(ctx, e.Project, e.WebhookID)
+		switch {
+		case errors.Is(err, upper.ErrNoMoreRows):
+			e.Status = webhook.StatusFailed
+			e.Error = "webhook was deleted"
+		case err != nil:
+			level.Error(l).Log("message", "error reading project webhook", "error", err)
+			continue
+		default:
+			e.Attempts++
+			e.ResponseCode, err = h.webhooks.Send(ctx, wh.URL, wh.Secret, e.ID, e.EventType, []byte(e.Payload))
+			e.Error = ""
+			switch {
+			case err == nil:
+				e.Status = webhook.StatusSucceeded
+				level.Info(l).Log("message", "webhook delivered", "attempts", e.Attempts)
+			case e.Attempts >= h.env.WebhookMaxAttempts:
+				e.Status = webhook.StatusFailed
+				e.Error = h.redactor.String(err.Error())
+				level.Warn(l).Log("message", "webhook delivery failed", "attempts", e.Attempts, "error", err)
+			default:
+				e.Error = h.redactor.String(err.Error())
+				e.NextAttemptAt = h.now().UTC().Add(webhook.Backoff(e.Attempts, h.env.WebhookInitialBackoff, h.env.WebhookMaxBackoff))
+				level.Debug(l).Log("message", "webhook delivery attempt failed", "attempts", e.Attempts, "error", err)
+			}
+		}
+
+		e.UpdatedAt = h.now().UTC()
+		if err := h.dbClient.UpdateWebhookDeliveryEntry(ctx, e); err != nil {
+			level.Error(l).Log("message", "error updating webhook delivery", "error", err)
+		}
+	}
+	return nil
+}